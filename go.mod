@@ -3,14 +3,14 @@ module media-proxy-go
 go 1.25
 
 require (
+	github.com/andybalholm/brotli v1.0.6
 	github.com/refraction-networking/utls v1.8.2
+	golang.org/x/crypto v0.36.0
 	golang.org/x/net v0.38.0
 )
 
 require (
-	github.com/andybalholm/brotli v1.0.6 // indirect
 	github.com/klauspost/compress v1.17.4 // indirect
-	golang.org/x/crypto v0.36.0 // indirect
 	golang.org/x/sys v0.31.0 // indirect
 	golang.org/x/text v0.23.0 // indirect
 )
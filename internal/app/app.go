@@ -2,19 +2,37 @@
 package app
 
 import (
+	"context"
+
 	"media-proxy-go/pkg/appctx"
+	"media-proxy-go/pkg/browser"
+	"media-proxy-go/pkg/channels"
 	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/cookies"
+	"media-proxy-go/pkg/diskcache"
+	"media-proxy-go/pkg/export"
 	"media-proxy-go/pkg/extractors"
 	"media-proxy-go/pkg/flaresolverr"
 	"media-proxy-go/pkg/handlers/api"
 	"media-proxy-go/pkg/handlers/streams"
+	"media-proxy-go/pkg/hdhomerun"
+	"media-proxy-go/pkg/health"
 	"media-proxy-go/pkg/httpclient"
 	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/keys"
 	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/notify"
+	"media-proxy-go/pkg/playtoken"
 	"media-proxy-go/pkg/registry"
+	"media-proxy-go/pkg/rules"
 	"media-proxy-go/pkg/server"
 	"media-proxy-go/pkg/services"
+	"media-proxy-go/pkg/sessions"
+	"media-proxy-go/pkg/streamstats"
 	"media-proxy-go/pkg/stremio"
+	"media-proxy-go/pkg/tracing"
+	"media-proxy-go/pkg/types"
+	"media-proxy-go/pkg/urlcrypt"
 )
 
 // App is the main application container.
@@ -24,6 +42,7 @@ type App struct {
 	HTTPClient     *httpclient.Client
 	StreamHandlers *registry.StreamHandlerRegistry
 	ExtractorReg   *registry.ExtractorRegistry
+	Tracer         *tracing.Tracer
 }
 
 // New creates and initializes the application.
@@ -42,65 +61,222 @@ func New() (*App, error) {
 	httpClient := httpclient.New(cfg, log)
 	ctx.WithHTTPClient(httpClient)
 
+	// Share cookies (e.g. cf_clearance) across extractors and restarts
+	cookieJar := cookies.NewJar(cfg.CookiesFile, log)
+	if err := cookieJar.Load(); err != nil {
+		log.Warn("failed to load cookies", "path", cfg.CookiesFile, "error", err)
+	}
+	httpClient.SetCookieJar(cookieJar)
+
 	// Initialize stream handler registry
 	streamHandlers := registry.NewStreamHandlerRegistry()
 
 	// Initialize extractor registry
 	extractorReg := registry.NewExtractorRegistry()
 
+	// Probe FFmpeg once, so a missing binary or filter disables the features
+	// needing it here rather than failing every request
+	ffmpegInfo := services.CheckFFmpeg(cfg.FFmpegPath)
+	ctx.WithFFmpeg(&ffmpegInfo)
+	switch {
+	case !ffmpegInfo.Available:
+		log.Warn("FFmpeg unavailable, decrypt remux, recording, transcoding and HDHomeRun disabled",
+			"path", cfg.FFmpegPath, "error", ffmpegInfo.Error)
+	case len(ffmpegInfo.Missing) > 0:
+		log.Warn("FFmpeg build lacks required components, dependent features disabled",
+			"version", ffmpegInfo.Version, "missing", ffmpegInfo.Missing, "features", ffmpegInfo.Features)
+	default:
+		log.Info("FFmpeg found", "path", cfg.FFmpegPath, "version", ffmpegInfo.Version)
+	}
+
 	// Initialize FFmpeg transcoder
-	ffmpegTranscoder, err := services.NewFFmpegTranscoder(cfg, log)
-	if err != nil {
-		log.Warn("failed to initialize FFmpeg transcoder", "error", err)
-	} else {
-		ctx.WithTranscoder(ffmpegTranscoder)
+	if ctx.FFmpegSupports(types.FeatureTranscode) {
+		ffmpegTranscoder, err := services.NewFFmpegTranscoder(cfg, log)
+		if err != nil {
+			log.Warn("failed to initialize FFmpeg transcoder", "error", err)
+		} else {
+			ctx.WithTranscoder(ffmpegTranscoder)
+		}
+	}
+
+	// ClearKey key store, consulted when a protected stream has no clearkey
+	keyStore := keys.NewStore(cfg.KeysFile, log)
+	if err := keyStore.Load(); err != nil {
+		log.Warn("failed to load key store", "path", cfg.KeysFile, "error", err)
 	}
+	ctx.WithKeys(keyStore)
 
 	// Register stream handlers
-	registerStreamHandlers(streamHandlers, httpClient, log, ctx.BaseURL, ctx.Transcoder)
+	registerStreamHandlers(streamHandlers, httpClient, log, ctx.BaseURL, ctx.Transcoder, keyStore)
 
-	// Create FlareSolverr client if configured
-	var flareClient *flaresolverr.Client
+	// Create Cloudflare solver client if configured
+	var flareClient flaresolverr.Solver
 	if cfg.FlareSolverrURL != "" {
-		flareClient = flaresolverr.NewClient(cfg.FlareSolverrURL, cfg.FlareSolverrTimeout, log)
-		log.Info("FlareSolverr client enabled", "url", cfg.FlareSolverrURL)
+		solver, err := flaresolverr.NewSolver(cfg.CFSolver, cfg.FlareSolverrURL, cfg.FlareSolverrTimeout, log)
+		if err != nil {
+			log.Warn("failed to initialize Cloudflare solver", "error", err)
+		} else {
+			flareClient = solver
+			log.Info("Cloudflare solver enabled", "solver", solver.Name(), "url", cfg.FlareSolverrURL)
+		}
 	}
 
 	// Register extractors
-	registerExtractors(extractorReg, httpClient, log, flareClient)
+	registerExtractors(extractorReg, httpClient, log, flareClient, cfg)
+
+	// Patterns, URL templates and headers of the extractors from a signed
+	// bundle, loaded in the background so a slow host doesn't hold up startup
+	if cfg.RulesURL != "" {
+		var consumers []interfaces.RulesConsumer
+		for _, e := range extractorReg.All() {
+			if c, ok := e.(interfaces.RulesConsumer); ok {
+				consumers = append(consumers, c)
+			}
+		}
+		if loader, err := rules.NewLoader(cfg.RulesURL, cfg.RulesPublicKey, httpClient, consumers, log); err != nil {
+			log.Warn("failed to initialize extraction rules", "error", err)
+		} else {
+			ctx.WithRules(loader)
+			go func() {
+				if err := loader.Load(context.Background()); err != nil {
+					log.Warn("failed to load extraction rules", "url", cfg.RulesURL, "error", err)
+				}
+			}()
+		}
+	}
 
-	// Initialize recording manager (needs baseURL to route recordings through local proxy)
-	rm, err := services.NewRecordingManager(cfg, log, ctx.BaseURL)
-	if err != nil {
+	// Event broker for live dashboard updates (/api/events)
+	events := notify.NewBroker()
+	ctx.WithEvents(events)
+
+	// Initialize recording manager (needs baseURL to route recordings through local proxy
+	// and the extractor registry to resolve dlhd/vavoo links)
+	if !ctx.FFmpegSupports(types.FeatureRecording) {
+		log.Warn("recording disabled, FFmpeg cannot write MPEG-TS")
+	} else if rm, err := services.NewRecordingManager(cfg, log, ctx.BaseURL, extractorReg); err != nil {
 		log.Warn("failed to initialize recording manager", "error", err)
 	} else {
+		notifiers := notify.Multi{events}
+		if len(cfg.WebhookURLs) > 0 {
+			notifiers = append(notifiers, notify.NewWebhook(cfg.WebhookURLs, cfg.WebhookTimeout, log))
+			log.Info("webhook notifications enabled", "urls", len(cfg.WebhookURLs))
+		}
+		rm.SetNotifier(notifiers)
+		if uploader, err := export.New(cfg, log); err != nil {
+			log.Warn("failed to initialize recording export", "error", err)
+		} else if uploader != nil {
+			rm.SetUploader(uploader)
+			log.Info("recording export enabled", "target", uploader.Name(), "delete_local", cfg.ExportDeleteLocal)
+		}
 		ctx.WithRecordingManager(rm)
 	}
 
+	// Load live channel list (playlist export, Stremio catalog)
+	channelStore := channels.NewStore(cfg.ChannelsFile, httpClient, log)
+	if err := channelStore.Load(); err != nil {
+		log.Warn("failed to load channel list", "path", cfg.ChannelsFile, "error", err)
+	}
+	// The source seeds an empty list only: later imports go through the API,
+	// so channels added or edited there survive restarts
+	if cfg.ChannelsSource != "" && channelStore.Count() == 0 {
+		if _, err := channelStore.Import(context.Background(), cfg.ChannelsSource); err != nil {
+			log.Warn("failed to import channel list", "source", cfg.ChannelsSource, "error", err)
+		}
+	} else if cfg.ChannelsSource != "" {
+		log.Info("channel list already stored, skipping import", "source", cfg.ChannelsSource, "count", channelStore.Count())
+	}
+	ctx.WithChannels(channelStore)
+
+	// Vavoo channel catalog (/api/vavoo/channels)
+	if catalog, ok := extractorReg.GetByName("vavoo").(interfaces.ChannelCatalog); ok {
+		ctx.WithVavooCatalog(catalog)
+	}
+
 	// Create proxy service
 	proxyService := services.NewProxyService(log, streamHandlers, extractorReg, ctx.BaseURL)
+	proxyService.SetNotifier(events)
+	if cfg.SegmentCacheDir != "" {
+		segmentCache := diskcache.New(cfg.SegmentCacheDir, cfg.SegmentCacheMaxSize, cfg.SegmentCacheMaxEntry, log)
+		if err := segmentCache.Load(); err != nil {
+			log.Warn("failed to initialize segment cache", "dir", cfg.SegmentCacheDir, "error", err)
+		} else {
+			proxyService.SetSegmentCache(segmentCache)
+		}
+	}
 	ctx.WithProxyService(proxyService)
 
+	// Track playback sessions (/api/sessions)
+	ctx.WithSessions(sessions.NewTracker(cfg.SessionIdleTimeout, cfg.TrustedProxies))
+
+	// Count segments, bytes and errors per stream (/api/stats/streams)
+	ctx.WithStreamStats(streamstats.NewCollector(cfg.StreamStatsRetention))
+
+	// Check saved channels in the background (/api/health/channels)
+	if cfg.HealthCheckInterval > 0 {
+		monitor := health.NewMonitor(cfg, channelStore, proxyService, log)
+		monitor.Start()
+		ctx.WithHealth(monitor)
+		log.Info("channel health monitor enabled", "interval", cfg.HealthCheckInterval, "favorites_only", cfg.HealthCheckFavorites)
+	}
+
 	// Create HTTP server
 	srv := server.New(cfg, log)
 
+	// Bind playback URLs in proxied manifests to the client they were served to
+	if cfg.PlaybackTokens {
+		signer := playtoken.NewSigner(cfg.PlaybackTokenSecret, cfg.PlaybackTokenTTL, cfg.PlaybackTokenBind)
+		ctx.WithPlaybackTokens(signer)
+		srv.SetPlaybackTokens(signer)
+		log.Info("playback tokens enabled", "bind", cfg.PlaybackTokenBind, "ttl", cfg.PlaybackTokenTTL)
+	}
+
+	// Encrypt the upstream URLs and headers of proxy URLs in manifests
+	if cfg.URLEncryptionKey != "" {
+		if cipher, err := urlcrypt.New(cfg.URLEncryptionKey); err != nil {
+			log.Warn("failed to initialize URL encryption", "error", err)
+		} else {
+			ctx.WithURLCipher(cipher)
+			srv.SetURLCipher(cipher)
+			log.Info("URL encryption enabled")
+		}
+	}
+
+	// Export request traces to an OpenTelemetry collector
+	var tracer *tracing.Tracer
+	if cfg.OTLPEndpoint != "" {
+		tracer = tracing.New(cfg, log)
+		tracer.Start()
+		srv.SetTracer(tracer)
+		log.Info("tracing enabled", "endpoint", cfg.OTLPEndpoint, "sample_percent", cfg.TraceSamplePercent)
+	}
+
 	// Create API handlers
 	handlers := api.NewHandlers(ctx)
 	handlers.RegisterRoutes(srv.Router())
 
-	// Register Stremio addon routes (if enabled and DVR is available)
-	if cfg.StremioEnabled && ctx.RecordingManager != nil {
+	// Register Stremio addon routes (serves recordings and/or live channels)
+	if cfg.StremioEnabled {
 		stremioHandlers := stremio.NewHandlers(ctx)
 		stremioHandlers.RegisterRoutes(srv.Router())
 		log.Info("stremio addon enabled", "path", "/stremio")
 	}
 
+	// Register HDHomeRun tuner emulation (Plex/Jellyfin/Emby Live TV)
+	if cfg.HDHomeRunEnabled && !ctx.FFmpegSupports(types.FeatureHDHomeRun) {
+		log.Warn("hdhomerun emulation disabled, FFmpeg cannot write MPEG-TS")
+	} else if cfg.HDHomeRunEnabled {
+		hdhrHandlers := hdhomerun.NewHandlers(ctx)
+		hdhrHandlers.RegisterRoutes(srv.Router())
+		log.Info("hdhomerun emulation enabled", "discover", ctx.BaseURL+"/discover.json")
+	}
+
 	return &App{
 		Ctx:            ctx,
 		Server:         srv,
 		HTTPClient:     httpClient,
 		StreamHandlers: streamHandlers,
 		ExtractorReg:   extractorReg,
+		Tracer:         tracer,
 	}, nil
 }
 
@@ -122,7 +298,15 @@ func (a *App) Shutdown() {
 		a.Ctx.RecordingManager.Close()
 	}
 
+	if a.Ctx.Health != nil {
+		a.Ctx.Health.Close()
+	}
+
 	a.ExtractorReg.Close()
+
+	if a.Tracer != nil {
+		a.Tracer.Close()
+	}
 }
 
 // registerStreamHandlers registers all stream handlers.
@@ -135,6 +319,7 @@ func registerStreamHandlers(
 	log *logging.Logger,
 	baseURL string,
 	transcoder interfaces.Transcoder,
+	keyStore interfaces.KeyStore,
 ) {
 	// Register HLS handler
 	hlsHandler := streams.NewHLSHandler(client, log, baseURL)
@@ -142,6 +327,7 @@ func registerStreamHandlers(
 
 	// Register MPD handler
 	mpdHandler := streams.NewMPDHandler(client, log, baseURL, transcoder)
+	mpdHandler.SetKeyStore(keyStore)
 	reg.Register(mpdHandler)
 
 	// Register generic handler as fallback
@@ -159,8 +345,19 @@ func registerExtractors(
 	reg *registry.ExtractorRegistry,
 	client *httpclient.Client,
 	log *logging.Logger,
-	flareClient *flaresolverr.Client,
+	flareClient flaresolverr.Solver,
+	cfg *config.Config,
 ) {
+	// Register user plugins first so they can override built-in extractors
+	plugins, err := extractors.LoadPlugins(cfg.ExtractorsDir, client, log)
+	if err != nil {
+		log.Warn("failed to load extractor plugins", "dir", cfg.ExtractorsDir, "error", err)
+	}
+	for _, plugin := range plugins {
+		reg.Register(plugin)
+		log.Info("loaded extractor plugin", "name", plugin.Name())
+	}
+
 	// Register Vavoo extractor
 	vavooExtractor := extractors.NewVavooExtractor(client, log)
 	reg.Register(vavooExtractor)
@@ -179,11 +376,38 @@ func registerExtractors(
 
 	// Register DLHD extractor (dlhd.dad/daddylive)
 	dlhdExtractor := extractors.NewDLHDExtractor(client, log, flareClient)
+	if err := dlhdExtractor.SetTemplates(extractors.DLHDTemplates{
+		Server:  cfg.DLHDServerURLTemplate,
+		Default: cfg.DLHDDefaultURLTemplate,
+	}); err != nil {
+		log.Warn("invalid DLHD URL template, using the built-in ones", "error", err)
+	}
 	reg.Register(dlhdExtractor)
 
+	// Register YouTube extractor (live/watch/embed URLs)
+	youtubeExtractor := extractors.NewYouTubeExtractor(client, log)
+	reg.Register(youtubeExtractor)
+
+	// Register Twitch extractor (channels and VODs)
+	twitchExtractor := extractors.NewTwitchExtractor(client, log)
+	reg.Register(twitchExtractor)
+
 	// Set generic extractor as fallback
 	genericExtractor := extractors.NewGenericExtractor(client, log)
+
+	// Register headless browser extractor, optionally the fallback's last resort
+	if cfg.BrowserPath != "" || cfg.BrowserURL != "" {
+		b := browser.New(cfg.BrowserPath, cfg.BrowserURL, cfg.BrowserMaxPages, log)
+		browserExtractor := extractors.NewBrowserExtractor(b, cfg.BrowserTimeout, log)
+		reg.Register(browserExtractor)
+		if cfg.BrowserFallback {
+			genericExtractor.SetBrowser(browserExtractor)
+		}
+		log.Info("headless browser extraction enabled", "path", cfg.BrowserPath, "url", cfg.BrowserURL,
+			"max_pages", cfg.BrowserMaxPages, "fallback", cfg.BrowserFallback)
+	}
 	reg.SetFallback(genericExtractor)
 
+	reg.SetLimits(cfg.ExtractorLimits)
 	log.Info("registered extractors", "count", len(reg.All())+1) // +1 for fallback
 }
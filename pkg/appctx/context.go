@@ -2,10 +2,20 @@
 package appctx
 
 import (
+	"media-proxy-go/pkg/channels"
 	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/health"
 	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/keys"
 	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/notify"
+	"media-proxy-go/pkg/playtoken"
+	"media-proxy-go/pkg/rules"
 	"media-proxy-go/pkg/services"
+	"media-proxy-go/pkg/sessions"
+	"media-proxy-go/pkg/streamstats"
+	"media-proxy-go/pkg/types"
+	"media-proxy-go/pkg/urlcrypt"
 )
 
 // Context holds all application runtime dependencies.
@@ -14,9 +24,20 @@ type Context struct {
 	Config           *config.Config
 	Log              *logging.Logger
 	ProxyService     *services.ProxyService
+	FFmpeg           *types.FFmpegInfo // Startup probe; nil when not checked
 	Transcoder       interfaces.Transcoder
 	RecordingManager interfaces.RecordingManager
 	HTTPClient       interfaces.HTTPClient
+	Channels         *channels.Store
+	Events           *notify.Broker
+	Sessions         *sessions.Tracker
+	StreamStats      *streamstats.Collector
+	VavooCatalog     interfaces.ChannelCatalog
+	Keys             *keys.Store
+	Health           *health.Monitor
+	PlaybackTokens   *playtoken.Signer // Nil unless PLAYBACK_TOKENS is enabled
+	URLCipher        *urlcrypt.Cipher  // Nil unless URL_ENCRYPTION_KEY is set
+	Rules            *rules.Loader     // Nil unless RULES_URL is set
 	BaseURL          string
 }
 
@@ -35,6 +56,36 @@ func (c *Context) WithProxyService(ps *services.ProxyService) *Context {
 	return c
 }
 
+// WithPlaybackTokens sets the playback token signer.
+func (c *Context) WithPlaybackTokens(s *playtoken.Signer) *Context {
+	c.PlaybackTokens = s
+	return c
+}
+
+// WithURLCipher sets the cipher of encrypted proxy URLs.
+func (c *Context) WithURLCipher(cipher *urlcrypt.Cipher) *Context {
+	c.URLCipher = cipher
+	return c
+}
+
+// WithRules sets the extraction rules loader.
+func (c *Context) WithRules(l *rules.Loader) *Context {
+	c.Rules = l
+	return c
+}
+
+// WithFFmpeg sets the result of the startup FFmpeg probe.
+func (c *Context) WithFFmpeg(info *types.FFmpegInfo) *Context {
+	c.FFmpeg = info
+	return c
+}
+
+// FFmpegSupports reports whether the probed FFmpeg build can serve feature
+// (a types.Feature* name). Without a probe FFmpeg is assumed to work.
+func (c *Context) FFmpegSupports(feature string) bool {
+	return c.FFmpeg == nil || c.FFmpeg.Features[feature]
+}
+
 // WithTranscoder sets the transcoder.
 func (c *Context) WithTranscoder(t interfaces.Transcoder) *Context {
 	c.Transcoder = t
@@ -52,3 +103,45 @@ func (c *Context) WithHTTPClient(client interfaces.HTTPClient) *Context {
 	c.HTTPClient = client
 	return c
 }
+
+// WithChannels sets the live channel store.
+func (c *Context) WithChannels(store *channels.Store) *Context {
+	c.Channels = store
+	return c
+}
+
+// WithEvents sets the event broker used for live dashboard updates.
+func (c *Context) WithEvents(b *notify.Broker) *Context {
+	c.Events = b
+	return c
+}
+
+// WithSessions sets the playback session tracker.
+func (c *Context) WithSessions(t *sessions.Tracker) *Context {
+	c.Sessions = t
+	return c
+}
+
+// WithStreamStats sets the per-stream statistics collector.
+func (c *Context) WithStreamStats(s *streamstats.Collector) *Context {
+	c.StreamStats = s
+	return c
+}
+
+// WithVavooCatalog sets the Vavoo channel catalog.
+func (c *Context) WithVavooCatalog(catalog interfaces.ChannelCatalog) *Context {
+	c.VavooCatalog = catalog
+	return c
+}
+
+// WithKeys sets the ClearKey key store.
+func (c *Context) WithKeys(store *keys.Store) *Context {
+	c.Keys = store
+	return c
+}
+
+// WithHealth sets the channel health monitor.
+func (c *Context) WithHealth(m *health.Monitor) *Context {
+	c.Health = m
+	return c
+}
@@ -0,0 +1,366 @@
+// Package browser drives a headless Chrome or Chromium over the DevTools
+// protocol, to load player pages whose stream URLs and tokens are computed in
+// JavaScript and capture the manifest request the player makes.
+package browser
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"media-proxy-go/pkg/logging"
+)
+
+// startTimeout bounds the wait for a launched browser's DevTools endpoint.
+const startTimeout = 20 * time.Second
+
+// ErrNoManifest is returned when a page made no manifest request in time.
+var ErrNoManifest = errors.New("no manifest request captured")
+
+// launchFlags are the command line flags of a launched browser, besides the
+// DevTools port and profile directory.
+var launchFlags = []string{
+	"--headless=new",
+	"--disable-gpu",
+	"--no-first-run",
+	"--no-default-browser-check",
+	"--mute-audio",
+	"--autoplay-policy=no-user-gesture-required",
+	"--remote-allow-origins=*",
+}
+
+// manifestMIMETypes identify manifests served from URLs without an extension.
+var manifestMIMETypes = []string{"mpegurl", "dash+xml"}
+
+// Request is a manifest request captured from a page.
+type Request struct {
+	URL     string
+	Headers map[string]string // As sent by the browser, cookies included
+}
+
+// Browser captures manifest requests of pages in a headless browser, either
+// launched on first use or already running at a DevTools endpoint. Pages are
+// loaded in their own tabs, up to a limit in parallel.
+type Browser struct {
+	path     string        // Executable launched when endpoint is empty
+	endpoint string        // DevTools HTTP or WebSocket endpoint of a running browser
+	pages    chan struct{} // Open tabs, bounded by its capacity
+	log      *logging.Logger
+
+	mu      sync.Mutex
+	conn    *conn
+	cmd     *exec.Cmd
+	profile string // Profile directory of the launched browser
+}
+
+// New creates a browser launching the executable at path, or connecting to
+// the DevTools endpoint (http://host:9222 or a ws:// URL) when set. At most
+// maxPages pages are open at once.
+func New(path, endpoint string, maxPages int, log *logging.Logger) *Browser {
+	return &Browser{
+		path:     path,
+		endpoint: endpoint,
+		pages:    make(chan struct{}, max(maxPages, 1)),
+		log:      log.WithComponent("browser"),
+	}
+}
+
+// Capture loads pageURL, sending headers with its requests, and returns the
+// first HLS or DASH manifest request of the page. It waits until ctx ends,
+// for a free page first when the limit is reached.
+func (b *Browser) Capture(ctx context.Context, pageURL string, headers map[string]string) (*Request, error) {
+	select {
+	case b.pages <- struct{}{}:
+		defer func() { <-b.pages }()
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no free browser page: %w", ctx.Err())
+	}
+
+	c, err := b.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var target struct {
+		TargetID string `json:"targetId"`
+	}
+	if err := c.call(ctx, "", "Target.createTarget", map[string]any{"url": "about:blank"}, &target); err != nil {
+		return nil, err
+	}
+	defer func() {
+		// The tab is closed even when ctx ended
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		c.call(closeCtx, "", "Target.closeTarget", map[string]any{"targetId": target.TargetID}, nil)
+	}()
+
+	var session struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := c.call(ctx, "", "Target.attachToTarget", map[string]any{"targetId": target.TargetID, "flatten": true}, &session); err != nil {
+		return nil, err
+	}
+	events := c.subscribe(session.SessionID)
+	defer c.unsubscribe(session.SessionID)
+
+	if err := c.call(ctx, session.SessionID, "Network.enable", map[string]any{}, nil); err != nil {
+		return nil, err
+	}
+	extra := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if strings.EqualFold(name, "User-Agent") {
+			if err := c.call(ctx, session.SessionID, "Network.setUserAgentOverride", map[string]any{"userAgent": value}, nil); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		extra[name] = value
+	}
+	if len(extra) > 0 {
+		if err := c.call(ctx, session.SessionID, "Network.setExtraHTTPHeaders", map[string]any{"headers": extra}, nil); err != nil {
+			return nil, err
+		}
+	}
+	var nav struct {
+		ErrorText string `json:"errorText"`
+	}
+	if err := c.call(ctx, session.SessionID, "Page.navigate", map[string]any{"url": pageURL}, &nav); err != nil {
+		return nil, err
+	}
+	if nav.ErrorText != "" {
+		return nil, fmt.Errorf("failed to load %s: %s", pageURL, nav.ErrorText)
+	}
+
+	req, err := waitManifest(ctx, events)
+	if err != nil {
+		return nil, err
+	}
+
+	var cookies struct {
+		Cookies []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"cookies"`
+	}
+	if err := c.call(ctx, session.SessionID, "Network.getCookies", map[string]any{"urls": []string{req.URL}}, &cookies); err == nil && len(cookies.Cookies) > 0 {
+		pairs := make([]string, len(cookies.Cookies))
+		for i, cookie := range cookies.Cookies {
+			pairs[i] = cookie.Name + "=" + cookie.Value
+		}
+		req.Headers["Cookie"] = strings.Join(pairs, "; ")
+	}
+	b.log.Debug("captured manifest request", "page", pageURL, "url", req.URL)
+	return req, nil
+}
+
+// waitManifest returns the first manifest request among a page's events: a
+// request for a .m3u8 or .mpd URL, or one answered with a manifest type.
+func waitManifest(ctx context.Context, events <-chan *message) (*Request, error) {
+	requests := make(map[string]*Request) // By request ID
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ErrNoManifest
+		case ev := <-events:
+			switch ev.Method {
+			case "Network.requestWillBeSent":
+				var p struct {
+					RequestID string `json:"requestId"`
+					Request   struct {
+						URL     string            `json:"url"`
+						Headers map[string]string `json:"headers"`
+					} `json:"request"`
+				}
+				if json.Unmarshal(ev.Params, &p) != nil || !strings.HasPrefix(p.Request.URL, "http") {
+					continue
+				}
+				req := &Request{URL: p.Request.URL, Headers: p.Request.Headers}
+				if req.Headers == nil {
+					req.Headers = make(map[string]string)
+				}
+				if isManifestURL(req.URL) {
+					return req, nil
+				}
+				requests[p.RequestID] = req
+			case "Network.responseReceived":
+				var p struct {
+					RequestID string `json:"requestId"`
+					Response  struct {
+						MIMEType string `json:"mimeType"`
+					} `json:"response"`
+				}
+				if json.Unmarshal(ev.Params, &p) != nil {
+					continue
+				}
+				req, ok := requests[p.RequestID]
+				delete(requests, p.RequestID)
+				if ok && isManifestType(p.Response.MIMEType) {
+					return req, nil
+				}
+			}
+		}
+	}
+}
+
+// isManifestURL reports whether u points to an HLS or DASH manifest.
+func isManifestURL(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	p := strings.ToLower(parsed.Path)
+	return strings.HasSuffix(p, ".m3u8") || strings.HasSuffix(p, ".mpd")
+}
+
+// isManifestType reports whether a MIME type is that of a manifest.
+func isManifestType(mimeType string) bool {
+	mimeType = strings.ToLower(mimeType)
+	for _, t := range manifestMIMETypes {
+		if strings.Contains(mimeType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// connection returns the DevTools connection, connecting or launching the
+// browser on first use or after it went away.
+func (b *Browser) connection(ctx context.Context) (*conn, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn != nil && b.conn.alive() {
+		return b.conn, nil
+	}
+	b.stop()
+
+	wsURL := b.endpoint
+	if wsURL == "" {
+		var err error
+		if wsURL, err = b.launch(); err != nil {
+			return nil, err
+		}
+	} else if !strings.HasPrefix(wsURL, "ws://") && !strings.HasPrefix(wsURL, "wss://") {
+		var err error
+		if wsURL, err = debuggerURL(ctx, b.endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	c, err := dial(ctx, wsURL)
+	if err != nil {
+		b.stop()
+		return nil, err
+	}
+	b.conn = c
+	return c, nil
+}
+
+// launch starts the browser and returns its DevTools WebSocket URL, which
+// it prints on stderr. The caller holds mu.
+func (b *Browser) launch() (string, error) {
+	if b.path == "" {
+		return "", errors.New("no browser configured")
+	}
+	profile, err := os.MkdirTemp("", "media-proxy-browser-")
+	if err != nil {
+		return "", err
+	}
+	args := append([]string{"--remote-debugging-port=0", "--user-data-dir=" + profile}, launchFlags...)
+	if os.Geteuid() == 0 {
+		args = append(args, "--no-sandbox") // Chrome refuses to run as root with its sandbox
+	}
+	cmd := exec.Command(b.path, append(args, "about:blank")...)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		os.RemoveAll(profile)
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(profile)
+		return "", fmt.Errorf("failed to start browser: %w", err)
+	}
+	b.cmd, b.profile = cmd, profile
+
+	found := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			if u, ok := strings.CutPrefix(scanner.Text(), "DevTools listening on "); ok {
+				found <- strings.TrimSpace(u)
+				break
+			}
+		}
+		close(found)
+		io.Copy(io.Discard, stderr) // Don't block the browser on a full pipe
+	}()
+
+	select {
+	case u, ok := <-found:
+		if !ok {
+			b.stop()
+			return "", errors.New("browser exited before its DevTools endpoint was up")
+		}
+		b.log.Info("started headless browser", "path", b.path, "pid", cmd.Process.Pid)
+		return u, nil
+	case <-time.After(startTimeout):
+		b.stop()
+		return "", errors.New("timed out waiting for the browser's DevTools endpoint")
+	}
+}
+
+// debuggerURL asks a running browser's DevTools HTTP endpoint for its
+// WebSocket URL.
+func debuggerURL(ctx context.Context, endpoint string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(endpoint, "/")+"/json/version", nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid browser endpoint: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach browser: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var version struct {
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil || version.WebSocketDebuggerURL == "" {
+		return "", fmt.Errorf("browser endpoint %s returned no WebSocket URL", endpoint)
+	}
+	return version.WebSocketDebuggerURL, nil
+}
+
+// stop closes the connection and the launched browser. The caller holds mu.
+func (b *Browser) stop() {
+	if b.conn != nil {
+		b.conn.close()
+		b.conn = nil
+	}
+	if b.cmd != nil {
+		b.cmd.Process.Kill()
+		b.cmd.Wait()
+		b.cmd = nil
+	}
+	if b.profile != "" {
+		os.RemoveAll(b.profile)
+		b.profile = ""
+	}
+}
+
+// Close closes the browser, if launched, or the connection to it.
+func (b *Browser) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stop()
+	return nil
+}
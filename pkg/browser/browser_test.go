@@ -0,0 +1,174 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"media-proxy-go/pkg/logging"
+)
+
+// fakeDevTools serves the DevTools protocol the way Chrome does for a page
+// whose player requests the given URLs, each answered with its MIME type.
+func fakeDevTools(t *testing.T, requests [][2]string) (*httptest.Server, chan map[string]any) {
+	t.Helper()
+	commands := make(chan map[string]any, 64)
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	mux.HandleFunc("/json/version", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"webSocketDebuggerUrl": "ws" + strings.TrimPrefix(srv.URL, "http") + "/devtools/browser/1",
+		})
+	})
+	mux.Handle("/devtools/browser/1", websocket.Handler(func(ws *websocket.Conn) {
+		for {
+			var cmd message
+			if err := websocket.JSON.Receive(ws, &cmd); err != nil {
+				return
+			}
+			var params map[string]any
+			json.Unmarshal(cmd.Params, &params)
+			commands <- map[string]any{"method": cmd.Method, "session": cmd.SessionID, "params": params}
+
+			var result any = map[string]any{}
+			switch cmd.Method {
+			case "Target.createTarget":
+				result = map[string]string{"targetId": "T1"}
+			case "Target.attachToTarget":
+				result = map[string]string{"sessionId": "S1"}
+			case "Network.getCookies":
+				result = map[string]any{"cookies": []map[string]string{{"name": "auth", "value": "xyz"}}}
+			}
+			raw, _ := json.Marshal(result)
+			websocket.JSON.Send(ws, message{ID: cmd.ID, SessionID: cmd.SessionID, Result: raw})
+
+			if cmd.Method == "Page.navigate" {
+				for i, r := range requests {
+					id := string(rune('a' + i))
+					params, _ := json.Marshal(map[string]any{
+						"requestId": id,
+						"request":   map[string]any{"url": r[0], "headers": map[string]string{"Referer": "https://player.example/"}},
+					})
+					websocket.JSON.Send(ws, message{SessionID: "S1", Method: "Network.requestWillBeSent", Params: params})
+					params, _ = json.Marshal(map[string]any{"requestId": id, "response": map[string]string{"mimeType": r[1]}})
+					websocket.JSON.Send(ws, message{SessionID: "S1", Method: "Network.responseReceived", Params: params})
+				}
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, commands
+}
+
+func TestBrowser_Capture(t *testing.T) {
+	srv, commands := fakeDevTools(t, [][2]string{
+		{"https://player.example/embed/1", "text/html"},
+		{"https://player.example/app.js", "application/javascript"},
+		{"https://cdn.example/live/index.m3u8?token=abc", "application/vnd.apple.mpegurl"},
+	})
+	b := New("", srv.URL, 2, logging.New("error", false, io.Discard))
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := b.Capture(ctx, "https://site.example/watch/1", map[string]string{
+		"User-Agent": "TestAgent/1.0",
+		"Referer":    "https://site.example/",
+	})
+	if err != nil {
+		t.Fatalf("Capture() error = %v", err)
+	}
+	if req.URL != "https://cdn.example/live/index.m3u8?token=abc" {
+		t.Errorf("URL = %q", req.URL)
+	}
+	if req.Headers["Referer"] != "https://player.example/" || req.Headers["Cookie"] != "auth=xyz" {
+		t.Errorf("headers = %v", req.Headers)
+	}
+
+	seen := map[string]map[string]any{}
+	for len(commands) > 0 {
+		cmd := <-commands
+		seen[cmd["method"].(string)] = cmd
+	}
+	if ua := seen["Network.setUserAgentOverride"]; ua == nil || ua["params"].(map[string]any)["userAgent"] != "TestAgent/1.0" {
+		t.Errorf("user agent override = %v", ua)
+	}
+	if nav := seen["Page.navigate"]; nav == nil || nav["session"] != "S1" {
+		t.Errorf("navigate = %v, want it sent to the page session", nav)
+	}
+	if seen["Target.closeTarget"] == nil {
+		t.Error("the tab wasn't closed")
+	}
+}
+
+func TestBrowser_Capture_ByMIMEType(t *testing.T) {
+	srv, _ := fakeDevTools(t, [][2]string{
+		{"https://player.example/embed/1", "text/html"},
+		{"https://cdn.example/playlist?id=7", "application/dash+xml"},
+	})
+	b := New("", srv.URL, 2, logging.New("error", false, io.Discard))
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := b.Capture(ctx, "https://site.example/watch/1", nil)
+	if err != nil {
+		t.Fatalf("Capture() error = %v", err)
+	}
+	if req.URL != "https://cdn.example/playlist?id=7" {
+		t.Errorf("URL = %q, want the request answered with an MPD", req.URL)
+	}
+}
+
+func TestBrowser_Capture_NoManifest(t *testing.T) {
+	srv, _ := fakeDevTools(t, [][2]string{{"https://player.example/embed/1", "text/html"}})
+	b := New("", srv.URL, 2, logging.New("error", false, io.Discard))
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := b.Capture(ctx, "https://site.example/watch/1", nil); !errors.Is(err, ErrNoManifest) {
+		t.Errorf("Capture() error = %v, want ErrNoManifest", err)
+	}
+}
+
+func TestBrowser_Capture_PageLimit(t *testing.T) {
+	srv, commands := fakeDevTools(t, [][2]string{
+		{"https://cdn.example/live/index.m3u8", "application/vnd.apple.mpegurl"},
+	})
+	b := New("", srv.URL, 1, logging.New("error", false, io.Discard))
+	defer b.Close()
+
+	// The only page is taken: captures wait for it, then give up with ctx
+	b.pages <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := b.Capture(ctx, "https://site.example/watch/1", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Capture() over the limit error = %v, want DeadlineExceeded", err)
+	}
+	if len(commands) != 0 {
+		t.Errorf("Capture() over the limit sent %d commands, want none", len(commands))
+	}
+
+	<-b.pages
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := b.Capture(ctx, "https://site.example/watch/1", nil); err != nil {
+		t.Errorf("Capture() after the page was freed error = %v", err)
+	}
+}
+
+func TestBrowser_NotConfigured(t *testing.T) {
+	b := New("", "", 2, logging.New("error", false, io.Discard))
+	if _, err := b.Capture(context.Background(), "https://site.example/", nil); err == nil {
+		t.Error("Capture() without a browser succeeded")
+	}
+}
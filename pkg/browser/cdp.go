@@ -0,0 +1,174 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// eventBuffer is how many events of a page are queued for its capture.
+const eventBuffer = 1024
+
+// errClosed is returned for calls on a connection the browser closed.
+var errClosed = errors.New("browser connection closed")
+
+// message is a DevTools protocol message: a command, its response or an
+// event. Page commands and events carry the session ID of the page.
+type message struct {
+	ID        int64           `json:"id,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// conn is a DevTools protocol connection to the browser, shared by the pages
+// through flattened sessions.
+type conn struct {
+	ws *websocket.Conn
+
+	mu       sync.Mutex
+	nextID   int64
+	pending  map[int64]chan *message
+	sessions map[string]chan *message // Events by session
+	closed   bool
+
+	done chan struct{}
+}
+
+// dial connects to the browser's DevTools WebSocket endpoint.
+func dial(ctx context.Context, wsURL string) (*conn, error) {
+	config, err := websocket.NewConfig(wsURL, "http://localhost")
+	if err != nil {
+		return nil, err
+	}
+	ws, err := config.DialContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to browser: %w", err)
+	}
+	c := &conn{
+		ws:       ws,
+		pending:  make(map[int64]chan *message),
+		sessions: make(map[string]chan *message),
+		done:     make(chan struct{}),
+	}
+	go c.read()
+	return c, nil
+}
+
+// read dispatches the browser's messages until the connection closes.
+func (c *conn) read() {
+	defer c.close()
+	for {
+		var msg message
+		if err := websocket.JSON.Receive(c.ws, &msg); err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		if msg.ID != 0 {
+			if ch, ok := c.pending[msg.ID]; ok {
+				delete(c.pending, msg.ID)
+				ch <- &msg
+			}
+		} else if ch, ok := c.sessions[msg.SessionID]; ok && msg.SessionID != "" {
+			select {
+			case ch <- &msg:
+			default: // The capture is too far behind, drop the event
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// call sends a command, to the page of sessionID or to the browser when it is
+// empty, and decodes its result into result (if not nil).
+func (c *conn) call(ctx context.Context, sessionID, method string, params, result any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	ch := make(chan *message, 1)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errClosed
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	cmd := message{ID: id, SessionID: sessionID, Method: method, Params: raw}
+	if err := websocket.JSON.Send(c.ws, cmd); err != nil {
+		c.forget(id)
+		return fmt.Errorf("%s: %w", method, err)
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return fmt.Errorf("%s: %s", method, resp.Error.Message)
+		}
+		if result != nil {
+			return json.Unmarshal(resp.Result, result)
+		}
+		return nil
+	case <-c.done:
+		return errClosed
+	case <-ctx.Done():
+		c.forget(id)
+		return ctx.Err()
+	}
+}
+
+// forget drops the pending response of a command.
+func (c *conn) forget(id int64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// subscribe returns the events of a page until unsubscribe.
+func (c *conn) subscribe(sessionID string) <-chan *message {
+	ch := make(chan *message, eventBuffer)
+	c.mu.Lock()
+	c.sessions[sessionID] = ch
+	c.mu.Unlock()
+	return ch
+}
+
+// unsubscribe stops the events of a page.
+func (c *conn) unsubscribe(sessionID string) {
+	c.mu.Lock()
+	delete(c.sessions, sessionID)
+	c.mu.Unlock()
+}
+
+// alive reports whether the connection is still open.
+func (c *conn) alive() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.closed
+}
+
+// close closes the connection, failing the pending commands.
+func (c *conn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.ws.Close()
+	close(c.done)
+}
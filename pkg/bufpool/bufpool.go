@@ -0,0 +1,35 @@
+// Package bufpool reuses byte buffers across the segment pipeline. Fetching,
+// decrypting and remuxing a segment otherwise allocates several segment-sized
+// slices per request, which keeps the GC busy under concurrent playback.
+//
+// A buffer taken with Get is owned by the caller until it is handed back with
+// Put; neither the buffer nor slices of its contents may be used afterwards.
+package bufpool
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledSize is the largest buffer kept for reuse. Occasional huge
+// segments (e.g. a whole MP4 file) are left to the GC instead of pinning
+// their memory in the pool.
+const maxPooledSize = 32 << 20
+
+var pool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// Get returns an empty buffer.
+func Get() *bytes.Buffer {
+	return pool.Get().(*bytes.Buffer)
+}
+
+// Put returns b to the pool. A nil buffer is ignored.
+func Put(b *bytes.Buffer) {
+	if b == nil || b.Cap() > maxPooledSize {
+		return
+	}
+	b.Reset()
+	pool.Put(b)
+}
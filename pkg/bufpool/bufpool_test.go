@@ -0,0 +1,40 @@
+package bufpool
+
+import "testing"
+
+func TestPut(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		want bool // Kept for reuse
+	}{
+		{"small", 1 << 10, true},
+		{"at limit", maxPooledSize, true},
+		{"oversized", maxPooledSize + 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Get()
+			b.Grow(tt.size)
+			b.WriteString("data")
+			Put(b)
+
+			// The pool may drop buffers at any GC, so only a reused buffer is checked
+			for range 10 {
+				got := Get()
+				if got == b {
+					if !tt.want {
+						t.Error("oversized buffer was pooled")
+					}
+					if got.Len() != 0 {
+						t.Errorf("reused buffer has %d bytes, want 0", got.Len())
+					}
+					return
+				}
+			}
+		})
+	}
+
+	Put(nil) // Must not panic
+}
@@ -0,0 +1,233 @@
+package channels
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+const testPlaylist = `#EXTM3U
+#EXTINF:-1 tvg-id="news.uk" tvg-logo="https://logo.example.com/news.png" group-title="News",News, Live
+#EXTVLCOPT:http-user-agent=Mozilla/5.0
+#EXTVLCOPT:http-referrer=https://example.com/
+https://cdn.example.com/news/index.m3u8
+#EXTINF:-1 group-title="Sport",Sport 1
+#KODIPROP:inputstream.adaptive.license_type=clearkey
+#KODIPROP:inputstream.adaptive.license_key=0123456789abcdef0123456789abcdef:fedcba9876543210fedcba9876543210
+https://cdn.example.com/sport/manifest.mpd
+
+https://cdn.example.com/bare.m3u8
+`
+
+func TestParseM3U(t *testing.T) {
+	channels, err := ParseM3U(strings.NewReader(testPlaylist))
+	if err != nil {
+		t.Fatalf("ParseM3U() error = %v", err)
+	}
+
+	if len(channels) != 3 {
+		t.Fatalf("len(channels) = %d, want 3", len(channels))
+	}
+
+	news := channels[0]
+	if news.ID != "news.uk" || news.Name != "News, Live" || news.Group != "News" {
+		t.Errorf("news = %+v", news)
+	}
+	if news.Logo != "https://logo.example.com/news.png" {
+		t.Errorf("news.Logo = %q", news.Logo)
+	}
+	if news.Headers["User-Agent"] != "Mozilla/5.0" || news.Headers["Referer"] != "https://example.com/" {
+		t.Errorf("news.Headers = %v", news.Headers)
+	}
+
+	sport := channels[1]
+	if sport.Name != "Sport 1" || sport.URL != "https://cdn.example.com/sport/manifest.mpd" {
+		t.Errorf("sport = %+v", sport)
+	}
+	if sport.ClearKey != "0123456789abcdef0123456789abcdef:fedcba9876543210fedcba9876543210" {
+		t.Errorf("sport.ClearKey = %q", sport.ClearKey)
+	}
+
+	bare := channels[2]
+	if bare.URL != "https://cdn.example.com/bare.m3u8" || bare.Name != bare.URL {
+		t.Errorf("bare = %+v", bare)
+	}
+}
+
+func TestParse_JSON(t *testing.T) {
+	data := []byte(`[{"name": "Channel 1", "url": "https://example.com/1.m3u8", "headers": {"Referer": "https://example.com/"}}]`)
+
+	channels, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(channels) != 1 || channels[0].Name != "Channel 1" || channels[0].Headers["Referer"] != "https://example.com/" {
+		t.Errorf("channels = %+v", channels)
+	}
+}
+
+func TestStore_Set(t *testing.T) {
+	store := NewStore("", nil, logging.New("error", false, nil))
+	store.Set([]types.Channel{
+		{ID: "ch", Name: "HD", URL: "https://example.com/hd.m3u8", Group: "B"},
+		{ID: "ch", Name: "SD", URL: "https://example.com/sd.m3u8", Group: "A"},
+		{Name: "No ID", URL: "https://example.com/other.m3u8", Group: "A"},
+		{Name: "No URL"},
+	})
+
+	list := store.List()
+	if len(list) != 3 {
+		t.Fatalf("len(List()) = %d, want 3", len(list))
+	}
+	if list[0].ID != "ch" || list[1].ID != "ch-2" {
+		t.Errorf("duplicate IDs not made unique: %q, %q", list[0].ID, list[1].ID)
+	}
+	if list[2].ID == "" {
+		t.Error("missing ID was not generated")
+	}
+
+	if ch, ok := store.Get("ch-2"); !ok || ch.Name != "SD" {
+		t.Errorf("Get(ch-2) = %+v, %v", ch, ok)
+	}
+
+	groups := store.Groups()
+	if strings.Join(groups, ",") != "A,B" {
+		t.Errorf("Groups() = %v, want [A B]", groups)
+	}
+}
+
+func TestStore_ImportData_Persists(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "channels_test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	filePath := filepath.Join(tempDir, "data", "channels.json")
+	log := logging.New("error", false, nil)
+
+	store := NewStore(filePath, nil, log)
+	n, err := store.ImportData([]byte(testPlaylist))
+	if err != nil {
+		t.Fatalf("ImportData() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("ImportData() = %d, want 3", n)
+	}
+
+	reloaded := NewStore(filePath, nil, log)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if reloaded.Count() != 3 {
+		t.Errorf("Count() after reload = %d, want 3", reloaded.Count())
+	}
+	if ch, ok := reloaded.Get("news.uk"); !ok || ch.Headers["Referer"] != "https://example.com/" {
+		t.Errorf("Get(news.uk) = %+v, %v", ch, ok)
+	}
+
+	if _, err := store.ImportData([]byte("#EXTM3U\n")); err == nil {
+		t.Error("ImportData() of empty playlist should fail")
+	}
+}
+
+func TestStore_AddUpdateDelete(t *testing.T) {
+	store := NewStore("", nil, logging.New("error", false, nil))
+	store.Set([]types.Channel{
+		{ID: "a", Name: "A", URL: "https://example.com/a.m3u8"},
+		{ID: "b", Name: "B", URL: "https://example.com/b.m3u8"},
+		{ID: "c", Name: "C", URL: "https://example.com/c.m3u8"},
+	})
+
+	added, err := store.Add(types.Channel{ID: "a", URL: "https://example.com/d.m3u8"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if added.ID != "a-2" || added.Name != added.URL {
+		t.Errorf("Add() = %+v, want unique ID and name defaulted to URL", added)
+	}
+	if _, err := store.Add(types.Channel{Name: "No URL"}); err == nil {
+		t.Error("Add() without URL should fail")
+	}
+
+	updated, err := store.Update("b", types.Channel{ID: "ignored", Name: "B2", URL: "https://example.com/b2.m3u8", Favorite: true})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if updated.ID != "b" {
+		t.Errorf("Update() ID = %q, want b", updated.ID)
+	}
+	if ch, _ := store.Get("b"); ch.Name != "B2" || !ch.Favorite {
+		t.Errorf("Get(b) after update = %+v", ch)
+	}
+	if _, err := store.Update("missing", types.Channel{URL: "https://example.com/x.m3u8"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update(missing) error = %v, want ErrNotFound", err)
+	}
+
+	if err := store.Delete("a"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := store.Delete("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete() error = %v, want ErrNotFound", err)
+	}
+	// Remaining channels must still be reachable by ID after the index shift
+	for _, id := range []string{"b", "c", "a-2"} {
+		if ch, ok := store.Get(id); !ok || ch.ID != id {
+			t.Errorf("Get(%s) = %+v, %v", id, ch, ok)
+		}
+	}
+}
+
+func TestStore_ImportData_KeepsFavorites(t *testing.T) {
+	store := NewStore("", nil, logging.New("error", false, nil))
+	if _, err := store.ImportData([]byte(testPlaylist)); err != nil {
+		t.Fatalf("ImportData() error = %v", err)
+	}
+
+	bare := store.List()[2]
+	bare.Favorite = true
+	if _, err := store.Update(bare.ID, bare); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if _, err := store.ImportData([]byte(testPlaylist)); err != nil {
+		t.Fatalf("ImportData() error = %v", err)
+	}
+	if ch, _ := store.Get(bare.ID); !ch.Favorite {
+		t.Errorf("favorite lost on re-import: %+v", ch)
+	}
+}
+
+func TestStore_Replace_FavoritesOfDuplicateIDs(t *testing.T) {
+	store := NewStore("", nil, logging.New("error", false, nil))
+	variants := func() []types.Channel {
+		return []types.Channel{
+			{ID: "sport", Name: "Sport HD", URL: "https://cdn.example.com/sport-hd.m3u8"},
+			{ID: "sport", Name: "Sport SD", URL: "https://cdn.example.com/sport-sd.m3u8"},
+		}
+	}
+	if _, err := store.Replace(variants()); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+
+	sd, _ := store.Get("sport-2")
+	sd.Favorite = true
+	if _, err := store.Update(sd.ID, sd); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if _, err := store.Replace(variants()); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	if ch, _ := store.Get("sport"); ch.Favorite {
+		t.Errorf("favorite moved to the first variant: %+v", ch)
+	}
+	if ch, _ := store.Get("sport-2"); !ch.Favorite {
+		t.Errorf("favorite lost on re-import: %+v", ch)
+	}
+}
@@ -0,0 +1,136 @@
+package channels
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"media-proxy-go/pkg/types"
+)
+
+// extinfAttrRegex matches key="value" attributes in #EXTINF lines.
+var extinfAttrRegex = regexp.MustCompile(`([a-zA-Z0-9_-]+)="([^"]*)"`)
+
+// ParseM3U parses an IPTV M3U/M3U8 playlist into channels.
+// It understands tvg-id/tvg-logo/group-title attributes, #EXTVLCOPT
+// http-user-agent/http-referrer/http-origin options and #KODIPROP ClearKey licenses.
+func ParseM3U(r io.Reader) ([]types.Channel, error) {
+	var channels []types.Channel
+	var current *types.Channel
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "#EXTINF:"):
+			current = parseExtinf(line)
+
+		case strings.HasPrefix(line, "#EXTVLCOPT:"):
+			if current == nil {
+				continue
+			}
+			opt := strings.TrimPrefix(line, "#EXTVLCOPT:")
+			key, value, ok := strings.Cut(opt, "=")
+			if !ok {
+				continue
+			}
+			switch strings.ToLower(key) {
+			case "http-user-agent":
+				setHeader(current, "User-Agent", value)
+			case "http-referrer", "http-referer":
+				setHeader(current, "Referer", value)
+			case "http-origin":
+				setHeader(current, "Origin", value)
+			}
+
+		case strings.HasPrefix(line, "#KODIPROP:"):
+			if current == nil {
+				continue
+			}
+			prop := strings.TrimPrefix(line, "#KODIPROP:")
+			key, value, ok := strings.Cut(prop, "=")
+			if ok && strings.HasSuffix(strings.ToLower(key), "license_key") && isClearKey(value) {
+				current.ClearKey = value
+			}
+
+		case strings.HasPrefix(line, "#"):
+			// Other directives (#EXTM3U, #EXTGRP...) are ignored
+
+		default:
+			if current == nil {
+				current = &types.Channel{}
+			}
+			current.URL = line
+			if current.Name == "" {
+				current.Name = line
+			}
+			channels = append(channels, *current)
+			current = nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return channels, nil
+}
+
+// parseExtinf parses an #EXTINF line: #EXTINF:-1 tvg-id="x" tvg-logo="y" group-title="z",Name
+func parseExtinf(line string) *types.Channel {
+	ch := &types.Channel{}
+
+	info := strings.TrimPrefix(line, "#EXTINF:")
+	attrs := info
+	// The display name follows the first comma outside of quoted attributes
+	inQuotes := false
+scan:
+	for i := 0; i < len(info); i++ {
+		switch info[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case ',':
+			if !inQuotes {
+				attrs = info[:i]
+				ch.Name = strings.TrimSpace(info[i+1:])
+				break scan
+			}
+		}
+	}
+
+	for _, m := range extinfAttrRegex.FindAllStringSubmatch(attrs, -1) {
+		switch strings.ToLower(m[1]) {
+		case "tvg-id":
+			ch.ID = m[2]
+		case "tvg-logo":
+			ch.Logo = m[2]
+		case "group-title":
+			ch.Group = m[2]
+		case "tvg-name":
+			if ch.Name == "" {
+				ch.Name = m[2]
+			}
+		}
+	}
+
+	return ch
+}
+
+func setHeader(ch *types.Channel, key, value string) {
+	if ch.Headers == nil {
+		ch.Headers = make(map[string]string)
+	}
+	ch.Headers[key] = value
+}
+
+// isClearKey reports whether a license key value is in KID:KEY form rather than a license URL.
+func isClearKey(value string) bool {
+	return !strings.Contains(value, "://") && strings.Contains(value, ":")
+}
@@ -0,0 +1,63 @@
+package channels
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"media-proxy-go/pkg/types"
+)
+
+// ProxyURL builds a proxy URL for a channel on path (e.g. /proxy/manifest.m3u8),
+// carrying its headers as h_ params and its ClearKey. IPTV players and Stremio
+// cannot send custom headers, so a non-empty apiPassword is passed as a query parameter.
+func ProxyURL(baseURL, path string, ch types.Channel, apiPassword string, extra url.Values) string {
+	q := ProxyQuery(ch, extra)
+	if apiPassword != "" {
+		q.Set("api_password", apiPassword)
+	}
+	return baseURL + path + "?" + q.Encode()
+}
+
+// ProxyQuery returns the query of a channel's proxy URL without credentials:
+// its URL, headers as h_ params, ClearKey and the extra params.
+func ProxyQuery(ch types.Channel, extra url.Values) url.Values {
+	q := url.Values{}
+	q.Set("url", ch.URL)
+	for key, value := range ch.Headers {
+		q.Set("h_"+key, value)
+	}
+	if ch.ClearKey != "" {
+		q.Set("clearkey", ch.ClearKey)
+	}
+	for key, values := range extra {
+		q[key] = values
+	}
+	return q
+}
+
+// WriteM3U writes channels as an M3U playlist, using urlFor to build each channel's URL.
+func WriteM3U(w io.Writer, channels []types.Channel, urlFor func(types.Channel) string) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("#EXTM3U\n")
+
+	for _, ch := range channels {
+		fmt.Fprintf(bw, "#EXTINF:-1 tvg-id=\"%s\" tvg-name=\"%s\"", attr(ch.ID), attr(ch.Name))
+		if ch.Logo != "" {
+			fmt.Fprintf(bw, " tvg-logo=\"%s\"", attr(ch.Logo))
+		}
+		if ch.Group != "" {
+			fmt.Fprintf(bw, " group-title=\"%s\"", attr(ch.Group))
+		}
+		fmt.Fprintf(bw, ",%s\n%s\n", strings.ReplaceAll(ch.Name, "\n", " "), urlFor(ch))
+	}
+
+	return bw.Flush()
+}
+
+// attr makes a value safe for a quoted #EXTINF attribute.
+func attr(s string) string {
+	return strings.NewReplacer(`"`, "'", "\n", " ", "\r", "").Replace(s)
+}
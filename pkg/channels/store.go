@@ -0,0 +1,341 @@
+// Package channels manages the user's live channel list, imported from
+// an M3U playlist or a JSON file (local path, URL or upload) and persisted to disk.
+package channels
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+// ErrNotFound is returned when a channel ID does not exist.
+var ErrNotFound = errors.New("channel not found")
+
+// Store holds the channel list in memory and persists it to a JSON file.
+type Store struct {
+	filePath string // Empty disables persistence
+	client   interfaces.HTTPClient
+	log      *logging.Logger
+
+	saveMu   sync.Mutex // Serializes writes to filePath
+	mu       sync.RWMutex
+	channels []types.Channel
+	byID     map[string]int
+}
+
+// NewStore creates a channel store persisted at filePath.
+// client is used to fetch playlists imported from a URL.
+func NewStore(filePath string, client interfaces.HTTPClient, log *logging.Logger) *Store {
+	return &Store{
+		filePath: filePath,
+		client:   client,
+		log:      log.WithComponent("channels"),
+		byID:     make(map[string]int),
+	}
+}
+
+// Load loads the persisted channel list. A missing file is not an error.
+func (s *Store) Load() error {
+	if s.filePath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read channel list: %w", err)
+	}
+
+	var channels []types.Channel
+	if err := json.Unmarshal(data, &channels); err != nil {
+		return fmt.Errorf("failed to parse channel list: %w", err)
+	}
+
+	s.Set(channels)
+	s.log.Info("loaded channels", "path", s.filePath, "count", len(channels))
+	return nil
+}
+
+// Import replaces the channel list with the playlist at source (file path or http(s) URL).
+func (s *Store) Import(ctx context.Context, source string) (int, error) {
+	data, err := s.read(ctx, source)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := s.ImportData(data)
+	if err != nil {
+		return 0, err
+	}
+
+	s.log.Info("imported channels", "source", source, "count", n)
+	return n, nil
+}
+
+// ImportData replaces the channel list with a raw M3U or JSON playlist and persists it.
+func (s *Store) ImportData(data []byte) (int, error) {
+	channels, err := Parse(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse channel list: %w", err)
+	}
+	if len(channels) == 0 {
+		return 0, fmt.Errorf("playlist contains no channels")
+	}
+
+	return s.Replace(channels)
+}
+
+// Replace replaces the channel list and persists it, keeping favorites of
+// channels that were already in the list.
+func (s *Store) Replace(channels []types.Channel) (int, error) {
+	// Keep favorites across re-imports of the same playlist. IDs are assigned
+	// as Set does, so channels sharing a tvg-id match their own entry.
+	byID := make(map[string]int, len(channels))
+	for i, ch := range channels {
+		if ch.URL == "" {
+			continue
+		}
+		ch = prepare(ch, byID)
+		byID[ch.ID] = i
+		if existing, ok := s.Get(ch.ID); ok && existing.URL == ch.URL {
+			channels[i].Favorite = existing.Favorite
+		}
+	}
+
+	s.Set(channels)
+	if err := s.save(); err != nil {
+		return 0, err
+	}
+
+	return s.Count(), nil
+}
+
+// save writes the channel list to disk.
+func (s *Store) save() error {
+	if s.filePath == "" {
+		return nil
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	data, err := json.MarshalIndent(s.List(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal channels: %w", err)
+	}
+
+	if dir := filepath.Dir(s.filePath); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create channels directory: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(s.filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to save channels: %w", err)
+	}
+	return nil
+}
+
+// Set replaces the channel list, assigning IDs to channels that have none.
+func (s *Store) Set(channels []types.Channel) {
+	byID := make(map[string]int, len(channels))
+	result := make([]types.Channel, 0, len(channels))
+	for _, ch := range channels {
+		if ch.URL == "" {
+			continue
+		}
+		ch = prepare(ch, byID)
+		byID[ch.ID] = len(result)
+		result = append(result, ch)
+	}
+
+	s.mu.Lock()
+	s.channels = result
+	s.byID = byID
+	s.mu.Unlock()
+}
+
+// Add appends a channel and persists the list. The stored channel is returned
+// with its assigned ID.
+func (s *Store) Add(ch types.Channel) (types.Channel, error) {
+	if ch.URL == "" {
+		return types.Channel{}, fmt.Errorf("channel url is required")
+	}
+
+	s.mu.Lock()
+	ch = prepare(ch, s.byID)
+	s.byID[ch.ID] = len(s.channels)
+	s.channels = append(s.channels, ch)
+	s.mu.Unlock()
+
+	return ch, s.save()
+}
+
+// Update replaces the channel with the given ID, keeping its ID and position.
+func (s *Store) Update(id string, ch types.Channel) (types.Channel, error) {
+	if ch.URL == "" {
+		return types.Channel{}, fmt.Errorf("channel url is required")
+	}
+
+	s.mu.Lock()
+	i, ok := s.byID[id]
+	if !ok {
+		s.mu.Unlock()
+		return types.Channel{}, ErrNotFound
+	}
+	ch.ID = id
+	if ch.Name == "" {
+		ch.Name = ch.URL
+	}
+	s.channels[i] = ch
+	s.mu.Unlock()
+
+	return ch, s.save()
+}
+
+// Delete removes the channel with the given ID.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	i, ok := s.byID[id]
+	if !ok {
+		s.mu.Unlock()
+		return ErrNotFound
+	}
+	s.channels = append(s.channels[:i], s.channels[i+1:]...)
+	delete(s.byID, id)
+	for j := i; j < len(s.channels); j++ {
+		s.byID[s.channels[j].ID] = j
+	}
+	s.mu.Unlock()
+
+	return s.save()
+}
+
+// List returns all channels.
+func (s *Store) List() []types.Channel {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]types.Channel, len(s.channels))
+	copy(result, s.channels)
+	return result
+}
+
+// Count returns the number of channels.
+func (s *Store) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.channels)
+}
+
+// Get returns a channel by ID.
+func (s *Store) Get(id string) (types.Channel, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.byID[id]
+	if !ok {
+		return types.Channel{}, false
+	}
+	return s.channels[i], true
+}
+
+// Groups returns the sorted list of distinct channel groups.
+func (s *Store) Groups() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var groups []string
+	for _, ch := range s.channels {
+		if ch.Group != "" && !seen[ch.Group] {
+			seen[ch.Group] = true
+			groups = append(groups, ch.Group)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// read fetches a raw playlist from a URL or local file.
+func (s *Store) read(ctx context.Context, source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read channel list: %w", err)
+		}
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch channel list: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch channel list: status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// Parse parses a channel list, detecting JSON (array of channels) or M3U.
+func Parse(data []byte) ([]types.Channel, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var channels []types.Channel
+		if err := json.Unmarshal(trimmed, &channels); err != nil {
+			return nil, err
+		}
+		return channels, nil
+	}
+	return ParseM3U(bytes.NewReader(trimmed))
+}
+
+// prepare fills in a channel's name and ID, keeping the ID unique among byID.
+func prepare(ch types.Channel, byID map[string]int) types.Channel {
+	if ch.Name == "" {
+		ch.Name = ch.URL
+	}
+	if ch.ID == "" {
+		ch.ID = channelID(ch.Name, ch.URL)
+	}
+	// Keep IDs unique when playlists reuse tvg-id for several variants
+	if _, exists := byID[ch.ID]; exists {
+		base := ch.ID
+		for n := 2; exists; n++ {
+			ch.ID = fmt.Sprintf("%s-%d", base, n)
+			_, exists = byID[ch.ID]
+		}
+	}
+	return ch
+}
+
+// channelID derives a stable ID from a channel's name and URL.
+func channelID(name, url string) string {
+	sum := sha1.Sum([]byte(name + "\n" + url))
+	return hex.EncodeToString(sum[:6])
+}
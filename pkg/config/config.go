@@ -3,81 +3,423 @@ package config
 
 import (
 	"fmt"
+	"net/http"
+	"net/netip"
 	"os"
+	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"media-proxy-go/pkg/throttle"
+	"media-proxy-go/pkg/types"
 )
 
 // Config holds all application configuration.
 type Config struct {
 	// Server settings
 	Port         int
+	Listeners    []Listener // LISTEN entries; empty listens on Port for all traffic
 	BaseURL      string
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+	ManifestGzip bool // Gzip manifest responses for clients that accept it
+
+	// HTTPS: a certificate/key pair, or certificates from an ACME CA
+	// (Let's Encrypt) for ACMEDomains
+	TLSCertFile      string
+	TLSKeyFile       string
+	ACMEDomains      []string
+	ACMEEmail        string
+	ACMECacheDir     string // Issued certificates and the account key
+	ACMEDirectoryURL string // Empty for Let's Encrypt production
+	HTTPPort         int    // Plain HTTP listener redirecting to HTTPS and answering ACME challenges (0 disables)
+
+	// HTTP/2: negotiated over TLS, and optionally h2c (prior knowledge) on
+	// plaintext listeners, e.g. behind a reverse proxy speaking h2c
+	HTTP2 bool
+	H2C   bool
+
+	// Reverse proxies whose X-Forwarded-Host/Proto (or Forwarded) headers set
+	// the public URL of a request; empty ignores those headers
+	TrustedProxies []netip.Prefix
+
+	// Upstream response limits by class (0 disables a limit)
+	ManifestMaxSize int64 // Bytes buffered for an HLS playlist or MPD
+	ManifestTimeout time.Duration
+	SegmentMaxSize  int64 // Bytes buffered for a segment being decrypted or remuxed
+	SegmentTimeout  time.Duration
+	InitCacheSize   int64 // Bytes of fMP4 init segments cached for the decrypt path (0 = disabled)
+	PageMaxSize     int64 // Bytes buffered for an extractor page
+	PageTimeout     time.Duration
+
+	// Outbound budget of page and API requests (not manifests or segments),
+	// so bulk refreshes don't trip upstream anti-bot thresholds (0 disables)
+	OutboundMaxInFlight     int           // Across all hosts
+	OutboundHostMaxInFlight int           // Per host
+	OutboundHostInterval    time.Duration // Minimum time between requests to a host
+
+	// UAProfile is the preset User-Agent profile of upstream requests:
+	// desktop, mobile or smarttv. UARotate gives each host its own
+	// User-Agent of the profile.
+	UAProfile string
+	UARotate  bool
 
-	// Authentication
-	APIPassword string
+	// Authentication by route class (see middleware.RouteClass)
+	APIPassword     string
+	AdminPassword   string // Required on admin routes instead of APIPassword when set
+	StreamingPublic bool   // Serve streaming routes without a password
+
+	// Signed playback tokens: URLs in proxied manifests carry a token bound to
+	// the client the manifest was served to instead of the password
+	PlaybackTokens      bool
+	PlaybackTokenBind   []string // Client attributes tokens are bound to: "ip", "ua"
+	PlaybackTokenTTL    time.Duration
+	PlaybackTokenSecret string // Empty uses a random key per start
+
+	// Short URLs: proxied playlists link /proxy/s/{id}/... with the upstream
+	// URL and headers kept server-side
+	ShortURLs   bool
+	ShortURLTTL time.Duration // Unused IDs expire after this
+
+	// Proxy URLs in manifests carry their query string AES-GCM encrypted
+	// with this secret (empty = disabled)
+	URLEncryptionKey string
 
 	// Proxy settings
 	GlobalProxies   []string
 	TransportRoutes []TransportRoute
+	GeoIPURL        string   // IP lookup service queried through each egress by /proxy/ip
+	HeaderAllowlist []string // Extra h_ param headers clients may send, "X-Foo-*" allows a prefix
+
+	ForwardResponseHeaders []string // Upstream response headers passed on to clients, "X-Cache-*" allows a prefix
 
 	// DVR settings
-	RecordingsDir          string
-	MaxRecordingDuration   time.Duration
+	RecordingsDir           string
+	MaxRecordingDuration    time.Duration
 	RecordingsRetentionDays int
+	RecordingStallTimeout   time.Duration // Restart FFmpeg if the output file stops growing for this long (0 disables)
+	RecordingMaxRestarts    int
+	RecordingPassthrough    bool              // Copy raw TS sources directly instead of running FFmpeg
+	RecordingsVolumes       []RecordingVolume // Directories recordings are spread across; empty uses RecordingsDir
+	RecordingsPlacement     string            // "most-free" or "round-robin"
+	RecordingsWatchDir      string            // Media files dropped here are imported into the library
+	RecordingsImportScan    time.Duration     // Interval of the import scan when RecordingsWatchDir is set
+	RecordingAnalyze        bool              // Analyze finished recordings with ffprobe into a health report
+	RecordingChapters       bool              // Detect black/silent filler in finished recordings as chapter markers
+	RecordingChaptersMin    time.Duration     // Shortest black or silent stretch that makes a chapter
 
 	// FFmpeg settings
 	FFmpegPath      string
 	FFmpegOutputDir string
+	FFprobePath     string
+
+	// Transcoding profiles (built-ins plus TRANSCODE_PROFILES)
+	TranscodeProfiles       []types.TranscodeProfile
+	TranscodeDefaultProfile string
+	TranscodeMaxSessions    int           // Concurrent FFmpeg transcodes (0 = unlimited)
+	FFmpegHWAccel           string        // "auto" or preferred hwaccels, e.g. "qsv,nvenc"; empty encodes in software
+	DecryptProfile          string        // Re-encode decrypted segments with this profile instead of stream copying
+	DecryptWorkers          int           // Concurrent /decrypt segment jobs: fetch, decrypt, remux (0 = unlimited)
+	DecryptQueue            int           // Jobs waiting for a worker beyond which requests get 503
+	DecryptQueueTimeout     time.Duration // Longest wait for a worker before a 503
+	RemuxPoolSize           int           // FFmpeg processes kept started for remuxing decrypted segments (0 = disabled)
 
 	// Logging
 	LogLevel string
 	LogJSON  bool
 
 	// Stremio addon
-	StremioEnabled bool
+	StremioEnabled         bool
+	StremioRequirePassword bool // Only serve addon installs configured with API_PASSWORD
+
+	// HDHomeRun tuner emulation (Plex/Jellyfin/Emby Live TV)
+	HDHomeRunEnabled      bool
+	HDHomeRunDeviceID     string
+	HDHomeRunFriendlyName string
+	HDHomeRunTunerCount   int
+
+	// Live channel list
+	ChannelsFile   string // Where imported channels are persisted
+	ChannelsSource string // M3U playlist or JSON (path or URL) imported at startup
 
-	// FlareSolverr settings (for Cloudflare bypass)
-	FlareSolverrURL     string
+	// Extractor plugins (*.json definitions loaded at startup)
+	ExtractorsDir string
+
+	// Extractions run in parallel by POST /api/extract/batch
+	ExtractBatchWorkers int
+
+	// How the extractions of each extractor are paced, by name or "*"
+	ExtractorLimits map[string]throttle.Limit
+
+	// DLHD stream URL templates ({server}, {channel}); empty keeps the built-in ones
+	DLHDServerURLTemplate  string
+	DLHDDefaultURLTemplate string
+
+	// Headless browser extraction (Chrome/Chromium), for pages computing
+	// their stream URLs in JavaScript; off when both are empty
+	BrowserPath     string // Executable, launched on first use
+	BrowserURL      string // DevTools endpoint of a running browser, instead of launching one
+	BrowserTimeout  time.Duration
+	BrowserMaxPages int  // Pages loaded at once, others wait
+	BrowserFallback bool // Also try the browser on pages the generic extractor finds no stream in
+
+	// Signed extraction rules bundle, fetched at startup and on POST /api/rules/reload
+	RulesURL       string
+	RulesPublicKey string // Base64 Ed25519 public key the bundle is signed with
+
+	// Extractor cookie jar file (empty keeps cookies in memory only)
+	CookiesFile string
+
+	// ClearKey key store file (KID -> KEY, managed via /api/keys)
+	KeysFile string
+
+	// Cloudflare challenge solver settings (FlareSolverr, Byparr or cf-clearance-scraper)
+	CFSolver            string // Solver API type
+	FlareSolverrURL     string // Solver endpoint (CF_SOLVER_URL or FLARESOLVERR_URL)
 	FlareSolverrTimeout time.Duration
+
+	// Webhook notifications for recording events
+	WebhookURLs    []string
+	WebhookTimeout time.Duration
+
+	// Live dashboard events (/api/events)
+	EventsStatsInterval time.Duration
+
+	// Language of the dashboard and error messages (en, it, de, es); empty
+	// follows each client's Accept-Language
+	UILanguage string
+
+	// Playback sessions (/api/sessions)
+	SessionIdleTimeout time.Duration // A session ends after this long without requests
+
+	// Per-stream statistics (/api/stats/streams)
+	StreamStatsRetention time.Duration // A stream's counters are dropped after this long without requests
+
+	// VOD segment disk cache
+	SegmentCacheDir      string // Empty disables the cache
+	SegmentCacheMaxSize  int64  // Bytes
+	SegmentCacheMaxEntry int64  // Larger responses are not cached
+
+	// Channel health monitor (/api/health/channels)
+	HealthCheckInterval    time.Duration // Time between checks of all channels (0 = disabled)
+	HealthCheckTimeout     time.Duration // Per-channel check timeout
+	HealthCheckConcurrency int           // Channels checked in parallel
+	HealthCheckFavorites   bool          // Check only favorite channels
+	HealthCheckHistory     int           // Check results kept per channel
+
+	// Diagnostics
+	DebugEndpoints bool // Serve /debug/pprof and /api/debug/runtime
+
+	// OpenTelemetry tracing, exported over OTLP/HTTP
+	OTLPEndpoint       string   // Collector URL, e.g. http://otel-collector:4318 (empty = disabled)
+	OTLPHeaders        []string // "name=value" headers sent with every export, e.g. an API key
+	TraceServiceName   string
+	TraceSamplePercent int // Percentage of requests traced
+
+	// Recording export to external storage
+	ExportType         string // "s3", "webdav" or empty to disable
+	ExportPathTemplate string // Placeholders: {id}, {name}, {filename}, {date}, {time}
+	ExportDeleteLocal  bool   // Delete the local file after a successful upload
+	S3Endpoint         string
+	S3Region           string
+	S3Bucket           string
+	S3AccessKey        string
+	S3SecretKey        string
+	S3PathStyle        bool
+	WebDAVURL          string
+	WebDAVUsername     string
+	WebDAVPassword     string
 }
 
 // TransportRoute defines URL-specific proxy routing.
 type TransportRoute struct {
 	URLPattern string
+	Match      string         // How URLPattern is matched, one of the RouteMatch constants
+	Regexp     *regexp.Regexp // Compiled URLPattern of RouteMatchRegex routes
+	Priority   int            // Routes are evaluated by descending priority, then in order
 	Proxy      string
 	DisableSSL bool
 	Direct     bool // If true, bypass global proxy and connect directly
+
+	// Headers sent to matching upstreams unless the request sets them
+	// (User-Agent also replaces the built-in default)
+	Headers map[string]string
+
+	// Redirect policy
+	RedirectHeaders []string // Headers re-sent when a redirect changes host (nil keeps all)
+	RedirectStream  bool     // Hand segment/stream redirects to the client instead of following them
+
+	// How requests to matching upstreams are paced
+	Limit throttle.Limit
+}
+
+// Transport route match types.
+const (
+	RouteMatchContains = "contains" // Substring of the whole URL
+	RouteMatchHost     = "host"     // Glob on the hostname, e.g. *.example.com
+	RouteMatchPath     = "path"     // Prefix of the path (/live/) or of host and path (cdn.example.com/live/)
+	RouteMatchRegex    = "regex"    // Regular expression on the whole URL
+)
+
+// Listener roles: which endpoints a listener serves.
+const (
+	ListenerRoleAll       = "all"       // Every endpoint, including the API and dashboard
+	ListenerRoleStreaming = "streaming" // Proxy, playback and addon endpoints only
+)
+
+// Listener is an address the server listens on.
+type Listener struct {
+	Network string // "tcp" or "unix"
+	Address string // host:port, or a socket path
+	Role    string
+}
+
+// RecordingVolume is a directory recordings are placed in.
+type RecordingVolume struct {
+	Path  string
+	Quota int64 // Bytes of recordings the volume may hold, 0 for no quota
 }
 
 // Load reads configuration from environment variables with sensible defaults.
 func Load() *Config {
 	port := getEnvInt("PORT", 7860)
+	acmeDomains := getEnvStringSlice("ACME_DOMAINS", nil)
 	cfg := &Config{
 		Port:                    port,
-		BaseURL:                 getEnvString("BASE_URL", fmt.Sprintf("http://localhost:%d", port)),
+		BaseURL:                 getEnvString("BASE_URL", defaultBaseURL(port, acmeDomains)),
+		TLSCertFile:             getEnvString("TLS_CERT_FILE", ""),
+		TLSKeyFile:              getEnvString("TLS_KEY_FILE", ""),
+		ACMEDomains:             acmeDomains,
+		ACMEEmail:               getEnvString("ACME_EMAIL", ""),
+		ACMECacheDir:            getEnvString("ACME_CACHE_DIR", "acme"),
+		ACMEDirectoryURL:        getEnvString("ACME_DIRECTORY_URL", ""),
+		HTTPPort:                getEnvInt("HTTP_PORT", 0),
+		HTTP2:                   getEnvBool("HTTP2_ENABLED", true),
+		H2C:                     getEnvBool("H2C_ENABLED", false),
 		ReadTimeout:             getEnvDuration("READ_TIMEOUT", 30*time.Second),
 		WriteTimeout:            getEnvDuration("WRITE_TIMEOUT", 120*time.Second),
 		IdleTimeout:             getEnvDuration("IDLE_TIMEOUT", 60*time.Second),
+		ManifestGzip:            getEnvBool("MANIFEST_GZIP", true),
+		ManifestMaxSize:         int64(getEnvInt("MANIFEST_MAX_MB", 16)) << 20,
+		ManifestTimeout:         getEnvDuration("MANIFEST_TIMEOUT", 15*time.Second),
+		SegmentMaxSize:          int64(getEnvInt("SEGMENT_MAX_MB", 256)) << 20,
+		SegmentTimeout:          getEnvDuration("SEGMENT_TIMEOUT", 30*time.Second),
+		InitCacheSize:           int64(getEnvInt("INIT_CACHE_MB", 32)) << 20,
+		PageMaxSize:             int64(getEnvInt("PAGE_MAX_MB", 10)) << 20,
+		PageTimeout:             getEnvDuration("PAGE_TIMEOUT", 30*time.Second),
+		OutboundMaxInFlight:     getEnvInt("OUTBOUND_MAX_IN_FLIGHT", 0),
+		OutboundHostMaxInFlight: getEnvInt("OUTBOUND_HOST_MAX_IN_FLIGHT", 0),
+		OutboundHostInterval:    getEnvDuration("OUTBOUND_HOST_INTERVAL", 0),
+		UAProfile:               getEnvString("UA_PROFILE", "desktop"),
+		UARotate:                getEnvBool("UA_ROTATE", false),
 		APIPassword:             os.Getenv("API_PASSWORD"),
+		AdminPassword:           os.Getenv("ADMIN_PASSWORD"),
+		StreamingPublic:         getEnvBool("STREAMING_PUBLIC", false),
+		PlaybackTokens:          getEnvBool("PLAYBACK_TOKENS", false),
+		PlaybackTokenBind:       getEnvStringSlice("PLAYBACK_TOKEN_BIND", []string{"ip", "ua"}),
+		PlaybackTokenTTL:        getEnvDuration("PLAYBACK_TOKEN_TTL", 2*time.Hour),
+		PlaybackTokenSecret:     os.Getenv("PLAYBACK_TOKEN_SECRET"),
+		ShortURLs:               getEnvBool("SHORT_URLS", false),
+		ShortURLTTL:             getEnvDuration("SHORT_URL_TTL", 6*time.Hour),
+		URLEncryptionKey:        os.Getenv("URL_ENCRYPTION_KEY"),
 		GlobalProxies:           getEnvStringSlice("GLOBAL_PROXIES", nil),
+		HeaderAllowlist:         getEnvStringSlice("HEADER_ALLOWLIST", nil),
+		ForwardResponseHeaders:  getEnvStringSlice("FORWARD_RESPONSE_HEADERS", []string{"Age", "X-Cache", "X-Cache-Hits"}),
+		GeoIPURL:                getEnvString("GEOIP_URL", "https://ipinfo.io/json"),
 		RecordingsDir:           getEnvString("RECORDINGS_DIR", "recordings"),
 		MaxRecordingDuration:    getEnvDuration("MAX_RECORDING_DURATION", 8*time.Hour),
 		RecordingsRetentionDays: getEnvInt("RECORDINGS_RETENTION_DAYS", 7),
+		RecordingStallTimeout:   getEnvDuration("RECORDING_STALL_TIMEOUT", 60*time.Second),
+		RecordingMaxRestarts:    getEnvInt("RECORDING_MAX_RESTARTS", 3),
+		RecordingPassthrough:    getEnvBool("RECORDING_PASSTHROUGH", true),
+		RecordingsPlacement:     strings.ToLower(getEnvString("RECORDINGS_PLACEMENT", "most-free")),
+		RecordingsWatchDir:      getEnvString("RECORDINGS_WATCH_DIR", ""),
+		RecordingsImportScan:    getEnvDuration("RECORDINGS_IMPORT_INTERVAL", 5*time.Minute),
+		RecordingAnalyze:        getEnvBool("RECORDING_ANALYZE", true),
+		RecordingChapters:       getEnvBool("RECORDING_CHAPTERS", false),
+		RecordingChaptersMin:    getEnvDuration("RECORDING_CHAPTERS_MIN", 5*time.Second),
 		FFmpegPath:              getEnvString("FFMPEG_PATH", "ffmpeg"),
 		FFmpegOutputDir:         getEnvString("FFMPEG_OUTPUT_DIR", "/tmp/mediaproxy-streams"),
+		FFprobePath:             getEnvString("FFPROBE_PATH", "ffprobe"),
+		TranscodeDefaultProfile: getEnvString("TRANSCODE_DEFAULT_PROFILE", "720p"),
+		TranscodeMaxSessions:    getEnvInt("TRANSCODE_MAX_SESSIONS", 4),
+		FFmpegHWAccel:           strings.ToLower(getEnvString("FFMPEG_HWACCEL", "")),
+		DecryptProfile:          getEnvString("DECRYPT_TRANSCODE_PROFILE", ""),
+		DecryptWorkers:          getEnvInt("DECRYPT_WORKERS", 2*runtime.NumCPU()),
+		DecryptQueue:            getEnvInt("DECRYPT_QUEUE", 64),
+		DecryptQueueTimeout:     getEnvDuration("DECRYPT_QUEUE_TIMEOUT", 10*time.Second),
+		RemuxPoolSize:           getEnvInt("REMUX_POOL_SIZE", 2),
 		LogLevel:                getEnvString("LOG_LEVEL", "info"),
 		LogJSON:                 getEnvBool("LOG_JSON", false),
 		StremioEnabled:          getEnvBool("STREMIO_ENABLED", true),
-		FlareSolverrURL:         getEnvString("FLARESOLVERR_URL", ""),
+		StremioRequirePassword:  getEnvBool("STREMIO_REQUIRE_PASSWORD", true),
+		HDHomeRunEnabled:        getEnvBool("HDHR_ENABLED", false),
+		HDHomeRunDeviceID:       getEnvString("HDHR_DEVICE_ID", ""),
+		HDHomeRunFriendlyName:   getEnvString("HDHR_FRIENDLY_NAME", "MediaProxy"),
+		HDHomeRunTunerCount:     getEnvInt("HDHR_TUNER_COUNT", 4),
+		ChannelsFile:            getEnvString("CHANNELS_FILE", "channels.json"),
+		ChannelsSource:          getEnvString("CHANNELS_SOURCE", ""),
+		ExtractorsDir:           getEnvString("EXTRACTORS_DIR", "extractors.d"),
+		ExtractBatchWorkers:     getEnvInt("EXTRACT_BATCH_WORKERS", 8),
+		DLHDServerURLTemplate:   getEnvString("DLHD_SERVER_URL_TEMPLATE", ""),
+		DLHDDefaultURLTemplate:  getEnvString("DLHD_DEFAULT_URL_TEMPLATE", ""),
+		BrowserPath:             getEnvString("BROWSER_PATH", ""),
+		BrowserURL:              getEnvString("BROWSER_URL", ""),
+		BrowserTimeout:          getEnvDuration("BROWSER_TIMEOUT", 30*time.Second),
+		BrowserMaxPages:         getEnvInt("BROWSER_MAX_PAGES", 2),
+		BrowserFallback:         getEnvBool("BROWSER_FALLBACK", false),
+		RulesURL:                getEnvString("RULES_URL", ""),
+		RulesPublicKey:          getEnvString("RULES_PUBLIC_KEY", ""),
+		CookiesFile:             getEnvString("COOKIES_FILE", ""),
+		KeysFile:                getEnvString("KEYS_FILE", "keys.json"),
+		CFSolver:                strings.ToLower(getEnvString("CF_SOLVER", "flaresolverr")),
+		FlareSolverrURL:         getEnvString("CF_SOLVER_URL", getEnvString("FLARESOLVERR_URL", "")),
 		FlareSolverrTimeout:     getEnvDuration("FLARESOLVERR_TIMEOUT", 60*time.Second),
+		WebhookURLs:             getEnvStringSlice("WEBHOOK_URLS", nil),
+		WebhookTimeout:          getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		EventsStatsInterval:     getEnvDuration("EVENTS_STATS_INTERVAL", 2*time.Second),
+		UILanguage:              getEnvString("UI_LANGUAGE", ""),
+		SessionIdleTimeout:      getEnvDuration("SESSION_IDLE_TIMEOUT", 30*time.Second),
+		StreamStatsRetention:    getEnvDuration("STREAM_STATS_RETENTION", time.Hour),
+		SegmentCacheDir:         getEnvString("SEGMENT_CACHE_DIR", ""),
+		SegmentCacheMaxSize:     int64(getEnvInt("SEGMENT_CACHE_MAX_MB", 2048)) << 20,
+		SegmentCacheMaxEntry:    int64(getEnvInt("SEGMENT_CACHE_MAX_ENTRY_MB", 64)) << 20,
+		HealthCheckInterval:     getEnvDuration("HEALTH_CHECK_INTERVAL", 0),
+		HealthCheckTimeout:      getEnvDuration("HEALTH_CHECK_TIMEOUT", 15*time.Second),
+		HealthCheckConcurrency:  getEnvInt("HEALTH_CHECK_CONCURRENCY", 4),
+		HealthCheckFavorites:    getEnvBool("HEALTH_CHECK_FAVORITES_ONLY", false),
+		HealthCheckHistory:      getEnvInt("HEALTH_CHECK_HISTORY", 20),
+		DebugEndpoints:          getEnvBool("DEBUG_ENDPOINTS", false),
+		OTLPEndpoint:            getEnvString("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		OTLPHeaders:             getEnvStringSlice("OTEL_EXPORTER_OTLP_HEADERS", nil),
+		TraceServiceName:        getEnvString("OTEL_SERVICE_NAME", "media-proxy-go"),
+		TraceSamplePercent:      getEnvInt("TRACE_SAMPLE_PERCENT", 100),
+		ExportType:              strings.ToLower(getEnvString("EXPORT_TYPE", "")),
+		ExportPathTemplate:      getEnvString("EXPORT_PATH_TEMPLATE", "recordings/{date}/{filename}"),
+		ExportDeleteLocal:       getEnvBool("EXPORT_DELETE_LOCAL", false),
+		S3Endpoint:              getEnvString("S3_ENDPOINT", "https://s3.amazonaws.com"),
+		S3Region:                getEnvString("S3_REGION", "us-east-1"),
+		S3Bucket:                getEnvString("S3_BUCKET", ""),
+		S3AccessKey:             os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey:             os.Getenv("S3_SECRET_KEY"),
+		S3PathStyle:             getEnvBool("S3_PATH_STYLE", true),
+		WebDAVURL:               getEnvString("WEBDAV_URL", ""),
+		WebDAVUsername:          os.Getenv("WEBDAV_USERNAME"),
+		WebDAVPassword:          os.Getenv("WEBDAV_PASSWORD"),
 	}
 
 	cfg.TransportRoutes = parseTransportRoutes(os.Getenv("TRANSPORT_ROUTES"))
+	cfg.ExtractorLimits = parseExtractorLimits(os.Getenv("EXTRACTOR_CONCURRENCY"), os.Getenv("EXTRACTOR_RATE"),
+		getEnvDuration("EXTRACTOR_JITTER", 500*time.Millisecond))
+	cfg.RecordingsVolumes = parseRecordingVolumes(os.Getenv("RECORDINGS_VOLUMES"))
+	cfg.Listeners = parseListeners(os.Getenv("LISTEN"))
+	cfg.TrustedProxies = parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	cfg.TranscodeProfiles = mergeTranscodeProfiles(DefaultTranscodeProfiles(),
+		parseTranscodeProfiles(os.Getenv("TRANSCODE_PROFILES")))
 
 	// Legacy single proxy support
 	if globalProxy := os.Getenv("GLOBAL_PROXY"); globalProxy != "" && len(cfg.GlobalProxies) == 0 {
@@ -87,8 +429,50 @@ func Load() *Config {
 	return cfg
 }
 
+// StreamingPassword returns the password streaming routes require, empty
+// when playback is public. Stream URLs handed to players carry it.
+func (c *Config) StreamingPassword() string {
+	if c.StreamingPublic {
+		return ""
+	}
+	return c.APIPassword
+}
+
+// RequiredAdminPassword returns the password admin routes require, empty when
+// they are open.
+func (c *Config) RequiredAdminPassword() string {
+	if c.AdminPassword != "" {
+		return c.AdminPassword
+	}
+	return c.APIPassword
+}
+
+// TLSEnabled reports whether the server serves HTTPS.
+func (c *Config) TLSEnabled() bool {
+	return len(c.ACMEDomains) > 0 || (c.TLSCertFile != "" && c.TLSKeyFile != "")
+}
+
+// defaultBaseURL returns the BASE_URL default: the first ACME domain when
+// certificates are provisioned, else localhost.
+func defaultBaseURL(port int, acmeDomains []string) string {
+	switch {
+	case len(acmeDomains) == 0:
+		return fmt.Sprintf("http://localhost:%d", port)
+	case port == 443:
+		return "https://" + acmeDomains[0]
+	default:
+		return fmt.Sprintf("https://%s:%d", acmeDomains[0], port)
+	}
+}
+
 // parseTransportRoutes parses the TRANSPORT_ROUTES env var.
-// Format: {URL=pattern, PROXY=url, DISABLE_SSL=true}, {URL=pattern2}
+// Format: {URL=pattern, PROXY=url, DISABLE_SSL=true}, {URL=pattern2, REDIRECT_HEADERS=User-Agent|Accept}
+// MATCH selects how URL is matched (see the RouteMatch constants) and PRIORITY
+// orders the routes. Routes with an unknown MATCH or an invalid regex are
+// skipped. HEADERS=Name:value|Name:value and the USER_AGENT, REFERER, ORIGIN
+// and COOKIE shortcuts set the route's default headers; CONCURRENCY, RATE
+// (see parseRate) and JITTER pace its requests. A field that is not KEY=value
+// continues the previous value, so values may contain ", ".
 func parseTransportRoutes(s string) []TransportRoute {
 	if s == "" {
 		return nil
@@ -106,7 +490,14 @@ func parseTransportRoutes(s string) []TransportRoute {
 		}
 
 		route := TransportRoute{}
-		fields := strings.Split(part, ", ")
+		var fields []string
+		for _, field := range strings.Split(part, ", ") {
+			if len(fields) > 0 && !routeFieldRe.MatchString(field) {
+				fields[len(fields)-1] += ", " + field
+				continue
+			}
+			fields = append(fields, field)
+		}
 		for _, field := range fields {
 			kv := strings.SplitN(field, "=", 2)
 			if len(kv) != 2 {
@@ -118,22 +509,234 @@ func parseTransportRoutes(s string) []TransportRoute {
 			switch strings.ToUpper(key) {
 			case "URL":
 				route.URLPattern = value
+			case "MATCH":
+				route.Match = strings.ToLower(value)
+			case "PRIORITY":
+				route.Priority, _ = strconv.Atoi(value)
 			case "PROXY":
 				route.Proxy = value
 			case "DISABLE_SSL":
 				route.DisableSSL = strings.ToLower(value) == "true"
 			case "DIRECT":
 				route.Direct = strings.ToLower(value) == "true"
+			case "REDIRECT_HEADERS":
+				route.RedirectHeaders = parseHeaderList(value)
+			case "REDIRECT_STREAM":
+				route.RedirectStream = strings.ToLower(value) == "true"
+			case "HEADERS":
+				for _, header := range strings.Split(value, "|") {
+					name, v, ok := strings.Cut(header, ":")
+					if ok {
+						route.setHeader(name, v)
+					}
+				}
+			case "CONCURRENCY":
+				route.Limit.Concurrency, _ = strconv.Atoi(value)
+			case "RATE":
+				route.Limit.Rate = parseRate(value)
+			case "JITTER":
+				route.Limit.Jitter, _ = time.ParseDuration(value)
+			case "USER_AGENT", "REFERER", "ORIGIN", "COOKIE":
+				route.setHeader(strings.ReplaceAll(key, "_", "-"), value)
 			}
 		}
-		if route.URLPattern != "" {
-			routes = append(routes, route)
+		if route.URLPattern == "" {
+			continue
+		}
+		switch route.Match {
+		case "":
+			route.Match = RouteMatchContains
+		case RouteMatchContains, RouteMatchPath:
+		case RouteMatchHost:
+			route.URLPattern = strings.ToLower(route.URLPattern)
+		case RouteMatchRegex:
+			re, err := regexp.Compile(route.URLPattern)
+			if err != nil {
+				continue
+			}
+			route.Regexp = re
+		default:
+			continue
 		}
+		routes = append(routes, route)
 	}
 
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].Priority > routes[j].Priority })
 	return routes
 }
 
+// routeFieldRe matches the start of a TRANSPORT_ROUTES KEY=value field.
+var routeFieldRe = regexp.MustCompile(`^\s*[A-Za-z_]+=`)
+
+// setHeader adds a default header to the route, skipping empty names or values.
+func (r *TransportRoute) setHeader(name, value string) {
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if name == "" || value == "" {
+		return
+	}
+	if r.Headers == nil {
+		r.Headers = make(map[string]string)
+	}
+	r.Headers[http.CanonicalHeaderKey(name)] = value
+}
+
+// parseExtractorLimits parses the EXTRACTOR_CONCURRENCY and EXTRACTOR_RATE
+// env vars, comma-separated extractor names (or "*" for the others) with
+// their cap or rate, e.g. "dlhd=2,*=4" and "dlhd=30/m,vavoo=2/s". Throttled
+// extractions get up to jitter of random delay.
+func parseExtractorLimits(concurrency, rate string, jitter time.Duration) map[string]throttle.Limit {
+	limits := make(map[string]throttle.Limit)
+	parse := func(s string, set func(limit *throttle.Limit, value string)) {
+		for _, entry := range strings.Split(s, ",") {
+			name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if name = strings.ToLower(strings.TrimSpace(name)); !ok || name == "" {
+				continue
+			}
+			limit := limits[name]
+			limit.Jitter = jitter
+			set(&limit, strings.TrimSpace(value))
+			limits[name] = limit
+		}
+	}
+	parse(concurrency, func(limit *throttle.Limit, value string) {
+		limit.Concurrency, _ = strconv.Atoi(value)
+	})
+	parse(rate, func(limit *throttle.Limit, value string) {
+		limit.Rate = parseRate(value)
+	})
+	return limits
+}
+
+// parseRate parses a rate as a count per second, minute or hour, e.g. "2/s"
+// or "30/m"; a bare number is per second. Invalid rates give 0.
+func parseRate(s string) float64 {
+	count, unit, _ := strings.Cut(strings.TrimSpace(s), "/")
+	n, err := strconv.ParseFloat(strings.TrimSpace(count), 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	switch strings.ToLower(strings.TrimSpace(unit)) {
+	case "", "s":
+		return n
+	case "m":
+		return n / 60
+	case "h":
+		return n / 3600
+	}
+	return 0
+}
+
+// parseRecordingVolumes parses the RECORDINGS_VOLUMES env var: comma-separated
+// directories, each with an optional quota, e.g. /mnt/disk1=500G,/mnt/disk2.
+func parseRecordingVolumes(s string) []RecordingVolume {
+	var volumes []RecordingVolume
+	for _, entry := range strings.Split(s, ",") {
+		path, quota, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		volumes = append(volumes, RecordingVolume{Path: path, Quota: parseSize(quota)})
+	}
+	return volumes
+}
+
+// parseListeners parses the LISTEN env var: comma-separated addresses with an
+// optional role, e.g. "127.0.0.1:7861,:7860=streaming,unix:/run/mp.sock".
+// A bare port listens on all interfaces; entries with an unknown role are
+// skipped.
+func parseListeners(s string) []Listener {
+	var listeners []Listener
+	for _, entry := range strings.Split(s, ",") {
+		address, role, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		address, role = strings.TrimSpace(address), strings.ToLower(strings.TrimSpace(role))
+		if address == "" {
+			continue
+		}
+		switch role {
+		case "":
+			role = ListenerRoleAll
+		case ListenerRoleAll, ListenerRoleStreaming:
+		default:
+			continue
+		}
+
+		l := Listener{Network: "tcp", Address: address, Role: role}
+		if path, ok := strings.CutPrefix(address, "unix:"); ok {
+			l.Network, l.Address = "unix", path
+		} else if _, err := strconv.Atoi(address); err == nil {
+			l.Address = ":" + address
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners
+}
+
+// parseTrustedProxies parses the TRUSTED_PROXIES env var: comma-separated
+// IPs or CIDRs, or "*" to trust every client. Invalid entries are skipped.
+func parseTrustedProxies(s string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+		case entry == "*":
+			prefixes = append(prefixes, netip.MustParsePrefix("0.0.0.0/0"), netip.MustParsePrefix("::/0"))
+		case strings.Contains(entry, "/"):
+			if prefix, err := netip.ParsePrefix(entry); err == nil {
+				prefixes = append(prefixes, prefix.Masked())
+			}
+		default:
+			if addr, err := netip.ParseAddr(entry); err == nil {
+				addr = addr.Unmap()
+				prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			}
+		}
+	}
+	return prefixes
+}
+
+// parseSize parses a size in bytes with an optional K, M, G or T suffix
+// (powers of 1024), e.g. "500G". Invalid sizes give 0.
+func parseSize(s string) int64 {
+	s = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	shift := 0
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'K':
+			shift = 10
+		case 'M':
+			shift = 20
+		case 'G':
+			shift = 30
+		case 'T':
+			shift = 40
+		}
+		if shift > 0 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return int64(n * float64(int64(1)<<shift))
+}
+
+// parseHeaderList parses a "|"-separated header list; "none" gives an empty,
+// non-nil list so that no headers follow a redirect.
+func parseHeaderList(s string) []string {
+	list := []string{}
+	if strings.ToLower(s) == "none" {
+		return list
+	}
+	for _, name := range strings.Split(s, "|") {
+		if name = strings.TrimSpace(name); name != "" {
+			list = append(list, name)
+		}
+	}
+	return list
+}
+
 func getEnvString(key, defaultVal string) string {
 	if val := os.Getenv(key); val != "" {
 		return val
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+
+	"media-proxy-go/pkg/types"
+)
+
+// DefaultTranscodeProfiles returns the built-in transcoding profiles. "720p"
+// is the transcoder's original ultrafast baseline H.264 output.
+func DefaultTranscodeProfiles() []types.TranscodeProfile {
+	return []types.TranscodeProfile{
+		{Name: "1080p", Height: 1080, VideoCodec: "libx264", VideoBitrate: "5000k", Preset: "veryfast", AudioCodec: "aac", AudioBitrate: "192k"},
+		{Name: "720p", Height: 720, VideoCodec: "libx264", Preset: "ultrafast", VideoProfile: "baseline", AudioCodec: "aac", AudioBitrate: "128k"},
+		{Name: "480p", Height: 480, VideoCodec: "libx264", VideoBitrate: "1200k", Preset: "veryfast", AudioCodec: "aac", AudioBitrate: "96k"},
+		{Name: "copy", VideoCodec: "copy", AudioCodec: "copy"},
+		{Name: "audio", VideoCodec: "none", AudioCodec: "aac", AudioBitrate: "128k"},
+	}
+}
+
+// parseTranscodeProfiles parses the TRANSCODE_PROFILES env var.
+// Format: {NAME=720p-nvenc, HEIGHT=720, VBITRATE=3000k, HWACCEL=cuda}, {NAME=hq, HEIGHT=1080, PRESET=medium}
+// VCODEC and ACODEC default to libx264 and aac (hardware profiles pick their own encoder).
+func parseTranscodeProfiles(s string) []types.TranscodeProfile {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+
+	var profiles []types.TranscodeProfile
+	for _, part := range strings.Split(s, "}, {") {
+		part = strings.Trim(part, "{} ")
+		if part == "" {
+			continue
+		}
+
+		p := types.TranscodeProfile{AudioCodec: "aac"}
+		for _, field := range strings.Split(part, ", ") {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			value := strings.TrimSpace(kv[1])
+
+			switch strings.ToUpper(strings.TrimSpace(kv[0])) {
+			case "NAME":
+				p.Name = value
+			case "HEIGHT":
+				p.Height, _ = strconv.Atoi(value)
+			case "VCODEC":
+				p.VideoCodec = value
+			case "VBITRATE":
+				p.VideoBitrate = value
+			case "PRESET":
+				p.Preset = value
+			case "VPROFILE":
+				p.VideoProfile = value
+			case "ACODEC":
+				p.AudioCodec = value
+			case "ABITRATE":
+				p.AudioBitrate = value
+			case "HWACCEL":
+				p.HWAccel = strings.ToLower(value)
+			case "DEVICE":
+				p.Device = value
+			}
+		}
+		if p.Name == "" {
+			continue
+		}
+		if p.VideoCodec == "" && p.HWAccel == "" {
+			p.VideoCodec = "libx264"
+		}
+		profiles = append(profiles, p)
+	}
+
+	return profiles
+}
+
+// mergeTranscodeProfiles adds custom profiles to the built-ins; a custom
+// profile replaces a built-in of the same name.
+func mergeTranscodeProfiles(builtin, custom []types.TranscodeProfile) []types.TranscodeProfile {
+	profiles := builtin
+	for _, c := range custom {
+		replaced := false
+		for i := range profiles {
+			if strings.EqualFold(profiles[i].Name, c.Name) {
+				profiles[i] = c
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			profiles = append(profiles, c)
+		}
+	}
+	return profiles
+}
@@ -0,0 +1,233 @@
+// Package cookies provides the cookie jar shared by all extractors, so
+// Cloudflare clearance and session cookies are reused across extractions and,
+// when a file is configured, across restarts.
+package cookies
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+
+	"media-proxy-go/pkg/logging"
+)
+
+// entry is a persisted cookie.
+type entry struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	Path     string    `json:"path"`
+	HostOnly bool      `json:"host_only,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HTTPOnly bool      `json:"http_only,omitempty"`
+	Expires  time.Time `json:"expires,omitempty"` // Zero for session cookies
+}
+
+func (e entry) key() string {
+	return e.Domain + ";" + e.Path + ";" + e.Name
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !e.Expires.After(now)
+}
+
+// Jar is an http.CookieJar keeping cookies per domain in memory and
+// optionally persisting them to a JSON file.
+type Jar struct {
+	filePath string // Empty disables persistence
+	log      *logging.Logger
+	jar      *cookiejar.Jar
+
+	saveMu  sync.Mutex // Serializes writes to filePath
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+// NewJar creates a cookie jar persisted at filePath.
+func NewJar(filePath string, log *logging.Logger) *Jar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &Jar{
+		filePath: filePath,
+		log:      log.WithComponent("cookies"),
+		jar:      jar,
+		entries:  make(map[string]entry),
+	}
+}
+
+// Load loads the persisted cookies. A missing file is not an error.
+func (j *Jar) Load() error {
+	if j.filePath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(j.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read cookies: %w", err)
+	}
+
+	var entries []entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse cookies: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	for _, e := range entries {
+		if e.expired(now) || e.Domain == "" {
+			continue
+		}
+		cookie := &http.Cookie{
+			Name:     e.Name,
+			Value:    e.Value,
+			Path:     e.Path,
+			Secure:   e.Secure,
+			HttpOnly: e.HTTPOnly,
+			Expires:  e.Expires,
+		}
+		if !e.HostOnly {
+			cookie.Domain = e.Domain
+		}
+		j.jar.SetCookies(&url.URL{Scheme: "https", Host: e.Domain, Path: e.Path}, []*http.Cookie{cookie})
+		j.entries[e.key()] = e
+	}
+
+	j.log.Debug("loaded cookies", "path", j.filePath, "count", len(j.entries))
+	return nil
+}
+
+// SetCookies stores the cookies received from u.
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	changed := false
+	now := time.Now()
+	host := strings.ToLower(u.Hostname())
+	for _, c := range cookies {
+		e := entry{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   strings.ToLower(strings.TrimPrefix(c.Domain, ".")),
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HttpOnly,
+		}
+		if e.Domain == "" {
+			e.Domain, e.HostOnly = host, true
+		} else if e.Domain != host && !strings.HasSuffix(host, "."+e.Domain) {
+			continue // Rejected by the jar too
+		}
+		if e.Path == "" || !strings.HasPrefix(e.Path, "/") {
+			e.Path = defaultPath(u.Path)
+		}
+
+		switch {
+		case c.MaxAge < 0:
+			e.Expires = now
+		case c.MaxAge > 0:
+			e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		case !c.Expires.IsZero():
+			e.Expires = c.Expires
+		}
+
+		if e.expired(now) {
+			if _, ok := j.entries[e.key()]; ok {
+				delete(j.entries, e.key())
+				changed = true
+			}
+			continue
+		}
+		if old, ok := j.entries[e.key()]; !ok || old != e {
+			j.entries[e.key()] = e
+			changed = true
+		}
+	}
+	j.mu.Unlock()
+
+	if changed {
+		if err := j.save(); err != nil {
+			j.log.Warn("failed to save cookies", "error", err)
+		}
+	}
+}
+
+// Cookies returns the cookies to send in a request to u.
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// Len returns the number of stored cookies.
+func (j *Jar) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.entries)
+}
+
+// save writes the unexpired cookies to disk.
+func (j *Jar) save() error {
+	if j.filePath == "" {
+		return nil
+	}
+
+	j.saveMu.Lock()
+	defer j.saveMu.Unlock()
+
+	j.mu.Lock()
+	now := time.Now()
+	entries := make([]entry, 0, len(j.entries))
+	for key, e := range j.entries {
+		if e.expired(now) {
+			delete(j.entries, key)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	j.mu.Unlock()
+	sort.Slice(entries, func(a, b int) bool { return entries[a].key() < entries[b].key() })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cookies: %w", err)
+	}
+
+	if dir := filepath.Dir(j.filePath); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create cookies directory: %w", err)
+		}
+	}
+
+	// Cookies are credentials
+	if err := os.WriteFile(j.filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to save cookies: %w", err)
+	}
+	return nil
+}
+
+// defaultPath returns the RFC 6265 default cookie path for a request path.
+func defaultPath(p string) string {
+	if p == "" || p[0] != '/' {
+		return "/"
+	}
+	if dir := path.Dir(p); dir != "." {
+		return dir
+	}
+	return "/"
+}
+
+var _ http.CookieJar = (*Jar)(nil)
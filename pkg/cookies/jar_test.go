@@ -0,0 +1,69 @@
+package cookies
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/logging"
+)
+
+func cookieValues(cookies []*http.Cookie) map[string]string {
+	values := make(map[string]string)
+	for _, c := range cookies {
+		values[c.Name] = c.Value
+	}
+	return values
+}
+
+func TestJar_PersistsAcrossRestarts(t *testing.T) {
+	log := logging.New("error", false, nil)
+	file := filepath.Join(t.TempDir(), "cookies.json")
+
+	site, _ := url.Parse("https://www.example.com/watch/1")
+	jar := NewJar(file, log)
+	jar.SetCookies(site, []*http.Cookie{
+		{Name: "cf_clearance", Value: "token", Domain: ".example.com", Path: "/", Expires: time.Now().Add(time.Hour)},
+		{Name: "session", Value: "abc"}, // Host-only session cookie
+		{Name: "stale", Value: "x", Expires: time.Now().Add(-time.Hour)},
+		{Name: "foreign", Value: "x", Domain: "other.com"},
+	})
+	if jar.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", jar.Len())
+	}
+
+	restarted := NewJar(file, log)
+	if err := restarted.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	got := cookieValues(restarted.Cookies(site))
+	if got["cf_clearance"] != "token" || got["session"] != "abc" || len(got) != 2 {
+		t.Errorf("cookies for %s = %v", site, got)
+	}
+
+	// Domain cookies apply to subdomains, host-only cookies do not
+	cdn, _ := url.Parse("https://cdn.example.com/")
+	if got := cookieValues(restarted.Cookies(cdn)); got["cf_clearance"] != "token" || got["session"] != "" {
+		t.Errorf("cookies for %s = %v", cdn, got)
+	}
+
+	// Deleting a cookie removes it from disk too
+	restarted.SetCookies(site, []*http.Cookie{{Name: "session", Value: "", MaxAge: -1, Path: "/watch"}})
+	reloaded := NewJar(file, log)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cookieValues(reloaded.Cookies(site)); got["session"] != "" || got["cf_clearance"] != "token" {
+		t.Errorf("cookies after delete = %v", got)
+	}
+}
+
+func TestJar_MissingFile(t *testing.T) {
+	jar := NewJar(filepath.Join(t.TempDir(), "missing.json"), logging.New("error", false, nil))
+	if err := jar.Load(); err != nil {
+		t.Errorf("Load() error = %v, want nil for missing file", err)
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"strings"
+
+	"media-proxy-go/pkg/bufpool"
 )
 
 // MP4Decrypter decrypts CENC-encrypted MP4 segments.
@@ -40,6 +43,16 @@ func NewMP4Decrypter(keyMap map[string][]byte) *MP4Decrypter {
 
 // DecryptSegment decrypts a combined init+media segment.
 func (d *MP4Decrypter) DecryptSegment(combined []byte) ([]byte, error) {
+	var result bytes.Buffer
+	if err := d.DecryptSegmentTo(&result, combined); err != nil {
+		return nil, err
+	}
+	return result.Bytes(), nil
+}
+
+// DecryptSegmentTo decrypts a combined init+media segment and appends the
+// result to dst, which is left untouched on error.
+func (d *MP4Decrypter) DecryptSegmentTo(dst *bytes.Buffer, combined []byte) error {
 	atoms := parseAtoms(combined)
 
 	processOrder := []string{"moov", "moof", "sidx", "mdat"}
@@ -51,7 +64,7 @@ func (d *MP4Decrypter) DecryptSegment(combined []byte) ([]byte, error) {
 				var err error
 				processed[atomType], err = d.processAtom(atomType, atom)
 				if err != nil {
-					return nil, err
+					return err
 				}
 				break
 			}
@@ -59,16 +72,16 @@ func (d *MP4Decrypter) DecryptSegment(combined []byte) ([]byte, error) {
 	}
 
 	// Rebuild the output
-	var result bytes.Buffer
+	dst.Grow(len(combined))
 	for _, atom := range atoms {
 		if data, ok := processed[atom.atomType]; ok {
-			result.Write(data)
+			dst.Write(data)
 		} else {
-			result.Write(packAtom(atom.atomType, atom.data))
+			dst.Write(packAtom(atom.atomType, atom.data))
 		}
 	}
 
-	return result.Bytes(), nil
+	return nil
 }
 
 type mp4Atom struct {
@@ -331,7 +344,10 @@ func (d *MP4Decrypter) decryptMdat(mdat mp4Atom) ([]byte, error) {
 		return packAtom("mdat", mdat.data), nil
 	}
 
-	var decrypted bytes.Buffer
+	// Samples are decrypted into a pooled buffer; packAtom copies the result
+	decrypted := bufpool.Get()
+	defer bufpool.Put(decrypted)
+	decrypted.Grow(len(mdat.data))
 	pos := 0
 
 	for i, info := range d.currentSampleInfo {
@@ -353,11 +369,9 @@ func (d *MP4Decrypter) decryptMdat(mdat mp4Atom) ([]byte, error) {
 		sample := mdat.data[pos : pos+sampleSize]
 		pos += sampleSize
 
-		decryptedSample, err := d.processSample(sample, info)
-		if err != nil {
+		if err := d.writeSample(decrypted, sample, info); err != nil {
 			return nil, err
 		}
-		decrypted.Write(decryptedSample)
 	}
 
 	return packAtom("mdat", decrypted.Bytes()), nil
@@ -368,26 +382,45 @@ func (d *MP4Decrypter) processSample(sample []byte, info sampleAuxInfo) ([]byte,
 		return sample, nil
 	}
 
+	var result bytes.Buffer
+	if err := d.writeSample(&result, sample, info); err != nil {
+		return nil, err
+	}
+	return result.Bytes(), nil
+}
+
+// writeSample appends a decrypted sample to dst. Encrypted ranges are
+// decrypted straight into dst's spare capacity.
+func (d *MP4Decrypter) writeSample(dst *bytes.Buffer, sample []byte, info sampleAuxInfo) error {
+	if !info.isEncrypted || d.currentKey == nil {
+		dst.Write(sample)
+		return nil
+	}
+
 	// Pad IV to 16 bytes
-	iv := make([]byte, 16)
-	copy(iv, info.iv)
+	var iv [16]byte
+	copy(iv[:], info.iv)
 
 	block, err := aes.NewCipher(d.currentKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
+		return fmt.Errorf("failed to create cipher: %w", err)
 	}
 
-	stream := cipher.NewCTR(block, iv)
+	stream := cipher.NewCTR(block, iv[:])
+	decrypt := func(encrypted []byte) {
+		dst.Grow(len(encrypted))
+		out := dst.AvailableBuffer()[:len(encrypted)]
+		stream.XORKeyStream(out, encrypted)
+		dst.Write(out)
+	}
 
 	if len(info.subSamples) == 0 {
 		// Decrypt entire sample
-		result := make([]byte, len(sample))
-		stream.XORKeyStream(result, sample)
-		return result, nil
+		decrypt(sample)
+		return nil
 	}
 
 	// Handle subsample encryption
-	var result bytes.Buffer
 	offset := 0
 
 	for _, sub := range info.subSamples {
@@ -396,7 +429,7 @@ func (d *MP4Decrypter) processSample(sample []byte, info sampleAuxInfo) ([]byte,
 		if clearEnd > len(sample) {
 			clearEnd = len(sample)
 		}
-		result.Write(sample[offset:clearEnd])
+		dst.Write(sample[offset:clearEnd])
 		offset = clearEnd
 
 		// Decrypt encrypted bytes
@@ -404,22 +437,16 @@ func (d *MP4Decrypter) processSample(sample []byte, info sampleAuxInfo) ([]byte,
 		if encEnd > len(sample) {
 			encEnd = len(sample)
 		}
-		encrypted := sample[offset:encEnd]
-		decrypted := make([]byte, len(encrypted))
-		stream.XORKeyStream(decrypted, encrypted)
-		result.Write(decrypted)
+		decrypt(sample[offset:encEnd])
 		offset = encEnd
 	}
 
 	// Handle remaining data as encrypted
 	if offset < len(sample) {
-		remaining := sample[offset:]
-		decrypted := make([]byte, len(remaining))
-		stream.XORKeyStream(decrypted, remaining)
-		result.Write(decrypted)
+		decrypt(sample[offset:])
 	}
 
-	return result.Bytes(), nil
+	return nil
 }
 
 func (d *MP4Decrypter) processTrak(trak mp4Atom) ([]byte, error) {
@@ -578,44 +605,59 @@ func (d *MP4Decrypter) processSidx(sidx mp4Atom) ([]byte, error) {
 // DecryptSegmentWithKeys is a convenience function to decrypt a segment.
 // keyID and key can be comma-separated for multi-key support.
 func DecryptSegmentWithKeys(initSegment, mediaSegment []byte, keyID, key string) ([]byte, error) {
+	var result bytes.Buffer
+	if err := DecryptSegmentWithKeysTo(&result, initSegment, mediaSegment, keyID, key); err != nil {
+		return nil, err
+	}
+	return result.Bytes(), nil
+}
+
+// DecryptSegmentWithKeysTo is DecryptSegmentWithKeys appending to dst.
+func DecryptSegmentWithKeysTo(dst *bytes.Buffer, initSegment, mediaSegment []byte, keyID, key string) error {
 	keyMap := make(map[string][]byte)
 
 	kids := strings.Split(keyID, ",")
 	keys := strings.Split(key, ",")
 
 	if len(kids) != len(keys) {
-		return nil, fmt.Errorf("mismatched key_id/key count: %d vs %d", len(kids), len(keys))
+		return fmt.Errorf("mismatched key_id/key count: %d vs %d", len(kids), len(keys))
 	}
 
 	for i := range kids {
-		kid := strings.TrimSpace(kids[i])
-		k := strings.TrimSpace(keys[i])
+		kid := NormalizeKID(kids[i])
+		k := NormalizeKID(keys[i])
 
 		keyBytes, err := hexToBytes(k)
 		if err != nil {
-			return nil, fmt.Errorf("invalid key hex: %w", err)
+			return fmt.Errorf("invalid key hex: %w", err)
 		}
 		keyMap[kid] = keyBytes
 	}
 
-	combined := append(initSegment, mediaSegment...)
+	combined := bufpool.Get()
+	defer bufpool.Put(combined)
+	combined.Grow(len(initSegment) + len(mediaSegment))
+	combined.Write(initSegment)
+	combined.Write(mediaSegment)
+
 	decrypter := NewMP4Decrypter(keyMap)
-	return decrypter.DecryptSegment(combined)
+	return decrypter.DecryptSegmentTo(dst, combined.Bytes())
 }
 
-func hexToBytes(hex string) ([]byte, error) {
-	if len(hex)%2 != 0 {
-		return nil, fmt.Errorf("odd length hex string")
-	}
-
-	result := make([]byte, len(hex)/2)
-	for i := 0; i < len(hex); i += 2 {
-		var b byte
-		_, err := fmt.Sscanf(hex[i:i+2], "%02x", &b)
-		if err != nil {
-			return nil, err
+// StripInitSegment removes the ftyp and moov boxes from a segment, so that
+// several decrypted fragments can follow a single init segment.
+func StripInitSegment(data []byte) []byte {
+	var result []byte
+	for _, atom := range parseAtoms(data) {
+		if atom.atomType == "ftyp" || atom.atomType == "moov" {
+			continue
 		}
-		result[i/2] = b
+		result = append(result, packAtom(atom.atomType, atom.data)...)
 	}
-	return result, nil
+	return result
+}
+
+// hexToBytes decodes a hex string, rejecting odd lengths and non-hex digits.
+func hexToBytes(s string) ([]byte, error) {
+	return hex.DecodeString(s)
 }
@@ -27,6 +27,16 @@ func TestHexToBytes(t *testing.T) {
 			hex:     "abc",
 			wantErr: true,
 		},
+		{
+			name:    "non-hex digit",
+			hex:     "0g",
+			wantErr: true,
+		},
+		{
+			name:    "sign accepted by Sscanf",
+			hex:     "+f",
+			wantErr: true,
+		},
 		{
 			name: "empty string",
 			hex:  "",
@@ -375,3 +385,16 @@ func TestExtractCodecFormat_NoFrma(t *testing.T) {
 		t.Errorf("extractCodecFormat() = %s, want empty string", format)
 	}
 }
+
+func TestStripInitSegment(t *testing.T) {
+	var data []byte
+	data = append(data, packAtom("ftyp", []byte("isom"))...)
+	data = append(data, packAtom("moov", []byte{0x01})...)
+	data = append(data, packAtom("moof", []byte{0x02})...)
+	data = append(data, packAtom("mdat", []byte{0x03, 0x04})...)
+
+	want := append(packAtom("moof", []byte{0x02}), packAtom("mdat", []byte{0x03, 0x04})...)
+	if got := StripInitSegment(data); !bytes.Equal(got, want) {
+		t.Errorf("StripInitSegment() = %x, want %x", got, want)
+	}
+}
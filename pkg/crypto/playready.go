@@ -0,0 +1,124 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// playReadyHeaderRecord is the PlayReady Object record type holding a
+// WRMHEADER; other records (license stores) carry no KIDs.
+const playReadyHeaderRecord = 1
+
+// PlayReadyKIDs returns the KIDs of a PlayReady Object: the data of a
+// PlayReady pssh box, or a decoded mspr:pro element. The WRMHEADER lists them
+// as base64 GUIDs, whose first three fields are little-endian; they are
+// converted to the big-endian byte order of CENC KIDs.
+func PlayReadyKIDs(pro []byte) ([]string, error) {
+	// length(4) record_count(2), then per record: type(2) length(2) value, all little-endian
+	if len(pro) < 6 {
+		return nil, fmt.Errorf("PlayReady object too short")
+	}
+	count := int(binary.LittleEndian.Uint16(pro[4:]))
+	pos := 6
+
+	var kids []string
+	for i := 0; i < count; i++ {
+		if pos+4 > len(pro) {
+			return nil, fmt.Errorf("PlayReady record header truncated")
+		}
+		recordType := binary.LittleEndian.Uint16(pro[pos:])
+		size := int(binary.LittleEndian.Uint16(pro[pos+2:]))
+		pos += 4
+		if pos+size > len(pro) {
+			return nil, fmt.Errorf("PlayReady record truncated")
+		}
+		if recordType == playReadyHeaderRecord {
+			found, err := wrmHeaderKIDs(pro[pos : pos+size])
+			if err != nil {
+				return nil, err
+			}
+			kids = append(kids, found...)
+		}
+		pos += size
+	}
+	return kids, nil
+}
+
+// wrmHeaderKIDs reads the KIDs of a UTF-16LE WRMHEADER. Version 4.0 headers
+// hold a single <KID>base64</KID>; 4.1 and later use <KID VALUE="base64"/>,
+// inside <KIDS> for several keys.
+func wrmHeaderKIDs(header []byte) ([]string, error) {
+	units := make([]uint16, len(header)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(header[2*i:])
+	}
+	text := strings.TrimPrefix(string(utf16.Decode(units)), "\ufeff")
+
+	decoder := xml.NewDecoder(strings.NewReader(text))
+	decoder.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) {
+		return r, nil // Already decoded from UTF-16
+	}
+
+	var kids []string
+	seen := make(map[string]bool)
+	add := func(value string) {
+		if kid := playReadyKID(value); kid != "" && !seen[kid] {
+			seen[kid] = true
+			kids = append(kids, kid)
+		}
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return kids, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid WRMHEADER: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "KID" {
+			continue
+		}
+		value := ""
+		for _, attr := range start.Attr {
+			if attr.Name.Local == "VALUE" {
+				value = attr.Value
+			}
+		}
+		if value == "" {
+			var content string
+			if err := decoder.DecodeElement(&content, &start); err != nil {
+				return nil, fmt.Errorf("invalid WRMHEADER KID: %w", err)
+			}
+			value = content
+		}
+		add(value)
+	}
+}
+
+// playReadyKID converts a base64 PlayReady GUID to a hex CENC KID, or "".
+func playReadyKID(value string) string {
+	guid, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(guid) != 16 {
+		return ""
+	}
+	kid := bytes.Clone(guid)
+	swapGUIDBytes(kid)
+	return hex.EncodeToString(kid)
+}
+
+// swapGUIDBytes converts a GUID between its little-endian (Windows) and
+// big-endian (UUID) byte orders, which differ in the first three fields.
+func swapGUIDBytes(b []byte) {
+	b[0], b[1], b[2], b[3] = b[3], b[2], b[1], b[0]
+	b[4], b[5] = b[5], b[4]
+	b[6], b[7] = b[7], b[6]
+}
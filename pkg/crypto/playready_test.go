@@ -0,0 +1,73 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+// buildPlayReadyObject builds a PlayReady Object with a WRMHEADER record
+// preceded by an empty license store record.
+func buildPlayReadyObject(header string) []byte {
+	var record []byte
+	for _, u := range utf16.Encode([]rune(header)) {
+		record = binary.LittleEndian.AppendUint16(record, u)
+	}
+
+	body := binary.LittleEndian.AppendUint16(nil, 2) // Record count
+	body = binary.LittleEndian.AppendUint16(body, 3) // Embedded license store
+	body = binary.LittleEndian.AppendUint16(body, 0)
+	body = binary.LittleEndian.AppendUint16(body, playReadyHeaderRecord)
+	body = binary.LittleEndian.AppendUint16(body, uint16(len(record)))
+	body = append(body, record...)
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(body)+4)), body...)
+}
+
+func TestPlayReadyKIDs(t *testing.T) {
+	// Base64 of the little-endian GUIDs of the KIDs below
+	const (
+		kid1     = "0123456789abcdef0123456789abcdef"
+		kid1GUID = "Z0UjAauJ780BI0VniavN7w=="
+		kid2     = "fedcba9876543210fedcba9876543210"
+		kid2GUID = "mLrc/lR2EDL+3LqYdlQyEA=="
+	)
+
+	tests := []struct {
+		name   string
+		header string
+		want   []string
+	}{
+		{
+			name:   "v4.0",
+			header: `<WRMHEADER xmlns="http://schemas.microsoft.com/DRM/2007/03/PlayReadyHeader" version="4.0.0.0"><DATA><PROTECTINFO><KEYLEN>16</KEYLEN><ALGID>AESCTR</ALGID></PROTECTINFO><KID>` + kid1GUID + `</KID><LA_URL>https://example.com/rightsmanager.asmx</LA_URL></DATA></WRMHEADER>`,
+			want:   []string{kid1},
+		},
+		{
+			name:   "v4.3 with several KIDs",
+			header: `<?xml version="1.0" encoding="utf-16"?><WRMHEADER xmlns="http://schemas.microsoft.com/DRM/2007/03/PlayReadyHeader" version="4.3.0.0"><DATA><PROTECTINFO><KIDS><KID ALGID="AESCTR" VALUE="` + kid1GUID + `"></KID><KID ALGID="AESCTR" VALUE="` + kid2GUID + `"/><KID VALUE="` + kid1GUID + `"/></KIDS></PROTECTINFO></DATA></WRMHEADER>`,
+			want:   []string{kid1, kid2},
+		},
+		{
+			name:   "no KID",
+			header: `<WRMHEADER version="4.0.0.0"><DATA></DATA></WRMHEADER>`,
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PlayReadyKIDs(buildPlayReadyObject(tt.header))
+			if err != nil {
+				t.Fatalf("PlayReadyKIDs() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PlayReadyKIDs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := PlayReadyKIDs(buildPlayReadyObject("<WRMHEADER>")[:12]); err == nil {
+		t.Error("PlayReadyKIDs(truncated) error = nil, want error")
+	}
+}
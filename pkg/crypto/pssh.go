@@ -0,0 +1,193 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// DRM system IDs as used in pssh boxes and ContentProtection schemeIdUri.
+const (
+	WidevineSystemID  = "edef8ba979d64acea3c827dcd51d21ed"
+	PlayReadySystemID = "9a04f07998404286ab92e65be0885f95"
+	ClearKeySystemID  = "e2719d58a985b3c9781ab030af78d30e" // DASH-IF ClearKey
+	CommonSystemID    = "1077efecc0b24d02ace33c1e52e2fb4b" // W3C Common PSSH
+)
+
+// systemNames maps DRM system IDs to short names.
+var systemNames = map[string]string{
+	WidevineSystemID:  "widevine",
+	PlayReadySystemID: "playready",
+	ClearKeySystemID:  "clearkey",
+	CommonSystemID:    "clearkey",
+}
+
+// PSSH is a parsed Protection System Specific Header box.
+type PSSH struct {
+	SystemID string   // 32 hex digits
+	KIDs     []string // Key IDs listed in the box (v1) or the Widevine/PlayReady header
+	Data     []byte   // System specific data
+}
+
+// SystemName returns the short name of a DRM system ID ("widevine", ...), or "" if unknown.
+func SystemName(systemID string) string {
+	return systemNames[NormalizeKID(systemID)]
+}
+
+// NormalizeKID converts a KID, key or system ID in hex, UUID or base64(url)
+// form to 32 lowercase hex digits. Input in none of these forms is returned
+// lowercased, so it fails hex decoding later.
+func NormalizeKID(kid string) string {
+	kid = strings.TrimSpace(kid)
+	if b, ok := decodeBase64KID(kid); ok {
+		return hex.EncodeToString(b)
+	}
+	kid = strings.TrimPrefix(strings.ToLower(kid), "urn:uuid:")
+	kid = strings.TrimPrefix(kid, "0x")
+	return strings.NewReplacer("-", "", "{", "", "}", "").Replace(kid)
+}
+
+// decodeBase64KID decodes a 16-byte value in base64 or base64url, with or
+// without padding, as used by EME ClearKey licenses. Only 22 characters (24
+// padded) are tried, a length no hex or UUID KID has.
+func decodeBase64KID(s string) ([]byte, bool) {
+	s = strings.TrimRight(s, "=")
+	if len(s) != 22 {
+		return nil, false
+	}
+	s = strings.NewReplacer("+", "-", "/", "_").Replace(s)
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	return b, err == nil
+}
+
+// ClearKey is a KID and its content key, each 32 lowercase hex digits.
+type ClearKey struct {
+	KID string
+	Key string
+}
+
+// Base64URL returns the KID and key in unpadded base64url, the encoding EME
+// ClearKey licenses use.
+func (k ClearKey) Base64URL() (kid, key string) {
+	kidBytes, _ := hex.DecodeString(k.KID)
+	keyBytes, _ := hex.DecodeString(k.Key)
+	return base64.RawURLEncoding.EncodeToString(kidBytes), base64.RawURLEncoding.EncodeToString(keyBytes)
+}
+
+// NormalizeKIDs applies NormalizeKID to each of comma-separated values.
+func NormalizeKIDs(s string) string {
+	if s == "" {
+		return ""
+	}
+	parts := strings.Split(s, ",")
+	for i, part := range parts {
+		parts[i] = NormalizeKID(part)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseClearKeys parses comma-separated KID:KEY pairs, each part in any form
+// NormalizeKID accepts.
+func ParseClearKeys(s string) ([]ClearKey, error) {
+	var keys []ClearKey
+	for _, pair := range strings.Split(s, ",") {
+		kid, key, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		k := ClearKey{KID: NormalizeKID(kid), Key: NormalizeKID(key)}
+		if !ok || !isKeyHex(k.KID) || !isKeyHex(k.Key) {
+			return nil, fmt.Errorf("invalid clearkey %q: expected KID:KEY of 16 bytes each in hex, UUID or base64", pair)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// isKeyHex reports whether s is 16 bytes in hex.
+func isKeyHex(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == 16
+}
+
+// ParsePSSH parses a pssh box, including its header.
+func ParsePSSH(box []byte) (*PSSH, error) {
+	atoms := parseAtoms(box)
+	if len(atoms) == 0 || atoms[0].atomType != "pssh" {
+		return nil, fmt.Errorf("not a pssh box")
+	}
+	data := atoms[0].data
+	if len(data) < 24 {
+		return nil, fmt.Errorf("pssh box too short")
+	}
+
+	version := data[0]
+	pssh := &PSSH{SystemID: hex.EncodeToString(data[4:20])}
+	pos := 20
+
+	if version > 0 {
+		count := int(binary.BigEndian.Uint32(data[pos:]))
+		pos += 4
+		if count < 0 || pos+count*16 > len(data) {
+			return nil, fmt.Errorf("pssh KID list truncated")
+		}
+		for i := 0; i < count; i++ {
+			pssh.KIDs = append(pssh.KIDs, hex.EncodeToString(data[pos:pos+16]))
+			pos += 16
+		}
+	}
+
+	if pos+4 > len(data) {
+		return nil, fmt.Errorf("pssh data size missing")
+	}
+	size := int(binary.BigEndian.Uint32(data[pos:]))
+	pos += 4
+	if size < 0 || pos+size > len(data) {
+		return nil, fmt.Errorf("pssh data truncated")
+	}
+	pssh.Data = data[pos : pos+size]
+
+	if len(pssh.KIDs) == 0 {
+		switch pssh.SystemID {
+		case WidevineSystemID:
+			pssh.KIDs = widevineKIDs(pssh.Data)
+		case PlayReadySystemID:
+			// A malformed header only loses the KID hints
+			pssh.KIDs, _ = PlayReadyKIDs(pssh.Data)
+		}
+	}
+	return pssh, nil
+}
+
+// widevineKIDs reads the key_id fields (field 2) of a Widevine PSSH data protobuf.
+func widevineKIDs(data []byte) []string {
+	var kids []string
+	for pos := 0; pos < len(data); {
+		tag, n := binary.Uvarint(data[pos:])
+		if n <= 0 {
+			break
+		}
+		pos += n
+
+		switch tag & 7 {
+		case 0: // varint
+			_, n := binary.Uvarint(data[pos:])
+			if n <= 0 {
+				return kids
+			}
+			pos += n
+		case 2: // length-delimited
+			size, n := binary.Uvarint(data[pos:])
+			if n <= 0 || uint64(len(data)-pos-n) < size {
+				return kids
+			}
+			pos += n
+			if tag>>3 == 2 && size == 16 {
+				kids = append(kids, hex.EncodeToString(data[pos:pos+16]))
+			}
+			pos += int(size)
+		default:
+			return kids
+		}
+	}
+	return kids
+}
@@ -0,0 +1,118 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// buildPSSH builds a pssh box for tests.
+func buildPSSH(version byte, systemID string, kids []string, data []byte) []byte {
+	body := []byte{version, 0, 0, 0}
+	sys, _ := hex.DecodeString(systemID)
+	body = append(body, sys...)
+	if version > 0 {
+		body = binary.BigEndian.AppendUint32(body, uint32(len(kids)))
+		for _, kid := range kids {
+			b, _ := hex.DecodeString(kid)
+			body = append(body, b...)
+		}
+	}
+	body = binary.BigEndian.AppendUint32(body, uint32(len(data)))
+	body = append(body, data...)
+	return packAtom("pssh", body)
+}
+
+func TestParsePSSH(t *testing.T) {
+	kid1 := "0123456789abcdef0123456789abcdef"
+	kid2 := "fedcba9876543210fedcba9876543210"
+
+	// Widevine header: algorithm (field 1, varint) and two key_ids (field 2)
+	k1, _ := hex.DecodeString(kid1)
+	k2, _ := hex.DecodeString(kid2)
+	widevine := []byte{0x08, 0x01, 0x12, 0x10}
+	widevine = append(widevine, k1...)
+	widevine = append(widevine, 0x12, 0x10)
+	widevine = append(widevine, k2...)
+	widevine = append(widevine, 0x22, 0x03, 'a', 'b', 'c') // content_id
+
+	tests := []struct {
+		name       string
+		box        []byte
+		wantSystem string
+		wantKIDs   []string
+		wantErr    bool
+	}{
+		{"widevine v0", buildPSSH(0, WidevineSystemID, nil, widevine), WidevineSystemID, []string{kid1, kid2}, false},
+		{"v1 KID list", buildPSSH(1, PlayReadySystemID, []string{kid2}, []byte("xml")), PlayReadySystemID, []string{kid2}, false},
+		{"playready v0", buildPSSH(0, PlayReadySystemID, nil, buildPlayReadyObject(`<WRMHEADER version="4.0.0.0"><DATA><KID>Z0UjAauJ780BI0VniavN7w==</KID></DATA></WRMHEADER>`)), PlayReadySystemID, []string{kid1}, false},
+		{"not pssh", packAtom("moov", make([]byte, 32)), "", nil, true},
+		{"truncated", buildPSSH(0, WidevineSystemID, nil, nil)[:20], "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pssh, err := ParsePSSH(tt.box)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePSSH() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if pssh.SystemID != tt.wantSystem {
+				t.Errorf("SystemID = %s, want %s", pssh.SystemID, tt.wantSystem)
+			}
+			if len(pssh.KIDs) != len(tt.wantKIDs) {
+				t.Fatalf("KIDs = %v, want %v", pssh.KIDs, tt.wantKIDs)
+			}
+			for i := range tt.wantKIDs {
+				if pssh.KIDs[i] != tt.wantKIDs[i] {
+					t.Errorf("KIDs[%d] = %s, want %s", i, pssh.KIDs[i], tt.wantKIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeKID(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"0123456789ABCDEF0123456789ABCDEF", "0123456789abcdef0123456789abcdef"},
+		{"01234567-89ab-cdef-0123-456789abcdef", "0123456789abcdef0123456789abcdef"},
+		{"{01234567-89AB-CDEF-0123-456789ABCDEF}", "0123456789abcdef0123456789abcdef"},
+		{"urn:uuid:EDEF8BA9-79D6-4ACE-A3C8-27DCD51D21ED", WidevineSystemID},
+		{"0x0123456789abcdef0123456789abcdef", "0123456789abcdef0123456789abcdef"},
+		{"ASNFZ4mrze8BI0VniavN7w", "0123456789abcdef0123456789abcdef"},   // base64url
+		{"ASNFZ4mrze8BI0VniavN7w==", "0123456789abcdef0123456789abcdef"}, // padded
+		{"7//7v//u//+7//7v//u/+w", "effffbbfffeeffffbbfffeeffffbbffb"},   // base64
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeKID(tt.input); got != tt.want {
+			t.Errorf("NormalizeKID(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseClearKeys(t *testing.T) {
+	keys, err := ParseClearKeys("01234567-89AB-CDEF-0123-456789ABCDEF:ASNFZ4mrze8BI0VniavN7w, ffffffffffffffffffffffffffffffff:00000000000000000000000000000001")
+	if err != nil {
+		t.Fatalf("ParseClearKeys() error = %v", err)
+	}
+	want := []ClearKey{
+		{KID: "0123456789abcdef0123456789abcdef", Key: "0123456789abcdef0123456789abcdef"},
+		{KID: "ffffffffffffffffffffffffffffffff", Key: "00000000000000000000000000000001"},
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("ParseClearKeys() = %+v, want %+v", keys, want)
+	}
+
+	for _, invalid := range []string{"", "0123456789abcdef0123456789abcdef", "0123:4567", "0123456789abcdef0123456789abcdef:zz"} {
+		if _, err := ParseClearKeys(invalid); err == nil {
+			t.Errorf("ParseClearKeys(%q) error = nil, want error", invalid)
+		}
+	}
+}
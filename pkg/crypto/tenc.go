@@ -0,0 +1,71 @@
+package crypto
+
+import (
+	"encoding/hex"
+)
+
+// DefaultKIDs returns the default KIDs of the encrypted tracks in an init
+// segment, read from the tenc boxes of their sample entries.
+func DefaultKIDs(initSegment []byte) []string {
+	var kids []string
+	seen := make(map[string]bool)
+
+	for _, moov := range parseAtoms(initSegment) {
+		if moov.atomType != "moov" {
+			continue
+		}
+		for _, stsd := range findAtoms(moov.data, "trak", "mdia", "minf", "stbl", "stsd") {
+			if len(stsd.data) < 8 {
+				continue
+			}
+			for _, entry := range parseAtoms(stsd.data[8:]) {
+				kid := sampleEntryKID(entry)
+				if kid != "" && !seen[kid] {
+					seen[kid] = true
+					kids = append(kids, kid)
+				}
+			}
+		}
+	}
+	return kids
+}
+
+// findAtoms returns the atoms at path below data.
+func findAtoms(data []byte, path ...string) []mp4Atom {
+	var result []mp4Atom
+	for _, atom := range parseAtoms(data) {
+		if atom.atomType != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			result = append(result, atom)
+		} else {
+			result = append(result, findAtoms(atom.data, path[1:]...)...)
+		}
+	}
+	return result
+}
+
+// sampleEntryKID returns the default KID of an encrypted (encv/enca) sample entry.
+func sampleEntryKID(entry mp4Atom) string {
+	var fixedSize int
+	switch entry.atomType {
+	case "enca":
+		fixedSize = 28
+	case "encv":
+		fixedSize = 78
+	default:
+		return ""
+	}
+	if fixedSize > len(entry.data) {
+		return ""
+	}
+
+	for _, tenc := range findAtoms(entry.data[fixedSize:], "sinf", "schi", "tenc") {
+		// version_flags(4) reserved(1) pattern(1) is_protected(1) iv_size(1) KID(16)
+		if len(tenc.data) >= 24 {
+			return hex.EncodeToString(tenc.data[8:24])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,32 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestDefaultKIDs(t *testing.T) {
+	kid := "0123456789abcdef0123456789abcdef"
+	kidBytes, _ := hex.DecodeString(kid)
+
+	tenc := append([]byte{0, 0, 0, 0, 0, 0, 1, 8}, kidBytes...)
+	sinf := packAtom("sinf", append(packAtom("frma", []byte("avc1")), packAtom("schi", packAtom("tenc", tenc))...))
+	encv := packAtom("encv", append(make([]byte, 78), sinf...))
+	stsd := packAtom("stsd", append([]byte{0, 0, 0, 0, 0, 0, 0, 1}, encv...))
+	trak := packAtom("trak", packAtom("mdia", packAtom("minf", packAtom("stbl", stsd))))
+
+	// A clear audio track next to the encrypted video track
+	mp4a := packAtom("mp4a", make([]byte, 28))
+	clearTrak := packAtom("trak", packAtom("mdia", packAtom("minf", packAtom("stbl", packAtom("stsd", append([]byte{0, 0, 0, 0, 0, 0, 0, 1}, mp4a...))))))
+
+	init := append(packAtom("ftyp", []byte("isom")), packAtom("moov", append(trak, clearTrak...))...)
+
+	kids := DefaultKIDs(init)
+	if len(kids) != 1 || kids[0] != kid {
+		t.Errorf("DefaultKIDs() = %v, want [%s]", kids, kid)
+	}
+
+	if kids := DefaultKIDs(packAtom("ftyp", []byte("isom"))); len(kids) != 0 {
+		t.Errorf("DefaultKIDs() without moov = %v, want none", kids)
+	}
+}
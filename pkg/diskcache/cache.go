@@ -0,0 +1,389 @@
+// Package diskcache is a size-capped LRU cache of HTTP response bodies on disk.
+// It backs the VOD segment cache, so rewatching a VOD or several clients
+// watching the same one fetch each segment from upstream only once.
+//
+// Each entry is stored as two files named after the SHA-256 of its key: the
+// body and a JSON metadata file. Entries survive restarts; Load rebuilds the
+// index from the metadata files, least recently used first by modification time.
+package diskcache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"media-proxy-go/pkg/logging"
+)
+
+// metaSuffix is appended to an entry's body file name for its metadata file.
+const metaSuffix = ".json"
+
+// Entry describes a cached response.
+type Entry struct {
+	Key         string            `json:"key"`
+	StatusCode  int               `json:"status"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Size        int64             `json:"size"`
+	Expires     int64             `json:"expires,omitempty"` // Unix time, 0 never expires
+	StoredAt    int64             `json:"stored_at"`
+}
+
+// Stats is a snapshot of the cache's usage.
+type Stats struct {
+	Entries int   `json:"entries"`
+	Size    int64 `json:"size"`
+	MaxSize int64 `json:"max_size"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+// Cache is a size-capped LRU cache of response bodies in a directory.
+type Cache struct {
+	dir          string
+	maxSize      int64
+	maxEntrySize int64
+	log          *logging.Logger
+	now          func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List               // Of *Entry, most recently used first
+	entries map[string]*list.Element // By key
+	size    int64
+	hits    int64
+	misses  int64
+}
+
+// New creates a cache in dir holding at most maxSize bytes. Responses larger
+// than maxEntrySize are not cached. Call Load before use.
+func New(dir string, maxSize, maxEntrySize int64, log *logging.Logger) *Cache {
+	return &Cache{
+		dir:          dir,
+		maxSize:      maxSize,
+		maxEntrySize: min(maxEntrySize, maxSize),
+		log:          log.WithComponent("diskcache"),
+		now:          time.Now,
+		lru:          list.New(),
+		entries:      make(map[string]*list.Element),
+	}
+}
+
+// Load creates the cache directory and indexes the entries already in it,
+// dropping incomplete and expired ones.
+func (c *Cache) Load() error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	type loaded struct {
+		entry   *Entry
+		modTime time.Time
+	}
+	var found []loaded
+	for _, f := range files {
+		name := f.Name()
+		if strings.HasPrefix(name, ".tmp-") {
+			os.Remove(filepath.Join(c.dir, name)) // Left over from an interrupted write
+			continue
+		}
+		if !strings.HasSuffix(name, metaSuffix) {
+			continue
+		}
+
+		entry, modTime, err := c.readEntry(strings.TrimSuffix(name, metaSuffix))
+		if err != nil {
+			c.log.Debug("dropping cache entry", "file", name, "error", err)
+			c.removeFiles(strings.TrimSuffix(name, metaSuffix))
+			continue
+		}
+		found = append(found, loaded{entry, modTime})
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].modTime.After(found[j].modTime) })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, l := range found {
+		c.entries[l.entry.Key] = c.lru.PushBack(l.entry)
+		c.size += l.entry.Size
+	}
+	c.evict()
+
+	c.log.Info("segment cache loaded", "dir", c.dir, "entries", len(c.entries), "size", c.size)
+	return nil
+}
+
+// readEntry reads and validates the metadata of the entry stored as name.
+func (c *Cache) readEntry(name string) (*Entry, time.Time, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, name+metaSuffix))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, time.Time{}, err
+	}
+	if fileName(entry.Key) != name {
+		return nil, time.Time{}, errors.New("key does not match file name")
+	}
+	if c.expired(&entry) {
+		return nil, time.Time{}, errors.New("expired")
+	}
+
+	info, err := os.Stat(filepath.Join(c.dir, name))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if info.Size() != entry.Size {
+		return nil, time.Time{}, errors.New("incomplete body")
+	}
+	return &entry, info.ModTime(), nil
+}
+
+// Get returns a cached entry and its body. The caller must close the body.
+func (c *Cache) Get(key string) (Entry, io.ReadCloser, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return Entry{}, nil, false
+	}
+	entry := el.Value.(*Entry)
+	if c.expired(entry) {
+		c.remove(el)
+		c.misses++
+		return Entry{}, nil, false
+	}
+
+	path := filepath.Join(c.dir, fileName(key))
+	f, err := os.Open(path)
+	if err != nil {
+		c.log.Warn("cached file unreadable", "key", key, "error", err)
+		c.remove(el)
+		c.misses++
+		return Entry{}, nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	now := c.now()
+	os.Chtimes(path, now, now) // Keeps the LRU order across restarts
+	c.hits++
+	return *entry, f, true
+}
+
+// Writer returns a writer that stores entry's body under entry.Key. The
+// entry is added to the cache by Commit; Abort discards it. size is the
+// expected body size, or -1 if unknown.
+func (c *Cache) Writer(entry Entry, size int64) *Writer {
+	w := &Writer{cache: c, entry: entry, expected: size}
+	if size > c.maxEntrySize {
+		w.err = errors.New("response too large to cache")
+		return w
+	}
+
+	f, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		w.err = fmt.Errorf("failed to create cache file: %w", err)
+		return w
+	}
+	w.file = f
+	return w
+}
+
+// Delete removes the entry for key, if any.
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Purge removes all entries.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.lru.Front(); el != nil; el = c.lru.Front() {
+		c.remove(el)
+	}
+}
+
+// Stats returns the cache's current usage.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Entries: len(c.entries),
+		Size:    c.size,
+		MaxSize: c.maxSize,
+		Hits:    c.hits,
+		Misses:  c.misses,
+	}
+}
+
+// add moves the body and metadata files written at tmp into place and
+// indexes the entry, replacing an older one with the same key. Both happen
+// under mu, so removing the older entry can't unlink the new files and
+// readers never see the new body with the old metadata.
+func (c *Cache) add(tmp string, entry *Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[entry.Key]; ok {
+		// Its files are replaced by the renames
+		c.size -= el.Value.(*Entry).Size
+		c.lru.Remove(el)
+		delete(c.entries, entry.Key)
+	}
+
+	name := fileName(entry.Key)
+	path := filepath.Join(c.dir, name)
+	if err := os.Rename(tmp, path); err != nil {
+		c.removeFiles(name)
+		return fmt.Errorf("failed to store cache file: %w", err)
+	}
+	if err := os.Rename(tmp+metaSuffix, path+metaSuffix); err != nil {
+		c.removeFiles(name)
+		return fmt.Errorf("failed to store cache entry: %w", err)
+	}
+
+	c.entries[entry.Key] = c.lru.PushFront(entry)
+	c.size += entry.Size
+	c.evict()
+	return nil
+}
+
+// evict removes least recently used entries until the cache fits; the caller holds mu.
+func (c *Cache) evict() {
+	for c.size > c.maxSize {
+		el := c.lru.Back()
+		if el == nil {
+			return
+		}
+		c.remove(el)
+	}
+}
+
+// remove deletes an entry and its files; the caller holds mu. Open readers
+// keep reading the unlinked body.
+func (c *Cache) remove(el *list.Element) {
+	entry := c.lru.Remove(el).(*Entry)
+	delete(c.entries, entry.Key)
+	c.size -= entry.Size
+	c.removeFiles(fileName(entry.Key))
+}
+
+// removeFiles deletes the body and metadata files of the entry stored as name.
+func (c *Cache) removeFiles(name string) {
+	os.Remove(filepath.Join(c.dir, name))
+	os.Remove(filepath.Join(c.dir, name+metaSuffix))
+}
+
+// expired reports whether an entry is past its expiry time.
+func (c *Cache) expired(entry *Entry) bool {
+	return entry.Expires > 0 && c.now().Unix() >= entry.Expires
+}
+
+// fileName returns the file name an entry with key is stored under.
+func fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Writer stores a response body in the cache as it is read by a client.
+type Writer struct {
+	cache    *Cache
+	entry    Entry
+	expected int64
+	file     *os.File
+	written  int64
+	err      error // Set once the body can't be cached
+}
+
+// Write appends p to the cached body. It never fails: once the body can't
+// be cached (too large, disk error) further writes are discarded.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return len(p), nil
+	}
+	if w.written+int64(len(p)) > w.cache.maxEntrySize {
+		w.fail(errors.New("response too large to cache"))
+		return len(p), nil
+	}
+	n, err := w.file.Write(p)
+	w.written += int64(n)
+	if err != nil {
+		w.fail(fmt.Errorf("failed to write cache file: %w", err))
+	}
+	return len(p), nil
+}
+
+// Commit adds the written body to the cache.
+func (w *Writer) Commit() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.expected >= 0 && w.written != w.expected {
+		w.fail(fmt.Errorf("body is %d bytes, expected %d", w.written, w.expected))
+		return w.err
+	}
+	if err := w.file.Close(); err != nil {
+		w.fail(fmt.Errorf("failed to close cache file: %w", err))
+		return w.err
+	}
+
+	w.entry.Size = w.written
+	w.entry.StoredAt = w.cache.now().Unix()
+	meta, err := json.Marshal(w.entry)
+	if err != nil {
+		w.fail(fmt.Errorf("failed to encode cache entry: %w", err))
+		return w.err
+	}
+
+	if err := os.WriteFile(w.file.Name()+metaSuffix, meta, 0644); err != nil {
+		w.fail(fmt.Errorf("failed to write cache entry: %w", err))
+		return w.err
+	}
+	if err := w.cache.add(w.file.Name(), &w.entry); err != nil {
+		w.fail(err)
+		return w.err
+	}
+
+	w.err = errors.New("already committed")
+	return nil
+}
+
+// Abort discards the written body.
+func (w *Writer) Abort() {
+	w.fail(errors.New("aborted"))
+}
+
+// fail records why the body can't be cached and removes the temporary files.
+func (w *Writer) fail(err error) {
+	if w.err != nil {
+		return
+	}
+	w.err = err
+	if w.file != nil {
+		w.file.Close()
+		os.Remove(w.file.Name())
+		os.Remove(w.file.Name() + metaSuffix)
+	}
+}
@@ -0,0 +1,184 @@
+package diskcache
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/logging"
+)
+
+func newTestCache(t *testing.T, dir string, maxSize, maxEntry int64) *Cache {
+	t.Helper()
+	c := New(dir, maxSize, maxEntry, logging.New("error", false, io.Discard))
+	if err := c.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return c
+}
+
+func store(t *testing.T, c *Cache, key, body string) {
+	t.Helper()
+	w := c.Writer(Entry{Key: key, StatusCode: 200, ContentType: "video/MP2T"}, int64(len(body)))
+	w.Write([]byte(body))
+	if err := w.Commit(); err != nil {
+		t.Fatalf("Commit(%s) error = %v", key, err)
+	}
+}
+
+func read(t *testing.T, c *Cache, key string) (string, bool) {
+	t.Helper()
+	_, body, ok := c.Get(key)
+	if !ok {
+		return "", false
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read %s: %v", key, err)
+	}
+	return string(data), true
+}
+
+func TestCache_LRUEviction(t *testing.T) {
+	c := newTestCache(t, t.TempDir(), 10, 10)
+
+	store(t, c, "a", "aaaa")
+	store(t, c, "b", "bbbb")
+	if body, ok := read(t, c, "a"); !ok || body != "aaaa" {
+		t.Fatalf("Get(a) = %q, %v", body, ok)
+	}
+
+	// b is the least recently used entry
+	store(t, c, "c", "cccc")
+	if _, ok := read(t, c, "b"); ok {
+		t.Error("b was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := read(t, c, key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+
+	stats := c.Stats()
+	if stats.Entries != 2 || stats.Size != 8 || stats.Hits != 3 || stats.Misses != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestCache_RejectsIncompleteAndOversized(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestCache(t, dir, 100, 5)
+
+	w := c.Writer(Entry{Key: "big"}, -1)
+	w.Write([]byte("123456"))
+	if err := w.Commit(); err == nil {
+		t.Error("Commit() of an oversized body succeeded")
+	}
+
+	w = c.Writer(Entry{Key: "short"}, 4)
+	w.Write([]byte("12"))
+	if err := w.Commit(); err == nil {
+		t.Error("Commit() of a truncated body succeeded")
+	}
+
+	w = c.Writer(Entry{Key: "aborted"}, -1)
+	w.Write([]byte("12"))
+	w.Abort()
+
+	if stats := c.Stats(); stats.Entries != 0 {
+		t.Errorf("Stats() = %+v, want no entries", stats)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("leftover files: %v", files)
+	}
+}
+
+func TestCache_PersistsAndExpires(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestCache(t, dir, 100, 100)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	store(t, c, "https://cdn.example.com/seg1.ts|", "segment")
+	w := c.Writer(Entry{Key: "expiring", Expires: now.Add(time.Minute).Unix()}, -1)
+	w.Write([]byte("soon"))
+	if err := w.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	// A new cache over the same directory finds the entries
+	reloaded := newTestCache(t, dir, 100, 100)
+	reloaded.now = func() time.Time { return now }
+	entry, body, ok := reloaded.Get("https://cdn.example.com/seg1.ts|")
+	if !ok {
+		t.Fatal("entry lost across reload")
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "segment" || entry.ContentType != "video/MP2T" || entry.Size != 7 {
+		t.Errorf("entry = %+v, body = %q", entry, data)
+	}
+
+	reloaded.now = func() time.Time { return now.Add(2 * time.Minute) }
+	if _, ok := read(t, reloaded, "expiring"); ok {
+		t.Error("expired entry was served")
+	}
+
+	reloaded.Purge()
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+		t.Errorf("files after Purge(): %s", strings.Join(names, ", "))
+	}
+}
+
+func TestCache_ConcurrentCommitAndEvict(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestCache(t, dir, 24, 8)
+
+	// Writers replace the same key while others evict and delete it
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				body := strings.Repeat(strconv.Itoa(i), 4+j%4)
+				w := c.Writer(Entry{Key: "shared"}, int64(len(body)))
+				w.Write([]byte(body))
+				w.Commit()
+				if j%3 == 0 {
+					c.Delete("shared")
+				}
+				w = c.Writer(Entry{Key: fmt.Sprintf("other-%d", i)}, 8)
+				w.Write([]byte("evicting"))
+				w.Commit()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every indexed entry has its own body and metadata on disk
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, el := range c.entries {
+		entry := el.Value.(*Entry)
+		if _, _, err := c.readEntry(fileName(key)); err != nil {
+			t.Errorf("entry %s: %v", key, err)
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, fileName(key)))
+		if err != nil || int64(len(data)) != entry.Size {
+			t.Errorf("body of %s = %q, %v, want %d bytes", key, data, err, entry.Size)
+		}
+	}
+}
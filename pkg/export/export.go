@@ -0,0 +1,57 @@
+// Package export uploads finished recordings to external storage.
+// Supported backends are S3-compatible object stores and WebDAV servers.
+package export
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+// New creates the uploader selected by cfg.ExportType.
+// It returns nil without error when export is disabled.
+func New(cfg *config.Config, log *logging.Logger) (interfaces.Uploader, error) {
+	switch cfg.ExportType {
+	case "":
+		return nil, nil
+	case "s3":
+		if cfg.S3Bucket == "" || cfg.S3AccessKey == "" || cfg.S3SecretKey == "" {
+			return nil, fmt.Errorf("S3 export requires S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY")
+		}
+		return NewS3Uploader(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		}, log)
+	case "webdav":
+		if cfg.WebDAVURL == "" {
+			return nil, fmt.Errorf("WebDAV export requires WEBDAV_URL")
+		}
+		return NewWebDAVUploader(cfg.WebDAVURL, cfg.WebDAVUsername, cfg.WebDAVPassword, log), nil
+	default:
+		return nil, fmt.Errorf("unknown export type: %s", cfg.ExportType)
+	}
+}
+
+// RemotePath expands the path template for a recording.
+// Supported placeholders: {id}, {name}, {filename}, {date} (YYYY-MM-DD), {time} (HHMMSS).
+func RemotePath(template string, rec *types.Recording) string {
+	started := time.Unix(rec.StartedAt, 0)
+	replacer := strings.NewReplacer(
+		"{id}", rec.ID,
+		"{name}", strings.ReplaceAll(rec.Name, "/", "_"),
+		"{filename}", filepath.Base(rec.FilePath),
+		"{date}", started.Format("2006-01-02"),
+		"{time}", started.Format("150405"),
+	)
+	return strings.TrimLeft(replacer.Replace(template), "/")
+}
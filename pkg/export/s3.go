@@ -0,0 +1,195 @@
+package export
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+)
+
+// unsignedPayload skips body hashing so large recordings can be streamed from disk.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Config holds settings for an S3-compatible object store.
+type S3Config struct {
+	Endpoint  string // e.g. https://s3.amazonaws.com or http://minio:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // Use endpoint/bucket/key instead of bucket.endpoint/key (MinIO, most self-hosted stores)
+}
+
+// S3Uploader uploads files with a single SigV4-signed PUT.
+// Single PUTs are limited to 5 GiB by S3.
+type S3Uploader struct {
+	cfg        S3Config
+	endpoint   *url.URL
+	httpClient *http.Client
+	log        *logging.Logger
+	now        func() time.Time
+}
+
+// NewS3Uploader creates a new S3 uploader.
+func NewS3Uploader(cfg S3Config, log *logging.Logger) (*S3Uploader, error) {
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint: %s", cfg.Endpoint)
+	}
+
+	return &S3Uploader{
+		cfg:        cfg,
+		endpoint:   endpoint,
+		httpClient: &http.Client{},
+		log:        log.WithComponent("s3-export"),
+		now:        time.Now,
+	}, nil
+}
+
+// Name returns the backend name.
+func (u *S3Uploader) Name() string {
+	return "s3"
+}
+
+// Upload stores the file as an object at remotePath.
+func (u *S3Uploader) Upload(ctx context.Context, remotePath string, r io.Reader, size int64) (string, error) {
+	objectURL := u.objectURL(remotePath)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectURL, r)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "video/MP2T")
+
+	u.sign(req, unsignedPayload)
+
+	u.log.Debug("uploading to S3", "url", objectURL, "size", size)
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload to S3: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("S3 returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return objectURL, nil
+}
+
+// objectURL builds the URL of an object for the configured addressing style.
+func (u *S3Uploader) objectURL(key string) string {
+	objURL := *u.endpoint
+	escapedKey := escapePath(key)
+	if u.cfg.PathStyle {
+		objURL.Path = "/" + u.cfg.Bucket + "/" + strings.TrimPrefix(key, "/")
+		objURL.RawPath = "/" + u.cfg.Bucket + "/" + escapedKey
+	} else {
+		objURL.Host = u.cfg.Bucket + "." + objURL.Host
+		objURL.Path = "/" + strings.TrimPrefix(key, "/")
+		objURL.RawPath = "/" + escapedKey
+	}
+	return objURL.String()
+}
+
+// sign adds AWS Signature Version 4 headers to the request.
+// All headers present on the request are signed.
+func (u *S3Uploader) sign(req *http.Request, payloadHash string) {
+	now := u.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Canonical headers (host is not part of req.Header)
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := dateStamp + "/" + u.cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+u.cfg.SecretKey), dateStamp)
+	key = hmacSHA256(key, u.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		u.cfg.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+// escapePath URI-encodes an object key as required by SigV4: every byte
+// except unreserved characters and slashes is percent-encoded.
+func escapePath(key string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for _, c := range []byte(strings.TrimPrefix(key, "/")) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0x0f])
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Ensure S3Uploader implements Uploader.
+var _ interfaces.Uploader = (*S3Uploader)(nil)
@@ -0,0 +1,121 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+)
+
+// WebDAVUploader uploads files to a WebDAV server (Nextcloud, rclone serve, nginx dav...).
+type WebDAVUploader struct {
+	baseURL    string
+	username   string
+	password   string
+	httpClient *http.Client
+	log        *logging.Logger
+}
+
+// NewWebDAVUploader creates a new WebDAV uploader.
+func NewWebDAVUploader(baseURL, username, password string, log *logging.Logger) *WebDAVUploader {
+	return &WebDAVUploader{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		username:   username,
+		password:   password,
+		httpClient: &http.Client{},
+		log:        log.WithComponent("webdav-export"),
+	}
+}
+
+// Name returns the backend name.
+func (u *WebDAVUploader) Name() string {
+	return "webdav"
+}
+
+// Upload creates any missing parent collections and PUTs the file at remotePath.
+func (u *WebDAVUploader) Upload(ctx context.Context, remotePath string, r io.Reader, size int64) (string, error) {
+	remotePath = strings.TrimPrefix(remotePath, "/")
+
+	if dir := path.Dir(remotePath); dir != "." {
+		if err := u.mkcolAll(ctx, dir); err != nil {
+			return "", err
+		}
+	}
+
+	fileURL := u.url(remotePath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, fileURL, r)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "video/MP2T")
+
+	u.log.Debug("uploading to WebDAV", "url", fileURL, "size", size)
+
+	resp, err := u.do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload to WebDAV: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return fileURL, nil
+	default:
+		return "", fmt.Errorf("WebDAV returned status %d", resp.StatusCode)
+	}
+}
+
+// mkcolAll creates every collection along dir, ignoring ones that already exist.
+func (u *WebDAVUploader) mkcolAll(ctx context.Context, dir string) error {
+	current := ""
+	for _, segment := range strings.Split(dir, "/") {
+		if segment == "" {
+			continue
+		}
+		current = path.Join(current, segment)
+
+		req, err := http.NewRequestWithContext(ctx, "MKCOL", u.url(current)+"/", nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := u.do(req)
+		if err != nil {
+			return fmt.Errorf("failed to create WebDAV collection %s: %w", current, err)
+		}
+		resp.Body.Close()
+
+		// 405 Method Not Allowed means the collection already exists
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("failed to create WebDAV collection %s: status %d", current, resp.StatusCode)
+		}
+	}
+	return nil
+}
+
+// do sends a request with basic auth if credentials are configured.
+func (u *WebDAVUploader) do(req *http.Request) (*http.Response, error) {
+	if u.username != "" {
+		req.SetBasicAuth(u.username, u.password)
+	}
+	return u.httpClient.Do(req)
+}
+
+// url builds the escaped URL of a remote path.
+func (u *WebDAVUploader) url(remotePath string) string {
+	segments := strings.Split(remotePath, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return u.baseURL + "/" + strings.Join(segments, "/")
+}
+
+// Ensure WebDAVUploader implements Uploader.
+var _ interfaces.Uploader = (*WebDAVUploader)(nil)
@@ -9,8 +9,10 @@ package extractors
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"net/url"
+	"path"
 	"strings"
 	"sync"
 
@@ -25,15 +27,23 @@ type BaseExtractor struct {
 	client     *httpclient.Client
 	log        *logging.Logger
 	httpClient *http.Client
+	jar        http.CookieJar // Cookie jar shared by all extractors, may be nil
 	mu         sync.RWMutex
 }
 
 // NewBaseExtractor creates a new base extractor.
 func NewBaseExtractor(client *httpclient.Client, log *logging.Logger) *BaseExtractor {
+	var jar http.CookieJar
+	if client != nil {
+		jar = client.CookieJar()
+	}
+
 	return &BaseExtractor{
 		client: client,
 		log:    log,
+		jar:    jar,
 		httpClient: &http.Client{
+			Jar:     jar,
 			Timeout: 30e9, // 30 seconds
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				// Allow up to 10 redirects
@@ -51,7 +61,13 @@ func (b *BaseExtractor) Close() error {
 	return nil
 }
 
+// CookieJar returns the cookie jar shared by all extractors, or nil.
+func (b *BaseExtractor) CookieJar() http.CookieJar {
+	return b.jar
+}
+
 // DoRequest performs an HTTP request with the given options.
+// Cookies from the shared jar are sent, and cookies set by the response stored.
 func (b *BaseExtractor) DoRequest(ctx context.Context, method, urlStr string, headers map[string]string) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, method, urlStr, nil)
 	if err != nil {
@@ -62,11 +78,33 @@ func (b *BaseExtractor) DoRequest(ctx context.Context, method, urlStr string, he
 		req.Header.Set(key, value)
 	}
 
-	if req.Header.Get("User-Agent") == "" {
-		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	if b.jar != nil {
+		for _, cookie := range b.jar.Cookies(req.URL) {
+			req.AddCookie(cookie)
+		}
 	}
 
-	return b.client.Do(req)
+	resp, err := b.client.DoClass(req, httpclient.ClassPage)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.jar != nil {
+		if cookies := resp.Cookies(); len(cookies) > 0 {
+			// Store against the URL that set them, after any redirects
+			setURL := req.URL
+			if resp.Request != nil {
+				setURL = resp.Request.URL
+			}
+			b.jar.SetCookies(setURL, cookies)
+		}
+	}
+	return resp, nil
+}
+
+// ReadBody reads an extractor page, within the configured page size limit.
+func (b *BaseExtractor) ReadBody(r io.Reader) ([]byte, error) {
+	return b.client.ReadBody(r, httpclient.ClassPage)
 }
 
 // GetDomain extracts the domain from a URL.
@@ -78,18 +116,29 @@ func GetDomain(urlStr string) string {
 	return parsed.Host
 }
 
-// GenericExtractor is a fallback extractor that returns the URL as-is.
+// GenericExtractor is a fallback extractor. Media URLs are returned as-is;
+// other pages are scanned for an embedded stream, then loaded in the headless
+// browser if one is set.
 type GenericExtractor struct {
 	*BaseExtractor
+	embed   *EmbedExtractor
+	browser interfaces.Extractor // Headless browser extraction, may be nil
 }
 
 // NewGenericExtractor creates a new generic extractor.
 func NewGenericExtractor(client *httpclient.Client, log *logging.Logger) *GenericExtractor {
 	return &GenericExtractor{
 		BaseExtractor: NewBaseExtractor(client, log.WithComponent("generic-extractor")),
+		embed:         NewEmbedExtractor(client, log),
 	}
 }
 
+// SetBrowser sets the extractor tried on pages with no stream found by
+// scanning them.
+func (e *GenericExtractor) SetBrowser(browser interfaces.Extractor) {
+	e.browser = browser
+}
+
 // Name returns the extractor name.
 func (e *GenericExtractor) Name() string {
 	return "generic"
@@ -100,12 +149,27 @@ func (e *GenericExtractor) CanExtract(url string) bool {
 	return false
 }
 
-// Extract returns the URL as-is with basic headers.
+// Extract returns the URL as-is with basic headers, or the stream embedded in
+// the page if urlStr is not a media URL.
 func (e *GenericExtractor) Extract(ctx context.Context, urlStr string, opts interfaces.ExtractOptions) (*types.ExtractResult, error) {
+	if !isMediaURL(urlStr) {
+		result, err := e.embed.Extract(ctx, urlStr, opts)
+		if err == nil {
+			return result, nil
+		}
+		if e.browser != nil {
+			e.log.Debug("no embedded stream found, trying the browser", "url", urlStr, "error", err)
+			if result, err = e.browser.Extract(ctx, urlStr, opts); err == nil {
+				return result, nil
+			}
+		}
+		e.log.Debug("no embedded stream found, using URL as-is", "url", urlStr, "error", err)
+	}
+
 	domain := GetDomain(urlStr)
 
 	headers := map[string]string{
-		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		"User-Agent": e.client.UserAgent(urlStr),
 	}
 
 	if domain != "" {
@@ -133,4 +197,21 @@ func (e *GenericExtractor) Extract(ctx context.Context, urlStr string, opts inte
 	}, nil
 }
 
+// isMediaURL reports whether urlStr looks like a manifest or media file rather than a web page.
+func isMediaURL(urlStr string) bool {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return true
+	}
+	lower := strings.ToLower(u.Path)
+	if strings.Contains(lower, ".m3u8") || strings.Contains(lower, ".mpd") {
+		return true
+	}
+	switch path.Ext(lower) {
+	case "", ".html", ".htm", ".php", ".asp", ".aspx", ".jsp":
+		return false
+	}
+	return true
+}
+
 var _ interfaces.Extractor = (*GenericExtractor)(nil)
@@ -0,0 +1,87 @@
+package extractors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/browser"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+// browserRequestHeaders are the headers of a captured manifest request
+// passed on to the proxy; the others describe the browser's fetch.
+var browserRequestHeaders = []string{"User-Agent", "Referer", "Origin", "Cookie", "Authorization"}
+
+// BrowserExtractor loads pages in a headless browser and returns the first
+// manifest their player requests, for sites whose stream URLs or tokens are
+// computed in JavaScript. It is used by name (host=browser) and by the
+// generic fallback when scanning the page finds nothing.
+type BrowserExtractor struct {
+	browser *browser.Browser
+	timeout time.Duration
+	log     *logging.Logger
+}
+
+// NewBrowserExtractor creates a browser extractor waiting up to timeout for
+// a page's manifest request.
+func NewBrowserExtractor(b *browser.Browser, timeout time.Duration, log *logging.Logger) *BrowserExtractor {
+	return &BrowserExtractor{
+		browser: b,
+		timeout: timeout,
+		log:     log.WithComponent("browser-extractor"),
+	}
+}
+
+// Name returns the extractor name.
+func (e *BrowserExtractor) Name() string {
+	return "browser"
+}
+
+// CanExtract returns false: pages go through the browser only when asked
+// for or as the generic fallback's last resort.
+func (e *BrowserExtractor) CanExtract(url string) bool {
+	return false
+}
+
+// Extract loads the page and returns the manifest request it makes, with the
+// headers the browser sent.
+func (e *BrowserExtractor) Extract(ctx context.Context, urlStr string, opts interfaces.ExtractOptions) (*types.ExtractResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	e.log.Debug("loading page in browser", "url", urlStr)
+	req, err := e.browser.Capture(ctx, urlStr, opts.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("browser extraction of %s failed: %w", urlStr, err)
+	}
+
+	headers := make(map[string]string)
+	for name, value := range req.Headers {
+		for _, keep := range browserRequestHeaders {
+			if strings.EqualFold(name, keep) {
+				headers[keep] = value
+			}
+		}
+	}
+
+	endpoint := "hls_manifest_proxy"
+	if strings.Contains(strings.ToLower(req.URL), ".mpd") {
+		endpoint = "mpd_manifest_proxy"
+	}
+	return &types.ExtractResult{
+		DestinationURL:    req.URL,
+		RequestHeaders:    headers,
+		MediaflowEndpoint: endpoint,
+	}, nil
+}
+
+// Close closes the browser.
+func (e *BrowserExtractor) Close() error {
+	return e.browser.Close()
+}
+
+var _ interfaces.Extractor = (*BrowserExtractor)(nil)
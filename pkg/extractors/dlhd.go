@@ -13,6 +13,7 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"media-proxy-go/pkg/flaresolverr"
@@ -26,16 +27,37 @@ import (
 type DLHDExtractor struct {
 	*BaseExtractor
 	log         *logging.Logger
-	flareClient *flaresolverr.Client
+	flareClient flaresolverr.Solver           // Cloudflare challenge solver, may be nil
+	sessions    *dlhdSessions                 // Per channel, to skip the server lookup and auth calls
+	templates   atomic.Pointer[DLHDTemplates] // In use, from the configuration or the rules
+	configured  atomic.Pointer[DLHDTemplates] // From the configuration
+	rules       atomic.Pointer[dlhdRules]
 }
 
 // NewDLHDExtractor creates a new DLHD extractor.
-func NewDLHDExtractor(client *httpclient.Client, log *logging.Logger, flareClient *flaresolverr.Client) *DLHDExtractor {
-	return &DLHDExtractor{
+func NewDLHDExtractor(client *httpclient.Client, log *logging.Logger, flareClient flaresolverr.Solver) *DLHDExtractor {
+	e := &DLHDExtractor{
 		BaseExtractor: NewBaseExtractor(client, log),
 		log:           log.WithComponent("dlhd-extractor"),
 		flareClient:   flareClient,
+		sessions:      newDLHDSessions(),
 	}
+	e.templates.Store(&DefaultDLHDTemplates)
+	e.configured.Store(&DefaultDLHDTemplates)
+	e.rules.Store(&dlhdRules{})
+	return e
+}
+
+// SetTemplates replaces the CDN URL templates of the streams. Empty fields
+// keep the built-in templates. Templates from a rules bundle take precedence.
+func (e *DLHDExtractor) SetTemplates(t DLHDTemplates) error {
+	t, err := t.withDefaults(&DefaultDLHDTemplates)
+	if err != nil {
+		return err
+	}
+	e.configured.Store(&t)
+	e.templates.Store(&t)
+	return nil
 }
 
 // Name returns the extractor name.
@@ -51,7 +73,8 @@ func (e *DLHDExtractor) CanExtract(url string) bool {
 		strings.Contains(lower, "daddyhd")
 }
 
-// Extract extracts the stream URL from a DLHD URL.
+// Extract extracts the stream URL from a DLHD URL. The channel's session is
+// reused from the cache until shortly before it expires.
 func (e *DLHDExtractor) Extract(ctx context.Context, urlStr string, opts interfaces.ExtractOptions) (*types.ExtractResult, error) {
 	e.log.Debug("extracting DLHD stream", "url", urlStr)
 
@@ -63,12 +86,63 @@ func (e *DLHDExtractor) Extract(ctx context.Context, urlStr string, opts interfa
 
 	e.log.Debug("extracted channel ID", "id", channelID)
 
+	if !opts.ForceRefresh {
+		if session := e.sessions.get(channelID, func() { e.refreshSession(urlStr, channelID) }); session != nil {
+			e.log.Debug("using cached DLHD session", "id", channelID, "expires", session.expires)
+			return e.sessionResult(session)
+		}
+	}
+
+	session, err := e.extractSession(ctx, urlStr, channelID)
+	if err != nil {
+		return nil, err
+	}
+	e.sessions.put(channelID, session)
+	return e.sessionResult(session)
+}
+
+// refreshSession extracts the session of a channel again in the background,
+// replacing the cached one before it expires.
+func (e *DLHDExtractor) refreshSession(urlStr, channelID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), dlhdRefreshTimeout)
+	defer cancel()
+
+	session, err := e.extractSession(ctx, urlStr, channelID)
+	if err != nil {
+		e.log.Debug("DLHD session refresh failed", "id", channelID, "error", err)
+		e.sessions.refreshFailed(channelID)
+		return
+	}
+	e.sessions.put(channelID, session)
+	e.log.Debug("refreshed DLHD session", "id", channelID, "expires", session.expires)
+}
+
+// sessionResult builds the extraction result of a session.
+func (e *DLHDExtractor) sessionResult(session *dlhdSession) (*types.ExtractResult, error) {
+	result, err := e.buildStreamResult(session.channelKey, session.serverKey, session.token, session.playerURL)
+	if err != nil {
+		return nil, err
+	}
+	if session.userAgent != "" {
+		result.RequestHeaders["User-Agent"] = session.userAgent
+	}
+	result.ExpiresAt = session.expires.Unix()
+	return result, nil
+}
+
+// extractSession walks the DLHD pages of a channel to its stream session:
+// channel key, server key and session token.
+func (e *DLHDExtractor) extractSession(ctx context.Context, urlStr, channelID string) (*dlhdSession, error) {
 	// Determine base URL from the original URL
 	baseURL := e.getBaseURL(urlStr)
 
-	// Create HTTP client with cookie jar for session persistence
+	// Create HTTP client with cookie jar for session persistence, sharing
+	// cookies (e.g. cf_clearance) with earlier extractions when possible
 	// Use IPv4-only dialer to avoid IPv6 connectivity issues
-	jar, _ := cookiejar.New(nil)
+	jar := e.CookieJar()
+	if jar == nil {
+		jar, _ = cookiejar.New(nil)
+	}
 	client := &http.Client{
 		Transport: &http.Transport{
 			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
@@ -85,32 +159,48 @@ func (e *DLHDExtractor) Extract(ctx context.Context, urlStr string, opts interfa
 	}
 
 	// Try direct extraction first
-	result, err := e.tryExtractStream(ctx, client, urlStr, channelID, baseURL)
+	session, err := e.tryExtractStream(ctx, client, urlStr, channelID, baseURL)
 	if err == nil {
-		return result, nil
+		return session, nil
 	}
 
 	e.log.Debug("direct extraction failed", "error", err)
 
-	// If direct extraction failed and FlareSolverr is configured, try it as fallback
+	// The input domain may be down or parked; try the same page on the other mirrors
+	for _, mirror := range dlhdMirrors {
+		if mirror == baseURL || ctx.Err() != nil {
+			continue
+		}
+		mirrorURL := rebaseURL(urlStr, mirror)
+		e.log.Debug("retrying extraction on mirror", "mirror", mirror, "url", mirrorURL)
+
+		session, mirrorErr := e.tryExtractStream(ctx, client, mirrorURL, channelID, mirror)
+		if mirrorErr == nil {
+			e.log.Info("extracted stream from DLHD mirror", "mirror", mirror)
+			return session, nil
+		}
+		e.log.Debug("mirror extraction failed", "mirror", mirror, "error", mirrorErr)
+	}
+
+	// If direct extraction failed and a Cloudflare solver is configured, try it as fallback
 	// This handles Cloudflare 403 blocks
 	if e.flareClient != nil && e.flareClient.IsConfigured() {
-		e.log.Info("trying FlareSolverr as fallback for Cloudflare bypass")
-		result, flareErr := e.tryExtractWithFlareSolverr(ctx, client, urlStr, channelID, baseURL)
+		e.log.Info("trying Cloudflare solver as fallback", "solver", e.flareClient.Name())
+		session, flareErr := e.tryExtractWithFlareSolverr(ctx, client, urlStr, channelID, baseURL)
 		if flareErr != nil {
-			e.log.Warn("FlareSolverr extraction also failed", "error", flareErr)
+			e.log.Warn("Cloudflare solver extraction also failed", "solver", e.flareClient.Name(), "error", flareErr)
 			// Return the original error since it's more informative
 			return nil, err
 		}
-		return result, nil
+		return session, nil
 	}
 
 	return nil, err
 }
 
-// tryExtractStream tries different methods to extract the stream.
-func (e *DLHDExtractor) tryExtractStream(ctx context.Context, client *http.Client, originalURL, channelID, baseURL string) (*types.ExtractResult, error) {
-	userAgent := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+// tryExtractStream tries different methods to find the stream session.
+func (e *DLHDExtractor) tryExtractStream(ctx context.Context, client *http.Client, originalURL, channelID, baseURL string) (*dlhdSession, error) {
+	userAgent := e.client.UserAgent(originalURL)
 
 	// Helper function to make requests with the session client
 	doRequest := func(urlStr, referer string) (*http.Response, error) {
@@ -133,7 +223,7 @@ func (e *DLHDExtractor) tryExtractStream(ctx context.Context, client *http.Clien
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch watch page: %w", err)
 	}
-	body, _ := io.ReadAll(resp.Body)
+	body, _ := e.ReadBody(resp.Body)
 	resp.Body.Close()
 
 	watchContent := string(body)
@@ -144,6 +234,10 @@ func (e *DLHDExtractor) tryExtractStream(ctx context.Context, client *http.Clien
 	}
 	e.log.Debug("got watch page", "status", resp.StatusCode, "length", len(watchContent), "content_preview", debugContent)
 
+	if isParkedPage(watchContent) {
+		return nil, fmt.Errorf("watch page on %s is a parked domain", baseURL)
+	}
+
 	// Check for JavaScript/meta refresh redirect
 	redirectURL := e.findRedirectURL(watchContent)
 	if redirectURL != "" {
@@ -171,7 +265,7 @@ func (e *DLHDExtractor) tryExtractStream(ctx context.Context, client *http.Clien
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch stream page: %w", err)
 	}
-	body2, _ := io.ReadAll(resp2.Body)
+	body2, _ := e.ReadBody(resp2.Body)
 	resp2.Body.Close()
 
 	streamContent := string(body2)
@@ -196,7 +290,7 @@ func (e *DLHDExtractor) tryExtractStream(ctx context.Context, client *http.Clien
 		// Step 3: Fetch the nested iframe (player page)
 		resp3, err := doRequest(nestedIframe, iframeSrc)
 		if err == nil {
-			body3, _ := io.ReadAll(resp3.Body)
+			body3, _ := e.ReadBody(resp3.Body)
 			resp3.Body.Close()
 
 			playerContent := string(body3)
@@ -212,7 +306,7 @@ func (e *DLHDExtractor) tryExtractStream(ctx context.Context, client *http.Clien
 
 				// Call auth endpoint if available
 				if authURL != "" {
-					e.callAuthEndpointWithClient(ctx, client, authURL, nestedIframe)
+					e.callAuthEndpointWithUserAgent(ctx, client, authURL, nestedIframe, userAgent)
 				}
 
 				// Get server key
@@ -223,10 +317,10 @@ func (e *DLHDExtractor) tryExtractStream(ctx context.Context, client *http.Clien
 						serverLookupURL += channelID
 					}
 					e.log.Debug("fetching server key", "url", serverLookupURL)
-					serverKey, _ = e.fetchServerKeyWithClient(ctx, client, serverLookupURL, nestedIframe)
+					serverKey, _ = e.fetchServerKeyWithUserAgent(ctx, client, serverLookupURL, nestedIframe, userAgent)
 				}
 
-				return e.buildStreamResult(channelKey, serverKey, sessionToken, nestedIframe)
+				return &dlhdSession{channelKey: channelKey, serverKey: serverKey, token: sessionToken, playerURL: nestedIframe, userAgent: userAgent}, nil
 			}
 		}
 	}
@@ -240,7 +334,7 @@ func (e *DLHDExtractor) tryExtractStream(ctx context.Context, client *http.Clien
 		sessionToken := e.extractSessionToken(streamContent)
 
 		if authURL != "" {
-			e.callAuthEndpointWithClient(ctx, client, authURL, iframeSrc)
+			e.callAuthEndpointWithUserAgent(ctx, client, authURL, iframeSrc, userAgent)
 		}
 
 		serverKey := ""
@@ -250,17 +344,17 @@ func (e *DLHDExtractor) tryExtractStream(ctx context.Context, client *http.Clien
 				serverLookupURL += channelID
 			}
 			e.log.Debug("fetching server key", "url", serverLookupURL)
-			serverKey, _ = e.fetchServerKeyWithClient(ctx, client, serverLookupURL, iframeSrc)
+			serverKey, _ = e.fetchServerKeyWithUserAgent(ctx, client, serverLookupURL, iframeSrc, userAgent)
 		}
 
-		return e.buildStreamResult(channelKey, serverKey, sessionToken, iframeSrc)
+		return &dlhdSession{channelKey: channelKey, serverKey: serverKey, token: sessionToken, playerURL: iframeSrc, userAgent: userAgent}, nil
 	}
 
 	return nil, fmt.Errorf("could not extract stream URL from any page")
 }
 
-// tryExtractWithFlareSolverr uses FlareSolverr to bypass Cloudflare and extract the stream.
-func (e *DLHDExtractor) tryExtractWithFlareSolverr(ctx context.Context, client *http.Client, originalURL, channelID, baseURL string) (*types.ExtractResult, error) {
+// tryExtractWithFlareSolverr uses the Cloudflare solver (FlareSolverr or compatible) to find the stream session.
+func (e *DLHDExtractor) tryExtractWithFlareSolverr(ctx context.Context, client *http.Client, originalURL, channelID, baseURL string) (*dlhdSession, error) {
 	// Step 1: Fetch the watch page via FlareSolverr to get cookies
 	e.log.Debug("fetching watch page via FlareSolverr", "url", originalURL)
 	watchResp, err := e.flareClient.Get(ctx, originalURL, nil)
@@ -368,7 +462,7 @@ func (e *DLHDExtractor) tryExtractWithFlareSolverr(ctx context.Context, client *
 					serverKey, _ = e.fetchServerKeyWithUserAgent(ctx, client, serverLookupURL, nestedIframe, userAgent)
 				}
 
-				return e.buildStreamResult(channelKey, serverKey, sessionToken, nestedIframe)
+				return &dlhdSession{channelKey: channelKey, serverKey: serverKey, token: sessionToken, playerURL: nestedIframe, userAgent: userAgent}, nil
 			}
 		}
 	}
@@ -395,7 +489,7 @@ func (e *DLHDExtractor) tryExtractWithFlareSolverr(ctx context.Context, client *
 			serverKey, _ = e.fetchServerKeyWithUserAgent(ctx, client, serverLookupURL, iframeSrc, userAgent)
 		}
 
-		return e.buildStreamResult(channelKey, serverKey, sessionToken, iframeSrc)
+		return &dlhdSession{channelKey: channelKey, serverKey: serverKey, token: sessionToken, playerURL: iframeSrc, userAgent: userAgent}, nil
 	}
 
 	return nil, fmt.Errorf("could not extract stream URL via FlareSolverr")
@@ -412,7 +506,7 @@ func (e *DLHDExtractor) addCookiesToJar(jar http.CookieJar, baseURLStr string, c
 		return
 	}
 
-	httpCookies := e.flareClient.ToHTTPCookies(cookies)
+	httpCookies := flaresolverr.ToHTTPCookies(cookies)
 	jar.SetCookies(parsedURL, httpCookies)
 }
 
@@ -471,7 +565,7 @@ func (e *DLHDExtractor) fetchServerKeyWithUserAgent(ctx context.Context, client
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := e.ReadBody(resp.Body)
 	if err != nil {
 		return "", err
 	}
@@ -503,12 +597,12 @@ func (e *DLHDExtractor) fetchServerKeyWithUserAgent(ctx context.Context, client
 
 // findIframeSrc finds an iframe source in HTML content.
 func (e *DLHDExtractor) findIframeSrc(content string) string {
-	patterns := []string{
+	patterns := e.rules.Load().patternsFor(dlhdPatternIframe, []string{
 		`<iframe[^>]*\ssrc=["']([^"']+)["']`,
 		`<iframe[^>]*\ssrc=([^\s>]+)`,
 		`iframe\.src\s*=\s*["']([^"']+)["']`,
 		`embedUrl['":\s]+["']([^"']+)["']`,
-	}
+	})
 
 	for _, pattern := range patterns {
 		re := regexp.MustCompile(pattern)
@@ -578,7 +672,7 @@ func (e *DLHDExtractor) findRedirectURL(content string) string {
 // extractAuthParams extracts authentication parameters from page content.
 func (e *DLHDExtractor) extractAuthParams(content string) (channelKey, serverLookupURL, authURL string) {
 	// Extract CHANNEL_KEY - can be a string literal or a variable reference
-	keyPatterns := []string{
+	keyPatterns := e.rules.Load().patternsFor(dlhdPatternChannelKey, []string{
 		`const\s+CHANNEL_KEY\s*=\s*["']([^"']+)["']`,
 		`CHANNEL_KEY\s*[=:]\s*["']([^"']+)["']`,
 		`channel_key\s*[=:]\s*["']([^"']+)["']`,
@@ -586,7 +680,7 @@ func (e *DLHDExtractor) extractAuthParams(content string) (channelKey, serverLoo
 		// Match variable reference: window.CHANNEL_KEY=_7b4a394a541f91f9;
 		// The variable name itself (without underscore) is often the key
 		`(?:window\.)?CHANNEL_KEY\s*=\s*_([a-fA-F0-9]+);`,
-	}
+	})
 
 	for _, pattern := range keyPatterns {
 		re := regexp.MustCompile(pattern)
@@ -598,10 +692,10 @@ func (e *DLHDExtractor) extractAuthParams(content string) (channelKey, serverLoo
 	}
 
 	// Extract server lookup URL
-	serverPatterns := []string{
+	serverPatterns := e.rules.Load().patternsFor(dlhdPatternServerLookup, []string{
 		`fetchWithRetry\s*\(\s*["']([^"']+)["']`,
 		`fetch\s*\(\s*["']([^"']+server[^"']*)["']`,
-	}
+	})
 
 	for _, pattern := range serverPatterns {
 		re := regexp.MustCompile(pattern)
@@ -705,12 +799,7 @@ func (e *DLHDExtractor) extractSessionToken(content string) string {
 
 // buildStreamResult builds the final stream result using channel key and optional session token.
 func (e *DLHDExtractor) buildStreamResult(channelKey, serverKey, sessionToken, playerPageURL string) (*types.ExtractResult, error) {
-	var m3u8URL string
-	if serverKey == "" || serverKey == "top1" {
-		m3u8URL = fmt.Sprintf("https://top1.newkso.ru/top1/cdn/%s/mono.m3u8", channelKey)
-	} else {
-		m3u8URL = fmt.Sprintf("https://%snew.newkso.ru/%s/%s/mono.m3u8", serverKey, serverKey, channelKey)
-	}
+	m3u8URL := e.templates.Load().streamURL(channelKey, serverKey)
 
 	// Determine Referer - use player page URL if available, otherwise use epicplayplay.cfd
 	referer := "https://epicplayplay.cfd/"
@@ -725,11 +814,15 @@ func (e *DLHDExtractor) buildStreamResult(channelKey, serverKey, sessionToken, p
 	e.log.Debug("constructed stream URL from channel key", "url", m3u8URL, "has_token", sessionToken != "", "referer", referer)
 
 	headers := map[string]string{
-		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		"User-Agent": e.client.UserAgent(playerPageURL),
 		"Referer":    referer,
 		"Origin":     origin,
 	}
 
+	for name, value := range e.rules.Load().headers {
+		headers[name] = value
+	}
+
 	// Add Authorization header if we have a session token
 	if sessionToken != "" {
 		headers["Authorization"] = "Bearer " + sessionToken
@@ -743,74 +836,6 @@ func (e *DLHDExtractor) buildStreamResult(channelKey, serverKey, sessionToken, p
 	}, nil
 }
 
-// callAuthEndpointWithClient calls the authentication endpoint using the session client.
-func (e *DLHDExtractor) callAuthEndpointWithClient(ctx context.Context, client *http.Client, authURL, referer string) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, authURL, nil)
-	if err != nil {
-		e.log.Debug("failed to create auth request", "error", err)
-		return
-	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-	req.Header.Set("Referer", referer)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		e.log.Debug("auth endpoint call failed", "error", err)
-		return
-	}
-	defer resp.Body.Close()
-	io.Copy(io.Discard, resp.Body)
-}
-
-// fetchServerKeyWithClient fetches the server assignment using the session client.
-func (e *DLHDExtractor) fetchServerKeyWithClient(ctx context.Context, client *http.Client, serverURL, referer string) (string, error) {
-	if serverURL == "" {
-		return "", nil
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serverURL, nil)
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-	req.Header.Set("Referer", referer)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	serverKey := strings.TrimSpace(string(body))
-
-	// Handle JSON response
-	if strings.HasPrefix(serverKey, "{") {
-		var jsonResp struct {
-			Server string `json:"server"`
-			Error  string `json:"error"`
-		}
-		if err := json.Unmarshal(body, &jsonResp); err == nil {
-			// Check for error response
-			if jsonResp.Error != "" {
-				e.log.Debug("server lookup returned error", "error", jsonResp.Error)
-				return "", nil
-			}
-			if jsonResp.Server != "" {
-				return jsonResp.Server, nil
-			}
-		}
-		// If it's JSON but we couldn't extract a valid server, return empty
-		return "", nil
-	}
-
-	return serverKey, nil
-}
-
 // extractChannelID extracts the channel ID from various URL formats.
 func (e *DLHDExtractor) extractChannelID(urlStr string) string {
 	patterns := []struct {
@@ -833,7 +858,88 @@ func (e *DLHDExtractor) extractChannelID(urlStr string) string {
 	return ""
 }
 
+// DLHDTemplates are the newkso CDN URL shapes of DLHD streams, which change
+// when the site rotates domains. {server} is replaced by the looked up server
+// key and {channel} by the channel key.
+type DLHDTemplates struct {
+	Server  string `json:"server,omitempty"`  // Streams on a looked up server
+	Default string `json:"default,omitempty"` // Streams without a server key, or on top1
+}
+
+// DefaultDLHDTemplates are the built-in CDN URL templates.
+var DefaultDLHDTemplates = DLHDTemplates{
+	Server:  "https://{server}new.newkso.ru/{server}/{channel}/mono.m3u8",
+	Default: "https://top1.newkso.ru/top1/cdn/{channel}/mono.m3u8",
+}
+
+// withDefaults fills the empty fields of t from defaults and checks the
+// result.
+func (t DLHDTemplates) withDefaults(defaults *DLHDTemplates) (DLHDTemplates, error) {
+	if t.Server == "" {
+		t.Server = defaults.Server
+	}
+	if t.Default == "" {
+		t.Default = defaults.Default
+	}
+	for _, tmpl := range []string{t.Server, t.Default} {
+		if !strings.Contains(tmpl, "{channel}") {
+			return t, fmt.Errorf("DLHD URL template %q lacks {channel}", tmpl)
+		}
+	}
+	return t, nil
+}
+
+// streamURL returns the stream URL of a channel on a server.
+func (t *DLHDTemplates) streamURL(channelKey, serverKey string) string {
+	if serverKey == "" || serverKey == "top1" {
+		return strings.ReplaceAll(t.Default, "{channel}", channelKey)
+	}
+	return strings.NewReplacer("{server}", serverKey, "{channel}", channelKey).Replace(t.Server)
+}
+
 // getBaseURL extracts the base URL from the original URL.
+// dlhdMirrors are the known DLHD domains, tried in order when the input domain fails.
+var dlhdMirrors = []string{
+	"https://dlhd.dad",
+	"https://dlhd.link",
+	"https://dlhd.sx",
+	"https://daddylive.dad",
+	"https://daddylive.sx",
+	"https://daddylive.me",
+	"https://daddylivehd.sx",
+}
+
+// parkedMarkers are phrases found on parked or for-sale domain pages.
+var parkedMarkers = []string{
+	"this domain is for sale",
+	"domain is parked",
+	"buy this domain",
+	"sedoparking",
+	"parkingcrew",
+	"bodis.com",
+	"domain has expired",
+}
+
+// isParkedPage reports whether content looks like a domain parking page.
+func isParkedPage(content string) bool {
+	lower := strings.ToLower(content)
+	for _, marker := range parkedMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// rebaseURL replaces the scheme and host of urlStr with base, keeping path and query.
+func rebaseURL(urlStr, base string) string {
+	u, err := url.Parse(urlStr)
+	if err != nil || u.Host == "" {
+		return base + "/"
+	}
+	return base + u.RequestURI()
+}
+
 func (e *DLHDExtractor) getBaseURL(urlStr string) string {
 	domains := map[string]string{
 		"dlhd.link":    "https://dlhd.link",
@@ -0,0 +1,56 @@
+package extractors
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"media-proxy-go/pkg/types"
+)
+
+// Names of the DLHD patterns a rules bundle can add to, each with one
+// capture group.
+const (
+	dlhdPatternIframe       = "iframe"        // Player iframe source
+	dlhdPatternChannelKey   = "channel_key"   // Channel key in the player page
+	dlhdPatternServerLookup = "server_lookup" // Server lookup URL in the player page
+)
+
+// dlhdRules are the DLHD part of a rules bundle.
+type dlhdRules struct {
+	patterns map[string][]string // Tried before the built-in ones
+	headers  map[string]string   // Set on the stream requests
+}
+
+// patternsFor returns the patterns of name: those of the rules, then builtin.
+func (r *dlhdRules) patternsFor(name string, builtin []string) []string {
+	return slices.Concat(r.patterns[name], builtin)
+}
+
+// ApplyRules replaces the patterns, URL templates ("server", "default") and
+// stream headers from a rules bundle. Templates the rules leave empty are the
+// configured ones.
+func (e *DLHDExtractor) ApplyRules(rules types.ExtractorRules) error {
+	for name, patterns := range rules.Patterns {
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid %s pattern: %w", name, err)
+			}
+			if re.NumSubexp() < 1 {
+				return fmt.Errorf("%s pattern %q has no capture group", name, pattern)
+			}
+		}
+	}
+	templates, err := DLHDTemplates{
+		Server:  rules.Templates["server"],
+		Default: rules.Templates["default"],
+	}.withDefaults(e.configured.Load())
+	if err != nil {
+		return err
+	}
+
+	e.templates.Store(&templates)
+	e.rules.Store(&dlhdRules{patterns: rules.Patterns, headers: rules.Headers})
+	return nil
+}
@@ -0,0 +1,116 @@
+package extractors
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// dlhdSessionTTL is how long a session without a readable JWT expiry is
+	// reused, and the longest any session is.
+	dlhdSessionTTL = 10 * time.Minute
+
+	// dlhdRefreshBefore is how long before expiry a session is refreshed in
+	// the background, at most a fifth of its lifetime.
+	dlhdRefreshBefore = time.Minute
+
+	// dlhdRefreshTimeout bounds a background refresh.
+	dlhdRefreshTimeout = time.Minute
+)
+
+// dlhdSession is what the server lookup and auth endpoints give a channel's
+// stream: the server key and the session token it is played with.
+type dlhdSession struct {
+	channelKey string
+	serverKey  string
+	token      string // JWT sent as Authorization: Bearer, may be empty
+	playerURL  string // Page the stream is played from, for Referer/Origin
+	userAgent  string // User-Agent the session was opened with, may be empty
+
+	expires    time.Time
+	refreshAt  time.Time
+	refreshing bool
+}
+
+// dlhdSessions caches the sessions of channels by channel ID.
+type dlhdSessions struct {
+	mu   sync.Mutex
+	byID map[string]*dlhdSession
+	now  func() time.Time
+}
+
+func newDLHDSessions() *dlhdSessions {
+	return &dlhdSessions{byID: make(map[string]*dlhdSession), now: time.Now}
+}
+
+// get returns the unexpired session of a channel, or nil. Once the session is
+// due for a refresh, refresh is started in the background, once.
+func (c *dlhdSessions) get(channelID string, refresh func()) *dlhdSession {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	session, ok := c.byID[channelID]
+	now := c.now()
+	if !ok || !now.Before(session.expires) {
+		return nil
+	}
+	if !now.Before(session.refreshAt) && !session.refreshing {
+		session.refreshing = true
+		go refresh()
+	}
+	copied := *session
+	return &copied
+}
+
+// put caches a new session of a channel, expiring with its token.
+func (c *dlhdSessions) put(channelID string, session *dlhdSession) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	session.expires = now.Add(dlhdSessionTTL)
+	if exp, ok := jwtExpiry(session.token); ok && exp.Before(session.expires) {
+		session.expires = exp
+	}
+	session.refreshAt = session.expires.Add(-min(dlhdRefreshBefore, session.expires.Sub(now)/5))
+	session.refreshing = false
+
+	for id, old := range c.byID {
+		if !now.Before(old.expires) {
+			delete(c.byID, id)
+		}
+	}
+	c.byID[channelID] = session
+}
+
+// refreshFailed lets a failed background refresh be tried again by the next
+// extraction of the channel.
+func (c *dlhdSessions) refreshFailed(channelID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if session, ok := c.byID[channelID]; ok {
+		session.refreshing = false
+	}
+}
+
+// jwtExpiry returns the exp claim of a JWT, without verifying it.
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
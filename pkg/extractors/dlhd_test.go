@@ -1,9 +1,16 @@
 package extractors
 
 import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"media-proxy-go/pkg/interfaces"
 	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
 )
 
 func TestDLHDExtractor_CanExtract(t *testing.T) {
@@ -94,6 +101,44 @@ func TestDLHDExtractor_getBaseURL(t *testing.T) {
 	}
 }
 
+func TestRebaseURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		base     string
+		expected string
+	}{
+		{"https://dlhd.link/watch.php?id=577", "https://dlhd.dad", "https://dlhd.dad/watch.php?id=577"},
+		{"https://daddylive.me/stream/stream-123.php", "https://dlhd.sx", "https://dlhd.sx/stream/stream-123.php"},
+		{"not a url", "https://dlhd.sx", "https://dlhd.sx/"},
+	}
+
+	for _, tt := range tests {
+		if result := rebaseURL(tt.url, tt.base); result != tt.expected {
+			t.Errorf("rebaseURL(%q, %q) = %q, want %q", tt.url, tt.base, result, tt.expected)
+		}
+	}
+}
+
+func TestIsParkedPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected bool
+	}{
+		{"for sale", `<h1>This domain is for sale!</h1>`, true},
+		{"parking service", `<script src="https://www.parkingcrew.net/js.js"></script>`, true},
+		{"watch page", `<iframe src="/stream/stream-577.php"></iframe>`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := isParkedPage(tt.content); result != tt.expected {
+				t.Errorf("isParkedPage() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestDLHDExtractor_findIframeSrc(t *testing.T) {
 	log := logging.New("error", false, nil)
 	e := NewDLHDExtractor(nil, log, nil)
@@ -330,3 +375,165 @@ func TestDLHDExtractor_buildStreamResult(t *testing.T) {
 		}
 	})
 }
+
+func TestJWTExpiry(t *testing.T) {
+	token := "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"x","exp":1700000000}`)) + ".sig"
+	if exp, ok := jwtExpiry(token); !ok || exp.Unix() != 1700000000 {
+		t.Errorf("jwtExpiry() = %v, %v", exp, ok)
+	}
+	for _, bad := range []string{"", "not-a-jwt", "a.b.c", "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"x"}`)) + ".sig"} {
+		if _, ok := jwtExpiry(bad); ok {
+			t.Errorf("jwtExpiry(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestDLHDSessions(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := newDLHDSessions()
+	c.now = func() time.Time { return now }
+	var refreshes atomic.Int32
+	refresh := func() { refreshes.Add(1) }
+
+	if c.get("51", refresh) != nil {
+		t.Fatal("get() of an unknown channel returned a session")
+	}
+
+	// The token expires in 5 minutes: refreshed in its last minute
+	token := "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, now.Add(5*time.Minute).Unix()))) + ".sig"
+	c.put("51", &dlhdSession{channelKey: "premium51", serverKey: "wind", token: token})
+
+	now = now.Add(3 * time.Minute)
+	if s := c.get("51", refresh); s == nil || s.serverKey != "wind" {
+		t.Fatalf("get() = %+v, want the cached session", s)
+	}
+	now = now.Add(90 * time.Second)
+	c.get("51", refresh)
+	c.get("51", refresh)
+	time.Sleep(10 * time.Millisecond)
+	if n := refreshes.Load(); n != 1 {
+		t.Errorf("%d refreshes, want 1 once the session is due", n)
+	}
+	c.refreshFailed("51")
+	c.get("51", refresh)
+	time.Sleep(10 * time.Millisecond)
+	if n := refreshes.Load(); n != 2 {
+		t.Errorf("%d refreshes, want another after a failed one", n)
+	}
+
+	now = now.Add(time.Minute)
+	if c.get("51", refresh) != nil {
+		t.Error("get() returned an expired session")
+	}
+
+	// Without a token the session lasts dlhdSessionTTL
+	c.put("52", &dlhdSession{channelKey: "premium52"})
+	if s := c.get("52", refresh); s == nil || !s.expires.Equal(now.Add(dlhdSessionTTL)) {
+		t.Errorf("get() = %+v, want a session expiring after %s", s, dlhdSessionTTL)
+	}
+}
+
+func TestDLHDExtractor_Extract_CachedSession(t *testing.T) {
+	log := logging.New("error", false, nil)
+	e := NewDLHDExtractor(nil, log, nil)
+	e.sessions.put("51", &dlhdSession{channelKey: "premium51", serverKey: "wind", playerURL: "https://player.example/embed/51"})
+
+	// Served from the cache, without fetching any page
+	result, err := e.Extract(context.Background(), "https://dlhd.dad/watch.php?id=51", interfaces.ExtractOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.DestinationURL != "https://windnew.newkso.ru/wind/premium51/mono.m3u8" || result.RequestHeaders["Referer"] != "https://player.example/" {
+		t.Errorf("result = %+v", result)
+	}
+	if result.ExpiresAt == 0 {
+		t.Error("ExpiresAt not set from the session")
+	}
+}
+
+func TestDLHDExtractor_SetTemplates(t *testing.T) {
+	log := logging.New("error", false, nil)
+	e := NewDLHDExtractor(nil, log, nil)
+
+	if err := e.SetTemplates(DLHDTemplates{Server: "https://{server}.cdn.example/{channel}/index.m3u8"}); err != nil {
+		t.Fatal(err)
+	}
+	result, _ := e.buildStreamResult("premium51", "wind", "", "")
+	if result.DestinationURL != "https://wind.cdn.example/premium51/index.m3u8" {
+		t.Errorf("server stream = %q", result.DestinationURL)
+	}
+	result, _ = e.buildStreamResult("premium51", "", "", "")
+	if result.DestinationURL != "https://top1.newkso.ru/top1/cdn/premium51/mono.m3u8" {
+		t.Errorf("default stream = %q, want the built-in template", result.DestinationURL)
+	}
+
+	if err := e.SetTemplates(DLHDTemplates{Default: "https://cdn.example/mono.m3u8"}); err == nil {
+		t.Error("SetTemplates() accepted a template without {channel}")
+	}
+	result, _ = e.buildStreamResult("premium51", "wind", "", "")
+	if result.DestinationURL != "https://wind.cdn.example/premium51/index.m3u8" {
+		t.Errorf("stream = %q after a rejected template, want the previous one", result.DestinationURL)
+	}
+}
+
+func TestDLHDExtractor_ApplyRules(t *testing.T) {
+	log := logging.New("error", false, nil)
+	e := NewDLHDExtractor(nil, log, nil)
+	if err := e.SetTemplates(DLHDTemplates{Server: "https://{server}.cdn.example/{channel}/index.m3u8"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := e.ApplyRules(types.ExtractorRules{
+		Patterns: map[string][]string{
+			"channel_key": {`STREAM_ID\s*=\s*"([^"]+)"`},
+			"iframe":      {`data-player="([^"]+)"`},
+		},
+		Templates: map[string]string{"default": "https://edge.example/{channel}.m3u8"},
+		Headers:   map[string]string{"Referer": "https://rules.example/", "X-Rule": "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key, _, _ := e.extractAuthParams(`STREAM_ID = "premium7"; const CHANNEL_KEY = "other"`); key != "premium7" {
+		t.Errorf("channel key = %q, want the rules pattern's match", key)
+	}
+	if src := e.findIframeSrc(`<div data-player="/embed/7"></div>`); src != "/embed/7" {
+		t.Errorf("iframe = %q, want the rules pattern's match", src)
+	}
+	if key, _, _ := e.extractAuthParams(`const CHANNEL_KEY = "premium51";`); key != "premium51" {
+		t.Errorf("channel key = %q, want the built-in pattern's match", key)
+	}
+
+	result, _ := e.buildStreamResult("premium51", "", "", "")
+	if result.DestinationURL != "https://edge.example/premium51.m3u8" {
+		t.Errorf("default stream = %q, want the rules template", result.DestinationURL)
+	}
+	if result.RequestHeaders["Referer"] != "https://rules.example/" || result.RequestHeaders["X-Rule"] != "1" {
+		t.Errorf("headers = %v, want the rules headers", result.RequestHeaders)
+	}
+	result, _ = e.buildStreamResult("premium51", "wind", "", "")
+	if result.DestinationURL != "https://wind.cdn.example/premium51/index.m3u8" {
+		t.Errorf("server stream = %q, want the configured template", result.DestinationURL)
+	}
+
+	// Invalid rules leave the current ones in place
+	if err := e.ApplyRules(types.ExtractorRules{Patterns: map[string][]string{"iframe": {`src="[^"]+"`}}}); err == nil {
+		t.Error("ApplyRules() accepted a pattern without a capture group")
+	}
+	if err := e.ApplyRules(types.ExtractorRules{Patterns: map[string][]string{"iframe": {`(`}}}); err == nil {
+		t.Error("ApplyRules() accepted an invalid pattern")
+	}
+	if src := e.findIframeSrc(`<div data-player="/embed/7"></div>`); src != "/embed/7" {
+		t.Errorf("iframe = %q after rejected rules, want the previous rules' match", src)
+	}
+
+	// Empty rules restore the built-in patterns and the configured templates
+	if err := e.ApplyRules(types.ExtractorRules{}); err != nil {
+		t.Fatal(err)
+	}
+	result, _ = e.buildStreamResult("premium51", "", "", "")
+	if result.DestinationURL != "https://top1.newkso.ru/top1/cdn/premium51/mono.m3u8" || result.RequestHeaders["X-Rule"] != "" {
+		t.Errorf("stream = %q, %v after empty rules", result.DestinationURL, result.RequestHeaders)
+	}
+}
@@ -0,0 +1,294 @@
+package extractors
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+const (
+	embedMaxPageSize = 5 << 20 // Pages larger than this are truncated before scanning
+	embedMaxDepth    = 2       // How many nested iframes to follow
+)
+
+var (
+	// embedAbsoluteRe matches absolute or protocol-relative manifest URLs.
+	embedAbsoluteRe = regexp.MustCompile(`(?:https?:)?//[^\s"'<>\\(){}]+?\.(?:m3u8|mpd)(?:\?[^\s"'<>\\(){}]*)?`)
+	// embedQuotedRe matches quoted (possibly relative) manifest URLs.
+	embedQuotedRe = regexp.MustCompile(`["']([^"'\s<>]+?\.(?:m3u8|mpd)(?:\?[^"'\s<>]*)?)["']`)
+	// embedPackedRe matches P.A.C.K.E.R. packed scripts.
+	embedPackedRe = regexp.MustCompile(`eval\(function\(p,a,c,k,e,[dr]\).*?\.split\('\|'\)[^)]*\)\)`)
+	// embedAtobRe matches atob("...") calls.
+	embedAtobRe = regexp.MustCompile(`atob\(\s*["']([A-Za-z0-9+/=_-]+)["']\s*\)`)
+	// embedBase64Re matches standalone base64 string literals long enough to hold a URL.
+	embedBase64Re = regexp.MustCompile(`["']([A-Za-z0-9+/]{24,}={0,2})["']`)
+	// embedIframeRe matches iframe sources.
+	embedIframeRe = regexp.MustCompile(`(?i)<iframe[^>]+src\s*=\s*["']([^"']+)["']`)
+	// embedPackerParamsRe extracts the payload and keywords of a packed script.
+	embedPackerParamsRe = regexp.MustCompile(`\}\('(.+)',(\d+),(\d+),'([^']+)'\.split`)
+)
+
+// EmbedExtractor is a best-effort extractor for arbitrary embed pages. It scans
+// the page (unpacking packed JS and decoding base64 blobs) for HLS/DASH
+// manifest URLs, follows iframes, and returns the most likely candidate.
+type EmbedExtractor struct {
+	*BaseExtractor
+	log *logging.Logger
+}
+
+// NewEmbedExtractor creates a new embed page extractor.
+func NewEmbedExtractor(client *httpclient.Client, log *logging.Logger) *EmbedExtractor {
+	return &EmbedExtractor{
+		BaseExtractor: NewBaseExtractor(client, log),
+		log:           log.WithComponent("embed-extractor"),
+	}
+}
+
+// Name returns the extractor name.
+func (e *EmbedExtractor) Name() string {
+	return "embed"
+}
+
+// CanExtract returns false: the embed extractor is only used as a catch-all
+// by the generic fallback.
+func (e *EmbedExtractor) CanExtract(url string) bool {
+	return false
+}
+
+// Extract fetches the page and returns the best manifest URL found on it.
+func (e *EmbedExtractor) Extract(ctx context.Context, urlStr string, opts interfaces.ExtractOptions) (*types.ExtractResult, error) {
+	e.log.Debug("scanning embed page", "url", urlStr)
+
+	pageURL := urlStr
+	referer := opts.Headers["Referer"]
+
+	for depth := 0; depth <= embedMaxDepth; depth++ {
+		page, err := e.fetchPage(ctx, pageURL, referer, opts.Headers)
+		if err != nil {
+			return nil, err
+		}
+
+		base, _ := url.Parse(pageURL)
+		if candidate := bestCandidate(findManifestURLs(page, base)); candidate != "" {
+			e.log.Debug("found stream on embed page", "page", pageURL, "stream", candidate)
+			return embedResult(candidate, pageURL, e.client.UserAgent(pageURL), opts.Headers), nil
+		}
+
+		// Players are usually embedded one or two iframes deep
+		match := embedIframeRe.FindStringSubmatch(page)
+		if match == nil {
+			break
+		}
+		next := resolveEmbedURL(match[1], base)
+		if next == "" {
+			break
+		}
+		referer, pageURL = pageURL, next
+	}
+
+	return nil, fmt.Errorf("no stream URL found on %s", urlStr)
+}
+
+// fetchPage downloads a page, sending referer if set.
+func (e *EmbedExtractor) fetchPage(ctx context.Context, pageURL, referer string, extra map[string]string) (string, error) {
+	headers := make(map[string]string, len(extra)+2)
+	for k, v := range extra {
+		headers[k] = v
+	}
+	headers["Accept"] = "text/html,application/xhtml+xml,*/*;q=0.8"
+	if referer != "" {
+		headers["Referer"] = referer
+	}
+
+	resp, err := e.DoRequest(ctx, http.MethodGet, pageURL, headers)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("page returned status %d", resp.StatusCode)
+	}
+
+	// Don't download media served from extensionless URLs
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "html") && !strings.HasPrefix(ct, "text/") && !strings.Contains(ct, "javascript") {
+		return "", fmt.Errorf("not a web page: %s", ct)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, embedMaxPageSize))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	return string(body), nil
+}
+
+// embedResult builds the extract result for a stream found on pageURL,
+// played with the User-Agent the page was loaded with.
+func embedResult(streamURL, pageURL, userAgent string, extra map[string]string) *types.ExtractResult {
+	headers := map[string]string{
+		"User-Agent": userAgent,
+	}
+	for k, v := range extra {
+		headers[k] = v
+	}
+	// CDNs check the embedding page, not the page the user opened
+	if u, err := url.Parse(pageURL); err == nil && u.Host != "" {
+		headers["Referer"] = u.Scheme + "://" + u.Host + "/"
+		headers["Origin"] = u.Scheme + "://" + u.Host
+	}
+
+	endpoint := "hls_manifest_proxy"
+	if strings.Contains(strings.ToLower(streamURL), ".mpd") {
+		endpoint = "mpd_manifest_proxy"
+	}
+
+	return &types.ExtractResult{
+		DestinationURL:    streamURL,
+		RequestHeaders:    headers,
+		MediaflowEndpoint: endpoint,
+	}
+}
+
+// findManifestURLs returns the manifest URLs found in a page, including those
+// hidden in packed scripts and base64 blobs, resolved against base.
+func findManifestURLs(page string, base *url.URL) []string {
+	sources := []string{page}
+	for _, packed := range embedPackedRe.FindAllString(page, -1) {
+		if unpacked, err := unpackPacker(packed); err == nil {
+			sources = append(sources, unpacked)
+		}
+	}
+	for _, re := range []*regexp.Regexp{embedAtobRe, embedBase64Re} {
+		for _, match := range re.FindAllStringSubmatch(page, -1) {
+			if decoded := decodeBase64String(match[1]); decoded != "" {
+				sources = append(sources, decoded)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	var result []string
+	add := func(raw string) {
+		if resolved := resolveEmbedURL(raw, base); resolved != "" && !seen[resolved] {
+			seen[resolved] = true
+			result = append(result, resolved)
+		}
+	}
+
+	for _, src := range sources {
+		src = unescapeJS(src)
+		for _, match := range embedAbsoluteRe.FindAllString(src, -1) {
+			add(match)
+		}
+		for _, match := range embedQuotedRe.FindAllStringSubmatch(src, -1) {
+			add(match[1])
+		}
+	}
+	return result
+}
+
+// bestCandidate picks the most likely main stream: master playlists first,
+// then HLS over DASH, then the first found.
+func bestCandidate(candidates []string) string {
+	best, bestScore := "", -1
+	for _, c := range candidates {
+		lower := strings.ToLower(c)
+		score := 0
+		if strings.Contains(lower, ".m3u8") {
+			score += 2
+		}
+		for _, hint := range []string{"master", "playlist", "index", "manifest"} {
+			if strings.Contains(path.Base(lower), hint) {
+				score += 3
+				break
+			}
+		}
+		if strings.Contains(lower, "/ads/") || strings.Contains(lower, "preroll") {
+			score -= 10
+		}
+		if score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+	return best
+}
+
+// resolveEmbedURL makes raw absolute against base, returning "" for non-HTTP URLs.
+func resolveEmbedURL(raw string, base *url.URL) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
+
+// unescapeJS undoes the escaping commonly applied to URLs in JS strings.
+func unescapeJS(s string) string {
+	return strings.NewReplacer(`\/`, `/`, `\u002F`, `/`, `\u002f`, `/`, `\u0026`, `&`, `&amp;`, `&`).Replace(s)
+}
+
+// decodeBase64String decodes a base64 blob, returning "" unless it looks like it holds a URL.
+func decodeBase64String(s string) string {
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if decoded, err := enc.DecodeString(s); err == nil {
+			if text := string(decoded); strings.Contains(text, "//") {
+				return text
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// unpackPacker unpacks P.A.C.K.E.R. packed JavaScript.
+func unpackPacker(packed string) (string, error) {
+	// Extract parameters from eval(function(p,a,c,k,e,d){...}('payload',a,c,'keywords'.split('|'),e,d))
+	match := embedPackerParamsRe.FindStringSubmatch(packed)
+	if len(match) < 5 {
+		return "", fmt.Errorf("failed to extract packer params")
+	}
+
+	payload := match[1]
+	keywords := strings.Split(match[4], "|")
+
+	// Simple unpacker - replace \bword\b with keyword
+	result := payload
+	for i := len(keywords) - 1; i >= 0; i-- {
+		if keywords[i] != "" {
+			pattern := fmt.Sprintf(`\b%s\b`, encodeBase(i, 36))
+			re := regexp.MustCompile(pattern)
+			result = re.ReplaceAllString(result, keywords[i])
+		}
+	}
+
+	return result, nil
+}
+
+// encodeBase encodes a number in the given base (like JavaScript's toString(base)).
+func encodeBase(n, base int) string {
+	const chars = "0123456789abcdefghijklmnopqrstuvwxyz"
+	if n < base {
+		return string(chars[n])
+	}
+	return encodeBase(n/base, base) + string(chars[n%base])
+}
+
+var _ interfaces.Extractor = (*EmbedExtractor)(nil)
@@ -0,0 +1,145 @@
+package extractors
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+func TestFindManifestURLs(t *testing.T) {
+	base, _ := url.Parse("https://player.example.com/embed/42")
+	encoded := base64.StdEncoding.EncodeToString([]byte("https://cdn.example.com/b64/master.m3u8?token=1"))
+
+	tests := []struct {
+		name     string
+		page     string
+		expected string
+	}{
+		{"absolute URL", `<source src="https://cdn.example.com/live/index.m3u8">`, "https://cdn.example.com/live/index.m3u8"},
+		{"escaped JSON", `{"file":"https:\/\/cdn.example.com\/live\/stream.mpd"}`, "https://cdn.example.com/live/stream.mpd"},
+		{"relative URL", `player.setup({file: "/hls/ch1.m3u8"})`, "https://player.example.com/hls/ch1.m3u8"},
+		{"protocol relative", `var src = '//cdn.example.com/a.m3u8';`, "https://cdn.example.com/a.m3u8"},
+		{"atob blob", `var src = atob("` + encoded + `");`, "https://cdn.example.com/b64/master.m3u8?token=1"},
+		{"packed script", `eval(function(p,a,c,k,e,d){return p}('0 1="2://3.4/5.6"',7,7,'var|src|https|cdn|example|stream|m3u8'.split('|'),0,{}))`, "https://cdn.example/stream.m3u8"},
+		{"nothing", `<html><body>No stream</body></html>`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bestCandidate(findManifestURLs(tt.page, base)); got != tt.expected {
+				t.Errorf("bestCandidate() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBestCandidate(t *testing.T) {
+	candidates := []string{
+		"https://ads.example.com/ads/preroll.m3u8",
+		"https://cdn.example.com/live/720p.m3u8",
+		"https://cdn.example.com/live/master.m3u8",
+		"https://cdn.example.com/live/manifest.mpd",
+	}
+	if got := bestCandidate(candidates); got != "https://cdn.example.com/live/master.m3u8" {
+		t.Errorf("bestCandidate() = %q", got)
+	}
+}
+
+func TestGenericExtractor_EmbedPage(t *testing.T) {
+	log := logging.New("error", false, nil)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/watch/1":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<iframe src="/embed/1"></iframe>`))
+		case "/embed/1":
+			if r.Header.Get("Referer") != server.URL+"/watch/1" {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<script>var hls = "/streams/1/index.m3u8";</script>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	e := NewGenericExtractor(httpclient.New(&config.Config{}, log), log)
+
+	result, err := e.Extract(context.Background(), server.URL+"/watch/1", interfaces.ExtractOptions{})
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if result.DestinationURL != server.URL+"/streams/1/index.m3u8" {
+		t.Errorf("DestinationURL = %q", result.DestinationURL)
+	}
+	if result.RequestHeaders["Referer"] != server.URL+"/" {
+		t.Errorf("Referer = %q", result.RequestHeaders["Referer"])
+	}
+
+	// Media URLs and pages without a stream are returned as-is
+	for _, u := range []string{server.URL + "/live.m3u8", server.URL + "/missing"} {
+		result, err := e.Extract(context.Background(), u, interfaces.ExtractOptions{})
+		if err != nil {
+			t.Fatalf("Extract(%q) error = %v", u, err)
+		}
+		if result.DestinationURL != u {
+			t.Errorf("Extract(%q) DestinationURL = %q", u, result.DestinationURL)
+		}
+	}
+}
+
+// pageExtractor returns a fixed stream for the pages it is asked about.
+type pageExtractor struct {
+	pages []string
+}
+
+func (e *pageExtractor) Name() string               { return "browser" }
+func (e *pageExtractor) CanExtract(url string) bool { return false }
+func (e *pageExtractor) Close() error               { return nil }
+
+func (e *pageExtractor) Extract(ctx context.Context, url string, opts interfaces.ExtractOptions) (*types.ExtractResult, error) {
+	e.pages = append(e.pages, url)
+	return &types.ExtractResult{DestinationURL: "https://cdn.example/live.m3u8", MediaflowEndpoint: "hls_manifest_proxy"}, nil
+}
+
+func TestGenericExtractor_Browser(t *testing.T) {
+	log := logging.New("error", false, nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<script src="/player.js"></script>`))
+	}))
+	defer server.Close()
+
+	e := NewGenericExtractor(httpclient.New(&config.Config{}, log), log)
+	browser := &pageExtractor{}
+	e.SetBrowser(browser)
+
+	result, err := e.Extract(context.Background(), server.URL+"/watch/1", interfaces.ExtractOptions{})
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if result.DestinationURL != "https://cdn.example/live.m3u8" {
+		t.Errorf("DestinationURL = %q, want the browser's capture", result.DestinationURL)
+	}
+
+	// Media URLs don't need the browser
+	if _, err := e.Extract(context.Background(), server.URL+"/live.m3u8", interfaces.ExtractOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(browser.pages) != 1 || browser.pages[0] != server.URL+"/watch/1" {
+		t.Errorf("browser loaded %v, want only the page without an embedded stream", browser.pages)
+	}
+}
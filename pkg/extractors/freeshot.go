@@ -4,10 +4,10 @@ package extractors
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"media-proxy-go/pkg/httpclient"
 	"media-proxy-go/pkg/interfaces"
@@ -18,6 +18,10 @@ import (
 // Compile-time check that httpclient is used (via BaseExtractor)
 var _ *httpclient.Client
 
+// freeshotTokenTTL is how long a lovecdn token stays valid; streams are
+// re-extracted shortly before it runs out.
+const freeshotTokenTTL = 2 * time.Minute
+
 // FreeshotExtractor extracts stream URLs from popcdn.day/freeshot.
 type FreeshotExtractor struct {
 	*BaseExtractor
@@ -60,8 +64,9 @@ func (e *FreeshotExtractor) Extract(ctx context.Context, urlStr string, opts int
 	// Fetch the player page to get the token
 	playerURL := fmt.Sprintf("https://popcdn.day/player/%s", channelCode)
 
+	userAgent := e.client.UserAgent(playerURL)
 	headers := map[string]string{
-		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/135.0.0.0 Safari/537.36",
+		"User-Agent": userAgent,
 		"Referer":    "https://popcdn.day/",
 	}
 
@@ -75,7 +80,7 @@ func (e *FreeshotExtractor) Extract(ctx context.Context, urlStr string, opts int
 		return nil, fmt.Errorf("player page returned status %d", resp.StatusCode)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := e.ReadBody(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read player page: %w", err)
 	}
@@ -96,11 +101,12 @@ func (e *FreeshotExtractor) Extract(ctx context.Context, urlStr string, opts int
 	return &types.ExtractResult{
 		DestinationURL: m3u8URL,
 		RequestHeaders: map[string]string{
-			"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/135.0.0.0 Safari/537.36",
+			"User-Agent": userAgent,
 			"Referer":    "https://popcdn.day/",
 			"Origin":     "https://popcdn.day",
 		},
 		MediaflowEndpoint: "hls_proxy",
+		ExpiresAt:         time.Now().Add(freeshotTokenTTL).Unix(),
 	}, nil
 }
 
@@ -3,7 +3,6 @@ package extractors
 import (
 	"context"
 	"fmt"
-	"io"
 	"regexp"
 	"strings"
 
@@ -47,7 +46,7 @@ func (e *MixdropExtractor) Extract(ctx context.Context, urlStr string, opts inte
 	urlStr = e.normalizeURL(urlStr)
 
 	headers := map[string]string{
-		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		"User-Agent": e.client.UserAgent(urlStr),
 		"Referer":    "https://mixdrop.co/",
 	}
 
@@ -57,7 +56,7 @@ func (e *MixdropExtractor) Extract(ctx context.Context, urlStr string, opts inte
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := e.ReadBody(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
@@ -104,7 +103,7 @@ func (e *MixdropExtractor) extractStreamURL(html string) (string, error) {
 	packed := packedRe.FindString(html)
 
 	if packed != "" {
-		unpacked, err := e.unpack(packed)
+		unpacked, err := unpackPacker(packed)
 		if err != nil {
 			e.log.Debug("failed to unpack JavaScript", "error", err)
 		} else {
@@ -135,38 +134,4 @@ func (e *MixdropExtractor) extractStreamURL(html string) (string, error) {
 	return "", fmt.Errorf("stream URL not found in page")
 }
 
-// unpack unpacks P.A.C.K.E.R. packed JavaScript.
-func (e *MixdropExtractor) unpack(packed string) (string, error) {
-	// Extract parameters from eval(function(p,a,c,k,e,d){...}('payload',a,c,'keywords'.split('|'),e,d))
-	paramsRe := regexp.MustCompile(`\}\('(.+)',(\d+),(\d+),'([^']+)'\.split`)
-	match := paramsRe.FindStringSubmatch(packed)
-	if len(match) < 5 {
-		return "", fmt.Errorf("failed to extract packer params")
-	}
-
-	payload := match[1]
-	keywords := strings.Split(match[4], "|")
-
-	// Simple unpacker - replace \bword\b with keyword
-	result := payload
-	for i := len(keywords) - 1; i >= 0; i-- {
-		if keywords[i] != "" {
-			pattern := fmt.Sprintf(`\b%s\b`, e.encode(i, 36))
-			re := regexp.MustCompile(pattern)
-			result = re.ReplaceAllString(result, keywords[i])
-		}
-	}
-
-	return result, nil
-}
-
-// encode encodes a number to base 36 (like JavaScript's toString(36)).
-func (e *MixdropExtractor) encode(n, base int) string {
-	const chars = "0123456789abcdefghijklmnopqrstuvwxyz"
-	if n < base {
-		return string(chars[n])
-	}
-	return e.encode(n/base, base) + string(chars[n%base])
-}
-
 var _ interfaces.Extractor = (*MixdropExtractor)(nil)
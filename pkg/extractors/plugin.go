@@ -0,0 +1,279 @@
+package extractors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+// defaultPluginTimeout bounds a plugin command when the definition sets no timeout.
+const defaultPluginTimeout = 30 * time.Second
+
+// PluginDefinition describes a user-defined extractor loaded from a JSON file.
+//
+// Regex plugins fetch the page (FetchURL, or the input URL) and return the first
+// capture group of Pattern. Command plugins run Command and read the stream URL,
+// or an ExtractResult as JSON, from its stdout.
+//
+//	{"name": "example", "match": "example\\.com/watch/(\\d+)",
+//	 "fetch_url": "https://example.com/embed/$1",
+//	 "pattern": "file:\\s*\"([^\"]+\\.m3u8[^\"]*)\""}
+//
+//	{"name": "mysite", "match": "mysite\\.tv/", "command": ["./mysite.py", "{url}"]}
+type PluginDefinition struct {
+	Name          string            `json:"name"`
+	Match         string            `json:"match"`                    // Regex the input URL must match
+	FetchURL      string            `json:"fetch_url,omitempty"`      // Page to fetch; $1.. expand Match groups
+	Headers       map[string]string `json:"headers,omitempty"`        // Headers for the page request
+	Pattern       string            `json:"pattern,omitempty"`        // Regex finding the stream URL in the page
+	Command       []string          `json:"command,omitempty"`        // External command; {url} is replaced by the input URL
+	Timeout       int               `json:"timeout,omitempty"`        // Command timeout in seconds
+	StreamHeaders map[string]string `json:"stream_headers,omitempty"` // Headers for stream requests; {origin} is the page origin
+	Endpoint      string            `json:"endpoint,omitempty"`       // Mediaflow endpoint, derived from the URL if empty
+}
+
+// PluginExtractor runs a PluginDefinition.
+type PluginExtractor struct {
+	*BaseExtractor
+	log     *logging.Logger
+	def     PluginDefinition
+	dir     string // Directory commands run in
+	match   *regexp.Regexp
+	pattern *regexp.Regexp
+}
+
+// NewPluginExtractor validates def and creates an extractor for it.
+func NewPluginExtractor(def PluginDefinition, dir string, client *httpclient.Client, log *logging.Logger) (*PluginExtractor, error) {
+	if def.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	match, err := regexp.Compile(def.Match)
+	if err != nil || def.Match == "" {
+		return nil, fmt.Errorf("invalid match regex %q: %v", def.Match, err)
+	}
+
+	e := &PluginExtractor{
+		BaseExtractor: NewBaseExtractor(client, log),
+		log:           log.WithComponent("plugin-extractor").With("plugin", def.Name),
+		def:           def,
+		dir:           dir,
+		match:         match,
+	}
+
+	switch {
+	case len(def.Command) > 0:
+	case def.Pattern != "":
+		if e.pattern, err = regexp.Compile(def.Pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern regex: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("either pattern or command is required")
+	}
+
+	return e, nil
+}
+
+// LoadPlugins loads every *.json extractor definition in dir. A missing
+// directory yields no plugins; invalid definitions are logged and skipped.
+func LoadPlugins(dir string, client *httpclient.Client, log *logging.Logger) ([]*PluginExtractor, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plugins: %w", err)
+	}
+	sort.Strings(files)
+
+	var plugins []*PluginExtractor
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			log.Warn("failed to read extractor plugin", "file", file, "error", err)
+			continue
+		}
+
+		var def PluginDefinition
+		if err := json.Unmarshal(data, &def); err != nil {
+			log.Warn("failed to parse extractor plugin", "file", file, "error", err)
+			continue
+		}
+
+		plugin, err := NewPluginExtractor(def, dir, client, log)
+		if err != nil {
+			log.Warn("invalid extractor plugin", "file", file, "error", err)
+			continue
+		}
+		plugins = append(plugins, plugin)
+	}
+
+	return plugins, nil
+}
+
+// Name returns the plugin name.
+func (e *PluginExtractor) Name() string {
+	return e.def.Name
+}
+
+// CanExtract returns true for URLs matching the plugin's match regex.
+func (e *PluginExtractor) CanExtract(urlStr string) bool {
+	return e.match.MatchString(urlStr)
+}
+
+// Extract runs the plugin.
+func (e *PluginExtractor) Extract(ctx context.Context, urlStr string, opts interfaces.ExtractOptions) (*types.ExtractResult, error) {
+	e.log.Debug("extracting with plugin", "url", urlStr)
+
+	if len(e.def.Command) > 0 {
+		return e.runCommand(ctx, urlStr)
+	}
+	return e.scanPage(ctx, urlStr, opts)
+}
+
+// scanPage fetches the page and applies the plugin pattern.
+func (e *PluginExtractor) scanPage(ctx context.Context, urlStr string, opts interfaces.ExtractOptions) (*types.ExtractResult, error) {
+	pageURL := urlStr
+	if e.def.FetchURL != "" {
+		submatches := e.match.FindStringSubmatchIndex(urlStr)
+		pageURL = string(e.match.ExpandString(nil, e.def.FetchURL, urlStr, submatches))
+	}
+
+	headers := make(map[string]string, len(opts.Headers)+len(e.def.Headers))
+	for k, v := range opts.Headers {
+		headers[k] = v
+	}
+	for k, v := range e.def.Headers {
+		headers[k] = v
+	}
+
+	resp, err := e.DoRequest(ctx, http.MethodGet, pageURL, headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("page returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, embedMaxPageSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Match against the page and any packed scripts on it
+	sources := []string{string(body)}
+	for _, packed := range embedPackedRe.FindAllString(string(body), -1) {
+		if unpacked, err := unpackPacker(packed); err == nil {
+			sources = append(sources, unpacked)
+		}
+	}
+
+	base, _ := url.Parse(pageURL)
+	for _, src := range sources {
+		match := e.pattern.FindStringSubmatch(unescapeJS(src))
+		if match == nil {
+			continue
+		}
+		streamURL := match[0]
+		if len(match) > 1 {
+			streamURL = match[1]
+		}
+		if resolved := resolveEmbedURL(streamURL, base); resolved != "" {
+			return e.result(resolved, pageURL, opts.Headers), nil
+		}
+	}
+
+	return nil, fmt.Errorf("pattern did not match on %s", pageURL)
+}
+
+// runCommand executes the plugin command and parses its output.
+func (e *PluginExtractor) runCommand(ctx context.Context, urlStr string) (*types.ExtractResult, error) {
+	timeout := defaultPluginTimeout
+	if e.def.Timeout > 0 {
+		timeout = time.Duration(e.def.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := make([]string, len(e.def.Command))
+	for i, arg := range e.def.Command {
+		args[i] = strings.ReplaceAll(arg, "{url}", urlStr)
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = e.dir
+	cmd.Env = append(os.Environ(), "MEDIAPROXY_URL="+urlStr)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("plugin command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	output = bytes.TrimSpace(output)
+	if bytes.HasPrefix(output, []byte("{")) {
+		var result types.ExtractResult
+		if err := json.Unmarshal(output, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse plugin output: %w", err)
+		}
+		if result.DestinationURL == "" {
+			return nil, fmt.Errorf("plugin returned no destination_url")
+		}
+		if result.MediaflowEndpoint == "" {
+			result.MediaflowEndpoint = pluginEndpoint(result.DestinationURL)
+		}
+		return &result, nil
+	}
+
+	streamURL, _, _ := strings.Cut(string(output), "\n")
+	streamURL = strings.TrimSpace(streamURL)
+	if streamURL == "" {
+		return nil, fmt.Errorf("plugin returned no stream URL")
+	}
+	return e.result(streamURL, urlStr, nil), nil
+}
+
+// result builds the extract result, applying the plugin's stream headers.
+func (e *PluginExtractor) result(streamURL, pageURL string, extra map[string]string) *types.ExtractResult {
+	result := embedResult(streamURL, pageURL, e.client.UserAgent(pageURL), extra)
+
+	origin := strings.TrimSuffix(result.RequestHeaders["Referer"], "/")
+	for k, v := range e.def.StreamHeaders {
+		result.RequestHeaders[k] = strings.ReplaceAll(v, "{origin}", origin)
+	}
+
+	result.MediaflowEndpoint = pluginEndpoint(streamURL)
+	if e.def.Endpoint != "" {
+		result.MediaflowEndpoint = e.def.Endpoint
+	}
+	return result
+}
+
+// pluginEndpoint derives the mediaflow endpoint from a stream URL.
+func pluginEndpoint(streamURL string) string {
+	lower := strings.ToLower(streamURL)
+	switch {
+	case strings.Contains(lower, ".mpd"):
+		return "mpd_manifest_proxy"
+	case strings.Contains(lower, ".m3u8"):
+		return "hls_manifest_proxy"
+	}
+	return "proxy_stream_endpoint"
+}
+
+var _ interfaces.Extractor = (*PluginExtractor)(nil)
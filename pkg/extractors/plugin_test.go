@@ -0,0 +1,131 @@
+package extractors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+)
+
+func TestLoadPlugins(t *testing.T) {
+	log := logging.New("error", false, nil)
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"a.json":     `{"name": "regex", "match": "example\\.com/watch/", "pattern": "file: \"([^\"]+)\""}`,
+		"b.json":     `{"name": "cmd", "match": "mysite\\.tv/", "command": ["./run.sh", "{url}"]}`,
+		"bad.json":   `{"name": "bad", "match": "(", "pattern": "x"}`,
+		"empty.json": `{"name": "empty", "match": "x"}`,
+		"notes.txt":  `ignored`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plugins, err := LoadPlugins(dir, nil, log)
+	if err != nil {
+		t.Fatalf("LoadPlugins() error = %v", err)
+	}
+	if len(plugins) != 2 || plugins[0].Name() != "regex" || plugins[1].Name() != "cmd" {
+		t.Fatalf("LoadPlugins() loaded %d plugins, want regex and cmd", len(plugins))
+	}
+	if !plugins[0].CanExtract("https://example.com/watch/1") || plugins[0].CanExtract("https://mysite.tv/1") {
+		t.Error("CanExtract() does not follow the match regex")
+	}
+
+	if plugins, err := LoadPlugins(filepath.Join(dir, "missing"), nil, log); err != nil || len(plugins) != 0 {
+		t.Errorf("LoadPlugins(missing dir) = %d, %v, want no plugins", len(plugins), err)
+	}
+}
+
+func TestPluginExtractor_Regex(t *testing.T) {
+	log := logging.New("error", false, nil)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embed/42" || r.Header.Get("Referer") != "https://example.com/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`<script>player({file: "\/live\/42\/index.m3u8"})</script>`))
+	}))
+	defer server.Close()
+
+	def := PluginDefinition{
+		Name:          "example",
+		Match:         `^https://example\.com/watch/(\d+)`,
+		FetchURL:      server.URL + "/embed/$1",
+		Headers:       map[string]string{"Referer": "https://example.com/"},
+		Pattern:       `file: "([^"]+)"`,
+		StreamHeaders: map[string]string{"X-Origin": "{origin}"},
+	}
+	e, err := NewPluginExtractor(def, "", httpclient.New(&config.Config{}, log), log)
+	if err != nil {
+		t.Fatalf("NewPluginExtractor() error = %v", err)
+	}
+
+	result, err := e.Extract(context.Background(), "https://example.com/watch/42", interfaces.ExtractOptions{})
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if result.DestinationURL != server.URL+"/live/42/index.m3u8" {
+		t.Errorf("DestinationURL = %q", result.DestinationURL)
+	}
+	if result.RequestHeaders["X-Origin"] != server.URL {
+		t.Errorf("X-Origin = %q, want %q", result.RequestHeaders["X-Origin"], server.URL)
+	}
+	if result.MediaflowEndpoint != "hls_manifest_proxy" {
+		t.Errorf("MediaflowEndpoint = %q", result.MediaflowEndpoint)
+	}
+}
+
+func TestPluginExtractor_Command(t *testing.T) {
+	log := logging.New("error", false, nil)
+	dir := t.TempDir()
+
+	scripts := map[string]string{
+		"plain.sh": "#!/bin/sh\necho \"https://cdn.example.com/$(basename \"$1\").mpd\"\n",
+		"json.sh":  "#!/bin/sh\necho '{\"destination_url\": \"'\"$MEDIAPROXY_URL\"'/stream.m3u8\", \"request_headers\": {\"Referer\": \"https://x/\"}}'\n",
+		"fail.sh":  "#!/bin/sh\necho 'offline' >&2\nexit 1\n",
+	}
+	for name, content := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := func(script string) (*PluginExtractor, error) {
+		return NewPluginExtractor(PluginDefinition{Name: script, Match: ".", Command: []string{"./" + script, "{url}"}}, dir, nil, log)
+	}
+
+	e, _ := run("plain.sh")
+	result, err := e.Extract(context.Background(), "https://mysite.tv/ch1", interfaces.ExtractOptions{})
+	if err != nil {
+		t.Fatalf("Extract(plain) error = %v", err)
+	}
+	if result.DestinationURL != "https://cdn.example.com/ch1.mpd" || result.MediaflowEndpoint != "mpd_manifest_proxy" {
+		t.Errorf("Extract(plain) = %q, %q", result.DestinationURL, result.MediaflowEndpoint)
+	}
+
+	e, _ = run("json.sh")
+	result, err = e.Extract(context.Background(), "https://mysite.tv/ch1", interfaces.ExtractOptions{})
+	if err != nil {
+		t.Fatalf("Extract(json) error = %v", err)
+	}
+	if result.DestinationURL != "https://mysite.tv/ch1/stream.m3u8" || result.RequestHeaders["Referer"] != "https://x/" {
+		t.Errorf("Extract(json) = %+v", result)
+	}
+
+	e, _ = run("fail.sh")
+	if _, err := e.Extract(context.Background(), "https://mysite.tv/ch1", interfaces.ExtractOptions{}); err == nil {
+		t.Error("Extract(fail) should return an error")
+	}
+}
@@ -3,7 +3,6 @@ package extractors
 import (
 	"context"
 	"fmt"
-	"io"
 	"regexp"
 	"strings"
 
@@ -47,7 +46,7 @@ func (e *StreamtapeExtractor) Extract(ctx context.Context, urlStr string, opts i
 	e.log.Debug("extracting Streamtape stream", "url", urlStr)
 
 	headers := map[string]string{
-		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		"User-Agent": e.client.UserAgent(urlStr),
 		"Referer":    "https://streamtape.com/",
 	}
 
@@ -57,7 +56,7 @@ func (e *StreamtapeExtractor) Extract(ctx context.Context, urlStr string, opts i
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := e.ReadBody(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
@@ -0,0 +1,252 @@
+package extractors
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+const (
+	twitchGQLURL   = "https://gql.twitch.tv/gql"
+	twitchUsherURL = "https://usher.ttvnw.net"
+	twitchClientID = "kimne78kx3ncx6brgo4mv6wki5h1ko" // Public web player client ID
+	twitchSiteURL  = "https://www.twitch.tv/"         // Site the player runs on, whose User-Agent is used
+)
+
+// twitchTokenQuery requests a playback access token for a channel or VOD.
+const twitchTokenQuery = `query PlaybackAccessToken($login: String!, $isLive: Boolean!, $vodID: ID!, $isVod: Boolean!, $playerType: String!) {
+  streamPlaybackAccessToken(channelName: $login, params: {platform: "web", playerBackend: "mediaplayer", playerType: $playerType}) @include(if: $isLive) { value signature }
+  videoPlaybackAccessToken(id: $vodID, params: {platform: "web", playerBackend: "mediaplayer", playerType: $playerType}) @include(if: $isVod) { value signature }
+}`
+
+var (
+	// twitchChannelRe matches a channel login.
+	twitchChannelRe = regexp.MustCompile(`^[A-Za-z0-9_]{3,25}$`)
+	// twitchVODRe matches a /videos/ID path.
+	twitchVODRe = regexp.MustCompile(`^/videos/(\d+)`)
+)
+
+// twitchReservedPaths are twitch.tv paths that are not channel logins.
+var twitchReservedPaths = map[string]bool{
+	"directory": true, "videos": true, "search": true, "settings": true,
+	"downloads": true, "p": true, "jobs": true, "turbo": true, "subscriptions": true,
+}
+
+// TwitchExtractor resolves Twitch channels and VODs to their usher HLS playlist.
+type TwitchExtractor struct {
+	*BaseExtractor
+	log      *logging.Logger
+	gqlURL   string
+	usherURL string
+	deviceID string
+}
+
+// NewTwitchExtractor creates a new Twitch extractor.
+func NewTwitchExtractor(client *httpclient.Client, log *logging.Logger) *TwitchExtractor {
+	return &TwitchExtractor{
+		BaseExtractor: NewBaseExtractor(client, log),
+		log:           log.WithComponent("twitch-extractor"),
+		gqlURL:        twitchGQLURL,
+		usherURL:      twitchUsherURL,
+		deviceID:      newTwitchDeviceID(),
+	}
+}
+
+// Name returns the extractor name.
+func (e *TwitchExtractor) Name() string {
+	return "twitch"
+}
+
+// CanExtract returns true for Twitch channel and VOD URLs.
+func (e *TwitchExtractor) CanExtract(urlStr string) bool {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Host) {
+	case "twitch.tv", "www.twitch.tv", "m.twitch.tv", "player.twitch.tv":
+		return true
+	}
+	return false
+}
+
+// Extract resolves a Twitch URL to the usher m3u8 playlist.
+func (e *TwitchExtractor) Extract(ctx context.Context, urlStr string, opts interfaces.ExtractOptions) (*types.ExtractResult, error) {
+	e.log.Debug("extracting Twitch stream", "url", urlStr)
+
+	login, vodID, err := parseTwitchURL(urlStr)
+	if err != nil {
+		return nil, err
+	}
+
+	token, sig, err := e.fetchAccessToken(ctx, login, vodID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playback token: %w", err)
+	}
+
+	params := url.Values{}
+	params.Set("sig", sig)
+	params.Set("token", token)
+	params.Set("allow_source", "true")
+	params.Set("allow_audio_only", "true")
+	params.Set("fast_bread", "true")
+	params.Set("platform", "web")
+	params.Set("player_backend", "mediaplayer")
+	params.Set("playlist_include_framerate", "true")
+	params.Set("supported_codecs", "avc1")
+	params.Set("p", fmt.Sprintf("%d", randInt(9999999)))
+
+	var playlistURL string
+	if vodID != "" {
+		playlistURL = fmt.Sprintf("%s/vod/%s.m3u8?%s", e.usherURL, vodID, params.Encode())
+	} else {
+		playlistURL = fmt.Sprintf("%s/api/channel/hls/%s.m3u8?%s", e.usherURL, login, params.Encode())
+	}
+
+	e.log.Debug("resolved Twitch playlist", "channel", login, "vod", vodID)
+
+	return &types.ExtractResult{
+		DestinationURL: playlistURL,
+		RequestHeaders: map[string]string{
+			"User-Agent":  e.client.UserAgent(twitchSiteURL),
+			"Origin":      "https://www.twitch.tv",
+			"Referer":     "https://www.twitch.tv/",
+			"X-Device-Id": e.deviceID,
+		},
+		MediaflowEndpoint: "hls_proxy",
+	}, nil
+}
+
+// twitchTokenResponse is the GQL response for the playback access token query.
+type twitchTokenResponse struct {
+	Data struct {
+		Stream *twitchToken `json:"streamPlaybackAccessToken"`
+		Video  *twitchToken `json:"videoPlaybackAccessToken"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type twitchToken struct {
+	Value     string `json:"value"`
+	Signature string `json:"signature"`
+}
+
+// fetchAccessToken requests a playback access token via the GQL API.
+func (e *TwitchExtractor) fetchAccessToken(ctx context.Context, login, vodID string) (string, string, error) {
+	payload, err := json.Marshal(map[string]any{
+		"operationName": "PlaybackAccessToken",
+		"query":         twitchTokenQuery,
+		"variables": map[string]any{
+			"login":      login,
+			"isLive":     vodID == "",
+			"vodID":      vodID,
+			"isVod":      vodID != "",
+			"playerType": "site",
+		},
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal GQL request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.gqlURL, bytes.NewReader(payload))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain;charset=UTF-8")
+	req.Header.Set("Client-ID", twitchClientID)
+	req.Header.Set("X-Device-Id", e.deviceID)
+	req.Header.Set("User-Agent", e.client.UserAgent(twitchSiteURL))
+	req.Header.Set("Origin", "https://www.twitch.tv")
+	req.Header.Set("Referer", "https://www.twitch.tv/")
+
+	resp, err := e.client.DoClass(req, httpclient.ClassPage)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to call GQL API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("GQL API returned status %d", resp.StatusCode)
+	}
+
+	var result twitchTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", "", fmt.Errorf("failed to parse GQL response: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		return "", "", fmt.Errorf("GQL error: %s", result.Errors[0].Message)
+	}
+
+	token := result.Data.Stream
+	if vodID != "" {
+		token = result.Data.Video
+	}
+	if token == nil || token.Value == "" {
+		if vodID != "" {
+			return "", "", fmt.Errorf("video %s not found", vodID)
+		}
+		return "", "", fmt.Errorf("channel %s not found", login)
+	}
+
+	return token.Value, token.Signature, nil
+}
+
+// parseTwitchURL returns the channel login or VOD ID of a Twitch URL.
+func parseTwitchURL(urlStr string) (login, vodID string, err error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid URL: %w", err)
+	}
+
+	// player.twitch.tv/?channel=name or ?video=v123
+	if q := u.Query(); strings.EqualFold(u.Host, "player.twitch.tv") {
+		if video := strings.TrimPrefix(q.Get("video"), "v"); video != "" {
+			return "", video, nil
+		}
+		if channel := q.Get("channel"); twitchChannelRe.MatchString(channel) {
+			return strings.ToLower(channel), "", nil
+		}
+		return "", "", fmt.Errorf("no channel or video in %s", urlStr)
+	}
+
+	if m := twitchVODRe.FindStringSubmatch(u.Path); m != nil {
+		return "", m[1], nil
+	}
+
+	first, _, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if !twitchChannelRe.MatchString(first) || twitchReservedPaths[strings.ToLower(first)] {
+		return "", "", fmt.Errorf("no channel in %s", urlStr)
+	}
+	return strings.ToLower(first), "", nil
+}
+
+// newTwitchDeviceID returns a random device ID like the web player's.
+func newTwitchDeviceID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// randInt returns a random number in [0, n).
+func randInt(n int) int {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return int(binary.BigEndian.Uint32(b) % uint32(n))
+}
+
+var _ interfaces.Extractor = (*TwitchExtractor)(nil)
@@ -0,0 +1,91 @@
+package extractors
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+)
+
+func TestParseTwitchURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		login   string
+		vodID   string
+		wantErr bool
+	}{
+		{"https://www.twitch.tv/Shroud", "shroud", "", false},
+		{"https://m.twitch.tv/shroud/videos", "shroud", "", false},
+		{"https://www.twitch.tv/videos/123456789", "", "123456789", false},
+		{"https://player.twitch.tv/?channel=shroud&parent=example.com", "shroud", "", false},
+		{"https://player.twitch.tv/?video=v987", "", "987", false},
+		{"https://www.twitch.tv/directory", "", "", true},
+		{"https://www.twitch.tv/", "", "", true},
+	}
+
+	for _, tt := range tests {
+		login, vodID, err := parseTwitchURL(tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTwitchURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			continue
+		}
+		if login != tt.login || vodID != tt.vodID {
+			t.Errorf("parseTwitchURL(%q) = %q, %q, want %q, %q", tt.url, login, vodID, tt.login, tt.vodID)
+		}
+	}
+}
+
+func TestTwitchExtractor_Extract(t *testing.T) {
+	log := logging.New("error", false, nil)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Client-ID") == "" || r.Header.Get("X-Device-Id") == "" {
+			http.Error(w, "missing device headers", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Variables map[string]any `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Variables["login"] != "shroud" {
+			w.Write([]byte(`{"data": {"streamPlaybackAccessToken": null}}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"streamPlaybackAccessToken": {"value": "{\"channel\":\"shroud\"}", "signature": "abc123"}}}`))
+	}))
+	defer server.Close()
+
+	e := NewTwitchExtractor(httpclient.New(&config.Config{}, log), log)
+	e.gqlURL = server.URL
+
+	result, err := e.Extract(context.Background(), "https://www.twitch.tv/shroud", interfaces.ExtractOptions{})
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	u, err := url.Parse(result.DestinationURL)
+	if err != nil {
+		t.Fatalf("invalid destination URL %q", result.DestinationURL)
+	}
+	if !strings.HasSuffix(u.Path, "/api/channel/hls/shroud.m3u8") {
+		t.Errorf("path = %q", u.Path)
+	}
+	if u.Query().Get("sig") != "abc123" || u.Query().Get("token") != `{"channel":"shroud"}` {
+		t.Errorf("query = %q", u.RawQuery)
+	}
+	if result.RequestHeaders["X-Device-Id"] == "" {
+		t.Error("X-Device-Id header not passed to stream requests")
+	}
+
+	if _, err := e.Extract(context.Background(), "https://www.twitch.tv/offline_channel", interfaces.ExtractOptions{}); err == nil {
+		t.Error("Extract() of unknown channel should fail")
+	}
+}
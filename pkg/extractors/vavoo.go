@@ -21,6 +21,12 @@ import (
 const (
 	vavooPingURL    = "https://www.vavoo.tv/api/app/ping"
 	vavooResolveURL = "https://vavoo.to/mediahubmx-resolve.json"
+	vavooCatalogURL = "https://vavoo.to/mediahubmx-catalog.json"
+
+	// vavooCatalogTTL is how long a fetched channel catalog is reused.
+	vavooCatalogTTL = 30 * time.Minute
+	// vavooCatalogMaxPages bounds catalog paging in case the cursor never ends.
+	vavooCatalogMaxPages = 50
 )
 
 // VavooExtractor extracts streams from Vavoo.to.
@@ -28,10 +34,23 @@ type VavooExtractor struct {
 	*BaseExtractor
 	log *logging.Logger
 
+	pingURL    string
+	catalogURL string
+
 	// Cached signature
 	mu        sync.RWMutex
 	signature string
 	sigExpiry time.Time
+
+	// Cached channel catalogs by group filter
+	catalogMu sync.Mutex
+	catalogs  map[string]vavooCatalog
+}
+
+// vavooCatalog is a cached catalog listing.
+type vavooCatalog struct {
+	channels []types.Channel
+	expires  time.Time
 }
 
 // NewVavooExtractor creates a new Vavoo extractor.
@@ -39,6 +58,9 @@ func NewVavooExtractor(client *httpclient.Client, log *logging.Logger) *VavooExt
 	return &VavooExtractor{
 		BaseExtractor: NewBaseExtractor(client, log),
 		log:           log.WithComponent("vavoo-extractor"),
+		pingURL:       vavooPingURL,
+		catalogURL:    vavooCatalogURL,
+		catalogs:      make(map[string]vavooCatalog),
 	}
 }
 
@@ -69,7 +91,7 @@ func (e *VavooExtractor) Extract(ctx context.Context, urlStr string, opts interf
 	}
 
 	headers := map[string]string{
-		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36",
+		"User-Agent": e.client.UserAgent(resolvedURL),
 	}
 
 	return &types.ExtractResult{
@@ -170,7 +192,7 @@ func (e *VavooExtractor) refreshSignature(ctx context.Context) (string, error) {
 		return "", err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, vavooPingURL, bytes.NewReader(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.pingURL, bytes.NewReader(jsonData))
 	if err != nil {
 		return "", err
 	}
@@ -180,7 +202,7 @@ func (e *VavooExtractor) refreshSignature(ctx context.Context) (string, error) {
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Accept-Encoding", "gzip")
 
-	resp, err := e.client.Do(req)
+	resp, err := e.client.DoClass(req, httpclient.ClassPage)
 	if err != nil {
 		return "", err
 	}
@@ -197,7 +219,7 @@ func (e *VavooExtractor) refreshSignature(ctx context.Context) (string, error) {
 		reader = gzReader
 	}
 
-	body, err := io.ReadAll(reader)
+	body, err := e.ReadBody(reader)
 	if err != nil {
 		return "", err
 	}
@@ -259,7 +281,7 @@ func (e *VavooExtractor) resolveURL(ctx context.Context, urlStr, signature strin
 	req.Header.Set("Accept-Encoding", "gzip")
 	req.Header.Set("mediahubmx-signature", signature)
 
-	resp, err := e.client.Do(req)
+	resp, err := e.client.DoClass(req, httpclient.ClassPage)
 	if err != nil {
 		return "", err
 	}
@@ -276,7 +298,7 @@ func (e *VavooExtractor) resolveURL(ctx context.Context, urlStr, signature strin
 		reader = gzReader
 	}
 
-	body, err := io.ReadAll(reader)
+	body, err := e.ReadBody(reader)
 	if err != nil {
 		return "", err
 	}
@@ -314,4 +336,149 @@ func (e *VavooExtractor) resolveURL(ctx context.Context, urlStr, signature strin
 	return resolvedURL, nil
 }
 
+// vavooCatalogResponse is a page of the mediahubmx catalog.
+type vavooCatalogResponse struct {
+	Items []struct {
+		IDs struct {
+			ID string `json:"id"`
+		} `json:"ids"`
+		URL   string `json:"url"`
+		Name  string `json:"name"`
+		Group string `json:"group"`
+		Logo  string `json:"logo"`
+	} `json:"items"`
+	NextCursor *int `json:"nextCursor"`
+}
+
+// Catalog returns the Vavoo live channels in the given groups (countries, e.g.
+// "Germany"), or all channels if groups is empty. Channel URLs are resolved by
+// the extractor on playback.
+func (e *VavooExtractor) Catalog(ctx context.Context, groups []string) ([]types.Channel, error) {
+	if len(groups) == 0 {
+		return e.catalogGroup(ctx, "")
+	}
+
+	var result []types.Channel
+	for _, group := range groups {
+		channels, err := e.catalogGroup(ctx, group)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, channels...)
+	}
+	return result, nil
+}
+
+// catalogGroup returns the (cached) catalog for one group, "" for all.
+func (e *VavooExtractor) catalogGroup(ctx context.Context, group string) ([]types.Channel, error) {
+	e.catalogMu.Lock()
+	defer e.catalogMu.Unlock()
+
+	if cached, ok := e.catalogs[group]; ok && time.Now().Before(cached.expires) {
+		return cached.channels, nil
+	}
+
+	sig, err := e.getSignature(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signature: %w", err)
+	}
+
+	var channels []types.Channel
+	cursor := 0
+	for page := 0; page < vavooCatalogMaxPages; page++ {
+		resp, err := e.fetchCatalogPage(ctx, sig, group, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range resp.Items {
+			if item.URL == "" {
+				continue
+			}
+			ch := types.Channel{
+				Name:  item.Name,
+				URL:   item.URL,
+				Logo:  item.Logo,
+				Group: item.Group,
+			}
+			if item.IDs.ID != "" {
+				ch.ID = "vavoo-" + item.IDs.ID
+			}
+			channels = append(channels, ch)
+		}
+
+		if resp.NextCursor == nil || *resp.NextCursor <= cursor || len(resp.Items) == 0 {
+			break
+		}
+		cursor = *resp.NextCursor
+	}
+
+	e.log.Debug("fetched Vavoo catalog", "group", group, "channels", len(channels))
+	e.catalogs[group] = vavooCatalog{channels: channels, expires: time.Now().Add(vavooCatalogTTL)}
+	return channels, nil
+}
+
+// fetchCatalogPage fetches one page of the catalog starting at cursor.
+func (e *VavooExtractor) fetchCatalogPage(ctx context.Context, signature, group string, cursor int) (*vavooCatalogResponse, error) {
+	payload := map[string]interface{}{
+		"language":      "de",
+		"region":        "AT",
+		"catalogId":     "iptv",
+		"id":            "iptv",
+		"adult":         false,
+		"search":        "",
+		"sort":          "name",
+		"filter":        map[string]interface{}{},
+		"cursor":        cursor,
+		"clientVersion": "3.1.21",
+	}
+	if group != "" {
+		payload["filter"] = map[string]interface{}{"group": group}
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.catalogURL, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", "MediaHubMX/2")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("mediahubmx-signature", signature)
+
+	resp, err := e.client.DoClass(req, httpclient.ClassPage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch catalog: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("catalog returned status %d", resp.StatusCode)
+	}
+
+	// Handle gzip decompression
+	var reader io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gzReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	}
+
+	var result vavooCatalogResponse
+	if err := json.NewDecoder(reader).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog: %w", err)
+	}
+	return &result, nil
+}
+
 var _ interfaces.Extractor = (*VavooExtractor)(nil)
+var _ interfaces.ChannelCatalog = (*VavooExtractor)(nil)
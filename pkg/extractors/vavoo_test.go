@@ -0,0 +1,65 @@
+package extractors
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/logging"
+)
+
+func TestVavooExtractor_Catalog(t *testing.T) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"addonSig": "test-sig"}`))
+	})
+	mux.HandleFunc("/catalog", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("mediahubmx-signature") != "test-sig" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var req struct {
+			Cursor int               `json:"cursor"`
+			Filter map[string]string `json:"filter"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Filter["group"] != "Italy" {
+			w.Write([]byte(`{"items": []}`))
+			return
+		}
+		if req.Cursor == 0 {
+			w.Write([]byte(`{"items": [{"ids": {"id": "1"}, "url": "https://vavoo.to/play/1/index.m3u8", "name": "Rai 1", "group": "Italy"}], "nextCursor": 1}`))
+			return
+		}
+		w.Write([]byte(`{"items": [{"ids": {"id": "2"}, "url": "https://vavoo.to/play/2/index.m3u8", "name": "Rai 2", "group": "Italy"}, {"name": "No URL"}]}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	log := logging.New("error", false, nil)
+	e := NewVavooExtractor(httpclient.New(&config.Config{}, log), log)
+	e.pingURL = server.URL + "/ping"
+	e.catalogURL = server.URL + "/catalog"
+
+	channels, err := e.Catalog(context.Background(), []string{"Italy"})
+	if err != nil {
+		t.Fatalf("Catalog() error = %v", err)
+	}
+	if len(channels) != 2 || channels[0].ID != "vavoo-1" || channels[1].Name != "Rai 2" || channels[1].Group != "Italy" {
+		t.Errorf("channels = %+v", channels)
+	}
+
+	// Second call is served from the cache
+	if _, err := e.Catalog(context.Background(), []string{"Italy"}); err != nil {
+		t.Fatalf("Catalog() error = %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("catalog requests = %d, want 2 (two pages, then cached)", requests)
+	}
+}
@@ -0,0 +1,231 @@
+package extractors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+// The iOS innertube client returns an HLS manifest for live streams without
+// requiring signature deciphering.
+const (
+	youtubeClientName    = "IOS"
+	youtubeClientID      = "5"
+	youtubeClientVersion = "19.45.4"
+	youtubeUserAgent     = "com.google.ios.youtube/19.45.4 (iPhone16,2; U; CPU iOS 18_1_0 like Mac OS X;)"
+	youtubePlayerAPI     = "https://www.youtube.com/youtubei/v1/player?prettyPrint=false"
+)
+
+var (
+	// youtubeVideoIDRe matches an 11-character YouTube video ID.
+	youtubeVideoIDRe = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	// youtubeCanonicalRe finds the live video on a channel's /live page.
+	youtubeCanonicalRe = regexp.MustCompile(`<link rel="canonical" href="https://www\.youtube\.com/watch\?v=([A-Za-z0-9_-]{11})"`)
+)
+
+// YouTubeExtractor resolves YouTube live/watch/embed URLs to their HLS manifest.
+type YouTubeExtractor struct {
+	*BaseExtractor
+	log       *logging.Logger
+	playerAPI string
+}
+
+// NewYouTubeExtractor creates a new YouTube extractor.
+func NewYouTubeExtractor(client *httpclient.Client, log *logging.Logger) *YouTubeExtractor {
+	return &YouTubeExtractor{
+		BaseExtractor: NewBaseExtractor(client, log),
+		log:           log.WithComponent("youtube-extractor"),
+		playerAPI:     youtubePlayerAPI,
+	}
+}
+
+// Name returns the extractor name.
+func (e *YouTubeExtractor) Name() string {
+	return "youtube"
+}
+
+// CanExtract returns true for YouTube watch, live, embed and short-link URLs.
+func (e *YouTubeExtractor) CanExtract(urlStr string) bool {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	switch host {
+	case "youtube.com", "m.youtube.com", "music.youtube.com", "youtube-nocookie.com", "youtu.be":
+		return true
+	}
+	return false
+}
+
+// Extract resolves a YouTube URL to its HLS manifest via the innertube player API.
+func (e *YouTubeExtractor) Extract(ctx context.Context, urlStr string, opts interfaces.ExtractOptions) (*types.ExtractResult, error) {
+	e.log.Debug("extracting YouTube stream", "url", urlStr)
+
+	videoID := youtubeVideoID(urlStr)
+	if videoID == "" {
+		// Channel live pages (/@handle/live, /channel/ID/live) point at the current broadcast
+		var err error
+		videoID, err = e.resolveLiveVideoID(ctx, urlStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	manifestURL, err := e.fetchHLSManifest(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+
+	e.log.Debug("resolved YouTube manifest", "video_id", videoID)
+
+	return &types.ExtractResult{
+		DestinationURL: manifestURL,
+		RequestHeaders: map[string]string{
+			"User-Agent": youtubeUserAgent,
+		},
+		MediaflowEndpoint: "hls_proxy",
+	}, nil
+}
+
+// resolveLiveVideoID finds the video ID of a channel's current live stream.
+func (e *YouTubeExtractor) resolveLiveVideoID(ctx context.Context, pageURL string) (string, error) {
+	headers := map[string]string{
+		"Accept-Language": "en-US,en;q=0.9",
+		// Skip the EU consent interstitial
+		"Cookie": "CONSENT=YES+1; SOCS=CAI",
+	}
+
+	resp, err := e.DoRequest(ctx, http.MethodGet, pageURL, headers)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := e.ReadBody(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	match := youtubeCanonicalRe.FindSubmatch(body)
+	if match == nil {
+		return "", fmt.Errorf("no live stream found on %s", pageURL)
+	}
+	return string(match[1]), nil
+}
+
+// youtubePlayerResponse is the subset of the innertube player response we use.
+type youtubePlayerResponse struct {
+	PlayabilityStatus struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	} `json:"playabilityStatus"`
+	StreamingData struct {
+		HLSManifestURL string `json:"hlsManifestUrl"`
+	} `json:"streamingData"`
+	VideoDetails struct {
+		IsLive bool `json:"isLive"`
+	} `json:"videoDetails"`
+}
+
+// fetchHLSManifest asks the innertube player API for the video's HLS manifest.
+func (e *YouTubeExtractor) fetchHLSManifest(ctx context.Context, videoID string) (string, error) {
+	payload, err := json.Marshal(map[string]any{
+		"videoId": videoID,
+		"context": map[string]any{
+			"client": map[string]string{
+				"clientName":    youtubeClientName,
+				"clientVersion": youtubeClientVersion,
+				"deviceMake":    "Apple",
+				"deviceModel":   "iPhone16,2",
+				"osName":        "iPhone",
+				"osVersion":     "18.1.0.22B83",
+				"hl":            "en",
+				"gl":            "US",
+			},
+		},
+		"contentCheckOk": true,
+		"racyCheckOk":    true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal player request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.playerAPI, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", youtubeUserAgent)
+	req.Header.Set("X-Youtube-Client-Name", youtubeClientID)
+	req.Header.Set("X-Youtube-Client-Version", youtubeClientVersion)
+	req.Header.Set("Origin", "https://www.youtube.com")
+
+	resp, err := e.client.DoClass(req, httpclient.ClassPage)
+	if err != nil {
+		return "", fmt.Errorf("failed to call player API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("player API returned status %d", resp.StatusCode)
+	}
+
+	var player youtubePlayerResponse
+	if err := json.NewDecoder(resp.Body).Decode(&player); err != nil {
+		return "", fmt.Errorf("failed to parse player response: %w", err)
+	}
+
+	if player.PlayabilityStatus.Status != "OK" {
+		return "", fmt.Errorf("video %s is not playable: %s %s", videoID, player.PlayabilityStatus.Status, player.PlayabilityStatus.Reason)
+	}
+	if player.StreamingData.HLSManifestURL == "" {
+		return "", fmt.Errorf("no HLS manifest for video %s (live=%v)", videoID, player.VideoDetails.IsLive)
+	}
+
+	return player.StreamingData.HLSManifestURL, nil
+}
+
+// youtubeVideoID returns the video ID from a watch, live, embed, shorts or
+// youtu.be URL, or "" for channel pages.
+func youtubeVideoID(urlStr string) string {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return ""
+	}
+
+	if v := u.Query().Get("v"); youtubeVideoIDRe.MatchString(v) {
+		return v
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if strings.EqualFold(strings.TrimPrefix(u.Host, "www."), "youtu.be") {
+		if youtubeVideoIDRe.MatchString(segments[0]) {
+			return segments[0]
+		}
+		return ""
+	}
+
+	// /live/ID, /embed/ID, /shorts/ID, /v/ID
+	if len(segments) == 2 {
+		switch segments[0] {
+		case "live", "embed", "shorts", "v":
+			if youtubeVideoIDRe.MatchString(segments[1]) {
+				return segments[1]
+			}
+		}
+	}
+	return ""
+}
+
+var _ interfaces.Extractor = (*YouTubeExtractor)(nil)
@@ -0,0 +1,101 @@
+package extractors
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+)
+
+func TestYouTubeExtractor_CanExtract(t *testing.T) {
+	e := NewYouTubeExtractor(nil, logging.New("error", false, nil))
+
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", true},
+		{"https://youtu.be/dQw4w9WgXcQ", true},
+		{"https://m.youtube.com/live/dQw4w9WgXcQ", true},
+		{"https://www.youtube.com/@SkyNews/live", true},
+		{"https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ", true},
+		{"https://notyoutube.com/watch?v=dQw4w9WgXcQ", false},
+		{"https://example.com/youtube.com/stream.m3u8", false},
+	}
+
+	for _, tt := range tests {
+		if got := e.CanExtract(tt.url); got != tt.expected {
+			t.Errorf("CanExtract(%q) = %v, want %v", tt.url, got, tt.expected)
+		}
+	}
+}
+
+func TestYouTubeVideoID(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=10", "dQw4w9WgXcQ"},
+		{"https://youtu.be/dQw4w9WgXcQ?si=abc", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/live/dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/embed/dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/shorts/dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/@SkyNews/live", ""},
+		{"https://www.youtube.com/channel/UCoMdktPbSTixAyNGwb-UYkQ/live", ""},
+		{"https://www.youtube.com/watch?v=short", ""},
+	}
+
+	for _, tt := range tests {
+		if got := youtubeVideoID(tt.url); got != tt.expected {
+			t.Errorf("youtubeVideoID(%q) = %q, want %q", tt.url, got, tt.expected)
+		}
+	}
+}
+
+func TestYouTubeExtractor_Extract(t *testing.T) {
+	log := logging.New("error", false, nil)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/@news/live":
+			w.Write([]byte(`<html><head><link rel="canonical" href="https://www.youtube.com/watch?v=abcdefghijk"></head></html>`))
+		case "/player":
+			var req struct {
+				VideoID string `json:"videoId"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.VideoID != "abcdefghijk" {
+				w.Write([]byte(`{"playabilityStatus": {"status": "ERROR", "reason": "Video unavailable"}}`))
+				return
+			}
+			w.Write([]byte(`{"playabilityStatus": {"status": "OK"}, "streamingData": {"hlsManifestUrl": "https://manifest.googlevideo.com/live.m3u8"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	e := NewYouTubeExtractor(httpclient.New(&config.Config{}, log), log)
+	e.playerAPI = server.URL + "/player"
+
+	result, err := e.Extract(context.Background(), server.URL+"/@news/live", interfaces.ExtractOptions{})
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if result.DestinationURL != "https://manifest.googlevideo.com/live.m3u8" {
+		t.Errorf("DestinationURL = %q", result.DestinationURL)
+	}
+	if result.RequestHeaders["User-Agent"] != youtubeUserAgent {
+		t.Errorf("User-Agent = %q", result.RequestHeaders["User-Agent"])
+	}
+
+	if _, err := e.Extract(context.Background(), "https://www.youtube.com/watch?v=zzzzzzzzzzz", interfaces.ExtractOptions{}); err == nil {
+		t.Error("Extract() of unplayable video should fail")
+	}
+}
@@ -0,0 +1,162 @@
+package flaresolverr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"media-proxy-go/pkg/logging"
+)
+
+// byparrRequest is the request body for the Byparr API.
+type byparrRequest struct {
+	Cmd        string `json:"cmd"`
+	URL        string `json:"url"`
+	MaxTimeout int    `json:"max_timeout"` // Seconds
+}
+
+// byparrResponse is the Byparr response. It mirrors Response, but cookie
+// expiry is sent as a float.
+type byparrResponse struct {
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+	Version  string `json:"version"`
+	Solution struct {
+		URL       string          `json:"url"`
+		Status    int             `json:"status"`
+		Response  string          `json:"response"`
+		Cookies   []browserCookie `json:"cookies"`
+		UserAgent string          `json:"userAgent"`
+	} `json:"solution"`
+}
+
+// browserCookie is a cookie as reported by a browser automation tool.
+type browserCookie struct {
+	Name     string  `json:"name"`
+	Value    string  `json:"value"`
+	Domain   string  `json:"domain"`
+	Path     string  `json:"path"`
+	Expires  float64 `json:"expires"` // Unix seconds, -1 for session cookies
+	HTTPOnly bool    `json:"httpOnly"`
+	Secure   bool    `json:"secure"`
+}
+
+// toCookies converts browser cookies to solver cookies.
+func toCookies(cookies []browserCookie) []Cookie {
+	result := make([]Cookie, len(cookies))
+	for i, c := range cookies {
+		result[i] = Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			HTTPOnly: c.HTTPOnly,
+			Secure:   c.Secure,
+		}
+		if c.Expires > 0 {
+			result[i].Expires = int64(c.Expires)
+		}
+	}
+	return result
+}
+
+// ByparrClient is a Byparr API client. Byparr exposes a FlareSolverr-style
+// /v1 endpoint but takes its timeout in seconds and does not accept cookies.
+type ByparrClient struct {
+	baseURL    string
+	timeout    time.Duration
+	httpClient *http.Client
+	log        *logging.Logger
+}
+
+// NewByparrClient creates a new Byparr client.
+func NewByparrClient(baseURL string, timeout time.Duration, log *logging.Logger) *ByparrClient {
+	return &ByparrClient{
+		baseURL: baseURL,
+		timeout: timeout,
+		httpClient: &http.Client{
+			Timeout: timeout + 10*time.Second, // Add buffer for network overhead
+		},
+		log: log.WithComponent("byparr"),
+	}
+}
+
+// Name returns the solver type.
+func (c *ByparrClient) Name() string {
+	return SolverByparr
+}
+
+// Get fetches a URL through Byparr. Existing cookies are not supported by
+// Byparr and are ignored.
+func (c *ByparrClient) Get(ctx context.Context, targetURL string, existingCookies []Cookie) (*Response, error) {
+	c.log.Debug("fetching URL via Byparr", "url", targetURL)
+
+	body, err := json.Marshal(byparrRequest{
+		Cmd:        "request.get",
+		URL:        targetURL,
+		MaxTimeout: int(c.timeout.Seconds()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Byparr returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var bpResp byparrResponse
+	if err := json.Unmarshal(respBody, &bpResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if bpResp.Status != "ok" {
+		return nil, fmt.Errorf("Byparr error: %s", bpResp.Message)
+	}
+
+	c.log.Debug("Byparr request successful",
+		"url", targetURL,
+		"status", bpResp.Solution.Status,
+		"cookies", len(bpResp.Solution.Cookies),
+		"response_length", len(bpResp.Solution.Response))
+
+	return &Response{
+		Status:  bpResp.Status,
+		Message: bpResp.Message,
+		Version: bpResp.Version,
+		Solution: Solution{
+			URL:       bpResp.Solution.URL,
+			Status:    bpResp.Solution.Status,
+			Response:  bpResp.Solution.Response,
+			Cookies:   toCookies(bpResp.Solution.Cookies),
+			UserAgent: bpResp.Solution.UserAgent,
+		},
+	}, nil
+}
+
+// IsConfigured returns true if the client is properly configured.
+func (c *ByparrClient) IsConfigured() bool {
+	return c.baseURL != ""
+}
+
+var _ Solver = (*ByparrClient)(nil)
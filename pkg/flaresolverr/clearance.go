@@ -0,0 +1,168 @@
+package flaresolverr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/logging"
+)
+
+// clearanceMaxPageSize bounds the page fetched with the clearance cookies.
+const clearanceMaxPageSize = 10 << 20
+
+// clearanceRequest is the request body for the cf-clearance-scraper API.
+type clearanceRequest struct {
+	URL  string `json:"url"`
+	Mode string `json:"mode"`
+}
+
+// clearanceResponse is the cf-clearance-scraper waf-session response.
+type clearanceResponse struct {
+	Code    int               `json:"code"`
+	Message string            `json:"message"`
+	Cookies []browserCookie   `json:"cookies"`
+	Headers map[string]string `json:"headers"`
+}
+
+// ClearanceScraperClient is a cf-clearance-scraper API client. The scraper
+// only returns a WAF session (cookies and browser headers), so the page itself
+// is fetched directly with that session.
+type ClearanceScraperClient struct {
+	baseURL    string
+	httpClient *http.Client
+	log        *logging.Logger
+}
+
+// NewClearanceScraperClient creates a new cf-clearance-scraper client.
+func NewClearanceScraperClient(baseURL string, timeout time.Duration, log *logging.Logger) *ClearanceScraperClient {
+	return &ClearanceScraperClient{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: timeout + 10*time.Second, // Add buffer for network overhead
+		},
+		log: log.WithComponent("cf-clearance-scraper"),
+	}
+}
+
+// Name returns the solver type.
+func (c *ClearanceScraperClient) Name() string {
+	return SolverCFClearanceScraper
+}
+
+// Get solves the challenge for targetURL and fetches the page with the
+// resulting session.
+func (c *ClearanceScraperClient) Get(ctx context.Context, targetURL string, existingCookies []Cookie) (*Response, error) {
+	c.log.Debug("requesting WAF session via cf-clearance-scraper", "url", targetURL)
+
+	session, err := c.solve(ctx, targetURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range session.Headers {
+		switch strings.ToLower(k) {
+		case "host", "cookie", "content-length", "accept-encoding":
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+
+	cookies := toCookies(session.Cookies)
+	for _, cookie := range mergeCookies(existingCookies, cookies) {
+		req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, clearanceMaxPageSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page: %w", err)
+	}
+
+	c.log.Debug("cf-clearance-scraper request successful",
+		"url", targetURL,
+		"status", resp.StatusCode,
+		"cookies", len(cookies),
+		"response_length", len(body))
+
+	return &Response{
+		Status: "ok",
+		Solution: Solution{
+			URL:       resp.Request.URL.String(),
+			Status:    resp.StatusCode,
+			Response:  string(body),
+			Cookies:   cookies,
+			UserAgent: req.Header.Get("User-Agent"),
+		},
+	}, nil
+}
+
+// solve requests a WAF session for targetURL.
+func (c *ClearanceScraperClient) solve(ctx context.Context, targetURL string) (*clearanceResponse, error) {
+	body, err := json.Marshal(clearanceRequest{URL: targetURL, Mode: "waf-session"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/cf-clearance-scraper", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var session clearanceResponse
+	if err := json.Unmarshal(respBody, &session); err != nil {
+		return nil, fmt.Errorf("cf-clearance-scraper returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	if resp.StatusCode != http.StatusOK || (session.Code != 0 && session.Code != http.StatusOK) {
+		return nil, fmt.Errorf("cf-clearance-scraper error: %s", session.Message)
+	}
+	return &session, nil
+}
+
+// IsConfigured returns true if the client is properly configured.
+func (c *ClearanceScraperClient) IsConfigured() bool {
+	return c.baseURL != ""
+}
+
+// mergeCookies merges new cookies into existing ones, overwriting by name.
+func mergeCookies(existing, new []Cookie) []Cookie {
+	result := make([]Cookie, 0, len(existing)+len(new))
+	index := make(map[string]int)
+	for _, c := range append(append([]Cookie{}, existing...), new...) {
+		if i, ok := index[c.Name]; ok {
+			result[i] = c
+			continue
+		}
+		index[c.Name] = len(result)
+		result = append(result, c)
+	}
+	return result
+}
+
+var _ Solver = (*ClearanceScraperClient)(nil)
@@ -1,5 +1,6 @@
-// Package flaresolverr provides a client for the FlareSolverr API
-// to bypass Cloudflare protection on websites.
+// Package flaresolverr provides clients for FlareSolverr and compatible
+// Cloudflare challenge solvers (Byparr, cf-clearance-scraper) to bypass
+// Cloudflare protection on websites.
 package flaresolverr
 
 import (
@@ -73,6 +74,11 @@ func NewClient(baseURL string, timeout time.Duration, log *logging.Logger) *Clie
 	}
 }
 
+// Name returns the solver type.
+func (c *Client) Name() string {
+	return SolverFlareSolverr
+}
+
 // Get fetches a URL through FlareSolverr, bypassing Cloudflare protection.
 func (c *Client) Get(ctx context.Context, targetURL string, existingCookies []Cookie) (*Response, error) {
 	c.log.Debug("fetching URL via FlareSolverr", "url", targetURL)
@@ -130,24 +136,12 @@ func (c *Client) Get(ctx context.Context, targetURL string, existingCookies []Co
 
 // ToHTTPCookies converts FlareSolverr cookies to http.Cookie slice.
 func (c *Client) ToHTTPCookies(cookies []Cookie) []*http.Cookie {
-	result := make([]*http.Cookie, len(cookies))
-	for i, cookie := range cookies {
-		result[i] = &http.Cookie{
-			Name:     cookie.Name,
-			Value:    cookie.Value,
-			Domain:   cookie.Domain,
-			Path:     cookie.Path,
-			Secure:   cookie.Secure,
-			HttpOnly: cookie.HTTPOnly,
-		}
-		if cookie.Expires > 0 {
-			result[i].Expires = time.Unix(cookie.Expires, 0)
-		}
-	}
-	return result
+	return ToHTTPCookies(cookies)
 }
 
 // IsConfigured returns true if the client is properly configured.
 func (c *Client) IsConfigured() bool {
 	return c.baseURL != ""
 }
+
+var _ Solver = (*Client)(nil)
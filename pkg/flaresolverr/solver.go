@@ -0,0 +1,64 @@
+package flaresolverr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/logging"
+)
+
+// Solver fetches pages through a Cloudflare challenge solver. Every solver
+// returns its result in the FlareSolverr response format.
+type Solver interface {
+	// Name returns the solver type.
+	Name() string
+	// Get fetches targetURL, solving any challenge, and returns the page with
+	// the clearance cookies and the User-Agent they are bound to.
+	Get(ctx context.Context, targetURL string, existingCookies []Cookie) (*Response, error)
+	// IsConfigured returns true if the solver has an endpoint.
+	IsConfigured() bool
+}
+
+// Solver types selectable with CF_SOLVER.
+const (
+	SolverFlareSolverr       = "flaresolverr"
+	SolverByparr             = "byparr"
+	SolverCFClearanceScraper = "cf-clearance-scraper"
+)
+
+// NewSolver creates a solver client of the given type.
+func NewSolver(kind, baseURL string, timeout time.Duration, log *logging.Logger) (Solver, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	switch strings.ToLower(kind) {
+	case "", SolverFlareSolverr:
+		return NewClient(baseURL, timeout, log), nil
+	case SolverByparr:
+		return NewByparrClient(baseURL, timeout, log), nil
+	case SolverCFClearanceScraper:
+		return NewClearanceScraperClient(baseURL, timeout, log), nil
+	}
+	return nil, fmt.Errorf("unknown Cloudflare solver %q", kind)
+}
+
+// ToHTTPCookies converts solver cookies to http.Cookie slice.
+func ToHTTPCookies(cookies []Cookie) []*http.Cookie {
+	result := make([]*http.Cookie, len(cookies))
+	for i, cookie := range cookies {
+		result[i] = &http.Cookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HTTPOnly,
+		}
+		if cookie.Expires > 0 {
+			result[i].Expires = time.Unix(cookie.Expires, 0)
+		}
+	}
+	return result
+}
@@ -0,0 +1,140 @@
+package flaresolverr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/logging"
+)
+
+func TestNewSolver(t *testing.T) {
+	log := logging.New("error", false, nil)
+
+	tests := []struct {
+		kind    string
+		want    string
+		wantErr bool
+	}{
+		{"", SolverFlareSolverr, false},
+		{"flaresolverr", SolverFlareSolverr, false},
+		{"Byparr", SolverByparr, false},
+		{"cf-clearance-scraper", SolverCFClearanceScraper, false},
+		{"unknown", "", true},
+	}
+
+	for _, tt := range tests {
+		solver, err := NewSolver(tt.kind, "http://localhost:8191/", 30*time.Second, log)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewSolver(%q) error = %v, wantErr %v", tt.kind, err, tt.wantErr)
+			continue
+		}
+		if err == nil && solver.Name() != tt.want {
+			t.Errorf("NewSolver(%q).Name() = %q, want %q", tt.kind, solver.Name(), tt.want)
+		}
+	}
+}
+
+func TestByparrClient_Get(t *testing.T) {
+	log := logging.New("error", false, nil)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1" {
+			t.Errorf("expected path /v1, got %s", r.URL.Path)
+		}
+		var req byparrRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if req.URL != "https://example.com" || req.MaxTimeout != 30 {
+			t.Errorf("request = %+v, want url https://example.com and max_timeout 30", req)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "ok", "message": "", "solution": {"url": "https://example.com", "status": 200,
+			"response": "<html>ok</html>", "userAgent": "Mozilla/5.0 Test",
+			"cookies": [{"name": "cf_clearance", "value": "token", "domain": ".example.com", "expires": 1735689600.5}]}}`))
+	}))
+	defer server.Close()
+
+	client := NewByparrClient(server.URL, 30*time.Second, log)
+
+	resp, err := client.Get(context.Background(), "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Solution.Response != "<html>ok</html>" || resp.Solution.UserAgent != "Mozilla/5.0 Test" {
+		t.Errorf("solution = %+v", resp.Solution)
+	}
+	if len(resp.Solution.Cookies) != 1 || resp.Solution.Cookies[0].Expires != 1735689600 {
+		t.Errorf("cookies = %+v", resp.Solution.Cookies)
+	}
+}
+
+func TestClearanceScraperClient_Get(t *testing.T) {
+	log := logging.New("error", false, nil)
+
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("cf_clearance")
+		if err != nil || cookie.Value != "token" || r.UserAgent() != "Mozilla/5.0 Test" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if session, err := r.Cookie("session"); err != nil || session.Value != "abc" {
+			t.Errorf("existing cookie not sent: %v", err)
+		}
+		w.Write([]byte("<html>protected</html>"))
+	}))
+	defer site.Close()
+
+	scraper := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cf-clearance-scraper" {
+			t.Errorf("expected path /cf-clearance-scraper, got %s", r.URL.Path)
+		}
+		var req clearanceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if req.Mode != "waf-session" || req.URL != site.URL {
+			t.Errorf("request = %+v", req)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"code": 200, "cookies": [{"name": "cf_clearance", "value": "token", "expires": -1}],
+			"headers": {"user-agent": "Mozilla/5.0 Test", "host": "ignored"}}`))
+	}))
+	defer scraper.Close()
+
+	client := NewClearanceScraperClient(scraper.URL, 30*time.Second, log)
+
+	resp, err := client.Get(context.Background(), site.URL, []Cookie{{Name: "session", Value: "abc"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Solution.Status != http.StatusOK || resp.Solution.Response != "<html>protected</html>" {
+		t.Errorf("solution = %+v", resp.Solution)
+	}
+	if resp.Solution.UserAgent != "Mozilla/5.0 Test" || len(resp.Solution.Cookies) != 1 {
+		t.Errorf("user agent = %q, cookies = %+v", resp.Solution.UserAgent, resp.Solution.Cookies)
+	}
+}
+
+func TestClearanceScraperClient_Get_Error(t *testing.T) {
+	log := logging.New("error", false, nil)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"code": 500, "message": "Request Timeout"}`))
+	}))
+	defer server.Close()
+
+	client := NewClearanceScraperClient(server.URL, 30*time.Second, log)
+
+	_, err := client.Get(context.Background(), "https://example.com", nil)
+	if err == nil || err.Error() != "cf-clearance-scraper error: Request Timeout" {
+		t.Errorf("error = %v", err)
+	}
+}
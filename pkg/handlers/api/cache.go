@@ -0,0 +1,14 @@
+package api
+
+import "net/http"
+
+// handleSegmentCacheStats returns the VOD segment cache's size and hit counts.
+func (h *Handlers) handleSegmentCacheStats(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, h.ctx.ProxyService.SegmentCache().Stats())
+}
+
+// handlePurgeSegmentCache removes all cached segments.
+func (h *Handlers) handlePurgeSegmentCache(w http.ResponseWriter, r *http.Request) {
+	h.ctx.ProxyService.SegmentCache().Purge()
+	h.writeJSON(w, http.StatusOK, map[string]string{"status": "purged"})
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"media-proxy-go/pkg/channels"
+	"media-proxy-go/pkg/types"
+)
+
+// handleListChannels returns saved channels. Optional filters: ?group= and ?favorite=true.
+func (h *Handlers) handleListChannels(w http.ResponseWriter, r *http.Request) {
+	group := r.URL.Query().Get("group")
+	favoritesOnly := r.URL.Query().Get("favorite") == "true"
+
+	result := []types.Channel{}
+	for _, ch := range h.ctx.Channels.List() {
+		if group != "" && ch.Group != group {
+			continue
+		}
+		if favoritesOnly && !ch.Favorite {
+			continue
+		}
+		result = append(result, ch)
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// handleListChannelGroups returns the distinct channel groups.
+func (h *Handlers) handleListChannelGroups(w http.ResponseWriter, r *http.Request) {
+	groups := h.ctx.Channels.Groups()
+	if groups == nil {
+		groups = []string{}
+	}
+	h.writeJSON(w, http.StatusOK, groups)
+}
+
+// handleGetChannel returns a single channel.
+func (h *Handlers) handleGetChannel(w http.ResponseWriter, r *http.Request) {
+	ch, ok := h.ctx.Channels.Get(r.PathValue("id"))
+	if !ok {
+		h.writeError(w, r, http.StatusNotFound, channels.ErrNotFound.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, ch)
+}
+
+// handleCreateChannel saves a new channel.
+func (h *Handlers) handleCreateChannel(w http.ResponseWriter, r *http.Request) {
+	ch, ok := h.decodeChannel(w, r)
+	if !ok {
+		return
+	}
+
+	created, err := h.ctx.Channels.Add(ch)
+	if err != nil {
+		h.log.Error("❌ failed to save channel", "error", err)
+		h.writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, created)
+}
+
+// handleUpdateChannel replaces an existing channel.
+func (h *Handlers) handleUpdateChannel(w http.ResponseWriter, r *http.Request) {
+	ch, ok := h.decodeChannel(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := h.ctx.Channels.Update(r.PathValue("id"), ch)
+	if err != nil {
+		h.writeChannelError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, updated)
+}
+
+// handleDeleteChannel removes a channel.
+func (h *Handlers) handleDeleteChannel(w http.ResponseWriter, r *http.Request) {
+	if err := h.ctx.Channels.Delete(r.PathValue("id")); err != nil {
+		h.writeChannelError(w, r, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// decodeChannel reads and validates a channel from the request body.
+func (h *Handlers) decodeChannel(w http.ResponseWriter, r *http.Request) (types.Channel, bool) {
+	var ch types.Channel
+	if err := json.NewDecoder(r.Body).Decode(&ch); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return ch, false
+	}
+
+	ch.URL = strings.TrimSpace(ch.URL)
+	if !strings.HasPrefix(ch.URL, "http://") && !strings.HasPrefix(ch.URL, "https://") {
+		h.writeError(w, r, http.StatusBadRequest, "url must be http or https")
+		return ch, false
+	}
+	ch.Name = strings.TrimSpace(ch.Name)
+
+	return ch, true
+}
+
+// writeChannelError maps channel store errors to HTTP responses.
+func (h *Handlers) writeChannelError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, channels.ErrNotFound) {
+		h.writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	h.log.Error("❌ failed to save channels", "error", err)
+	h.writeError(w, r, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"media-proxy-go/pkg/types"
+)
+
+// compressibleTypes are the content types gzipped for clients; media
+// segments are already compressed and stream through untouched.
+var compressibleTypes = []string{
+	"mpegurl", // HLS playlists
+	"xml",     // DASH manifests (dash+xml or plain xml)
+	"json",    // drm_info responses
+	"text/",   // Plain text playlists, WebVTT
+}
+
+// writeManifestResponse writes a manifest response, gzipped when enabled and
+// the client accepts it. Everything else goes through writeStreamResponse.
+func (h *Handlers) writeManifestResponse(w http.ResponseWriter, r *http.Request, resp *types.StreamResponse) {
+	if !h.ctx.Config.ManifestGzip || resp.RedirectURL != "" || resp.StatusCode != http.StatusOK ||
+		resp.Body == nil || !compressible(resp.ContentType) || !acceptsGzip(r) {
+		h.writeStreamResponse(w, r, resp)
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", resp.ContentType)
+	for key, value := range resp.Headers {
+		w.Header().Set(key, value)
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Add("Vary", "Accept-Encoding")
+	w.WriteHeader(resp.StatusCode)
+
+	gz := gzip.NewWriter(w)
+	if _, err := io.Copy(gz, resp.Body); err != nil {
+		h.log.Debug("manifest write interrupted", "error", err)
+	}
+	gz.Close()
+}
+
+// compressible reports whether a content type is worth gzipping.
+func compressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, t := range compressibleTypes {
+		if strings.Contains(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "x-gzip" && coding != "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		return q > 0
+	}
+	return false
+}
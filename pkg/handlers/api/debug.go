@@ -0,0 +1,96 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"media-proxy-go/pkg/types"
+)
+
+// handleDebugRuntime returns goroutine and heap figures, running child
+// processes (FFmpeg) and cache sizes.
+func (h *Handlers) handleDebugRuntime(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	info := types.RuntimeInfo{
+		GoVersion:   runtime.Version(),
+		Uptime:      int64(time.Since(h.startedAt).Seconds()),
+		Goroutines:  runtime.NumGoroutine(),
+		HeapAlloc:   mem.HeapAlloc,
+		HeapInuse:   mem.HeapInuse,
+		HeapObjects: mem.HeapObjects,
+		Sys:         mem.Sys,
+		NumGC:       mem.NumGC,
+		LastGCPause: int64(mem.PauseNs[(mem.NumGC+255)%256] / 1000),
+		Processes:   childProcesses(),
+		Caches: map[string]types.CacheSize{
+			"hls_keys":      {Entries: h.keys.len()},
+			"init_segments": h.inits.stats(),
+		},
+	}
+
+	if h.ctx.RecordingManager != nil {
+		if active, err := h.ctx.RecordingManager.ListActiveRecordings(); err == nil {
+			info.ActiveRecordings = len(active)
+		}
+	}
+	if h.ctx.Sessions != nil {
+		info.Sessions = h.ctx.Sessions.Count()
+	}
+	if h.ctx.ProxyService != nil && h.ctx.ProxyService.SegmentCache() != nil {
+		stats := h.ctx.ProxyService.SegmentCache().Stats()
+		info.Caches["segments"] = types.CacheSize{Entries: stats.Entries, Bytes: stats.Size}
+	}
+	if h.ctx.StreamStats != nil {
+		info.Caches["stream_stats"] = types.CacheSize{Entries: len(h.ctx.StreamStats.List())}
+	}
+	if h.ctx.Keys != nil {
+		info.Caches["clearkey_store"] = types.CacheSize{Entries: h.ctx.Keys.Count()}
+	}
+	if h.shortURLs != nil {
+		info.Caches["short_urls"] = types.CacheSize{Entries: h.shortURLs.Len()}
+	}
+	if h.decrypts != nil {
+		stats := h.decrypts.Stats()
+		info.DecryptPool = &stats
+	}
+	if h.remuxes != nil {
+		stats := h.remuxes.stats()
+		info.RemuxPool = &stats
+	}
+
+	h.writeJSON(w, http.StatusOK, info)
+}
+
+// childProcesses counts the running child processes of this process by
+// command name, e.g. {"ffmpeg": 3}. It reads /proc, so it is empty on
+// systems without it.
+func childProcesses() map[string]int {
+	counts := make(map[string]int)
+	self := strconv.Itoa(os.Getpid())
+
+	stats, _ := filepath.Glob("/proc/[0-9]*/stat")
+	for _, path := range stats {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue // Exited meanwhile
+		}
+		// pid (comm) state ppid ...; comm may contain spaces and parentheses
+		open, end := bytes.IndexByte(data, '('), bytes.LastIndexByte(data, ')')
+		if open < 0 || end < open {
+			continue
+		}
+		fields := bytes.Fields(data[end+1:])
+		if len(fields) < 2 || string(fields[1]) != self {
+			continue
+		}
+		counts[string(data[open+1:end])]++
+	}
+	return counts
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/types"
+)
+
+// downloadHeaders are the upstream response headers passed to the client.
+var downloadHeaders = []string{"Content-Length", "Content-Range", "Accept-Ranges", "Last-Modified", "ETag"}
+
+// handleDownload resolves a file-host link (MixDrop, Streamtape or a direct
+// file URL) and streams the file as an attachment. Range requests are
+// forwarded so interrupted downloads can be resumed.
+// ?filename= overrides the name offered to the client.
+func (h *Handlers) handleDownload(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	urlStr := query.Get("url")
+	if urlStr == "" {
+		urlStr = query.Get("d")
+	}
+	if urlStr == "" {
+		h.writeError(w, r, http.StatusBadRequest, "url parameter required")
+		return
+	}
+
+	h.log.Debug("download request", "url", urlStr, "range", r.Header.Get("Range"))
+
+	result, err := h.ctx.ProxyService.HandleExtract(r.Context(), urlStr, interfaces.ExtractOptions{
+		Headers: httpclient.ParseHeaderParams(query, h.ctx.Config.HeaderAllowlist...),
+	})
+	if err != nil {
+		h.log.Error("❌ extraction failed", "url", urlStr, "error", err)
+		h.writeError(w, r, http.StatusBadGateway, err.Error())
+		return
+	}
+	if result.MediaflowEndpoint != "proxy_stream_endpoint" {
+		h.writeError(w, r, http.StatusBadRequest, "not a downloadable file (HLS/DASH stream)")
+		return
+	}
+
+	headers := make(map[string]string, len(result.RequestHeaders)+3)
+	for k, v := range result.RequestHeaders {
+		headers[k] = v
+	}
+	if result.RequestCookies != "" {
+		headers["Cookie"] = result.RequestCookies
+	}
+	for _, name := range []string{"Range", "If-Range"} {
+		if v := r.Header.Get(name); v != "" {
+			headers[name] = v
+		}
+	}
+
+	resp, err := h.ctx.ProxyService.HandleSegment(r.Context(), &types.StreamRequest{
+		URL:     result.DestinationURL,
+		Headers: headers,
+	})
+	if err != nil {
+		h.log.Error("❌ download failed", "url", result.DestinationURL, "error", err)
+		h.writeError(w, r, http.StatusBadGateway, err.Error())
+		return
+	}
+	if resp.RedirectURL != "" {
+		http.Redirect(w, r, resp.RedirectURL, resp.StatusCode)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		h.log.Error("❌ download failed", "url", result.DestinationURL, "status", resp.StatusCode)
+		h.writeError(w, r, http.StatusBadGateway, "upstream returned status "+http.StatusText(resp.StatusCode))
+		return
+	}
+
+	filename := query.Get("filename")
+	if filename == "" {
+		filename = downloadFilename(result.DestinationURL, urlStr, resp.ContentType)
+	}
+
+	if resp.ContentType != "" {
+		w.Header().Set("Content-Type", resp.ContentType)
+	}
+	for _, name := range downloadHeaders {
+		if v := resp.Headers[name]; v != "" {
+			w.Header().Set(name, v)
+		}
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.WriteHeader(resp.StatusCode)
+
+	if r.Method != http.MethodHead {
+		relay(w, resp.Body)
+	}
+}
+
+// downloadFilename picks the name of a downloaded file: the file URL's name if
+// it has an extension, else the page URL's name with an extension for contentType.
+func downloadFilename(fileURL, pageURL, contentType string) string {
+	name := urlBase(fileURL)
+	if path.Ext(name) != "" {
+		return name
+	}
+
+	if name = urlBase(pageURL); name == "" {
+		name = "download"
+	}
+	if path.Ext(name) == "" {
+		ext := ".mp4" // File hosts serve MP4 unless told otherwise
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if strings.HasPrefix(mediaType, "video/") || strings.HasPrefix(mediaType, "audio/") {
+			if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+				ext = exts[0]
+			}
+		}
+		name += ext
+	}
+	return name
+}
+
+// urlBase returns the last path element of urlStr, or "".
+func urlBase(urlStr string) string {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(strings.TrimSuffix(u.Path, "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/types"
+)
+
+// egressTimeout bounds the IP lookup through each egress.
+const egressTimeout = 10 * time.Second
+
+// egressLister is implemented by httpclient.Client.
+type egressLister interface {
+	Egresses() []httpclient.Egress
+	EgressClient(proxy string) *http.Client
+}
+
+// geoIPResponse holds the fields of the ipinfo.io and ip-api.com formats.
+type geoIPResponse struct {
+	IP          string `json:"ip"`
+	Query       string `json:"query"` // ip-api.com
+	Country     string `json:"country"`
+	CountryCode string `json:"countryCode"` // ip-api.com, whose country is a name
+	City        string `json:"city"`
+	Org         string `json:"org"`
+	ISP         string `json:"isp"`
+}
+
+// handleIP reports the public IP and location of each egress: the default
+// path, the global proxies and the transport route proxies, so users can
+// check which streams leave through which VPN or proxy. The ip field keeps
+// the default egress IP.
+func (h *Handlers) handleIP(w http.ResponseWriter, r *http.Request) {
+	egresses := []httpclient.Egress{{Default: true}}
+	clientFor := func(string) *http.Client { return http.DefaultClient }
+	if lister, ok := h.ctx.HTTPClient.(egressLister); ok {
+		egresses = lister.Egresses()
+		clientFor = lister.EgressClient
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), egressTimeout)
+	defer cancel()
+
+	infos := make([]types.EgressInfo, len(egresses))
+	var wg sync.WaitGroup
+	for i, e := range egresses {
+		infos[i] = types.EgressInfo{Proxy: "direct", Default: e.Default, Routes: e.Routes}
+		if e.Proxy != "" {
+			infos[i].Proxy = httpclient.RedactProxy(e.Proxy)
+		}
+		wg.Add(1)
+		go func(info *types.EgressInfo, client *http.Client) {
+			defer wg.Done()
+			if err := h.lookupEgress(ctx, client, info); err != nil {
+				info.Error = err.Error()
+			}
+		}(&infos[i], clientFor(e.Proxy))
+	}
+	wg.Wait()
+
+	resp := map[string]any{"egress": infos}
+	failed := 0
+	for _, info := range infos {
+		if info.Default && info.IP != "" {
+			resp["ip"] = info.IP
+		}
+		if info.Error != "" {
+			failed++
+			h.log.Warn("egress IP lookup failed", "proxy", info.Proxy, "error", info.Error)
+		}
+	}
+	if failed == len(infos) {
+		h.writeError(w, r, http.StatusBadGateway, "failed to get IP")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// lookupEgress queries the GeoIP service through client and fills in the IP
+// and location of info. A plain-text response is taken as the bare IP.
+func (h *Handlers) lookupEgress(ctx context.Context, client *http.Client, info *types.EgressInfo) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.ctx.Config.GeoIPURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", req.URL.Host, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var geo geoIPResponse
+	if err := json.Unmarshal(body, &geo); err != nil {
+		info.IP = strings.TrimSpace(string(body))
+		return nil
+	}
+	info.IP = cmp.Or(geo.IP, geo.Query)
+	info.Country = cmp.Or(geo.CountryCode, geo.Country)
+	info.City = geo.City
+	info.Org = cmp.Or(geo.Org, geo.ISP)
+	if info.IP == "" {
+		return fmt.Errorf("no IP in the response of %s", req.URL.Host)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"media-proxy-go/pkg/types"
+)
+
+// sseHeartbeatInterval keeps idle event streams alive through proxies.
+const sseHeartbeatInterval = 15 * time.Second
+
+// handleEvents streams application events to the dashboard as Server-Sent Events.
+// Each event's SSE name is its type (recording.started, server.stats, ...).
+// A server.stats snapshot is sent on connect and every EVENTS_STATS_INTERVAL.
+func (h *Handlers) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.writeError(w, r, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	// The event stream outlives the server write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		h.log.Debug("failed to clear write deadline", "error", err)
+	}
+
+	events, unsubscribe := h.ctx.Events.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	w.WriteHeader(http.StatusOK)
+
+	interval := h.ctx.Config.EventsStatsInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	statsTicker := time.NewTicker(interval)
+	defer statsTicker.Stop()
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	if err := h.writeEvent(w, h.statsEvent()); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			err = h.writeEvent(w, event)
+		case <-statsTicker.C:
+			err = h.writeEvent(w, h.statsEvent())
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		}
+		if err != nil {
+			h.log.Debug("event stream closed", "error", err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes a single SSE message.
+func (h *Handlers) writeEvent(w http.ResponseWriter, event types.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// statsEvent builds a server.stats event from current activity.
+func (h *Handlers) statsEvent() types.Event {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := &types.ServerStats{
+		UptimeSeconds:    int64(time.Since(h.startedAt).Seconds()),
+		Goroutines:       runtime.NumGoroutine(),
+		MemoryBytes:      mem.Alloc,
+		ActiveStreams:    h.activeStreams(),
+		ActiveRecordings: []*types.Recording{},
+	}
+	if h.ctx.RecordingManager != nil {
+		if active, err := h.ctx.RecordingManager.ListActiveRecordings(); err == nil {
+			stats.ActiveRecordings = active
+		}
+	}
+
+	return types.Event{
+		Type:      types.EventServerStats,
+		Timestamp: time.Now().Unix(),
+		Stats:     stats,
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"sync"
+
+	"media-proxy-go/pkg/i18n"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/services"
+	"media-proxy-go/pkg/types"
+)
+
+// maxBatchExtract bounds the URLs of one batch extraction request.
+const maxBatchExtract = 1000
+
+// batchExtractResult is the outcome of one URL of a batch extraction.
+type batchExtractResult struct {
+	URL    string               `json:"url"`
+	Status string               `json:"status"` // "ok" or "error"
+	Result *types.ExtractResult `json:"result,omitempty"`
+	Error  string               `json:"error,omitempty"`
+}
+
+// handleExtractBatch extracts a list of URLs, EXTRACT_BATCH_WORKERS at a
+// time, for playlist builders resolving many links at once. JSON body:
+// {"urls": [...], "host": "", "headers": {}, "force": false}; host, headers
+// and force apply to every URL. Results come back in the order of the URLs,
+// each with its own status.
+func (h *Handlers) handleExtractBatch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URLs    []string          `json:"urls"`
+		Host    string            `json:"host"`
+		Headers map[string]string `json:"headers"`
+		Force   bool              `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if len(req.URLs) == 0 {
+		h.writeError(w, r, http.StatusBadRequest, "urls required")
+		return
+	}
+	if len(req.URLs) > maxBatchExtract {
+		h.writeError(w, r, http.StatusRequestEntityTooLarge, "too many urls")
+		return
+	}
+
+	opts := interfaces.ExtractOptions{Headers: req.Headers, ForceRefresh: req.Force, Host: req.Host}
+	if opts.Headers == nil {
+		opts.Headers = make(map[string]string)
+	}
+	results := h.extractBatch(r.Context(), req.URLs, opts)
+	lang := h.lang(r)
+	for i := range results {
+		results[i].Error = i18n.Translate(lang, results[i].Error)
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+// extractBatch extracts urls with a pool of workers and returns the results
+// in the same order. URLs not started when ctx ends fail with its error.
+func (h *Handlers) extractBatch(ctx context.Context, urls []string, opts interfaces.ExtractOptions) []batchExtractResult {
+	workers := max(h.ctx.Config.ExtractBatchWorkers, 1)
+	results := make([]batchExtractResult, len(urls))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(urls)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = h.extractOne(ctx, urls[i], opts)
+			}
+		}()
+	}
+
+	for i, u := range urls {
+		if ctx.Err() != nil {
+			results[i] = batchExtractResult{URL: u, Status: "error", Error: ctx.Err().Error()}
+			continue
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// extractOne extracts a URL of a batch.
+func (h *Handlers) extractOne(ctx context.Context, u string, opts interfaces.ExtractOptions) batchExtractResult {
+	if u == "" {
+		return batchExtractResult{Status: "error", Error: "url parameter required"}
+	}
+	// Extractors may add to the headers
+	opts.Headers = maps.Clone(opts.Headers)
+	result, err := h.ctx.ProxyService.HandleExtract(ctx, u, opts)
+	if err != nil {
+		var unknownHost *services.UnknownHostError
+		if !errors.As(err, &unknownHost) {
+			h.log.Warn("batch extraction failed", "url", u, "error", err)
+		}
+		return batchExtractResult{URL: u, Status: "error", Error: err.Error()}
+	}
+	return batchExtractResult{URL: u, Status: "ok", Result: result}
+}
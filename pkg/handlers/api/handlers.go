@@ -5,65 +5,68 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/pprof"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"media-proxy-go/pkg/appctx"
+	"media-proxy-go/pkg/bufpool"
+	"media-proxy-go/pkg/config"
 	"media-proxy-go/pkg/crypto"
 	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/i18n"
 	"media-proxy-go/pkg/interfaces"
 	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/middleware"
+	"media-proxy-go/pkg/services"
+	"media-proxy-go/pkg/shorturl"
+	"media-proxy-go/pkg/tracing"
 	"media-proxy-go/pkg/types"
+	"media-proxy-go/pkg/urlutil"
+	"media-proxy-go/pkg/web"
+	"media-proxy-go/pkg/workpool"
 )
 
 // Handlers contains all API handlers.
 type Handlers struct {
 	ctx *appctx.Context
 	log *logging.Logger
+
+	keys      *keyCache       // HLS AES-128 keys fetched via /key
+	inits     *initCache      // fMP4 init segments of the decrypt path
+	decrypts  *workpool.Pool  // Bounds concurrent /decrypt jobs, nil for no limit
+	remuxes   *remuxPool      // Warm FFmpeg processes for remuxing to TS, nil if disabled
+	shortURLs *shorturl.Store // Targets of the short URLs in playlists, nil if disabled
+	startedAt time.Time
 }
 
 // NewHandlers creates a new Handlers instance.
 func NewHandlers(ctx *appctx.Context) *Handlers {
 	return &Handlers{
-		ctx: ctx,
-		log: ctx.Log.WithComponent("api"),
+		ctx:       ctx,
+		log:       ctx.Log.WithComponent("api"),
+		keys:      newKeyCache(),
+		inits:     newInitCache(ctx.Config.InitCacheSize),
+		decrypts:  newDecryptPool(ctx.Config),
+		remuxes:   newRemuxPool(ctx.Config.FFmpegPath, ctx.Config.RemuxPoolSize, ctx.Log.WithComponent("api")),
+		shortURLs: newShortURLs(ctx.Config.ShortURLs, ctx.Config.ShortURLTTL),
+		startedAt: time.Now(),
 	}
 }
 
-// checkPassword verifies the API password if one is configured.
+// checkPassword verifies the password the route class of the request
+// requires (see middleware.RouteClass), if one is configured.
 // Returns true if authentication passes, false otherwise.
 func (h *Handlers) checkPassword(r *http.Request) bool {
-	configuredPassword := h.ctx.Config.APIPassword
-	if configuredPassword == "" {
-		return true // No password configured, allow access
-	}
-
-	// Check query parameter
-	if r.URL.Query().Get("api_password") == configuredPassword {
-		return true
-	}
-
-	// Check Authorization header (Bearer token)
-	authHeader := r.Header.Get("Authorization")
-	if strings.HasPrefix(authHeader, "Bearer ") {
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		if token == configuredPassword {
-			return true
-		}
-	}
-
-	// Check X-API-Password header
-	if r.Header.Get("X-API-Password") == configuredPassword {
-		return true
-	}
-
-	return false
+	return middleware.Authorized(h.ctx.Config, r, middleware.RouteClass(r.URL.Path))
 }
 
 // requireAuth wraps a handler with authentication check.
@@ -71,7 +74,7 @@ func (h *Handlers) requireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if !h.checkPassword(r) {
 			h.log.Warn("unauthorized access attempt", "path", r.URL.Path, "remote", r.RemoteAddr)
-			h.writeError(w, http.StatusUnauthorized, "Unauthorized: Invalid API Password")
+			h.writeError(w, r, http.StatusUnauthorized, "Unauthorized: Invalid API Password")
 			return
 		}
 		next(w, r)
@@ -85,25 +88,53 @@ func (h *Handlers) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /info", h.handleInfo)
 	mux.HandleFunc("GET /api/info", h.handleAPIInfo)
 	mux.HandleFunc("GET /favicon.ico", h.handleFavicon)
+	mux.Handle("GET "+web.StaticPrefix, web.StaticHandler())
 	mux.HandleFunc("GET /proxy/ip", h.handleIP)
 
 	// Proxy routes (protected by API password if configured)
-	mux.HandleFunc("GET /proxy/manifest.m3u8", h.requireAuth(h.handleProxyManifest))
-	mux.HandleFunc("GET /proxy/hls/manifest.m3u8", h.requireAuth(h.handleProxyHLS))
-	mux.HandleFunc("GET /proxy/mpd/manifest.m3u8", h.requireAuth(h.handleProxyMPD))
-	mux.HandleFunc("GET /proxy/stream", h.requireAuth(h.handleProxyStream))
+	mux.HandleFunc("GET /proxy/manifest.m3u8", h.requireAuth(h.trackStream(true, h.handleProxyManifest)))
+	mux.HandleFunc("GET /proxy/hls/manifest.m3u8", h.requireAuth(h.trackStream(true, h.handleProxyHLS)))
+	mux.HandleFunc("GET /proxy/mpd/manifest.m3u8", h.requireAuth(h.trackStream(true, h.handleProxyMPD)))
+	mux.HandleFunc("GET /proxy/stream", h.requireAuth(h.trackStream(false, h.handleProxyStream)))
+	// MediaFlow-Proxy names: file name in the path, link generation
+	mux.HandleFunc("GET /proxy/stream/{filename}", h.requireAuth(h.trackStream(false, h.handleProxyStream)))
+	mux.HandleFunc("POST /generate_url", h.handleGenerateURL)
+	mux.HandleFunc("POST /generate_urls", h.handleGenerateURLs)
+
+	// Browser player
+	mux.HandleFunc("GET /play", h.requireAuth(h.handlePlay))
 
 	// Segment routes (for MPD-to-HLS conversion)
-	mux.HandleFunc("GET /proxy/hls/segment.ts", h.requireAuth(h.handleProxyStream))
-	mux.HandleFunc("GET /proxy/hls/segment.m4s", h.requireAuth(h.handleProxyStream))
-	mux.HandleFunc("GET /proxy/hls/segment.mp4", h.requireAuth(h.handleProxyStream))
-	mux.HandleFunc("GET /segment/{filename}", h.requireAuth(h.handleSegment))
-	mux.HandleFunc("GET /decrypt/segment.ts", h.requireAuth(h.handleDecryptSegment))
-	mux.HandleFunc("GET /decrypt/segment.mp4", h.requireAuth(h.handleDecryptSegment))
+	mux.HandleFunc("GET /proxy/hls/segment.ts", h.requireAuth(h.trackStream(false, h.handleProxyStream)))
+	mux.HandleFunc("GET /proxy/hls/segment.m4s", h.requireAuth(h.trackStream(false, h.handleProxyStream)))
+	mux.HandleFunc("GET /proxy/hls/segment.mp4", h.requireAuth(h.trackStream(false, h.handleProxyStream)))
+	mux.HandleFunc("GET /segment/{filename}", h.requireAuth(h.trackStream(false, h.handleSegment)))
+	mux.HandleFunc("GET /decrypt/segment.ts", h.requireAuth(h.trackStream(false, h.handleDecryptSegment)))
+	mux.HandleFunc("GET /decrypt/segment.mp4", h.requireAuth(h.trackStream(false, h.handleDecryptSegment)))
+	if h.shortURLs != nil {
+		mux.HandleFunc("GET "+shortURLPrefix+"{id}/{name}", h.requireAuth(h.handleShortURL(mux)))
+	}
 
 	// Extractor routes
 	mux.HandleFunc("GET /extractor", h.handleExtractor)
 	mux.HandleFunc("GET /extractor/video", h.handleExtractor)
+	mux.HandleFunc("POST /api/extract/batch", h.requireAuth(h.handleExtractBatch))
+
+	// Stream probing (tracks, codecs, DRM)
+	mux.HandleFunc("GET /api/probe", h.requireAuth(h.handleProbe))
+
+	// Transport route dry run
+	mux.HandleFunc("GET /api/routes/test", h.requireAuth(h.handleRoutesTest))
+
+	// Extractor and transport route pacing counters
+	mux.HandleFunc("GET /api/stats/throttle", h.requireAuth(h.handleThrottleStats))
+
+	// User-Agent profile of upstream requests
+	mux.HandleFunc("GET /api/useragent", h.requireAuth(h.handleGetUAProfile))
+	mux.HandleFunc("PUT /api/useragent", h.requireAuth(h.handleSetUAProfile))
+
+	// File-host downloads (resumable)
+	mux.HandleFunc("GET /download", h.requireAuth(h.trackStream(true, h.handleDownload)))
 
 	// License routes
 	mux.HandleFunc("GET /license", h.handleLicense)
@@ -111,18 +142,106 @@ func (h *Handlers) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /key", h.handleKey)
 
 	// FFmpeg stream routes
-	mux.HandleFunc("GET /ffmpeg_stream/{streamID}/{filename}", h.handleFFmpegStream)
+	mux.HandleFunc("GET /ffmpeg_stream/{streamID}/{filename}", h.trackStream(false, h.handleFFmpegStream))
+	if h.ctx.Transcoder != nil {
+		mux.HandleFunc("GET /transcode", h.requireAuth(h.handleTranscode))
+		mux.HandleFunc("GET /api/transcode/profiles", h.requireAuth(h.handleListTranscodeProfiles))
+	}
+
+	// VOD segment cache
+	if h.ctx.ProxyService != nil && h.ctx.ProxyService.SegmentCache() != nil {
+		mux.HandleFunc("GET /api/cache/segments", h.requireAuth(h.handleSegmentCacheStats))
+		mux.HandleFunc("DELETE /api/cache/segments", h.requireAuth(h.handlePurgeSegmentCache))
+	}
+
+	// Live dashboard events
+	if h.ctx.Events != nil {
+		mux.HandleFunc("GET /api/events", h.requireAuth(h.handleEvents))
+	}
+
+	// Session routes
+	if h.ctx.Sessions != nil {
+		mux.HandleFunc("GET /api/sessions", h.requireAuth(h.handleListSessions))
+		mux.HandleFunc("DELETE /api/sessions/{id}", h.requireAuth(h.handleTerminateSession))
+	}
+
+	// Per-stream statistics
+	if h.ctx.StreamStats != nil {
+		mux.HandleFunc("GET /api/stats/streams", h.requireAuth(h.handleListStreamStats))
+		mux.HandleFunc("DELETE /api/stats/streams", h.requireAuth(h.handleResetStreamStats))
+	}
+
+	// Profiling and runtime diagnostics
+	if h.ctx.Config.DebugEndpoints {
+		mux.HandleFunc("GET /debug/pprof/", h.requireAuth(pprof.Index))
+		mux.HandleFunc("GET /debug/pprof/cmdline", h.requireAuth(pprof.Cmdline))
+		mux.HandleFunc("GET /debug/pprof/profile", h.requireAuth(pprof.Profile))
+		mux.HandleFunc("GET /debug/pprof/symbol", h.requireAuth(pprof.Symbol))
+		mux.HandleFunc("POST /debug/pprof/symbol", h.requireAuth(pprof.Symbol))
+		mux.HandleFunc("GET /debug/pprof/trace", h.requireAuth(pprof.Trace))
+		mux.HandleFunc("GET /api/debug/runtime", h.requireAuth(h.handleDebugRuntime))
+	}
+
+	// Channel and playlist routes
+	if h.ctx.Channels != nil {
+		mux.HandleFunc("GET /api/channels", h.requireAuth(h.handleListChannels))
+		mux.HandleFunc("GET /api/channels/groups", h.requireAuth(h.handleListChannelGroups))
+		mux.HandleFunc("GET /api/channels/{id}", h.requireAuth(h.handleGetChannel))
+		mux.HandleFunc("POST /api/channels", h.requireAuth(h.handleCreateChannel))
+		mux.HandleFunc("PUT /api/channels/{id}", h.requireAuth(h.handleUpdateChannel))
+		mux.HandleFunc("DELETE /api/channels/{id}", h.requireAuth(h.handleDeleteChannel))
+		mux.HandleFunc("POST /api/playlist/import", h.requireAuth(h.handlePlaylistImport))
+		mux.HandleFunc("GET /playlist.m3u", h.requireAuth(h.handlePlaylistM3U))
+	}
+
+	// Channel health monitor routes
+	if h.ctx.Health != nil {
+		mux.HandleFunc("GET /api/health/channels", h.requireAuth(h.handleListChannelHealth))
+		mux.HandleFunc("GET /api/health/channels/{id}", h.requireAuth(h.handleGetChannelHealth))
+		mux.HandleFunc("POST /api/health/channels/check", h.requireAuth(h.handleCheckChannelHealth))
+	}
+
+	// Extraction rules bundle routes
+	if h.ctx.Rules != nil {
+		mux.HandleFunc("GET /api/rules", h.requireAuth(h.handleRulesStatus))
+		mux.HandleFunc("POST /api/rules/reload", h.requireAuth(h.handleReloadRules))
+	}
+
+	// ClearKey key store routes
+	if h.ctx.Keys != nil {
+		mux.HandleFunc("GET /api/keys", h.requireAuth(h.handleListKeys))
+		mux.HandleFunc("GET /api/keys/{kid}", h.requireAuth(h.handleGetKey))
+		mux.HandleFunc("POST /api/keys", h.requireAuth(h.handleSetKey))
+		mux.HandleFunc("PUT /api/keys/{kid}", h.requireAuth(h.handleSetKey))
+		mux.HandleFunc("DELETE /api/keys/{kid}", h.requireAuth(h.handleDeleteKey))
+	}
+
+	// Vavoo catalog routes
+	if h.ctx.VavooCatalog != nil {
+		mux.HandleFunc("GET /api/vavoo/channels", h.requireAuth(h.handleVavooChannels))
+		if h.ctx.Channels != nil {
+			mux.HandleFunc("POST /api/vavoo/import", h.requireAuth(h.handleVavooImport))
+		}
+	}
 
 	// Recording routes (if DVR enabled)
 	if h.ctx.RecordingManager != nil {
 		mux.HandleFunc("GET /api/recordings", h.handleListRecordings)
 		mux.HandleFunc("GET /api/recordings/active", h.handleListActiveRecordings)
+		mux.HandleFunc("GET /api/recordings/volumes", h.handleListVolumes)
 		mux.HandleFunc("GET /api/recordings/{id}", h.handleGetRecording)
+		mux.HandleFunc("PATCH /api/recordings/{id}", h.handleUpdateRecording)
 		mux.HandleFunc("POST /api/recordings/start", h.handleStartRecording)
+		mux.HandleFunc("POST /api/recordings/import", h.handleImportRecordings)
 		mux.HandleFunc("POST /api/recordings/{id}/stop", h.handleStopRecording)
 		mux.HandleFunc("GET /api/recordings/{id}/stream", h.handleRecordingStream)
 		mux.HandleFunc("GET /api/recordings/{id}/download", h.handleRecordingDownload)
-		mux.HandleFunc("GET /api/recordings/{id}/delete", h.handleDeleteRecordingGet) // GET-based delete for Stremio
+		mux.HandleFunc("GET /api/recordings/{id}/health", h.handleRecordingHealth)
+		mux.HandleFunc("GET /api/recordings/{id}/chapters", h.handleRecordingChapters)
+		mux.HandleFunc("POST /api/recordings/{id}/chapters", h.handleDetectChapters)
+		mux.HandleFunc("GET /api/recordings/{id}/subtitles", h.handleListSubtitles)
+		mux.HandleFunc("POST /api/recordings/{id}/subtitles", h.handleAddSubtitle)
+		mux.HandleFunc("GET /api/recordings/{id}/subtitles/{sub}", h.handleSubtitleFile)
 		mux.HandleFunc("DELETE /api/recordings/{id}", h.handleDeleteRecording)
 		mux.HandleFunc("DELETE /api/recordings/all", h.handleDeleteAllRecordings)
 		mux.HandleFunc("GET /record", h.handleRecord)
@@ -133,385 +252,21 @@ func (h *Handlers) RegisterRoutes(mux *http.ServeMux) {
 // handleIndex serves the main dashboard.
 func (h *Handlers) handleIndex(w http.ResponseWriter, r *http.Request) {
 	dvrEnabled := h.ctx.RecordingManager != nil
-	stremioEnabled := h.ctx.Config.StremioEnabled && dvrEnabled
-
-	w.Header().Set("Content-Type", "text/html")
-	fmt.Fprintf(w, `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>MediaProxy</title>
-    <style>
-        :root {
-            --bg-primary: #0f0f0f;
-            --bg-secondary: #1a1a1a;
-            --bg-card: #242424;
-            --bg-input: #2a2a2a;
-            --text-primary: #ffffff;
-            --text-secondary: #a0a0a0;
-            --accent: #3b82f6;
-            --accent-hover: #2563eb;
-            --success: #22c55e;
-            --danger: #ef4444;
-            --warning: #f59e0b;
-            --border: #333333;
-            --stremio: #7b2cbf;
-        }
-        * { box-sizing: border-box; margin: 0; padding: 0; }
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, sans-serif;
-            background: var(--bg-primary);
-            color: var(--text-primary);
-            min-height: 100vh;
-            line-height: 1.6;
-        }
-        .container { max-width: 1000px; margin: 0 auto; padding: 40px 20px; }
-        header { text-align: center; margin-bottom: 40px; }
-        .logo { font-size: 3rem; margin-bottom: 8px; }
-        h1 {
-            font-size: 2.5rem; font-weight: 700; margin-bottom: 8px;
-            background: linear-gradient(135deg, var(--accent) 0%%, #8b5cf6 100%%);
-            -webkit-background-clip: text; -webkit-text-fill-color: transparent; background-clip: text;
-        }
-        .status {
-            display: inline-flex; align-items: center; gap: 8px;
-            background: rgba(34, 197, 94, 0.1); color: var(--success);
-            padding: 8px 16px; border-radius: 20px; font-size: 0.9rem; font-weight: 500;
-        }
-        .status::before {
-            content: ''; width: 8px; height: 8px; background: var(--success);
-            border-radius: 50%%; animation: pulse 2s infinite;
-        }
-        @keyframes pulse { 0%%, 100%% { opacity: 1; } 50%% { opacity: 0.5; } }
-        .nav { display: flex; gap: 12px; justify-content: center; margin-bottom: 32px; flex-wrap: wrap; }
-        .nav a {
-            display: inline-flex; align-items: center; gap: 8px; padding: 10px 20px;
-            background: var(--bg-card); border: 1px solid var(--border); border-radius: 8px;
-            color: var(--text-primary); text-decoration: none; font-size: 0.9rem; transition: all 0.2s;
-        }
-        .nav a:hover { border-color: var(--accent); background: var(--bg-secondary); }
-        .nav a.stremio:hover { border-color: var(--stremio); }
-        .section {
-            background: var(--bg-secondary); border-radius: 16px;
-            padding: 24px; margin-bottom: 24px;
-        }
-        .section-header {
-            display: flex; justify-content: space-between; align-items: center; margin-bottom: 20px;
-        }
-        .section h2 { font-size: 1.25rem; font-weight: 600; color: var(--text-primary); }
-        .badge {
-            background: var(--bg-card); padding: 4px 12px; border-radius: 12px;
-            font-size: 0.8rem; color: var(--text-secondary);
-        }
-        .form-row { display: flex; gap: 12px; margin-bottom: 16px; }
-        .form-row input {
-            flex: 1; padding: 12px 16px; background: var(--bg-input); border: 1px solid var(--border);
-            border-radius: 8px; color: var(--text-primary); font-size: 0.95rem;
-        }
-        .form-row input:focus { outline: none; border-color: var(--accent); }
-        .form-row input::placeholder { color: var(--text-secondary); }
-        .btn {
-            padding: 12px 24px; border: none; border-radius: 8px; font-size: 0.95rem;
-            font-weight: 500; cursor: pointer; transition: all 0.2s; display: inline-flex;
-            align-items: center; gap: 8px;
-        }
-        .btn-primary { background: var(--accent); color: white; }
-        .btn-primary:hover { background: var(--accent-hover); }
-        .btn-danger { background: var(--danger); color: white; }
-        .btn-danger:hover { background: #dc2626; }
-        .btn-sm { padding: 6px 12px; font-size: 0.8rem; }
-        .btn:disabled { opacity: 0.5; cursor: not-allowed; }
-        .recordings-list { display: flex; flex-direction: column; gap: 12px; }
-        .recording {
-            display: flex; align-items: center; gap: 16px; padding: 16px;
-            background: var(--bg-card); border-radius: 10px; border: 1px solid var(--border);
-        }
-        .recording-icon { font-size: 1.5rem; }
-        .recording-info { flex: 1; min-width: 0; }
-        .recording-name { font-weight: 600; margin-bottom: 4px; word-break: break-word; }
-        .recording-meta { font-size: 0.85rem; color: var(--text-secondary); display: flex; gap: 16px; flex-wrap: wrap; }
-        .recording-actions { display: flex; gap: 8px; flex-shrink: 0; }
-        .status-recording { color: var(--danger); }
-        .status-completed { color: var(--success); }
-        .status-failed { color: var(--warning); }
-        .empty-state { text-align: center; padding: 40px; color: var(--text-secondary); }
-        .empty-state span { font-size: 3rem; display: block; margin-bottom: 12px; }
-        .toast {
-            position: fixed; bottom: 24px; right: 24px; padding: 16px 24px;
-            background: var(--bg-card); border: 1px solid var(--border); border-radius: 10px;
-            box-shadow: 0 4px 20px rgba(0,0,0,0.3); display: none; z-index: 1000;
-        }
-        .toast.success { border-color: var(--success); }
-        .toast.error { border-color: var(--danger); }
-        .hidden { display: none; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <header>
-            <div class="logo">📡</div>
-            <h1>MediaProxy</h1>
-            <div class="status">Server Running</div>
-        </header>
-
-        <nav class="nav">
-            <a href="/api/info">📊 API Status</a>
-            <a href="/proxy/ip">🌐 Public IP</a>
-            %s
-        </nav>
-
-        %s
-
-        <div class="section">
-            <h2>API Endpoints</h2>
-            <div class="recordings-list" style="margin-top: 16px;">
-                <div class="recording">
-                    <span style="background:var(--accent);color:white;padding:2px 8px;border-radius:4px;font-size:0.75rem;font-weight:600;">GET</span>
-                    <div class="recording-info">
-                        <div class="recording-name" style="font-family:monospace;font-size:0.9rem;">/proxy/manifest.m3u8?url=...</div>
-                        <div class="recording-meta">Proxy HLS/MPD streams</div>
-                    </div>
-                </div>
-                <div class="recording">
-                    <span style="background:var(--accent);color:white;padding:2px 8px;border-radius:4px;font-size:0.75rem;font-weight:600;">GET</span>
-                    <div class="recording-info">
-                        <div class="recording-name" style="font-family:monospace;font-size:0.9rem;">/extractor?url=...</div>
-                        <div class="recording-meta">Extract stream URLs from platforms</div>
-                    </div>
-                </div>
-            </div>
-        </div>
-    </div>
-
-    <div class="toast" id="toast"></div>
-
-    %s
-</body>
-</html>`,
-		// Stremio nav link
-		func() string {
-			if stremioEnabled {
-				return `<a href="/stremio" class="stremio">📼 Stremio Addon</a>`
-			}
-			return ""
-		}(),
-		// DVR section
-		func() string {
-			if !dvrEnabled {
-				return ""
-			}
-			return `
-        <div class="section">
-            <div class="section-header">
-                <h2>📹 Start Recording</h2>
-            </div>
-            <form id="recordForm" onsubmit="startRecording(event)">
-                <div class="form-row">
-                    <input type="text" id="recordUrl" placeholder="Stream URL (HLS/MPD)" required>
-                    <input type="text" id="recordName" placeholder="Recording name" style="max-width: 200px;">
-                    <button type="submit" class="btn btn-primary">Record</button>
-                </div>
-            </form>
-        </div>
-
-        <div class="section">
-            <div class="section-header">
-                <h2>🔴 Active Recordings</h2>
-                <span class="badge" id="activeCount">0</span>
-            </div>
-            <div class="recordings-list" id="activeRecordings">
-                <div class="empty-state"><span>📭</span>No active recordings</div>
-            </div>
-        </div>
-
-        <div class="section">
-            <div class="section-header">
-                <h2>📁 Completed Recordings</h2>
-                <span class="badge" id="completedCount">0</span>
-            </div>
-            <div class="recordings-list" id="completedRecordings">
-                <div class="empty-state"><span>📭</span>No completed recordings</div>
-            </div>
-        </div>`
-		}(),
-		// JavaScript
-		func() string {
-			if !dvrEnabled {
-				return ""
-			}
-			return `
-    <script>
-        // Store active recordings for real-time elapsed updates
-        let activeRecordingsData = [];
-
-        function showToast(msg, type) {
-            const t = document.getElementById('toast');
-            t.textContent = msg;
-            t.className = 'toast ' + type;
-            t.style.display = 'block';
-            setTimeout(() => t.style.display = 'none', 3000);
-        }
-
-        function formatSize(bytes) {
-            if (!bytes) return '0 B';
-            const units = ['B', 'KB', 'MB', 'GB'];
-            let i = 0;
-            while (bytes >= 1024 && i < units.length - 1) { bytes /= 1024; i++; }
-            return bytes.toFixed(1) + ' ' + units[i];
-        }
-
-        function formatElapsed(seconds) {
-            if (!seconds || seconds < 0) seconds = 0;
-            const h = Math.floor(seconds / 3600);
-            const m = Math.floor((seconds % 3600) / 60);
-            const s = Math.floor(seconds % 60);
-            if (h > 0) return h + 'h ' + m.toString().padStart(2, '0') + 'm ' + s.toString().padStart(2, '0') + 's';
-            return m + 'm ' + s.toString().padStart(2, '0') + 's';
-        }
-
-        function formatDuration(seconds) {
-            if (!seconds) return '';
-            const h = Math.floor(seconds / 3600);
-            const m = Math.floor((seconds % 3600) / 60);
-            return h > 0 ? h + 'h ' + m + 'm' : m + 'm';
-        }
-
-        function formatDate(ts) {
-            if (!ts) return '';
-            return new Date(ts * 1000).toLocaleString();
-        }
-
-        async function fetchRecordings() {
-            try {
-                const [all, active] = await Promise.all([
-                    fetch('/api/recordings').then(r => r.json()),
-                    fetch('/api/recordings/active').then(r => r.json())
-                ]);
-                activeRecordingsData = active || [];
-                renderRecordings(all || [], activeRecordingsData);
-            } catch (e) { console.error('Failed to fetch recordings:', e); }
-        }
-
-        function renderRecordings(all, active) {
-            const activeIds = new Set((active || []).map(r => r.id));
-            const completed = (all || []).filter(r => !activeIds.has(r.id) && (r.status === 'completed' || r.status === 'failed'));
-
-            document.getElementById('activeCount').textContent = active.length;
-            document.getElementById('completedCount').textContent = completed.length;
-
-            const activeEl = document.getElementById('activeRecordings');
-            const completedEl = document.getElementById('completedRecordings');
-
-            if (active.length === 0) {
-                activeEl.innerHTML = '<div class="empty-state"><span>📭</span>No active recordings</div>';
-            } else {
-                activeEl.innerHTML = active.map(r => ` + "`" + `
-                    <div class="recording" data-id="${r.id}" data-started="${r.started_at}">
-                        <span class="recording-icon">🔴</span>
-                        <div class="recording-info">
-                            <div class="recording-name">${r.name || 'Unnamed'}</div>
-                            <div class="recording-meta">
-                                <span class="elapsed" title="Elapsed time">⏱ ${formatElapsed(Math.floor(Date.now()/1000) - r.started_at)}</span>
-                                <span class="filesize" title="File size">💾 ${formatSize(r.file_size)}</span>
-                            </div>
-                        </div>
-                        <div class="recording-actions">
-                            <button class="btn btn-danger btn-sm" onclick="stopRecording('${r.id}')">Stop</button>
-                        </div>
-                    </div>
-                ` + "`" + `).join('');
-            }
-
-            if (completed.length === 0) {
-                completedEl.innerHTML = '<div class="empty-state"><span>📭</span>No completed recordings</div>';
-            } else {
-                completedEl.innerHTML = completed.sort((a,b) => b.started_at - a.started_at).map(r => ` + "`" + `
-                    <div class="recording">
-                        <span class="recording-icon">✅</span>
-                        <div class="recording-info">
-                            <div class="recording-name">${r.name || 'Unnamed'}</div>
-                            <div class="recording-meta">
-                                <span title="Recorded on">${formatDate(r.started_at)}</span>
-                                <span title="Duration">⏱ ${formatDuration(r.duration)}</span>
-                                <span title="File size">💾 ${formatSize(r.file_size)}</span>
-                            </div>
-                        </div>
-                        <div class="recording-actions">
-                            <a href="/api/recordings/${r.id}/stream" target="_blank" class="btn btn-primary btn-sm">Play</a>
-                            <a href="/api/recordings/${r.id}/download" class="btn btn-sm" style="background:var(--bg-input);">Download</a>
-                            <button class="btn btn-danger btn-sm" onclick="deleteRecording('${r.id}')">Delete</button>
-                        </div>
-                    </div>
-                ` + "`" + `).join('');
-            }
-        }
-
-        // Update elapsed time every second for active recordings
-        function updateElapsedTimes() {
-            const now = Math.floor(Date.now() / 1000);
-            document.querySelectorAll('#activeRecordings .recording[data-started]').forEach(el => {
-                const started = parseInt(el.dataset.started);
-                const elapsed = now - started;
-                const elapsedEl = el.querySelector('.elapsed');
-                if (elapsedEl) {
-                    elapsedEl.textContent = '⏱ ' + formatElapsed(elapsed);
-                }
-            });
-        }
-
-        async function startRecording(e) {
-            e.preventDefault();
-            const btn = e.target.querySelector('button[type="submit"]');
-            if (btn.disabled) return; // Prevent double submission
-            btn.disabled = true;
-            btn.textContent = 'Starting...';
-            const url = document.getElementById('recordUrl').value;
-            const name = document.getElementById('recordName').value || 'recording';
-            try {
-                const res = await fetch('/api/recordings/start', {
-                    method: 'POST',
-                    headers: { 'Content-Type': 'application/json' },
-                    body: JSON.stringify({ url, name })
-                });
-                if (res.ok) {
-                    showToast('Recording started!', 'success');
-                    document.getElementById('recordUrl').value = '';
-                    document.getElementById('recordName').value = '';
-                    fetchRecordings();
-                } else {
-                    const err = await res.json();
-                    showToast('Error: ' + (err.error || 'Failed'), 'error');
-                }
-            } catch (e) { showToast('Error: ' + e.message, 'error'); }
-            finally { btn.disabled = false; btn.textContent = 'Record'; }
-        }
-
-        async function stopRecording(id) {
-            try {
-                const res = await fetch('/api/recordings/' + id + '/stop', { method: 'POST' });
-                if (res.ok) { showToast('Recording stopped', 'success'); fetchRecordings(); }
-                else {
-                    const err = await res.json().catch(() => ({}));
-                    showToast('Failed to stop: ' + (err.error || res.status), 'error');
-                }
-            } catch (e) { showToast('Error: ' + e.message, 'error'); }
-        }
-
-        async function deleteRecording(id) {
-            if (!confirm('Delete this recording?')) return;
-            try {
-                const res = await fetch('/api/recordings/' + id, { method: 'DELETE' });
-                if (res.ok) { showToast('Recording deleted', 'success'); fetchRecordings(); }
-                else { showToast('Failed to delete', 'error'); }
-            } catch (e) { showToast('Error: ' + e.message, 'error'); }
-        }
-
-        fetchRecordings();
-        setInterval(fetchRecordings, 5000);  // Refresh data every 5 seconds (updates file size)
-        setInterval(updateElapsedTimes, 1000);  // Update elapsed time every second
-    </script>`
-		}())
+	lang := h.lang(r)
+	page := web.Dashboard{
+		Features: web.DashboardFeatures{
+			DVR:      dvrEnabled,
+			Channels: h.ctx.Channels != nil,
+			Health:   h.ctx.Health != nil,
+			Stremio:  h.ctx.Config.StremioEnabled && dvrEnabled,
+			Events:   h.ctx.Events != nil,
+		},
+		Messages: i18n.Messages(lang),
+	}
+	if err := web.Render(w, "dashboard.html", lang, page); err != nil {
+		h.log.Error("❌ failed to render dashboard", "error", err)
+		h.writeError(w, r, http.StatusInternalServerError, err.Error())
+	}
 }
 
 // handleInfo serves the info page.
@@ -530,10 +285,24 @@ func (h *Handlers) handleInfo(w http.ResponseWriter, r *http.Request) {
 
 // handleAPIInfo returns server status as JSON.
 func (h *Handlers) handleAPIInfo(w http.ResponseWriter, r *http.Request) {
-	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+	info := map[string]interface{}{
 		"status":  "running",
 		"version": "1.0.0",
-	})
+	}
+	if h.ctx.Transcoder != nil {
+		info["hwaccel"] = h.ctx.Transcoder.HWAccel()
+	}
+	if h.ctx.FFmpeg != nil {
+		info["ffmpeg"] = h.ctx.FFmpeg
+	}
+	// What actually started, which also depends on config and startup errors
+	info["features"] = map[string]bool{
+		types.FeatureDecryptRemux: h.ctx.FFmpegSupports(types.FeatureDecryptRemux),
+		types.FeatureRecording:    h.ctx.RecordingManager != nil,
+		types.FeatureTranscode:    h.ctx.Transcoder != nil,
+		types.FeatureHDHomeRun:    h.ctx.Config.HDHomeRunEnabled && h.ctx.FFmpegSupports(types.FeatureHDHomeRun),
+	}
+	h.writeJSON(w, http.StatusOK, info)
 }
 
 // handleFavicon serves the favicon.
@@ -541,37 +310,49 @@ func (h *Handlers) handleFavicon(w http.ResponseWriter, r *http.Request) {
 	http.NotFound(w, r)
 }
 
-// handleIP returns the server's public IP.
-func (h *Handlers) handleIP(w http.ResponseWriter, r *http.Request) {
-	resp, err := http.Get("https://api.ipify.org")
-	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "failed to get IP")
-		return
-	}
-	defer resp.Body.Close()
-
-	ip, _ := io.ReadAll(resp.Body)
-	h.writeJSON(w, http.StatusOK, map[string]string{"ip": string(ip)})
-}
-
 // handleProxyManifest handles the main proxy endpoint.
 func (h *Handlers) handleProxyManifest(w http.ResponseWriter, r *http.Request) {
 	req := h.parseStreamRequest(r)
 	if req.URL == "" {
-		h.writeError(w, http.StatusBadRequest, "url parameter required")
+		h.writeError(w, r, http.StatusBadRequest, "url parameter required")
 		return
 	}
 
 	h.log.Debug("proxy manifest request", "url", req.URL)
 
+	// Forward validators so polling players get 304s for unchanged live playlists
+	for _, name := range []string{"If-None-Match", "If-Modified-Since"} {
+		if v := r.Header.Get(name); v != "" {
+			if req.Validators == nil {
+				req.Validators = make(map[string]string)
+			}
+			req.Validators[name] = v
+		}
+	}
+
 	resp, err := h.ctx.ProxyService.HandleManifest(r.Context(), req)
 	if err != nil {
 		h.log.Error("❌ proxy manifest failed", "url", req.URL, "error", err)
-		h.writeError(w, http.StatusInternalServerError, err.Error())
+		h.writeError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	h.writeStreamResponse(w, r, resp)
+	if err := h.shortenManifest(r, resp); err != nil {
+		h.log.Error("❌ proxy manifest failed", "url", req.URL, "error", err)
+		h.writeError(w, r, http.StatusBadGateway, err.Error())
+		return
+	}
+	if err := h.encryptManifest(r, resp); err != nil {
+		h.log.Error("❌ proxy manifest failed", "url", req.URL, "error", err)
+		h.writeError(w, r, http.StatusBadGateway, err.Error())
+		return
+	}
+	if err := h.signManifest(r, resp); err != nil {
+		h.log.Error("❌ proxy manifest failed", "url", req.URL, "error", err)
+		h.writeError(w, r, http.StatusBadGateway, err.Error())
+		return
+	}
+	h.writeManifestResponse(w, r, resp)
 }
 
 // handleProxyHLS handles explicit HLS proxy requests.
@@ -588,16 +369,23 @@ func (h *Handlers) handleProxyMPD(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) handleProxyStream(w http.ResponseWriter, r *http.Request) {
 	req := h.parseStreamRequest(r)
 	if req.URL == "" {
-		h.writeError(w, http.StatusBadRequest, "url parameter required")
+		h.writeError(w, r, http.StatusBadRequest, "url parameter required")
 		return
 	}
 
 	h.log.Debug("proxy stream request", "url", req.URL)
 
+	// Forward range requests (EXT-X-BYTERANGE segments, seeking in files)
+	for _, name := range []string{"Range", "If-Range"} {
+		if v := r.Header.Get(name); v != "" {
+			req.Headers[name] = v
+		}
+	}
+
 	resp, err := h.ctx.ProxyService.HandleSegment(r.Context(), req)
 	if err != nil {
 		h.log.Error("❌ proxy stream failed", "url", req.URL, "error", err)
-		h.writeError(w, http.StatusBadGateway, err.Error())
+		h.writeError(w, r, http.StatusBadGateway, err.Error())
 		return
 	}
 
@@ -608,19 +396,20 @@ func (h *Handlers) handleProxyStream(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) handleSegment(w http.ResponseWriter, r *http.Request) {
 	baseURL := r.URL.Query().Get("base_url")
 	if baseURL == "" {
-		h.writeError(w, http.StatusBadRequest, "base_url parameter required")
+		h.writeError(w, r, http.StatusBadRequest, "base_url parameter required")
 		return
 	}
 
 	req := &types.StreamRequest{
-		URL:     baseURL,
-		Headers: httpclient.ParseHeaderParams(r.URL.Query()),
+		URL:            baseURL,
+		Headers:        httpclient.ParseHeaderParams(r.URL.Query(), h.ctx.Config.HeaderAllowlist...),
+		RedirectStream: r.URL.Query().Get("redirect_stream") == "true",
 	}
 
 	resp, err := h.ctx.ProxyService.HandleSegment(r.Context(), req)
 	if err != nil {
 		h.log.Error("❌ segment proxy failed", "url", req.URL, "error", err)
-		h.writeError(w, http.StatusBadGateway, err.Error())
+		h.writeError(w, r, http.StatusBadGateway, err.Error())
 		return
 	}
 
@@ -631,16 +420,22 @@ func (h *Handlers) handleSegment(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) handleDecryptSegment(w http.ResponseWriter, r *http.Request) {
 	segmentURL := r.URL.Query().Get("url")
 	initURL := r.URL.Query().Get("init_url")
-	keyID := r.URL.Query().Get("key_id")
-	key := r.URL.Query().Get("key")
+	keyID := crypto.NormalizeKIDs(r.URL.Query().Get("key_id"))
+	key := crypto.NormalizeKIDs(r.URL.Query().Get("key"))
 	skipDecrypt := r.URL.Query().Get("skip_decrypt") == "1"
 
 	if segmentURL == "" {
-		h.writeError(w, http.StatusBadRequest, "url parameter required")
+		h.writeError(w, r, http.StatusBadRequest, "url parameter required")
+		return
+	}
+
+	release, ok := h.acquireDecryptWorker(w, r)
+	if !ok {
 		return
 	}
+	defer release()
 
-	headers := httpclient.ParseHeaderParams(r.URL.Query())
+	headers := httpclient.ParseHeaderParams(r.URL.Query(), h.ctx.Config.HeaderAllowlist...)
 
 	h.log.Debug("🔓 decrypt segment request",
 		"segment_url", segmentURL,
@@ -650,58 +445,159 @@ func (h *Handlers) handleDecryptSegment(w http.ResponseWriter, r *http.Request)
 	)
 
 	// Fetch init and segment in parallel
-	initContent, segmentContent, err := h.fetchInitAndSegment(r.Context(), initURL, segmentURL, headers)
+	initBuf, segmentBuf, err := h.fetchInitAndSegment(r.Context(), initURL, segmentURL, headers)
 	if err != nil {
 		h.log.Error("❌ failed to fetch segments",
 			"error", err,
 			"init_url", initURL,
 			"segment_url", segmentURL,
 		)
-		h.writeError(w, http.StatusBadGateway, err.Error())
+		h.writeError(w, r, http.StatusBadGateway, err.Error())
 		return
 	}
+	defer bufpool.Put(initBuf)
+	defer bufpool.Put(segmentBuf)
+	initContent, segmentContent := initBuf.Bytes(), segmentBuf.Bytes()
 
-	var combined []byte
-	if skipDecrypt || keyID == "00000000000000000000000000000000" {
-		// Just concatenate without decryption (remux only)
-		combined = append(initContent, segmentContent...)
-	} else if keyID != "" && key != "" {
-		// Decrypt using CENC decryption
-		h.log.Debug("🔐 decrypting segment", "key_id", keyID)
-		decrypted, err := crypto.DecryptSegmentWithKeys(initContent, segmentContent, keyID, key)
-		if err != nil {
-			h.log.Error("❌ decryption failed", "error", err)
-			// Fallback to raw content
-			combined = append(initContent, segmentContent...)
-		} else {
-			combined = decrypted
-			h.log.Debug("✅ decryption successful", "output_size", len(combined))
+	// Without a key in the request, use the key store for the init segment's KIDs
+	if skipDecrypt || keyID == "" || keyID == "00000000000000000000000000000000" {
+		if storedIDs, storedKeys := h.storedKeys(initContent); storedIDs != "" {
+			h.log.Debug("🔑 using keys from key store", "key_id", storedIDs)
+			keyID, key, skipDecrypt = storedIDs, storedKeys, false
 		}
-	} else {
-		combined = append(initContent, segmentContent...)
 	}
 
-	// Remux fMP4 to TS using FFmpeg
-	tsContent, err := h.remuxToTS(r.Context(), combined)
+	combined := bufpool.Get()
+	defer bufpool.Put(combined)
+	h.decryptFragment(r.Context(), combined, initContent, segmentContent, keyID, key, skipDecrypt)
+
+	// Without a usable FFmpeg (logged at startup) players get the decrypted fMP4
+	if !h.ctx.FFmpegSupports(types.FeatureDecryptRemux) {
+		h.writeFMP4(w, combined.Bytes())
+		return
+	}
+
+	// Remux fMP4 to TS using FFmpeg, muxing in the audio track if requested
+	var tsContent *bytes.Buffer
+	if audioURLs := r.URL.Query()["audio_url"]; len(audioURLs) > 0 {
+		tsContent, err = h.muxWithAudio(r.Context(), combined.Bytes(), r.URL.Query().Get("audio_init_url"), audioURLs, headers, keyID, key, skipDecrypt)
+		if err != nil {
+			h.log.Warn("⚠️ audio mux failed, serving video only", "error", err)
+		}
+	}
+	if tsContent == nil {
+		tsContent, err = h.remuxToTS(r.Context(), combined.Bytes(), r.URL.Query().Get("keyframe") == "1")
+	}
 	if err != nil {
 		h.log.Warn("⚠️ remux failed, serving raw fMP4", "error", err)
-		// Fallback to raw fMP4
-		w.Header().Set("Content-Type", "video/mp4")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Write(combined)
+		h.writeFMP4(w, combined.Bytes())
 		return
 	}
+	defer bufpool.Put(tsContent)
 
 	w.Header().Set("Content-Type", "video/MP2T")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Cache-Control", "no-cache")
-	w.Write(tsContent)
+	w.Header().Set("Content-Length", strconv.Itoa(tsContent.Len()))
+	w.Write(tsContent.Bytes())
+}
+
+// newDecryptPool returns the worker pool of /decrypt jobs, or nil when
+// DECRYPT_WORKERS is 0.
+func newDecryptPool(cfg *config.Config) *workpool.Pool {
+	if cfg.DecryptWorkers <= 0 {
+		return nil
+	}
+	return workpool.New(cfg.DecryptWorkers, cfg.DecryptQueue)
+}
+
+// acquireDecryptWorker waits for a /decrypt worker, queued fairly with the
+// other clients' segments. When the pool stays saturated it answers 503 with
+// Retry-After, which players handle by retrying the segment.
+func (h *Handlers) acquireDecryptWorker(w http.ResponseWriter, r *http.Request) (func(), bool) {
+	if h.decrypts == nil {
+		return func() {}, true
+	}
+
+	ctx := r.Context()
+	if timeout := h.ctx.Config.DecryptQueueTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	release, err := h.decrypts.Acquire(ctx, middleware.ClientIP(r, h.ctx.Config.TrustedProxies))
+	if err == nil {
+		return release, true
+	}
+	if r.Context().Err() != nil {
+		return nil, false // Client gone
+	}
+	stats := h.decrypts.Stats()
+	h.log.Warn("⚠️ decrypt workers saturated", "error", err, "busy", stats.Busy, "queued", stats.Queued)
+	w.Header().Set("Retry-After", "2")
+	h.writeError(w, r, http.StatusServiceUnavailable, "server busy, retry later")
+	return nil, false
+}
+
+// writeFMP4 serves a decrypted fragment as-is when it can't be remuxed to TS.
+func (h *Handlers) writeFMP4(w http.ResponseWriter, content []byte) {
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Write(content)
+}
+
+// runRemux remuxes content with proc, or with a new FFmpeg process when proc
+// is nil. Errors carry FFmpeg's stderr.
+func (h *Handlers) runRemux(ctx context.Context, proc *remuxProc, args []string, content []byte) (*bytes.Buffer, error) {
+	if proc == nil {
+		var err error
+		// TS output is about the size of the fMP4 input
+		if proc, err = startRemux(h.ctx.Config.FFmpegPath, args, len(content)); err != nil {
+			return nil, err
+		}
+	}
+	stdout, err := proc.run(ctx, content)
+	if err != nil && !errors.Is(err, errRemuxStdin) {
+		err = fmt.Errorf("%w, stderr: %s", err, proc.stderr.String())
+	}
+	return stdout, err
+}
+
+// decryptFragment decrypts an init and media segment pair into dst, writing
+// them concatenated as-is when no key is given or decryption fails.
+func (h *Handlers) decryptFragment(ctx context.Context, dst *bytes.Buffer, initContent, segmentContent []byte, keyID, key string, skipDecrypt bool) {
+	if skipDecrypt || keyID == "00000000000000000000000000000000" || keyID == "" || key == "" {
+		// Just concatenate without decryption (remux only)
+		dst.Write(initContent)
+		dst.Write(segmentContent)
+		return
+	}
+
+	// Decrypt using CENC decryption
+	h.log.Debug("🔐 decrypting segment", "key_id", keyID)
+	_, span := tracing.Start(ctx, "decrypt")
+	defer span.End()
+	span.SetAttr("input.size", len(initContent)+len(segmentContent))
+
+	start := dst.Len()
+	if err := crypto.DecryptSegmentWithKeysTo(dst, initContent, segmentContent, keyID, key); err != nil {
+		h.log.Error("❌ decryption failed", "error", err)
+		span.SetError(err)
+		// Fallback to raw content
+		dst.Write(initContent)
+		dst.Write(segmentContent)
+		return
+	}
+	h.log.Debug("✅ decryption successful", "output_size", dst.Len()-start)
 }
 
-// fetchInitAndSegment fetches init and media segment in parallel.
-func (h *Handlers) fetchInitAndSegment(ctx context.Context, initURL, segmentURL string, headers map[string]string) ([]byte, []byte, error) {
+// fetchInitAndSegment fetches init and media segment in parallel. The caller
+// must hand both buffers back with bufpool.Put.
+func (h *Handlers) fetchInitAndSegment(ctx context.Context, initURL, segmentURL string, headers map[string]string) (*bytes.Buffer, *bytes.Buffer, error) {
 	type result struct {
-		data []byte
+		data *bytes.Buffer
 		err  error
 	}
 
@@ -711,10 +607,10 @@ func (h *Handlers) fetchInitAndSegment(ctx context.Context, initURL, segmentURL
 	// Fetch init segment
 	go func() {
 		if initURL == "" {
-			initCh <- result{data: []byte{}, err: nil}
+			initCh <- result{data: bufpool.Get(), err: nil}
 			return
 		}
-		data, err := h.fetchURL(ctx, initURL, headers)
+		data, err := h.fetchInit(ctx, initURL, headers)
 		initCh <- result{data: data, err: err}
 	}()
 
@@ -731,18 +627,27 @@ func (h *Handlers) fetchInitAndSegment(ctx context.Context, initURL, segmentURL
 	initData := initRes.data
 	if initRes.err != nil {
 		h.log.Warn("⚠️ init segment fetch failed, continuing without it", "error", initRes.err)
-		initData = []byte{}
+		initData = bufpool.Get()
 	}
 
 	if segRes.err != nil {
+		bufpool.Put(initData)
 		return nil, nil, fmt.Errorf("❌ failed to fetch segment: %w", segRes.err)
 	}
 
 	return initData, segRes.data, nil
 }
 
-// fetchURL fetches a URL and returns the content using the configured HTTP client.
-func (h *Handlers) fetchURL(ctx context.Context, urlStr string, headers map[string]string) ([]byte, error) {
+// fetchURL fetches a URL and returns the content using the configured HTTP
+// client, within the segment size and time limits. The content is read into
+// a pooled buffer, which the caller must hand back with bufpool.Put.
+func (h *Handlers) fetchURL(ctx context.Context, urlStr string, headers map[string]string) (*bytes.Buffer, error) {
+	if timeout := h.ctx.Config.SegmentTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
 	if err != nil {
 		return nil, err
@@ -750,18 +655,13 @@ func (h *Handlers) fetchURL(ctx context.Context, urlStr string, headers map[stri
 
 	// Set default headers for upstream requests
 	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Accept-Encoding", "identity")
+	req.Header.Set("Accept-Encoding", "identity") // Segments are already compressed; Do decodes bodies if headers override this
 
 	// Apply passed headers (these override defaults)
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
 
-	// Set default User-Agent if not provided
-	if req.Header.Get("User-Agent") == "" {
-		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-	}
-
 	// Set default Referer from URL origin if not provided (many CDNs require this)
 	if req.Header.Get("Referer") == "" {
 		if parsed, err := url.Parse(urlStr); err == nil {
@@ -794,49 +694,79 @@ func (h *Handlers) fetchURL(ctx context.Context, urlStr string, headers map[stri
 		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
-	return io.ReadAll(resp.Body)
+	return httpclient.ReadLimitedBuffer(resp.Body, h.ctx.Config.SegmentMaxSize)
 }
 
-// remuxToTS remuxes fMP4 content to MPEG-TS using FFmpeg.
-func (h *Handlers) remuxToTS(ctx context.Context, content []byte) ([]byte, error) {
+// remuxToTS remuxes fMP4 content to MPEG-TS using FFmpeg. With a decrypt
+// transcode profile configured, the content is re-encoded (on the selected
+// hardware encoder, if any) instead of stream copied. With keyframe, only
+// the keyframe the segment starts with is kept, for I-frame playlists. The
+// caller must hand the returned buffer back with bufpool.Put.
+func (h *Handlers) remuxToTS(ctx context.Context, content []byte, keyframe bool) (*bytes.Buffer, error) {
+	ctx, span := tracing.Start(ctx, "ffmpeg remux")
+	defer span.End()
+	span.SetAttr("input.size", len(content))
+
 	// Match EasyProxy's FFmpeg command exactly for compatibility
 	// -bsf:v h264_mp4toannexb: Convert H.264 to Annex B format (MPEG-TS requirement)
 	// -bsf:a aac_adtstoasc: FFmpeg applies this gracefully even for fMP4 input
-	cmd := exec.CommandContext(ctx, "ffmpeg",
-		"-y",
-		"-i", "pipe:0",
-		"-c", "copy",
-		"-copyts",
-		"-bsf:v", "h264_mp4toannexb",
-		"-bsf:a", "aac_adtstoasc",
-		"-f", "mpegts",
-		"pipe:1",
-	)
-
-	cmd.Stdin = bytes.NewReader(content)
+	var input []string
+	output := []string{"-c", "copy", "-copyts", "-bsf:v", "h264_mp4toannexb", "-bsf:a", "aac_adtstoasc"}
+	if profile := h.ctx.Config.DecryptProfile; profile != "" && h.ctx.Transcoder != nil {
+		in, out, err := h.ctx.Transcoder.EncoderArgs(profile)
+		if err != nil {
+			span.SetError(err)
+			return nil, err
+		}
+		input, output = in, append(out, "-copyts")
+		span.SetAttr("transcode.profile", profile)
+	}
+	if keyframe {
+		output = append([]string{"-map", "0:v:0", "-frames:v", "1"}, output...)
+		span.SetAttr("keyframe", true)
+	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	args := append([]string{"-y"}, input...)
+	args = append(args, "-i", "pipe:0")
+	args = append(args, output...)
+	args = append(args, "-f", "mpegts", "pipe:1")
 
-	if err := cmd.Run(); err != nil {
+	// Keyframe extraction is too rare to keep processes warm for
+	var proc *remuxProc
+	if !keyframe {
+		proc = h.remuxes.take(args)
+	}
+	span.SetAttr("ffmpeg.warm", proc != nil)
+	stdout, err := h.runRemux(ctx, proc, args, content)
+	if errors.Is(err, errRemuxStdin) && proc != nil {
+		// The warm process died while idle
+		bufpool.Put(stdout)
+		stdout, err = h.runRemux(ctx, nil, args, content)
+	}
+	if err != nil {
 		// Check if we got any output even if there was an error
-		if stdout.Len() > 0 {
+		if stdout != nil && stdout.Len() > 0 {
 			h.log.Debug("ffmpeg completed with warnings",
 				"input_size", len(content),
 				"output_size", stdout.Len(),
-				"stderr", stderr.String(),
+				"stderr", err.Error(),
 			)
-			return stdout.Bytes(), nil
+			return stdout, nil
+		}
+		if stdout != nil {
+			bufpool.Put(stdout)
 		}
-		return nil, fmt.Errorf("ffmpeg error: %v, stderr: %s", err, stderr.String())
+		err = fmt.Errorf("ffmpeg error: %w", err)
+		span.SetError(err)
+		return nil, err
 	}
+	span.SetAttr("output.size", stdout.Len())
 
 	h.log.Debug("ffmpeg remux successful",
 		"input_size", len(content),
 		"output_size", stdout.Len(),
 	)
-	return stdout.Bytes(), nil
+	return stdout, nil
 }
 
 // handleExtractor handles URL extraction requests.
@@ -846,21 +776,31 @@ func (h *Handlers) handleExtractor(w http.ResponseWriter, r *http.Request) {
 		urlStr = r.URL.Query().Get("d")
 	}
 	if urlStr == "" {
-		h.writeError(w, http.StatusBadRequest, "url parameter required")
+		h.writeError(w, r, http.StatusBadRequest, "url parameter required")
 		return
 	}
 
 	h.log.Debug("extract request", "url", urlStr)
 
 	opts := interfaces.ExtractOptions{
-		Headers:      httpclient.ParseHeaderParams(r.URL.Query()),
+		Headers:      httpclient.ParseHeaderParams(r.URL.Query(), h.ctx.Config.HeaderAllowlist...),
 		ForceRefresh: r.URL.Query().Get("force") == "true",
+		Host:         r.URL.Query().Get("host"),
 	}
 
 	result, err := h.ctx.ProxyService.HandleExtract(r.Context(), urlStr, opts)
+	var unknownHost *services.UnknownHostError
+	if errors.As(err, &unknownHost) {
+		h.writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error":           i18n.Translate(h.lang(r), "unknown host"),
+			"host":            unknownHost.Host,
+			"available_hosts": unknownHost.Available,
+		})
+		return
+	}
 	if err != nil {
 		h.log.Error("❌ extraction failed", "url", urlStr, "error", err)
-		h.writeError(w, http.StatusInternalServerError, err.Error())
+		h.writeError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -873,75 +813,31 @@ func (h *Handlers) handleExtractor(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, http.StatusOK, result)
 }
 
-// handleLicense handles DRM license requests.
-func (h *Handlers) handleLicense(w http.ResponseWriter, r *http.Request) {
-	clearKey := r.URL.Query().Get("clearkey")
-	if clearKey != "" {
-		// Return ClearKey license
-		h.writeClearKeyLicense(w, clearKey)
-		return
-	}
-
-	// Proxy license request
-	licenseURL := r.URL.Query().Get("url")
-	if licenseURL == "" {
-		h.writeError(w, http.StatusBadRequest, "clearkey or url parameter required")
-		return
-	}
-
-	// Proxy the license request
-	h.proxyLicenseRequest(w, r, licenseURL)
-}
-
-// writeClearKeyLicense writes a ClearKey license response.
-func (h *Handlers) writeClearKeyLicense(w http.ResponseWriter, clearKey string) {
-	// Parse KID:KEY pairs
-	keys := make([]map[string]string, 0)
-	pairs := strings.Split(clearKey, ",")
-
-	for _, pair := range pairs {
-		parts := strings.SplitN(pair, ":", 2)
-		if len(parts) == 2 {
-			keys = append(keys, map[string]string{
-				"kty": "oct",
-				"kid": parts[0],
-				"k":   parts[1],
-			})
-		}
-	}
-
-	license := map[string]interface{}{
-		"keys": keys,
-		"type": "temporary",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(license)
-}
-
-// proxyLicenseRequest proxies a license request.
-func (h *Handlers) proxyLicenseRequest(w http.ResponseWriter, r *http.Request, licenseURL string) {
-	// Implementation for license proxying
-	h.writeError(w, http.StatusNotImplemented, "license proxy not implemented")
-}
-
-// handleKey handles AES-128 key requests.
+// handleKey handles AES-128 key requests, fetching the key with the
+// forwarded h_ headers through the configured HTTP client.
 func (h *Handlers) handleKey(w http.ResponseWriter, r *http.Request) {
 	keyURL := r.URL.Query().Get("url")
 	if keyURL == "" {
-		h.writeError(w, http.StatusBadRequest, "url parameter required")
+		h.writeError(w, r, http.StatusBadRequest, "url parameter required")
 		return
 	}
 
-	resp, err := http.Get(keyURL)
-	if err != nil {
-		h.writeError(w, http.StatusBadGateway, "failed to fetch key")
-		return
+	key, ok := h.keys.get(keyURL)
+	if !ok {
+		data, err := h.fetchURL(r.Context(), keyURL, httpclient.ParseHeaderParams(r.URL.Query(), h.ctx.Config.HeaderAllowlist...))
+		if err != nil {
+			h.log.Error("❌ failed to fetch key", "url", keyURL, "error", err)
+			h.writeError(w, r, http.StatusBadGateway, "failed to fetch key")
+			return
+		}
+		key = bytes.Clone(data.Bytes()) // Kept in the key cache
+		bufpool.Put(data)
+		h.keys.put(keyURL, key)
 	}
-	defer resp.Body.Close()
 
 	w.Header().Set("Content-Type", "application/octet-stream")
-	io.Copy(w, resp.Body)
+	w.Header().Set("Content-Length", strconv.Itoa(len(key)))
+	w.Write(key)
 }
 
 // handleFFmpegStream serves FFmpeg transcoded streams.
@@ -950,7 +846,7 @@ func (h *Handlers) handleFFmpegStream(w http.ResponseWriter, r *http.Request) {
 	filename := r.PathValue("filename")
 
 	if streamID == "" || filename == "" {
-		h.writeError(w, http.StatusBadRequest, "invalid path")
+		h.writeError(w, r, http.StatusBadRequest, "invalid path")
 		return
 	}
 
@@ -961,7 +857,7 @@ func (h *Handlers) handleFFmpegStream(w http.ResponseWriter, r *http.Request) {
 
 	// Check if file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		h.writeError(w, http.StatusNotFound, "stream file not found")
+		h.writeError(w, r, http.StatusNotFound, "stream file not found")
 		return
 	}
 
@@ -978,34 +874,103 @@ func (h *Handlers) handleFFmpegStream(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Cache-Control", "no-cache")
+	if strings.HasSuffix(filename, ".m3u8") {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			h.writeError(w, r, http.StatusNotFound, "stream file not found")
+			return
+		}
+		var issue func(string) string
+		if r.URL.Query().Has("token") && h.ctx.PlaybackTokens != nil {
+			dir := "/ffmpeg_stream/" + streamID + "/"
+			issue = func(uri string) string { return h.issuePlaybackToken(r, dir+uri, "") }
+		}
+		io.WriteString(w, withStreamAuth(string(data), r.URL.Query(), issue))
+		return
+	}
 	http.ServeFile(w, r, filePath)
 }
 
 // Recording handlers
 
+// handleListRecordings lists recordings, optionally searched (?q=), filtered
+// by status (?status=completed,failed), sorted (?sort=date|size|name,
+// ?order=asc|desc) and paged (?limit=, ?offset=). X-Total-Count holds the
+// number of matching recordings.
 func (h *Handlers) handleListRecordings(w http.ResponseWriter, r *http.Request) {
-	recordings, err := h.ctx.RecordingManager.ListRecordings()
+	params := r.URL.Query()
+	query := types.RecordingQuery{
+		Query: params.Get("q"),
+		Sort:  params.Get("sort"),
+		Order: params.Get("order"),
+	}
+	if status := params.Get("status"); status != "" {
+		query.Status = strings.Split(status, ",")
+	}
+	for name, target := range map[string]*int{"limit": &query.Limit, "offset": &query.Offset} {
+		if value := params.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				h.writeError(w, r, http.StatusBadRequest, "invalid "+name)
+				return
+			}
+			*target = n
+		}
+	}
+
+	recordings, total, err := h.ctx.RecordingManager.QueryRecordings(query)
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, err.Error())
+		if errors.Is(err, services.ErrInvalidQuery) {
+			h.writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.writeError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	h.writeJSON(w, http.StatusOK, recordings)
 }
 
 func (h *Handlers) handleListActiveRecordings(w http.ResponseWriter, r *http.Request) {
 	recordings, err := h.ctx.RecordingManager.ListActiveRecordings()
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, err.Error())
+		h.writeError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
 	h.writeJSON(w, http.StatusOK, recordings)
 }
 
+// handleListVolumes returns the usage and quota of each recording volume.
+func (h *Handlers) handleListVolumes(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, h.ctx.RecordingManager.ListVolumes())
+}
+
 func (h *Handlers) handleGetRecording(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	recording, err := h.ctx.RecordingManager.GetRecording(id)
 	if err != nil {
-		h.writeError(w, http.StatusNotFound, err.Error())
+		h.writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, recording)
+}
+
+// handleUpdateRecording edits a recording's name, description, tags, poster
+// and favorite flag; fields missing from the body are left unchanged.
+func (h *Handlers) handleUpdateRecording(w http.ResponseWriter, r *http.Request) {
+	var update types.RecordingUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	recording, err := h.ctx.RecordingManager.UpdateRecording(r.PathValue("id"), update)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMetadata) {
+			h.writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.writeError(w, r, http.StatusNotFound, err.Error())
 		return
 	}
 	h.writeJSON(w, http.StatusOK, recording)
@@ -1013,21 +978,45 @@ func (h *Handlers) handleGetRecording(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handlers) handleStartRecording(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		URL      string `json:"url"`
-		Name     string `json:"name"`
-		ClearKey string `json:"clearkey"`
+		URL      string            `json:"url"`
+		Name     string            `json:"name"`
+		ClearKey string            `json:"clearkey"`
+		Headers  map[string]string `json:"headers"`
+		Quality  string            `json:"quality"`
+		Backups  []string          `json:"backups"`
+
+		Subtitles []types.RecordingSubtitle `json:"subtitles"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		h.writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	for _, sub := range req.Subtitles {
+		if err := services.ValidateSubtitle(sub); err != nil {
+			h.writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Quality != "" && !applyQuality(&types.VariantFilter{}, req.Quality) {
+		h.writeError(w, r, http.StatusBadRequest, "invalid quality")
 		return
 	}
 
-	recording, err := h.ctx.RecordingManager.StartRecording(r.Context(), req.URL, req.Name, req.ClearKey)
+	recording, err := h.ctx.RecordingManager.StartRecording(r.Context(), req.URL, req.Name, req.ClearKey, req.Headers, req.Quality, req.Backups)
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, err.Error())
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrVolumesFull) {
+			status = http.StatusInsufficientStorage
+		}
+		h.writeError(w, r, status, err.Error())
 		return
 	}
+	for _, sub := range req.Subtitles {
+		if _, err := h.ctx.RecordingManager.AddSubtitle(recording.ID, sub); err != nil {
+			h.log.Warn("failed to add subtitle", "id", recording.ID, "error", err)
+		}
+	}
 
 	h.writeJSON(w, http.StatusCreated, recording)
 }
@@ -1035,7 +1024,7 @@ func (h *Handlers) handleStartRecording(w http.ResponseWriter, r *http.Request)
 func (h *Handlers) handleStopRecording(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if err := h.ctx.RecordingManager.StopRecording(id); err != nil {
-		h.writeError(w, http.StatusNotFound, err.Error())
+		h.writeError(w, r, http.StatusNotFound, err.Error())
 		return
 	}
 	h.writeJSON(w, http.StatusOK, map[string]string{"status": "stopped"})
@@ -1045,12 +1034,12 @@ func (h *Handlers) handleRecordingStream(w http.ResponseWriter, r *http.Request)
 	id := r.PathValue("id")
 	recording, err := h.ctx.RecordingManager.GetRecording(id)
 	if err != nil {
-		h.writeError(w, http.StatusNotFound, err.Error())
+		h.writeError(w, r, http.StatusNotFound, err.Error())
 		return
 	}
 
 	// Use http.ServeFile for proper range request support (seeking)
-	w.Header().Set("Content-Type", "video/MP2T")
+	w.Header().Set("Content-Type", recordingContentType(recording.FilePath))
 	http.ServeFile(w, r, recording.FilePath)
 }
 
@@ -1058,18 +1047,41 @@ func (h *Handlers) handleRecordingDownload(w http.ResponseWriter, r *http.Reques
 	id := r.PathValue("id")
 	recording, err := h.ctx.RecordingManager.GetRecording(id)
 	if err != nil {
-		h.writeError(w, http.StatusNotFound, err.Error())
+		h.writeError(w, r, http.StatusNotFound, err.Error())
 		return
 	}
 
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.ts\"", recording.Name))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s%s\"", recording.Name, filepath.Ext(recording.FilePath)))
 	http.ServeFile(w, r, recording.FilePath)
 }
 
+// recordingContentType returns the media type of a recording file: MPEG-TS
+// for recordings, or MP4 for imported files.
+func recordingContentType(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".mp4") {
+		return "video/mp4"
+	}
+	return "video/MP2T"
+}
+
+// handleImportRecordings registers the media files in the recording volumes
+// and watch folder that aren't in the library yet.
+func (h *Handlers) handleImportRecordings(w http.ResponseWriter, r *http.Request) {
+	imported, err := h.ctx.RecordingManager.ImportRecordings(r.Context())
+	if err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if imported == nil {
+		imported = []*types.Recording{}
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{"imported": len(imported), "recordings": imported})
+}
+
 func (h *Handlers) handleDeleteRecording(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if err := h.ctx.RecordingManager.DeleteRecording(id); err != nil {
-		h.writeError(w, http.StatusNotFound, err.Error())
+		h.writeError(w, r, http.StatusNotFound, err.Error())
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -1083,23 +1095,30 @@ func (h *Handlers) handleRecord(w http.ResponseWriter, r *http.Request) {
 		name = "recording"
 	}
 
-	_, err := h.ctx.RecordingManager.StartRecording(r.Context(), urlStr, name, clearKey)
+	quality := r.URL.Query().Get("quality")
+	if quality != "" && !applyQuality(&types.VariantFilter{}, quality) {
+		h.writeError(w, r, http.StatusBadRequest, "invalid quality")
+		return
+	}
+
+	headers := httpclient.ParseHeaderParams(r.URL.Query(), h.ctx.Config.HeaderAllowlist...)
+	_, err := h.ctx.RecordingManager.StartRecording(r.Context(), urlStr, name, clearKey, headers, quality, r.URL.Query()["backup"])
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, err.Error())
+		h.writeError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	// Redirect to live stream (not the recording file) so user can watch while recording
 	// This matches EasyProxy behavior: record in background, watch live
-	proxyURL, _ := url.Parse(h.ctx.BaseURL + "/proxy/manifest.m3u8")
+	proxyURL, _ := url.Parse(h.publicBaseURL(r) + "/proxy/manifest.m3u8")
 	q := proxyURL.Query()
 	q.Set("url", urlStr)
 	if clearKey != "" {
 		q.Set("clearkey", clearKey)
 	}
-	// Pass through headers from original request
+	// Pass through headers and API password from original request
 	for key, values := range r.URL.Query() {
-		if strings.HasPrefix(key, "h_") {
+		if strings.HasPrefix(key, "h_") || key == "api_password" {
 			q.Set(key, values[0])
 		}
 	}
@@ -1108,21 +1127,11 @@ func (h *Handlers) handleRecord(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, proxyURL.String(), http.StatusFound)
 }
 
-// handleDeleteRecordingGet handles GET-based deletion for Stremio compatibility.
-func (h *Handlers) handleDeleteRecordingGet(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	if err := h.ctx.RecordingManager.DeleteRecording(id); err != nil {
-		h.writeError(w, http.StatusNotFound, err.Error())
-		return
-	}
-	h.writeJSON(w, http.StatusOK, map[string]any{"success": true, "message": "Recording deleted"})
-}
-
 // handleDeleteAllRecordings deletes all completed recordings.
 func (h *Handlers) handleDeleteAllRecordings(w http.ResponseWriter, r *http.Request) {
 	recordings, err := h.ctx.RecordingManager.ListRecordings()
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, err.Error())
+		h.writeError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -1148,8 +1157,11 @@ func (h *Handlers) handleStopAndStream(w http.ResponseWriter, r *http.Request) {
 		h.log.Debug("stop recording result", "id", id, "error", err)
 	}
 
-	// Redirect to stream
-	streamURL := fmt.Sprintf("%s/api/recordings/%s/stream", h.ctx.BaseURL, id)
+	// Redirect to stream, passing through the API password of the original request
+	streamURL := fmt.Sprintf("%s/api/recordings/%s/stream", h.publicBaseURL(r), id)
+	if password := r.URL.Query().Get("api_password"); password != "" {
+		streamURL += "?" + url.Values{"api_password": {password}}.Encode()
+	}
 	http.Redirect(w, r, streamURL, http.StatusFound)
 }
 
@@ -1163,8 +1175,8 @@ func (h *Handlers) parseStreamRequest(r *http.Request) *types.StreamRequest {
 
 	// Get clearkey - supports combined format or separate key_id/key params
 	clearKey := r.URL.Query().Get("clearkey")
-	keyID := r.URL.Query().Get("key_id")
-	key := r.URL.Query().Get("key")
+	keyID := crypto.NormalizeKIDs(r.URL.Query().Get("key_id"))
+	key := crypto.NormalizeKIDs(r.URL.Query().Get("key"))
 
 	// If no clearkey but separate key_id/key provided, combine them
 	// Supports comma-separated multiple keys: key_id=KID1,KID2 key=KEY1,KEY2
@@ -1182,9 +1194,22 @@ func (h *Handlers) parseStreamRequest(r *http.Request) *types.StreamRequest {
 		}
 	}
 
+	// LL-HLS players add delivery directives to the playlist URL; pass them upstream
+	var directives url.Values
+	for name, values := range r.URL.Query() {
+		if strings.HasPrefix(name, "_HLS_") {
+			if directives == nil {
+				directives = url.Values{}
+			}
+			directives[name] = values
+		}
+	}
+
 	return &types.StreamRequest{
 		URL:            urlStr,
-		Headers:        httpclient.ParseHeaderParams(r.URL.Query()),
+		Headers:        httpclient.ParseHeaderParams(r.URL.Query(), h.ctx.Config.HeaderAllowlist...),
+		Directives:     directives,
+		Filter:         parseVariantFilter(r.URL.Query()),
 		ClearKey:       clearKey,
 		KeyID:          keyID,
 		Key:            key,
@@ -1192,18 +1217,75 @@ func (h *Handlers) parseStreamRequest(r *http.Request) *types.StreamRequest {
 		Force:          r.URL.Query().Get("force") == "true",
 		Extension:      r.URL.Query().Get("ext"),
 		RepID:          r.URL.Query().Get("rep_id"),
+		AudioRepID:     r.URL.Query().Get("audio_rep_id"),
+		IFrames:        r.URL.Query().Get("iframes") == "1",
+		Muxed:          r.URL.Query().Get("muxed") == "1" || r.URL.Query().Get("muxed") == "true",
+		InspectDRM:     r.URL.Query().Get("drm_info") == "1" || r.URL.Query().Get("drm_info") == "true",
 		NoBypass:       r.URL.Query().Get("no_bypass") == "1",
+		VOD:            r.URL.Query().Get("vod") == "1",
 	}
 }
 
+// parseVariantFilter parses the master playlist filter params:
+// max_resolution (720, 720p or 1280x720), min_bandwidth (bits/s, k/M suffix),
+// audio_lang (comma-separated), drop_subtitles, audio_only, quality (see
+// applyQuality) and device (chromecast, which prefers AAC audio in MPDs).
+// Returns nil if none are set.
+func parseVariantFilter(query url.Values) *types.VariantFilter {
+	filter := &types.VariantFilter{
+		AudioLangs:    splitList(query["audio_lang"]),
+		DropSubtitles: query.Get("drop_subtitles") == "true" || query.Get("drop_subtitles") == "1",
+		AudioOnly:     query.Get("audio_only") == "true" || query.Get("audio_only") == "1",
+		AudioCodecs:   splitList(query["audio_codec"]),
+		PreferEC3:     query.Get("prefer_ec3") == "true" || query.Get("prefer_ec3") == "1",
+		Device:        strings.ToLower(query.Get("device")),
+	}
+	if filter.Device == types.DeviceChromecast && len(filter.AudioCodecs) == 0 {
+		filter.AudioCodecs = []string{"aac"}
+	}
+
+	if res := strings.ToLower(query.Get("max_resolution")); res != "" {
+		if w, h, ok := strings.Cut(res, "x"); ok {
+			filter.MaxWidth, _ = strconv.Atoi(w)
+			filter.MaxHeight, _ = strconv.Atoi(h)
+		} else {
+			filter.MaxHeight, _ = strconv.Atoi(strings.TrimSuffix(res, "p"))
+		}
+	}
+
+	if bw := query.Get("min_bandwidth"); bw != "" {
+		filter.MinBandwidth, _ = parseBandwidth(bw)
+	}
+	if quality := query.Get("quality"); quality != "" {
+		applyQuality(filter, quality)
+	}
+
+	if filter.MaxWidth == 0 && filter.MaxHeight == 0 && filter.MinBandwidth == 0 &&
+		len(filter.AudioLangs) == 0 && len(filter.AudioCodecs) == 0 && !filter.PreferEC3 &&
+		!filter.DropSubtitles && !filter.AudioOnly && filter.Pin == "" && filter.Device == "" {
+		return nil
+	}
+	return filter
+}
+
+// publicBaseURL returns the base for absolute URLs handed to the client of r.
+func (h *Handlers) publicBaseURL(r *http.Request) string {
+	return urlutil.PublicBaseURL(r.Context(), h.ctx.BaseURL)
+}
+
 func (h *Handlers) writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
 }
 
-func (h *Handlers) writeError(w http.ResponseWriter, status int, message string) {
-	h.writeJSON(w, status, map[string]string{"error": message})
+func (h *Handlers) writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	h.writeJSON(w, status, map[string]string{"error": i18n.Translate(h.lang(r), message)})
+}
+
+// lang returns the language of the dashboard and error messages for r.
+func (h *Handlers) lang(r *http.Request) string {
+	return i18n.FromRequest(r, h.ctx.Config.UILanguage)
 }
 
 func (h *Handlers) writeStreamResponse(w http.ResponseWriter, r *http.Request, resp *types.StreamResponse) {
@@ -1224,6 +1306,8 @@ func (h *Handlers) writeStreamResponse(w http.ResponseWriter, r *http.Request, r
 
 	if resp.Body != nil {
 		defer resp.Body.Close()
-		io.Copy(w, resp.Body)
+		if _, err := relay(w, resp.Body); err != nil {
+			h.log.Debug("stream write interrupted", "error", err)
+		}
 	}
 }
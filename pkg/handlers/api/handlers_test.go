@@ -1,15 +1,50 @@
 package api
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"media-proxy-go/pkg/appctx"
+	"media-proxy-go/pkg/channels"
 	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/extractors"
+	"media-proxy-go/pkg/handlers/streams"
+	"media-proxy-go/pkg/health"
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/keys"
 	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/middleware"
+	"media-proxy-go/pkg/notify"
+	"media-proxy-go/pkg/registry"
+	"media-proxy-go/pkg/rules"
+	"media-proxy-go/pkg/services"
+	"media-proxy-go/pkg/sessions"
+	"media-proxy-go/pkg/streamstats"
+	"media-proxy-go/pkg/types"
+	"media-proxy-go/pkg/urlcrypt"
+	"media-proxy-go/pkg/web"
+	"media-proxy-go/pkg/workpool"
 )
 
 func newTestHandlers(apiPassword string) *Handlers {
@@ -266,13 +301,13 @@ func TestHandlers_writeClearKeyLicense(t *testing.T) {
 	}{
 		{
 			name:       "single key pair",
-			clearKey:   "kid1:key1",
+			clearKey:   "0123456789abcdef0123456789abcdef:fedcba9876543210fedcba9876543210",
 			wantStatus: http.StatusOK,
 			wantJSON:   true,
 		},
 		{
 			name:       "multiple key pairs",
-			clearKey:   "kid1:key1,kid2:key2",
+			clearKey:   "0123456789abcdef0123456789abcdef:fedcba9876543210fedcba9876543210,ffffffffffffffffffffffffffffffff:00000000000000000000000000000001",
 			wantStatus: http.StatusOK,
 			wantJSON:   true,
 		},
@@ -281,7 +316,7 @@ func TestHandlers_writeClearKeyLicense(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			w := httptest.NewRecorder()
-			h.writeClearKeyLicense(w, tt.clearKey)
+			h.writeClearKeyLicense(w, httptest.NewRequest(http.MethodPost, "/license", nil), tt.clearKey, licenseRequest{})
 
 			if w.Code != tt.wantStatus {
 				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
@@ -329,7 +364,7 @@ func TestHandlers_writeError(t *testing.T) {
 	h := newTestHandlers("")
 
 	w := httptest.NewRecorder()
-	h.writeError(w, http.StatusBadRequest, "missing parameter")
+	h.writeError(w, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusBadRequest, "missing parameter")
 
 	if w.Code != http.StatusBadRequest {
 		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
@@ -339,6 +374,22 @@ func TestHandlers_writeError(t *testing.T) {
 	if !contains(body, `"error":"missing parameter"`) {
 		t.Errorf("body = %q, expected to contain error message", body)
 	}
+
+	// Messages follow Accept-Language, unless UI_LANGUAGE forces a language
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Language", "it-IT,it;q=0.9,en;q=0.8")
+	w = httptest.NewRecorder()
+	h.writeError(w, r, http.StatusNotFound, "recording not found: rec_1")
+	if body := w.Body.String(); !contains(body, `"error":"registrazione non trovata: rec_1"`) {
+		t.Errorf("body = %q, want the Italian message", body)
+	}
+
+	h.ctx.Config.UILanguage = "de"
+	w = httptest.NewRecorder()
+	h.writeError(w, r, http.StatusBadRequest, "url parameter required")
+	if body := w.Body.String(); !contains(body, `"error":"Parameter url erforderlich"`) {
+		t.Errorf("body = %q, want the German message", body)
+	}
 }
 
 func contains(s, substr string) bool {
@@ -349,3 +400,1922 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestHandlers_License(t *testing.T) {
+	h := newTestHandlers("")
+	store := keys.NewStore("", h.log)
+	if _, err := store.Set(keys.Key{KID: "ffffffffffffffffffffffffffffffff", Key: "00000000000000000000000000000001"}); err != nil {
+		t.Fatal(err)
+	}
+	h.ctx.WithKeys(store)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	post := func(query, body string) (int, license) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/license"+query, strings.NewReader(body)))
+		var resp license
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid license JSON: %v", err)
+			}
+		}
+		return rec.Code, resp
+	}
+
+	// UUID KID and base64url key in, base64url JWKs out; only the requested KID
+	clearKey := url.QueryEscape("01234567-89AB-CDEF-0123-456789ABCDEF:ASNFZ4mrze8BI0VniavN7w,ffffffffffffffffffffffffffffffff:00000000000000000000000000000001")
+	code, resp := post("?clearkey="+clearKey, `{"kids":["ASNFZ4mrze8BI0VniavN7w"],"type":"temporary"}`)
+	want := license{Keys: []licenseKey{{Kty: "oct", KID: "ASNFZ4mrze8BI0VniavN7w", K: "ASNFZ4mrze8BI0VniavN7w"}}, Type: "temporary"}
+	if code != http.StatusOK || !reflect.DeepEqual(resp, want) {
+		t.Errorf("clearkey license = %d %+v, want %+v", code, resp, want)
+	}
+
+	// Without clearkey, the requested KIDs are looked up in the key store
+	code, resp = post("", `{"kids":["_____________________w"],"type":"persistent-license"}`)
+	want = license{Keys: []licenseKey{{Kty: "oct", KID: "_____________________w", K: "AAAAAAAAAAAAAAAAAAAAAQ"}}, Type: "persistent-license"}
+	if code != http.StatusOK || !reflect.DeepEqual(resp, want) {
+		t.Errorf("key store license = %d %+v, want %+v", code, resp, want)
+	}
+
+	for _, tt := range []struct{ query, body string }{
+		{"", `{"kids":["ASNFZ4mrze8BI0VniavN7w"]}`}, // Unknown KID
+		{"?clearkey=kid1:key1", ""},
+		{"?clearkey=" + clearKey, "not json"},
+	} {
+		if code, _ := post(tt.query, tt.body); code != http.StatusBadRequest {
+			t.Errorf("POST /license%s %q status = %d, want 400", tt.query, tt.body, code)
+		}
+	}
+}
+
+func TestHandlers_Playlist_ImportAndExport(t *testing.T) {
+	h := newTestHandlers("secret123")
+	h.ctx.WithChannels(channels.NewStore("", nil, h.log))
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	playlist := "#EXTM3U\n" +
+		"#EXTINF:-1 tvg-id=\"news\" group-title=\"News\",News\n" +
+		"#EXTVLCOPT:http-referrer=https://example.com/\n" +
+		"https://cdn.example.com/news.m3u8\n" +
+		"#EXTINF:-1 group-title=\"Sport\",Sport\n" +
+		"https://cdn.example.com/sport.m3u8\n"
+
+	req := httptest.NewRequest(http.MethodPost, "/api/playlist/import?api_password=secret123", strings.NewReader(playlist))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("import status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/playlist.m3u?group=News&api_password=secret123", nil)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("playlist status = %d", rec.Code)
+	}
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 || lines[0] != "#EXTM3U" {
+		t.Fatalf("playlist = %q, want header and one channel", rec.Body.String())
+	}
+	if !strings.Contains(lines[1], `group-title="News"`) || !strings.HasSuffix(lines[1], ",News") {
+		t.Errorf("EXTINF line = %q", lines[1])
+	}
+
+	proxyURL, err := url.Parse(lines[2])
+	if err != nil {
+		t.Fatalf("invalid channel URL %q: %v", lines[2], err)
+	}
+	if proxyURL.Path != "/proxy/manifest.m3u8" {
+		t.Errorf("path = %q, want /proxy/manifest.m3u8", proxyURL.Path)
+	}
+	q := proxyURL.Query()
+	if q.Get("url") != "https://cdn.example.com/news.m3u8" || q.Get("h_Referer") != "https://example.com/" || q.Get("api_password") != "secret123" {
+		t.Errorf("query = %v", q)
+	}
+}
+
+func TestHandlers_Channels_CRUD(t *testing.T) {
+	h := newTestHandlers("")
+	h.ctx.WithChannels(channels.NewStore("", nil, h.log))
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/channels", `{"name": "News", "url": "https://cdn.example.com/news.m3u8", "group": "News", "headers": {"Referer": "https://example.com/"}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	var created types.Channel
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil || created.ID == "" {
+		t.Fatalf("create response = %+v, %v", created, err)
+	}
+
+	if rec := do(http.MethodPost, "/api/channels", `{"name": "Bad", "url": "file:///etc/passwd"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("create with non-http URL status = %d, want 400", rec.Code)
+	}
+
+	rec = do(http.MethodPut, "/api/channels/"+created.ID, `{"name": "News HD", "url": "https://cdn.example.com/news-hd.m3u8", "favorite": true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	rec = do(http.MethodGet, "/api/channels?favorite=true", "")
+	var list []types.Channel
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode list: %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "News HD" || list[0].ID != created.ID {
+		t.Errorf("favorites = %+v", list)
+	}
+
+	if rec := do(http.MethodDelete, "/api/channels/"+created.ID, ""); rec.Code != http.StatusOK {
+		t.Errorf("delete status = %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/channels/"+created.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get after delete status = %d, want 404", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "/api/channels/"+created.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second delete status = %d, want 404", rec.Code)
+	}
+}
+
+func TestHandlers_Keys_CRUD(t *testing.T) {
+	h := newTestHandlers("")
+	h.ctx.WithKeys(keys.NewStore("", h.log))
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	kid := "0123456789abcdef0123456789abcdef"
+	rec := do(http.MethodPost, "/api/keys", `{"kid": "01234567-89AB-CDEF-0123-456789ABCDEF", "key": "00112233445566778899aabbccddeeff", "label": "News"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := do(http.MethodPost, "/api/keys", `{"kid": "abc", "key": "00112233445566778899aabbccddeeff"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("create with invalid kid status = %d, want 400", rec.Code)
+	}
+
+	rec = do(http.MethodPut, "/api/keys/"+kid, `{"key": "ffeeddccbbaa99887766554433221100"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	rec = do(http.MethodGet, "/api/keys", "")
+	var list []keys.Key
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode list: %v", err)
+	}
+	if len(list) != 1 || list[0].KID != kid || list[0].Key != "ffeeddccbbaa99887766554433221100" {
+		t.Errorf("keys = %+v", list)
+	}
+
+	if rec := do(http.MethodDelete, "/api/keys/"+kid, ""); rec.Code != http.StatusOK {
+		t.Errorf("delete status = %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/keys/"+kid, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get after delete status = %d, want 404", rec.Code)
+	}
+}
+
+func TestHandlers_Key_ForwardsHeadersAndCaches(t *testing.T) {
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.Header.Get("Referer") != "https://example.com/" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("0123456789abcdef"))
+	}))
+	defer upstream.Close()
+
+	h := newTestHandlers("")
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	keyPath := "/key?url=" + url.QueryEscape(upstream.URL+"/1.key")
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, keyPath+"&h_referer="+url.QueryEscape("https://example.com/"), nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != "0123456789abcdef" {
+			t.Fatalf("request %d: status = %d, body = %q", i, rec.Code, rec.Body.String())
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("upstream hits = %d, want 1 (cached)", n)
+	}
+
+	// Failed fetches are not cached
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/key?url="+url.QueryEscape(upstream.URL+"/2.key"), nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status without referer = %d, want 502", rec.Code)
+	}
+}
+
+func TestHandlers_writeManifestResponse_Gzip(t *testing.T) {
+	const playlist = "#EXTM3U\n#EXTINF:4.0,\nseg1.ts\n"
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		wantGzip       bool
+	}{
+		{"gzip accepted", "gzip, deflate, br", "application/vnd.apple.mpegurl", true},
+		{"gzip refused", "gzip;q=0, br", "application/vnd.apple.mpegurl", false},
+		{"no accept-encoding", "", "application/vnd.apple.mpegurl", false},
+		{"media is not compressed", "gzip", "video/mp2t", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandlers("")
+			h.ctx.Config.ManifestGzip = true
+
+			req := httptest.NewRequest(http.MethodGet, "/proxy/manifest.m3u8", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			h.writeManifestResponse(w, req, &types.StreamResponse{
+				ContentType: tt.contentType,
+				Body:        io.NopCloser(strings.NewReader(playlist)),
+				StatusCode:  http.StatusOK,
+			})
+
+			body := w.Body.Bytes()
+			if gotGzip := w.Header().Get("Content-Encoding") == "gzip"; gotGzip != tt.wantGzip {
+				t.Fatalf("Content-Encoding = %q, want gzip = %v", w.Header().Get("Content-Encoding"), tt.wantGzip)
+			}
+			if tt.wantGzip {
+				if w.Header().Get("Vary") != "Accept-Encoding" {
+					t.Errorf("Vary = %q, want Accept-Encoding", w.Header().Get("Vary"))
+				}
+				gz, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatalf("gzip.NewReader() error = %v", err)
+				}
+				body, _ = io.ReadAll(gz)
+			}
+			if string(body) != playlist {
+				t.Errorf("body = %q, want %q", body, playlist)
+			}
+		})
+	}
+}
+
+func TestHandlers_ListTranscodeProfiles(t *testing.T) {
+	h := newTestHandlers("")
+	h.ctx.Config.FFmpegOutputDir = t.TempDir()
+	h.ctx.Config.TranscodeDefaultProfile = "720p"
+	transcoder, err := services.NewFFmpegTranscoder(h.ctx.Config, h.log)
+	if err != nil {
+		t.Fatalf("NewFFmpegTranscoder() error = %v", err)
+	}
+	defer transcoder.Close()
+	h.ctx.WithTranscoder(transcoder)
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/transcode/profiles", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var got struct {
+		Default  string                   `json:"default"`
+		Profiles []types.TranscodeProfile `json:"profiles"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Default != "720p" {
+		t.Errorf("default = %q, want 720p", got.Default)
+	}
+	names := make(map[string]bool)
+	for _, p := range got.Profiles {
+		names[p.Name] = true
+	}
+	for _, name := range []string{"1080p", "720p", "480p", "copy"} {
+		if !names[name] {
+			t.Errorf("profile %q missing from %+v", name, got.Profiles)
+		}
+	}
+}
+
+func TestHandlers_Transcode(t *testing.T) {
+	// Stand-in for FFmpeg: report cuda support, or write the playlist (last
+	// argument) and keep running
+	dir := t.TempDir()
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	script := "#!/bin/sh\ncase \"$*\" in *-hwaccels*) printf 'Hardware acceleration methods:\\ncuda\\n'; exit 0;; esac\nfor last; do :; done\necho '#EXTM3U' > \"$last\"\nexec sleep 30\n"
+	if err := os.WriteFile(ffmpeg, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHandlers("")
+	h.ctx.Config.FFmpegPath = ffmpeg
+	h.ctx.Config.FFmpegOutputDir = filepath.Join(dir, "streams")
+	h.ctx.Config.TranscodeDefaultProfile = "720p"
+	h.ctx.Config.TranscodeMaxSessions = 1
+	h.ctx.Config.FFmpegHWAccel = "vaapi,nvenc"
+	transcoder, err := services.NewFFmpegTranscoder(h.ctx.Config, h.log)
+	if err != nil {
+		t.Fatalf("NewFFmpegTranscoder() error = %v", err)
+	}
+	defer transcoder.Close()
+	h.ctx.WithTranscoder(transcoder)
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	var info struct {
+		HWAccel types.HWAccelInfo `json:"hwaccel"`
+	}
+	if err := json.Unmarshal(get("/api/info").Body.Bytes(), &info); err != nil {
+		t.Fatalf("invalid /api/info JSON: %v", err)
+	}
+	if info.HWAccel.Selected != "cuda" {
+		t.Errorf("hwaccel = %+v, want cuda selected", info.HWAccel)
+	}
+
+	first := get("/transcode?url=" + url.QueryEscape("http://example.com/a.m3u8"))
+	if first.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302: %s", first.Code, first.Body.String())
+	}
+	location := first.Header().Get("Location")
+	if !strings.HasPrefix(location, "http://localhost:7860/ffmpeg_stream/") || !strings.HasSuffix(location, "/index.m3u8") {
+		t.Fatalf("Location = %q, want the /ffmpeg_stream playlist", location)
+	}
+
+	// The same source and profile reuses the running session
+	if again := get("/transcode?url=" + url.QueryEscape("http://example.com/a.m3u8")); again.Header().Get("Location") != location {
+		t.Errorf("second Location = %q, want %q", again.Header().Get("Location"), location)
+	}
+
+	// The playlist is served from the transcoder's output directory
+	playlist := get(strings.TrimPrefix(location, "http://localhost:7860"))
+	if playlist.Code != http.StatusOK || !strings.Contains(playlist.Body.String(), "#EXTM3U") {
+		t.Errorf("playlist = %d %q, want the transcoded playlist", playlist.Code, playlist.Body.String())
+	}
+
+	if rec := get("/transcode?url=" + url.QueryEscape("http://example.com/b.m3u8")); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("over the session limit: status = %d, want 503", rec.Code)
+	}
+	if rec := get("/transcode?url=" + url.QueryEscape("http://example.com/a.m3u8") + "&profile=8k"); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown profile: status = %d, want 400", rec.Code)
+	}
+	if rec := get("/transcode"); rec.Code != http.StatusBadRequest {
+		t.Errorf("missing url: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestHandlers_TranscodeWithPassword(t *testing.T) {
+	dir := t.TempDir()
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	script := "#!/bin/sh\ncase \"$*\" in *-hwaccels*) exit 0;; esac\nfor last; do :; done\nprintf '#EXTM3U\\n#EXTINF:4,\\nseg_0.ts\\n' > \"$last.tmp\" && mv \"$last.tmp\" \"$last\"\nexec sleep 30\n"
+	if err := os.WriteFile(ffmpeg, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHandlers("secret")
+	h.ctx.Config.FFmpegPath = ffmpeg
+	h.ctx.Config.FFmpegOutputDir = filepath.Join(dir, "streams")
+	h.ctx.Config.TranscodeDefaultProfile = "720p"
+	transcoder, err := services.NewFFmpegTranscoder(h.ctx.Config, h.log)
+	if err != nil {
+		t.Fatalf("NewFFmpegTranscoder() error = %v", err)
+	}
+	defer transcoder.Close()
+	h.ctx.WithTranscoder(transcoder)
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	handler := middleware.Auth(h.ctx.Config, h.log)(mux)
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	source := "/transcode?url=" + url.QueryEscape("http://example.com/a.m3u8")
+	if rec := get(source); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("without password: status = %d, want 401", rec.Code)
+	}
+	rec := get(source + "&api_password=secret")
+	if rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302: %s", rec.Code, rec.Body.String())
+	}
+
+	// The redirect is signed instead of carrying the password
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil || location.Query().Has("api_password") || location.Query().Get("signature") == "" {
+		t.Fatalf("Location = %q, want a signed playlist URL", rec.Header().Get("Location"))
+	}
+	playlist := get(location.RequestURI())
+	if playlist.Code != http.StatusOK {
+		t.Fatalf("playlist status = %d, want 200", playlist.Code)
+	}
+
+	// Its segments inherit the signature
+	var segment string
+	for _, line := range strings.Split(playlist.Body.String(), "\n") {
+		if strings.HasPrefix(line, "seg_0.ts") {
+			segment = line
+		}
+	}
+	if !strings.Contains(segment, "signature=") {
+		t.Fatalf("playlist = %q, want a signed segment URI", playlist.Body.String())
+	}
+	segmentURL := location.ResolveReference(&url.URL{Path: "seg_0.ts"})
+	if rec := get(segmentURL.Path); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned segment status = %d, want 401", rec.Code)
+	}
+	signed, _ := location.Parse(segment)
+	if rec := get(signed.RequestURI()); rec.Code == http.StatusUnauthorized {
+		t.Error("signed segment unauthorized")
+	}
+	other := strings.Replace(signed.RequestURI(), "/ffmpeg_stream/", "/ffmpeg_stream/x", 1)
+	if rec := get(other); rec.Code != http.StatusUnauthorized {
+		t.Errorf("signature on another stream: status = %d, want 401", rec.Code)
+	}
+}
+
+func TestHandlers_Transcode_BurnSubtitles(t *testing.T) {
+	// Stand-in for FFmpeg logging its arguments: write the subtitles or the
+	// playlist (last argument)
+	dir := t.TempDir()
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	script := "#!/bin/sh\ncase \"$*\" in *-hwaccels*) exit 0;; esac\necho \"$*\" >> " + filepath.Join(dir, "calls") + "\nfor last; do :; done\necho '#EXTM3U' > \"$last\"\ncase \"$*\" in *\"-f ass\"*) exit 0;; esac\nexec sleep 30\n"
+	if err := os.WriteFile(ffmpeg, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	master := "#EXTM3U\n" +
+		`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",LANGUAGE="it-IT",NAME="Italiano",URI="subs/it.m3u8"` + "\n" +
+		`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",LANGUAGE="it",NAME="Forzati",FORCED=YES,URI="subs/it-forced.m3u8"` + "\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000,SUBTITLES=\"subs\"\nvideo.m3u8\n"
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(master))
+	}))
+	defer upstream.Close()
+
+	h := newTestHandlers("")
+	h.ctx.Config.FFmpegPath = ffmpeg
+	h.ctx.Config.FFmpegOutputDir = filepath.Join(dir, "streams")
+	h.ctx.Config.TranscodeDefaultProfile = "720p"
+	transcoder, err := services.NewFFmpegTranscoder(h.ctx.Config, h.log)
+	if err != nil {
+		t.Fatalf("NewFFmpegTranscoder() error = %v", err)
+	}
+	defer transcoder.Close()
+	h.ctx.WithTranscoder(transcoder)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/transcode?url="+url.QueryEscape(upstream.URL+"/master.m3u8")+query, nil))
+		return rec
+	}
+
+	if rec := get("&burn_lang=it&burn_forced=1"); rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302: %s", rec.Code, rec.Body.String())
+	}
+	calls, _ := os.ReadFile(filepath.Join(dir, "calls"))
+	lines := strings.Split(strings.TrimSpace(string(calls)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("ffmpeg calls = %q, want the subtitles download then the transcode", lines)
+	}
+	if want := "-i " + upstream.URL + "/subs/it-forced.m3u8 "; !strings.Contains(lines[0], want) {
+		t.Errorf("subtitles download = %q, want the forced rendition", lines[0])
+	}
+	if !strings.Contains(lines[1], "-vf scale=-2:720,subtitles=burn.ass") {
+		t.Errorf("transcode = %q, want the subtitles filter after scaling", lines[1])
+	}
+
+	if rec := get("&burn_lang=de"); rec.Code != http.StatusBadRequest {
+		t.Errorf("no rendition: status = %d, want 400", rec.Code)
+	}
+	if rec := get("&burn_subs=http://example.com/subs.srt&profile=copy"); rec.Code != http.StatusBadRequest {
+		t.Errorf("stream copy profile: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestMatchesLang(t *testing.T) {
+	tests := []struct {
+		language, want string
+		match          bool
+	}{
+		{"en-US", "", true},
+		{"en-US", "en", true},
+		{"EN", "en", true},
+		{"en-US", "en-GB", false},
+		{"it", "en", false},
+	}
+	for _, tt := range tests {
+		if got := matchesLang(tt.language, tt.want); got != tt.match {
+			t.Errorf("matchesLang(%q, %q) = %v, want %v", tt.language, tt.want, got, tt.match)
+		}
+	}
+}
+
+func TestHandlers_ShortURLs(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/live.m3u8":
+			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+			fmt.Fprint(w, "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts?auth=upstream-token\n")
+		case "/seg1.ts":
+			fmt.Fprint(w, "segment from "+r.Header.Get("Referer"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	h := newTestHandlers("")
+	h.shortURLs = newShortURLs(true, time.Hour)
+	client := httpclient.New(&config.Config{}, h.log)
+	streamHandlers := registry.NewStreamHandlerRegistry()
+	streamHandlers.Register(streams.NewHLSHandler(client, h.log, h.ctx.BaseURL))
+	streamHandlers.SetFallback(streams.NewGenericHandler(client, h.log))
+	h.ctx.WithProxyService(services.NewProxyService(h.log, streamHandlers, registry.NewExtractorRegistry(), h.ctx.BaseURL))
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/proxy/manifest.m3u8?url=" + url.QueryEscape(upstream.URL+"/live.m3u8") + "&h_referer=" + url.QueryEscape("https://site.example/"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("manifest status = %d: %s", rec.Code, rec.Body.String())
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	segment := lines[len(lines)-1]
+	if !strings.HasPrefix(segment, "http://localhost:7860/proxy/s/") || !strings.HasSuffix(segment, "/stream") {
+		t.Fatalf("segment URL = %q, want a short /proxy/s/ URL", segment)
+	}
+	if strings.Contains(rec.Body.String(), "upstream-token") || strings.Contains(rec.Body.String(), "site.example") {
+		t.Errorf("playlist leaks the upstream URL or headers:\n%s", rec.Body.String())
+	}
+	if n, _ := strconv.Atoi(rec.Header().Get("Content-Length")); n != 0 && n != rec.Body.Len() {
+		t.Errorf("Content-Length = %d, body is %d bytes", n, rec.Body.Len())
+	}
+
+	seg := get(strings.TrimPrefix(segment, "http://localhost:7860"))
+	if seg.Code != http.StatusOK || seg.Body.String() != "segment from https://site.example/" {
+		t.Errorf("segment = %d %q, want it fetched with the stored headers", seg.Code, seg.Body.String())
+	}
+	if rec := get("/proxy/s/unknown/stream"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown ID: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestHandlers_EncryptedURLs(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/live.m3u8":
+			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+			fmt.Fprint(w, "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts?auth=upstream-token\n")
+		case "/seg1.ts":
+			fmt.Fprint(w, "segment from "+r.Header.Get("Referer"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	h := newTestHandlers("")
+	cipher, err := urlcrypt.New("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.ctx.WithURLCipher(cipher)
+	client := httpclient.New(&config.Config{}, h.log)
+	streamHandlers := registry.NewStreamHandlerRegistry()
+	streamHandlers.Register(streams.NewHLSHandler(client, h.log, h.ctx.BaseURL))
+	streamHandlers.SetFallback(streams.NewGenericHandler(client, h.log))
+	h.ctx.WithProxyService(services.NewProxyService(h.log, streamHandlers, registry.NewExtractorRegistry(), h.ctx.BaseURL))
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	handler := middleware.EncryptedQuery(cipher, h.log)(mux)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/proxy/manifest.m3u8?url=" + url.QueryEscape(upstream.URL+"/live.m3u8") + "&h_referer=" + url.QueryEscape("https://site.example/"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("manifest status = %d: %s", rec.Code, rec.Body.String())
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	segment := lines[len(lines)-1]
+	if !strings.HasPrefix(segment, "http://localhost:7860/proxy/") || !strings.Contains(segment, "?enc=") {
+		t.Fatalf("segment URL = %q, want an encrypted proxy URL", segment)
+	}
+	if strings.Contains(rec.Body.String(), "upstream-token") || strings.Contains(rec.Body.String(), "site.example") {
+		t.Errorf("playlist leaks the upstream URL or headers:\n%s", rec.Body.String())
+	}
+
+	seg := get(strings.TrimPrefix(segment, "http://localhost:7860"))
+	if seg.Code != http.StatusOK || seg.Body.String() != "segment from https://site.example/" {
+		t.Errorf("segment = %d %q, want it fetched with the encrypted headers", seg.Code, seg.Body.String())
+	}
+}
+
+func TestHandlers_GenerateURL(t *testing.T) {
+	h := newTestHandlers("secret")
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	post := func(target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+		return rec
+	}
+	generate := func(body string) *url.URL {
+		t.Helper()
+		rec := post("/generate_url", body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp struct {
+			URL string `json:"url"`
+		}
+		json.NewDecoder(rec.Body).Decode(&resp)
+		u, err := url.Parse(resp.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	authorized := func(u *url.URL) bool {
+		return middleware.Authorized(h.ctx.Config, httptest.NewRequest(http.MethodGet, u.RequestURI(), nil), middleware.RouteStreaming)
+	}
+
+	if rec := post("/generate_url", `{"endpoint":"/proxy/stream","destination_url":"http://origin/v.mp4"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without password: status = %d, want 401", rec.Code)
+	}
+	if rec := post("/generate_url", `{"api_password":"secret"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("without destination: status = %d, want 400", rec.Code)
+	}
+
+	u := generate(`{"mediaflow_proxy_url":"https://proxy.example","endpoint":"/proxy/hls/manifest.m3u8","destination_url":"http://origin/live.m3u8",` +
+		`"request_headers":{"Referer":"https://site/"},"api_password":"secret"}`)
+	q := u.Query()
+	if u.Host != "proxy.example" || u.Path != "/proxy/hls/manifest.m3u8" || q.Get("d") != "http://origin/live.m3u8" ||
+		q.Get("h_referer") != "https://site/" || q.Get("api_password") != "secret" {
+		t.Errorf("url = %s", u)
+	}
+
+	u = generate(`{"endpoint":"/proxy/stream","destination_url":"http://origin/v.mp4","filename":"movie.mp4","expiration":3600,"api_password":"secret"}`)
+	q = u.Query()
+	if u.Path != "/proxy/stream/movie.mp4" || q.Has("api_password") || q.Get("signature") == "" || q.Get("expiration") == "" {
+		t.Errorf("signed url = %s", u)
+	}
+	if !authorized(u) {
+		t.Error("signed link not authorized")
+	}
+	q.Set("d", "http://elsewhere/")
+	u.RawQuery = q.Encode()
+	if authorized(u) {
+		t.Error("altered signed link authorized")
+	}
+
+	// Signed links are only valid on their endpoint
+	auth := middleware.Auth(h.ctx.Config, h.log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	u = generate(`{"endpoint":"/proxy/hls/manifest.m3u8","destination_url":"http://origin/live.m3u8","expiration":3600,"api_password":"secret"}`)
+	for _, path := range []string{"/proxy/hls/manifest.m3u8", "/proxy/stream", "/ffmpeg_stream/abc/index.m3u8", "/decrypt/segment.mp4"} {
+		rec := httptest.NewRecorder()
+		auth.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path+"?"+u.RawQuery, nil))
+		want := http.StatusUnauthorized
+		if path == u.Path {
+			want = http.StatusOK
+		}
+		if rec.Code != want {
+			t.Errorf("link replayed on %s: status = %d, want %d", path, rec.Code, want)
+		}
+	}
+
+	rec := post("/generate_urls", `{"api_password":"secret","urls":[{"destination_url":"http://origin/a.mp4"},{"endpoint":"/proxy/mpd/manifest.m3u8","destination_url":"http://origin/b.mpd"}]}`)
+	var batch struct {
+		URLs []string `json:"urls"`
+	}
+	json.NewDecoder(rec.Body).Decode(&batch)
+	if rec.Code != http.StatusOK || len(batch.URLs) != 2 || !strings.HasPrefix(batch.URLs[1], "http://localhost:7860/proxy/mpd/manifest.m3u8?") {
+		t.Errorf("generate_urls = %d %v", rec.Code, batch.URLs)
+	}
+}
+
+func TestHandlers_Extractor_UnknownHost(t *testing.T) {
+	h := newTestHandlers("")
+	extractorReg := registry.NewExtractorRegistry()
+	extractorReg.SetFallback(extractors.NewGenericExtractor(httpclient.New(&config.Config{}, h.log), h.log))
+	h.ctx.WithProxyService(services.NewProxyService(h.log, registry.NewStreamHandlerRegistry(), extractorReg, h.ctx.BaseURL))
+
+	rec := httptest.NewRecorder()
+	h.handleExtractor(rec, httptest.NewRequest(http.MethodGet, "/extractor/video?host=Nope&d=http%3A%2F%2Forigin%2Fv", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	var body struct {
+		Host           string   `json:"host"`
+		AvailableHosts []string `json:"available_hosts"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	if body.Host != "Nope" || !reflect.DeepEqual(body.AvailableHosts, []string{"generic"}) {
+		t.Errorf("body = %+v, want the host and the available ones", body)
+	}
+}
+
+// slowExtractor resolves URLs after a delay, failing those containing "bad",
+// and records its highest concurrency.
+type slowExtractor struct {
+	running, peak atomic.Int32
+}
+
+func (e *slowExtractor) Name() string { return "slow" }
+
+func (e *slowExtractor) CanExtract(u string) bool { return true }
+
+func (e *slowExtractor) Extract(ctx context.Context, u string, opts interfaces.ExtractOptions) (*types.ExtractResult, error) {
+	n := e.running.Add(1)
+	defer e.running.Add(-1)
+	for {
+		peak := e.peak.Load()
+		if n <= peak || e.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	if strings.Contains(u, "bad") {
+		return nil, errors.New("no stream found")
+	}
+	return &types.ExtractResult{DestinationURL: u + "/index.m3u8", MediaflowEndpoint: "hls_proxy"}, nil
+}
+
+func (e *slowExtractor) Close() error { return nil }
+
+func TestHandlers_ExtractBatch(t *testing.T) {
+	h := newTestHandlers("")
+	h.ctx.Config.ExtractBatchWorkers = 3
+	extractor := &slowExtractor{}
+	extractorReg := registry.NewExtractorRegistry()
+	extractorReg.Register(extractor)
+	h.ctx.WithProxyService(services.NewProxyService(h.log, registry.NewStreamHandlerRegistry(), extractorReg, h.ctx.BaseURL))
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	urls := []string{"http://site/1", "http://site/bad", "", "http://site/3", "http://site/4", "http://site/5", "http://site/6"}
+	body, _ := json.Marshal(map[string]any{"urls": urls})
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/extract/batch", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Results []batchExtractResult `json:"results"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != len(urls) {
+		t.Fatalf("%d results, want %d", len(resp.Results), len(urls))
+	}
+	for i, res := range resp.Results {
+		wantOK := urls[i] != "" && !strings.Contains(urls[i], "bad")
+		if (res.Status == "ok") != wantOK || res.URL != urls[i] {
+			t.Errorf("result %d = %+v", i, res)
+		}
+		if wantOK && (res.Result == nil || res.Result.DestinationURL != urls[i]+"/index.m3u8") {
+			t.Errorf("result %d = %+v, want its extraction", i, res.Result)
+		}
+	}
+	if peak := extractor.peak.Load(); peak > 3 || peak < 2 {
+		t.Errorf("peak concurrency = %d, want up to the 3 workers", peak)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/extract/batch", strings.NewReader(`{"urls":[]}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("empty batch: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestHandlers_Rules(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	bundle, _ := rules.Seal(&rules.Bundle{
+		Version:    "3",
+		Extractors: map[string]types.ExtractorRules{"dlhd": {Templates: map[string]string{"default": "https://edge.example/{channel}.m3u8"}}},
+	}, priv)
+	fail := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(bundle)
+	}))
+	defer upstream.Close()
+
+	h := newTestHandlers("")
+	dlhd := extractors.NewDLHDExtractor(nil, h.log, nil)
+	loader, err := rules.NewLoader(upstream.URL, base64.StdEncoding.EncodeToString(pub), http.DefaultClient,
+		[]interfaces.RulesConsumer{dlhd}, h.log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.ctx.WithRules(loader)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/rules/reload", nil))
+	var status types.RulesStatus
+	json.NewDecoder(rec.Body).Decode(&status)
+	if rec.Code != http.StatusOK || status.Version != "3" || len(status.Extractors) != 1 {
+		t.Errorf("reload = %d, %+v", rec.Code, status)
+	}
+
+	fail = true
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/rules/reload", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("failed reload status = %d, want 502", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/rules", nil))
+	status = types.RulesStatus{}
+	json.NewDecoder(rec.Body).Decode(&status)
+	if rec.Code != http.StatusOK || status.Version != "3" || status.Error == "" || status.URL != upstream.URL {
+		t.Errorf("status = %d, %+v, want the last bundle and the failed load's error", rec.Code, status)
+	}
+}
+
+func TestHandlers_UAProfile(t *testing.T) {
+	h := newTestHandlers("")
+	h.ctx.HTTPClient = httpclient.New(h.ctx.Config, h.log)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/useragent", strings.NewReader(`{"profile":"mobile","rotate":true}`)))
+	var status types.UAProfileStatus
+	json.NewDecoder(rec.Body).Decode(&status)
+	if rec.Code != http.StatusOK || status.Profile != "mobile" || !status.Rotate {
+		t.Errorf("PUT = %d, %+v", rec.Code, status)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/useragent", strings.NewReader(`{"profile":"fridge"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("PUT of an unknown profile = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/useragent", nil))
+	status = types.UAProfileStatus{}
+	json.NewDecoder(rec.Body).Decode(&status)
+	if status.Profile != "mobile" || len(status.Profiles["desktop"]) == 0 {
+		t.Errorf("GET = %+v", status)
+	}
+}
+
+func TestChaptersWebVTT(t *testing.T) {
+	got := chaptersWebVTT([]types.RecordingChapter{
+		{Title: "Part 1", Kind: types.ChapterProgram, Start: 0, End: 2712.5},
+		{Title: "Break", Kind: types.ChapterIdle, Start: 2712.5, End: 3725},
+	})
+	want := "WEBVTT\n\n1\n00:00:00.000 --> 00:45:12.500\nPart 1\n\n2\n00:45:12.500 --> 01:02:05.000\nBreak\n"
+	if got != want {
+		t.Errorf("chaptersWebVTT() = %q, want %q", got, want)
+	}
+}
+
+func TestHandlers_Probe(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		fmt.Fprint(w, "#EXTM3U\n"+
+			`#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,KEYFORMAT="com.apple.streamingkeydelivery",URI="skd://key"`+"\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=2500000,RESOLUTION=1280x720\n720p.m3u8\n")
+	}))
+	defer upstream.Close()
+
+	// Stand-in for ffprobe: a master playlist repeats the audio track per variant
+	dir := t.TempDir()
+	ffprobe := filepath.Join(dir, "ffprobe")
+	output := `{"streams": [
+		{"codec_type": "video", "codec_name": "h264", "profile": "High", "width": 1280, "height": 720, "avg_frame_rate": "30000/1001", "tags": {"variant_bitrate": "2500000"}},
+		{"codec_type": "audio", "codec_name": "aac", "channels": 2, "sample_rate": "48000", "tags": {"language": "eng"}},
+		{"codec_type": "audio", "codec_name": "aac", "channels": 2, "sample_rate": "48000", "tags": {"language": "eng"}}
+	], "format": {"format_name": "hls"}}`
+	script := "#!/bin/sh\ncat <<'EOF'\n" + output + "\nEOF\n"
+	if err := os.WriteFile(ffprobe, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHandlers("")
+	h.ctx.Config.FFprobePath = ffprobe
+	h.ctx.Config.ManifestMaxSize = 1 << 20
+	client := httpclient.New(&config.Config{}, h.log)
+	streamHandlers := registry.NewStreamHandlerRegistry()
+	streamHandlers.Register(streams.NewHLSHandler(client, h.log, h.ctx.BaseURL))
+	streamHandlers.SetFallback(streams.NewGenericHandler(client, h.log))
+	extractorReg := registry.NewExtractorRegistry()
+	h.ctx.WithProxyService(services.NewProxyService(h.log, streamHandlers, extractorReg, h.ctx.BaseURL))
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/probe?url="+url.QueryEscape(upstream.URL+"/live/master.m3u8"), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var result types.ProbeResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if result.Type != types.StreamTypeHLS || result.Format != "hls" {
+		t.Errorf("type = %q, format = %q, want hls", result.Type, result.Format)
+	}
+	if len(result.Tracks) != 2 {
+		t.Fatalf("tracks = %+v, want video and one audio", result.Tracks)
+	}
+	if v := result.Tracks[0]; v.Height != 720 || v.FrameRate != "29.97" || v.Bitrate != 2500000 {
+		t.Errorf("video track = %+v", v)
+	}
+	if a := result.Tracks[1]; a.Codec != "aac" || a.Channels != 2 || a.SampleRate != 48000 || a.Language != "eng" {
+		t.Errorf("audio track = %+v", a)
+	}
+	if result.Bandwidth != 2500000 {
+		t.Errorf("bandwidth = %d, want 2500000", result.Bandwidth)
+	}
+	if result.DRM == nil || len(result.DRM.Systems) != 1 || result.DRM.Systems[0] != "fairplay" {
+		t.Errorf("drm = %+v, want fairplay", result.DRM)
+	}
+}
+
+func TestHandlers_ChannelHealth(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/live.m3u8" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n")
+	}))
+	defer upstream.Close()
+
+	h := newTestHandlers("")
+	h.ctx.Config.HealthCheckTimeout = 5 * time.Second
+	client := httpclient.New(&config.Config{}, h.log)
+	streamHandlers := registry.NewStreamHandlerRegistry()
+	streamHandlers.Register(streams.NewHLSHandler(client, h.log, h.ctx.BaseURL))
+	streamHandlers.SetFallback(streams.NewGenericHandler(client, h.log))
+	proxy := services.NewProxyService(h.log, streamHandlers, registry.NewExtractorRegistry(), h.ctx.BaseURL)
+
+	store := channels.NewStore("", nil, h.log)
+	store.Set([]types.Channel{
+		{ID: "live", Name: "Live", URL: upstream.URL + "/live.m3u8"},
+		{ID: "dead", Name: "Dead", URL: upstream.URL + "/dead.m3u8"},
+	})
+	h.ctx.WithChannels(store)
+	monitor := health.NewMonitor(h.ctx.Config, store, proxy, h.log)
+	defer monitor.Close()
+	h.ctx.WithHealth(monitor)
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	var list []types.ChannelHealth
+	if err := json.Unmarshal(get("/api/health/channels").Body.Bytes(), &list); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(list) != 2 || list[0].Status != types.HealthStatusUnknown {
+		t.Fatalf("list before check = %+v", list)
+	}
+
+	monitor.CheckAll(context.Background())
+
+	if err := json.Unmarshal(get("/api/health/channels?status=down").Body.Bytes(), &list); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != "dead" || list[0].LastError == "" {
+		t.Errorf("down channels = %+v, want dead", list)
+	}
+
+	rec := get("/api/health/channels/live")
+	var live types.ChannelHealth
+	if err := json.Unmarshal(rec.Body.Bytes(), &live); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if live.Status != types.HealthStatusUp || live.Uptime != 100 || len(live.History) != 1 {
+		t.Errorf("live = %+v", live)
+	}
+
+	if rec := get("/api/health/channels/missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("missing channel status = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/health/channels/check", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("check status = %d, want 202", rec.Code)
+	}
+}
+
+func TestHandlers_writeStreamResponse_Streams(t *testing.T) {
+	h := newTestHandlers("")
+	body, upstream := io.Pipe()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.writeStreamResponse(w, r, &types.StreamResponse{ContentType: "video/MP2T", Body: body, StatusCode: http.StatusOK})
+	}))
+	defer server.Close()
+
+	// The first chunk reaches the client while upstream is still sending
+	go upstream.Write([]byte("first"))
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	got := make([]byte, 5)
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadFull(resp.Body, got)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil || string(got) != "first" {
+			t.Fatalf("first chunk = %q, %v", got, err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("first chunk was buffered until the body ended")
+	}
+
+	upstream.Write([]byte("-rest"))
+	upstream.Close()
+	rest, _ := io.ReadAll(resp.Body)
+	if string(rest) != "-rest" {
+		t.Errorf("rest = %q, want %q", rest, "-rest")
+	}
+}
+
+// stubCatalog is a ChannelCatalog returning fixed channels per group.
+type stubCatalog map[string][]types.Channel
+
+func (c stubCatalog) Catalog(ctx context.Context, groups []string) ([]types.Channel, error) {
+	var result []types.Channel
+	for _, group := range groups {
+		result = append(result, c[group]...)
+	}
+	return result, nil
+}
+
+func TestHandlers_Vavoo_ChannelsAndImport(t *testing.T) {
+	h := newTestHandlers("")
+	h.ctx.WithChannels(channels.NewStore("", nil, h.log))
+	h.ctx.WithVavooCatalog(stubCatalog{
+		"Germany": {{ID: "vavoo-1", Name: "Das Erste", Group: "Germany", URL: "https://vavoo.to/play/1/index.m3u8"}},
+		"Italy": {
+			{ID: "vavoo-2", Name: "Rai 1", Group: "Italy", URL: "https://vavoo.to/play/2/index.m3u8"},
+			{ID: "vavoo-3", Name: "Rai 2", Group: "Italy", URL: "https://vavoo.to/play/3/index.m3u8"},
+		},
+	})
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/api/vavoo/channels?country=Germany,Italy&search=rai", "")
+	var list []types.Channel
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode list: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "Rai 1" {
+		t.Errorf("channels = %+v, want Rai 1 and Rai 2", list)
+	}
+
+	rec = do(http.MethodGet, "/api/vavoo/channels?country=Germany&format=m3u", "")
+	if body := rec.Body.String(); !strings.HasPrefix(body, "#EXTM3U") || !strings.Contains(body, "/proxy/manifest.m3u8?") || !strings.Contains(body, ",Das Erste") {
+		t.Errorf("playlist = %q", body)
+	}
+
+	rec = do(http.MethodPost, "/api/vavoo/import", `{"countries": ["Germany"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("import status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	rec = do(http.MethodPost, "/api/vavoo/import", `{"countries": ["Italy", "Germany"], "append": true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("append status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if count := h.ctx.Channels.Count(); count != 3 {
+		t.Errorf("channel count = %d, want 3 (duplicates skipped)", count)
+	}
+
+	if rec := do(http.MethodPost, "/api/vavoo/import", `{"countries": ["Atlantis"]}`); rec.Code != http.StatusNotFound {
+		t.Errorf("import of unknown country status = %d, want 404", rec.Code)
+	}
+}
+
+func TestHandlers_Download_Range(t *testing.T) {
+	content := strings.NewReader("0123456789abcdefghij")
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "movie.mkv", time.Time{}, content)
+	}))
+	defer upstream.Close()
+
+	h := newTestHandlers("")
+	client := httpclient.New(&config.Config{}, h.log)
+	streamHandlers := registry.NewStreamHandlerRegistry()
+	streamHandlers.SetFallback(streams.NewGenericHandler(client, h.log))
+	extractorReg := registry.NewExtractorRegistry()
+	extractorReg.SetFallback(extractors.NewGenericExtractor(client, h.log))
+	h.ctx.WithProxyService(services.NewProxyService(h.log, streamHandlers, extractorReg, h.ctx.BaseURL))
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/download?url="+url.QueryEscape(upstream.URL+"/files/movie.mkv"), nil)
+	req.Header.Set("Range", "bytes=10-14")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want 206, body = %s", rec.Code, rec.Body.String())
+	}
+	if rec.Body.String() != "abcde" {
+		t.Errorf("body = %q, want %q", rec.Body.String(), "abcde")
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 10-14/20" {
+		t.Errorf("Content-Range = %q", got)
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename=movie.mkv` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/download?url="+url.QueryEscape(upstream.URL+"/live/index.m3u8"), nil)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("manifest download status = %d, want 400", rec.Code)
+	}
+}
+
+func TestDownloadFilename(t *testing.T) {
+	tests := []struct {
+		fileURL     string
+		pageURL     string
+		contentType string
+		want        string
+	}{
+		{"https://s-delivery1.mxdcontent.net/v/abc.mp4?s=x", "https://mixdrop.co/e/abc", "video/mp4", "abc.mp4"},
+		{"https://streamtape.com/get_video?id=1&token=x", "https://streamtape.com/e/XyZ/My_Movie.mkv", "video/x-matroska", "My_Movie.mkv"},
+		{"https://streamtape.com/get_video?id=1", "https://streamtape.com/e/XyZ", "", "XyZ.mp4"},
+		{"https://cdn.example.com/", "https://example.com/", "", "download.mp4"},
+	}
+
+	for _, tt := range tests {
+		if got := downloadFilename(tt.fileURL, tt.pageURL, tt.contentType); got != tt.want {
+			t.Errorf("downloadFilename(%q, %q) = %q, want %q", tt.fileURL, tt.pageURL, got, tt.want)
+		}
+	}
+}
+
+func TestParseVariantFilter(t *testing.T) {
+	tests := []struct {
+		query string
+		want  *types.VariantFilter
+	}{
+		{"url=x", nil},
+		{"max_resolution=720p", &types.VariantFilter{MaxHeight: 720}},
+		{"max_resolution=1280x720&min_bandwidth=1.5M", &types.VariantFilter{MaxWidth: 1280, MaxHeight: 720, MinBandwidth: 1500000}},
+		{"min_bandwidth=800k&audio_lang=de,en&drop_subtitles=true", &types.VariantFilter{MinBandwidth: 800000, AudioLangs: []string{"de", "en"}, DropSubtitles: true}},
+		{"quality=best", &types.VariantFilter{Pin: types.PinBest}},
+		{"quality=worst", &types.VariantFilter{Pin: types.PinWorst}},
+		{"quality=1080p", &types.VariantFilter{MaxHeight: 1080, Pin: types.PinBest}},
+		{"quality=3M", &types.VariantFilter{MaxBandwidth: 3000000, Pin: types.PinBest}},
+		{"quality=audio", &types.VariantFilter{AudioOnly: true, Pin: types.PinBest}},
+		{"quality=audio:128k", &types.VariantFilter{AudioOnly: true, MaxBandwidth: 128000, Pin: types.PinBest}},
+		{"quality=bogus", nil},
+		{"audio_codec=aac,ec-3&prefer_ec3=1", &types.VariantFilter{AudioCodecs: []string{"aac", "ec-3"}, PreferEC3: true}},
+		{"device=Chromecast", &types.VariantFilter{AudioCodecs: []string{"aac"}, Device: types.DeviceChromecast}},
+		{"device=chromecast&audio_codec=mp4a.40.5", &types.VariantFilter{AudioCodecs: []string{"mp4a.40.5"}, Device: types.DeviceChromecast}},
+	}
+
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		got := parseVariantFilter(query)
+		if (got == nil) != (tt.want == nil) || (got != nil && fmt.Sprint(*got) != fmt.Sprint(*tt.want)) {
+			t.Errorf("parseVariantFilter(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestHandlers_Events_Stream(t *testing.T) {
+	h := newTestHandlers("")
+	h.ctx.WithEvents(notify.NewBroker())
+
+	server := httptest.NewServer(http.HandlerFunc(h.handleEvents))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET /api/events error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() (string, types.Event) {
+		var name string
+		var event types.Event
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("failed to read event: %v", err)
+			}
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event)
+			case line == "" && name != "":
+				return name, event
+			}
+		}
+	}
+
+	// A stats snapshot is sent on connect
+	name, event := readEvent()
+	if name != string(types.EventServerStats) || event.Stats == nil {
+		t.Fatalf("first event = %q %+v, want server.stats", name, event)
+	}
+
+	h.ctx.Events.Publish(types.Event{Type: types.EventRecordingStarted, Message: "started"})
+	for {
+		name, event = readEvent()
+		if name != string(types.EventServerStats) {
+			break
+		}
+	}
+	if name != string(types.EventRecordingStarted) || event.Message != "started" {
+		t.Errorf("event = %q %+v, want recording.started", name, event)
+	}
+}
+
+func TestHandlers_Sessions_ListAndTerminate(t *testing.T) {
+	h := newTestHandlers("")
+	h.ctx.WithSessions(sessions.NewTracker(30*time.Second, nil))
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	// A stream request that blocks until its session is terminated
+	started := make(chan struct{})
+	stream := h.trackStream(true, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+	done := make(chan struct{})
+	go func() {
+		stream(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/proxy/manifest.m3u8?url=https://cdn.example.com/live.m3u8", nil))
+		close(done)
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/sessions", nil))
+	var list []types.Session
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode sessions: %v", err)
+	}
+	if len(list) != 1 || list[0].URL != "https://cdn.example.com/live.m3u8" {
+		t.Fatalf("sessions = %+v", list)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/sessions/"+list[0].ID, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("terminate status = %d", rec.Code)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("in-flight request was not cancelled")
+	}
+
+	rec = httptest.NewRecorder()
+	stream(rec, httptest.NewRequest(http.MethodGet, "/proxy/manifest.m3u8?url=https://cdn.example.com/live.m3u8", nil))
+	if rec.Code != http.StatusGone {
+		t.Errorf("retry status = %d, want 410", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/sessions/"+list[0].ID, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("second terminate status = %d, want 404", rec.Code)
+	}
+}
+
+func TestHandlers_StreamStats(t *testing.T) {
+	h := newTestHandlers("")
+	h.ctx.WithSessions(sessions.NewTracker(30*time.Second, nil))
+	h.ctx.WithStreamStats(streamstats.NewCollector(time.Hour))
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	request := func(entry bool, target string, handler http.HandlerFunc) {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.RemoteAddr = "10.0.0.1:5000"
+		h.trackStream(entry, handler)(httptest.NewRecorder(), r)
+	}
+	ok := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, body) }
+	}
+
+	// Segments are counted towards the manifest their client is playing
+	request(true, "/proxy/manifest.m3u8?url=https://cdn.example.com/live.m3u8", ok("#EXTM3U\n"))
+	request(false, "/proxy/hls/segment.ts?d=https://seg.example.net/1.ts", ok("segment"))
+	request(false, "/proxy/hls/segment.ts?d=https://seg.example.net/2.ts", func(w http.ResponseWriter, r *http.Request) {
+		h.writeError(w, r, http.StatusBadGateway, "upstream returned 403")
+	})
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats/streams?sort=errors", nil))
+	var list []types.StreamStats
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode stats: %v", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("stats = %+v, want one stream", list)
+	}
+	s := list[0]
+	if s.URL != "https://cdn.example.com/live.m3u8" || s.Manifests != 1 || s.Segments != 2 || s.Bytes == 0 {
+		t.Errorf("stats = %+v", s)
+	}
+	if s.Errors != 1 || s.LastError != "502: upstream returned 403" {
+		t.Errorf("Errors = %d, LastError = %q", s.Errors, s.LastError)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats/streams?sort=name", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown sort status = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/stats/streams", nil))
+	if rec.Code != http.StatusOK || len(h.ctx.StreamStats.List()) != 0 {
+		t.Errorf("reset status = %d, streams left = %d", rec.Code, len(h.ctx.StreamStats.List()))
+	}
+}
+
+func TestHandlers_DebugEndpoints(t *testing.T) {
+	h := newTestHandlers("secret")
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/debug/runtime?api_password=secret", nil))
+	if strings.Contains(rec.Body.String(), "goroutines") {
+		t.Error("runtime info served without DEBUG_ENDPOINTS")
+	}
+
+	h = newTestHandlers("secret")
+	h.ctx.Config.DebugEndpoints = true
+	mux = http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine", "/api/debug/runtime"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s without password: status = %d, want 401", path, rec.Code)
+		}
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path+"?api_password=secret", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", path, rec.Code)
+		}
+	}
+
+	// A running child process is reported by command name
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("no /proc")
+	}
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	defer cmd.Process.Kill()
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/debug/runtime?api_password=secret", nil))
+	var info types.RuntimeInfo
+	if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+		t.Fatalf("failed to decode runtime info: %v", err)
+	}
+	if info.Goroutines == 0 || info.HeapAlloc == 0 || info.Processes["sleep"] != 1 {
+		t.Errorf("runtime info = %+v", info)
+	}
+}
+
+func TestHandlers_FFmpegUnavailable(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	h := newTestHandlers("")
+	h.ctx.Config.FFmpegPath = filepath.Join(t.TempDir(), "ffmpeg") // Must not be run
+	h.ctx.WithFFmpeg(&types.FFmpegInfo{
+		Path:     h.ctx.Config.FFmpegPath,
+		Error:    "not found",
+		Features: map[string]bool{types.FeatureDecryptRemux: false},
+	})
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/info", nil))
+	var info struct {
+		FFmpeg   types.FFmpegInfo `json:"ffmpeg"`
+		Features map[string]bool  `json:"features"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("invalid /api/info JSON: %v", err)
+	}
+	if info.FFmpeg.Available || info.FFmpeg.Error != "not found" {
+		t.Errorf("ffmpeg = %+v, want unavailable", info.FFmpeg)
+	}
+	for _, feature := range []string{types.FeatureDecryptRemux, types.FeatureRecording, types.FeatureTranscode} {
+		if enabled, ok := info.Features[feature]; !ok || enabled {
+			t.Errorf("features[%s] = %v (present %v), want false", feature, enabled, ok)
+		}
+	}
+
+	// Segments are served decrypted but not remuxed
+	path := "/decrypt/segment.ts?skip_decrypt=1&init_url=" + url.QueryEscape(upstream.URL+"/init") +
+		"&url=" + url.QueryEscape(upstream.URL+"/seg")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "video/mp4" {
+		t.Fatalf("status = %d, content type = %q, want 200 video/mp4", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if got := rec.Body.String(); got != "/init/seg" {
+		t.Errorf("body = %q, want init and segment concatenated", got)
+	}
+}
+
+func TestHandlers_DecryptSegment_InitCache(t *testing.T) {
+	var initHits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/init" {
+			initHits.Add(1)
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	h := newTestHandlers("")
+	h.inits = newInitCache(1 << 20)
+	h.ctx.WithFFmpeg(&types.FFmpegInfo{Features: map[string]bool{types.FeatureDecryptRemux: false}})
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	for _, seg := range []string{"/seg1", "/seg2", "/seg3"} {
+		path := "/decrypt/segment.ts?skip_decrypt=1&init_url=" + url.QueryEscape(upstream.URL+"/init") +
+			"&url=" + url.QueryEscape(upstream.URL+seg)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if got := rec.Body.String(); rec.Code != http.StatusOK || got != "/init"+seg {
+			t.Fatalf("status = %d, body = %q, want /init%s", rec.Code, got, seg)
+		}
+	}
+	if n := initHits.Load(); n != 1 {
+		t.Errorf("init segment fetched %d times, want 1", n)
+	}
+	if stats := h.inits.stats(); stats.Entries != 1 || stats.Bytes != int64(len("/init")) {
+		t.Errorf("init cache = %+v, want one 5 byte entry", stats)
+	}
+}
+
+func TestHandlers_DecryptSegment_Saturated(t *testing.T) {
+	h := newTestHandlers("")
+	h.decrypts = workpool.New(1, 0)
+	release, _ := h.decrypts.Acquire(t.Context(), "other")
+	defer release()
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/decrypt/segment.ts?url=http://origin/seg.m4s", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got == "" {
+		t.Error("Retry-After header missing")
+	}
+	if stats := h.decrypts.Stats(); stats.Rejected != 1 {
+		t.Errorf("rejected = %d, want 1", stats.Rejected)
+	}
+}
+
+func TestInitCache(t *testing.T) {
+	c := newInitCache(64)
+	fetched := make(map[string]int)
+	get := func(u string, size int) {
+		t.Helper()
+		data, err := c.get(t.Context(), u, func(context.Context) ([]byte, error) {
+			fetched[u]++
+			return bytes.Repeat([]byte("x"), size), nil
+		})
+		if err != nil || len(data) != size {
+			t.Fatalf("get(%s) = %d bytes, %v", u, len(data), err)
+		}
+	}
+
+	get("a", 8)
+	get("b", 8)
+	get("a", 8)
+	if fetched["a"] != 1 {
+		t.Errorf("a fetched %d times, want 1", fetched["a"])
+	}
+
+	// Too big to cache
+	get("big", 16)
+	get("big", 16)
+	if fetched["big"] != 2 {
+		t.Errorf("big fetched %d times, want 2 (not cached)", fetched["big"])
+	}
+
+	// Filling the cache evicts the least recently used: b
+	for _, u := range []string{"c", "d", "e", "f", "g", "h", "i"} {
+		get(u, 8)
+	}
+	get("a", 8)
+	get("b", 8)
+	if fetched["a"] != 1 || fetched["b"] != 2 {
+		t.Errorf("fetches a = %d, b = %d, want 1 and 2", fetched["a"], fetched["b"])
+	}
+	if stats := c.stats(); stats.Bytes > 64 {
+		t.Errorf("cache holds %d bytes, over its 64 byte limit", stats.Bytes)
+	}
+
+	// Failed fetches are not cached
+	fail := func(context.Context) ([]byte, error) { return nil, fmt.Errorf("HTTP 404") }
+	if _, err := c.get(t.Context(), "missing", fail); err == nil {
+		t.Error("get(missing) should fail")
+	}
+	get("missing", 8)
+}
+
+func TestInitCache_ConcurrentFetch(t *testing.T) {
+	c := newInitCache(1 << 20)
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	fetch := func(context.Context) ([]byte, error) {
+		fetches.Add(1)
+		<-release
+		return []byte("init"), nil
+	}
+
+	results := make(chan string, 4)
+	for range 4 {
+		go func() {
+			data, _ := c.get(context.Background(), "init", fetch)
+			results <- string(data)
+		}()
+	}
+	// Let the requests queue up behind the first fetch
+	for deadline := time.Now().Add(time.Second); fetches.Load() == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	for range 4 {
+		if got := <-results; got != "init" {
+			t.Errorf("get() = %q, want init", got)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetched %d times, want 1", n)
+	}
+}
+
+// fakeFFmpeg writes a script standing in for FFmpeg, copying stdin to stdout.
+func fakeFFmpeg(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nexec cat\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("no cat")
+	}
+	return path
+}
+
+// waitIdle waits until the pool has n warm processes.
+func waitIdle(t *testing.T, p *remuxPool, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.stats().Idle != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("idle = %d, want %d", p.stats().Idle, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHandlers_remuxToTS_WarmPool(t *testing.T) {
+	h := newTestHandlers("")
+	h.ctx.Config.FFmpegPath = fakeFFmpeg(t)
+	h.remuxes = newRemuxPool(h.ctx.Config.FFmpegPath, 2, h.log)
+	defer h.remuxes.drain()
+
+	remux := func(content string) {
+		t.Helper()
+		out, err := h.remuxToTS(t.Context(), []byte(content), false)
+		if err != nil || out.String() != content {
+			t.Fatalf("remuxToTS() = %q, %v, want %q", out, err, content)
+		}
+	}
+
+	remux("first") // Starts its own process and fills the pool
+	waitIdle(t, h.remuxes, 2)
+	remux("second")
+	if stats := h.remuxes.stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("stats = %+v, want 1 hit and 1 miss", stats)
+	}
+
+	// A warm process that died while idle is replaced by a new one
+	waitIdle(t, h.remuxes, 2)
+	h.remuxes.mu.Lock()
+	for _, proc := range h.remuxes.idle {
+		proc.cmd.Process.Kill()
+	}
+	h.remuxes.mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	remux("third")
+
+	// Keyframe extraction doesn't use the pool
+	hits := h.remuxes.stats().Hits
+	if _, err := h.remuxToTS(t.Context(), []byte("key"), true); err != nil {
+		t.Fatalf("remuxToTS(keyframe) error = %v", err)
+	}
+	if stats := h.remuxes.stats(); stats.Hits != hits || stats.Misses != 1 {
+		t.Errorf("stats after keyframe = %+v", stats)
+	}
+}
+
+func TestRemuxPool_ArgsChange(t *testing.T) {
+	p := newRemuxPool(fakeFFmpeg(t), 1, logging.New("error", false, nil))
+	defer p.drain()
+
+	if proc := p.take([]string{"-a"}); proc != nil {
+		t.Fatal("take() on an empty pool returned a process")
+	}
+	waitIdle(t, p, 1)
+	if proc := p.take([]string{"-b"}); proc != nil {
+		t.Fatal("take() returned a process started with other arguments")
+	}
+	waitIdle(t, p, 1)
+	proc := p.take([]string{"-b"})
+	if proc == nil {
+		t.Fatal("take() returned no warm process")
+	}
+	proc.kill()
+
+	p.drain()
+	if stats := p.stats(); stats.Idle != 0 {
+		t.Errorf("idle after drain = %d", stats.Idle)
+	}
+}
+
+func TestHandlers_Play(t *testing.T) {
+	h := newTestHandlers("")
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		want     []string
+	}{
+		{
+			name:     "hls through proxy manifest",
+			query:    "url=" + url.QueryEscape("https://cdn.example.com/live.m3u8") + "&h_referer=https://example.com/",
+			wantCode: http.StatusOK,
+			want:     []string{`"mode":"hls"`, `/proxy/manifest.m3u8?`, `h_Referer=`},
+		},
+		{
+			name:     "dash with clearkey via EME",
+			query:    "url=" + url.QueryEscape("https://cdn.example.com/live.mpd") + "&player=dash&clearkey=0123456789abcdef0123456789abcdef:fedcba9876543210fedcba9876543210",
+			wantCode: http.StatusOK,
+			want:     []string{`"mode":"dash"`, `"ASNFZ4mrze8BI0VniavN7w":"_ty6mHZUMhD-3LqYdlQyEA"`},
+		},
+		{
+			name:     "progressive file plays natively",
+			query:    "url=" + url.QueryEscape("https://cdn.example.com/movie.mp4"),
+			wantCode: http.StatusOK,
+			want:     []string{`"mode":"native"`, `/proxy/stream?`},
+		},
+		{
+			name:     "script injection is escaped",
+			query:    "url=" + url.QueryEscape("https://cdn.example.com/</script><script>alert(1)</script>.mp4"),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "invalid clearkey",
+			query:    "url=" + url.QueryEscape("https://cdn.example.com/live.mpd") + "&player=dash&clearkey=abc:def",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "missing url",
+			query:    "",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.handlePlay(rec, httptest.NewRequest(http.MethodGet, "/play?"+tt.query, nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			body := rec.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body missing %q", want)
+				}
+			}
+			if strings.Contains(body, "<script>alert(1)") {
+				t.Error("unescaped user input in page")
+			}
+		})
+	}
+}
+
+func TestHandlers_Index(t *testing.T) {
+	h := newTestHandlers("")
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{`id="testerForm"`, `"features":{"dvr":false`, web.Asset("tester.js")} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard missing %q", want)
+		}
+	}
+	if strings.Contains(body, `id="recordForm"`) {
+		t.Error("dashboard shows the recording form without a recording manager")
+	}
+
+	// The scripts the page links are served
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, web.Asset("tester.js"), nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "function probeTestStream") {
+		t.Errorf("tester.js status = %d", w.Code)
+	}
+}
+
+func TestHandlers_IP_Egress(t *testing.T) {
+	geo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"ip":"203.0.113.1","country":"DE","city":"Frankfurt","org":"AS64500 Home ISP"}`)
+	}))
+	defer geo.Close()
+	// An HTTP proxy that answers the lookup itself, as seen from its exit IP
+	vpn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host == "" {
+			t.Errorf("proxy got a non-proxy request for %s", r.URL)
+		}
+		io.WriteString(w, `{"query":"198.51.100.7","countryCode":"IT","country":"Italy","isp":"VPN Provider"}`)
+	}))
+	defer vpn.Close()
+
+	h := newTestHandlers("")
+	h.ctx.Config.GeoIPURL = geo.URL
+	h.ctx.Config.TransportRoutes = []config.TransportRoute{
+		{URLPattern: "vavoo.to", Proxy: strings.Replace(vpn.URL, "http://", "http://user:secret@", 1)},
+		{URLPattern: "broken.example", Proxy: "http://127.0.0.1:1"},
+	}
+	h.ctx.HTTPClient = httpclient.New(h.ctx.Config, h.log)
+
+	w := httptest.NewRecorder()
+	h.handleIP(w, httptest.NewRequest(http.MethodGet, "/proxy/ip", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		IP     string             `json:"ip"`
+		Egress []types.EgressInfo `json:"egress"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.IP != "203.0.113.1" || len(resp.Egress) != 3 {
+		t.Fatalf("response = %+v, want the default IP and 3 egresses", resp)
+	}
+
+	direct, vpnEgress, broken := resp.Egress[0], resp.Egress[1], resp.Egress[2]
+	if !direct.Default || direct.Proxy != "direct" || direct.Country != "DE" || direct.Org != "AS64500 Home ISP" {
+		t.Errorf("default egress = %+v", direct)
+	}
+	if vpnEgress.IP != "198.51.100.7" || vpnEgress.Country != "IT" || vpnEgress.Org != "VPN Provider" ||
+		!reflect.DeepEqual(vpnEgress.Routes, []string{"vavoo.to"}) || strings.Contains(vpnEgress.Proxy, "secret") {
+		t.Errorf("route egress = %+v", vpnEgress)
+	}
+	if broken.Error == "" || broken.IP != "" {
+		t.Errorf("unreachable proxy egress = %+v, want an error", broken)
+	}
+}
+
+func TestHandlers_RoutesTest(t *testing.T) {
+	h := newTestHandlers("")
+	h.ctx.Config.TransportRoutes = []config.TransportRoute{{URLPattern: "*.example.com", Match: config.RouteMatchHost, Direct: true}}
+	h.ctx.HTTPClient = httpclient.New(h.ctx.Config, h.log)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/routes/test?url="+url.QueryEscape("https://cdn.example.com/a.m3u8"), nil))
+	var got types.RouteTest
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("status %d: %v", w.Code, err)
+	}
+	if got.Transport != "route" || got.Route == nil || got.Route.Pattern != "*.example.com" {
+		t.Errorf("result = %+v, want the host route", got)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/routes/test", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status without url = %d, want 400", w.Code)
+	}
+}
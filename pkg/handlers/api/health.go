@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+
+	"media-proxy-go/pkg/types"
+)
+
+// handleListChannelHealth returns the health of monitored channels.
+// Optional filter: ?status=up|down|unknown.
+func (h *Handlers) handleListChannelHealth(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+
+	result := []types.ChannelHealth{}
+	for _, ch := range h.ctx.Health.List() {
+		if status != "" && ch.Status != status {
+			continue
+		}
+		result = append(result, ch)
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// handleGetChannelHealth returns the health and check history of one channel.
+func (h *Handlers) handleGetChannelHealth(w http.ResponseWriter, r *http.Request) {
+	ch, ok := h.ctx.Health.Get(r.PathValue("id"))
+	if !ok {
+		h.writeError(w, r, http.StatusNotFound, "channel not monitored")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, ch)
+}
+
+// handleCheckChannelHealth starts an immediate check of all monitored channels.
+func (h *Handlers) handleCheckChannelHealth(w http.ResponseWriter, r *http.Request) {
+	if !h.ctx.Health.Trigger() {
+		h.writeError(w, r, http.StatusConflict, "a health check is already running")
+		return
+	}
+	h.writeJSON(w, http.StatusAccepted, map[string]string{"status": "checking"})
+}
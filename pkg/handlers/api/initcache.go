@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"sync"
+
+	"media-proxy-go/pkg/bufpool"
+	"media-proxy-go/pkg/types"
+)
+
+// initCache is a size-capped LRU cache of fMP4 init segments by URL. Every
+// media segment of the decrypt path needs its representation's init segment,
+// which is the same for the whole stream, so it is fetched once instead of
+// once per segment. Concurrent requests for an init segment being fetched
+// wait for that fetch.
+type initCache struct {
+	mu       sync.Mutex
+	maxSize  int64
+	size     int64
+	lru      *list.List // Of *initEntry, most recently used first
+	entries  map[string]*list.Element
+	inflight map[string]*initFetch
+}
+
+type initEntry struct {
+	url  string
+	data []byte
+}
+
+// initFetch is a fetch of an init segment other requests can wait for.
+type initFetch struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// newInitCache returns a cache holding up to maxSize bytes, or nil (no
+// caching) when maxSize is 0.
+func newInitCache(maxSize int64) *initCache {
+	if maxSize <= 0 {
+		return nil
+	}
+	return &initCache{
+		maxSize:  maxSize,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		inflight: make(map[string]*initFetch),
+	}
+}
+
+// get returns the init segment at initURL, from the cache or with fetch. The
+// returned bytes must not be modified.
+func (c *initCache) get(ctx context.Context, initURL string, fetch func(context.Context) ([]byte, error)) ([]byte, error) {
+	if c == nil {
+		return fetch(ctx)
+	}
+
+	c.mu.Lock()
+	if el, ok := c.entries[initURL]; ok {
+		c.lru.MoveToFront(el)
+		c.mu.Unlock()
+		return el.Value.(*initEntry).data, nil
+	}
+	if f, ok := c.inflight[initURL]; ok {
+		c.mu.Unlock()
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if f.err == nil {
+			return f.data, nil
+		}
+		// The other request failed, possibly because its client went away
+		return fetch(ctx)
+	}
+	f := &initFetch{done: make(chan struct{})}
+	c.inflight[initURL] = f
+	c.mu.Unlock()
+
+	f.data, f.err = fetch(ctx)
+
+	c.mu.Lock()
+	delete(c.inflight, initURL)
+	if f.err == nil {
+		c.add(initURL, f.data)
+	}
+	c.mu.Unlock()
+	close(f.done)
+	return f.data, f.err
+}
+
+// add caches data, evicting the least recently used entries to make room.
+// Caller must hold c.mu.
+func (c *initCache) add(initURL string, data []byte) {
+	// Init segments are a few KiB; anything big is not worth evicting for
+	if int64(len(data)) > c.maxSize/8 {
+		return
+	}
+	for c.size+int64(len(data)) > c.maxSize {
+		el := c.lru.Back()
+		entry := el.Value.(*initEntry)
+		c.lru.Remove(el)
+		delete(c.entries, entry.url)
+		c.size -= int64(len(entry.data))
+	}
+	c.entries[initURL] = c.lru.PushFront(&initEntry{url: initURL, data: data})
+	c.size += int64(len(data))
+}
+
+// stats returns the number and total size of cached init segments.
+func (c *initCache) stats() types.CacheSize {
+	if c == nil {
+		return types.CacheSize{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return types.CacheSize{Entries: c.lru.Len(), Bytes: c.size}
+}
+
+// fetchInit fetches an init segment through the init segment cache into a
+// pooled buffer, which the caller must hand back with bufpool.Put.
+func (h *Handlers) fetchInit(ctx context.Context, initURL string, headers map[string]string) (*bytes.Buffer, error) {
+	data, err := h.inits.get(ctx, initURL, func(ctx context.Context) ([]byte, error) {
+		buf, err := h.fetchURL(ctx, initURL, headers)
+		if err != nil {
+			return nil, err
+		}
+		defer bufpool.Put(buf)
+		return bytes.Clone(buf.Bytes()), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf := bufpool.Get()
+	buf.Write(data)
+	return buf, nil
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// keyCacheTTL is how long fetched AES-128 keys are reused. Players request
+	// the key of every segment in a rotation period, often in bursts.
+	keyCacheTTL = 30 * time.Second
+	// maxCachedKeys bounds the key cache.
+	maxCachedKeys = 256
+)
+
+// keyCache briefly caches HLS AES-128 keys by URL.
+type keyCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedKey
+}
+
+type cachedKey struct {
+	data    []byte
+	expires time.Time
+}
+
+func newKeyCache() *keyCache {
+	return &keyCache{entries: make(map[string]cachedKey)}
+}
+
+// get returns the cached key for keyURL, if fresh.
+func (c *keyCache) get(keyURL string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[keyURL]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.data, true
+}
+
+// len returns the number of cached keys, including expired ones not yet dropped.
+func (c *keyCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// put caches a key, dropping expired entries when the cache is full.
+func (c *keyCache) put(keyURL string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= maxCachedKeys {
+		for u, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, u)
+			}
+		}
+		if len(c.entries) >= maxCachedKeys {
+			c.entries = make(map[string]cachedKey)
+		}
+	}
+	c.entries[keyURL] = cachedKey{data: data, expires: now.Add(keyCacheTTL)}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"media-proxy-go/pkg/crypto"
+	"media-proxy-go/pkg/keys"
+)
+
+// handleListKeys returns the stored ClearKey keys.
+func (h *Handlers) handleListKeys(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, h.ctx.Keys.List())
+}
+
+// handleGetKey returns the key stored for a KID.
+func (h *Handlers) handleGetKey(w http.ResponseWriter, r *http.Request) {
+	k, ok := h.ctx.Keys.Get(r.PathValue("kid"))
+	if !ok {
+		h.writeError(w, r, http.StatusNotFound, keys.ErrNotFound.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, k)
+}
+
+// handleSetKey adds or replaces a key: POST /api/keys with {"kid","key","label"},
+// or PUT /api/keys/{kid} with {"key","label"}.
+func (h *Handlers) handleSetKey(w http.ResponseWriter, r *http.Request) {
+	var k keys.Key
+	if err := json.NewDecoder(r.Body).Decode(&k); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if kid := r.PathValue("kid"); kid != "" {
+		k.KID = kid
+	}
+
+	_, existed := h.ctx.Keys.Get(k.KID)
+	stored, err := h.ctx.Keys.Set(k)
+	if err != nil {
+		if errors.Is(err, keys.ErrInvalidKey) {
+			h.writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.log.Error("❌ failed to save key", "error", err)
+		h.writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	status := http.StatusCreated
+	if existed {
+		status = http.StatusOK
+	}
+	h.writeJSON(w, status, stored)
+}
+
+// handleDeleteKey removes a key.
+func (h *Handlers) handleDeleteKey(w http.ResponseWriter, r *http.Request) {
+	if err := h.ctx.Keys.Delete(r.PathValue("kid")); err != nil {
+		if errors.Is(err, keys.ErrNotFound) {
+			h.writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		h.log.Error("❌ failed to save keys", "error", err)
+		h.writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// storedKeys looks up the keys for the KIDs of an init segment, returning
+// comma-separated key IDs and keys as accepted by crypto.DecryptSegmentWithKeys.
+func (h *Handlers) storedKeys(initSegment []byte) (string, string) {
+	if h.ctx.Keys == nil {
+		return "", ""
+	}
+
+	var kids, values []string
+	for _, kid := range crypto.DefaultKIDs(initSegment) {
+		if key, ok := h.ctx.Keys.Lookup(kid); ok {
+			kids = append(kids, kid)
+			values = append(values, key)
+		}
+	}
+	return strings.Join(kids, ","), strings.Join(values, ",")
+}
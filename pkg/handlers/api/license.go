@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"media-proxy-go/pkg/crypto"
+	"media-proxy-go/pkg/middleware"
+)
+
+// maxLicenseRequestSize bounds the POSTed license request body.
+const maxLicenseRequestSize = 64 << 10
+
+// licenseRequest is an EME ClearKey license request, as sent by the browser's
+// ClearKey CDM.
+type licenseRequest struct {
+	KIDs []string `json:"kids"` // base64url
+	Type string   `json:"type"` // "temporary" or "persistent-license"
+}
+
+// licenseKey is a JSON Web Key in a ClearKey license.
+type licenseKey struct {
+	Kty string `json:"kty"` // Always "oct"
+	KID string `json:"kid"` // base64url
+	K   string `json:"k"`   // base64url
+}
+
+// license is an EME ClearKey license response.
+type license struct {
+	Keys []licenseKey `json:"keys"`
+	Type string       `json:"type"`
+}
+
+// handleLicense serves ClearKey licenses: with the keys of the clearkey
+// parameter, or else from the key store for the KIDs the CDM asks for, which
+// needs the API password.
+func (h *Handlers) handleLicense(w http.ResponseWriter, r *http.Request) {
+	clearKey := r.URL.Query().Get("clearkey")
+	licenseURL := r.URL.Query().Get("url")
+	if clearKey == "" && licenseURL != "" {
+		h.proxyLicenseRequest(w, r, licenseURL)
+		return
+	}
+
+	var req licenseRequest
+	if r.Method == http.MethodPost {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxLicenseRequestSize))
+		if err != nil {
+			h.writeError(w, r, http.StatusBadRequest, "failed to read license request")
+			return
+		}
+		if len(bytes.TrimSpace(body)) > 0 {
+			if err := json.Unmarshal(body, &req); err != nil {
+				h.writeError(w, r, http.StatusBadRequest, "invalid license request: "+err.Error())
+				return
+			}
+		}
+	}
+
+	if clearKey == "" {
+		// Stored keys are as private as /api/keys
+		if !middleware.Authorized(h.ctx.Config, r, middleware.RouteAdmin) {
+			h.writeError(w, r, http.StatusUnauthorized, "Unauthorized: Invalid API Password")
+			return
+		}
+		clearKey = h.storedClearKeys(req.KIDs)
+		if clearKey == "" {
+			h.writeError(w, r, http.StatusBadRequest, "clearkey or url parameter required")
+			return
+		}
+	}
+	h.writeClearKeyLicense(w, r, clearKey, req)
+}
+
+// storedClearKeys returns the key store's keys for the requested base64url
+// KIDs as KID:KEY pairs, or "" if none are known.
+func (h *Handlers) storedClearKeys(kids []string) string {
+	if h.ctx.Keys == nil {
+		return ""
+	}
+	var pairs []string
+	for _, kid := range kids {
+		kid = crypto.NormalizeKID(kid)
+		if key, ok := h.ctx.Keys.Lookup(kid); ok {
+			pairs = append(pairs, kid+":"+key)
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+// writeClearKeyLicense writes a ClearKey license with the keys of clearKey
+// that req asks for (all of them if it names no KIDs).
+func (h *Handlers) writeClearKeyLicense(w http.ResponseWriter, r *http.Request, clearKey string, req licenseRequest) {
+	pairs, err := crypto.ParseClearKeys(clearKey)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	requested := make(map[string]bool, len(req.KIDs))
+	for _, kid := range req.KIDs {
+		requested[crypto.NormalizeKID(kid)] = true
+	}
+
+	resp := license{Keys: make([]licenseKey, 0, len(pairs)), Type: "temporary"}
+	if req.Type == "persistent-license" {
+		resp.Type = req.Type
+	}
+	for _, pair := range pairs {
+		if len(requested) > 0 && !requested[pair.KID] {
+			continue
+		}
+		kid, key := pair.Base64URL()
+		resp.Keys = append(resp.Keys, licenseKey{Kty: "oct", KID: kid, K: key})
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// proxyLicenseRequest proxies a license request.
+func (h *Handlers) proxyLicenseRequest(w http.ResponseWriter, r *http.Request, licenseURL string) {
+	// Implementation for license proxying
+	h.writeError(w, r, http.StatusNotImplemented, "license proxy not implemented")
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/middleware"
+	"media-proxy-go/pkg/urlcrypt"
+	"media-proxy-go/pkg/urlsign"
+)
+
+// mediaFlowLink is a link to generate, as in the body of MediaFlow-Proxy's
+// /generate_url and the urls of /generate_urls.
+type mediaFlowLink struct {
+	Endpoint       string            `json:"endpoint"`
+	DestinationURL string            `json:"destination_url"`
+	QueryParams    map[string]string `json:"query_params"`
+	RequestHeaders map[string]string `json:"request_headers"`
+	Filename       string            `json:"filename"`
+}
+
+// mediaFlowRequest is the body of /generate_url and /generate_urls. The
+// settings apply to every link.
+type mediaFlowRequest struct {
+	mediaFlowLink
+	MediaflowProxyURL string          `json:"mediaflow_proxy_url"`
+	APIPassword       string          `json:"api_password"`
+	Expiration        int             `json:"expiration"` // Seconds the links are valid for, 0 for no expiry
+	IP                string          `json:"ip"`         // Client the links are restricted to
+	URLs              []mediaFlowLink `json:"urls"`
+}
+
+// handleGenerateURL builds a proxy link the way MediaFlow-Proxy's
+// /generate_url does, for addons that create their links through it.
+func (h *Handlers) handleGenerateURL(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeMediaFlowRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.DestinationURL == "" {
+		h.writeError(w, r, http.StatusBadRequest, "destination_url required")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]string{"url": h.mediaFlowURL(r, req, req.mediaFlowLink)})
+}
+
+// handleGenerateURLs builds the proxy links of a batch, like MediaFlow-Proxy's
+// /generate_urls.
+func (h *Handlers) handleGenerateURLs(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeMediaFlowRequest(w, r)
+	if !ok {
+		return
+	}
+	links := make([]string, 0, len(req.URLs))
+	for _, l := range req.URLs {
+		if l.DestinationURL == "" {
+			h.writeError(w, r, http.StatusBadRequest, "destination_url required")
+			return
+		}
+		links = append(links, h.mediaFlowURL(r, req, l))
+	}
+	h.writeJSON(w, http.StatusOK, map[string][]string{"urls": links})
+}
+
+// decodeMediaFlowRequest reads a link generation request. Like MediaFlow,
+// the API password may come in the body; the request isn't served without it
+// when one is configured.
+func (h *Handlers) decodeMediaFlowRequest(w http.ResponseWriter, r *http.Request) (*mediaFlowRequest, bool) {
+	var req mediaFlowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid JSON body")
+		return nil, false
+	}
+
+	authReq := r
+	if req.APIPassword != "" {
+		authReq = r.Clone(r.Context())
+		authReq.Header.Set("X-API-Password", req.APIPassword)
+	}
+	if !middleware.Authorized(h.ctx.Config, authReq, middleware.RouteStreaming) {
+		h.writeError(w, r, http.StatusUnauthorized, "Unauthorized: Invalid API Password")
+		return nil, false
+	}
+	return &req, true
+}
+
+// mediaFlowURL returns the proxy link of l. Links with an expiration or IP
+// are signed with the API password for their endpoint only instead of
+// carrying it, and the query is encrypted when URL_ENCRYPTION_KEY is set.
+func (h *Handlers) mediaFlowURL(r *http.Request, req *mediaFlowRequest, l mediaFlowLink) string {
+	endpoint := "/" + strings.TrimPrefix(l.Endpoint, "/")
+	if endpoint == "/" {
+		endpoint = "/proxy/stream"
+	}
+	path := endpoint
+	if l.Filename != "" && endpoint == "/proxy/stream" {
+		endpoint += "/" + url.PathEscape(l.Filename)
+		path += "/" + l.Filename
+	}
+	base := strings.TrimSuffix(req.MediaflowProxyURL, "/")
+	if base == "" {
+		base = h.publicBaseURL(r)
+	}
+
+	query := url.Values{}
+	for name, value := range l.QueryParams {
+		query.Set(name, value)
+	}
+	query.Set("d", l.DestinationURL)
+	for name, value := range l.RequestHeaders {
+		query.Set("h_"+strings.ToLower(name), value)
+	}
+
+	password := h.ctx.Config.StreamingPassword()
+	if password != "" && (req.Expiration > 0 || req.IP != "") {
+		query.Del("api_password")
+		if req.IP != "" {
+			query.Set(urlsign.ParamIP, req.IP)
+		}
+		// The signature covers only the query: pin it to the endpoint
+		query.Set(urlsign.ParamPath, path)
+		var expires time.Time
+		if req.Expiration > 0 {
+			expires = time.Now().Add(time.Duration(req.Expiration) * time.Second)
+		}
+		urlsign.Sign(password, query, expires)
+	} else if req.APIPassword != "" && !query.Has("api_password") {
+		query.Set("api_password", req.APIPassword)
+	}
+
+	if cipher := h.ctx.URLCipher; cipher != nil {
+		return base + endpoint + "?" + urlcrypt.Param + "=" + cipher.Seal(query.Encode())
+	}
+	return base + endpoint + "?" + query.Encode()
+}
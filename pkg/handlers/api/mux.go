@@ -0,0 +1,165 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"media-proxy-go/pkg/bufpool"
+	"media-proxy-go/pkg/crypto"
+	"media-proxy-go/pkg/tracing"
+)
+
+// muxWithAudio fetches and decrypts the audio segments of a muxed DASH-to-HLS
+// segment and muxes them with the decrypted video fragment into a single TS.
+// The caller must hand the returned buffer back with bufpool.Put.
+func (h *Handlers) muxWithAudio(ctx context.Context, video []byte, audioInitURL string, audioURLs []string, headers map[string]string, keyID, key string, skipDecrypt bool) (*bytes.Buffer, error) {
+	audioInit, segments, err := h.fetchAudioSegments(ctx, audioInitURL, audioURLs, headers)
+	if err != nil {
+		return nil, err
+	}
+	defer bufpool.Put(audioInit)
+	defer func() {
+		for _, segment := range segments {
+			bufpool.Put(segment)
+		}
+	}()
+
+	audio := bufpool.Get()
+	defer bufpool.Put(audio)
+	fragment := bufpool.Get()
+	defer bufpool.Put(fragment)
+
+	// Only the first fragment keeps its (decrypted) init segment
+	for i, segment := range segments {
+		if i == 0 {
+			h.decryptFragment(ctx, audio, audioInit.Bytes(), segment.Bytes(), keyID, key, skipDecrypt)
+			continue
+		}
+		fragment.Reset()
+		h.decryptFragment(ctx, fragment, audioInit.Bytes(), segment.Bytes(), keyID, key, skipDecrypt)
+		audio.Write(crypto.StripInitSegment(fragment.Bytes()))
+	}
+
+	return h.muxToTS(ctx, video, audio.Bytes())
+}
+
+// fetchAudioSegments fetches an audio init segment and its media segments in
+// parallel. The caller must hand all buffers back with bufpool.Put.
+func (h *Handlers) fetchAudioSegments(ctx context.Context, initURL string, segmentURLs []string, headers map[string]string) (*bytes.Buffer, []*bytes.Buffer, error) {
+	type result struct {
+		index int
+		data  *bytes.Buffer
+		err   error
+	}
+
+	results := make(chan result, len(segmentURLs))
+	for i, segmentURL := range segmentURLs {
+		go func() {
+			data, err := h.fetchURL(ctx, segmentURL, headers)
+			results <- result{index: i, data: data, err: err}
+		}()
+	}
+
+	initData := bufpool.Get()
+	if initURL != "" {
+		data, err := h.fetchInit(ctx, initURL, headers)
+		if err != nil {
+			h.log.Warn("⚠️ audio init segment fetch failed, continuing without it", "error", err)
+		} else {
+			bufpool.Put(initData)
+			initData = data
+		}
+	}
+
+	segments := make([]*bytes.Buffer, len(segmentURLs))
+	var firstErr error
+	for range segmentURLs {
+		res := <-results
+		if res.err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to fetch audio segment: %w", res.err)
+		}
+		segments[res.index] = res.data
+	}
+	if firstErr != nil {
+		bufpool.Put(initData)
+		for _, segment := range segments {
+			bufpool.Put(segment)
+		}
+		return nil, nil, firstErr
+	}
+
+	return initData, segments, nil
+}
+
+// muxToTS muxes separate fMP4 video and audio tracks into MPEG-TS using FFmpeg.
+// FFmpeg can only read one input from stdin, so both are written to temp files.
+// The caller must hand the returned buffer back with bufpool.Put.
+func (h *Handlers) muxToTS(ctx context.Context, video, audio []byte) (*bytes.Buffer, error) {
+	ctx, span := tracing.Start(ctx, "ffmpeg mux")
+	defer span.End()
+	span.SetAttr("input.size", len(video)+len(audio))
+
+	videoPath, err := writeTempFile("mux-video-*.mp4", video)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(videoPath)
+
+	audioPath, err := writeTempFile("mux-audio-*.mp4", audio)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(audioPath)
+
+	cmd := exec.CommandContext(ctx, h.ctx.Config.FFmpegPath,
+		"-y",
+		"-i", videoPath,
+		"-i", audioPath,
+		"-map", "0:v:0",
+		"-map", "1:a:0",
+		"-c", "copy",
+		"-copyts",
+		"-bsf:v", "h264_mp4toannexb",
+		"-f", "mpegts",
+		"pipe:1",
+	)
+
+	stdout := bufpool.Get()
+	stdout.Grow(len(video) + len(audio))
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		bufpool.Put(stdout)
+		err = fmt.Errorf("ffmpeg error: %v, stderr: %s", err, stderr.String())
+		span.SetError(err)
+		return nil, err
+	}
+	span.SetAttr("output.size", stdout.Len())
+
+	h.log.Debug("ffmpeg mux successful",
+		"video_size", len(video),
+		"audio_size", len(audio),
+		"output_size", stdout.Len(),
+	)
+	return stdout, nil
+}
+
+// writeTempFile writes data to a new temp file and returns its path.
+func writeTempFile(pattern string, data []byte) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	return f.Name(), nil
+}
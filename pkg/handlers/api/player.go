@@ -0,0 +1,209 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"media-proxy-go/pkg/crypto"
+	"media-proxy-go/pkg/middleware"
+	"media-proxy-go/pkg/types"
+)
+
+// Player modes for /play.
+const (
+	playerHLS    = "hls"    // hls.js on the proxied manifest (MPD is converted and decrypted server-side)
+	playerDASH   = "dash"   // dash.js on the original MPD, every request routed through /proxy/stream, ClearKey via EME
+	playerNative = "native" // <video> on /proxy/stream for progressive files
+)
+
+// playerConfig is passed to the player page script.
+type playerConfig struct {
+	Mode        string            `json:"mode"`
+	Source      string            `json:"source"`       // URL the player loads
+	ProxyPrefix string            `json:"proxy_prefix"` // dash: prefix for routing requests through the proxy
+	ProxyQuery  string            `json:"proxy_query"`  // dash: h_ params and api_password or a playback token appended to proxied requests
+	ClearKeys   map[string]string `json:"clearkeys,omitempty"`
+}
+
+// handlePlay serves a browser player for a stream: /play?url=...[&clearkey=KID:KEY][&h_*=...][&player=hls|dash|native].
+func (h *Handlers) handlePlay(w http.ResponseWriter, r *http.Request) {
+	req := h.parseStreamRequest(r)
+	if req.URL == "" {
+		h.writeError(w, r, http.StatusBadRequest, "url parameter required")
+		return
+	}
+
+	streamURL, streamType := req.URL, types.StreamTypeGeneric
+	if h.ctx.ProxyService != nil {
+		streamURL, streamType = h.ctx.ProxyService.StreamInfo(req.URL)
+	}
+
+	mode := r.URL.Query().Get("player")
+	if mode == "" {
+		switch streamType {
+		case types.StreamTypeMPD:
+			mode = playerDASH
+		case types.StreamTypeHLS:
+			mode = playerHLS
+		default:
+			// Extractor links resolve to HLS/MPD through /proxy/manifest.m3u8
+			mode = playerHLS
+			if isProgressive(streamURL) {
+				mode = playerNative
+			}
+		}
+	}
+
+	// Query shared by all proxied URLs: custom headers and the API password,
+	// unless a playback token stands in for it
+	shared := url.Values{}
+	for key, value := range req.Headers {
+		shared.Set("h_"+key, value)
+	}
+	tokens := h.ctx.PlaybackTokens != nil
+	if password := r.URL.Query().Get("api_password"); password != "" && !tokens {
+		shared.Set("api_password", password)
+	}
+
+	cfg := playerConfig{Mode: mode}
+	switch mode {
+	case playerDASH:
+		if tokens {
+			// dash.js requests the manifest and the segments next to it on
+			// its own, so the token is scoped to the manifest's directory
+			dir := streamURL
+			if u, err := url.Parse(streamURL); err == nil {
+				dir = u.ResolveReference(&url.URL{Path: "./"}).String()
+			}
+			// The page's player fetches as the same client
+			shared.Set("token", h.ctx.PlaybackTokens.IssueScoped(middleware.ClientIP(r, h.ctx.Config.TrustedProxies), r.UserAgent(), "/proxy/stream", dir))
+		}
+		cfg.Source = streamURL
+		cfg.ProxyPrefix = h.publicBaseURL(r) + "/proxy/stream?url="
+		cfg.ProxyQuery = shared.Encode()
+		if req.ClearKey != "" {
+			keys, err := clearKeysForEME(req.ClearKey)
+			if err != nil {
+				h.writeError(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			cfg.ClearKeys = keys
+		}
+	case playerHLS, playerNative:
+		endpoint := "/proxy/manifest.m3u8"
+		if mode == playerNative {
+			endpoint = "/proxy/stream"
+		}
+		shared.Set("url", streamURL)
+		if req.ClearKey != "" {
+			shared.Set("clearkey", req.ClearKey)
+		}
+		if token := h.issuePlaybackToken(r, endpoint, streamURL); token != "" {
+			shared.Set("token", token)
+		}
+		cfg.Source = h.publicBaseURL(r) + endpoint + "?" + shared.Encode()
+	default:
+		h.writeError(w, r, http.StatusBadRequest, "player must be hls, dash or native")
+		return
+	}
+
+	// json.Marshal escapes <, > and &, so the config is safe inside <script>
+	cfgJSON, err := json.Marshal(cfg)
+	if err != nil {
+		h.writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprintf(w, playerPage, cfgJSON)
+}
+
+// isProgressive reports whether rawURL points at a file the browser can play directly.
+func isProgressive(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".mp4", ".m4v", ".webm", ".mkv", ".mov", ".mp3", ".m4a", ".aac":
+		return true
+	}
+	return false
+}
+
+// clearKeysForEME converts KID:KEY pairs to the base64url map dash.js
+// expects for org.w3.clearkey.
+func clearKeysForEME(clearKey string) (map[string]string, error) {
+	pairs, err := crypto.ParseClearKeys(clearKey)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		kid, key := pair.Base64URL()
+		keys[kid] = key
+	}
+	return keys, nil
+}
+
+// playerPage is the /play page; %s is the JSON player config.
+const playerPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>MediaProxy Player</title>
+    <style>
+        * { box-sizing: border-box; margin: 0; padding: 0; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, sans-serif;
+            background: #0f0f0f; color: #ffffff; min-height: 100vh;
+            display: flex; flex-direction: column; align-items: center; justify-content: center; padding: 20px;
+        }
+        video { width: 100%%; max-width: 1280px; aspect-ratio: 16 / 9; background: #000; border-radius: 12px; }
+        .info { margin-top: 16px; font-size: 0.85rem; color: #a0a0a0; max-width: 1280px; width: 100%%; word-break: break-all; }
+        .error { color: #ef4444; margin-top: 12px; }
+        a { color: #3b82f6; }
+    </style>
+</head>
+<body>
+    <video id="video" controls autoplay playsinline></video>
+    <div class="info"><span id="mode"></span> · <a href="/">Dashboard</a><div id="error" class="error"></div></div>
+
+    <script src="https://cdn.jsdelivr.net/npm/hls.js@1/dist/hls.min.js"></script>
+    <script src="https://cdn.jsdelivr.net/npm/dashjs@4/dist/dash.all.min.js"></script>
+    <script>
+        const config = %s;
+        const video = document.getElementById('video');
+        const showError = msg => { document.getElementById('error').textContent = '❌ ' + msg; };
+        document.getElementById('mode').textContent = 'Player: ' + config.mode;
+
+        if (config.mode === 'dash') {
+            const player = dashjs.MediaPlayer().create();
+            // Route every request (manifest, segments) through the proxy for headers and CORS
+            player.extend('RequestModifier', () => ({
+                modifyRequestHeader: xhr => xhr,
+                modifyRequestURL: u => config.proxy_prefix + encodeURIComponent(u) + (config.proxy_query ? '&' + config.proxy_query : '')
+            }), true);
+            if (config.clearkeys) {
+                player.setProtectionData({ 'org.w3.clearkey': { clearkeys: config.clearkeys } });
+            }
+            player.on(dashjs.MediaPlayer.events.ERROR, e => showError((e.error && e.error.message) || 'playback error'));
+            player.initialize(video, config.source, true);
+        } else if (config.mode === 'hls' && window.Hls && Hls.isSupported()) {
+            const hls = new Hls();
+            hls.on(Hls.Events.ERROR, (_, data) => { if (data.fatal) showError(data.type + ': ' + data.details); });
+            hls.loadSource(config.source);
+            hls.attachMedia(video);
+        } else {
+            // Native playback: progressive files, or HLS on Safari/iOS
+            video.src = config.source;
+            video.addEventListener('error', () => showError('playback error'));
+        }
+    </script>
+</body>
+</html>`
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"media-proxy-go/pkg/channels"
+	"media-proxy-go/pkg/types"
+)
+
+// maxPlaylistSize limits uploaded playlists.
+const maxPlaylistSize = 20 << 20
+
+// handlePlaylistImport imports an M3U/JSON playlist, replacing the channel list.
+// Accepts a JSON body {"url": "..."}, a multipart form with a "file" field,
+// or the raw playlist as the request body.
+func (h *Handlers) handlePlaylistImport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPlaylistSize)
+	contentType := r.Header.Get("Content-Type")
+
+	var count int
+	var err error
+
+	switch {
+	case strings.HasPrefix(contentType, "application/json"):
+		var req struct {
+			URL string `json:"url"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
+			h.writeError(w, r, http.StatusBadRequest, "url is required")
+			return
+		}
+		if !strings.HasPrefix(req.URL, "http://") && !strings.HasPrefix(req.URL, "https://") {
+			h.writeError(w, r, http.StatusBadRequest, "url must be http or https")
+			return
+		}
+		count, err = h.ctx.Channels.Import(r.Context(), req.URL)
+
+	case strings.HasPrefix(contentType, "multipart/form-data"):
+		file, _, formErr := r.FormFile("file")
+		if formErr != nil {
+			h.writeError(w, r, http.StatusBadRequest, "file field is required")
+			return
+		}
+		defer file.Close()
+
+		data, readErr := io.ReadAll(file)
+		if readErr != nil {
+			h.writeError(w, r, http.StatusBadRequest, "failed to read playlist")
+			return
+		}
+		count, err = h.ctx.Channels.ImportData(data)
+
+	default:
+		data, readErr := io.ReadAll(r.Body)
+		if readErr != nil {
+			h.writeError(w, r, http.StatusBadRequest, "failed to read playlist")
+			return
+		}
+		count, err = h.ctx.Channels.ImportData(data)
+	}
+
+	if err != nil {
+		h.log.Error("❌ playlist import failed", "error", err)
+		h.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"channels": count,
+		"groups":   h.ctx.Channels.Groups(),
+	})
+}
+
+// handlePlaylistM3U serves the channel list as an M3U playlist with every
+// channel routed through the proxy. Optional ?group= filters by group.
+func (h *Handlers) handlePlaylistM3U(w http.ResponseWriter, r *http.Request) {
+	list := h.ctx.Channels.List()
+
+	if group := r.URL.Query().Get("group"); group != "" {
+		filtered := list[:0]
+		for _, ch := range list {
+			if ch.Group == group {
+				filtered = append(filtered, ch)
+			}
+		}
+		list = filtered
+	}
+
+	apiPassword, baseURL := h.ctx.Config.StreamingPassword(), h.publicBaseURL(r)
+	urlFor := func(ch types.Channel) string {
+		return channels.ProxyURL(baseURL, "/proxy/manifest.m3u8", ch, apiPassword, nil)
+	}
+
+	w.Header().Set("Content-Type", "audio/x-mpegurl")
+	w.Header().Set("Content-Disposition", `inline; filename="playlist.m3u"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := channels.WriteM3U(w, list, urlFor); err != nil {
+		h.log.Debug("failed to write playlist", "error", err)
+	}
+}
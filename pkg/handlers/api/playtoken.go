@@ -0,0 +1,108 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"media-proxy-go/pkg/middleware"
+	"media-proxy-go/pkg/playtoken"
+	"media-proxy-go/pkg/types"
+	"media-proxy-go/pkg/urlcrypt"
+)
+
+// issuePlaybackToken returns a playback token bound to the client of r for
+// requests to path with target, or "" when playback tokens are disabled.
+func (h *Handlers) issuePlaybackToken(r *http.Request, path, target string) string {
+	if h.ctx.PlaybackTokens == nil {
+		return ""
+	}
+	return h.ctx.PlaybackTokens.Issue(middleware.ClientIP(r, h.ctx.Config.TrustedProxies), r.UserAgent(), path, target)
+}
+
+// signManifest adds playback tokens issued to the client of r to the proxy
+// URLs of a proxied playlist, each for its own path and upstream target, so
+// its variants, segments and keys are only served to that client. Each
+// playlist refresh issues fresh tokens, which keeps live streams playing past
+// the token lifetime.
+func (h *Handlers) signManifest(r *http.Request, resp *types.StreamResponse) error {
+	if h.ctx.PlaybackTokens == nil || resp.StatusCode != http.StatusOK || resp.Body == nil ||
+		!strings.Contains(strings.ToLower(resp.ContentType), "mpegurl") {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	signed := signPlaylist(string(body), h.publicBaseURL(r)+"/", func(path string, query url.Values) string {
+		if sealed := query.Get(urlcrypt.Param); sealed != "" && h.ctx.URLCipher != nil {
+			// Requests are verified once decrypted
+			if opened, err := h.ctx.URLCipher.Open(sealed); err == nil {
+				query, _ = url.ParseQuery(opened)
+			}
+		}
+		return h.issuePlaybackToken(r, path, playtoken.Target(query))
+	})
+	if _, ok := resp.Headers["Content-Length"]; ok {
+		resp.Headers["Content-Length"] = strconv.Itoa(len(signed))
+	}
+	resp.Body = io.NopCloser(strings.NewReader(signed))
+	return nil
+}
+
+// signPlaylist adds a token to the URI lines and URI attributes of an HLS
+// playlist that point at base, issued with the route path and the query of
+// each URI.
+func signPlaylist(playlist, base string, issue func(path string, query url.Values) string) string {
+	return mapPlaylistURIs(playlist, func(u string) string {
+		if !strings.HasPrefix(u, base) {
+			return u
+		}
+		endpoint, rawQuery, hasQuery := strings.Cut(u, "?")
+		query, _ := url.ParseQuery(rawQuery)
+		param := "token=" + url.QueryEscape(issue("/"+strings.TrimPrefix(endpoint, base), query))
+		if hasQuery {
+			return u + "&" + param
+		}
+		return u + "?" + param
+	})
+}
+
+// mapPlaylistURIs replaces the URI lines and URI attributes of an HLS
+// playlist with fn's result.
+func mapPlaylistURIs(playlist string, fn func(string) string) string {
+	lines := strings.Split(playlist, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, "\r")
+		if !strings.HasPrefix(trimmed, "#") {
+			lines[i] = fn(trimmed) + line[len(trimmed):]
+			continue
+		}
+
+		var b strings.Builder
+		rest := line
+		for {
+			start := strings.Index(rest, `URI="`)
+			if start < 0 {
+				break
+			}
+			start += len(`URI="`)
+			end := strings.IndexByte(rest[start:], '"')
+			if end < 0 {
+				break
+			}
+			b.WriteString(rest[:start])
+			b.WriteString(fn(rest[start : start+end]))
+			rest = rest[start+end:]
+		}
+		b.WriteString(rest)
+		lines[i] = b.String()
+	}
+	return strings.Join(lines, "\n")
+}
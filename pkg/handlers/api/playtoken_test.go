@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/playtoken"
+	"media-proxy-go/pkg/types"
+)
+
+func TestSignPlaylist(t *testing.T) {
+	playlist := "#EXTM3U\r\n" +
+		`#EXT-X-KEY:METHOD=AES-128,URI="http://localhost:7860/key?url=k",IV=0x1` + "\r\n" +
+		`#EXT-X-MAP:URI="http://localhost:7860/proxy/hls/segment.mp4?url=init"` + "\r\n" +
+		"#EXTINF:4,\r\n" +
+		"http://localhost:7860/proxy/stream?url=seg1\r\n" +
+		"#EXTINF:4,\r\n" +
+		"https://cdn.example.com/direct.ts\r\n"
+
+	got := signPlaylist(playlist, "http://localhost:7860/", func(path string, query url.Values) string {
+		return path + ":" + query.Get("url")
+	})
+	want := "#EXTM3U\r\n" +
+		`#EXT-X-KEY:METHOD=AES-128,URI="http://localhost:7860/key?url=k&token=%2Fkey%3Ak",IV=0x1` + "\r\n" +
+		`#EXT-X-MAP:URI="http://localhost:7860/proxy/hls/segment.mp4?url=init&token=%2Fproxy%2Fhls%2Fsegment.mp4%3Ainit"` + "\r\n" +
+		"#EXTINF:4,\r\n" +
+		"http://localhost:7860/proxy/stream?url=seg1&token=%2Fproxy%2Fstream%3Aseg1\r\n" +
+		"#EXTINF:4,\r\n" +
+		"https://cdn.example.com/direct.ts\r\n"
+	if got != want {
+		t.Errorf("signPlaylist() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSignManifest(t *testing.T) {
+	h := newTestHandlers("secret")
+	h.ctx.PlaybackTokens = playtoken.NewSigner("key", time.Hour, []string{playtoken.BindIP, playtoken.BindUserAgent})
+
+	r := httptest.NewRequest(http.MethodGet, "/proxy/manifest.m3u8?url=x&api_password=secret", nil)
+	r.RemoteAddr = "192.0.2.1:5000"
+	r.Header.Set("User-Agent", "VLC/3.0")
+
+	body := "#EXTM3U\n#EXTINF:4,\nhttp://localhost:7860/proxy/stream?url=seg1\n"
+	resp := &types.StreamResponse{
+		ContentType: "application/vnd.apple.mpegurl",
+		Headers:     map[string]string{"Content-Length": "55"},
+		Body:        io.NopCloser(strings.NewReader(body)),
+		StatusCode:  http.StatusOK,
+	}
+	if err := h.signManifest(r, resp); err != nil {
+		t.Fatalf("signManifest() error = %v", err)
+	}
+	signed, _ := io.ReadAll(resp.Body)
+	if resp.Headers["Content-Length"] != strconv.Itoa(len(signed)) {
+		t.Errorf("Content-Length = %s, want %d", resp.Headers["Content-Length"], len(signed))
+	}
+
+	segment := strings.Split(strings.TrimSpace(string(signed)), "\n")[2]
+	u, err := url.Parse(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := u.Query().Get("token")
+	if err := h.ctx.PlaybackTokens.Verify(token, "192.0.2.1", "VLC/3.0", "/proxy/stream", "seg1"); err != nil {
+		t.Errorf("token rejected for the client it was issued to: %v", err)
+	}
+	if err := h.ctx.PlaybackTokens.Verify(token, "198.51.100.7", "VLC/3.0", "/proxy/stream", "seg1"); err == nil {
+		t.Error("token accepted for another client")
+	}
+	if err := h.ctx.PlaybackTokens.Verify(token, "192.0.2.1", "VLC/3.0", "/proxy/stream", "http://evil/relay"); err == nil {
+		t.Error("token accepted for another upstream target")
+	}
+}
+
+func TestHandlePlay_PlaybackTokens(t *testing.T) {
+	h := newTestHandlers("secret")
+	h.ctx.PlaybackTokens = playtoken.NewSigner("key", time.Hour, []string{playtoken.BindIP})
+
+	r := httptest.NewRequest(http.MethodGet, "/play?player=dash&api_password=secret&url="+url.QueryEscape("https://cdn.example.com/live/stream.mpd"), nil)
+	r.RemoteAddr = "192.0.2.1:5000"
+	rec := httptest.NewRecorder()
+	h.handlePlay(rec, r)
+
+	match := regexp.MustCompile(`"proxy_query":("[^"]*")`).FindStringSubmatch(rec.Body.String())
+	if match == nil {
+		t.Fatalf("page has no proxy query: %s", rec.Body.String())
+	}
+	var raw string
+	if err := json.Unmarshal([]byte(match[1]), &raw); err != nil {
+		t.Fatal(err)
+	}
+	query, _ := url.ParseQuery(raw)
+	if query.Has("api_password") {
+		t.Error("password passed on to the player next to the token")
+	}
+
+	// dash.js requests the segments next to the manifest itself
+	token := query.Get("token")
+	if err := h.ctx.PlaybackTokens.Verify(token, "192.0.2.1", "", "/proxy/stream", "https://cdn.example.com/live/seg-1.m4s"); err != nil {
+		t.Errorf("token rejected for a segment of the stream: %v", err)
+	}
+	if err := h.ctx.PlaybackTokens.Verify(token, "192.0.2.1", "", "/proxy/stream", "https://evil.example.com/relay"); err == nil {
+		t.Error("token accepted for another upstream target")
+	}
+}
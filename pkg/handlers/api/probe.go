@@ -0,0 +1,275 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/crypto"
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/types"
+)
+
+// probeTimeout bounds an /api/probe request, including ffprobe.
+const probeTimeout = 30 * time.Second
+
+// ffprobeOutput is the part of ffprobe's JSON output used by /api/probe.
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType    string            `json:"codec_type"`
+		CodecName    string            `json:"codec_name"`
+		Profile      string            `json:"profile"`
+		Width        int               `json:"width"`
+		Height       int               `json:"height"`
+		AvgFrameRate string            `json:"avg_frame_rate"`
+		BitRate      string            `json:"bit_rate"`
+		Channels     int               `json:"channels"`
+		SampleRate   string            `json:"sample_rate"`
+		Tags         map[string]string `json:"tags"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// handleProbe describes a stream's tracks, codecs and DRM. The stream is
+// probed through the local proxy so headers, routes and extractors apply.
+func (h *Handlers) handleProbe(w http.ResponseWriter, r *http.Request) {
+	req := h.parseStreamRequest(r)
+	if req.URL == "" {
+		h.writeError(w, r, http.StatusBadRequest, "url parameter required")
+		return
+	}
+
+	streamURL, streamType := req.URL, types.StreamTypeGeneric
+	if h.ctx.ProxyService != nil {
+		streamURL, streamType = h.ctx.ProxyService.StreamInfo(req.URL)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), probeTimeout)
+	defer cancel()
+
+	result := &types.ProbeResult{URL: streamURL, Type: streamType, Tracks: []types.ProbeTrack{}}
+	if streamType != types.StreamTypeGeneric {
+		result.DRM = h.probeDRM(ctx, req, streamType)
+	}
+
+	if err := h.runFFprobe(ctx, h.probeURL(req, streamType), result); err != nil {
+		h.log.Error("❌ probe failed", "url", streamURL, "error", err)
+		h.writeError(w, r, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// probeURL returns the local proxy URL ffprobe reads the stream from.
+func (h *Handlers) probeURL(req *types.StreamRequest, streamType types.StreamType) string {
+	endpoint := "/proxy/manifest.m3u8"
+	if streamType == types.StreamTypeMPD {
+		endpoint = "/proxy/mpd/manifest.m3u8"
+	}
+
+	query := url.Values{}
+	query.Set("url", req.URL)
+	if req.ClearKey != "" {
+		query.Set("clearkey", req.ClearKey)
+	}
+	for key, value := range req.Headers {
+		query.Set("h_"+key, value)
+	}
+	query.Set("no_bypass", "1")
+	return h.ctx.BaseURL + endpoint + "?" + query.Encode()
+}
+
+// runFFprobe probes streamURL and adds the format and tracks to result.
+func (h *Handlers) runFFprobe(ctx context.Context, streamURL string, result *types.ProbeResult) error {
+	args := []string{"-v", "error", "-print_format", "json", "-show_format", "-show_streams"}
+	if password := h.ctx.Config.StreamingPassword(); password != "" {
+		args = append(args, "-headers", "X-API-Password: "+password+"\r\n")
+	}
+	args = append(args, streamURL)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.ctx.Config.FFprobePath, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("probe timed out")
+		}
+		return fmt.Errorf("ffprobe failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var probe ffprobeOutput
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	result.Format = probe.Format.FormatName
+	result.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	result.Bandwidth, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+
+	// A master playlist lists every variant's streams; report each track once
+	var streamsTotal, variantMax int64
+	seen := make(map[types.ProbeTrack]bool)
+	for _, s := range probe.Streams {
+		track := types.ProbeTrack{
+			Type:     s.CodecType,
+			Codec:    s.CodecName,
+			Profile:  s.Profile,
+			Width:    s.Width,
+			Height:   s.Height,
+			Channels: s.Channels,
+			Language: s.Tags["language"],
+		}
+		track.Bitrate, _ = strconv.ParseInt(s.BitRate, 10, 64)
+		track.SampleRate, _ = strconv.Atoi(s.SampleRate)
+		if s.CodecType == "video" {
+			track.FrameRate = frameRate(s.AvgFrameRate)
+		}
+
+		variant, _ := strconv.ParseInt(s.Tags["variant_bitrate"], 10, 64)
+		variantMax = max(variantMax, variant)
+		if track.Bitrate == 0 {
+			track.Bitrate = variant
+		}
+
+		if !seen[track] {
+			seen[track] = true
+			streamsTotal += track.Bitrate
+			result.Tracks = append(result.Tracks, track)
+		}
+	}
+
+	if result.Bandwidth == 0 {
+		result.Bandwidth = variantMax
+	}
+	if result.Bandwidth == 0 {
+		result.Bandwidth = streamsTotal
+	}
+	return nil
+}
+
+// probeDRM returns the content protection signalled in a stream's manifest,
+// or nil if it is not encrypted.
+func (h *Handlers) probeDRM(ctx context.Context, req *types.StreamRequest, streamType types.StreamType) *types.DRMInfo {
+	drmReq := *req
+	drmReq.InspectDRM = true
+	drmReq.Filter = nil
+
+	resp, err := h.ctx.ProxyService.HandleManifest(ctx, &drmReq)
+	if err != nil || resp.Body == nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	body, err := httpclient.ReadLimited(resp.Body, h.ctx.Config.ManifestMaxSize)
+	if err != nil {
+		return nil
+	}
+
+	var info *types.DRMInfo
+	if streamType == types.StreamTypeMPD {
+		info = &types.DRMInfo{}
+		if json.Unmarshal(body, info) != nil {
+			return nil
+		}
+	} else {
+		info = hlsDRMInfo(body)
+	}
+	if len(info.KIDs) == 0 && len(info.Systems) == 0 {
+		return nil
+	}
+	return info
+}
+
+// hlsDRMInfo collects the encryption signalled by EXT-X-KEY and
+// EXT-X-SESSION-KEY tags.
+func hlsDRMInfo(playlist []byte) *types.DRMInfo {
+	info := &types.DRMInfo{KIDs: []string{}, Systems: []string{}}
+	seen := make(map[string]bool)
+	add := func(list *[]string, value string) {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			*list = append(*list, value)
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(playlist))
+	for scanner.Scan() {
+		tag, attrList, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || (tag != "#EXT-X-KEY" && tag != "#EXT-X-SESSION-KEY") {
+			continue
+		}
+		attrs := parseKeyAttributes(attrList)
+		if attrs["METHOD"] == "" || attrs["METHOD"] == "NONE" {
+			continue
+		}
+
+		system := strings.ToLower(attrs["METHOD"])
+		switch format := attrs["KEYFORMAT"]; {
+		case format == "com.apple.streamingkeydelivery":
+			system = "fairplay"
+		case strings.HasPrefix(strings.ToLower(format), "urn:uuid:"):
+			if name := crypto.SystemName(format); name != "" {
+				system = name
+			}
+		}
+		add(&info.Systems, system)
+		if kid := attrs["KEYID"]; kid != "" {
+			add(&info.KIDs, crypto.NormalizeKID(strings.TrimPrefix(strings.ToLower(kid), "0x")))
+		}
+	}
+	return info
+}
+
+// parseKeyAttributes parses an EXT-X-KEY attribute list, unquoting values.
+func parseKeyAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for s != "" {
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+			_, rest, _ = strings.Cut(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[strings.ToUpper(strings.TrimSpace(name))] = value
+		s = rest
+	}
+	return attrs
+}
+
+// frameRate converts an ffprobe rate such as "30000/1001" to "29.97".
+func frameRate(rate string) string {
+	num, den, ok := strings.Cut(rate, "/")
+	if !ok {
+		return rate
+	}
+	n, _ := strconv.ParseFloat(num, 64)
+	d, _ := strconv.ParseFloat(den, 64)
+	if n == 0 || d == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(math.Round(n/d*100)/100, 'f', -1, 64)
+}
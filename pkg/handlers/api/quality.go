@@ -0,0 +1,61 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+
+	"media-proxy-go/pkg/types"
+)
+
+// applyQuality sets the variant pin of filter from a quality param: "best",
+// "worst", a height ("1080p", "720"), a bandwidth ("3M", "800k"), or "audio"
+// optionally with a bandwidth ("audio:128k"). Heights and bandwidths pin the
+// best variant within the limit. Reports false for values it can't parse.
+func applyQuality(filter *types.VariantFilter, quality string) bool {
+	quality = strings.ToLower(strings.TrimSpace(quality))
+	if rest, ok := strings.CutPrefix(quality, "audio"); ok {
+		filter.AudioOnly = true
+		quality = strings.TrimPrefix(rest, ":")
+		if quality == "" {
+			quality = types.PinBest
+		}
+	}
+
+	switch {
+	case quality == types.PinBest || quality == types.PinWorst:
+		filter.Pin = quality
+		return true
+	case strings.HasSuffix(quality, "k") || strings.HasSuffix(quality, "m"):
+		bandwidth, ok := parseBandwidth(quality)
+		if !ok || bandwidth <= 0 {
+			return false
+		}
+		filter.MaxBandwidth, filter.Pin = bandwidth, types.PinBest
+		return true
+	case !filter.AudioOnly:
+		height, err := strconv.Atoi(strings.TrimSuffix(quality, "p"))
+		if err != nil || height <= 0 {
+			return false
+		}
+		filter.MaxHeight, filter.Pin = height, types.PinBest
+		return true
+	}
+	return false
+}
+
+// parseBandwidth parses a bandwidth in bits/s with an optional k or M suffix.
+func parseBandwidth(s string) (int, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier, s = 1000, strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		multiplier, s = 1000000, strings.TrimSuffix(s, "m")
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return int(value * float64(multiplier)), true
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"media-proxy-go/pkg/services"
+	"media-proxy-go/pkg/types"
+)
+
+// handleRecordingChapters serves the chapters of a recording as WebVTT
+// chapters, for players that show chapter markers.
+func (h *Handlers) handleRecordingChapters(w http.ResponseWriter, r *http.Request) {
+	recording, err := h.ctx.RecordingManager.GetRecording(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	if recording.Chapters == nil || recording.Chapters.Status != types.ChaptersStatusReady {
+		h.writeError(w, r, http.StatusNotFound, "chapters not detected")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	w.Write([]byte(chaptersWebVTT(recording.Chapters.Chapters)))
+}
+
+// handleDetectChapters starts detecting the black and silent filler of a
+// finished recording; the chapters show up on the recording once found.
+func (h *Handlers) handleDetectChapters(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := h.ctx.RecordingManager.DetectChapters(id); err != nil {
+		if errors.Is(err, services.ErrChaptersUnavailable) {
+			h.writeError(w, r, http.StatusConflict, err.Error())
+			return
+		}
+		h.writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusAccepted, types.RecordingChapters{Status: types.ChaptersStatusDetecting})
+}
+
+// chaptersWebVTT renders chapters as a WebVTT chapters track.
+func chaptersWebVTT(chapters []types.RecordingChapter) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for i, chapter := range chapters {
+		fmt.Fprintf(&b, "\n%d\n%s --> %s\n%s\n", i+1, vttTimestamp(chapter.Start), vttTimestamp(chapter.End), chapter.Title)
+	}
+	return b.String()
+}
+
+// vttTimestamp formats seconds as a WebVTT timestamp, hh:mm:ss.mmm.
+func vttTimestamp(seconds float64) string {
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"media-proxy-go/pkg/types"
+)
+
+// handleRecordingHealth returns the health report of a recording: average
+// bitrate, resolution, dropped packets and discontinuities. The recording is
+// analyzed when it has no report yet, is still running (a partial report) or
+// ?refresh=true is given.
+func (h *Handlers) handleRecordingHealth(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	recording, err := h.ctx.RecordingManager.GetRecording(id)
+	if err != nil {
+		h.writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+
+	refresh, _ := strconv.ParseBool(r.URL.Query().Get("refresh"))
+	if recording.Health != nil && !refresh && recording.Status != string(types.RecordingStatusRecording) {
+		h.writeJSON(w, http.StatusOK, recording.Health)
+		return
+	}
+
+	health, err := h.ctx.RecordingManager.AnalyzeRecording(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, health)
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"io"
+	"net/http"
+)
+
+// relay copies an upstream body to the client as it arrives. Bodies of
+// unknown length (live TS, chunked upstreams) are flushed after every chunk
+// instead of waiting for net/http's write buffer to fill, so the player gets
+// them as soon as upstream sends them.
+func relay(w http.ResponseWriter, body io.Reader) (int64, error) {
+	var dst io.Writer = w
+	if w.Header().Get("Content-Length") == "" {
+		if _, ok := w.(http.Flusher); ok {
+			dst = flushWriter{w}
+		}
+	}
+	return io.Copy(dst, body)
+}
+
+// flushWriter flushes after every write.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	fw.w.(http.Flusher).Flush()
+	return n, err
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+
+	"media-proxy-go/pkg/bufpool"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+// remuxIdleTimeout is how long the pool keeps warm processes without a
+// remux, after which it lets them go until the next one.
+const remuxIdleTimeout = 2 * time.Minute
+
+// errRemuxStdin is returned by remuxProc.run when FFmpeg exited before
+// reading its input, e.g. a warm process killed while idle.
+var errRemuxStdin = errors.New("ffmpeg stdin closed")
+
+// remuxProc is an FFmpeg remux process, started with its arguments and
+// waiting for the segment on stdin.
+type remuxProc struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bytes.Buffer
+	stderr bytes.Buffer
+}
+
+// startRemux starts FFmpeg with args, reading the segment from stdin and
+// writing the result to stdout. sizeHint presizes the output buffer.
+func startRemux(ffmpegPath string, args []string, sizeHint int) (*remuxProc, error) {
+	p := &remuxProc{cmd: exec.Command(ffmpegPath, args...), stdout: bufpool.Get()}
+	p.stdout.Grow(sizeHint)
+	p.cmd.Stdout = p.stdout
+	p.cmd.Stderr = &p.stderr
+	stdin, err := p.cmd.StdinPipe()
+	if err == nil {
+		p.stdin = stdin
+		err = p.cmd.Start()
+	}
+	if err != nil {
+		bufpool.Put(p.stdout)
+		return nil, err
+	}
+	return p, nil
+}
+
+// run feeds content to the process and waits for it to exit, killing it
+// when ctx ends first. The returned buffer belongs to the caller.
+func (p *remuxProc) run(ctx context.Context, content []byte) (*bytes.Buffer, error) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.cmd.Process.Kill()
+		case <-done:
+		}
+	}()
+
+	_, writeErr := p.stdin.Write(content)
+	p.stdin.Close()
+	err := p.cmd.Wait()
+	if writeErr != nil && p.stdout.Len() == 0 && ctx.Err() == nil {
+		err = errRemuxStdin
+	}
+	return p.stdout, err
+}
+
+// kill stops a process that won't be used.
+func (p *remuxProc) kill() {
+	p.cmd.Process.Kill()
+	p.stdin.Close()
+	p.cmd.Wait()
+	bufpool.Put(p.stdout)
+}
+
+// remuxPool keeps FFmpeg remux processes started ahead of time, so the
+// process startup (exec, loading FFmpeg's libraries, setting up the muxer)
+// isn't paid by the segment request. Each process still remuxes a single
+// segment: FFmpeg gives no reliable way of telling where the output of one
+// input segment ends in a continuous stream. The pool is refilled with the
+// arguments of the last remux, after each one it serves.
+type remuxPool struct {
+	log        *logging.Logger
+	ffmpegPath string
+	size       int
+
+	mu       sync.Mutex
+	args     []string // Of the idle processes
+	idle     []*remuxProc
+	starting int
+	idleTime *time.Timer
+	hits     uint64
+	misses   uint64
+}
+
+// newRemuxPool returns a pool keeping up to size warm processes, or nil
+// when size is 0.
+func newRemuxPool(ffmpegPath string, size int, log *logging.Logger) *remuxPool {
+	if size <= 0 {
+		return nil
+	}
+	return &remuxPool{log: log, ffmpegPath: ffmpegPath, size: size}
+}
+
+// take returns a warm process started with args, or nil when none is idle.
+// Either way the pool is then refilled with processes for args.
+func (p *remuxPool) take(args []string) *remuxProc {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var proc *remuxProc
+	if !slices.Equal(p.args, args) {
+		// The arguments changed (a profile was configured): the idle
+		// processes are of no use anymore
+		for _, old := range p.idle {
+			go old.kill()
+		}
+		p.idle = nil
+		p.args = slices.Clone(args)
+	} else if n := len(p.idle); n > 0 {
+		proc = p.idle[n-1]
+		p.idle = p.idle[:n-1]
+	}
+	if proc != nil {
+		p.hits++
+	} else {
+		p.misses++
+	}
+
+	for len(p.idle)+p.starting < p.size {
+		p.starting++
+		go p.spawn(p.args)
+	}
+	if p.idleTime == nil {
+		p.idleTime = time.AfterFunc(remuxIdleTimeout, p.drain)
+	} else {
+		p.idleTime.Reset(remuxIdleTimeout)
+	}
+	return proc
+}
+
+// spawn starts a warm process with args and adds it to the idle ones.
+func (p *remuxPool) spawn(args []string) {
+	proc, err := startRemux(p.ffmpegPath, args, 0)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.starting--
+	if err != nil {
+		p.log.Warn("⚠️ failed to start warm ffmpeg process", "error", err)
+		return
+	}
+	if !slices.Equal(p.args, args) || len(p.idle) >= p.size {
+		go proc.kill()
+		return
+	}
+	p.idle = append(p.idle, proc)
+}
+
+// drain stops the idle processes after remuxIdleTimeout without a remux.
+func (p *remuxPool) drain() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.args = nil // Processes still starting are stopped too
+	p.mu.Unlock()
+
+	for _, proc := range idle {
+		proc.kill()
+	}
+	if len(idle) > 0 {
+		p.log.Debug("stopped idle ffmpeg processes", "count", len(idle))
+	}
+}
+
+// stats returns the pool's occupancy and counters.
+func (p *remuxPool) stats() types.RemuxPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return types.RemuxPoolStats{
+		Size:   p.size,
+		Idle:   len(p.idle),
+		Hits:   p.hits,
+		Misses: p.misses,
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+
+	"media-proxy-go/pkg/types"
+)
+
+// routeTester is implemented by httpclient.Client.
+type routeTester interface {
+	TestRoutes(targetURL string) types.RouteTest
+}
+
+// handleRoutesTest reports which transport route, proxy and redirect policy
+// requests to a URL would use, without sending any request.
+func (h *Handlers) handleRoutesTest(w http.ResponseWriter, r *http.Request) {
+	targetURL := r.URL.Query().Get("url")
+	if targetURL == "" {
+		h.writeError(w, r, http.StatusBadRequest, "url parameter required")
+		return
+	}
+	tester, ok := h.ctx.HTTPClient.(routeTester)
+	if !ok {
+		h.writeError(w, r, http.StatusServiceUnavailable, "transport routes not available")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, tester.TestRoutes(targetURL))
+}
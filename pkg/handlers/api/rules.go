@@ -0,0 +1,19 @@
+package api
+
+import "net/http"
+
+// handleRulesStatus returns the outcome of the last extraction rules load.
+func (h *Handlers) handleRulesStatus(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, h.ctx.Rules.Status())
+}
+
+// handleReloadRules fetches and applies the extraction rules bundle now. A
+// failed load answers 502 with the status carrying the error.
+func (h *Handlers) handleReloadRules(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusOK
+	if err := h.ctx.Rules.Load(r.Context()); err != nil {
+		h.log.Warn("failed to load extraction rules", "error", err)
+		status = http.StatusBadGateway
+	}
+	h.writeJSON(w, status, h.ctx.Rules.Status())
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"media-proxy-go/pkg/sessions"
+	"media-proxy-go/pkg/streamstats"
+	"media-proxy-go/pkg/types"
+)
+
+// handleListSessions returns active playback sessions.
+func (h *Handlers) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, h.ctx.Sessions.List())
+}
+
+// handleTerminateSession stops a playback session and refuses its client's retries.
+func (h *Handlers) handleTerminateSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !h.ctx.Sessions.Terminate(id) {
+		h.writeError(w, r, http.StatusNotFound, "session not found")
+		return
+	}
+	h.log.Info("session terminated", "id", id)
+	h.writeJSON(w, http.StatusOK, map[string]string{"status": "terminated"})
+}
+
+// trackStream attributes a proxied request to a playback session. entry marks
+// manifest requests (see sessions.Tracker.Begin). Requests from terminated
+// sessions are refused with 410 Gone.
+func (h *Handlers) trackStream(entry bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.ctx.Sessions == nil {
+			next(w, r)
+			return
+		}
+
+		streamURL := r.URL.Query().Get("url")
+		if streamURL == "" {
+			streamURL = r.URL.Query().Get("d")
+		}
+		if streamURL == "" {
+			streamURL = r.URL.Query().Get("base_url")
+		}
+		streamType := types.StreamTypeGeneric
+		if h.ctx.ProxyService != nil {
+			var detected types.StreamType
+			streamURL, detected = h.ctx.ProxyService.StreamInfo(streamURL)
+			if entry {
+				streamType = detected
+			}
+		}
+
+		req, ok := h.ctx.Sessions.Begin(r, entry, streamURL, streamType)
+		if !ok {
+			h.writeError(w, r, http.StatusGone, "session terminated")
+			return
+		}
+		defer req.End()
+
+		start := time.Now()
+		sw := &sessionWriter{ResponseWriter: w, req: req}
+		next(sw, r.WithContext(req.Context()))
+
+		if h.ctx.StreamStats != nil {
+			h.ctx.StreamStats.Record(req.StreamURL(), req.StreamType(), sw.sample(entry, start))
+		}
+	}
+}
+
+// activeStreams returns the number of active playback sessions.
+func (h *Handlers) activeStreams() int64 {
+	if h.ctx.Sessions == nil {
+		return 0
+	}
+	return int64(h.ctx.Sessions.Count())
+}
+
+// maxErrorBody is how much of an error response is kept for its message.
+const maxErrorBody = 512
+
+// sessionWriter counts bytes sent to the client for session bandwidth, and
+// records the response status and timing for stream statistics.
+type sessionWriter struct {
+	http.ResponseWriter
+	req     *sessions.Request
+	status  int
+	started time.Time // When the response started
+	bytes   int64
+	errBody []byte // Start of an error response
+}
+
+func (sw *sessionWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+		sw.started = time.Now()
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *sessionWriter) Write(p []byte) (int, error) {
+	if sw.status == 0 {
+		sw.WriteHeader(http.StatusOK)
+	}
+	if sw.status >= 400 && len(sw.errBody) < maxErrorBody {
+		sw.errBody = append(sw.errBody, p[:min(len(p), maxErrorBody-len(sw.errBody))]...)
+	}
+	n, err := sw.ResponseWriter.Write(p)
+	sw.bytes += int64(n)
+	sw.req.Add(n)
+	return n, err
+}
+
+// sample describes the finished response for stream statistics.
+func (sw *sessionWriter) sample(manifest bool, start time.Time) streamstats.Sample {
+	s := streamstats.Sample{Manifest: manifest, Bytes: sw.bytes, Status: sw.status}
+	if sw.status == 0 {
+		s.Status = http.StatusOK // Nothing written
+		s.FetchTime = time.Since(start)
+		return s
+	}
+	s.FetchTime = sw.started.Sub(start)
+	if sw.status >= 400 {
+		s.Error = responseError(sw.status, sw.errBody)
+	}
+	return s
+}
+
+// responseError returns the message of an error response: the error of a
+// writeError JSON body, or the status text.
+func responseError(status int, body []byte) string {
+	var resp struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &resp) == nil && resp.Error != "" {
+		return fmt.Sprintf("%d: %s", status, resp.Error)
+	}
+	return fmt.Sprintf("%d %s", status, http.StatusText(status))
+}
+
+// Flush implements http.Flusher for streaming responses.
+func (sw *sessionWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (sw *sessionWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
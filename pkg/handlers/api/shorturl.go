@@ -0,0 +1,93 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/shorturl"
+	"media-proxy-go/pkg/types"
+)
+
+// shortURLPrefix is the path of the short URLs in proxied playlists,
+// followed by the ID and the name of the endpoint the ID stands for.
+const shortURLPrefix = "/proxy/s/"
+
+// maxShortURLs bounds the IDs held in memory, about 1 KB each.
+const maxShortURLs = 100000
+
+// newShortURLs returns the short URL store, or nil when SHORT_URLS is off.
+func newShortURLs(enabled bool, ttl time.Duration) *shorturl.Store {
+	if !enabled {
+		return nil
+	}
+	return shorturl.New(ttl, maxShortURLs)
+}
+
+// shortenManifest replaces the proxy URLs of a proxied playlist with short
+// ones, keeping the upstream URLs and headers they carry server-side. The
+// endpoint name (segment.ts, manifest.m3u8...) stays at the end of the path
+// for players that go by the extension.
+func (h *Handlers) shortenManifest(r *http.Request, resp *types.StreamResponse) error {
+	if h.shortURLs == nil || resp.StatusCode != http.StatusOK || resp.Body == nil ||
+		!strings.Contains(strings.ToLower(resp.ContentType), "mpegurl") {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	base := h.publicBaseURL(r)
+	shortened := mapPlaylistURIs(string(body), func(u string) string {
+		target, ok := strings.CutPrefix(u, base)
+		if !ok || !strings.HasPrefix(target, "/") || strings.HasPrefix(target, shortURLPrefix) {
+			return u
+		}
+		endpoint, _, _ := strings.Cut(target, "?")
+		return base + shortURLPrefix + h.shortURLs.Shorten(target) + "/" + path.Base(endpoint)
+	})
+	if _, ok := resp.Headers["Content-Length"]; ok {
+		resp.Headers["Content-Length"] = strconv.Itoa(len(shortened))
+	}
+	resp.Body = io.NopCloser(strings.NewReader(shortened))
+	return nil
+}
+
+// handleShortURL serves a short URL with the endpoint its ID stands for. The
+// request's own parameters (playback token, password, LL-HLS directives) are
+// added to the stored ones.
+func (h *Handlers) handleShortURL(mux http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target, ok := h.shortURLs.Resolve(r.PathValue("id"))
+		if !ok {
+			h.writeError(w, r, http.StatusNotFound, "short URL not found or expired")
+			return
+		}
+		u, err := url.Parse(target)
+		if err != nil {
+			h.writeError(w, r, http.StatusNotFound, "short URL not found or expired")
+			return
+		}
+
+		query := u.Query()
+		for name, values := range r.URL.Query() {
+			if !query.Has(name) {
+				query[name] = values
+			}
+		}
+		u.RawQuery = query.Encode()
+
+		forwarded := r.Clone(r.Context())
+		forwarded.URL = u
+		forwarded.RequestURI = u.RequestURI()
+		mux.ServeHTTP(w, forwarded)
+	}
+}
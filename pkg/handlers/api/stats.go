@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"media-proxy-go/pkg/types"
+)
+
+// streamStatsOrder are the orderings of /api/stats/streams besides the
+// default, most recently active first.
+var streamStatsOrder = map[string]func(a, b types.StreamStats) bool{
+	"errors": func(a, b types.StreamStats) bool { return a.Errors > b.Errors },
+	"fetch":  func(a, b types.StreamStats) bool { return a.AvgFetchTime > b.AvgFetchTime },
+	"bytes":  func(a, b types.StreamStats) bool { return a.Bytes > b.Bytes },
+}
+
+// handleListStreamStats returns per-stream counters, optionally ordered by
+// errors, average fetch time or bytes (?sort=), so the streams that buffer
+// come first.
+func (h *Handlers) handleListStreamStats(w http.ResponseWriter, r *http.Request) {
+	stats := h.ctx.StreamStats.List()
+	if key := r.URL.Query().Get("sort"); key != "" {
+		less, ok := streamStatsOrder[key]
+		if !ok {
+			h.writeError(w, r, http.StatusBadRequest, "sort must be errors, fetch or bytes")
+			return
+		}
+		sort.SliceStable(stats, func(i, j int) bool { return less(stats[i], stats[j]) })
+	}
+	h.writeJSON(w, http.StatusOK, stats)
+}
+
+// outboundLimiter is implemented by httpclient.Client.
+type outboundLimiter interface {
+	RouteLimitStats() map[string]types.ThrottleStats
+	OutboundStats() (*types.ThrottleStats, map[string]types.ThrottleStats)
+}
+
+// handleThrottleStats returns the counters of the paced extractors, transport
+// routes and hosts: how many requests waited for their turn, how long, and
+// how many gave up.
+func (h *Handlers) handleThrottleStats(w http.ResponseWriter, r *http.Request) {
+	report := types.ThrottleReport{
+		Extractors: map[string]types.ThrottleStats{},
+		Routes:     map[string]types.ThrottleStats{},
+		Hosts:      map[string]types.ThrottleStats{},
+	}
+	if h.ctx.ProxyService != nil {
+		report.Extractors = h.ctx.ProxyService.ExtractorLimitStats()
+	}
+	if limiter, ok := h.ctx.HTTPClient.(outboundLimiter); ok {
+		report.Routes = limiter.RouteLimitStats()
+		report.Outbound, report.Hosts = limiter.OutboundStats()
+	}
+	h.writeJSON(w, http.StatusOK, report)
+}
+
+// handleResetStreamStats drops all per-stream counters.
+func (h *Handlers) handleResetStreamStats(w http.ResponseWriter, r *http.Request) {
+	h.ctx.StreamStats.Reset()
+	h.writeJSON(w, http.StatusOK, map[string]string{"status": "reset"})
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"media-proxy-go/pkg/services"
+	"media-proxy-go/pkg/types"
+)
+
+// handleListSubtitles returns the subtitle tracks of a recording.
+func (h *Handlers) handleListSubtitles(w http.ResponseWriter, r *http.Request) {
+	recording, err := h.ctx.RecordingManager.GetRecording(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	subtitles := recording.Subtitles
+	if subtitles == nil {
+		subtitles = []types.RecordingSubtitle{}
+	}
+	h.writeJSON(w, http.StatusOK, subtitles)
+}
+
+// handleAddSubtitle attaches a subtitle track to a recording: an external
+// URL with {"url","lang","label"}, or {"embedded":true} to extract teletext
+// or closed captions from the recording itself.
+func (h *Handlers) handleAddSubtitle(w http.ResponseWriter, r *http.Request) {
+	var sub types.RecordingSubtitle
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	added, err := h.ctx.RecordingManager.AddSubtitle(r.PathValue("id"), sub)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSubtitle) {
+			h.writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusCreated, added)
+}
+
+// handleSubtitleFile serves a captured subtitle track as WebVTT.
+func (h *Handlers) handleSubtitleFile(w http.ResponseWriter, r *http.Request) {
+	recording, err := h.ctx.RecordingManager.GetRecording(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+
+	subID := r.PathValue("sub")
+	for _, sub := range recording.Subtitles {
+		if sub.ID != subID {
+			continue
+		}
+		if sub.Status != types.SubtitleStatusReady {
+			h.writeError(w, r, http.StatusNotFound, "subtitle not ready: "+sub.Status)
+			return
+		}
+		w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+		http.ServeFile(w, r, sub.FilePath)
+		return
+	}
+	h.writeError(w, r, http.StatusNotFound, "subtitle not found: "+subID)
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/bufpool"
+	"media-proxy-go/pkg/services"
+	"media-proxy-go/pkg/types"
+	"media-proxy-go/pkg/urlsign"
+)
+
+// transcodeStartTimeout bounds how long /transcode waits for FFmpeg to write
+// the first playlist.
+const transcodeStartTimeout = 30 * time.Second
+
+// handleTranscode starts (or reuses) an FFmpeg HLS transcode of url with the
+// requested profile and redirects to its playlist. audio_only=1 without a
+// profile transcodes with the audio profile, dropping the video. Subtitles
+// are burned in for players that can't render text tracks: an external
+// track with burn_subs=URL, or a subtitle rendition of the HLS source with
+// burn_lang and/or burn_forced=1.
+func (h *Handlers) handleTranscode(w http.ResponseWriter, r *http.Request) {
+	req := h.parseStreamRequest(r)
+	if req.URL == "" {
+		h.writeError(w, r, http.StatusBadRequest, "url parameter required")
+		return
+	}
+	query := r.URL.Query()
+	profile := query.Get("profile")
+	if profile == "" && req.Filter != nil && req.Filter.AudioOnly {
+		profile = services.AudioProfile
+	}
+
+	burnSubtitles := query.Get("burn_subs")
+	if lang, forced := query.Get("burn_lang"), query.Get("burn_forced") == "1"; burnSubtitles == "" && (lang != "" || forced) {
+		rendition, err := h.subtitleRendition(r.Context(), req, lang, forced)
+		if err != nil {
+			h.log.Warn("⚠️ no subtitles to burn in", "url", req.URL, "lang", lang, "forced", forced, "error", err)
+			h.writeError(w, r, http.StatusBadRequest, "no matching subtitle rendition")
+			return
+		}
+		burnSubtitles = rendition
+	}
+
+	// The transcode outlives this request; only the wait below is bound to it
+	streamID, err := h.ctx.Transcoder.StartStream(context.Background(), req.URL, req.Headers, req.ClearKey, profile, burnSubtitles)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownProfile), errors.Is(err, services.ErrBurnSubtitles):
+			h.writeError(w, r, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrTooManySessions):
+			w.Header().Set("Retry-After", "30")
+			h.writeError(w, r, http.StatusServiceUnavailable, err.Error())
+		default:
+			h.log.Error("❌ failed to start transcode", "url", req.URL, "error", err)
+			h.writeError(w, r, http.StatusInternalServerError, "failed to start transcode")
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), transcodeStartTimeout)
+	defer cancel()
+	if err := h.ctx.Transcoder.WaitReady(ctx, streamID); err != nil {
+		h.log.Error("❌ transcode not ready", "stream_id", streamID, "url", req.URL, "error", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			h.writeError(w, r, http.StatusGatewayTimeout, "transcode did not start in time")
+		} else {
+			h.writeError(w, r, http.StatusBadGateway, "transcode failed to start")
+		}
+		return
+	}
+
+	// The redirect can't carry the caller's headers: sign it for the stream's
+	// directory, whose playlist passes the signature on to the segments
+	dir := "/ffmpeg_stream/" + streamID + "/"
+	http.Redirect(w, r, urlsign.ScopedURL(h.ctx.Config.StreamingPassword(), h.publicBaseURL(r), dir+"index.m3u8", dir, nil), http.StatusFound)
+}
+
+// streamAuthParams are the query parameters authorizing a request to a
+// streaming route without headers. Playback tokens are issued per path, so
+// they aren't among them.
+var streamAuthParams = []string{
+	"api_password",
+	urlsign.ParamExpiration, urlsign.ParamSignature, urlsign.ParamIP, urlsign.ParamPath,
+}
+
+// withStreamAuth adds the credentials of the query of a transcode playlist
+// request to the relative URIs of the playlist, which players resolve without
+// the query. issue, if set, returns the playback token of a URI.
+func withStreamAuth(playlist string, query url.Values, issue func(uri string) string) string {
+	auth := url.Values{}
+	for _, name := range streamAuthParams {
+		if v := query.Get(name); v != "" {
+			auth.Set(name, v)
+		}
+	}
+	if len(auth) == 0 && issue == nil {
+		return playlist
+	}
+	return mapPlaylistURIs(playlist, func(u string) string {
+		if u == "" || strings.Contains(u, "://") || strings.HasPrefix(u, "/") {
+			return u
+		}
+		params := maps.Clone(auth)
+		if issue != nil {
+			name, _, _ := strings.Cut(u, "?")
+			params.Set("token", issue(name))
+		}
+		if strings.Contains(u, "?") {
+			return u + "&" + params.Encode()
+		}
+		return u + "?" + params.Encode()
+	})
+}
+
+// subtitleRendition returns the URL of the subtitle rendition of an HLS
+// master playlist in lang (any language when empty), FORCED=YES when forced
+// is set. Without forced, full renditions win over forced ones.
+func (h *Handlers) subtitleRendition(ctx context.Context, req *types.StreamRequest, lang string, forced bool) (string, error) {
+	buf, err := h.fetchURL(ctx, req.URL, req.Headers)
+	if err != nil {
+		return "", err
+	}
+	defer bufpool.Put(buf)
+	base, err := url.Parse(req.URL)
+	if err != nil {
+		return "", err
+	}
+
+	var best string
+	bestForced := false
+	scanner := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for scanner.Scan() {
+		tag, attrList, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || tag != "#EXT-X-MEDIA" {
+			continue
+		}
+		attrs := parseKeyAttributes(attrList)
+		if attrs["TYPE"] != "SUBTITLES" || attrs["URI"] == "" || !matchesLang(attrs["LANGUAGE"], lang) {
+			continue
+		}
+		isForced := attrs["FORCED"] == "YES"
+		if forced && !isForced {
+			continue
+		}
+		if best == "" || (bestForced && !isForced) {
+			ref, err := url.Parse(attrs["URI"])
+			if err != nil {
+				continue
+			}
+			best, bestForced = base.ResolveReference(ref).String(), isForced
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no subtitle rendition for language %q (forced: %t)", lang, forced)
+	}
+	return best, nil
+}
+
+// matchesLang reports whether an HLS LANGUAGE matches the wanted one, by
+// primary subtag when want has none: "en" matches "en-US".
+func matchesLang(language, want string) bool {
+	if want == "" {
+		return true
+	}
+	if strings.EqualFold(language, want) {
+		return true
+	}
+	primary, _, _ := strings.Cut(language, "-")
+	return !strings.Contains(want, "-") && strings.EqualFold(primary, want)
+}
+
+// handleListTranscodeProfiles returns the transcoding profiles and the default profile name.
+func (h *Handlers) handleListTranscodeProfiles(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"default":  h.ctx.Config.TranscodeDefaultProfile,
+		"profiles": h.ctx.Transcoder.Profiles(),
+	})
+}
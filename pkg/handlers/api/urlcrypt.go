@@ -0,0 +1,47 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"media-proxy-go/pkg/types"
+	"media-proxy-go/pkg/urlcrypt"
+)
+
+// encryptManifest replaces the query strings of the proxy URLs in a proxied
+// playlist with their encryption, so the upstream URLs and h_ headers they
+// carry aren't readable by the player. Short URLs already keep them
+// server-side and are left alone.
+func (h *Handlers) encryptManifest(r *http.Request, resp *types.StreamResponse) error {
+	cipher := h.ctx.URLCipher
+	if cipher == nil || h.shortURLs != nil || resp.StatusCode != http.StatusOK || resp.Body == nil ||
+		!strings.Contains(strings.ToLower(resp.ContentType), "mpegurl") {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	base := h.publicBaseURL(r) + "/"
+	encrypted := mapPlaylistURIs(string(body), func(u string) string {
+		if !strings.HasPrefix(u, base) {
+			return u
+		}
+		endpoint, query, ok := strings.Cut(u, "?")
+		if !ok || query == "" || strings.HasPrefix(query, urlcrypt.Param+"=") {
+			return u
+		}
+		return endpoint + "?" + urlcrypt.Param + "=" + cipher.Seal(query)
+	})
+	if _, ok := resp.Headers["Content-Length"]; ok {
+		resp.Headers["Content-Length"] = strconv.Itoa(len(encrypted))
+	}
+	resp.Body = io.NopCloser(strings.NewReader(encrypted))
+	return nil
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"media-proxy-go/pkg/types"
+)
+
+// uaProfiler is implemented by httpclient.Client.
+type uaProfiler interface {
+	UAProfile() types.UAProfileStatus
+	SetUAProfile(profile string, rotate bool) error
+}
+
+// handleGetUAProfile returns the User-Agent profile of upstream requests and
+// the presets.
+func (h *Handlers) handleGetUAProfile(w http.ResponseWriter, r *http.Request) {
+	profiler, ok := h.ctx.HTTPClient.(uaProfiler)
+	if !ok {
+		h.writeError(w, r, http.StatusServiceUnavailable, "User-Agent profiles not available")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, profiler.UAProfile())
+}
+
+// handleSetUAProfile switches the User-Agent profile of upstream requests:
+// PUT /api/useragent with {"profile","rotate"}. It lasts until the next
+// restart, which goes back to UA_PROFILE and UA_ROTATE.
+func (h *Handlers) handleSetUAProfile(w http.ResponseWriter, r *http.Request) {
+	profiler, ok := h.ctx.HTTPClient.(uaProfiler)
+	if !ok {
+		h.writeError(w, r, http.StatusServiceUnavailable, "User-Agent profiles not available")
+		return
+	}
+	var req types.UAProfileStatus
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := profiler.SetUAProfile(req.Profile, req.Rotate); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, profiler.UAProfile())
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"media-proxy-go/pkg/channels"
+	"media-proxy-go/pkg/types"
+)
+
+// handleVavooChannels lists the Vavoo channel catalog.
+// ?country= filters by country (repeatable or comma-separated), ?search= by name,
+// and ?format=m3u returns an M3U playlist routed through the proxy.
+func (h *Handlers) handleVavooChannels(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	list, err := h.ctx.VavooCatalog.Catalog(r.Context(), splitList(query["country"]))
+	if err != nil {
+		h.log.Error("❌ failed to fetch Vavoo catalog", "error", err)
+		h.writeError(w, r, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	if search := strings.ToLower(query.Get("search")); search != "" {
+		filtered := []types.Channel{}
+		for _, ch := range list {
+			if strings.Contains(strings.ToLower(ch.Name), search) {
+				filtered = append(filtered, ch)
+			}
+		}
+		list = filtered
+	}
+
+	if query.Get("format") == "m3u" {
+		apiPassword, baseURL := h.ctx.Config.StreamingPassword(), h.publicBaseURL(r)
+		urlFor := func(ch types.Channel) string {
+			return channels.ProxyURL(baseURL, "/proxy/manifest.m3u8", ch, apiPassword, nil)
+		}
+
+		w.Header().Set("Content-Type", "audio/x-mpegurl")
+		w.Header().Set("Content-Disposition", `inline; filename="vavoo.m3u"`)
+		if err := channels.WriteM3U(w, list, urlFor); err != nil {
+			h.log.Debug("failed to write playlist", "error", err)
+		}
+		return
+	}
+
+	if list == nil {
+		list = []types.Channel{}
+	}
+	h.writeJSON(w, http.StatusOK, list)
+}
+
+// handleVavooImport imports Vavoo channels into the channel list, so they are
+// served by /playlist.m3u, the Stremio addon and the HDHomeRun tuner.
+// JSON body: {"countries": ["Germany"], "append": true}. Without append the
+// channel list is replaced.
+func (h *Handlers) handleVavooImport(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Countries []string `json:"countries"`
+		Append    bool     `json:"append"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	list, err := h.ctx.VavooCatalog.Catalog(r.Context(), splitList(req.Countries))
+	if err != nil {
+		h.log.Error("❌ failed to fetch Vavoo catalog", "error", err)
+		h.writeError(w, r, http.StatusBadGateway, err.Error())
+		return
+	}
+	if len(list) == 0 {
+		h.writeError(w, r, http.StatusNotFound, "no Vavoo channels found")
+		return
+	}
+
+	if req.Append {
+		existing := h.ctx.Channels.List()
+		seen := make(map[string]bool, len(existing))
+		for _, ch := range existing {
+			seen[ch.URL] = true
+		}
+		for _, ch := range list {
+			if !seen[ch.URL] {
+				existing = append(existing, ch)
+			}
+		}
+		list = existing
+	}
+
+	count, err := h.ctx.Channels.Replace(list)
+	if err != nil {
+		h.log.Error("❌ failed to save channels", "error", err)
+		h.writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"channels": count,
+		"groups":   h.ctx.Channels.Groups(),
+	})
+}
+
+// splitList flattens repeated and comma-separated values.
+func splitList(values []string) []string {
+	var result []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
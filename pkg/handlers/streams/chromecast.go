@@ -0,0 +1,138 @@
+package streams
+
+import (
+	"strings"
+
+	"media-proxy-go/pkg/types"
+)
+
+// castCodecPrefixes are the CODECS entries the Chromecast default receiver
+// plays everywhere: H.264 video, AAC audio and text subtitles. HEVC, AV1,
+// VP9, AC-3 and E-AC-3 only work on some models and fail on the others.
+var castCodecPrefixes = []string{"avc1", "avc3", "mp4a", "wvtt", "stpp"}
+
+// forChromecast reports whether filter asks for Chromecast compatible output.
+func forChromecast(filter *types.VariantFilter) bool {
+	return filter != nil && filter.Device == types.DeviceChromecast
+}
+
+// castableCodecs reports whether every codec of a CODECS attribute plays on
+// Chromecast. Unknown (empty) codecs are given the benefit of the doubt.
+func castableCodecs(codecs string) bool {
+	for _, codec := range strings.Split(codecs, ",") {
+		codec = strings.ToLower(strings.TrimSpace(codec))
+		if codec == "" {
+			continue
+		}
+		castable := false
+		for _, prefix := range castCodecPrefixes {
+			if strings.HasPrefix(codec, prefix) {
+				castable = true
+				break
+			}
+		}
+		if !castable {
+			return false
+		}
+	}
+	return true
+}
+
+// castCleanup prepares a master playlist for Chromecast: variants in codecs
+// it can't play are dropped (unless that would drop them all), as are I-frame
+// playlists and the renditions of groups no remaining variant uses. Every
+// audio group keeps a DEFAULT=YES rendition, which the receiver starts with.
+func castCleanup(entries []*playlistEntry) {
+	var variants []*playlistEntry
+	castable := 0
+	for _, entry := range entries {
+		switch entry.tag {
+		case "#EXT-X-I-FRAME-STREAM-INF":
+			entry.keep = false
+		case "#EXT-X-STREAM-INF":
+			if entry.keep {
+				variants = append(variants, entry)
+				if castableCodecs(entry.attrs["CODECS"]) {
+					castable++
+				}
+			}
+		}
+	}
+	if castable > 0 {
+		for _, v := range variants {
+			v.keep = castableCodecs(v.attrs["CODECS"])
+		}
+	}
+
+	groups := map[string]bool{}
+	for _, v := range variants {
+		if !v.keep {
+			continue
+		}
+		for _, attr := range []string{"AUDIO", "VIDEO", "SUBTITLES", "CLOSED-CAPTIONS"} {
+			if id := v.attrs[attr]; id != "" {
+				groups[attr+"/"+id] = true
+			}
+		}
+	}
+
+	audio := make(map[string][]*playlistEntry)
+	for _, entry := range entries {
+		if entry.tag != "#EXT-X-MEDIA" || !entry.keep {
+			continue
+		}
+		if !groups[entry.attrs["TYPE"]+"/"+entry.attrs["GROUP-ID"]] {
+			entry.keep = false
+			continue
+		}
+		if entry.attrs["TYPE"] == "AUDIO" {
+			audio[entry.attrs["GROUP-ID"]] = append(audio[entry.attrs["GROUP-ID"]], entry)
+		}
+	}
+	for _, renditions := range audio {
+		hasDefault := false
+		for _, r := range renditions {
+			hasDefault = hasDefault || r.attrs["DEFAULT"] == "YES"
+		}
+		if !hasDefault {
+			first := renditions[0]
+			if strings.Contains(first.lines[0], "DEFAULT=NO") {
+				first.lines[0] = strings.Replace(first.lines[0], "DEFAULT=NO", "DEFAULT=YES", 1)
+			} else {
+				first.lines[0] += ",DEFAULT=YES"
+			}
+			first.attrs["DEFAULT"] = "YES"
+		}
+	}
+}
+
+// castVideoFilter returns the test of which video representations stay in a
+// master playlist converted from mpd for filter. For Chromecast only H.264 is
+// kept, if the MPD has any.
+func (h *MPDHandler) castVideoFilter(mpd *MPD, filter *types.VariantFilter) func(as *AdaptationSet, rep *Representation) bool {
+	keepAll := func(*AdaptationSet, *Representation) bool { return true }
+	if !forChromecast(filter) {
+		return keepAll
+	}
+	castable := func(as *AdaptationSet, rep *Representation) bool {
+		codecs := rep.Codecs
+		if codecs == "" {
+			codecs = as.Codecs
+		}
+		return castableCodecs(codecs)
+	}
+	for _, period := range mpd.Periods {
+		for i := range period.AdaptationSets {
+			as := &period.AdaptationSets[i]
+			if !h.isVideo(*as) {
+				continue
+			}
+			for j := range as.Representations {
+				if castable(as, &as.Representations[j]) {
+					return castable
+				}
+			}
+		}
+	}
+	return keepAll
+}
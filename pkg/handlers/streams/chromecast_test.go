@@ -0,0 +1,79 @@
+package streams
+
+import (
+	"strings"
+	"testing"
+
+	"media-proxy-go/pkg/types"
+)
+
+func TestFilterMasterPlaylist_Chromecast(t *testing.T) {
+	master := strings.Join([]string{
+		"#EXTM3U",
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",LANGUAGE="en",NAME="English",DEFAULT=NO,URI="aac_en.m3u8"`,
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="ec3",LANGUAGE="en",NAME="English 5.1",DEFAULT=YES,URI="ec3_en.m3u8"`,
+		`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",LANGUAGE="en",NAME="English",URI="subs_en.m3u8"`,
+		`#EXT-X-STREAM-INF:BANDWIDTH=8000000,CODECS="hvc1.2.4.L150,ec-3",AUDIO="ec3"`,
+		"hevc.m3u8",
+		`#EXT-X-STREAM-INF:BANDWIDTH=5000000,CODECS="avc1.640028,ec-3",AUDIO="ec3"`,
+		"avc_ec3.m3u8",
+		`#EXT-X-STREAM-INF:BANDWIDTH=4000000,CODECS="avc1.640028,mp4a.40.2",AUDIO="aac",SUBTITLES="subs"`,
+		"avc_aac.m3u8",
+		`#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=400000,CODECS="avc1.640028",URI="iframes.m3u8"`,
+	}, "\n")
+
+	got := string(filterMasterPlaylist([]byte(master), &types.VariantFilter{Device: types.DeviceChromecast}))
+	for _, want := range []string{"avc_aac.m3u8", "aac_en.m3u8", "subs_en.m3u8", `NAME="English",DEFAULT=YES`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("playlist missing %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"hevc.m3u8", "avc_ec3.m3u8", "ec3_en.m3u8", "I-FRAME"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("playlist contains %q:\n%s", unwanted, got)
+		}
+	}
+
+	// Without any castable variant all of them stay
+	hevcOnly := "#EXTM3U\n" + `#EXT-X-STREAM-INF:BANDWIDTH=8000000,CODECS="hvc1.2.4.L150,mp4a.40.2"` + "\nhevc.m3u8\n"
+	if got := string(filterMasterPlaylist([]byte(hevcOnly), &types.VariantFilter{Device: types.DeviceChromecast})); !strings.Contains(got, "hevc.m3u8") {
+		t.Errorf("HEVC-only playlist lost its variant:\n%s", got)
+	}
+}
+
+func TestMPDHandler_convertMasterPlaylist_Chromecast(t *testing.T) {
+	mpd := `<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static">
+  <Period>
+    <AdaptationSet mimeType="video/mp4" codecs="hev1.1.6.L150">
+      <Representation id="hevc" bandwidth="8000000" width="3840" height="2160"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="video/mp4">
+      <Representation id="avc" bandwidth="5000000" width="1920" height="1080" codecs="avc1.640028"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="audio/mp4" lang="en" codecs="ec-3">
+      <Representation id="ec3" bandwidth="384000"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="audio/mp4" lang="en" codecs="mp4a.40.2">
+      <Representation id="aac" bandwidth="128000"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+	h := &MPDHandler{}
+	filter := &types.VariantFilter{Device: types.DeviceChromecast, AudioCodecs: []string{"aac"}}
+	got, err := h.convertMasterPlaylist([]byte(mpd), "http://proxy", "http://origin/manifest.mpd", nil, "", filter)
+	if err != nil {
+		t.Fatalf("convertMasterPlaylist() error = %v", err)
+	}
+	for _, want := range []string{"rep_id=avc", "rep_id=aac", "RESOLUTION=1920x1080"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("playlist missing %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"rep_id=hevc", "rep_id=ec3", "I-FRAME"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("playlist contains %q:\n%s", unwanted, got)
+		}
+	}
+}
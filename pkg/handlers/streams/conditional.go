@@ -0,0 +1,56 @@
+package streams
+
+import (
+	"net/http"
+	"strings"
+
+	"media-proxy-go/pkg/types"
+)
+
+// conditionalHeaders are the client validators forwarded with manifest fetches.
+var conditionalHeaders = []string{"If-None-Match", "If-Modified-Since"}
+
+// applyValidators sets the client's validators on an upstream manifest request.
+func applyValidators(httpReq *http.Request, validators map[string]string) {
+	for _, name := range conditionalHeaders {
+		if v := validators[name]; v != "" {
+			httpReq.Header.Set(name, v)
+		}
+	}
+}
+
+// manifestHeaders returns the headers of a rewritten manifest response,
+// passing the upstream validators back so polling players can revalidate.
+func manifestHeaders(upstream http.Header) map[string]string {
+	headers := map[string]string{
+		"Cache-Control": "no-cache, no-store, must-revalidate",
+	}
+
+	etag := upstream.Get("ETag")
+	lastModified := upstream.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return headers
+	}
+
+	// Revalidate on every request instead of not storing at all
+	headers["Cache-Control"] = "no-cache"
+	if etag != "" {
+		// The body is rewritten, so it is only semantically equivalent to upstream's
+		if !strings.HasPrefix(etag, "W/") {
+			etag = "W/" + etag
+		}
+		headers["ETag"] = etag
+	}
+	if lastModified != "" {
+		headers["Last-Modified"] = lastModified
+	}
+	return headers
+}
+
+// notModifiedResponse answers an upstream 304 without fetching or converting anything.
+func notModifiedResponse(upstream http.Header) *types.StreamResponse {
+	return &types.StreamResponse{
+		StatusCode: http.StatusNotModified,
+		Headers:    manifestHeaders(upstream),
+	}
+}
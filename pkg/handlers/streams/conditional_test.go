@@ -0,0 +1,86 @@
+package streams
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+func TestHandleManifest_ConditionalGet(t *testing.T) {
+	const etag = `"v1"`
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if inm := r.Header.Get("If-None-Match"); inm == etag || inm == "W/"+etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.URL.Path == "/live.mpd" {
+			w.Write([]byte(`<MPD type="dynamic"><Period><AdaptationSet mimeType="video/mp4"><Representation id="v1" bandwidth="1000"/></AdaptationSet></Period></MPD>`))
+			return
+		}
+		w.Write([]byte("#EXTM3U\n#EXTINF:4.0,\nseg1.ts\n"))
+	}))
+	defer upstream.Close()
+
+	log := logging.New("error", false, nil)
+	client := httpclient.New(&config.Config{}, log)
+	handlers := map[string]interfaces.StreamHandler{
+		"hls": NewHLSHandler(client, log, "http://proxy"),
+		"mpd": NewMPDHandler(client, log, "http://proxy", nil),
+	}
+	paths := map[string]string{"hls": "/live.m3u8", "mpd": "/live.mpd"}
+
+	for name, h := range handlers {
+		t.Run(name, func(t *testing.T) {
+			req := &types.StreamRequest{URL: upstream.URL + paths[name]}
+			resp, err := h.HandleManifest(t.Context(), req, "http://proxy")
+			if err != nil {
+				t.Fatalf("HandleManifest() error = %v", err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+			if got := resp.Headers["ETag"]; got != "W/"+etag {
+				t.Errorf("ETag = %q, want W/%s", got, etag)
+			}
+			if got := resp.Headers["Cache-Control"]; got != "no-cache" {
+				t.Errorf("Cache-Control = %q, want no-cache", got)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if got := resp.Headers["Content-Length"]; got != strconv.Itoa(len(body)) {
+				t.Errorf("Content-Length = %q, want %d", got, len(body))
+			}
+
+			req = &types.StreamRequest{
+				URL:        upstream.URL + paths[name],
+				Validators: map[string]string{"If-None-Match": resp.Headers["ETag"]},
+			}
+			resp, err = h.HandleManifest(t.Context(), req, "http://proxy")
+			if err != nil {
+				t.Fatalf("HandleManifest() error = %v", err)
+			}
+			if resp.StatusCode != http.StatusNotModified || resp.Body != nil {
+				t.Errorf("revalidation status = %d, want 304 without body", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestManifestHeaders_NoValidators(t *testing.T) {
+	headers := manifestHeaders(http.Header{})
+	if headers["Cache-Control"] != "no-cache, no-store, must-revalidate" {
+		t.Errorf("Cache-Control = %q", headers["Cache-Control"])
+	}
+	if _, ok := headers["ETag"]; ok {
+		t.Error("ETag set without upstream validators")
+	}
+}
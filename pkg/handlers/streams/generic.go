@@ -1,8 +1,11 @@
 package streams
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"path"
 	"strings"
@@ -44,17 +47,76 @@ func (h *GenericHandler) CanHandle(urlStr string) bool {
 	return false
 }
 
-// HandleManifest is not applicable for generic streams, returns the stream directly.
+// HandleManifest proxies the content directly. Extension-less URLs end up
+// here too, so the response is sniffed and marked when it is an HLS or DASH
+// manifest, for the caller to hand it to the right handler along with the
+// upstream response.
 func (h *GenericHandler) HandleManifest(ctx context.Context, req *types.StreamRequest, baseURL string) (*types.StreamResponse, error) {
-	// For generic streams, just proxy the content directly
-	return h.HandleSegment(ctx, req)
+	upstream, err := h.fetch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp := h.response(req, upstream)
+	if resp.Body == nil || resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	br := bufio.NewReaderSize(resp.Body, sniffLen)
+	head, _ := br.Peek(sniffLen)
+	resp.Detected = sniffStreamType(resp.ContentType, head)
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{br, resp.Body}
+	if resp.Detected != types.StreamTypeGeneric {
+		h.log.Debug("sniffed manifest", "url", req.URL, "type", resp.Detected, "content_type", resp.ContentType)
+		upstream.Body = resp.Body
+		resp.Upstream = upstream
+	}
+	return resp, nil
+}
+
+// sniffLen is how much of a body sniffStreamType looks at.
+const sniffLen = 512
+
+// sniffStreamType tells HLS playlists and DASH manifests from other content
+// by their content type or, as servers often send text/plain or
+// application/octet-stream, by how the body starts.
+func sniffStreamType(contentType string, head []byte) types.StreamType {
+	contentType = strings.ToLower(contentType)
+	switch {
+	case strings.Contains(contentType, "mpegurl"):
+		return types.StreamTypeHLS
+	case strings.Contains(contentType, "dash+xml"):
+		return types.StreamTypeMPD
+	case strings.HasPrefix(contentType, "video/"), strings.HasPrefix(contentType, "audio/"):
+		return types.StreamTypeGeneric
+	}
+
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(head, []byte("#EXTM3U")):
+		return types.StreamTypeHLS
+	case bytes.HasPrefix(head, []byte("<")) && bytes.Contains(head, []byte("<MPD")):
+		return types.StreamTypeMPD
+	}
+	return types.StreamTypeGeneric
 }
 
 // HandleSegment proxies the stream content.
 func (h *GenericHandler) HandleSegment(ctx context.Context, req *types.StreamRequest) (*types.StreamResponse, error) {
+	resp, err := h.fetch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return h.response(req, resp), nil
+}
+
+// fetch requests the stream from upstream.
+func (h *GenericHandler) fetch(ctx context.Context, req *types.StreamRequest) (*http.Response, error) {
 	h.log.Debug("handling generic stream", "url", req.URL)
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
+	httpReq, err := http.NewRequestWithContext(segmentContext(ctx, h.client, req), http.MethodGet, req.URL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -63,14 +125,19 @@ func (h *GenericHandler) HandleSegment(ctx context.Context, req *types.StreamReq
 	for key, value := range req.Headers {
 		httpReq.Header.Set(key, value)
 	}
-	if httpReq.Header.Get("User-Agent") == "" {
-		httpReq.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-	}
 
 	resp, err := h.client.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch stream: %w", err)
 	}
+	return resp, nil
+}
+
+// response passes an upstream response on to the client.
+func (h *GenericHandler) response(req *types.StreamRequest, resp *http.Response) *types.StreamResponse {
+	if redirect := redirectResponse(resp); redirect != nil {
+		return redirect
+	}
 
 	contentType := resp.Header.Get("Content-Type")
 	if contentType == "" {
@@ -78,21 +145,17 @@ func (h *GenericHandler) HandleSegment(ctx context.Context, req *types.StreamReq
 	}
 
 	// Build response headers
-	headers := make(map[string]string)
-	if cl := resp.Header.Get("Content-Length"); cl != "" {
-		headers["Content-Length"] = cl
+	headers := withForwarded(h.client, resp.Header, transferHeaders(resp))
+	if headers["Accept-Ranges"] == "" {
+		headers["Accept-Ranges"] = "bytes"
 	}
-	if cr := resp.Header.Get("Content-Range"); cr != "" {
-		headers["Content-Range"] = cr
-	}
-	headers["Accept-Ranges"] = "bytes"
 
 	return &types.StreamResponse{
 		ContentType: contentType,
 		Body:        resp.Body,
 		StatusCode:  resp.StatusCode,
 		Headers:     headers,
-	}, nil
+	}
 }
 
 // guessContentType guesses the content type based on file extension.
@@ -0,0 +1,34 @@
+package streams
+
+import (
+	"testing"
+
+	"media-proxy-go/pkg/types"
+)
+
+func TestSniffStreamType(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		head        string
+		want        types.StreamType
+	}{
+		{"hls content type", "application/vnd.apple.mpegurl", "", types.StreamTypeHLS},
+		{"legacy hls content type", "audio/x-mpegURL", "", types.StreamTypeHLS},
+		{"dash content type", "application/dash+xml", "", types.StreamTypeMPD},
+		{"hls body", "text/plain", "#EXTM3U\n#EXT-X-VERSION:3\n", types.StreamTypeHLS},
+		{"hls body with BOM", "application/octet-stream", "\xef\xbb\xbf\r\n#EXTM3U\n", types.StreamTypeHLS},
+		{"dash body", "text/xml", `<?xml version="1.0"?>` + "\n" + `<MPD xmlns="urn:mpeg:dash:schema:mpd:2011">`, types.StreamTypeMPD},
+		{"video content type wins", "video/mp2t", "#EXTM3U", types.StreamTypeGeneric},
+		{"transport stream", "", "\x47\x40\x00\x10", types.StreamTypeGeneric},
+		{"html page", "text/html", "<html><body>not a manifest</body></html>", types.StreamTypeGeneric},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sniffStreamType(tt.contentType, []byte(tt.head)); got != tt.want {
+				t.Errorf("sniffStreamType() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
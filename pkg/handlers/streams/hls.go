@@ -9,7 +9,11 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"media-proxy-go/pkg/httpclient"
 	"media-proxy-go/pkg/interfaces"
@@ -23,14 +27,18 @@ type HLSHandler struct {
 	client  *httpclient.Client
 	log     *logging.Logger
 	baseURL string
+
+	adsMu     sync.Mutex
+	twitchAds map[string]*twitchAdState // By playlist URL
 }
 
 // NewHLSHandler creates a new HLS stream handler.
 func NewHLSHandler(client *httpclient.Client, log *logging.Logger, baseURL string) *HLSHandler {
 	return &HLSHandler{
-		client:  client,
-		log:     log.WithComponent("hls-handler"),
-		baseURL: baseURL,
+		client:    client,
+		log:       log.WithComponent("hls-handler"),
+		baseURL:   baseURL,
+		twitchAds: make(map[string]*twitchAdState),
 	}
 }
 
@@ -67,29 +75,20 @@ func (h *HLSHandler) HandleManifest(ctx context.Context, req *types.StreamReques
 		"no_bypass", req.NoBypass,
 	)
 
-	// Fetch the original manifest
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Apply headers
-	for key, value := range req.Headers {
-		httpReq.Header.Set(key, value)
-	}
-	if httpReq.Header.Get("User-Agent") == "" {
-		httpReq.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-	}
-
-	resp, err := h.client.Do(httpReq)
-	if err != nil {
-		h.log.Error("failed to fetch manifest", "url", req.URL, "error", err)
-		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	resp := req.Prefetched
+	if resp == nil {
+		var err error
+		if resp, err = h.fetchManifest(ctx, req); err != nil {
+			return nil, err
+		}
 	}
 	defer resp.Body.Close()
 
 	h.log.Debug("manifest fetch response", "url", req.URL, "status", resp.StatusCode)
 
+	if resp.StatusCode == http.StatusNotModified {
+		return notModifiedResponse(resp.Header), nil
+	}
 	if resp.StatusCode != http.StatusOK {
 		h.log.Warn("manifest fetch failed", "url", req.URL, "status", resp.StatusCode)
 		return &types.StreamResponse{
@@ -98,32 +97,66 @@ func (h *HLSHandler) HandleManifest(ctx context.Context, req *types.StreamReques
 	}
 
 	// Read manifest content
-	body, err := io.ReadAll(resp.Body)
+	body, err := h.client.ReadBody(resp.Body, httpclient.ClassManifest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read manifest: %w", err)
 	}
 
+	// Drop Twitch server-side inserted ads so players and recordings only see the live content
+	body = h.stripTwitchAds(req.URL, body)
+
+	// Keep only the requested renditions of a master playlist
+	if req.Filter != nil && bytes.Contains(body, []byte("#EXT-X-STREAM-INF")) {
+		body = filterMasterPlaylist(body, req.Filter)
+	}
+
 	// Rewrite the manifest
 	rewritten, err := h.rewriteManifest(body, req.URL, baseURL, req.Headers, req.NoBypass)
 	if err != nil {
 		return nil, fmt.Errorf("failed to rewrite manifest: %w", err)
 	}
 
+	headers := withForwarded(h.client, resp.Header, manifestHeaders(resp.Header))
+	headers["Content-Length"] = strconv.Itoa(len(rewritten))
+
 	return &types.StreamResponse{
 		ContentType: "application/vnd.apple.mpegurl",
 		Body:        io.NopCloser(bytes.NewReader(rewritten)),
 		StatusCode:  http.StatusOK,
-		Headers: map[string]string{
-			"Cache-Control": "no-cache, no-store, must-revalidate",
-		},
+		Headers:     headers,
 	}, nil
 }
 
+// fetchManifest fetches the original manifest, with any LL-HLS delivery
+// directives.
+func (h *HLSHandler) fetchManifest(ctx context.Context, req *types.StreamRequest) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, withDirectives(req.URL, req.Directives), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Apply headers
+	for key, value := range req.Headers {
+		httpReq.Header.Set(key, value)
+	}
+	if httpReq.Header.Get("Accept-Encoding") == "" {
+		httpReq.Header.Set("Accept-Encoding", httpclient.AcceptEncoding)
+	}
+	applyValidators(httpReq, req.Validators)
+
+	resp, err := h.client.DoClass(httpReq, httpclient.ClassManifest)
+	if err != nil {
+		h.log.Error("failed to fetch manifest", "url", req.URL, "error", err)
+		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	return resp, nil
+}
+
 // HandleSegment proxies an HLS segment.
 func (h *HLSHandler) HandleSegment(ctx context.Context, req *types.StreamRequest) (*types.StreamResponse, error) {
 	h.log.Debug("handling HLS segment", "url", req.URL)
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
+	httpReq, err := http.NewRequestWithContext(segmentContext(ctx, h.client, req), http.MethodGet, req.URL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -136,19 +169,67 @@ func (h *HLSHandler) HandleSegment(ctx context.Context, req *types.StreamRequest
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch segment: %w", err)
 	}
+	if redirect := redirectResponse(resp); redirect != nil {
+		return redirect, nil
+	}
 
 	contentType := resp.Header.Get("Content-Type")
 	if contentType == "" {
-		contentType = "video/MP2T"
+		contentType = segmentContentType(req.URL)
 	}
 
+	// Byte-range segments (EXT-X-BYTERANGE, EXT-X-MAP/EXT-X-PART BYTERANGE) are partial responses
 	return &types.StreamResponse{
 		ContentType: contentType,
 		Body:        resp.Body,
 		StatusCode:  resp.StatusCode,
+		Headers:     withForwarded(h.client, resp.Header, transferHeaders(resp)),
 	}, nil
 }
 
+// segmentContentType guesses a segment's content type from its extension.
+func segmentContentType(urlStr string) string {
+	switch segmentExt(urlStr) {
+	case ".m4s", ".cmfv", ".cmfa":
+		return "video/iso.segment"
+	case ".mp4", ".m4v", ".fmp4":
+		return "video/mp4"
+	case ".m4a":
+		return "audio/mp4"
+	case ".aac":
+		return "audio/aac"
+	case ".vtt", ".webvtt":
+		return "text/vtt"
+	}
+	return "video/MP2T"
+}
+
+// segmentExt returns the lowercase file extension of a URL's path.
+func segmentExt(urlStr string) string {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(path.Ext(u.Path))
+}
+
+// withDirectives adds LL-HLS delivery directives to a playlist URL.
+func withDirectives(urlStr string, directives url.Values) string {
+	if len(directives) == 0 {
+		return urlStr
+	}
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return urlStr
+	}
+	query := u.Query()
+	for name, values := range directives {
+		query[name] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
 // CDNs with fast-expiring tokens that should not be proxied
 var bypassProxyCDNs = []string{
 	"planetary.lovecdn.ru",
@@ -178,6 +259,9 @@ func (h *HLSHandler) rewriteManifest(manifest []byte, originalURL, proxyBaseURL
 	// noBypass=true forces all segments through proxy (used for recordings)
 	bypassSegments := !noBypass && h.shouldBypassProxy(originalURL)
 
+	// Segments of a finished playlist never change; mark them for the segment cache
+	vod := isVODPlaylist(manifest)
+
 	h.log.Debug("rewriting manifest",
 		"original_url", originalURL,
 		"bypass_segments", bypassSegments,
@@ -199,10 +283,13 @@ func (h *HLSHandler) rewriteManifest(manifest []byte, originalURL, proxyBaseURL
 
 		// Handle tags
 		if strings.HasPrefix(line, "#") {
-			// Rewrite URI in tags like #EXT-X-KEY, #EXT-X-MAP
+			// Rewrite URI in tags like #EXT-X-KEY, #EXT-X-MAP, #EXT-X-PART,
+			// #EXT-X-PRELOAD-HINT and #EXT-X-RENDITION-REPORT. Other attributes
+			// (BYTERANGE) and #EXT-X-BYTERANGE lines are kept as-is: the player
+			// sends Range requests, which the proxy forwards.
 			// But check if the URI itself should bypass proxy
 			if strings.Contains(line, "URI=") {
-				line = h.rewriteURITag(line, baseURL, proxyBaseURL, headers, bypassSegments)
+				line = h.rewriteURITag(line, baseURL, proxyBaseURL, headers, bypassSegments, vod)
 			}
 			result.WriteString(line + "\n")
 			continue
@@ -221,7 +308,7 @@ func (h *HLSHandler) rewriteManifest(manifest []byte, originalURL, proxyBaseURL
 			// Don't proxy segments - use direct URL (fast-expiring tokens)
 			result.WriteString(segmentURL + "\n")
 		} else {
-			proxyURL := h.buildProxyURL(segmentURL, proxyBaseURL, headers)
+			proxyURL := h.buildProxyURL(segmentURL, proxyBaseURL, headers, vod)
 			result.WriteString(proxyURL + "\n")
 		}
 	}
@@ -229,8 +316,182 @@ func (h *HLSHandler) rewriteManifest(manifest []byte, originalURL, proxyBaseURL
 	return result.Bytes(), scanner.Err()
 }
 
+// twitchAdClass is the EXT-X-DATERANGE class Twitch uses to mark stitched ads.
+const twitchAdClass = "twitch-stitched-ad"
+
+// twitchAdStateTTL is how long the ad state of a Twitch stream is kept after
+// its last playlist refresh.
+const twitchAdStateTTL = 5 * time.Minute
+
+// twitchAdState is what stripping the ads of a Twitch stream remembers between
+// playlist refreshes, so the kept segments get the same media and
+// discontinuity sequence numbers in each.
+type twitchAdState struct {
+	ads             map[int64]bool // Upstream sequence numbers of the removed ads still in the window
+	removed         int64          // Removed ads that left the window
+	marks           map[int64]bool // Whether each kept segment in the window follows a discontinuity
+	discontinuities int64          // Discontinuities that left the window
+	started         bool
+	used            time.Time
+}
+
+func newTwitchAdState() *twitchAdState {
+	return &twitchAdState{ads: make(map[int64]bool), marks: make(map[int64]bool)}
+}
+
+// stripTwitchAds removes the stitched ads of a Twitch media playlist fetched
+// from playlistURL. Playlists of streams that never carried ads are returned
+// as they are; once a stream has, every refresh is numbered consistently
+// with the earlier ones.
+func (h *HLSHandler) stripTwitchAds(playlistURL string, manifest []byte) []byte {
+	h.adsMu.Lock()
+	defer h.adsMu.Unlock()
+
+	now := time.Now()
+	for key, state := range h.twitchAds {
+		if now.Sub(state.used) > twitchAdStateTTL {
+			delete(h.twitchAds, key)
+		}
+	}
+	state, ok := h.twitchAds[playlistURL]
+	if !ok {
+		if !bytes.Contains(manifest, []byte(twitchAdClass)) {
+			return manifest
+		}
+		state = newTwitchAdState()
+		h.twitchAds[playlistURL] = state
+	}
+	state.used = now
+	return state.strip(manifest)
+}
+
+// strip removes the ad segments of a refresh of the playlist. Live content
+// segments are titled "live"; ad segments carry other titles (e.g. "Amazon")
+// and are announced with a twitch-stitched-ad DATERANGE. The kept segments
+// are numbered as if the ads never were, and each one following removed ads
+// or an upstream discontinuity is marked with a single discontinuity.
+func (s *twitchAdState) strip(manifest []byte) []byte {
+	var sequence, discontinuitySequence int64
+	for _, line := range strings.Split(string(manifest), "\n") {
+		line = strings.TrimSpace(line)
+		if value, ok := strings.CutPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"); ok {
+			sequence, _ = strconv.ParseInt(value, 10, 64)
+		}
+		if value, ok := strings.CutPrefix(line, "#EXT-X-DISCONTINUITY-SEQUENCE:"); ok {
+			discontinuitySequence, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	if !s.started {
+		s.discontinuities = discontinuitySequence
+		s.started = true
+	}
+
+	var lines, tags []string // tags: since the last segment, written with the next
+	next, first := sequence, int64(-1)
+	inSegment, skip, discontinuity := false, false, false
+
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-DATERANGE") && strings.Contains(line, twitchAdClass):
+		case line == "#EXT-X-DISCONTINUITY":
+			// Written once before the next kept segment
+			discontinuity = true
+		case strings.HasPrefix(line, "#EXTINF:"):
+			_, title, _ := strings.Cut(line, ",")
+			inSegment, skip = true, !strings.HasPrefix(title, "live")
+			for _, tag := range tags {
+				// The date of an ad goes with it; keys and maps still apply
+				if !skip || !strings.HasPrefix(tag, "#EXT-X-PROGRAM-DATE-TIME") {
+					lines = append(lines, tag)
+				}
+			}
+			tags = nil
+			if skip {
+				continue
+			}
+			// A segment keeps the mark it got when first seen, as upstream
+			// drops the discontinuity of the first segment of its window
+			mark, seen := s.marks[next]
+			if !seen {
+				mark = discontinuity || s.ads[next-1]
+				s.marks[next] = mark
+			}
+			if mark {
+				lines = append(lines, "#EXT-X-DISCONTINUITY")
+			}
+			discontinuity = false
+			if first < 0 {
+				first = next
+			}
+			lines = append(lines, line)
+		case inSegment && line != "" && !strings.HasPrefix(line, "#"):
+			// Segment URI
+			inSegment = false
+			if skip {
+				s.ads[next] = true
+			} else {
+				lines = append(lines, line)
+			}
+			next++
+		case inSegment:
+			if !skip {
+				lines = append(lines, line)
+			}
+		case strings.HasPrefix(line, "#"):
+			tags = append(tags, line)
+		default:
+			lines = append(lines, line)
+		}
+	}
+	lines = append(lines, tags...)
+	if first < 0 {
+		first = next
+	}
+
+	// Forget the segments that left the window, counting the removed ads and
+	// the discontinuities before the first segment
+	for seq := range s.ads {
+		if seq < sequence {
+			delete(s.ads, seq)
+			s.removed++
+		}
+	}
+	for seq, mark := range s.marks {
+		if seq < first {
+			delete(s.marks, seq)
+			if mark {
+				s.discontinuities++
+			}
+		}
+	}
+	mediaSequence := first - s.removed
+	for seq := range s.ads {
+		if seq < first {
+			mediaSequence--
+		}
+	}
+
+	var result bytes.Buffer
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			line = "#EXT-X-MEDIA-SEQUENCE:" + strconv.FormatInt(mediaSequence, 10)
+			if !bytes.Contains(manifest, []byte("#EXT-X-DISCONTINUITY-SEQUENCE:")) && s.discontinuities > 0 {
+				line += "\n#EXT-X-DISCONTINUITY-SEQUENCE:" + strconv.FormatInt(s.discontinuities, 10)
+			}
+		case strings.HasPrefix(line, "#EXT-X-DISCONTINUITY-SEQUENCE:"):
+			line = "#EXT-X-DISCONTINUITY-SEQUENCE:" + strconv.FormatInt(s.discontinuities, 10)
+		}
+		result.WriteString(line + "\n")
+	}
+	return result.Bytes()
+}
+
 // rewriteURITag rewrites the URI attribute in HLS tags.
-func (h *HLSHandler) rewriteURITag(line string, baseURL *url.URL, proxyBaseURL string, headers map[string]string, bypassProxy bool) string {
+func (h *HLSHandler) rewriteURITag(line string, baseURL *url.URL, proxyBaseURL string, headers map[string]string, bypassProxy, vod bool) string {
 	// Find URI="..." pattern
 	start := strings.Index(line, "URI=\"")
 	if start == -1 {
@@ -246,12 +507,21 @@ func (h *HLSHandler) rewriteURITag(line string, baseURL *url.URL, proxyBaseURL s
 	uri := line[start : start+end]
 	resolvedURL := h.resolveURL(uri, baseURL)
 
+	// Keys go through /key with the stream headers, even when segments bypass
+	// the proxy: key servers usually check Referer/Origin or cookies
+	if strings.HasPrefix(line, "#EXT-X-KEY") || strings.HasPrefix(line, "#EXT-X-SESSION-KEY") {
+		if u, err := url.Parse(uri); err != nil || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+			return line // skd://, data: and other DRM key URIs
+		}
+		return line[:start] + h.buildKeyURL(resolvedURL, proxyBaseURL, headers) + line[start+end:]
+	}
+
 	// Check if this URL should bypass proxy
 	if bypassProxy || h.shouldBypassProxy(resolvedURL) {
 		return line[:start] + resolvedURL + line[start+end:]
 	}
 
-	proxyURL := h.buildProxyURL(resolvedURL, proxyBaseURL, headers)
+	proxyURL := h.buildProxyURL(resolvedURL, proxyBaseURL, headers, vod)
 	return line[:start] + proxyURL + line[start+end:]
 }
 
@@ -262,18 +532,51 @@ func (h *HLSHandler) resolveURL(urlStr string, base *url.URL) string {
 }
 
 // buildProxyURL builds a proxy URL with the target URL and headers encoded.
-func (h *HLSHandler) buildProxyURL(targetURL, proxyBaseURL string, headers map[string]string) string {
+// vod marks segments of a VOD playlist as cacheable.
+func (h *HLSHandler) buildProxyURL(targetURL, proxyBaseURL string, headers map[string]string, vod bool) string {
 	// Determine the correct endpoint based on URL type
-	path := "/proxy/stream"
+	endpoint := "/proxy/stream"
 	lower := strings.ToLower(targetURL)
 	if strings.Contains(lower, ".m3u8") {
-		path = "/proxy/manifest.m3u8"
+		endpoint = "/proxy/manifest.m3u8"
+	} else {
+		// Give players that go by the extension a hint for fMP4 segments
+		switch segmentExt(targetURL) {
+		case ".m4s", ".cmfv", ".cmfa":
+			endpoint = "/proxy/hls/segment.m4s"
+		case ".mp4", ".m4v", ".fmp4":
+			endpoint = "/proxy/hls/segment.mp4"
+		}
 	}
 
-	proxyURL, _ := url.Parse(proxyBaseURL + path)
+	proxyURL, _ := url.Parse(proxyBaseURL + endpoint)
 	query := proxyURL.Query()
 	query.Set("url", targetURL)
 
+	for key, value := range headers {
+		query.Set("h_"+key, value)
+	}
+	if vod && endpoint != "/proxy/manifest.m3u8" {
+		query.Set("vod", "1")
+	}
+
+	proxyURL.RawQuery = query.Encode()
+	return proxyURL.String()
+}
+
+// isVODPlaylist reports whether a media playlist is complete: it has an
+// EXT-X-ENDLIST tag or is declared as a VOD playlist.
+func isVODPlaylist(manifest []byte) bool {
+	return bytes.Contains(manifest, []byte("#EXT-X-ENDLIST")) ||
+		bytes.Contains(manifest, []byte("#EXT-X-PLAYLIST-TYPE:VOD"))
+}
+
+// buildKeyURL builds a /key URL fetching keyURL with the given headers.
+func (h *HLSHandler) buildKeyURL(keyURL, proxyBaseURL string, headers map[string]string) string {
+	proxyURL, _ := url.Parse(proxyBaseURL + "/key")
+	query := proxyURL.Query()
+	query.Set("url", keyURL)
+
 	for key, value := range headers {
 		query.Set("h_"+key, value)
 	}
@@ -0,0 +1,379 @@
+package streams
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"media-proxy-go/pkg/types"
+)
+
+// subtitlesAttrRe matches the SUBTITLES attribute of an EXT-X-STREAM-INF tag.
+var subtitlesAttrRe = regexp.MustCompile(`,?SUBTITLES="[^"]*"`)
+
+// audioCodecPrefixes identify the audio entries of a CODECS attribute.
+var audioCodecPrefixes = []string{"mp4a", "ac-3", "ec-3", "opus", "flac", "alac", "mp3"}
+
+// audioOnlyBandwidth is advertised for the variants built from audio
+// renditions, which carry no BANDWIDTH of their own.
+const audioOnlyBandwidth = 192000
+
+// playlistEntry is a tag of a master playlist, with the URI line of a variant.
+type playlistEntry struct {
+	lines []string
+	tag   string            // Tag name, e.g. "#EXT-X-STREAM-INF"
+	attrs map[string]string // Tag attributes
+	keep  bool
+}
+
+// filterMasterPlaylist removes the renditions of an HLS master playlist that
+// don't match filter. If no variant matches, the closest one is kept so the
+// stream stays playable.
+func filterMasterPlaylist(manifest []byte, filter *types.VariantFilter) []byte {
+	var entries []*playlistEntry
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	var variant *playlistEntry
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// A variant's tag is followed by its URI
+		if variant != nil {
+			variant.lines = append(variant.lines, line)
+			if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				variant = nil
+			}
+			continue
+		}
+
+		entry := &playlistEntry{lines: []string{line}, keep: true}
+		if tag, attrs, ok := strings.Cut(line, ":"); ok && strings.HasPrefix(tag, "#EXT") {
+			entry.tag = tag
+			entry.attrs = parseAttributes(attrs)
+		}
+		if entry.tag == "#EXT-X-STREAM-INF" {
+			variant = entry
+		}
+		entries = append(entries, entry)
+	}
+
+	if !filter.AudioOnly {
+		filterVariants(entries, filter)
+	}
+	if len(filter.AudioLangs) > 0 {
+		filterAudio(entries, filter.AudioLangs)
+	}
+	if filter.AudioOnly {
+		entries = keepAudioOnly(entries)
+	}
+	if forChromecast(filter) {
+		castCleanup(entries)
+	}
+	if filter.Pin != "" {
+		pinVariant(entries, filter)
+	}
+
+	var result bytes.Buffer
+	for _, entry := range entries {
+		if !entry.keep {
+			continue
+		}
+		if filter.DropSubtitles {
+			if entry.tag == "#EXT-X-MEDIA" && entry.attrs["TYPE"] == "SUBTITLES" {
+				continue
+			}
+			if entry.tag == "#EXT-X-STREAM-INF" {
+				entry.lines[0] = subtitlesAttrRe.ReplaceAllString(entry.lines[0], "")
+				entry.lines[0] = strings.Replace(entry.lines[0], ":,", ":", 1)
+			}
+		}
+		for _, line := range entry.lines {
+			result.WriteString(line + "\n")
+		}
+	}
+	return result.Bytes()
+}
+
+// filterVariants marks the variants and I-frame playlists outside the
+// resolution and bandwidth limits as dropped.
+func filterVariants(entries []*playlistEntry, filter *types.VariantFilter) {
+	var variants []*playlistEntry
+	kept := 0
+	for _, entry := range entries {
+		if entry.tag != "#EXT-X-STREAM-INF" && entry.tag != "#EXT-X-I-FRAME-STREAM-INF" {
+			continue
+		}
+
+		width, height := parseResolution(entry.attrs["RESOLUTION"])
+		bandwidth, _ := strconv.Atoi(entry.attrs["BANDWIDTH"])
+		entry.keep = (filter.MaxWidth == 0 || width <= filter.MaxWidth) &&
+			(filter.MaxHeight == 0 || height <= filter.MaxHeight) &&
+			(filter.MaxBandwidth == 0 || bandwidth <= filter.MaxBandwidth) &&
+			bandwidth >= filter.MinBandwidth
+
+		if entry.tag == "#EXT-X-STREAM-INF" {
+			variants = append(variants, entry)
+			if entry.keep {
+				kept++
+			}
+		}
+	}
+	if kept > 0 || len(variants) == 0 {
+		return
+	}
+
+	// Nothing matched: keep the lowest variant when limiting resolution or
+	// bandwidth, otherwise the highest
+	lowest := filter.MaxWidth > 0 || filter.MaxHeight > 0 || filter.MaxBandwidth > 0
+	best, bestBandwidth := variants[0], -1
+	for _, v := range variants {
+		bandwidth, _ := strconv.Atoi(v.attrs["BANDWIDTH"])
+		if bestBandwidth < 0 || (lowest && bandwidth < bestBandwidth) || (!lowest && bandwidth > bestBandwidth) {
+			best, bestBandwidth = v, bandwidth
+		}
+	}
+	best.keep = true
+}
+
+// pinVariant keeps a single variant, the highest or lowest BANDWIDTH one of
+// those left by the other filters (within MaxBandwidth for audio-only
+// playlists, which filterVariants skips), so FFmpeg can't pick another. I-frame
+// playlists and the renditions of groups the variant doesn't use are dropped.
+func pinVariant(entries []*playlistEntry, filter *types.VariantFilter) {
+	var candidates, within []*playlistEntry
+	for _, entry := range entries {
+		if entry.tag == "#EXT-X-STREAM-INF" && entry.keep {
+			candidates = append(candidates, entry)
+			bandwidth, _ := strconv.Atoi(entry.attrs["BANDWIDTH"])
+			if filter.MaxBandwidth == 0 || bandwidth <= filter.MaxBandwidth {
+				within = append(within, entry)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	lowest := filter.Pin == types.PinWorst
+	if len(within) > 0 {
+		candidates = within
+	} else {
+		lowest = true
+	}
+	pinned, pinnedBandwidth := candidates[0], -1
+	for _, v := range candidates {
+		bandwidth, _ := strconv.Atoi(v.attrs["BANDWIDTH"])
+		if pinnedBandwidth < 0 || (lowest && bandwidth < pinnedBandwidth) || (!lowest && bandwidth > pinnedBandwidth) {
+			pinned, pinnedBandwidth = v, bandwidth
+		}
+	}
+
+	groups := map[string]bool{}
+	for _, attr := range []string{"AUDIO", "VIDEO", "SUBTITLES", "CLOSED-CAPTIONS"} {
+		if id := pinned.attrs[attr]; id != "" {
+			groups[attr+"/"+id] = true
+		}
+	}
+	for _, entry := range entries {
+		switch entry.tag {
+		case "#EXT-X-STREAM-INF":
+			entry.keep = entry == pinned
+		case "#EXT-X-I-FRAME-STREAM-INF":
+			entry.keep = false
+		case "#EXT-X-MEDIA":
+			if !groups[entry.attrs["TYPE"]+"/"+entry.attrs["GROUP-ID"]] {
+				entry.keep = false
+			}
+		}
+	}
+}
+
+// keepAudioOnly turns a master playlist into audio-only variants. With
+// separate audio renditions, each audio group becomes one variant playing its
+// default rendition (the group's other languages stay selectable). With muxed
+// audio, only audio-only variants are kept, or the lowest variant if there
+// are none.
+func keepAudioOnly(entries []*playlistEntry) []*playlistEntry {
+	var groupIDs []string
+	defaults := make(map[string]*playlistEntry)
+	codecs := make(map[string]string)
+
+	for _, entry := range entries {
+		switch entry.tag {
+		case "#EXT-X-MEDIA":
+			if entry.attrs["TYPE"] != "AUDIO" {
+				entry.keep = false
+				continue
+			}
+			id := entry.attrs["GROUP-ID"]
+			if !entry.keep || entry.attrs["URI"] == "" {
+				continue
+			}
+			if _, ok := defaults[id]; !ok {
+				groupIDs = append(groupIDs, id)
+				defaults[id] = entry
+			} else if strings.Contains(entry.lines[0], "DEFAULT=YES") && !strings.Contains(defaults[id].lines[0], "DEFAULT=YES") {
+				defaults[id] = entry
+			}
+		case "#EXT-X-STREAM-INF":
+			if group := entry.attrs["AUDIO"]; group != "" && codecs[group] == "" {
+				codecs[group] = strings.Join(audioCodecs(entry.attrs["CODECS"]), ",")
+			}
+		}
+	}
+
+	if len(groupIDs) > 0 {
+		for _, entry := range entries {
+			if entry.tag == "#EXT-X-STREAM-INF" || entry.tag == "#EXT-X-I-FRAME-STREAM-INF" {
+				entry.keep = false
+			}
+		}
+		for _, id := range groupIDs {
+			inf := fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d", audioOnlyBandwidth)
+			if codecs[id] != "" {
+				inf += fmt.Sprintf(",CODECS=\"%s\"", codecs[id])
+			}
+			inf += fmt.Sprintf(",AUDIO=\"%s\"", id)
+			entries = append(entries, &playlistEntry{
+				lines: []string{inf, defaults[id].attrs["URI"]},
+				tag:   "#EXT-X-STREAM-INF",
+				attrs: map[string]string{"BANDWIDTH": strconv.Itoa(audioOnlyBandwidth), "AUDIO": id},
+				keep:  true,
+			})
+		}
+		return entries
+	}
+
+	// Muxed audio: keep the variants without video
+	var lowest *playlistEntry
+	lowestBandwidth, kept := -1, 0
+	for _, entry := range entries {
+		switch entry.tag {
+		case "#EXT-X-I-FRAME-STREAM-INF":
+			entry.keep = false
+		case "#EXT-X-STREAM-INF":
+			attr := entry.attrs["CODECS"]
+			entry.keep = attr != "" && len(audioCodecs(attr)) == len(strings.Split(attr, ","))
+			if entry.keep {
+				kept++
+			}
+			bandwidth, _ := strconv.Atoi(entry.attrs["BANDWIDTH"])
+			if lowestBandwidth < 0 || bandwidth < lowestBandwidth {
+				lowest, lowestBandwidth = entry, bandwidth
+			}
+		}
+	}
+	if kept == 0 && lowest != nil {
+		lowest.keep = true
+	}
+	return entries
+}
+
+// audioCodecs returns the audio codecs of a CODECS attribute.
+func audioCodecs(codecs string) []string {
+	var audio []string
+	for _, codec := range strings.Split(codecs, ",") {
+		codec = strings.TrimSpace(codec)
+		for _, prefix := range audioCodecPrefixes {
+			if strings.HasPrefix(strings.ToLower(codec), prefix) {
+				audio = append(audio, codec)
+				break
+			}
+		}
+	}
+	return audio
+}
+
+// filterAudio drops the audio renditions not in langs. Groups without any
+// rendition in langs are left untouched so every variant keeps its audio.
+func filterAudio(entries []*playlistEntry, langs []string) {
+	groups := make(map[string][]*playlistEntry)
+	for _, entry := range entries {
+		if entry.tag == "#EXT-X-MEDIA" && entry.attrs["TYPE"] == "AUDIO" {
+			groups[entry.attrs["GROUP-ID"]] = append(groups[entry.attrs["GROUP-ID"]], entry)
+		}
+	}
+
+	for _, renditions := range groups {
+		var matching []*playlistEntry
+		hasDefault := false
+		for _, r := range renditions {
+			if matchesLanguage(r.attrs["LANGUAGE"], langs) {
+				matching = append(matching, r)
+				hasDefault = hasDefault || r.attrs["DEFAULT"] == "YES"
+			}
+		}
+		if len(matching) == 0 {
+			continue
+		}
+
+		for _, r := range renditions {
+			r.keep = false
+		}
+		for _, r := range matching {
+			r.keep = true
+		}
+		if !hasDefault {
+			first := matching[0]
+			if strings.Contains(first.lines[0], "DEFAULT=NO") {
+				first.lines[0] = strings.Replace(first.lines[0], "DEFAULT=NO", "DEFAULT=YES", 1)
+			} else {
+				first.lines[0] += ",DEFAULT=YES"
+			}
+		}
+	}
+}
+
+// matchesLanguage reports whether lang is one of langs, or a regional variant
+// of one ("en-US" matches "en").
+func matchesLanguage(lang string, langs []string) bool {
+	for _, l := range langs {
+		if strings.EqualFold(lang, l) || (len(lang) > len(l) && strings.EqualFold(lang[:len(l)], l) && lang[len(l)] == '-') {
+			return true
+		}
+	}
+	return false
+}
+
+// parseResolution parses "1280x720", or "720"/"720p" as a height.
+func parseResolution(s string) (width, height int) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if w, h, ok := strings.Cut(s, "x"); ok {
+		width, _ = strconv.Atoi(w)
+		height, _ = strconv.Atoi(h)
+		return width, height
+	}
+	height, _ = strconv.Atoi(strings.TrimSuffix(s, "p"))
+	return 0, height
+}
+
+// parseAttributes parses an HLS attribute list, unquoting quoted values.
+func parseAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for s != "" {
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+			_, rest, _ = strings.Cut(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+
+		attrs[strings.TrimSpace(name)] = value
+		s = rest
+	}
+	return attrs
+}
@@ -0,0 +1,180 @@
+package streams
+
+import (
+	"strings"
+	"testing"
+
+	"media-proxy-go/pkg/types"
+)
+
+const testMasterPlaylist = `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",LANGUAGE="en",NAME="English",DEFAULT=YES,URI="audio_en.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",LANGUAGE="de-DE",NAME="Deutsch",DEFAULT=NO,URI="audio_de.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",LANGUAGE="en",NAME="English",URI="subs_en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2",AUDIO="aac",SUBTITLES="subs"
+360p.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2500000,RESOLUTION=1280x720,CODECS="avc1.4d401f,mp4a.40.2",AUDIO="aac",SUBTITLES="subs"
+720p.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=6000000,RESOLUTION=1920x1080,CODECS="avc1.640028,mp4a.40.2",AUDIO="aac",SUBTITLES="subs"
+1080p.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=200000,RESOLUTION=1920x1080,URI="1080p_iframes.m3u8"
+`
+
+func TestFilterMasterPlaylist(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  types.VariantFilter
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "max height",
+			filter:  types.VariantFilter{MaxHeight: 720},
+			want:    []string{"360p.m3u8", "720p.m3u8"},
+			notWant: []string{"\n1080p.m3u8", "1080p_iframes.m3u8"},
+		},
+		{
+			name:    "min bandwidth",
+			filter:  types.VariantFilter{MinBandwidth: 2000000},
+			want:    []string{"720p.m3u8", "1080p.m3u8"},
+			notWant: []string{"360p.m3u8", "1080p_iframes.m3u8"},
+		},
+		{
+			name:    "nothing matches keeps the lowest variant",
+			filter:  types.VariantFilter{MaxHeight: 240},
+			want:    []string{"360p.m3u8"},
+			notWant: []string{"720p.m3u8", "\n1080p.m3u8"},
+		},
+		{
+			name:    "audio language selects regional variant and makes it default",
+			filter:  types.VariantFilter{AudioLangs: []string{"de"}},
+			want:    []string{`LANGUAGE="de-DE",NAME="Deutsch",DEFAULT=YES`, "1080p.m3u8"},
+			notWant: []string{"audio_en.m3u8"},
+		},
+		{
+			name:   "unknown audio language keeps all audio",
+			filter: types.VariantFilter{AudioLangs: []string{"fr"}},
+			want:   []string{"audio_en.m3u8", "audio_de.m3u8"},
+		},
+		{
+			name:    "drop subtitles",
+			filter:  types.VariantFilter{DropSubtitles: true},
+			want:    []string{`CODECS="avc1.4d401e,mp4a.40.2",AUDIO="aac"` + "\n360p.m3u8"},
+			notWant: []string{"subs_en.m3u8", "SUBTITLES="},
+		},
+		{
+			name:    "audio only plays the default rendition",
+			filter:  types.VariantFilter{AudioOnly: true},
+			want:    []string{`#EXT-X-STREAM-INF:BANDWIDTH=192000,CODECS="mp4a.40.2",AUDIO="aac"` + "\naudio_en.m3u8", `URI="audio_de.m3u8"`},
+			notWant: []string{"360p.m3u8", "1080p_iframes.m3u8", "subs_en.m3u8", "RESOLUTION="},
+		},
+		{
+			name:    "audio only with language",
+			filter:  types.VariantFilter{AudioOnly: true, AudioLangs: []string{"de"}},
+			want:    []string{`AUDIO="aac"` + "\naudio_de.m3u8"},
+			notWant: []string{"audio_en.m3u8", "720p.m3u8"},
+		},
+		{
+			name:    "pin best keeps one variant and its renditions",
+			filter:  types.VariantFilter{Pin: types.PinBest},
+			want:    []string{"\n1080p.m3u8", "audio_en.m3u8", "subs_en.m3u8"},
+			notWant: []string{"360p.m3u8", "720p.m3u8", "1080p_iframes.m3u8"},
+		},
+		{
+			name:    "pin worst",
+			filter:  types.VariantFilter{Pin: types.PinWorst},
+			want:    []string{"\n360p.m3u8"},
+			notWant: []string{"720p.m3u8", "\n1080p.m3u8"},
+		},
+		{
+			name:    "pin best within max height",
+			filter:  types.VariantFilter{MaxHeight: 720, Pin: types.PinBest},
+			want:    []string{"720p.m3u8"},
+			notWant: []string{"360p.m3u8", "\n1080p.m3u8"},
+		},
+		{
+			name:    "pin best within max bandwidth",
+			filter:  types.VariantFilter{MaxBandwidth: 3000000, Pin: types.PinBest},
+			want:    []string{"720p.m3u8"},
+			notWant: []string{"360p.m3u8", "\n1080p.m3u8"},
+		},
+		{
+			name:    "pin below every bandwidth keeps the lowest",
+			filter:  types.VariantFilter{MaxBandwidth: 100000, Pin: types.PinBest},
+			want:    []string{"\n360p.m3u8"},
+			notWant: []string{"720p.m3u8", "\n1080p.m3u8"},
+		},
+		{
+			name:    "pin audio only",
+			filter:  types.VariantFilter{AudioOnly: true, Pin: types.PinBest},
+			want:    []string{`AUDIO="aac"` + "\naudio_en.m3u8", `URI="audio_de.m3u8"`},
+			notWant: []string{"360p.m3u8", "subs_en.m3u8"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := string(filterMasterPlaylist([]byte(testMasterPlaylist), &tt.filter))
+			for _, want := range tt.want {
+				if !strings.Contains(result, want) {
+					t.Errorf("result missing %q:\n%s", want, result)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(result, notWant) {
+					t.Errorf("result contains %q:\n%s", notWant, result)
+				}
+			}
+		})
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	attrs := parseAttributes(`BANDWIDTH=800000,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=640x360,AUDIO="aac"`)
+	want := map[string]string{
+		"BANDWIDTH":  "800000",
+		"CODECS":     "avc1.4d401e,mp4a.40.2",
+		"RESOLUTION": "640x360",
+		"AUDIO":      "aac",
+	}
+	for k, v := range want {
+		if attrs[k] != v {
+			t.Errorf("attrs[%q] = %q, want %q", k, attrs[k], v)
+		}
+	}
+}
+
+func TestFilterMasterPlaylist_AudioOnlyMuxed(t *testing.T) {
+	tests := []struct {
+		name     string
+		playlist string
+		want     string
+		notWant  string
+	}{
+		{
+			name: "keeps the audio-only variant",
+			playlist: "#EXTM3U\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=64000,CODECS=\"mp4a.40.5\"\naudio.m3u8\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=2500000,RESOLUTION=1280x720,CODECS=\"avc1.4d401f,mp4a.40.2\"\n720p.m3u8\n",
+			want:    "audio.m3u8",
+			notWant: "720p.m3u8",
+		},
+		{
+			name: "falls back to the lowest variant",
+			playlist: "#EXTM3U\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=2500000,RESOLUTION=1280x720\n720p.m3u8\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\n360p.m3u8\n",
+			want:    "360p.m3u8",
+			notWant: "720p.m3u8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := string(filterMasterPlaylist([]byte(tt.playlist), &types.VariantFilter{AudioOnly: true}))
+			if !strings.Contains(result, tt.want) || strings.Contains(result, tt.notWant) {
+				t.Errorf("result should contain %q but not %q:\n%s", tt.want, tt.notWant, result)
+			}
+		})
+	}
+}
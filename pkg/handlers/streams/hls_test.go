@@ -1,8 +1,12 @@
 package streams
 
 import (
+	"fmt"
 	"net/url"
+	"strings"
 	"testing"
+
+	"media-proxy-go/pkg/logging"
 )
 
 func TestHLSHandler_CanHandle(t *testing.T) {
@@ -118,11 +122,18 @@ func TestHLSHandler_buildProxyURL(t *testing.T) {
 			headers:      map[string]string{"Referer": "https://origin.com"},
 			expectPath:   "/proxy/stream",
 		},
+		{
+			name:         "fMP4 segment uses m4s segment path",
+			targetURL:    "https://example.com/video/seg-1.m4s?token=abc",
+			proxyBaseURL: "https://proxy.com",
+			headers:      nil,
+			expectPath:   "/proxy/hls/segment.m4s",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := h.buildProxyURL(tt.targetURL, tt.proxyBaseURL, tt.headers)
+			result := h.buildProxyURL(tt.targetURL, tt.proxyBaseURL, tt.headers, false)
 			if !contains(result, tt.expectPath) {
 				t.Errorf("buildProxyURL() = %q, expected to contain path %q", result, tt.expectPath)
 			}
@@ -133,6 +144,245 @@ func TestHLSHandler_buildProxyURL(t *testing.T) {
 	}
 }
 
+func TestHLSHandler_rewriteManifest_FMP4(t *testing.T) {
+	h := NewHLSHandler(nil, logging.New("error", false, nil), "https://proxy.com")
+
+	manifest := `#EXTM3U
+#EXT-X-VERSION:9
+#EXT-X-TARGETDURATION:4
+#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=1.0
+#EXT-X-PART-INF:PART-TARGET=0.5
+#EXT-X-MAP:URI="main.mp4",BYTERANGE="720@0"
+#EXTINF:4.000,
+#EXT-X-BYTERANGE:50000@720
+main.mp4
+#EXT-X-PART:DURATION=0.5,URI="main.mp4",BYTERANGE="20000@50720",INDEPENDENT=YES
+#EXT-X-PRELOAD-HINT:TYPE=PART,URI="part-next.m4s"
+#EXT-X-RENDITION-REPORT:URI="../audio/index.m3u8",LAST-MSN=10,LAST-PART=1
+`
+
+	result, err := h.rewriteManifest([]byte(manifest), "https://cdn.example.com/live/video/index.m3u8", "https://proxy.com", nil, false)
+	if err != nil {
+		t.Fatalf("rewriteManifest() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(result)), "\n")
+
+	proxied := url.QueryEscape("https://cdn.example.com/live/video/main.mp4")
+	wantLines := map[int]string{
+		5:  `#EXT-X-MAP:URI="https://proxy.com/proxy/hls/segment.mp4?url=` + proxied + `",BYTERANGE="720@0"`,
+		7:  "#EXT-X-BYTERANGE:50000@720",
+		8:  "https://proxy.com/proxy/hls/segment.mp4?url=" + proxied,
+		9:  `#EXT-X-PART:DURATION=0.5,URI="https://proxy.com/proxy/hls/segment.mp4?url=` + proxied + `",BYTERANGE="20000@50720",INDEPENDENT=YES`,
+		10: `#EXT-X-PRELOAD-HINT:TYPE=PART,URI="https://proxy.com/proxy/hls/segment.m4s?url=` + url.QueryEscape("https://cdn.example.com/live/video/part-next.m4s") + `"`,
+		11: `#EXT-X-RENDITION-REPORT:URI="https://proxy.com/proxy/manifest.m3u8?url=` + url.QueryEscape("https://cdn.example.com/live/audio/index.m3u8") + `",LAST-MSN=10,LAST-PART=1`,
+	}
+	for i, want := range wantLines {
+		if i >= len(lines) || lines[i] != want {
+			t.Errorf("line %d = %q, want %q", i, lines[min(i, len(lines)-1)], want)
+		}
+	}
+}
+
+func TestHLSHandler_rewriteManifest_Keys(t *testing.T) {
+	h := NewHLSHandler(nil, logging.New("error", false, nil), "https://proxy.com")
+
+	manifest := `#EXTM3U
+#EXT-X-KEY:METHOD=AES-128,URI="keys/1.key",IV=0x0123
+#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI="skd://key-id",KEYFORMAT="com.apple.streamingkeydelivery"
+#EXTINF:4.000,
+seg1.ts
+`
+
+	headers := map[string]string{"Referer": "https://example.com/"}
+	result, err := h.rewriteManifest([]byte(manifest), "https://cdn.example.com/live/index.m3u8", "https://proxy.com", headers, false)
+	if err != nil {
+		t.Fatalf("rewriteManifest() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(result)), "\n")
+
+	keyURL := "https://proxy.com/key?h_Referer=" + url.QueryEscape("https://example.com/") + "&url=" + url.QueryEscape("https://cdn.example.com/live/keys/1.key")
+	if want := `#EXT-X-KEY:METHOD=AES-128,URI="` + keyURL + `",IV=0x0123`; lines[1] != want {
+		t.Errorf("key line = %q, want %q", lines[1], want)
+	}
+	if lines[2] != `#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI="skd://key-id",KEYFORMAT="com.apple.streamingkeydelivery"` {
+		t.Errorf("skd key line should be unchanged, got %q", lines[2])
+	}
+}
+
+func TestHLSHandler_rewriteManifest_VOD(t *testing.T) {
+	h := NewHLSHandler(nil, logging.New("error", false, nil), "https://proxy.com")
+
+	manifest := `#EXTM3U
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:4.000,
+seg1.m4s
+#EXT-X-ENDLIST
+`
+
+	result, err := h.rewriteManifest([]byte(manifest), "https://cdn.example.com/vod/index.m3u8", "https://proxy.com", nil, false)
+	if err != nil {
+		t.Fatalf("rewriteManifest() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(result)), "\n")
+
+	if want := `#EXT-X-MAP:URI="https://proxy.com/proxy/hls/segment.mp4?url=` + url.QueryEscape("https://cdn.example.com/vod/init.mp4") + `&vod=1"`; lines[2] != want {
+		t.Errorf("map line = %q, want %q", lines[2], want)
+	}
+	if want := "https://proxy.com/proxy/hls/segment.m4s?url=" + url.QueryEscape("https://cdn.example.com/vod/seg1.m4s") + "&vod=1"; lines[4] != want {
+		t.Errorf("segment line = %q, want %q", lines[4], want)
+	}
+
+	// Live playlists are not marked
+	live := strings.NewReplacer("#EXT-X-PLAYLIST-TYPE:VOD\n", "", "#EXT-X-ENDLIST\n", "").Replace(manifest)
+	result, _ = h.rewriteManifest([]byte(live), "https://cdn.example.com/vod/index.m3u8", "https://proxy.com", nil, false)
+	if strings.Contains(string(result), "vod=1") {
+		t.Errorf("live playlist marked as VOD:\n%s", result)
+	}
+}
+
+func TestWithDirectives(t *testing.T) {
+	directives := url.Values{"_HLS_msn": {"10"}, "_HLS_part": {"2"}}
+	got := withDirectives("https://cdn.example.com/live.m3u8?token=abc", directives)
+	want := "https://cdn.example.com/live.m3u8?_HLS_msn=10&_HLS_part=2&token=abc"
+	if got != want {
+		t.Errorf("withDirectives() = %q, want %q", got, want)
+	}
+	if got := withDirectives("https://cdn.example.com/live.m3u8", nil); got != "https://cdn.example.com/live.m3u8" {
+		t.Errorf("withDirectives() without directives = %q", got)
+	}
+}
+
+func TestStripTwitchAds(t *testing.T) {
+	manifest := `#EXTM3U
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:100
+#EXTINF:2.000,live
+https://video.example/live1.ts
+#EXT-X-DATERANGE:ID="stitched-ad-1",CLASS="twitch-stitched-ad",START-DATE="2024-01-01T00:00:00Z"
+#EXT-X-DISCONTINUITY
+#EXTINF:2.000,Amazon|123
+https://video.example/ad1.ts
+#EXTINF:2.000,Amazon|123
+https://video.example/ad2.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:2.000,live
+https://video.example/live2.ts
+`
+
+	result := string(newTwitchAdState().strip([]byte(manifest)))
+
+	for _, removed := range []string{"ad1.ts", "ad2.ts", "Amazon", "twitch-stitched-ad"} {
+		if contains(result, removed) {
+			t.Errorf("stripTwitchAds() kept %q:\n%s", removed, result)
+		}
+	}
+	for _, kept := range []string{"live1.ts", "live2.ts", "#EXT-X-MEDIA-SEQUENCE:100"} {
+		if !contains(result, kept) {
+			t.Errorf("stripTwitchAds() dropped %q:\n%s", kept, result)
+		}
+	}
+	// The splice is marked once, right before the segment after the ads
+	if n := strings.Count(result, "#EXT-X-DISCONTINUITY"); n != 1 || !contains(result, "#EXT-X-DISCONTINUITY\n#EXTINF:2.000,live\nhttps://video.example/live2.ts") {
+		t.Errorf("stripTwitchAds() discontinuities = %d, want one before live2.ts:\n%s", n, result)
+	}
+
+	// Ads at the start are numbered away and spliced like the others
+	leading := `#EXTM3U
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.000Z
+#EXTINF:2.000,Amazon|123
+https://video.example/ad1.ts
+#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:02.000Z
+#EXTINF:2.000,Amazon|123
+https://video.example/ad2.ts
+#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:04.000Z
+#EXTINF:2.000,live
+https://video.example/live1.ts
+#EXTINF:2.000,live
+https://video.example/live2.ts
+`
+	want := `#EXTM3U
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:04.000Z
+#EXT-X-DISCONTINUITY
+#EXTINF:2.000,live
+https://video.example/live1.ts
+#EXTINF:2.000,live
+https://video.example/live2.ts
+`
+	if got := string(newTwitchAdState().strip([]byte(leading))); got != want {
+		t.Errorf("stripTwitchAds() with leading ads =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHLSHandler_StripTwitchAds_Refreshes(t *testing.T) {
+	h := NewHLSHandler(nil, logging.New("error", false, nil), "")
+	playlist := func(sequence int, segments ...string) string {
+		var b strings.Builder
+		fmt.Fprintf(&b, "#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:%d\n", sequence)
+		for _, segment := range segments {
+			if strings.HasPrefix(segment, "ad") {
+				b.WriteString(`#EXT-X-DATERANGE:ID="stitched-ad",CLASS="twitch-stitched-ad",START-DATE="2024-01-01T00:00:00Z"` + "\n")
+				fmt.Fprintf(&b, "#EXTINF:2.000,Amazon|123\nhttps://video.example/%s.ts\n", segment)
+			} else {
+				fmt.Fprintf(&b, "#EXTINF:2.000,live\nhttps://video.example/%s.ts\n", segment)
+			}
+		}
+		return b.String()
+	}
+	strip := func(manifest string) string {
+		return string(h.stripTwitchAds("https://video.example/index.m3u8", []byte(manifest)))
+	}
+
+	// Each refresh numbers live2 the same, and the splice before it keeps
+	// its discontinuity until it leaves the window
+	refreshes := []struct {
+		playlist string
+		want     string
+	}{
+		{
+			playlist(100, "live1", "ad1", "live2"),
+			"#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:100\n" +
+				"#EXTINF:2.000,live\nhttps://video.example/live1.ts\n" +
+				"#EXT-X-DISCONTINUITY\n#EXTINF:2.000,live\nhttps://video.example/live2.ts\n",
+		},
+		{
+			playlist(101, "ad1", "live2", "live3"),
+			"#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:101\n" +
+				"#EXT-X-DISCONTINUITY\n#EXTINF:2.000,live\nhttps://video.example/live2.ts\n" +
+				"#EXTINF:2.000,live\nhttps://video.example/live3.ts\n",
+		},
+		{
+			playlist(102, "live2", "live3", "live4"),
+			"#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:101\n" +
+				"#EXT-X-DISCONTINUITY\n#EXTINF:2.000,live\nhttps://video.example/live2.ts\n" +
+				"#EXTINF:2.000,live\nhttps://video.example/live3.ts\n" +
+				"#EXTINF:2.000,live\nhttps://video.example/live4.ts\n",
+		},
+		{
+			playlist(103, "live3", "live4", "live5"),
+			"#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:102\n#EXT-X-DISCONTINUITY-SEQUENCE:1\n" +
+				"#EXTINF:2.000,live\nhttps://video.example/live3.ts\n" +
+				"#EXTINF:2.000,live\nhttps://video.example/live4.ts\n" +
+				"#EXTINF:2.000,live\nhttps://video.example/live5.ts\n",
+		},
+	}
+	for i, refresh := range refreshes {
+		if got := strip(refresh.playlist); got != refresh.want {
+			t.Errorf("refresh %d =\n%s\nwant\n%s", i+1, got, refresh.want)
+		}
+	}
+
+	// Streams that never carried ads are left alone
+	plain := playlist(100, "live1", "live2")
+	if got := string(h.stripTwitchAds("https://video.example/other.m3u8", []byte(plain))); got != plain {
+		t.Errorf("playlist without ads =\n%s\nwant it unchanged", got)
+	}
+}
+
 func parseURL(s string) (*url.URL, error) {
 	return url.Parse(s)
 }
@@ -23,15 +23,25 @@ type MPDHandler struct {
 	client  *httpclient.Client
 	log     *logging.Logger
 	baseURL string
+	keys    interfaces.KeyStore // Optional, fills in missing ClearKey keys
+
+	sequences *sequenceTracker   // Media sequence of live playlists across refreshes
+	clock     *clockSync         // Clock offsets of live origins (UTCTiming)
+	locations *manifestLocations // Where live MPDs moved (Location, redirects)
 }
 
 // NewMPDHandler creates a new MPD stream handler.
 func NewMPDHandler(client *httpclient.Client, log *logging.Logger, baseURL string, _ interfaces.Transcoder) *MPDHandler {
-	return &MPDHandler{
+	h := &MPDHandler{
 		client:  client,
 		log:     log.WithComponent("mpd-handler"),
 		baseURL: baseURL,
+
+		sequences: newSequenceTracker(),
+		locations: newManifestLocations(),
 	}
+	h.clock = newClockSync(client, h.log)
+	return h
 }
 
 // Type returns the stream type.
@@ -51,71 +61,116 @@ func (h *MPDHandler) CanHandle(urlStr string) bool {
 func (h *MPDHandler) HandleManifest(ctx context.Context, req *types.StreamRequest, baseURL string) (*types.StreamResponse, error) {
 	h.log.Debug("handling MPD manifest", "url", req.URL)
 
-	// Fetch the original MPD manifest
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	// Fetch the MPD from where it was last relocated to, or from the
+	// original URL if it isn't there anymore
+	fetchURL, resp := req.URL, req.Prefetched
+	if resp == nil {
+		var err error
+		fetchURL = h.locations.refreshURL(req.URL)
+		resp, err = h.fetchManifest(ctx, fetchURL, req)
+		if fetchURL != req.URL && (err != nil || resp.StatusCode >= http.StatusBadRequest) {
+			if err == nil {
+				resp.Body.Close()
+			}
+			h.log.Debug("relocated MPD unavailable, fetching original URL", "url", req.URL, "location", fetchURL)
+			h.locations.forget(req.URL)
+			fetchURL = req.URL
+			resp, err = h.fetchManifest(ctx, fetchURL, req)
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
+	defer resp.Body.Close()
 
-	for key, value := range req.Headers {
-		httpReq.Header.Set(key, value)
+	// Unchanged MPD: skip the conversion entirely
+	if resp.StatusCode == http.StatusNotModified {
+		return notModifiedResponse(resp.Header), nil
 	}
-	if httpReq.Header.Get("User-Agent") == "" {
-		httpReq.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	if resp.StatusCode != http.StatusOK {
+		return &types.StreamResponse{StatusCode: resp.StatusCode}, nil
 	}
 
-	resp, err := h.client.Do(httpReq)
+	body, err := h.client.ReadBody(resp.Body, httpclient.ClassManifest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch MPD: %w", err)
+		return nil, fmt.Errorf("failed to read MPD: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return &types.StreamResponse{StatusCode: resp.StatusCode}, nil
+	mpd, err := h.parseMPD(body)
+	if err != nil {
+		return nil, err
 	}
+	h.locations.set(req.URL, h.relocation(mpd, resp, fetchURL))
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read MPD: %w", err)
+	// Report the content protection and fill in keys from the key store
+	drm := h.drmInfo(mpd)
+	clearKey := h.resolveClearKey(drm, req.ClearKey)
+	if req.InspectDRM {
+		return drmInfoResponse(drm), nil
 	}
 
-	// Check if requesting specific representation (media playlist)
-	if req.RepID != "" {
-		playlist, err := h.convertMediaPlaylist(body, req.RepID, baseURL, req.URL, req.Headers, req.ClearKey)
-		if err != nil {
-			return nil, err
-		}
-		return &types.StreamResponse{
-			ContentType: "application/vnd.apple.mpegurl",
-			Body:        io.NopCloser(bytes.NewReader([]byte(playlist))),
-			StatusCode:  http.StatusOK,
-			Headers: map[string]string{
-				"Cache-Control": "no-cache, no-store, must-revalidate",
-			},
-		}, nil
-	}
-
-	// Generate master playlist
-	playlist, err := h.convertMasterPlaylist(body, baseURL, req.URL, req.Headers, req.ClearKey)
+	// Segment availability of live $Number$ templates follows the origin's clock
+	if req.RepID != "" && strings.ToLower(mpd.Type) == "dynamic" {
+		h.clock.sync(ctx, mpd)
+	}
+
+	// Media playlist for a specific representation, or the master playlist
+	var playlist string
+	switch {
+	case req.RepID != "" && req.IFrames:
+		playlist, err = h.convertIFramePlaylist(body, req.RepID, baseURL, req.URL, req.Headers, clearKey)
+	case req.RepID != "":
+		playlist, err = h.convertMediaPlaylist(body, req.RepID, req.AudioRepID, baseURL, req.URL, req.Headers, clearKey)
+	case req.Filter != nil && req.Filter.AudioOnly:
+		playlist, err = h.convertAudioMasterPlaylist(body, baseURL, req.URL, req.Headers, clearKey, req.Filter)
+	case req.Muxed:
+		playlist, err = h.convertMuxedMasterPlaylist(body, baseURL, req.URL, req.Headers, clearKey, req.Filter)
+	default:
+		playlist, err = h.convertMasterPlaylist(body, baseURL, req.URL, req.Headers, clearKey, req.Filter)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	headers := withForwarded(h.client, resp.Header, manifestHeaders(resp.Header))
+	headers["Content-Length"] = strconv.Itoa(len(playlist))
+	drmHeaders(headers, drm)
+
 	return &types.StreamResponse{
 		ContentType: "application/vnd.apple.mpegurl",
 		Body:        io.NopCloser(bytes.NewReader([]byte(playlist))),
 		StatusCode:  http.StatusOK,
-		Headers: map[string]string{
-			"Cache-Control": "no-cache, no-store, must-revalidate",
-		},
+		Headers:     headers,
 	}, nil
 }
 
+// fetchManifest requests the MPD of req from urlStr.
+func (h *MPDHandler) fetchManifest(ctx context.Context, urlStr string, req *types.StreamRequest) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for key, value := range req.Headers {
+		httpReq.Header.Set(key, value)
+	}
+	if httpReq.Header.Get("Accept-Encoding") == "" {
+		httpReq.Header.Set("Accept-Encoding", httpclient.AcceptEncoding)
+	}
+	applyValidators(httpReq, req.Validators)
+
+	resp, err := h.client.DoClass(httpReq, httpclient.ClassManifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch MPD: %w", err)
+	}
+	return resp, nil
+}
+
 // HandleSegment proxies an MPD segment.
 func (h *MPDHandler) HandleSegment(ctx context.Context, req *types.StreamRequest) (*types.StreamResponse, error) {
 	h.log.Debug("handling MPD segment", "url", req.URL)
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
+	httpReq, err := http.NewRequestWithContext(segmentContext(ctx, h.client, req), http.MethodGet, req.URL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -128,6 +183,9 @@ func (h *MPDHandler) HandleSegment(ctx context.Context, req *types.StreamRequest
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch segment: %w", err)
 	}
+	if redirect := redirectResponse(resp); redirect != nil {
+		return redirect, nil
+	}
 
 	contentType := resp.Header.Get("Content-Type")
 	if contentType == "" {
@@ -142,11 +200,12 @@ func (h *MPDHandler) HandleSegment(ctx context.Context, req *types.StreamRequest
 		ContentType: contentType,
 		Body:        resp.Body,
 		StatusCode:  resp.StatusCode,
+		Headers:     withForwarded(h.client, resp.Header, transferHeaders(resp)),
 	}, nil
 }
 
 // convertMasterPlaylist generates an HLS master playlist from MPD.
-func (h *MPDHandler) convertMasterPlaylist(manifest []byte, proxyBaseURL, originalURL string, headers map[string]string, clearKey string) (string, error) {
+func (h *MPDHandler) convertMasterPlaylist(manifest []byte, proxyBaseURL, originalURL string, headers map[string]string, clearKey string, filter *types.VariantFilter) (string, error) {
 	mpd, err := h.parseMPD(manifest)
 	if err != nil {
 		return "", err
@@ -158,35 +217,30 @@ func (h *MPDHandler) convertMasterPlaylist(manifest []byte, proxyBaseURL, origin
 	audioGroupID := "audio"
 	hasAudio := false
 
-	// Process audio tracks
-	for _, period := range mpd.Periods {
-		for _, as := range period.AdaptationSets {
-			if !h.isAudio(as) {
-				continue
-			}
-			for _, rep := range as.Representations {
-				mediaURL := h.buildMediaPlaylistURL(proxyBaseURL, originalURL, rep.ID, headers, clearKey)
-				lang := as.Lang
-				if lang == "" {
-					lang = "und"
-				}
-				name := fmt.Sprintf("Audio %s (%s)", lang, rep.Bandwidth)
-
-				defaultAttr := "NO"
-				if !hasAudio {
-					defaultAttr = "YES"
-				}
+	// Process audio tracks, the preferred one as default
+	for _, track := range h.selectAudio(mpd, filter) {
+		rep := track.rep
+		mediaURL := h.buildMediaPlaylistURL(proxyBaseURL, originalURL, rep.ID, headers, clearKey)
+		lang := track.set.Lang
+		if lang == "" {
+			lang = "und"
+		}
+		name := fmt.Sprintf("Audio %s (%s)", lang, rep.Bandwidth)
 
-				lines = append(lines, fmt.Sprintf(
-					`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="%s",NAME="%s",LANGUAGE="%s",DEFAULT=%s,AUTOSELECT=YES,URI="%s"`,
-					audioGroupID, name, lang, defaultAttr, mediaURL,
-				))
-				hasAudio = true
-			}
+		defaultAttr := "NO"
+		if !hasAudio {
+			defaultAttr = "YES"
 		}
+
+		lines = append(lines, fmt.Sprintf(
+			`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="%s",NAME="%s",LANGUAGE="%s",DEFAULT=%s,AUTOSELECT=YES,URI="%s"`,
+			audioGroupID, name, lang, defaultAttr, mediaURL,
+		))
+		hasAudio = true
 	}
 
 	// Find max video height for quality filtering
+	keepVideo := h.castVideoFilter(mpd, filter)
 	maxHeight := 0
 	for _, period := range mpd.Periods {
 		for _, as := range period.AdaptationSets {
@@ -194,6 +248,9 @@ func (h *MPDHandler) convertMasterPlaylist(manifest []byte, proxyBaseURL, origin
 				continue
 			}
 			for _, rep := range as.Representations {
+				if !keepVideo(&as, &rep) {
+					continue
+				}
 				if rep.Height > maxHeight {
 					maxHeight = rep.Height
 				}
@@ -209,7 +266,7 @@ func (h *MPDHandler) convertMasterPlaylist(manifest []byte, proxyBaseURL, origin
 			}
 			for _, rep := range as.Representations {
 				// Filter to highest quality only
-				if rep.Height < maxHeight {
+				if rep.Height < maxHeight || !keepVideo(&as, &rep) {
 					continue
 				}
 
@@ -234,31 +291,148 @@ func (h *MPDHandler) convertMasterPlaylist(manifest []byte, proxyBaseURL, origin
 		}
 	}
 
+	// I-frame streams for scrubbing previews and fast seeking, which
+	// Chromecast doesn't use
+	if !forChromecast(filter) {
+		lines = append(lines, h.iframeStreams(mpd, proxyBaseURL, originalURL, headers, clearKey)...)
+	}
+
 	return strings.Join(lines, "\n"), nil
 }
 
-// convertMediaPlaylist generates an HLS media playlist for a specific representation.
-func (h *MPDHandler) convertMediaPlaylist(manifest []byte, repID, proxyBaseURL, originalURL string, headers map[string]string, clearKey string) (string, error) {
+// convertMuxedMasterPlaylist generates an HLS master playlist with a single
+// variant muxing the best video and the preferred audio track, for players
+// that don't support EXT-X-MEDIA audio groups.
+func (h *MPDHandler) convertMuxedMasterPlaylist(manifest []byte, proxyBaseURL, originalURL string, headers map[string]string, clearKey string, filter *types.VariantFilter) (string, error) {
 	mpd, err := h.parseMPD(manifest)
 	if err != nil {
 		return "", err
 	}
 
-	// Find the representation
-	var rep *Representation
-	var as *AdaptationSet
+	var video *Representation
+	keepVideo := h.castVideoFilter(mpd, filter)
+	for _, period := range mpd.Periods {
+		for i := range period.AdaptationSets {
+			as := &period.AdaptationSets[i]
+			if !h.isVideo(*as) {
+				continue
+			}
+			for j := range as.Representations {
+				rep := &as.Representations[j]
+				if !keepVideo(as, rep) {
+					continue
+				}
+				if video == nil || rep.Height > video.Height ||
+					(rep.Height == video.Height && atoiOrZero(rep.Bandwidth) > atoiOrZero(video.Bandwidth)) {
+					video = rep
+				}
+			}
+		}
+	}
+
+	// Preferred audio track, best quality
+	var audio *Representation
+	if tracks := h.selectAudio(mpd, filter); len(tracks) > 0 {
+		audio = tracks[0].rep
+		for _, t := range tracks {
+			if t.set == tracks[0].set && atoiOrZero(t.rep.Bandwidth) > atoiOrZero(audio.Bandwidth) {
+				audio = t.rep
+			}
+		}
+	}
+
+	main, muxedAudio := video, audio
+	if main == nil {
+		main, muxedAudio = audio, nil
+	}
+	if main == nil {
+		return "", fmt.Errorf("no video or audio representation in MPD")
+	}
+
+	mediaURL := h.buildMediaPlaylistURL(proxyBaseURL, originalURL, main.ID, headers, clearKey)
+	bandwidth := atoiOrZero(main.Bandwidth)
+	codecs := main.Codecs
+	if muxedAudio != nil {
+		mediaURL += "&audio_rep_id=" + url.QueryEscape(muxedAudio.ID)
+		bandwidth += atoiOrZero(muxedAudio.Bandwidth)
+		if muxedAudio.Codecs != "" && codecs != "" {
+			codecs += "," + muxedAudio.Codecs
+		}
+	}
+
+	inf := fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d", bandwidth)
+	if main.Width > 0 && main.Height > 0 {
+		inf += fmt.Sprintf(",RESOLUTION=%dx%d", main.Width, main.Height)
+	}
+	if codecs != "" {
+		inf += fmt.Sprintf(",CODECS=\"%s\"", codecs)
+	}
+
+	return strings.Join([]string{"#EXTM3U", "#EXT-X-VERSION:3", inf, mediaURL}, "\n"), nil
+}
+
+// convertAudioMasterPlaylist generates an HLS master playlist whose variants
+// are the MPD's audio representations, for radio and background listening.
+// The filter's audio languages and codecs narrow the list (see selectAudio).
+func (h *MPDHandler) convertAudioMasterPlaylist(manifest []byte, proxyBaseURL, originalURL string, headers map[string]string, clearKey string, filter *types.VariantFilter) (string, error) {
+	mpd, err := h.parseMPD(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, track := range h.selectAudio(mpd, filter) {
+		rep := track.rep
+		inf := fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%s", rep.Bandwidth)
+		if codecs := track.codecs(); codecs != "" {
+			inf += fmt.Sprintf(",CODECS=\"%s\"", codecs)
+		}
+		mediaURL := h.buildMediaPlaylistURL(proxyBaseURL, originalURL, rep.ID, headers, clearKey)
+		lines = append(lines, inf, mediaURL)
+	}
+	if len(lines) == 0 {
+		return "", fmt.Errorf("no audio representation in MPD")
+	}
+
+	return strings.Join(append([]string{"#EXTM3U", "#EXT-X-VERSION:3"}, lines...), "\n"), nil
+}
+
+// findRepresentation returns the representation with the given ID and its adaptation set.
+func (h *MPDHandler) findRepresentation(mpd *MPD, repID string) (*Representation, *AdaptationSet) {
 	for _, period := range mpd.Periods {
 		for i := range period.AdaptationSets {
 			for j := range period.AdaptationSets[i].Representations {
 				if period.AdaptationSets[i].Representations[j].ID == repID {
-					rep = &period.AdaptationSets[i].Representations[j]
-					as = &period.AdaptationSets[i]
-					break
+					return &period.AdaptationSets[i].Representations[j], &period.AdaptationSets[i]
 				}
 			}
 		}
 	}
+	return nil, nil
+}
 
+// convertMediaPlaylist generates an HLS media playlist for a specific representation.
+// If audioRepID is set, each segment is muxed with the audio segments starting during it.
+func (h *MPDHandler) convertMediaPlaylist(manifest []byte, repID, audioRepID, proxyBaseURL, originalURL string, headers map[string]string, clearKey string) (string, error) {
+	return h.mediaPlaylist(manifest, repID, audioRepID, false, proxyBaseURL, originalURL, headers, clearKey)
+}
+
+// convertIFramePlaylist generates an HLS I-frame playlist for a video
+// representation: the segments of a trick-mode representation, or the
+// keyframe each segment of a regular one starts with.
+func (h *MPDHandler) convertIFramePlaylist(manifest []byte, repID, proxyBaseURL, originalURL string, headers map[string]string, clearKey string) (string, error) {
+	return h.mediaPlaylist(manifest, repID, "", true, proxyBaseURL, originalURL, headers, clearKey)
+}
+
+// mediaPlaylist generates the media or I-frame playlist of a representation.
+func (h *MPDHandler) mediaPlaylist(manifest []byte, repID, audioRepID string, iframes bool, proxyBaseURL, originalURL string, headers map[string]string, clearKey string) (string, error) {
+	mpd, err := h.parseMPD(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	// Find the representation
+	rep, as := h.findRepresentation(mpd, repID)
 	if rep == nil {
 		return "#EXTM3U\n#EXT-X-ERROR: Representation not found", nil
 	}
@@ -266,11 +440,13 @@ func (h *MPDHandler) convertMediaPlaylist(manifest []byte, repID, proxyBaseURL,
 	isLive := strings.ToLower(mpd.Type) == "dynamic"
 
 	var lines []string
-	lines = append(lines, "#EXTM3U", "#EXT-X-VERSION:3")
-
-	if isLive {
-		lines = append(lines, "#EXT-X-START:TIME-OFFSET=-30.0,PRECISE=NO")
+	if iframes {
+		lines = append(lines, "#EXTM3U", "#EXT-X-VERSION:4", "#EXT-X-I-FRAMES-ONLY")
 	} else {
+		lines = append(lines, "#EXTM3U", "#EXT-X-VERSION:3")
+	}
+
+	if !isLive {
 		lines = append(lines, "#EXT-X-TARGETDURATION:10", "#EXT-X-PLAYLIST-TYPE:VOD")
 	}
 
@@ -295,14 +471,21 @@ func (h *MPDHandler) convertMediaPlaylist(manifest []byte, repID, proxyBaseURL,
 	}
 
 	// Resolve base URL
-	baseURL := h.getBaseURL(mpd, originalURL)
+	baseURL := h.getBaseURL(mpd, h.locations.baseURL(originalURL))
 
 	// Build segments from timeline
-	segments := h.buildSegmentsFromTimeline(st, repID, rep.Bandwidth, timescale, startNumber)
+	segments := h.buildSegments(mpd, as, st, repID, rep.Bandwidth, timescale, startNumber)
 
-	// For live: sliding window of last 20 segments
-	if isLive && len(segments) > 20 {
-		segments = segments[len(segments)-20:]
+	// For live: sliding window over the origin's time shift buffer, numbered
+	// consistently with the previous refreshes
+	var mediaSeq int64
+	if isLive {
+		segments = liveWindow(mpd, segments)
+		if len(segments) > 0 && segments[0].DurationTS > 0 {
+			mediaSeq = segments[0].Time / int64(segments[0].DurationTS)
+		}
+		segments, mediaSeq = h.sequences.assign(originalURL+"#"+repID, segments, mediaSeq)
+		lines = append(lines, fmt.Sprintf("#EXT-X-START:TIME-OFFSET=-%.1f,PRECISE=NO", liveStartOffset(mpd, segments)))
 	}
 
 	if len(segments) > 0 {
@@ -315,8 +498,6 @@ func (h *MPDHandler) convertMediaPlaylist(manifest []byte, repID, proxyBaseURL,
 		}
 
 		if isLive {
-			// Calculate media sequence from first segment time
-			mediaSeq := segments[0].Time / int64(segments[0].DurationTS)
 			lines = append(lines, fmt.Sprintf("#EXT-X-TARGETDURATION:%d", int(maxDur)+1))
 			lines = append(lines, fmt.Sprintf("#EXT-X-MEDIA-SEQUENCE:%d", mediaSeq))
 		}
@@ -332,6 +513,14 @@ func (h *MPDHandler) convertMediaPlaylist(manifest []byte, repID, proxyBaseURL,
 		initURL = h.resolveURL(initPath, baseURL)
 	}
 
+	// Audio track muxed into each segment (muxed mode)
+	var audio *muxedAudioTrack
+	if audioRepID != "" {
+		if audio = h.muxedAudio(mpd, audioRepID, baseURL); audio == nil {
+			h.log.Warn("muxed audio representation not found, serving video only", "audio_rep_id", audioRepID)
+		}
+	}
+
 	// Add segments
 	for _, seg := range segments {
 		lines = append(lines, fmt.Sprintf("#EXTINF:%.3f,", seg.Duration))
@@ -341,6 +530,13 @@ func (h *MPDHandler) convertMediaPlaylist(manifest []byte, repID, proxyBaseURL,
 		if useDecrypt {
 			// Use decrypt endpoint for TS output
 			proxyURL := h.buildDecryptURL(proxyBaseURL, segURL, initURL, headers, clearKey)
+			if audio != nil {
+				start := float64(seg.Time) / float64(timescale)
+				proxyURL = audio.addTo(proxyURL, start, start+seg.Duration)
+			}
+			if iframes && !as.isTrickMode() {
+				proxyURL += "&keyframe=1"
+			}
 			lines = append(lines, proxyURL)
 		} else {
 			// Direct segment proxy
@@ -356,6 +552,79 @@ func (h *MPDHandler) convertMediaPlaylist(manifest []byte, repID, proxyBaseURL,
 	return strings.Join(lines, "\n"), nil
 }
 
+// muxedAudioTrack is an audio representation muxed into video segments.
+type muxedAudioTrack struct {
+	initURL  string
+	segments []segment // URLs resolved
+	starts   []float64 // Segment start times in seconds
+}
+
+// muxedAudio builds the audio track for repID, or returns nil if it has no segments.
+func (h *MPDHandler) muxedAudio(mpd *MPD, repID, baseURL string) *muxedAudioTrack {
+	rep, as := h.findRepresentation(mpd, repID)
+	if rep == nil {
+		return nil
+	}
+	st := rep.SegmentTemplate
+	if st == nil {
+		st = as.SegmentTemplate
+	}
+	if st == nil {
+		return nil
+	}
+
+	timescale := 1
+	if st.Timescale != "" {
+		timescale, _ = strconv.Atoi(st.Timescale)
+	}
+	startNumber := 1
+	if st.StartNumber != "" {
+		startNumber, _ = strconv.Atoi(st.StartNumber)
+	}
+
+	track := &muxedAudioTrack{}
+	if st.Initialization != "" {
+		track.initURL = h.resolveURL(h.replaceTemplateVars(st.Initialization, repID, rep.Bandwidth, 0, 0), baseURL)
+	}
+	for _, seg := range h.buildSegments(mpd, as, st, repID, rep.Bandwidth, timescale, startNumber) {
+		seg.URL = h.resolveURL(seg.URL, baseURL)
+		track.segments = append(track.segments, seg)
+		track.starts = append(track.starts, float64(seg.Time)/float64(timescale))
+	}
+	if len(track.segments) == 0 {
+		return nil
+	}
+	return track
+}
+
+// addTo adds the audio segments starting in [start, end) to a decrypt URL.
+func (t *muxedAudioTrack) addTo(decryptURL string, start, end float64) string {
+	u, err := url.Parse(decryptURL)
+	if err != nil {
+		return decryptURL
+	}
+	q := u.Query()
+	for i, seg := range t.segments {
+		if t.starts[i] >= start && t.starts[i] < end {
+			q.Add("audio_url", seg.URL)
+		}
+	}
+	if !q.Has("audio_url") {
+		return decryptURL
+	}
+	if t.initURL != "" {
+		q.Set("audio_init_url", t.initURL)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// atoiOrZero parses a decimal attribute, returning 0 if it is invalid.
+func atoiOrZero(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
 type segment struct {
 	URL        string
 	Duration   float64
@@ -364,6 +633,27 @@ type segment struct {
 	Number     int
 }
 
+// buildSegments lists the segments of a representation of adaptation set as,
+// from its SegmentTimeline or its $Number$ template.
+func (h *MPDHandler) buildSegments(mpd *MPD, as *AdaptationSet, st *SegmentTemplate, repID, bandwidth string, timescale, startNumber int) []segment {
+	if st.SegmentTimeline == nil && st.Duration != "" {
+		return h.buildSegmentsFromNumber(mpd, periodOf(mpd, as), st, repID, bandwidth, timescale, startNumber)
+	}
+	return h.buildSegmentsFromTimeline(st, repID, bandwidth, timescale, startNumber)
+}
+
+// periodOf returns the period of an adaptation set of mpd.
+func periodOf(mpd *MPD, as *AdaptationSet) *Period {
+	for i := range mpd.Periods {
+		for j := range mpd.Periods[i].AdaptationSets {
+			if &mpd.Periods[i].AdaptationSets[j] == as {
+				return &mpd.Periods[i]
+			}
+		}
+	}
+	return &Period{}
+}
+
 func (h *MPDHandler) buildSegmentsFromTimeline(st *SegmentTemplate, repID, bandwidth string, timescale, startNumber int) []segment {
 	var segments []segment
 
@@ -418,29 +708,35 @@ func (h *MPDHandler) replaceTemplateVars(template, repID, bandwidth string, numb
 }
 
 func (h *MPDHandler) getBaseURL(mpd *MPD, originalURL string) string {
-	if len(mpd.BaseURLs) > 0 && mpd.BaseURLs[0] != "" {
-		return mpd.BaseURLs[0]
-	}
 	// Use directory of original URL
 	// Important: use string manipulation to preserve original URL encoding
 	// (Go's url.Parse + Path modification + String() re-encodes special chars)
-	queryIdx := strings.Index(originalURL, "?")
-	if queryIdx > 0 {
-		originalURL = originalURL[:queryIdx]
+	dir := originalURL
+	if queryIdx := strings.Index(dir, "?"); queryIdx > 0 {
+		dir = dir[:queryIdx]
 	}
-	lastSlash := strings.LastIndex(originalURL, "/")
-	if lastSlash > 0 {
-		return originalURL[:lastSlash+1]
+	if lastSlash := strings.LastIndex(dir, "/"); lastSlash > 0 {
+		dir = dir[:lastSlash+1]
 	}
-	return originalURL
+
+	// A relative BaseURL is relative to the MPD
+	if len(mpd.BaseURLs) > 0 && mpd.BaseURLs[0] != "" {
+		if strings.Contains(mpd.BaseURLs[0], "://") {
+			return mpd.BaseURLs[0]
+		}
+		return h.resolveURL(mpd.BaseURLs[0], dir)
+	}
+	return dir
 }
 
 func (h *MPDHandler) resolveURL(urlStr string, base string) string {
 	return urlutil.ResolveURL(urlStr, base)
 }
 
+// isVideo reports whether as is a regular video adaptation set; trick-mode
+// sets are only listed as I-frame streams.
 func (h *MPDHandler) isVideo(as AdaptationSet) bool {
-	return strings.Contains(as.MimeType, "video") || strings.Contains(as.ContentType, "video")
+	return (strings.Contains(as.MimeType, "video") || strings.Contains(as.ContentType, "video")) && !as.isTrickMode()
 }
 
 func (h *MPDHandler) isAudio(as AdaptationSet) bool {
@@ -530,32 +826,59 @@ func (h *MPDHandler) parseMPD(data []byte) (*MPD, error) {
 
 // MPD XML structures
 type MPD struct {
-	XMLName  xml.Name `xml:"MPD"`
-	Type     string   `xml:"type,attr"`
-	BaseURLs []string `xml:"BaseURL"`
-	Periods  []Period `xml:"Period"`
+	XMLName                    xml.Name    `xml:"MPD"`
+	Type                       string      `xml:"type,attr"`
+	AvailabilityStartTime      string      `xml:"availabilityStartTime,attr"`
+	MediaPresentationDuration  string      `xml:"mediaPresentationDuration,attr"`
+	TimeShiftBufferDepth       string      `xml:"timeShiftBufferDepth,attr"`
+	SuggestedPresentationDelay string      `xml:"suggestedPresentationDelay,attr"`
+	BaseURLs                   []string    `xml:"BaseURL"`
+	Locations                  []string    `xml:"Location"`
+	UTCTimings                 []UTCTiming `xml:"UTCTiming"`
+	Periods                    []Period    `xml:"Period"`
 }
 
 type Period struct {
+	Start          string          `xml:"start,attr"`
 	AdaptationSets []AdaptationSet `xml:"AdaptationSet"`
 }
 
 type AdaptationSet struct {
-	MimeType        string           `xml:"mimeType,attr"`
-	ContentType     string           `xml:"contentType,attr"`
-	Lang            string           `xml:"lang,attr"`
-	SegmentTemplate *SegmentTemplate `xml:"SegmentTemplate"`
-	Representations []Representation `xml:"Representation"`
+	MimeType           string              `xml:"mimeType,attr"`
+	ContentType        string              `xml:"contentType,attr"`
+	Lang               string              `xml:"lang,attr"`
+	Codecs             string              `xml:"codecs,attr"`
+	ID                 string              `xml:"id,attr"`
+	Roles              []Descriptor        `xml:"Role"`
+	EssentialProps     []Descriptor        `xml:"EssentialProperty"`
+	SupplementalProps  []Descriptor        `xml:"SupplementalProperty"`
+	ContentProtections []ContentProtection `xml:"ContentProtection"`
+	SegmentTemplate    *SegmentTemplate    `xml:"SegmentTemplate"`
+	Representations    []Representation    `xml:"Representation"`
 }
 
 type Representation struct {
-	ID              string           `xml:"id,attr"`
-	Bandwidth       string           `xml:"bandwidth,attr"`
-	Width           int              `xml:"width,attr"`
-	Height          int              `xml:"height,attr"`
-	FrameRate       string           `xml:"frameRate,attr"`
-	Codecs          string           `xml:"codecs,attr"`
-	SegmentTemplate *SegmentTemplate `xml:"SegmentTemplate"`
+	ID                 string              `xml:"id,attr"`
+	Bandwidth          string              `xml:"bandwidth,attr"`
+	Width              int                 `xml:"width,attr"`
+	Height             int                 `xml:"height,attr"`
+	FrameRate          string              `xml:"frameRate,attr"`
+	Codecs             string              `xml:"codecs,attr"`
+	ContentProtections []ContentProtection `xml:"ContentProtection"`
+	SegmentTemplate    *SegmentTemplate    `xml:"SegmentTemplate"`
+}
+
+// Descriptor is a DASH descriptor element: Role, EssentialProperty...
+type Descriptor struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr"`
+}
+
+type ContentProtection struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	DefaultKID  string `xml:"default_KID,attr"` // cenc:default_KID
+	PSSH        string `xml:"pssh"`             // cenc:pssh, base64
+	PRO         string `xml:"pro"`              // mspr:pro, a base64 PlayReady Object
 }
 
 type SegmentTemplate struct {
@@ -563,6 +886,7 @@ type SegmentTemplate struct {
 	Initialization  string           `xml:"initialization,attr"`
 	Media           string           `xml:"media,attr"`
 	StartNumber     string           `xml:"startNumber,attr"`
+	Duration        string           `xml:"duration,attr"` // Segment duration without SegmentTimeline
 	SegmentTimeline *SegmentTimeline `xml:"SegmentTimeline"`
 }
 
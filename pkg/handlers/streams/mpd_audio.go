@@ -0,0 +1,135 @@
+package streams
+
+import (
+	"sort"
+	"strings"
+
+	"media-proxy-go/pkg/types"
+)
+
+// audioCodecAliases maps the codec names accepted by audio_codec to the
+// prefixes of their CODECS strings.
+var audioCodecAliases = map[string]string{
+	"aac":  "mp4a",
+	"ac3":  "ac-3",
+	"eac3": "ec-3",
+	"ec3":  "ec-3",
+	"dd":   "ac-3",
+	"ddp":  "ec-3",
+}
+
+// audioTrack is an audio representation of an MPD with its adaptation set.
+type audioTrack struct {
+	rep *Representation
+	set *AdaptationSet
+}
+
+// codecs returns the representation's codecs, inherited from the adaptation
+// set when the representation doesn't list them.
+func (t audioTrack) codecs() string {
+	if t.rep.Codecs != "" {
+		return t.rep.Codecs
+	}
+	return t.set.Codecs
+}
+
+// selectAudio returns the audio representations of mpd offered to players,
+// the default one first. filter.AudioLangs and filter.AudioCodecs narrow the
+// list, each ignored when nothing matches so the stream keeps its audio. The
+// rest is ordered by the preferred languages, E-AC-3 first with
+// filter.PreferEC3, then tracks with the main role, then MPD order.
+func (h *MPDHandler) selectAudio(mpd *MPD, filter *types.VariantFilter) []audioTrack {
+	var tracks []audioTrack
+	for _, period := range mpd.Periods {
+		for i := range period.AdaptationSets {
+			as := &period.AdaptationSets[i]
+			if !h.isAudio(*as) {
+				continue
+			}
+			for j := range as.Representations {
+				tracks = append(tracks, audioTrack{rep: &as.Representations[j], set: as})
+			}
+		}
+	}
+	if filter == nil || len(tracks) == 0 {
+		return tracks
+	}
+
+	if len(filter.AudioLangs) > 0 {
+		tracks = keepAudio(tracks, func(t audioTrack) bool { return matchesLanguage(t.set.Lang, filter.AudioLangs) })
+	}
+	if len(filter.AudioCodecs) > 0 {
+		tracks = keepAudio(tracks, func(t audioTrack) bool { return matchesAudioCodec(t.codecs(), filter.AudioCodecs) })
+	}
+
+	rank := func(t audioTrack) (lang, codec, role int) {
+		lang = len(filter.AudioLangs)
+		for i, l := range filter.AudioLangs {
+			if matchesLanguage(t.set.Lang, []string{l}) {
+				lang = i
+				break
+			}
+		}
+		if filter.PreferEC3 && !matchesAudioCodec(t.codecs(), []string{"ec-3"}) {
+			codec = 1
+		}
+		if !t.set.hasRole("main") {
+			role = 1
+		}
+		return lang, codec, role
+	}
+	sort.SliceStable(tracks, func(i, j int) bool {
+		li, ci, ri := rank(tracks[i])
+		lj, cj, rj := rank(tracks[j])
+		if li != lj {
+			return li < lj
+		}
+		if ci != cj {
+			return ci < cj
+		}
+		return ri < rj
+	})
+	return tracks
+}
+
+// keepAudio returns the tracks matching keep, or all of them if none does.
+func keepAudio(tracks []audioTrack, keep func(audioTrack) bool) []audioTrack {
+	var kept []audioTrack
+	for _, t := range tracks {
+		if keep(t) {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == 0 {
+		return tracks
+	}
+	return kept
+}
+
+// matchesAudioCodec reports whether a CODECS string contains one of codecs,
+// given as CODECS prefixes ("mp4a.40.2", "ec-3") or names ("aac", "eac3").
+func matchesAudioCodec(codecs string, wanted []string) bool {
+	for _, codec := range strings.Split(strings.ToLower(codecs), ",") {
+		codec = strings.TrimSpace(codec)
+		for _, w := range wanted {
+			w = strings.ToLower(strings.TrimSpace(w))
+			if alias, ok := audioCodecAliases[w]; ok {
+				w = alias
+			}
+			if w != "" && strings.HasPrefix(codec, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasRole reports whether the adaptation set has a DASH role, e.g. "main".
+func (as *AdaptationSet) hasRole(value string) bool {
+	for _, role := range as.Roles {
+		if strings.EqualFold(role.Value, value) {
+			return true
+		}
+	}
+	return false
+}
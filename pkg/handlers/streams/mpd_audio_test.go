@@ -0,0 +1,88 @@
+package streams
+
+import (
+	"strings"
+	"testing"
+
+	"media-proxy-go/pkg/types"
+)
+
+const audioTestMPD = `<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static">
+  <Period>
+    <AdaptationSet mimeType="video/mp4">
+      <Representation id="v1080" bandwidth="6000000" width="1920" height="1080" codecs="avc1.640028"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="audio/mp4" lang="fr" codecs="mp4a.40.2">
+      <Representation id="fr-aac" bandwidth="128000"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="audio/mp4" lang="en" codecs="mp4a.40.2">
+      <Role schemeIdUri="urn:mpeg:dash:role:2011" value="alternate"/>
+      <Representation id="en-aac" bandwidth="128000"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="audio/mp4" lang="en" codecs="ec-3">
+      <Role schemeIdUri="urn:mpeg:dash:role:2011" value="main"/>
+      <Representation id="en-ec3" bandwidth="384000"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+func TestMPDHandler_selectAudio(t *testing.T) {
+	h := &MPDHandler{}
+	mpd, err := h.parseMPD([]byte(audioTestMPD))
+	if err != nil {
+		t.Fatalf("parseMPD() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter *types.VariantFilter
+		want   []string
+	}{
+		{"no filter", nil, []string{"fr-aac", "en-aac", "en-ec3"}},
+		{"empty filter ranks main role", &types.VariantFilter{}, []string{"en-ec3", "fr-aac", "en-aac"}},
+		{"language", &types.VariantFilter{AudioLangs: []string{"en"}}, []string{"en-ec3", "en-aac"}},
+		{"language order", &types.VariantFilter{AudioLangs: []string{"fr", "en"}}, []string{"fr-aac", "en-ec3", "en-aac"}},
+		{"codec alias", &types.VariantFilter{AudioCodecs: []string{"aac"}}, []string{"fr-aac", "en-aac"}},
+		{"codec prefix", &types.VariantFilter{AudioCodecs: []string{"EC-3"}}, []string{"en-ec3"}},
+		{"unmatched codec keeps all", &types.VariantFilter{AudioCodecs: []string{"opus"}}, []string{"en-ec3", "fr-aac", "en-aac"}},
+		{"unmatched language keeps all", &types.VariantFilter{AudioLangs: []string{"ja"}}, []string{"en-ec3", "fr-aac", "en-aac"}},
+		{"prefer ec3", &types.VariantFilter{AudioLangs: []string{"fr", "en"}, PreferEC3: true}, []string{"fr-aac", "en-ec3", "en-aac"}},
+		{"prefer ec3 within language", &types.VariantFilter{AudioCodecs: []string{"aac", "eac3"}, PreferEC3: true}, []string{"en-ec3", "fr-aac", "en-aac"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, track := range h.selectAudio(mpd, tt.filter) {
+				got = append(got, track.rep.ID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("selectAudio() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMPDHandler_convertMasterPlaylist_AudioDefault(t *testing.T) {
+	h := &MPDHandler{}
+
+	filter := &types.VariantFilter{AudioLangs: []string{"en"}, AudioCodecs: []string{"aac"}}
+	playlist, err := h.convertMasterPlaylist([]byte(audioTestMPD), "http://proxy", "https://cdn.example.com/manifest.mpd", nil, "", filter)
+	if err != nil {
+		t.Fatalf("convertMasterPlaylist() error = %v", err)
+	}
+	if n := strings.Count(playlist, "TYPE=AUDIO"); n != 1 {
+		t.Errorf("got %d audio renditions, want 1:\n%s", n, playlist)
+	}
+	if !strings.Contains(playlist, `LANGUAGE="en",DEFAULT=YES`) || !strings.Contains(playlist, "rep_id=en-aac") {
+		t.Errorf("en-aac should be the default audio:\n%s", playlist)
+	}
+
+	playlist, err = h.convertMuxedMasterPlaylist([]byte(audioTestMPD), "http://proxy", "https://cdn.example.com/manifest.mpd", nil, "", &types.VariantFilter{PreferEC3: true})
+	if err != nil {
+		t.Fatalf("convertMuxedMasterPlaylist() error = %v", err)
+	}
+	if !strings.Contains(playlist, "en-ec3") {
+		t.Errorf("muxed playlist should use the E-AC-3 track:\n%s", playlist)
+	}
+}
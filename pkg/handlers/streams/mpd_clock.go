@@ -0,0 +1,202 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/logging"
+)
+
+const (
+	// clockResyncInterval is how often a UTCTiming source is queried again.
+	clockResyncInterval = 5 * time.Minute
+	// clockRetryInterval is how long a failed UTCTiming source is skipped.
+	clockRetryInterval = 30 * time.Second
+	// clockSyncTimeout bounds a UTCTiming request.
+	clockSyncTimeout = 3 * time.Second
+	// maxClockSources bounds the number of UTCTiming sources remembered.
+	maxClockSources = 256
+)
+
+// UTCTiming is an MPD clock synchronization source.
+type UTCTiming struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr"`
+}
+
+// clockSync keeps the offset between the local clock and the clocks of live
+// MPD origins, measured from their UTCTiming elements, so the segments
+// available on a $Number$ template are computed on the origin's time. A
+// local clock ahead of the origin lists segments that don't exist yet and
+// players hit a 404 for each.
+type clockSync struct {
+	client  *httpclient.Client
+	log     *logging.Logger
+	now     func() time.Time
+	mu      sync.Mutex
+	offsets map[string]clockOffset // By scheme and source URL
+}
+
+// clockOffset is the measured offset of a UTCTiming source.
+type clockOffset struct {
+	offset  time.Duration // Origin clock minus local clock
+	ok      bool          // False when the last query failed
+	checked time.Time
+}
+
+func newClockSync(client *httpclient.Client, log *logging.Logger) *clockSync {
+	return &clockSync{
+		client:  client,
+		log:     log,
+		now:     time.Now,
+		offsets: make(map[string]clockOffset),
+	}
+}
+
+// sync measures the clock offset of the UTCTiming sources of mpd that weren't
+// queried recently, stopping at the first one that answers.
+func (c *clockSync) sync(ctx context.Context, mpd *MPD) {
+	if c == nil {
+		return
+	}
+	for _, timing := range mpd.UTCTimings {
+		kind, source := timingSource(timing)
+		if kind == "" || kind == "direct" {
+			continue
+		}
+		key := kind + " " + source
+
+		c.mu.Lock()
+		last, seen := c.offsets[key]
+		c.mu.Unlock()
+		if seen && last.ok && c.now().Sub(last.checked) < clockResyncInterval {
+			return
+		}
+		if seen && !last.ok && c.now().Sub(last.checked) < clockRetryInterval {
+			continue
+		}
+
+		offset, err := c.query(ctx, kind, source)
+		c.mu.Lock()
+		if len(c.offsets) >= maxClockSources {
+			clear(c.offsets)
+		}
+		c.offsets[key] = clockOffset{offset: offset, ok: err == nil, checked: c.now()}
+		c.mu.Unlock()
+		if err == nil {
+			c.log.Debug("synchronized with MPD clock", "source", source, "offset", offset)
+			return
+		}
+		c.log.Debug("failed to query MPD clock", "source", source, "error", err)
+	}
+}
+
+// originTime returns the current time on the clock of mpd's origin: corrected
+// by the offset of its first UTCTiming source known to work, or local time.
+func (c *clockSync) originTime(mpd *MPD) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	now := c.now()
+	for _, timing := range mpd.UTCTimings {
+		kind, source := timingSource(timing)
+		switch kind {
+		case "":
+			continue
+		case "direct":
+			// The MPD was generated a moment ago: its clock is as good as any
+			if t, err := parseXSDateTime(source); err == nil {
+				return t
+			}
+			continue
+		}
+
+		c.mu.Lock()
+		last, ok := c.offsets[kind+" "+source]
+		c.mu.Unlock()
+		if ok && last.ok {
+			return now.Add(last.offset)
+		}
+	}
+	return now
+}
+
+// query measures the offset of a UTCTiming source.
+func (c *clockSync) query(ctx context.Context, kind, source string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, clockSyncTimeout)
+	defer cancel()
+
+	method := http.MethodGet
+	if kind == "http-head" {
+		method = http.MethodHead
+	}
+	req, err := http.NewRequestWithContext(ctx, method, source, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	sent := c.now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch clock: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("clock source returned status %d", resp.StatusCode)
+	}
+
+	var remote time.Time
+	if kind == "http-head" {
+		remote, err = http.ParseTime(resp.Header.Get("Date"))
+	} else {
+		var body []byte
+		body, err = io.ReadAll(io.LimitReader(resp.Body, 256))
+		if err == nil {
+			remote, err = parseXSDateTime(string(body))
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse clock: %w", err)
+	}
+
+	// The origin read its clock halfway through the round trip
+	received := c.now()
+	local := sent.Add(received.Sub(sent) / 2)
+	return remote.Sub(local), nil
+}
+
+// timingSource returns the kind of a UTCTiming element ("http-iso",
+// "http-xsdate", "http-head" or "direct") and its first source, or an empty
+// kind for unsupported schemes such as NTP.
+func timingSource(timing UTCTiming) (kind, source string) {
+	fields := strings.Fields(timing.Value)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	for _, k := range []string{"http-iso", "http-xsdate", "http-head", "direct"} {
+		if strings.Contains(timing.SchemeIDURI, ":utc:"+k+":") {
+			if k == "direct" {
+				return k, strings.TrimSpace(timing.Value)
+			}
+			return k, fields[0]
+		}
+	}
+	return "", ""
+}
+
+// parseXSDateTime parses an xs:dateTime, UTC when it has no time zone.
+func parseXSDateTime(s string) (time.Time, error) {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date time %q", s)
+}
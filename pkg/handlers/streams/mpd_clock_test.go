@@ -0,0 +1,140 @@
+package streams
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/logging"
+)
+
+func TestTimingSource(t *testing.T) {
+	tests := []struct {
+		timing     UTCTiming
+		wantKind   string
+		wantSource string
+	}{
+		{UTCTiming{"urn:mpeg:dash:utc:http-iso:2014", "https://time.example.com/?iso"}, "http-iso", "https://time.example.com/?iso"},
+		{UTCTiming{"urn:mpeg:dash:utc:http-xsdate:2014", "https://a.example.com/ https://b.example.com/"}, "http-xsdate", "https://a.example.com/"},
+		{UTCTiming{"urn:mpeg:dash:utc:http-head:2014", "https://time.example.com/"}, "http-head", "https://time.example.com/"},
+		{UTCTiming{"urn:mpeg:dash:utc:direct:2014", "2024-01-01T00:00:00Z"}, "direct", "2024-01-01T00:00:00Z"},
+		{UTCTiming{"urn:mpeg:dash:utc:ntp:2014", "pool.ntp.org"}, "", ""},
+		{UTCTiming{"urn:mpeg:dash:utc:http-iso:2014", ""}, "", ""},
+	}
+	for _, tt := range tests {
+		kind, source := timingSource(tt.timing)
+		if kind != tt.wantKind || source != tt.wantSource {
+			t.Errorf("timingSource(%v) = %q, %q, want %q, %q", tt.timing, kind, source, tt.wantKind, tt.wantSource)
+		}
+	}
+}
+
+func TestParseXSDateTime(t *testing.T) {
+	want := time.Date(2024, 1, 1, 12, 0, 0, 500000000, time.UTC)
+	for _, s := range []string{"2024-01-01T12:00:00.5Z", "2024-01-01T13:00:00.5+01:00", "2024-01-01T12:00:00.5", "\"2024-01-01T12:00:00.5Z\"\n"} {
+		got, err := parseXSDateTime(s)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("parseXSDateTime(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	if _, err := parseXSDateTime("yesterday"); err == nil {
+		t.Error("parseXSDateTime(yesterday) should fail")
+	}
+}
+
+func TestClockSync(t *testing.T) {
+	local := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	remote := local.Add(-90 * time.Second)
+
+	var isoHits atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/iso", func(w http.ResponseWriter, r *http.Request) {
+		isoHits.Add(1)
+		w.Write([]byte(remote.Format(time.RFC3339Nano)))
+	})
+	mux.HandleFunc("/head", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", remote.Add(30*time.Second).Format(http.TimeFormat))
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	log := logging.New("error", false, nil)
+	c := newClockSync(httpclient.New(&config.Config{}, log), log)
+	c.now = func() time.Time { return local }
+
+	mpd := &MPD{UTCTimings: []UTCTiming{
+		{"urn:mpeg:dash:utc:ntp:2014", "pool.ntp.org"},
+		{"urn:mpeg:dash:utc:http-iso:2014", server.URL + "/iso"},
+	}}
+	if got := c.originTime(mpd); !got.Equal(local) {
+		t.Errorf("originTime() before sync = %v, want local %v", got, local)
+	}
+	c.sync(context.Background(), mpd)
+	c.sync(context.Background(), mpd)
+	if got := c.originTime(mpd); !got.Equal(remote) {
+		t.Errorf("originTime() = %v, want %v", got, remote)
+	}
+	if n := isoHits.Load(); n != 1 {
+		t.Errorf("clock queried %d times, want 1 within the resync interval", n)
+	}
+
+	// A failing source falls back to the next one
+	fallback := &MPD{UTCTimings: []UTCTiming{
+		{"urn:mpeg:dash:utc:http-xsdate:2014", server.URL + "/broken"},
+		{"urn:mpeg:dash:utc:http-head:2014", server.URL + "/head"},
+	}}
+	c.sync(context.Background(), fallback)
+	if got := c.originTime(fallback); !got.Equal(remote.Add(30 * time.Second)) {
+		t.Errorf("originTime() = %v, want the Date of the second source", got)
+	}
+
+	direct := &MPD{UTCTimings: []UTCTiming{{"urn:mpeg:dash:utc:direct:2014", "2024-01-01T11:00:00Z"}}}
+	if got := c.originTime(direct); !got.Equal(local.Add(-time.Hour)) {
+		t.Errorf("originTime() = %v, want the direct value", got)
+	}
+}
+
+func TestMPDHandler_buildSegmentsFromNumber(t *testing.T) {
+	availabilityStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	st := &SegmentTemplate{Media: "$RepresentationID$/$Number$.m4s", Duration: "4000"}
+	live := &MPD{
+		Type:                  "dynamic",
+		AvailabilityStartTime: "2024-01-01T00:00:00Z",
+		TimeShiftBufferDepth:  "PT20S",
+		UTCTimings:            []UTCTiming{{"urn:mpeg:dash:utc:direct:2014", "2024-01-01T00:16:30Z"}},
+	}
+
+	h := &MPDHandler{clock: &clockSync{now: func() time.Time { return availabilityStart.Add(1000 * time.Second) }}}
+
+	// The origin is at 990s: segment 247 ends at 992s and doesn't exist yet
+	segments := h.buildSegmentsFromNumber(live, &Period{}, st, "v1", "1000", 1000, 1)
+	if len(segments) != 6 || segments[0].Number != 242 || segments[5].Number != 247 {
+		t.Fatalf("got %d segments %+v, want 242..247", len(segments), segments)
+	}
+	if segments[5].URL != "v1/247.m4s" || segments[5].Time != 246*4000 || segments[5].Duration != 4 {
+		t.Errorf("last segment = %+v", segments[5])
+	}
+
+	// Period start delays availability
+	segments = h.buildSegmentsFromNumber(live, &Period{Start: "PT984S"}, st, "v1", "1000", 1000, 1)
+	if len(segments) != 1 || segments[0].Number != 1 {
+		t.Errorf("got %+v, want only the first segment of the period", segments)
+	}
+	if segments := h.buildSegmentsFromNumber(live, &Period{Start: "PT1H"}, st, "v1", "1000", 1000, 1); len(segments) != 0 {
+		t.Errorf("future period lists %d segments", len(segments))
+	}
+
+	static := &MPD{Type: "static", MediaPresentationDuration: "PT1M2S"}
+	segments = h.buildSegmentsFromNumber(static, &Period{}, st, "v1", "1000", 1000, 0)
+	if len(segments) != 16 || segments[0].Number != 0 || segments[15].Number != 15 {
+		t.Errorf("got %d segments, want 16 covering 62s", len(segments))
+	}
+}
@@ -0,0 +1,127 @@
+package streams
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"media-proxy-go/pkg/crypto"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/types"
+)
+
+// SetKeyStore sets the store consulted for ClearKey keys when a request
+// doesn't carry any.
+func (h *MPDHandler) SetKeyStore(keys interfaces.KeyStore) {
+	h.keys = keys
+}
+
+// drmInfo collects the KIDs, DRM systems and PSSH boxes signalled in the MPD's
+// ContentProtection elements.
+func (h *MPDHandler) drmInfo(mpd *MPD) *types.DRMInfo {
+	info := &types.DRMInfo{KIDs: []string{}, Systems: []string{}}
+	seen := make(map[string]bool)
+	add := func(list *[]string, value string) {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			*list = append(*list, value)
+		}
+	}
+
+	visit := func(cps []ContentProtection) {
+		for _, cp := range cps {
+			add(&info.KIDs, crypto.NormalizeKID(cp.DefaultKID))
+			add(&info.Systems, crypto.SystemName(cp.SchemeIDURI))
+
+			// PlayReady-only manifests may carry their KIDs just in the header
+			if pro, err := base64.StdEncoding.DecodeString(strings.TrimSpace(cp.PRO)); err == nil && len(pro) > 0 {
+				kids, _ := crypto.PlayReadyKIDs(pro)
+				for _, kid := range kids {
+					add(&info.KIDs, kid)
+				}
+			}
+
+			psshB64 := strings.TrimSpace(cp.PSSH)
+			if psshB64 == "" {
+				continue
+			}
+			add(&info.PSSH, psshB64)
+			box, err := base64.StdEncoding.DecodeString(psshB64)
+			if err != nil {
+				continue
+			}
+			if pssh, err := crypto.ParsePSSH(box); err == nil {
+				for _, kid := range pssh.KIDs {
+					add(&info.KIDs, kid)
+				}
+			}
+		}
+	}
+
+	for _, period := range mpd.Periods {
+		for _, as := range period.AdaptationSets {
+			visit(as.ContentProtections)
+			for _, rep := range as.Representations {
+				visit(rep.ContentProtections)
+			}
+		}
+	}
+	return info
+}
+
+// resolveClearKey returns the ClearKey string to decrypt with: the request's
+// own keys, or those found in the key store for the manifest's KIDs. It sets
+// info.HasKeys if every KID has a key.
+func (h *MPDHandler) resolveClearKey(info *types.DRMInfo, clearKey string) string {
+	known := make(map[string]bool)
+	for _, pair := range strings.Split(clearKey, ",") {
+		if kid, _, ok := strings.Cut(pair, ":"); ok {
+			known[crypto.NormalizeKID(kid)] = true
+		}
+	}
+
+	if clearKey == "" && h.keys != nil {
+		var pairs []string
+		for _, kid := range info.KIDs {
+			if key, ok := h.keys.Lookup(kid); ok {
+				pairs = append(pairs, kid+":"+key)
+				known[kid] = true
+			}
+		}
+		if len(pairs) > 0 {
+			h.log.Debug("using keys from key store", "kids", len(pairs))
+			clearKey = strings.Join(pairs, ",")
+		}
+	}
+
+	info.HasKeys = len(info.KIDs) > 0
+	for _, kid := range info.KIDs {
+		if !known[kid] {
+			info.HasKeys = false
+		}
+	}
+	return clearKey
+}
+
+// drmInfoResponse returns the DRM info as JSON.
+func drmInfoResponse(info *types.DRMInfo) *types.StreamResponse {
+	data, _ := json.Marshal(info)
+	return &types.StreamResponse{
+		ContentType: "application/json",
+		Body:        io.NopCloser(bytes.NewReader(data)),
+		StatusCode:  http.StatusOK,
+	}
+}
+
+// drmHeaders adds the KIDs and DRM systems of a protected manifest to response headers.
+func drmHeaders(headers map[string]string, info *types.DRMInfo) {
+	if len(info.KIDs) == 0 {
+		return
+	}
+	headers["X-DRM-KIDs"] = strings.Join(info.KIDs, ",")
+	headers["X-DRM-Systems"] = strings.Join(info.Systems, ",")
+	headers["Access-Control-Expose-Headers"] = "X-DRM-KIDs, X-DRM-Systems"
+}
@@ -0,0 +1,151 @@
+package streams
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+type stubKeyStore map[string]string
+
+func (s stubKeyStore) Lookup(kid string) (string, bool) {
+	key, ok := s[kid]
+	return key, ok
+}
+
+// widevinePSSH builds a base64 Widevine pssh box listing kid.
+func widevinePSSH(kid string) string {
+	k, _ := hex.DecodeString(kid)
+	data := append([]byte{0x12, 0x10}, k...)
+	sys, _ := hex.DecodeString("edef8ba979d64acea3c827dcd51d21ed")
+
+	body := append([]byte{0, 0, 0, 0}, sys...)
+	body = binary.BigEndian.AppendUint32(body, uint32(len(data)))
+	body = append(body, data...)
+	box := binary.BigEndian.AppendUint32(nil, uint32(len(body)+8))
+	box = append(box, "pssh"...)
+	return base64.StdEncoding.EncodeToString(append(box, body...))
+}
+
+func TestMPDHandler_drmInfo(t *testing.T) {
+	h := &MPDHandler{log: logging.New("error", false, nil)}
+
+	manifest := `<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" xmlns:cenc="urn:mpeg:cenc:2013" type="static">
+  <Period>
+    <AdaptationSet mimeType="video/mp4">
+      <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" value="cenc" cenc:default_KID="11111111-2222-3333-4444-555555555555"/>
+      <ContentProtection schemeIdUri="urn:uuid:EDEF8BA9-79D6-4ACE-A3C8-27DCD51D21ED">
+        <cenc:pssh>` + widevinePSSH("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa") + `</cenc:pssh>
+      </ContentProtection>
+      <Representation id="v1" bandwidth="1000000"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="audio/mp4">
+      <Representation id="a1" bandwidth="128000">
+        <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" cenc:default_KID="11111111-2222-3333-4444-555555555555"/>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+	mpd, err := h.parseMPD([]byte(manifest))
+	if err != nil {
+		t.Fatalf("parseMPD() error = %v", err)
+	}
+	info := h.drmInfo(mpd)
+
+	wantKIDs := "11111111222233334444555555555555,aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	if got := strings.Join(info.KIDs, ","); got != wantKIDs {
+		t.Errorf("KIDs = %s, want %s", got, wantKIDs)
+	}
+	if got := strings.Join(info.Systems, ","); got != "widevine" {
+		t.Errorf("Systems = %s, want widevine", got)
+	}
+	if len(info.PSSH) != 1 {
+		t.Errorf("PSSH = %v, want 1 box", info.PSSH)
+	}
+}
+
+func TestMPDHandler_drmInfo_PlayReady(t *testing.T) {
+	h := &MPDHandler{log: logging.New("error", false, nil)}
+
+	// PlayReady Object whose WRMHEADER (v4.3) lists KID 0123456789abcdef0123456789abcdef
+	// as a little-endian GUID
+	header := `<WRMHEADER version="4.3.0.0"><DATA><PROTECTINFO><KIDS><KID ALGID="AESCTR" VALUE="Z0UjAauJ780BI0VniavN7w=="/></KIDS></PROTECTINFO></DATA></WRMHEADER>`
+	var record []byte
+	for _, u := range utf16.Encode([]rune(header)) {
+		record = binary.LittleEndian.AppendUint16(record, u)
+	}
+	pro := binary.LittleEndian.AppendUint32(nil, uint32(len(record)+10))
+	pro = binary.LittleEndian.AppendUint16(pro, 1)
+	pro = binary.LittleEndian.AppendUint16(pro, 1)
+	pro = binary.LittleEndian.AppendUint16(pro, uint16(len(record)))
+	pro = append(pro, record...)
+
+	manifest := `<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" xmlns:mspr="urn:microsoft:playready" type="static">
+  <Period>
+    <AdaptationSet mimeType="video/mp4">
+      <ContentProtection schemeIdUri="urn:uuid:9A04F079-9840-4286-AB92-E65BE0885F95">
+        <mspr:pro>` + base64.StdEncoding.EncodeToString(pro) + `</mspr:pro>
+      </ContentProtection>
+      <Representation id="v1" bandwidth="1000000"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+	mpd, err := h.parseMPD([]byte(manifest))
+	if err != nil {
+		t.Fatalf("parseMPD() error = %v", err)
+	}
+	info := h.drmInfo(mpd)
+	if got := strings.Join(info.KIDs, ","); got != "0123456789abcdef0123456789abcdef" {
+		t.Errorf("KIDs = %s, want 0123456789abcdef0123456789abcdef", got)
+	}
+	if got := strings.Join(info.Systems, ","); got != "playready" {
+		t.Errorf("Systems = %s, want playready", got)
+	}
+}
+
+func TestMPDHandler_resolveClearKey(t *testing.T) {
+	kid1 := "11111111222233334444555555555555"
+	kid2 := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	store := stubKeyStore{kid1: "00112233445566778899aabbccddeeff"}
+
+	tests := []struct {
+		name        string
+		kids        []string
+		clearKey    string
+		keys        stubKeyStore
+		want        string
+		wantHasKeys bool
+	}{
+		{"request keys win", []string{kid1}, kid1 + ":ffff", store, kid1 + ":ffff", true},
+		{"filled from store", []string{kid1}, "", store, kid1 + ":00112233445566778899aabbccddeeff", true},
+		{"partially known", []string{kid1, kid2}, "", store, kid1 + ":00112233445566778899aabbccddeeff", false},
+		{"no store", []string{kid1}, "", nil, "", false},
+		{"unprotected", []string{}, "", store, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &MPDHandler{log: logging.New("error", false, nil)}
+			if tt.keys != nil {
+				h.SetKeyStore(tt.keys)
+			}
+			info := &types.DRMInfo{KIDs: tt.kids}
+			if got := h.resolveClearKey(info, tt.clearKey); got != tt.want {
+				t.Errorf("resolveClearKey() = %q, want %q", got, tt.want)
+			}
+			if info.HasKeys != tt.wantHasKeys {
+				t.Errorf("HasKeys = %v, want %v", info.HasKeys, tt.wantHasKeys)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package streams
+
+import (
+	"fmt"
+	"strings"
+)
+
+// trickModeScheme marks a DASH-IF trick-mode adaptation set, whose value is
+// the ID of the adaptation set it is a trick mode of.
+const trickModeScheme = "http://dashif.org/guidelines/trickmode"
+
+// isTrickMode reports whether as is a trick-mode (I-frame only) video set.
+func (as AdaptationSet) isTrickMode() bool {
+	for _, props := range [][]Descriptor{as.EssentialProps, as.SupplementalProps} {
+		for _, prop := range props {
+			if prop.SchemeIDURI == trickModeScheme {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// iframeStreams returns the EXT-X-I-FRAME-STREAM-INF tags of the master
+// playlist: one per representation of the MPD's trick-mode sets or, without
+// any, one synthesized from the keyframes starting each segment of the
+// lowest bandwidth video representation.
+func (h *MPDHandler) iframeStreams(mpd *MPD, proxyBaseURL, originalURL string, headers map[string]string, clearKey string) []string {
+	var trickModes, regular []*Representation
+	for _, period := range mpd.Periods {
+		for i := range period.AdaptationSets {
+			as := &period.AdaptationSets[i]
+			if !strings.Contains(as.MimeType, "video") && !strings.Contains(as.ContentType, "video") {
+				continue
+			}
+			for j := range as.Representations {
+				if as.isTrickMode() {
+					trickModes = append(trickModes, &as.Representations[j])
+				} else {
+					regular = append(regular, &as.Representations[j])
+				}
+			}
+		}
+	}
+
+	reps := trickModes
+	if len(reps) == 0 && len(regular) > 0 {
+		lowest := regular[0]
+		for _, rep := range regular[1:] {
+			if atoiOrZero(rep.Bandwidth) < atoiOrZero(lowest.Bandwidth) {
+				lowest = rep
+			}
+		}
+		reps = []*Representation{lowest}
+	}
+
+	var lines []string
+	for _, rep := range reps {
+		inf := fmt.Sprintf("#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=%s", rep.Bandwidth)
+		if rep.Width > 0 && rep.Height > 0 {
+			inf += fmt.Sprintf(",RESOLUTION=%dx%d", rep.Width, rep.Height)
+		}
+		if rep.Codecs != "" {
+			inf += fmt.Sprintf(",CODECS=\"%s\"", rep.Codecs)
+		}
+		mediaURL := h.buildMediaPlaylistURL(proxyBaseURL, originalURL, rep.ID, headers, clearKey) + "&iframes=1"
+		lines = append(lines, inf+fmt.Sprintf(",URI=\"%s\"", mediaURL))
+	}
+	return lines
+}
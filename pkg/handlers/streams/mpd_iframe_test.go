@@ -0,0 +1,90 @@
+package streams
+
+import (
+	"strings"
+	"testing"
+)
+
+const trickModeTestMPD = `<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static" mediaPresentationDuration="PT8S">
+  <Period>
+    <AdaptationSet id="1" mimeType="video/mp4">
+      <SegmentTemplate timescale="1000" initialization="$RepresentationID$/init.mp4" media="$RepresentationID$/$Time$.m4s">
+        <SegmentTimeline><S t="0" d="4000" r="1"/></SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="v720" bandwidth="3000000" width="1280" height="720" codecs="avc1.64001f"/>
+      <Representation id="v1080" bandwidth="6000000" width="1920" height="1080" codecs="avc1.640028"/>
+    </AdaptationSet>
+    <AdaptationSet id="2" mimeType="video/mp4">
+      <EssentialProperty schemeIdUri="http://dashif.org/guidelines/trickmode" value="1"/>
+      <SegmentTemplate timescale="1000" initialization="$RepresentationID$/init.mp4" media="$RepresentationID$/$Time$.m4s">
+        <SegmentTimeline><S t="0" d="4000" r="1"/></SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="trick" bandwidth="200000" width="640" height="360" codecs="avc1.64001e"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+func TestMPDHandler_convertMasterPlaylist_IFrames(t *testing.T) {
+	h := &MPDHandler{}
+
+	playlist, err := h.convertMasterPlaylist([]byte(trickModeTestMPD), "http://proxy", "https://cdn.example.com/vod.mpd", nil, "", nil)
+	if err != nil {
+		t.Fatalf("convertMasterPlaylist() error = %v", err)
+	}
+	if n := strings.Count(playlist, "#EXT-X-STREAM-INF"); n != 1 {
+		t.Errorf("got %d variants, want only v1080 (trick mode isn't a variant):\n%s", n, playlist)
+	}
+	if !strings.Contains(playlist, `#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=200000,RESOLUTION=640x360,CODECS="avc1.64001e",URI="`) ||
+		!strings.Contains(playlist, "rep_id=trick&iframes=1") {
+		t.Errorf("missing trick-mode I-frame stream:\n%s", playlist)
+	}
+
+	// Without trick mode, the lowest representation's keyframes are used
+	plain := strings.Replace(trickModeTestMPD, "http://dashif.org/guidelines/trickmode", "urn:example:other", 1)
+	playlist, err = h.convertMasterPlaylist([]byte(plain), "http://proxy", "https://cdn.example.com/vod.mpd", nil, "", nil)
+	if err != nil {
+		t.Fatalf("convertMasterPlaylist() error = %v", err)
+	}
+	if n := strings.Count(playlist, "#EXT-X-I-FRAME-STREAM-INF"); n != 1 || !strings.Contains(playlist, "rep_id=trick&iframes=1") {
+		t.Errorf("want one I-frame stream from the lowest representation:\n%s", playlist)
+	}
+}
+
+func TestMPDHandler_convertIFramePlaylist(t *testing.T) {
+	h := &MPDHandler{}
+
+	tests := []struct {
+		repID        string
+		wantKeyframe bool
+	}{
+		{"trick", false},
+		{"v720", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.repID, func(t *testing.T) {
+			playlist, err := h.convertIFramePlaylist([]byte(trickModeTestMPD), tt.repID, "http://proxy", "https://cdn.example.com/vod.mpd", nil, "")
+			if err != nil {
+				t.Fatalf("convertIFramePlaylist() error = %v", err)
+			}
+			if !strings.HasPrefix(playlist, "#EXTM3U\n#EXT-X-VERSION:4\n#EXT-X-I-FRAMES-ONLY\n") {
+				t.Errorf("missing I-frame header:\n%s", playlist)
+			}
+			if n := strings.Count(playlist, "#EXTINF:4.000,"); n != 2 {
+				t.Errorf("got %d segments, want 2:\n%s", n, playlist)
+			}
+			if got := strings.Count(playlist, "&keyframe=1"); (got == 2) != tt.wantKeyframe || (got == 0) == tt.wantKeyframe {
+				t.Errorf("keyframe=1 on %d segments, want keyframe extraction %v:\n%s", got, tt.wantKeyframe, playlist)
+			}
+		})
+	}
+
+	// Regular media playlists are unchanged
+	playlist, err := h.convertMediaPlaylist([]byte(trickModeTestMPD), "v720", "", "http://proxy", "https://cdn.example.com/vod.mpd", nil, "")
+	if err != nil {
+		t.Fatalf("convertMediaPlaylist() error = %v", err)
+	}
+	if strings.Contains(playlist, "I-FRAMES-ONLY") || strings.Contains(playlist, "keyframe=1") {
+		t.Errorf("media playlist marked as I-frame playlist:\n%s", playlist)
+	}
+}
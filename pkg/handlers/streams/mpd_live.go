@@ -0,0 +1,137 @@
+package streams
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultLiveWindow is the number of segments kept in a live playlist
+	// when the MPD has no timeShiftBufferDepth.
+	defaultLiveWindow = 20
+	// defaultLiveDelay is how far behind the live edge players start when
+	// the MPD has no suggestedPresentationDelay.
+	defaultLiveDelay = 30 * time.Second
+)
+
+var isoDurationRe = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseISODuration parses an xs:duration as used by MPD attributes, e.g.
+// "PT1H30M" or "PT4.5S". Years and months are not supported.
+func parseISODuration(s string) (time.Duration, bool) {
+	m := isoDurationRe.FindStringSubmatch(s)
+	if m == nil || s == "P" || s == "PT" {
+		return 0, false
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+1] == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(m[i+1], 64)
+		if err != nil {
+			return 0, false
+		}
+		d += time.Duration(v * float64(unit))
+	}
+	return d, true
+}
+
+// liveWindow returns the segments of a live playlist: those within the MPD's
+// timeShiftBufferDepth of the live edge, so players can seek back as far as
+// the origin keeps segments, or the last defaultLiveWindow segments.
+func liveWindow(mpd *MPD, segments []segment) []segment {
+	depth, ok := parseISODuration(mpd.TimeShiftBufferDepth)
+	if !ok || depth <= 0 {
+		if len(segments) > defaultLiveWindow {
+			segments = segments[len(segments)-defaultLiveWindow:]
+		}
+		return segments
+	}
+
+	start, total := len(segments), 0.0
+	for start > 0 && total+segments[start-1].Duration <= depth.Seconds() {
+		start--
+		total += segments[start].Duration
+	}
+	// Always keep the segment at the live edge
+	if start == len(segments) && start > 0 {
+		start--
+	}
+	return segments[start:]
+}
+
+// liveStartOffset returns how many seconds behind the live edge players
+// should start: the MPD's suggestedPresentationDelay, at most the window.
+func liveStartOffset(mpd *MPD, window []segment) float64 {
+	delay, ok := parseISODuration(mpd.SuggestedPresentationDelay)
+	if !ok {
+		delay = defaultLiveDelay
+	}
+	offset := delay.Seconds()
+
+	windowDuration := 0.0
+	for _, seg := range window {
+		windowDuration += seg.Duration
+	}
+	if windowDuration > 0 && offset > windowDuration {
+		offset = windowDuration
+	}
+	return offset
+}
+
+// buildSegmentsFromNumber lists the segments of a $Number$ template without
+// SegmentTimeline, whose segments all last st.Duration. For a live MPD these
+// are the segments available on the origin's clock, within its time shift
+// buffer; for a static one, those covering mediaPresentationDuration.
+func (h *MPDHandler) buildSegmentsFromNumber(mpd *MPD, period *Period, st *SegmentTemplate, repID, bandwidth string, timescale, startNumber int) []segment {
+	d, _ := strconv.Atoi(st.Duration)
+	if d <= 0 || timescale <= 0 {
+		return nil
+	}
+	duration := float64(d) / float64(timescale)
+
+	var first, last int64
+	if strings.ToLower(mpd.Type) == "dynamic" {
+		availabilityStart, err := parseXSDateTime(mpd.AvailabilityStartTime)
+		if err != nil {
+			return nil
+		}
+		periodStart, _ := parseISODuration(period.Start)
+		elapsed := h.clock.originTime(mpd).Sub(availabilityStart) - periodStart
+
+		// A segment is available once it has ended
+		last = int64(elapsed.Seconds()/duration) - 1
+		if last < 0 {
+			return nil
+		}
+		count := int64(defaultLiveWindow)
+		if depth, ok := parseISODuration(mpd.TimeShiftBufferDepth); ok && depth > 0 {
+			count = int64(depth.Seconds()/duration) + 1
+		}
+		first = max(0, last-count+1)
+	} else {
+		total, ok := parseISODuration(mpd.MediaPresentationDuration)
+		if !ok {
+			return nil
+		}
+		last = int64(math.Ceil(total.Seconds()/duration)) - 1
+	}
+
+	segments := make([]segment, 0, last-first+1)
+	for k := first; k <= last; k++ {
+		number := startNumber + int(k)
+		t := k * int64(d)
+		segments = append(segments, segment{
+			URL:        h.replaceTemplateVars(st.Media, repID, bandwidth, number, t),
+			Duration:   duration,
+			DurationTS: d,
+			Time:       t,
+			Number:     number,
+		})
+	}
+	return segments
+}
@@ -0,0 +1,102 @@
+package streams
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseISODuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"PT30S", 30 * time.Second, true},
+		{"PT1H30M", 90 * time.Minute, true},
+		{"PT4.5S", 4500 * time.Millisecond, true},
+		{"P1DT2H", 26 * time.Hour, true},
+		{"PT0S", 0, true},
+		{"P1Y", 0, false},
+		{"PT", 0, false},
+		{"30", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseISODuration(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseISODuration(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLiveWindow(t *testing.T) {
+	segments := make([]segment, 100)
+	for i := range segments {
+		segments[i] = segment{Number: i, Duration: 4}
+	}
+
+	tests := []struct {
+		name      string
+		depth     string
+		wantLen   int
+		wantFirst int
+	}{
+		{"no depth", "", defaultLiveWindow, 100 - defaultLiveWindow},
+		{"one minute", "PT1M", 15, 85},
+		{"partial segment", "PT62S", 15, 85},
+		{"longer than timeline", "PT1H", 100, 0},
+		{"shorter than a segment", "PT1S", 1, 99},
+		{"invalid", "1 minute", defaultLiveWindow, 100 - defaultLiveWindow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := liveWindow(&MPD{TimeShiftBufferDepth: tt.depth}, segments)
+			if len(got) != tt.wantLen || got[0].Number != tt.wantFirst {
+				t.Errorf("liveWindow() = %d segments from %d, want %d from %d", len(got), got[0].Number, tt.wantLen, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestLiveStartOffset(t *testing.T) {
+	window := []segment{{Duration: 6}, {Duration: 6}, {Duration: 6}}
+
+	if got := liveStartOffset(&MPD{SuggestedPresentationDelay: "PT10S"}, window); got != 10 {
+		t.Errorf("offset = %v, want 10", got)
+	}
+	if got := liveStartOffset(&MPD{}, window); got != 18 {
+		t.Errorf("default offset = %v, want the 18s window", got)
+	}
+	if got := liveStartOffset(&MPD{}, append(window, window...)); got != defaultLiveDelay.Seconds() {
+		t.Errorf("default offset = %v, want %v", got, defaultLiveDelay.Seconds())
+	}
+}
+
+func TestMPDHandler_convertMediaPlaylist_TimeShift(t *testing.T) {
+	manifest := `<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="dynamic" timeShiftBufferDepth="PT2M" suggestedPresentationDelay="PT12S">
+  <Period>
+    <AdaptationSet mimeType="video/mp4">
+      <SegmentTemplate timescale="1000" initialization="$RepresentationID$/init.mp4" media="$RepresentationID$/$Time$.m4s">
+        <SegmentTimeline><S t="0" d="4000" r="59"/></SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="v1" bandwidth="1000000"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+	h := &MPDHandler{}
+	playlist, err := h.convertMediaPlaylist([]byte(manifest), "v1", "", "http://proxy", "https://cdn.example.com/live.mpd", nil, "")
+	if err != nil {
+		t.Fatalf("convertMediaPlaylist() error = %v", err)
+	}
+	if n := strings.Count(playlist, "#EXTINF"); n != 30 {
+		t.Errorf("got %d segments, want the 30 of a 2 minute buffer", n)
+	}
+	if !strings.Contains(playlist, "#EXT-X-START:TIME-OFFSET=-12.0,PRECISE=NO") {
+		t.Errorf("missing start offset from suggestedPresentationDelay:\n%s", playlist)
+	}
+	if !strings.Contains(playlist, "#EXT-X-MEDIA-SEQUENCE:30") {
+		t.Errorf("media sequence should start at the window:\n%s", playlist)
+	}
+}
@@ -0,0 +1,113 @@
+package streams
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxManifestLocations bounds the original URL -> manifest location map.
+const maxManifestLocations = 4096
+
+// manifestLocations remembers where the live MPDs requested by players have
+// moved: the URL to refresh them from, given by an MPD Location element or a
+// permanent redirect, and the URL they were last served from, which relative
+// segment URLs resolve against. Players keep requesting the original URL, so
+// an origin migrating mid-stream doesn't break playback.
+type manifestLocations struct {
+	mu        sync.RWMutex
+	locations map[string]manifestLocation
+}
+
+// manifestLocation is where a manifest has moved.
+type manifestLocation struct {
+	refresh string // URL to fetch the manifest from
+	base    string // URL the manifest was last served from
+}
+
+func newManifestLocations() *manifestLocations {
+	return &manifestLocations{locations: make(map[string]manifestLocation)}
+}
+
+// refreshURL returns the URL to fetch the manifest requested as original from.
+func (l *manifestLocations) refreshURL(original string) string {
+	if loc, ok := l.get(original); ok && loc.refresh != "" {
+		return loc.refresh
+	}
+	return original
+}
+
+// baseURL returns the URL the manifest requested as original was last served
+// from.
+func (l *manifestLocations) baseURL(original string) string {
+	if loc, ok := l.get(original); ok && loc.base != "" {
+		return loc.base
+	}
+	return original
+}
+
+func (l *manifestLocations) get(original string) (manifestLocation, bool) {
+	if l == nil {
+		return manifestLocation{}, false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	loc, ok := l.locations[original]
+	return loc, ok
+}
+
+// set remembers where the manifest requested as original has moved, or
+// forgets it when it hasn't.
+func (l *manifestLocations) set(original string, loc manifestLocation) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if loc.refresh == original && loc.base == original {
+		delete(l.locations, original)
+		return
+	}
+	if len(l.locations) >= maxManifestLocations {
+		l.locations = make(map[string]manifestLocation)
+	}
+	l.locations[original] = loc
+}
+
+// forget drops what is known of the manifest requested as original.
+func (l *manifestLocations) forget(original string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.locations, original)
+}
+
+// relocation returns where a fetched manifest has moved: refreshes go to its
+// MPD Location, or past the permanent redirects (301, 308) leading to it,
+// and relative URLs resolve against the URL it was served from.
+func (h *MPDHandler) relocation(mpd *MPD, resp *http.Response, fetched string) manifestLocation {
+	final := resp.Request.URL.String()
+
+	// Requests of the redirect chain, the first one first
+	chain := []*http.Request{resp.Request}
+	for chain[0].Response != nil && chain[0].Response.Request != nil {
+		chain = append([]*http.Request{chain[0].Response.Request}, chain...)
+	}
+
+	refresh := fetched
+	for _, r := range chain[1:] {
+		if code := r.Response.StatusCode; code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+			break
+		}
+		refresh = r.URL.String()
+	}
+	for _, location := range mpd.Locations {
+		if location = strings.TrimSpace(location); location != "" {
+			refresh = h.resolveURL(location, final)
+			break
+		}
+	}
+	return manifestLocation{refresh: refresh, base: final}
+}
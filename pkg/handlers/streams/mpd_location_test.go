@@ -0,0 +1,103 @@
+package streams
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+const locationTestMPD = `<MPD type="dynamic">%s<Period><AdaptationSet mimeType="video/mp4">
+<SegmentTemplate timescale="1" media="$RepresentationID$/$Time$.m4s"><SegmentTimeline><S t="0" d="4"/></SegmentTimeline></SegmentTemplate>
+<Representation id="v1" bandwidth="1000"/></AdaptationSet></Period></MPD>`
+
+func TestMPDHandler_HandleManifest_Relocation(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	gone := make(map[string]bool)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		isGone := gone[r.URL.Path]
+		mu.Unlock()
+
+		switch {
+		case isGone:
+			http.NotFound(w, r)
+		case r.URL.Path == "/old.mpd":
+			http.Redirect(w, r, "/new/live.mpd", http.StatusMovedPermanently)
+		case r.URL.Path == "/temp.mpd":
+			http.Redirect(w, r, "/edge/live.mpd", http.StatusFound)
+		case r.URL.Path == "/loc.mpd":
+			w.Write([]byte(strings.Replace(locationTestMPD, "%s", "<Location>/moved/live.mpd</Location>", 1)))
+		default:
+			w.Write([]byte(strings.Replace(locationTestMPD, "%s", "", 1)))
+		}
+	}))
+	defer upstream.Close()
+
+	log := logging.New("error", false, nil)
+	h := NewMPDHandler(httpclient.New(&config.Config{}, log), log, "http://proxy", nil)
+
+	playlist := func(path string) string {
+		t.Helper()
+		resp, err := h.HandleManifest(t.Context(), &types.StreamRequest{URL: upstream.URL + path, RepID: "v1"}, "http://proxy")
+		if err != nil {
+			t.Fatalf("HandleManifest(%s) error = %v", path, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("HandleManifest(%s) status = %d", path, resp.StatusCode)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	wantSegment := func(p, dir string) {
+		t.Helper()
+		if seg := url.QueryEscape(upstream.URL + dir + "v1/0.m4s"); !strings.Contains(p, seg) {
+			t.Errorf("segment should resolve against %s:\n%s", dir, p)
+		}
+	}
+	hitCount := func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return hits[path]
+	}
+
+	// Permanent redirect: later refreshes skip it
+	wantSegment(playlist("/old.mpd"), "/new/")
+	wantSegment(playlist("/old.mpd"), "/new/")
+	if n := hitCount("/old.mpd"); n != 1 {
+		t.Errorf("permanently redirected MPD fetched %d times, want 1", n)
+	}
+
+	// Temporary redirect: segments follow it, refreshes still start there
+	wantSegment(playlist("/temp.mpd"), "/edge/")
+	wantSegment(playlist("/temp.mpd"), "/edge/")
+	if n := hitCount("/temp.mpd"); n != 2 {
+		t.Errorf("temporarily redirected MPD fetched %d times, want 2", n)
+	}
+
+	// MPD Location: later refreshes go there
+	wantSegment(playlist("/loc.mpd"), "/")
+	wantSegment(playlist("/loc.mpd"), "/moved/")
+	if n := hitCount("/loc.mpd"); n != 1 {
+		t.Errorf("relocated MPD fetched %d times, want 1", n)
+	}
+
+	// The new location disappears: back to the original URL
+	mu.Lock()
+	gone["/moved/live.mpd"] = true
+	mu.Unlock()
+	wantSegment(playlist("/loc.mpd"), "/")
+	if n := hitCount("/loc.mpd"); n != 2 {
+		t.Errorf("original MPD fetched %d times after the location broke, want 2", n)
+	}
+}
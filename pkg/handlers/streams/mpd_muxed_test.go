@@ -0,0 +1,140 @@
+package streams
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+const muxedTestMPD = `<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static">
+  <Period>
+    <AdaptationSet mimeType="video/mp4">
+      <SegmentTemplate timescale="90000" initialization="v/$RepresentationID$/init.mp4" media="v/$RepresentationID$/$Time$.m4s">
+        <SegmentTimeline><S t="0" d="360000" r="1"/></SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="v720" bandwidth="3000000" width="1280" height="720" codecs="avc1.64001f"/>
+      <Representation id="v1080" bandwidth="6000000" width="1920" height="1080" codecs="avc1.640028"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="audio/mp4" lang="en">
+      <SegmentTemplate timescale="48000" initialization="a/$RepresentationID$/init.mp4" media="a/$RepresentationID$/$Time$.m4s">
+        <SegmentTimeline><S t="0" d="96000" r="3"/></SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="a64" bandwidth="64000" codecs="mp4a.40.5"/>
+      <Representation id="a128" bandwidth="128000" codecs="mp4a.40.2"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="audio/mp4" lang="de">
+      <Representation id="a-de" bandwidth="256000" codecs="mp4a.40.2"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+func TestMPDHandler_convertMuxedMasterPlaylist(t *testing.T) {
+	h := &MPDHandler{}
+
+	playlist, err := h.convertMuxedMasterPlaylist([]byte(muxedTestMPD), "http://proxy", "https://cdn.example.com/live/manifest.mpd", nil, "", nil)
+	if err != nil {
+		t.Fatalf("convertMuxedMasterPlaylist() error = %v", err)
+	}
+
+	if strings.Contains(playlist, "#EXT-X-MEDIA") {
+		t.Errorf("muxed playlist should not have audio groups:\n%s", playlist)
+	}
+	if n := strings.Count(playlist, "#EXT-X-STREAM-INF"); n != 1 {
+		t.Fatalf("got %d variants, want 1:\n%s", n, playlist)
+	}
+	if !strings.Contains(playlist, `BANDWIDTH=6128000,RESOLUTION=1920x1080,CODECS="avc1.640028,mp4a.40.2"`) {
+		t.Errorf("unexpected variant:\n%s", playlist)
+	}
+
+	lines := strings.Split(playlist, "\n")
+	u, err := url.Parse(lines[len(lines)-1])
+	if err != nil {
+		t.Fatalf("invalid media playlist URL: %v", err)
+	}
+	if got := u.Query().Get("rep_id"); got != "v1080" {
+		t.Errorf("rep_id = %q, want v1080", got)
+	}
+	if got := u.Query().Get("audio_rep_id"); got != "a128" {
+		t.Errorf("audio_rep_id = %q, want a128", got)
+	}
+}
+
+func TestMPDHandler_convertMediaPlaylist_Muxed(t *testing.T) {
+	h := &MPDHandler{log: logging.New("error", false, nil)}
+
+	playlist, err := h.convertMediaPlaylist([]byte(muxedTestMPD), "v1080", "a128", "http://proxy", "https://cdn.example.com/live/manifest.mpd", nil, "")
+	if err != nil {
+		t.Fatalf("convertMediaPlaylist() error = %v", err)
+	}
+
+	var segments []*url.URL
+	for _, line := range strings.Split(playlist, "\n") {
+		if strings.HasPrefix(line, "http://proxy/decrypt/") {
+			u, err := url.Parse(line)
+			if err != nil {
+				t.Fatalf("invalid segment URL: %v", err)
+			}
+			segments = append(segments, u)
+		}
+	}
+	if len(segments) != 2 {
+		t.Fatalf("got %d segments, want 2:\n%s", len(segments), playlist)
+	}
+
+	// Each 4s video segment carries the two 2s audio segments starting in it
+	want := [][]string{
+		{"https://cdn.example.com/live/a/a128/0.m4s", "https://cdn.example.com/live/a/a128/96000.m4s"},
+		{"https://cdn.example.com/live/a/a128/192000.m4s", "https://cdn.example.com/live/a/a128/288000.m4s"},
+	}
+	for i, u := range segments {
+		q := u.Query()
+		if got := q["audio_url"]; strings.Join(got, " ") != strings.Join(want[i], " ") {
+			t.Errorf("segment %d audio_url = %v, want %v", i, got, want[i])
+		}
+		if got := q.Get("audio_init_url"); got != "https://cdn.example.com/live/a/a128/init.mp4" {
+			t.Errorf("segment %d audio_init_url = %q", i, got)
+		}
+	}
+}
+
+func TestMPDHandler_convertMediaPlaylist_MissingAudio(t *testing.T) {
+	h := &MPDHandler{log: logging.New("error", false, nil)}
+
+	playlist, err := h.convertMediaPlaylist([]byte(muxedTestMPD), "v720", "missing", "http://proxy", "https://cdn.example.com/live/manifest.mpd", nil, "")
+	if err != nil {
+		t.Fatalf("convertMediaPlaylist() error = %v", err)
+	}
+	if strings.Contains(playlist, "audio_url") {
+		t.Errorf("playlist should fall back to video only:\n%s", playlist)
+	}
+}
+
+func TestMPDHandler_convertAudioMasterPlaylist(t *testing.T) {
+	h := &MPDHandler{}
+
+	playlist, err := h.convertAudioMasterPlaylist([]byte(muxedTestMPD), "http://proxy", "https://cdn.example.com/live/manifest.mpd", nil, "", nil)
+	if err != nil {
+		t.Fatalf("convertAudioMasterPlaylist() error = %v", err)
+	}
+	if strings.Contains(playlist, "RESOLUTION=") || strings.Contains(playlist, "rep_id=v") {
+		t.Errorf("audio-only playlist lists video:\n%s", playlist)
+	}
+	if n := strings.Count(playlist, "#EXT-X-STREAM-INF"); n != 3 {
+		t.Errorf("got %d variants, want 3:\n%s", n, playlist)
+	}
+	if !strings.Contains(playlist, `#EXT-X-STREAM-INF:BANDWIDTH=64000,CODECS="mp4a.40.5"`) {
+		t.Errorf("missing a64 variant:\n%s", playlist)
+	}
+
+	playlist, err = h.convertAudioMasterPlaylist([]byte(muxedTestMPD), "http://proxy", "https://cdn.example.com/live/manifest.mpd", nil, "", &types.VariantFilter{AudioLangs: []string{"de"}})
+	if err != nil {
+		t.Fatalf("convertAudioMasterPlaylist() error = %v", err)
+	}
+	if n := strings.Count(playlist, "#EXT-X-STREAM-INF"); n != 1 || !strings.Contains(playlist, "rep_id=a-de") {
+		t.Errorf("audio_lang=de should keep only a-de:\n%s", playlist)
+	}
+}
@@ -0,0 +1,121 @@
+package streams
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// sequenceIdle is how long the sequence state of a live MPD playlist is
+	// kept after its last refresh.
+	sequenceIdle = 10 * time.Minute
+	// maxTrackedPlaylists bounds the number of live playlists tracked.
+	maxTrackedPlaylists = 4096
+)
+
+// sequenceTracker numbers the segments of live MPD playlists consistently
+// across refreshes. Deriving EXT-X-MEDIA-SEQUENCE from each refresh alone
+// goes backwards when the origin's window or segment durations change, and
+// players reset on a sequence that goes backwards.
+type sequenceTracker struct {
+	mu        sync.Mutex
+	playlists map[string]*sequenceState
+	now       func() time.Time
+}
+
+// sequenceState is what the last refresh of a playlist served.
+type sequenceState struct {
+	firstSeq int64     // Media sequence of the first segment
+	times    []int64   // Presentation times of the segments, in order
+	seen     time.Time // Last refresh
+}
+
+func newSequenceTracker() *sequenceTracker {
+	return &sequenceTracker{
+		playlists: make(map[string]*sequenceState),
+		now:       time.Now,
+	}
+}
+
+// assign returns the media sequence of the first of segments, the window of
+// playlist key sorted by time, and the segments to serve. The first refresh
+// starts at initial. Later ones keep the sequence number of the newest
+// segment already served, so players continue with the segment after the
+// one they played, even when the origin changed segment durations. Segments
+// from before the previous window start are dropped so the sequence never
+// decreases. A nil tracker always returns initial.
+func (t *sequenceTracker) assign(key string, segments []segment, initial int64) ([]segment, int64) {
+	if t == nil || len(segments) == 0 {
+		return segments, initial
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	st := t.playlists[key]
+	if st == nil {
+		t.prune(now)
+		st = &sequenceState{}
+		t.playlists[key] = st
+	}
+
+	first := initial
+	if len(st.times) > 0 {
+		first = st.continueAt(segments)
+		if first < st.firstSeq {
+			drop := st.firstSeq - first
+			if drop >= int64(len(segments)) {
+				drop = int64(len(segments)) - 1
+			}
+			segments = segments[drop:]
+			first += drop
+		}
+	}
+
+	st.firstSeq = first
+	st.times = st.times[:0]
+	for _, seg := range segments {
+		st.times = append(st.times, seg.Time)
+	}
+	st.seen = now
+	return segments, first
+}
+
+// continueAt returns the media sequence of the first of segments that keeps
+// the numbering of the previous refresh.
+func (st *sequenceState) continueAt(segments []segment) int64 {
+	lastSeq := st.firstSeq + int64(len(st.times)) - 1
+
+	// Newest segment served before
+	for i := len(segments) - 1; i >= 0; i-- {
+		j := sort.Search(len(st.times), func(j int) bool { return st.times[j] >= segments[i].Time })
+		if j < len(st.times) && st.times[j] == segments[i].Time {
+			return st.firstSeq + int64(j) - int64(i)
+		}
+	}
+
+	// None: the window moved past the previous one, or the timeline
+	// restarted. New segments follow the last one served.
+	lastTime := st.times[len(st.times)-1]
+	i := sort.Search(len(segments), func(i int) bool { return segments[i].Time > lastTime })
+	if i == len(segments) {
+		i = 0
+	}
+	return lastSeq + 1 - int64(i)
+}
+
+// prune drops playlists idle for longer than sequenceIdle, and all of them
+// when too many are tracked. Caller must hold t.mu.
+func (t *sequenceTracker) prune(now time.Time) {
+	cutoff := now.Add(-sequenceIdle)
+	for key, st := range t.playlists {
+		if st.seen.Before(cutoff) {
+			delete(t.playlists, key)
+		}
+	}
+	if len(t.playlists) >= maxTrackedPlaylists {
+		clear(t.playlists)
+	}
+}
@@ -0,0 +1,105 @@
+package streams
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// timeline returns segments of the given duration starting at times from..to.
+func timeline(from, to, d int64) []segment {
+	var segments []segment
+	for t := from; t <= to; t += d {
+		segments = append(segments, segment{Time: t, DurationTS: int(d), Duration: float64(d)})
+	}
+	return segments
+}
+
+func TestSequenceTracker_Assign(t *testing.T) {
+	tracker := newSequenceTracker()
+
+	steps := []struct {
+		name      string
+		segments  []segment
+		initial   int64
+		wantFirst int64
+		wantLen   int
+	}{
+		{"first refresh", timeline(100, 190, 10), 10, 10, 10},
+		{"window slides", timeline(120, 210, 10), 12, 12, 10},
+		{"unchanged", timeline(120, 210, 10), 12, 12, 10},
+		// Longer segments would make time/duration go backwards
+		{"duration change", append(timeline(130, 210, 10), timeline(220, 260, 20)...), 6, 13, 12},
+		{"window grows backwards", timeline(100, 260, 10), 10, 13, 12},
+		{"window skipped ahead", timeline(400, 440, 10), 40, 25, 5},
+		{"timeline restarts", timeline(0, 30, 10), 0, 30, 4},
+	}
+	for _, step := range steps {
+		segments, first := tracker.assign("live.mpd#v1", step.segments, step.initial)
+		if first != step.wantFirst || len(segments) != step.wantLen {
+			t.Fatalf("%s: assign() = %d segments from %d, want %d from %d", step.name, len(segments), first, step.wantLen, step.wantFirst)
+		}
+	}
+
+	// Other playlists are numbered independently
+	if _, first := tracker.assign("live.mpd#a1", timeline(100, 190, 10), 10); first != 10 {
+		t.Errorf("other playlist starts at %d, want 10", first)
+	}
+
+	var nilTracker *sequenceTracker
+	if _, first := nilTracker.assign("live.mpd#v1", timeline(100, 190, 10), 10); first != 10 {
+		t.Errorf("nil tracker returned %d, want the initial 10", first)
+	}
+}
+
+func TestSequenceTracker_Prune(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tracker := newSequenceTracker()
+	tracker.now = func() time.Time { return now }
+
+	tracker.assign("old.mpd#v1", timeline(100, 190, 10), 10)
+	now = now.Add(sequenceIdle + time.Second)
+	tracker.assign("new.mpd#v1", timeline(100, 190, 10), 10)
+
+	if _, ok := tracker.playlists["old.mpd#v1"]; ok {
+		t.Error("idle playlist was not pruned")
+	}
+	if _, first := tracker.assign("old.mpd#v1", timeline(0, 90, 10), 0); first != 0 {
+		t.Errorf("pruned playlist continued at %d, want a fresh start at 0", first)
+	}
+}
+
+func TestMPDHandler_convertMediaPlaylist_SequenceAcrossRefreshes(t *testing.T) {
+	manifest := func(t0, d int64, r int) string {
+		return fmt.Sprintf(`<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="dynamic" timeShiftBufferDepth="PT40S">
+  <Period>
+    <AdaptationSet mimeType="video/mp4">
+      <SegmentTemplate timescale="1" media="$RepresentationID$/$Time$.m4s">
+        <SegmentTimeline><S t="%d" d="%d" r="%d"/></SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="v1" bandwidth="1000000"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`, t0, d, r)
+	}
+	h := &MPDHandler{sequences: newSequenceTracker()}
+
+	refresh := func(m string) string {
+		playlist, err := h.convertMediaPlaylist([]byte(m), "v1", "", "http://proxy", "https://cdn.example.com/live.mpd", nil, "")
+		if err != nil {
+			t.Fatalf("convertMediaPlaylist() error = %v", err)
+		}
+		return playlist
+	}
+
+	if p := refresh(manifest(400, 4, 9)); !strings.Contains(p, "#EXT-X-MEDIA-SEQUENCE:100") {
+		t.Fatalf("first refresh:\n%s", p)
+	}
+	// The origin switched to 8s segments: 416/8 would be sequence 52, and
+	// 432 keeps its 108 so the new 440 follows as 109
+	if p := refresh(manifest(416, 8, 4)); !strings.Contains(p, "#EXT-X-MEDIA-SEQUENCE:106") {
+		t.Errorf("sequence went backwards after a duration change:\n%s", p)
+	}
+}
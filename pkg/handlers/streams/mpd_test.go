@@ -448,6 +448,18 @@ func TestMPDHandler_getBaseURL(t *testing.T) {
 			originalURL: "https://example.com/stream/manifest.mpd?token=abc123",
 			expected:    "https://example.com/stream/",
 		},
+		{
+			name:        "relative MPD BaseURL",
+			mpd:         &MPD{BaseURLs: []string{"video/"}},
+			originalURL: "https://example.com/live/manifest.mpd?token=abc123",
+			expected:    "https://example.com/live/video/",
+		},
+		{
+			name:        "absolute path MPD BaseURL",
+			mpd:         &MPD{BaseURLs: []string{"/dash/"}},
+			originalURL: "https://example.com/live/manifest.mpd",
+			expected:    "https://example.com/dash/",
+		},
 	}
 
 	for _, tt := range tests {
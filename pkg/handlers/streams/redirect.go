@@ -0,0 +1,37 @@
+package streams
+
+import (
+	"context"
+	"net/http"
+
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/types"
+)
+
+// segmentContext stops the client from following upstream redirects of a
+// segment or stream when the player should follow them itself (redirect_stream
+// or the route's REDIRECT_STREAM). Manifests are always followed, since they
+// need rewriting.
+func segmentContext(ctx context.Context, client *httpclient.Client, req *types.StreamRequest) context.Context {
+	if req.RedirectStream || client.RedirectsToClient(req.URL) {
+		return httpclient.NoFollow(ctx)
+	}
+	return ctx
+}
+
+// redirectResponse returns an upstream redirect as a response redirecting the
+// client, or nil if resp isn't one.
+func redirectResponse(resp *http.Response) *types.StreamResponse {
+	if resp.StatusCode < 300 || resp.StatusCode > 399 || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	location, err := resp.Location()
+	if err != nil {
+		return nil
+	}
+	resp.Body.Close()
+	return &types.StreamResponse{
+		StatusCode:  resp.StatusCode,
+		RedirectURL: location.String(),
+	}
+}
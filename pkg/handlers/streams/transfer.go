@@ -0,0 +1,42 @@
+package streams
+
+import (
+	"net/http"
+	"strconv"
+
+	"media-proxy-go/pkg/httpclient"
+)
+
+// rangeHeaders are the upstream headers describing partial and seekable bodies.
+var rangeHeaders = []string{"Content-Range", "Accept-Ranges"}
+
+// cacheHeaders are the upstream caching headers, honored by the segment cache
+// and passed on to players.
+var cacheHeaders = []string{"Cache-Control", "Expires", "ETag", "Last-Modified"}
+
+// transferHeaders returns the length, range and caching headers of an upstream
+// segment or file, so players can size their buffers and downloads show progress.
+// Content-Length is omitted when unknown (chunked upstream, decoded bodies).
+func transferHeaders(resp *http.Response) map[string]string {
+	headers := make(map[string]string)
+	if resp.ContentLength >= 0 {
+		headers["Content-Length"] = strconv.FormatInt(resp.ContentLength, 10)
+	}
+	for _, name := range append(rangeHeaders, cacheHeaders...) {
+		if v := resp.Header.Get(name); v != "" {
+			headers[name] = v
+		}
+	}
+	return headers
+}
+
+// withForwarded adds the upstream headers the client's forwarding policy
+// passes on (FORWARD_RESPONSE_HEADERS) to headers, keeping the ones already set.
+func withForwarded(client *httpclient.Client, upstream http.Header, headers map[string]string) map[string]string {
+	for name, value := range client.ForwardedHeaders(upstream) {
+		if _, ok := headers[name]; !ok {
+			headers[name] = value
+		}
+	}
+	return headers
+}
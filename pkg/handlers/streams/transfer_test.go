@@ -0,0 +1,165 @@
+package streams
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+func TestHandleSegment_TransferHeaders(t *testing.T) {
+	segment := make([]byte, 64*1024)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chunked.ts":
+			// No Content-Length: flushing forces a chunked response
+			w.Write(segment[:1024])
+			w.(http.Flusher).Flush()
+			w.Write(segment[1024:])
+		default:
+			w.Header().Set("Content-Length", strconv.Itoa(len(segment)))
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Write(segment)
+		}
+	}))
+	defer upstream.Close()
+
+	log := logging.New("error", false, nil)
+	client := httpclient.New(&config.Config{}, log)
+	handlers := map[string]interfaces.StreamHandler{
+		"hls":     NewHLSHandler(client, log, "http://proxy"),
+		"mpd":     NewMPDHandler(client, log, "http://proxy", nil),
+		"generic": NewGenericHandler(client, log),
+	}
+
+	for name, h := range handlers {
+		t.Run(name, func(t *testing.T) {
+			resp, err := h.HandleSegment(t.Context(), &types.StreamRequest{URL: upstream.URL + "/seg1.ts"})
+			if err != nil {
+				t.Fatalf("HandleSegment() error = %v", err)
+			}
+			resp.Body.Close()
+			if got := resp.Headers["Content-Length"]; got != strconv.Itoa(len(segment)) {
+				t.Errorf("Content-Length = %q, want %d", got, len(segment))
+			}
+			if got := resp.Headers["Accept-Ranges"]; got != "bytes" {
+				t.Errorf("Accept-Ranges = %q, want bytes", got)
+			}
+
+			resp, err = h.HandleSegment(t.Context(), &types.StreamRequest{URL: upstream.URL + "/chunked.ts"})
+			if err != nil {
+				t.Fatalf("HandleSegment() error = %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if len(body) != len(segment) {
+				t.Errorf("read %d bytes, want %d", len(body), len(segment))
+			}
+			if got, ok := resp.Headers["Content-Length"]; ok {
+				t.Errorf("Content-Length = %q for a chunked upstream, want none", got)
+			}
+		})
+	}
+}
+
+func TestHandleSegment_RedirectStream(t *testing.T) {
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("segment"))
+	}))
+	defer cdn.Close()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, cdn.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer upstream.Close()
+
+	log := logging.New("error", false, nil)
+	h := NewGenericHandler(httpclient.New(&config.Config{}, log), log)
+
+	resp, err := h.HandleSegment(t.Context(), &types.StreamRequest{URL: upstream.URL + "/video.mp4", RedirectStream: true})
+	if err != nil {
+		t.Fatalf("HandleSegment() error = %v", err)
+	}
+	if resp.RedirectURL != cdn.URL+"/video.mp4" || resp.StatusCode != http.StatusFound {
+		t.Errorf("got redirect %d %q, want 302 %s/video.mp4", resp.StatusCode, resp.RedirectURL, cdn.URL)
+	}
+
+	// Without redirect_stream the redirect is followed
+	resp, err = h.HandleSegment(t.Context(), &types.StreamRequest{URL: upstream.URL + "/video.mp4"})
+	if err != nil {
+		t.Fatalf("HandleSegment() error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.RedirectURL != "" || string(body) != "segment" {
+		t.Errorf("got redirect %q body %q, want the followed segment", resp.RedirectURL, body)
+	}
+}
+
+func TestHandleManifest_SizeLimit(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("#EXTM3U\n"))
+		for i := 0; i < 1000; i++ {
+			w.Write([]byte("#EXTINF:4.0,\nseg.ts\n"))
+		}
+	}))
+	defer upstream.Close()
+
+	log := logging.New("error", false, nil)
+	client := httpclient.New(&config.Config{ManifestMaxSize: 1024}, log)
+	h := NewHLSHandler(client, log, "http://proxy")
+
+	_, err := h.HandleManifest(t.Context(), &types.StreamRequest{URL: upstream.URL + "/huge.m3u8"}, "http://proxy")
+	if !errors.Is(err, httpclient.ErrBodyTooLarge) {
+		t.Errorf("HandleManifest() error = %v, want ErrBodyTooLarge", err)
+	}
+}
+
+func TestHandleSegment_ForwardedHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Cache", "HIT from edge")
+		w.Header().Set("X-Served-By", "cache-fra1")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("Access-Control-Allow-Origin", "https://site.example")
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		w.Write([]byte("segment"))
+	}))
+	defer upstream.Close()
+
+	log := logging.New("error", false, nil)
+	cfg := &config.Config{ForwardResponseHeaders: []string{"x-cache", "X-Served-*", "Set-Cookie", "Access-Control-*"}}
+	client := httpclient.New(cfg, log)
+	handlers := map[string]interfaces.StreamHandler{
+		"hls":     NewHLSHandler(client, log, "http://proxy"),
+		"mpd":     NewMPDHandler(client, log, "http://proxy", nil),
+		"generic": NewGenericHandler(client, log),
+	}
+
+	for name, h := range handlers {
+		t.Run(name, func(t *testing.T) {
+			resp, err := h.HandleSegment(t.Context(), &types.StreamRequest{URL: upstream.URL + "/seg1.ts"})
+			if err != nil {
+				t.Fatalf("HandleSegment() error = %v", err)
+			}
+			resp.Body.Close()
+			if got := resp.Headers["X-Cache"]; got != "HIT from edge" {
+				t.Errorf("X-Cache = %q, want the upstream value", got)
+			}
+			if got := resp.Headers["X-Served-By"]; got != "cache-fra1" {
+				t.Errorf("X-Served-By = %q, want the upstream value", got)
+			}
+			for _, name := range []string{"Set-Cookie", "Access-Control-Allow-Origin", "Strict-Transport-Security"} {
+				if got, ok := resp.Headers[name]; ok {
+					t.Errorf("%s = %q forwarded, want it stripped", name, got)
+				}
+			}
+		})
+	}
+}
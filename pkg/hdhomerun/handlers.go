@@ -0,0 +1,254 @@
+// Package hdhomerun emulates an HDHomeRun network tuner so Plex, Jellyfin
+// and Emby can tune channels from the imported playlist through the proxy.
+package hdhomerun
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/appctx"
+	"media-proxy-go/pkg/channels"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+	"media-proxy-go/pkg/urlsign"
+)
+
+// Discovery is the discover.json response.
+type Discovery struct {
+	FriendlyName    string `json:"FriendlyName"`
+	Manufacturer    string `json:"Manufacturer"`
+	ModelNumber     string `json:"ModelNumber"`
+	FirmwareName    string `json:"FirmwareName"`
+	FirmwareVersion string `json:"FirmwareVersion"`
+	DeviceID        string `json:"DeviceID"`
+	DeviceAuth      string `json:"DeviceAuth"`
+	BaseURL         string `json:"BaseURL"`
+	LineupURL       string `json:"LineupURL"`
+	TunerCount      int    `json:"TunerCount"`
+}
+
+// LineupEntry is a channel in lineup.json.
+type LineupEntry struct {
+	GuideNumber string `json:"GuideNumber"`
+	GuideName   string `json:"GuideName"`
+	URL         string `json:"URL"`
+}
+
+// Handlers serves the HDHomeRun API.
+type Handlers struct {
+	ctx      *appctx.Context
+	log      *logging.Logger
+	deviceID string
+	tuners   chan struct{} // Semaphore limiting concurrent streams to the tuner count
+}
+
+// NewHandlers creates a new HDHomeRun Handlers instance.
+func NewHandlers(ctx *appctx.Context) *Handlers {
+	deviceID := ctx.Config.HDHomeRunDeviceID
+	if deviceID == "" {
+		// Stable per installation so Plex doesn't see a new device on every restart
+		sum := sha1.Sum([]byte(ctx.BaseURL))
+		deviceID = strings.ToUpper(hex.EncodeToString(sum[:4]))
+	}
+
+	tunerCount := ctx.Config.HDHomeRunTunerCount
+	if tunerCount <= 0 {
+		tunerCount = 1
+	}
+
+	return &Handlers{
+		ctx:      ctx,
+		log:      ctx.Log.WithComponent("hdhomerun"),
+		deviceID: deviceID,
+		tuners:   make(chan struct{}, tunerCount),
+	}
+}
+
+// RegisterRoutes registers all HDHomeRun routes.
+// Discovery routes are public (see middleware); tuner URLs carry the API password.
+func (h *Handlers) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /discover.json", h.handleDiscover)
+	mux.HandleFunc("GET /lineup_status.json", h.handleLineupStatus)
+	mux.HandleFunc("GET /lineup.json", h.handleLineup)
+	mux.HandleFunc("POST /lineup.post", h.handleLineupPost)
+	mux.HandleFunc("GET /device.xml", h.handleDeviceXML)
+	mux.HandleFunc("GET /auto/{channel}", h.handleStream)
+}
+
+// handleDiscover returns the device description.
+func (h *Handlers) handleDiscover(w http.ResponseWriter, r *http.Request) {
+	h.jsonResponse(w, Discovery{
+		FriendlyName:    h.ctx.Config.HDHomeRunFriendlyName,
+		Manufacturer:    "Silicondust",
+		ModelNumber:     "HDTC-2US",
+		FirmwareName:    "hdhomeruntc_atsc",
+		FirmwareVersion: "20200101",
+		DeviceID:        h.deviceID,
+		DeviceAuth:      "mediaproxy",
+		BaseURL:         h.ctx.BaseURL,
+		LineupURL:       h.ctx.BaseURL + "/lineup.json",
+		TunerCount:      cap(h.tuners),
+	})
+}
+
+// handleLineupStatus reports that no channel scan is in progress.
+func (h *Handlers) handleLineupStatus(w http.ResponseWriter, r *http.Request) {
+	h.jsonResponse(w, map[string]any{
+		"ScanInProgress": 0,
+		"ScanPossible":   1,
+		"Source":         "Cable",
+		"SourceList":     []string{"Cable"},
+	})
+}
+
+// handleLineup returns the channel lineup. Guide numbers follow playlist order.
+func (h *Handlers) handleLineup(w http.ResponseWriter, r *http.Request) {
+	list := h.ctx.Channels.List()
+	lineup := make([]LineupEntry, len(list))
+	for i, ch := range list {
+		number := strconv.Itoa(i + 1)
+		lineup[i] = LineupEntry{
+			GuideNumber: number,
+			GuideName:   ch.Name,
+			URL:         h.streamURL(number),
+		}
+	}
+	h.jsonResponse(w, lineup)
+}
+
+// handleLineupPost accepts channel scan requests; the lineup is always current.
+func (h *Handlers) handleLineupPost(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleDeviceXML returns the UPnP device description used by some clients.
+func (h *Handlers) handleDeviceXML(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+    <specVersion><major>1</major><minor>0</minor></specVersion>
+    <URLBase>%s</URLBase>
+    <device>
+        <deviceType>urn:schemas-upnp-org:device:MediaServer:1</deviceType>
+        <friendlyName>%s</friendlyName>
+        <manufacturer>Silicondust</manufacturer>
+        <modelName>HDTC-2US</modelName>
+        <modelNumber>HDTC-2US</modelNumber>
+        <serialNumber></serialNumber>
+        <UDN>uuid:%s</UDN>
+    </device>
+</root>`, xmlEscape(h.ctx.BaseURL), xmlEscape(h.ctx.Config.HDHomeRunFriendlyName), h.deviceID)
+}
+
+// handleStream tunes a channel (/auto/v<number>) and streams it as MPEG-TS.
+func (h *Handlers) handleStream(w http.ResponseWriter, r *http.Request) {
+	ch, ok := h.channelByNumber(strings.TrimPrefix(r.PathValue("channel"), "v"))
+	if !ok {
+		http.Error(w, "channel not found", http.StatusNotFound)
+		return
+	}
+
+	// Acquire a tuner
+	select {
+	case h.tuners <- struct{}{}:
+		defer func() { <-h.tuners }()
+	default:
+		h.log.Warn("all tuners busy", "channel", ch.Name)
+		http.Error(w, "all tuners in use", http.StatusServiceUnavailable)
+		return
+	}
+
+	// A signed link keeps the password out of the FFmpeg command line
+	sourceURL := urlsign.SignedURL(h.ctx.Config.StreamingPassword(), h.ctx.BaseURL, "/proxy/manifest.m3u8", channels.ProxyQuery(ch, nil))
+	h.log.Info("tuning channel", "channel", ch.Name, "remote", r.RemoteAddr)
+
+	// Remux to MPEG-TS: media servers expect a raw transport stream from a tuner
+	cmd := exec.CommandContext(r.Context(), h.ctx.Config.FFmpegPath,
+		"-hide_banner",
+		"-loglevel", "error",
+		"-fflags", "+genpts+discardcorrupt",
+		"-i", sourceURL,
+		"-map", "0:v:0?",
+		"-map", "0:a?",
+		"-c", "copy",
+		"-f", "mpegts",
+		"pipe:1",
+	)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		http.Error(w, "failed to start stream", http.StatusInternalServerError)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		h.log.Error("❌ failed to start FFmpeg", "error", err)
+		http.Error(w, "failed to start stream", http.StatusInternalServerError)
+		return
+	}
+
+	// Live streams outlast the server write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		h.log.Debug("failed to clear write deadline", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "video/MP2T")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(flushWriter{w}, stdout); err != nil {
+		h.log.Debug("stream client disconnected", "channel", ch.Name, "error", err)
+	}
+	cmd.Wait()
+
+	h.log.Info("tuner released", "channel", ch.Name)
+}
+
+// channelByNumber returns the channel with the given guide number.
+func (h *Handlers) channelByNumber(number string) (types.Channel, bool) {
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 {
+		return types.Channel{}, false
+	}
+	list := h.ctx.Channels.List()
+	if n > len(list) {
+		return types.Channel{}, false
+	}
+	return list[n-1], true
+}
+
+// streamURL returns the tuner URL for a guide number. The lineup is public,
+// so the URL is signed for its path instead of carrying the password.
+func (h *Handlers) streamURL(number string) string {
+	return urlsign.SignedURL(h.ctx.Config.StreamingPassword(), h.ctx.BaseURL, "/auto/v"+number, nil)
+}
+
+// jsonResponse writes a JSON response.
+func (h *Handlers) jsonResponse(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// flushWriter flushes after every write so live data reaches the client immediately.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if f, ok := fw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
+
+// xmlEscape escapes a string for use in XML text.
+func xmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
@@ -0,0 +1,109 @@
+package hdhomerun
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/appctx"
+	"media-proxy-go/pkg/channels"
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+	"media-proxy-go/pkg/urlsign"
+)
+
+func newTestHandlers() *Handlers {
+	log := logging.New("error", false, io.Discard)
+	cfg := &config.Config{
+		APIPassword:           "secret123",
+		BaseURL:               "http://localhost:7860",
+		HDHomeRunFriendlyName: "MediaProxy",
+		HDHomeRunTunerCount:   2,
+	}
+	ctx := appctx.New(cfg, log)
+
+	store := channels.NewStore("", nil, log)
+	store.Set([]types.Channel{
+		{Name: "News", URL: "https://cdn.example.com/news.m3u8"},
+		{Name: "Sport", URL: "https://cdn.example.com/sport.m3u8"},
+	})
+	ctx.WithChannels(store)
+
+	return NewHandlers(ctx)
+}
+
+func TestHandlers_Discover(t *testing.T) {
+	h := newTestHandlers()
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/discover.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var discovery Discovery
+	if err := json.NewDecoder(rec.Body).Decode(&discovery); err != nil {
+		t.Fatalf("failed to decode discover.json: %v", err)
+	}
+	if discovery.TunerCount != 2 {
+		t.Errorf("TunerCount = %d, want 2", discovery.TunerCount)
+	}
+	if discovery.DeviceID == "" || discovery.DeviceID != NewHandlers(h.ctx).deviceID {
+		t.Errorf("DeviceID = %q, want stable non-empty ID", discovery.DeviceID)
+	}
+	if discovery.LineupURL != "http://localhost:7860/lineup.json" {
+		t.Errorf("LineupURL = %q", discovery.LineupURL)
+	}
+}
+
+func TestHandlers_Lineup(t *testing.T) {
+	h := newTestHandlers()
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/lineup.json", nil))
+
+	var lineup []LineupEntry
+	if err := json.NewDecoder(rec.Body).Decode(&lineup); err != nil {
+		t.Fatalf("failed to decode lineup.json: %v", err)
+	}
+	if len(lineup) != 2 {
+		t.Fatalf("len(lineup) = %d, want 2", len(lineup))
+	}
+	if lineup[1].GuideNumber != "2" || lineup[1].GuideName != "Sport" {
+		t.Errorf("lineup[1] = %+v", lineup[1])
+	}
+	// The public lineup hands out links signed for the tuner, not the password
+	u, err := url.Parse(lineup[1].URL)
+	if err != nil || u.Host != "localhost:7860" || u.Path != "/auto/v2" || u.Query().Has("api_password") {
+		t.Fatalf("lineup[1].URL = %q", lineup[1].URL)
+	}
+	if err := urlsign.Verify("secret123", u.Query(), "/auto/v2", "", time.Now()); err != nil {
+		t.Errorf("lineup[1].URL signature: %v", err)
+	}
+	if err := urlsign.Verify("secret123", u.Query(), "/auto/v1", "", time.Now()); err == nil {
+		t.Error("lineup[1].URL accepted for another tuner")
+	}
+}
+
+func TestHandlers_StreamUnknownChannel(t *testing.T) {
+	h := newTestHandlers()
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+
+	for _, path := range []string{"/auto/v0", "/auto/v3", "/auto/vabc"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s status = %d, want 404", path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// Package health periodically checks the saved channel list so dead links in
+// large playlists are found without anyone having to play them.
+//
+// A check requests the channel's manifest through the proxy service, so
+// extractors, headers and transport routes apply exactly as for playback,
+// and reads the first byte of the (rewritten) response.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+	"time"
+
+	"media-proxy-go/pkg/channels"
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+// ManifestFetcher fetches a stream's manifest; implemented by services.ProxyService.
+type ManifestFetcher interface {
+	HandleManifest(ctx context.Context, req *types.StreamRequest) (*types.StreamResponse, error)
+}
+
+// Monitor checks channels in the background and keeps their recent history.
+type Monitor struct {
+	channels      *channels.Store
+	fetcher       ManifestFetcher
+	log           *logging.Logger
+	interval      time.Duration
+	timeout       time.Duration
+	concurrency   int
+	favoritesOnly bool
+	historySize   int
+	now           func() time.Time
+
+	mu      sync.Mutex
+	results map[string]*types.ChannelHealth // By channel ID
+	checkMu sync.Mutex                      // Serializes CheckAll runs
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewMonitor creates a monitor for the channels in store. Call Start to begin
+// periodic checks.
+func NewMonitor(cfg *config.Config, store *channels.Store, fetcher ManifestFetcher, log *logging.Logger) *Monitor {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Monitor{
+		channels:      store,
+		fetcher:       fetcher,
+		log:           log.WithComponent("health"),
+		interval:      cfg.HealthCheckInterval,
+		timeout:       cfg.HealthCheckTimeout,
+		concurrency:   max(cfg.HealthCheckConcurrency, 1),
+		favoritesOnly: cfg.HealthCheckFavorites,
+		historySize:   max(cfg.HealthCheckHistory, 1),
+		now:           time.Now,
+		results:       make(map[string]*types.ChannelHealth),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// Start checks all channels now and then every interval until Close.
+func (m *Monitor) Start() {
+	m.wg.Add(1)
+	go m.loop()
+}
+
+// loop runs CheckAll on the monitor's interval.
+func (m *Monitor) loop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.CheckAll(m.ctx)
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops the background checks and waits for a running pass to finish.
+func (m *Monitor) Close() error {
+	m.cancel()
+	m.wg.Wait()
+	return nil
+}
+
+// Trigger runs a check of all channels in the background, unless one is
+// already running. It returns false if a check was already in progress.
+func (m *Monitor) Trigger() bool {
+	if !m.checkMu.TryLock() {
+		return false
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer m.checkMu.Unlock()
+		m.checkAll(m.ctx)
+	}()
+	return true
+}
+
+// CheckAll checks every monitored channel, at most concurrency at a time.
+func (m *Monitor) CheckAll(ctx context.Context) {
+	m.checkMu.Lock()
+	defer m.checkMu.Unlock()
+	m.checkAll(ctx)
+}
+
+// checkAll does the work of CheckAll; the caller holds checkMu.
+func (m *Monitor) checkAll(ctx context.Context) {
+	targets := m.targets()
+	m.prune(targets)
+
+	start := m.now()
+	sem := make(chan struct{}, m.concurrency)
+	var wg sync.WaitGroup
+	for _, ch := range targets {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			m.record(ch, m.check(ctx, ch))
+		}()
+	}
+	wg.Wait()
+
+	m.log.Debug("channel health check finished", "channels", len(targets), "duration", m.now().Sub(start))
+}
+
+// check requests ch's manifest and reports whether any data came back.
+func (m *Monitor) check(ctx context.Context, ch types.Channel) types.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	start := m.now()
+	result := types.HealthCheck{At: start.Unix()}
+
+	err := m.fetch(ctx, ch)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", m.timeout)
+		}
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Up = true
+	result.Latency = m.now().Sub(start).Milliseconds()
+	return result
+}
+
+// fetch requests ch's manifest and reads its first byte.
+func (m *Monitor) fetch(ctx context.Context, ch types.Channel) error {
+	// HandleManifest adds extractor headers to the request's map
+	req := &types.StreamRequest{URL: ch.URL, Headers: maps.Clone(ch.Headers), ClearKey: ch.ClearKey}
+	if req.Headers == nil {
+		req.Headers = make(map[string]string)
+	}
+
+	resp, err := m.fetcher.HandleManifest(ctx, req)
+	if err != nil {
+		return err
+	}
+	if resp.Body != nil {
+		defer resp.Body.Close()
+	}
+
+	switch {
+	case resp.RedirectURL != "":
+		return nil
+	case resp.StatusCode >= 400:
+		return fmt.Errorf("upstream returned HTTP %d", resp.StatusCode)
+	case resp.Body == nil:
+		return errors.New("empty response")
+	}
+
+	if _, err := io.ReadFull(resp.Body, make([]byte, 1)); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("empty response")
+		}
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	return nil
+}
+
+// record adds a check result to ch's history.
+func (m *Monitor) record(ch types.Channel, check types.HealthCheck) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.results[ch.ID]
+	if !ok {
+		h = &types.ChannelHealth{ID: ch.ID, Status: types.HealthStatusUnknown}
+		m.results[ch.ID] = h
+	}
+	previous := h.Status
+
+	h.Name = ch.Name
+	h.LastCheck = check.At
+	h.History = append(h.History, check)
+	if len(h.History) > m.historySize {
+		h.History = append([]types.HealthCheck(nil), h.History[len(h.History)-m.historySize:]...)
+	}
+
+	if check.Up {
+		h.Status = types.HealthStatusUp
+		h.Latency = check.Latency
+		h.LastError = ""
+		h.Failures = 0
+	} else {
+		h.Status = types.HealthStatusDown
+		h.LastError = check.Error
+		h.Failures++
+	}
+
+	up := 0
+	for _, c := range h.History {
+		if c.Up {
+			up++
+		}
+	}
+	h.Uptime = float64(up) * 100 / float64(len(h.History))
+
+	switch {
+	case h.Status == types.HealthStatusDown && previous != types.HealthStatusDown:
+		m.log.Warn("channel is down", "id", ch.ID, "name", ch.Name, "error", check.Error)
+	case h.Status == types.HealthStatusUp && previous == types.HealthStatusDown:
+		m.log.Info("channel is back up", "id", ch.ID, "name", ch.Name)
+	}
+}
+
+// targets returns the channels to check.
+func (m *Monitor) targets() []types.Channel {
+	var targets []types.Channel
+	for _, ch := range m.channels.List() {
+		if m.favoritesOnly && !ch.Favorite {
+			continue
+		}
+		targets = append(targets, ch)
+	}
+	return targets
+}
+
+// prune forgets the history of channels that are no longer monitored.
+func (m *Monitor) prune(targets []types.Channel) {
+	keep := make(map[string]bool, len(targets))
+	for _, ch := range targets {
+		keep[ch.ID] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id := range m.results {
+		if !keep[id] {
+			delete(m.results, id)
+		}
+	}
+}
+
+// List returns the health of every monitored channel in playlist order.
+// Channels not checked yet have status "unknown".
+func (m *Monitor) List() []types.ChannelHealth {
+	targets := m.targets()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]types.ChannelHealth, 0, len(targets))
+	for _, ch := range targets {
+		list = append(list, m.snapshot(ch))
+	}
+	return list
+}
+
+// Get returns the health of a monitored channel.
+func (m *Monitor) Get(id string) (types.ChannelHealth, bool) {
+	ch, ok := m.channels.Get(id)
+	if !ok || (m.favoritesOnly && !ch.Favorite) {
+		return types.ChannelHealth{}, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.snapshot(ch), true
+}
+
+// snapshot copies ch's health; the caller holds mu.
+func (m *Monitor) snapshot(ch types.Channel) types.ChannelHealth {
+	h, ok := m.results[ch.ID]
+	if !ok {
+		return types.ChannelHealth{ID: ch.ID, Name: ch.Name, Status: types.HealthStatusUnknown, History: []types.HealthCheck{}}
+	}
+	snap := *h
+	snap.Name = ch.Name
+	snap.History = append([]types.HealthCheck{}, h.History...)
+	return snap
+}
@@ -0,0 +1,103 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/channels"
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+// stubFetcher answers manifest requests by URL.
+type stubFetcher map[string]func() (*types.StreamResponse, error)
+
+func (f stubFetcher) HandleManifest(ctx context.Context, req *types.StreamRequest) (*types.StreamResponse, error) {
+	req.Headers["X-Extracted"] = "1" // Must not leak into the stored channel
+	return f[req.URL]()
+}
+
+func ok(body string) func() (*types.StreamResponse, error) {
+	return func() (*types.StreamResponse, error) {
+		return &types.StreamResponse{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}
+}
+
+func TestMonitor_CheckAll(t *testing.T) {
+	log := logging.New("debug", false, io.Discard)
+	store := channels.NewStore("", nil, log)
+	store.Set([]types.Channel{
+		{ID: "up", Name: "Up", URL: "https://cdn.example.com/up.m3u8", Headers: map[string]string{"Referer": "https://example.com/"}, Favorite: true},
+		{ID: "forbidden", Name: "Forbidden", URL: "https://cdn.example.com/403.m3u8"},
+		{ID: "empty", Name: "Empty", URL: "https://cdn.example.com/empty.m3u8"},
+		{ID: "failed", Name: "Failed", URL: "https://cdn.example.com/failed.m3u8"},
+	})
+
+	up := ok("#EXTM3U\n")
+	fetcher := stubFetcher{
+		"https://cdn.example.com/up.m3u8": func() (*types.StreamResponse, error) { return up() },
+		"https://cdn.example.com/403.m3u8": func() (*types.StreamResponse, error) {
+			return &types.StreamResponse{StatusCode: http.StatusForbidden, Body: io.NopCloser(strings.NewReader("denied"))}, nil
+		},
+		"https://cdn.example.com/empty.m3u8":  ok(""),
+		"https://cdn.example.com/failed.m3u8": func() (*types.StreamResponse, error) { return nil, errors.New("extraction failed") },
+	}
+
+	cfg := &config.Config{HealthCheckTimeout: 5 * time.Second, HealthCheckConcurrency: 2, HealthCheckHistory: 2}
+	m := NewMonitor(cfg, store, fetcher, log)
+
+	list := m.List()
+	if len(list) != 4 || list[0].Status != types.HealthStatusUnknown {
+		t.Fatalf("List() before check = %+v, want 4 unknown channels", list)
+	}
+
+	m.CheckAll(context.Background())
+
+	want := map[string]string{
+		"up":        types.HealthStatusUp,
+		"forbidden": types.HealthStatusDown,
+		"empty":     types.HealthStatusDown,
+		"failed":    types.HealthStatusDown,
+	}
+	for _, h := range m.List() {
+		if h.Status != want[h.ID] {
+			t.Errorf("%s: Status = %q, want %q (error %q)", h.ID, h.Status, want[h.ID], h.LastError)
+		}
+	}
+	if h, _ := m.Get("forbidden"); h.LastError != "upstream returned HTTP 403" || h.Failures != 1 {
+		t.Errorf("forbidden = %+v", h)
+	}
+	if ch, _ := store.Get("up"); len(ch.Headers) != 1 {
+		t.Errorf("channel headers modified: %v", ch.Headers)
+	}
+
+	// The history is capped and uptime follows it
+	up = func() (*types.StreamResponse, error) { return nil, errors.New("connection refused") }
+	m.CheckAll(context.Background())
+	m.CheckAll(context.Background())
+	h, found := m.Get("up")
+	if !found {
+		t.Fatal("Get(up) not found")
+	}
+	if h.Status != types.HealthStatusDown || h.Failures != 2 || len(h.History) != 2 || h.Uptime != 0 {
+		t.Errorf("up after failures = %+v", h)
+	}
+
+	// Removed channels are forgotten, favorites-only ignores the rest
+	store.Set([]types.Channel{{ID: "up", Name: "Up", URL: "https://cdn.example.com/up.m3u8", Favorite: true}, {ID: "new", URL: "https://cdn.example.com/new.m3u8"}})
+	m.favoritesOnly = true
+	up = ok("#EXTM3U\n")
+	m.CheckAll(context.Background())
+	if list := m.List(); len(list) != 1 || list[0].ID != "up" || list[0].Uptime != 50 {
+		t.Errorf("List() = %+v, want only up with 50%% uptime", list)
+	}
+	if _, found := m.Get("new"); found {
+		t.Error("Get(new) found a non-favorite channel")
+	}
+}
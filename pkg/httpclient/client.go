@@ -9,12 +9,15 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"path"
 	"strings"
 	"sync"
 	"time"
 
 	"media-proxy-go/pkg/config"
 	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/throttle"
+	"media-proxy-go/pkg/tracing"
 
 	utls "github.com/refraction-networking/utls"
 	"golang.org/x/net/http2"
@@ -23,13 +26,20 @@ import (
 
 // Client wraps http.Client with proxy routing and connection pooling.
 type Client struct {
-	defaultClient *http.Client
-	utlsClient    *http.Client // Client with browser-like TLS fingerprint for Cloudflare bypass
-	proxyClients  map[string]*http.Client
-	routes        []config.TransportRoute
-	globalProxies []string
-	mu            sync.RWMutex
-	log           *logging.Logger
+	defaultClient  *http.Client
+	utlsClient     *http.Client // Client with browser-like TLS fingerprint for Cloudflare bypass
+	proxyClients   map[string]*http.Client
+	routes         []config.TransportRoute
+	routeLimiters  []*throttle.Limiter // By route index, nil for unpaced routes
+	outbound       *throttle.Limiter   // Budget of ClassPage requests, nil if unlimited
+	hosts          *throttle.Group     // ClassPage requests by host, nil if unpaced
+	userAgents     *userAgents         // User-Agent profile in use
+	globalProxies  []string
+	cookies        http.CookieJar // Shared extractor cookie jar, may be nil
+	forwardHeaders []string       // Upstream response headers passed on to clients
+	limits         [3]limit       // Size and time limits by Class
+	mu             sync.RWMutex
+	log            *logging.Logger
 }
 
 // Domains that require browser-like TLS fingerprinting (Cloudflare protected)
@@ -60,10 +70,15 @@ func ipv4DialContext(ctx context.Context, network, addr string) (net.Conn, error
 // New creates a new HTTP client with the given configuration.
 func New(cfg *config.Config, log *logging.Logger) *Client {
 	c := &Client{
-		proxyClients:  make(map[string]*http.Client),
-		routes:        cfg.TransportRoutes,
-		globalProxies: cfg.GlobalProxies,
-		log:           log.WithComponent("httpclient"),
+		proxyClients:   make(map[string]*http.Client),
+		routes:         cfg.TransportRoutes,
+		routeLimiters:  routeLimiters(cfg.TransportRoutes),
+		outbound:       throttle.New(throttle.Limit{Concurrency: cfg.OutboundMaxInFlight}),
+		hosts:          throttle.NewGroup(hostLimit(cfg)),
+		globalProxies:  cfg.GlobalProxies,
+		forwardHeaders: cfg.ForwardResponseHeaders,
+		limits:         classLimits(cfg),
+		log:            log.WithComponent("httpclient"),
 	}
 
 	// Default client with connection pooling (IPv4 only)
@@ -77,9 +92,17 @@ func New(cfg *config.Config, log *logging.Logger) *Client {
 			ExpectContinueTimeout: 1 * time.Second,
 			ResponseHeaderTimeout: 30 * time.Second,
 		},
-		Timeout: 30 * time.Second,
+		Timeout:       30 * time.Second,
+		CheckRedirect: checkRedirect,
 	}
 
+	userAgents, err := newUserAgents(cfg.UAProfile, cfg.UARotate)
+	if err != nil {
+		c.log.Warn("⚠️ invalid UA_PROFILE, using the default one", "error", err)
+		userAgents, _ = newUserAgents(DefaultUAProfile, cfg.UARotate)
+	}
+	c.userAgents = userAgents
+
 	// Create utls client with browser-like TLS fingerprint for Cloudflare bypass
 	c.utlsClient = c.createUTLSClient()
 
@@ -90,8 +113,9 @@ func New(cfg *config.Config, log *logging.Logger) *Client {
 func (c *Client) createUTLSClient() *http.Client {
 	// Use HTTP/2 transport with utls for Cloudflare bypass
 	return &http.Client{
-		Transport: newUTLSRoundTripper(),
-		Timeout:   30 * time.Second,
+		Transport:     newUTLSRoundTripper(),
+		Timeout:       30 * time.Second,
+		CheckRedirect: checkRedirect,
 	}
 }
 
@@ -139,8 +163,8 @@ func (t *utlsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error)
 		ServerName: host,
 	}
 
-	// Use Chrome 120 fingerprint with HTTP/2
-	utlsConn := utls.UClient(conn, tlsConfig, utls.HelloChrome_120)
+	// Use the fingerprint of the browser the User-Agent announces, with HTTP/2
+	utlsConn := utls.UClient(conn, tlsConfig, helloFor(req.Header.Get("User-Agent")))
 
 	// Perform TLS handshake
 	if err := utlsConn.Handshake(); err != nil {
@@ -205,10 +229,85 @@ func (c *Client) needsUTLS(targetURL string) bool {
 	return false
 }
 
+// SetCookieJar sets the cookie jar shared by the extractors using this client.
+func (c *Client) SetCookieJar(jar http.CookieJar) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cookies = jar
+}
+
+// CookieJar returns the shared extractor cookie jar, or nil if none is set.
+func (c *Client) CookieJar() http.CookieJar {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cookies
+}
+
 // Do executes an HTTP request, routing through proxies as configured.
+// Compressed response bodies are decoded transparently.
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx, span := tracing.StartClient(req.Context(), req.Method+" "+req.URL.Host)
+	if span != nil {
+		span.SetAttr("server.address", req.URL.Host)
+		span.SetAttr("url.path", req.URL.Path)
+		req = req.WithContext(ctx)
+	}
+
+	release, err := c.acquireRoute(req.Context(), req.URL.String())
+	if err != nil {
+		span.SetError(err)
+		span.End()
+		return nil, err
+	}
+
 	client := c.getClientForURL(req.URL.String())
-	return client.Do(req)
+	resp, err := client.Do(c.withRedirectPolicy(c.withUserAgent(c.withRouteHeaders(req))))
+	if err != nil {
+		if release != nil {
+			release()
+		}
+		span.SetError(err)
+		span.End()
+		return nil, err
+	}
+	if err := decodeBody(resp); err != nil {
+		resp.Body.Close()
+		if release != nil {
+			release()
+		}
+		span.SetError(err)
+		span.End()
+		return nil, err
+	}
+	if release != nil {
+		resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	}
+	if span != nil {
+		span.AddEvent("response headers")
+		span.SetAttr("http.response.status_code", resp.StatusCode)
+		resp.Body = &tracedBody{ReadCloser: resp.Body, span: span}
+	}
+	return resp, nil
+}
+
+// tracedBody ends an upstream request's span once its body is closed, so the
+// span covers the whole transfer.
+type tracedBody struct {
+	io.ReadCloser
+	span *tracing.Span
+	n    int64
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	b.span.SetAttr("http.response.body.size", b.n)
+	b.span.End()
+	return b.ReadCloser.Close()
 }
 
 // DoWithContext executes an HTTP request with context.
@@ -224,37 +323,73 @@ func (c *Client) getClientForURL(targetURL string) *http.Client {
 		return c.utlsClient
 	}
 
-	// Check transport routes first (most specific)
-	for _, route := range c.routes {
-		if strings.Contains(targetURL, route.URLPattern) {
-			c.log.Debug("matched transport route", "url", targetURL, "pattern", route.URLPattern, "proxy", route.Proxy, "direct", route.Direct)
+	route, proxyURL, insecure := c.transportFor(targetURL)
+	if route != nil {
+		c.log.Debug("matched transport route", "url", targetURL, "pattern", route.URLPattern, "match", route.Match, "proxy", route.Proxy, "direct", route.Direct)
+	} else if proxyURL != "" {
+		c.log.Debug("using global proxy", "url", targetURL, "proxy", proxyURL)
+	}
 
-			// Direct connection - bypass global proxy
-			if route.Direct {
-				if route.DisableSSL {
-					return c.getInsecureClient()
-				}
-				return c.defaultClient
-			}
+	if proxyURL != "" {
+		return c.getOrCreateProxyClient(proxyURL, insecure)
+	}
+	if insecure {
+		return c.getInsecureClient()
+	}
+	return c.defaultClient
+}
 
-			if route.Proxy != "" {
-				return c.getOrCreateProxyClient(route.Proxy, route.DisableSSL)
-			}
-			if route.DisableSSL {
-				return c.getInsecureClient()
-			}
+// transportFor decides how requests to targetURL are sent: the transport
+// route deciding it (nil for the global default), the proxy URL (empty for a
+// direct connection) and whether TLS verification is disabled.
+func (c *Client) transportFor(targetURL string) (route *config.TransportRoute, proxyURL string, insecure bool) {
+	// Check transport routes first (most specific). Routes without a proxy,
+	// DIRECT or DISABLE_SSL leave the choice to the following routes.
+	for i := range c.routes {
+		route := &c.routes[i]
+		if !routeMatches(route, targetURL) {
+			continue
+		}
+		switch {
+		case route.Direct:
+			// Direct connection - bypass global proxy
+			return route, "", route.DisableSSL
+		case route.Proxy != "":
+			return route, route.Proxy, route.DisableSSL
+		case route.DisableSSL:
+			return route, "", true
 		}
 	}
 
 	// Use global proxy if configured
 	if len(c.globalProxies) > 0 {
 		// Use first global proxy (could implement round-robin or failover later)
-		proxyURL := c.globalProxies[0]
-		c.log.Debug("using global proxy", "url", targetURL, "proxy", proxyURL)
-		return c.getOrCreateProxyClient(proxyURL, false)
+		return nil, c.globalProxies[0], false
 	}
+	return nil, "", false
+}
 
-	return c.defaultClient
+// routeMatches reports whether targetURL matches a transport route.
+func routeMatches(route *config.TransportRoute, targetURL string) bool {
+	switch route.Match {
+	case config.RouteMatchHost, config.RouteMatchPath:
+		u, err := url.Parse(targetURL)
+		if err != nil {
+			return false
+		}
+		if route.Match == config.RouteMatchHost {
+			ok, _ := path.Match(route.URLPattern, strings.ToLower(u.Hostname()))
+			return ok
+		}
+		if strings.HasPrefix(route.URLPattern, "/") {
+			return strings.HasPrefix(u.EscapedPath(), route.URLPattern)
+		}
+		return strings.HasPrefix(strings.ToLower(u.Host)+u.EscapedPath(), route.URLPattern)
+	case config.RouteMatchRegex:
+		return route.Regexp != nil && route.Regexp.MatchString(targetURL)
+	default:
+		return strings.Contains(targetURL, route.URLPattern)
+	}
 }
 
 // getOrCreateProxyClient returns a cached proxy client or creates a new one.
@@ -304,8 +439,9 @@ func (c *Client) createProxyClient(proxyURL string, disableSSL bool) *http.Clien
 	// If no proxy URL, just return client with transport (possibly with SSL disabled)
 	if proxyURL == "" {
 		return &http.Client{
-			Transport: transport,
-			Timeout:   30 * time.Second,
+			Transport:     transport,
+			Timeout:       30 * time.Second,
+			CheckRedirect: checkRedirect,
 		}
 	}
 
@@ -335,8 +471,9 @@ func (c *Client) createProxyClient(proxyURL string, disableSSL bool) *http.Clien
 	}
 
 	return &http.Client{
-		Transport: transport,
-		Timeout:   30 * time.Second,
+		Transport:     transport,
+		Timeout:       30 * time.Second,
+		CheckRedirect: checkRedirect,
 	}
 }
 
@@ -368,13 +505,18 @@ func FilteredHeaders(headers http.Header) http.Header {
 }
 
 // ParseHeaderParams extracts headers from query parameters with h_ prefix.
-// It converts underscores to hyphens in header names (e.g., h_User_Agent -> User-Agent).
-func ParseHeaderParams(query url.Values) map[string]string {
+// It converts underscores to hyphens in header names (e.g., h_User_Agent -> User-Agent)
+// and canonicalizes them. Headers that are not allowed (see AllowedHeader,
+// allow extends the allowlist) or have invalid values are dropped.
+func ParseHeaderParams(query url.Values, allow ...string) map[string]string {
 	headers := make(map[string]string)
 	for key, values := range query {
 		if strings.HasPrefix(key, "h_") && len(values) > 0 {
 			// Remove h_ prefix and convert underscores to hyphens
-			headerName := strings.ReplaceAll(key[2:], "_", "-")
+			headerName := http.CanonicalHeaderKey(strings.ReplaceAll(key[2:], "_", "-"))
+			if !AllowedHeader(headerName, allow) || !validHeaderValue(values[0]) {
+				continue
+			}
 			headers[headerName] = values[0]
 		}
 	}
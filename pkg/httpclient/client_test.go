@@ -12,6 +12,7 @@ func TestParseHeaderParams(t *testing.T) {
 	tests := []struct {
 		name     string
 		query    url.Values
+		allow    []string
 		expected map[string]string
 	}{
 		{
@@ -74,26 +75,74 @@ func TestParseHeaderParams(t *testing.T) {
 		{
 			name: "empty value",
 			query: url.Values{
-				"h_Empty": []string{""},
+				"h_Origin": []string{""},
 			},
 			expected: map[string]string{
-				"Empty": "",
+				"Origin": "",
 			},
 		},
 		{
 			name: "only first value used",
 			query: url.Values{
-				"h_Multi": []string{"first", "second", "third"},
+				"h_Referer": []string{"first", "second", "third"},
 			},
 			expected: map[string]string{
-				"Multi": "first",
+				"Referer": "first",
+			},
+		},
+		{
+			name: "canonical casing",
+			query: url.Values{
+				"h_user_agent": []string{"Mozilla/5.0"},
+			},
+			expected: map[string]string{
+				"User-Agent": "Mozilla/5.0",
+			},
+		},
+		{
+			name: "drops headers outside the allowlist",
+			query: url.Values{
+				"h_Host":                []string{"evil.example"},
+				"h_Content_Length":      []string{"0"},
+				"h_Transfer_Encoding":   []string{"chunked"},
+				"h_Proxy_Authorization": []string{"Basic abc"},
+				"h_Foo":                 []string{"bar"},
+				"h_Referer":             []string{"https://example.com"},
+			},
+			expected: map[string]string{
+				"Referer": "https://example.com",
+			},
+		},
+		{
+			name: "drops dangerous values",
+			query: url.Values{
+				"h_Referer": []string{"https://example.com\r\nHost: evil.example"},
+				"h_Cookie":  []string{"a=1\x00"},
+				"h_Origin":  []string{"https://example.com"},
+			},
+			expected: map[string]string{
+				"Origin": "https://example.com",
+			},
+		},
+		{
+			name: "configured allowlist",
+			query: url.Values{
+				"h_Foo":       []string{"bar"},
+				"h_Cdn_Token": []string{"abc"},
+				"h_Host":      []string{"evil.example"},
+				"h_Other":     []string{"dropped"},
+			},
+			allow: []string{"foo", "Cdn-*", "Host"},
+			expected: map[string]string{
+				"Foo":       "bar",
+				"Cdn-Token": "abc",
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := ParseHeaderParams(tt.query)
+			result := ParseHeaderParams(tt.query, tt.allow...)
 
 			if len(result) != len(tt.expected) {
 				t.Errorf("got %d headers, want %d", len(result), len(tt.expected))
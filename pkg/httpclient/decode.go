@@ -0,0 +1,85 @@
+package httpclient
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// AcceptEncoding is the Accept-Encoding sent for compressible upstream
+// responses (manifests, pages); Do decodes all of them.
+const AcceptEncoding = "gzip, deflate, br"
+
+// decodeBody replaces a compressed response body with a decoding reader and
+// drops the Content-Encoding and Content-Length headers, so callers always
+// see the identity body. Go only decodes gzip, and only when it set
+// Accept-Encoding itself; headers forwarded from players or extractors and the
+// utls HTTP/1.1 path get the raw bytes otherwise.
+func decodeBody(resp *http.Response) error {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" || resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+
+	var decoded io.Reader
+	switch encoding {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			if err == io.EOF {
+				// Empty body (e.g. 304 or HEAD) carrying the encoding header
+				break
+			}
+			return fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		decoded = gz
+	case "deflate":
+		decoded = newDeflateReader(resp.Body)
+	case "br":
+		decoded = brotli.NewReader(resp.Body)
+	default:
+		return nil // Unknown encoding: pass the body through untouched
+	}
+
+	if decoded != nil {
+		resp.Body = &decodedBody{Reader: decoded, body: resp.Body}
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// newDeflateReader reads "deflate" bodies, which servers send either
+// zlib-wrapped (as specified) or as raw DEFLATE.
+func newDeflateReader(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		if zr, err := zlib.NewReader(br); err == nil {
+			return zr
+		}
+	}
+	return flate.NewReader(br)
+}
+
+// decodedBody closes the original body along with the decoder.
+type decodedBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+func (b *decodedBody) Close() error {
+	if c, ok := b.Reader.(io.Closer); ok {
+		c.Close()
+	}
+	return b.body.Close()
+}
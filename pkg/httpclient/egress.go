@@ -0,0 +1,74 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Egress is a network path upstream requests leave through: a proxy, or the
+// direct connection.
+type Egress struct {
+	Proxy   string   // Proxy URL, empty for the direct connection
+	Default bool     // Used by requests no transport route matches
+	Routes  []string // URL patterns of the transport routes using it
+}
+
+// Egresses returns the distinct egress paths of the client, the default one
+// first, then the global proxies and the route proxies in configuration order.
+func (c *Client) Egresses() []Egress {
+	var egresses []Egress
+	index := make(map[string]int)
+	add := func(proxy string) *Egress {
+		i, ok := index[proxy]
+		if !ok {
+			i = len(egresses)
+			index[proxy] = i
+			egresses = append(egresses, Egress{Proxy: proxy})
+		}
+		return &egresses[i]
+	}
+
+	defaultProxy := ""
+	if len(c.globalProxies) > 0 {
+		defaultProxy = c.globalProxies[0]
+	}
+	add(defaultProxy).Default = true
+	for _, proxy := range c.globalProxies {
+		add(proxy)
+	}
+
+	// Mirrors transportFor: routes without a proxy, DIRECT or DISABLE_SSL
+	// leave the choice to the following routes
+	for _, route := range c.routes {
+		var proxy string
+		switch {
+		case route.Direct:
+		case route.Proxy != "":
+			proxy = route.Proxy
+		case route.DisableSSL:
+		default:
+			continue
+		}
+		e := add(proxy)
+		e.Routes = append(e.Routes, route.URLPattern)
+	}
+	return egresses
+}
+
+// EgressClient returns the client sending requests through the proxy of an
+// egress, or directly when proxy is empty.
+func (c *Client) EgressClient(proxy string) *http.Client {
+	if proxy == "" {
+		return c.defaultClient
+	}
+	return c.getOrCreateProxyClient(proxy, false)
+}
+
+// RedactProxy returns a proxy URL with its password masked, for display.
+func RedactProxy(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return "invalid proxy URL"
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,182 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// DefaultUserAgent is the first User-Agent of the default profile, sent
+// upstream when neither the client nor a transport route sets one and no
+// other profile is configured.
+const DefaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+// maxHeaderValue caps the length of a header value passed in h_ params.
+const maxHeaderValue = 8 << 10
+
+// allowedHeaders are the headers clients may pass in h_ params: the ones
+// CDNs check, plus the X- and browser Sec- families.
+var (
+	allowedHeaders = map[string]bool{
+		"Accept":          true,
+		"Accept-Language": true,
+		"Authorization":   true,
+		"Cache-Control":   true,
+		"Cookie":          true,
+		"Dnt":             true,
+		"Origin":          true,
+		"Pragma":          true,
+		"Referer":         true,
+		"User-Agent":      true,
+	}
+	allowedHeaderPrefixes = []string{"X-", "Sec-Ch-", "Sec-Fetch-"}
+)
+
+// forbiddenHeaders are never accepted from h_ params, even when allowlisted:
+// they describe the connection or the message framing, which the proxy owns.
+var forbiddenHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Expect":            true,
+	"Host":              true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Te":                true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// AllowedHeader reports whether clients may set the canonical header name in
+// h_ params. extra adds names to the allowlist; an entry ending in "*"
+// allows every header starting with it.
+func AllowedHeader(name string, extra []string) bool {
+	if !httpguts.ValidHeaderFieldName(name) || forbiddenHeaders[name] || strings.HasPrefix(name, "Proxy-") {
+		return false
+	}
+	if allowedHeaders[name] {
+		return true
+	}
+	for _, prefix := range allowedHeaderPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return headerListed(name, extra)
+}
+
+// headerListed reports whether the canonical header name is in list, where
+// an entry ending in "*" matches every header starting with it.
+func headerListed(name string, list []string) bool {
+	for _, entry := range list {
+		entry = http.CanonicalHeaderKey(strings.TrimSpace(entry))
+		if prefix, ok := strings.CutSuffix(entry, "*"); ok {
+			if prefix != "" && strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if entry == name {
+			return true
+		}
+	}
+	return false
+}
+
+// strippedResponseHeaders are never forwarded from upstream responses, even
+// when listed: hop-by-hop headers, cookies and credentials of the upstream
+// site, and the framing and CORS headers the proxy sets itself.
+var strippedResponseHeaders = map[string]bool{
+	"Alt-Svc":                   true,
+	"Connection":                true,
+	"Content-Encoding":          true,
+	"Content-Length":            true,
+	"Content-Type":              true,
+	"Keep-Alive":                true,
+	"Location":                  true,
+	"Proxy-Authenticate":        true,
+	"Proxy-Connection":          true,
+	"Set-Cookie":                true,
+	"Set-Cookie2":               true,
+	"Strict-Transport-Security": true,
+	"Te":                        true,
+	"Trailer":                   true,
+	"Transfer-Encoding":         true,
+	"Upgrade":                   true,
+	"Www-Authenticate":          true,
+}
+
+// ForwardedHeaders returns the upstream response headers passed on to clients
+// by the FORWARD_RESPONSE_HEADERS policy, first value only.
+func (c *Client) ForwardedHeaders(upstream http.Header) map[string]string {
+	headers := make(map[string]string)
+	for name, values := range upstream {
+		if len(values) == 0 || strippedResponseHeaders[name] || strings.HasPrefix(name, "Access-Control-") {
+			continue
+		}
+		if headerListed(name, c.forwardHeaders) {
+			headers[name] = values[0]
+		}
+	}
+	return headers
+}
+
+// validHeaderValue reports whether a header value is safe to send: no
+// control characters that would split or truncate the header, and not
+// oversized.
+func validHeaderValue(value string) bool {
+	return len(value) <= maxHeaderValue && httpguts.ValidHeaderFieldValue(value)
+}
+
+// RouteHeaders returns the default headers the transport routes matching
+// targetURL add to its requests. When several routes set a header, the first
+// one in evaluation order wins.
+func (c *Client) RouteHeaders(targetURL string) map[string]string {
+	var headers map[string]string
+	for i := range c.routes {
+		route := &c.routes[i]
+		if len(route.Headers) == 0 || !routeMatches(route, targetURL) {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		for name, value := range route.Headers {
+			if _, ok := headers[name]; !ok {
+				headers[name] = value
+			}
+		}
+	}
+	return headers
+}
+
+// withRouteHeaders returns req with the default headers of its transport
+// routes added. Headers set by the client win, except the proxy's own
+// defaults: the User-Agents of the preset profiles and a Referer of the URL's
+// origin.
+func (c *Client) withRouteHeaders(req *http.Request) *http.Request {
+	headers := c.RouteHeaders(req.URL.String())
+	if len(headers) == 0 {
+		return req
+	}
+	req = req.Clone(req.Context())
+	for name, value := range headers {
+		if current := req.Header.Get(name); current == "" || isDefaultHeader(req.URL, name, current) {
+			req.Header.Set(name, value)
+		}
+	}
+	return req
+}
+
+// isDefaultHeader reports whether a header value is one the proxy fills in
+// when the client passes none.
+func isDefaultHeader(u *url.URL, name, value string) bool {
+	switch name {
+	case "User-Agent":
+		_, ok := presetIdentity(value)
+		return ok
+	case "Referer":
+		return value == u.Scheme+"://"+u.Host+"/"
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/logging"
+)
+
+func TestClient_Do_RouteHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{TransportRoutes: []config.TransportRoute{
+		{URLPattern: "/live/", Match: config.RouteMatchPath, Headers: map[string]string{"User-Agent": "VLC/3.0", "Referer": "https://site.example/"}},
+		{URLPattern: "/", Match: config.RouteMatchPath, Headers: map[string]string{"User-Agent": "Other/1.0", "Cookie": "session=1"}},
+	}}
+	client := New(cfg, logging.New("error", false, nil))
+
+	tests := []struct {
+		name        string
+		path        string
+		headers     map[string]string
+		wantUA      string
+		wantReferer string
+	}{
+		{"route headers", "/live/1.m3u8", nil, "VLC/3.0", "https://site.example/"},
+		{"first route wins", "/vod/1.m3u8", nil, "Other/1.0", ""},
+		{"client headers win", "/live/1.m3u8", map[string]string{"User-Agent": "Client/2.0", "Referer": "https://client.example/"}, "Client/2.0", "https://client.example/"},
+		{"proxy defaults replaced", "/live/1.m3u8", map[string]string{"User-Agent": DefaultUserAgent, "Referer": server.URL + "/"}, "VLC/3.0", "https://site.example/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			resp.Body.Close()
+
+			if ua := got.Get("User-Agent"); ua != tt.wantUA {
+				t.Errorf("User-Agent = %q, want %q", ua, tt.wantUA)
+			}
+			if referer := got.Get("Referer"); referer != tt.wantReferer {
+				t.Errorf("Referer = %q, want %q", referer, tt.wantReferer)
+			}
+			if got.Get("Cookie") != "session=1" {
+				t.Errorf("Cookie = %q, want the catch-all route's", got.Get("Cookie"))
+			}
+			if req.Header.Get("Cookie") != "" {
+				t.Error("route headers were added to the caller's request")
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"media-proxy-go/pkg/bufpool"
+	"media-proxy-go/pkg/config"
+)
+
+// Class is a kind of upstream response, with its own size and time limits.
+type Class int
+
+const (
+	ClassManifest Class = iota // HLS playlists and MPDs
+	ClassSegment               // Segments, init segments and keys buffered in memory
+	ClassPage                  // Extractor pages and API responses
+)
+
+// ErrBodyTooLarge is returned when a response body exceeds its size limit.
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// limit bounds the responses of a class. Zero values disable a limit.
+type limit struct {
+	maxSize int64
+	timeout time.Duration
+}
+
+// classLimits returns the configured limit of each class.
+func classLimits(cfg *config.Config) [3]limit {
+	return [3]limit{
+		ClassManifest: {cfg.ManifestMaxSize, cfg.ManifestTimeout},
+		ClassSegment:  {cfg.SegmentMaxSize, cfg.SegmentTimeout},
+		ClassPage:     {cfg.PageMaxSize, cfg.PageTimeout},
+	}
+}
+
+// DoClass executes a request under the class's timeout, which also covers
+// reading the body. ClassPage requests first wait for their turn in the
+// outbound budget, which doesn't count against the timeout. The body must be
+// closed to release the timer and the budget.
+func (c *Client) DoClass(req *http.Request, class Class) (*http.Response, error) {
+	var release func()
+	if class == ClassPage {
+		var err error
+		if release, err = c.schedule(req); err != nil {
+			return nil, err
+		}
+	}
+
+	timeout := c.limits[class].timeout
+	if timeout <= 0 {
+		resp, err := c.Do(req)
+		if err != nil {
+			if release != nil {
+				release()
+			}
+			return nil, err
+		}
+		if release != nil {
+			resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+		}
+		return resp, nil
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		if release != nil {
+			release()
+		}
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	if release != nil {
+		resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	}
+	return resp, nil
+}
+
+// ReadBody reads a response body, failing with ErrBodyTooLarge beyond the
+// class's size limit instead of buffering whatever upstream sends.
+func (c *Client) ReadBody(r io.Reader, class Class) ([]byte, error) {
+	if c == nil {
+		return io.ReadAll(r)
+	}
+	return ReadLimited(r, c.limits[class].maxSize)
+}
+
+// ReadLimited reads r to the end, failing with ErrBodyTooLarge beyond
+// maxSize bytes (0 = no limit).
+func ReadLimited(r io.Reader, maxSize int64) ([]byte, error) {
+	if maxSize <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w: over %d bytes", ErrBodyTooLarge, maxSize)
+	}
+	return data, nil
+}
+
+// ReadLimitedBuffer is ReadLimited into a buffer from bufpool, for segments
+// that are only needed until the response is written. The caller must hand
+// the buffer back with bufpool.Put.
+func ReadLimitedBuffer(r io.Reader, maxSize int64) (*bytes.Buffer, error) {
+	if maxSize > 0 {
+		r = io.LimitReader(r, maxSize+1)
+	}
+
+	buf := bufpool.Get()
+	if _, err := buf.ReadFrom(r); err != nil {
+		bufpool.Put(buf)
+		return nil, err
+	}
+	if maxSize > 0 && int64(buf.Len()) > maxSize {
+		bufpool.Put(buf)
+		return nil, fmt.Errorf("%w: over %d bytes", ErrBodyTooLarge, maxSize)
+	}
+	return buf, nil
+}
+
+// cancelBody cancels the request context once the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,82 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/bufpool"
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/logging"
+)
+
+func TestReadLimited(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		maxSize int64
+		wantErr bool
+	}{
+		{"no limit", "0123456789", 0, false},
+		{"under limit", "0123456789", 20, false},
+		{"exactly at limit", "0123456789", 10, false},
+		{"over limit", "0123456789", 9, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ReadLimited(strings.NewReader(tt.body), tt.maxSize)
+			if tt.wantErr {
+				if !errors.Is(err, ErrBodyTooLarge) {
+					t.Errorf("ReadLimited() error = %v, want ErrBodyTooLarge", err)
+				}
+				if _, err := ReadLimitedBuffer(strings.NewReader(tt.body), tt.maxSize); !errors.Is(err, ErrBodyTooLarge) {
+					t.Errorf("ReadLimitedBuffer() error = %v, want ErrBodyTooLarge", err)
+				}
+				return
+			}
+			if err != nil || string(data) != tt.body {
+				t.Errorf("ReadLimited() = %q, %v, want %q", data, err, tt.body)
+			}
+
+			buf, err := ReadLimitedBuffer(strings.NewReader(tt.body), tt.maxSize)
+			if err != nil || buf.String() != tt.body {
+				t.Errorf("ReadLimitedBuffer() = %v, want %q", err, tt.body)
+			}
+			bufpool.Put(buf)
+		})
+	}
+}
+
+func TestClient_DoClass_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("#EXTM3U\n"))
+		w.(http.Flusher).Flush()
+		// Stall mid-body like a misbehaving upstream
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := New(&config.Config{ManifestTimeout: 100 * time.Millisecond}, logging.New("error", false, nil))
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+
+	resp, err := client.DoClass(req, ClassManifest)
+	if err != nil {
+		t.Fatalf("DoClass() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("reading a stalled body: error = %v, want deadline exceeded", err)
+	}
+}
@@ -0,0 +1,132 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/types"
+)
+
+// maxRedirects matches net/http's default redirect limit.
+const maxRedirects = 10
+
+type (
+	noFollowKey        struct{}
+	redirectHeadersKey struct{}
+)
+
+// NoFollow returns a context whose requests return redirects to the caller
+// instead of following them.
+func NoFollow(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noFollowKey{}, true)
+}
+
+// RedirectsToClient reports whether redirects of targetURL should be handed
+// to the client (REDIRECT_STREAM transport route option).
+func (c *Client) RedirectsToClient(targetURL string) bool {
+	route := c.redirectRoute(targetURL)
+	return route != nil && route.RedirectStream
+}
+
+// redirectRoute returns the first transport route matching targetURL that
+// sets a redirect option.
+func (c *Client) redirectRoute(targetURL string) *config.TransportRoute {
+	for i, route := range c.routes {
+		if (route.RedirectHeaders != nil || route.RedirectStream) && routeMatches(&c.routes[i], targetURL) {
+			return &c.routes[i]
+		}
+	}
+	return nil
+}
+
+// TestRoutes evaluates the transport routes for targetURL without sending a
+// request: which route and proxy requests would use, which route sets their
+// redirect policy and which default headers they get.
+func (c *Client) TestRoutes(targetURL string) types.RouteTest {
+	result := types.RouteTest{URL: targetURL, Routes: make([]types.RouteTestResult, len(c.routes))}
+	for i := range c.routes {
+		route := &c.routes[i]
+		result.Routes[i] = types.RouteTestResult{
+			Index:    i,
+			Pattern:  route.URLPattern,
+			Match:    route.Match,
+			Priority: route.Priority,
+			Matched:  routeMatches(route, targetURL),
+		}
+	}
+	indexOf := func(route *config.TransportRoute) *types.RouteTestResult {
+		for i := range c.routes {
+			if &c.routes[i] == route {
+				return &result.Routes[i]
+			}
+		}
+		return nil
+	}
+
+	route, proxyURL, insecure := c.transportFor(targetURL)
+	switch {
+	case c.needsUTLS(targetURL):
+		result.Transport = "utls"
+	case route != nil:
+		result.Transport = "route"
+		result.Route = indexOf(route)
+	case proxyURL != "":
+		result.Transport = "global"
+	default:
+		result.Transport = "direct"
+	}
+	if result.Transport != "utls" {
+		if proxyURL != "" {
+			result.Proxy = RedactProxy(proxyURL)
+		}
+		result.Insecure = insecure
+	}
+	result.Redirect = indexOf(c.redirectRoute(targetURL))
+	result.Headers = c.RouteHeaders(targetURL)
+	for name := range result.Headers {
+		if name == "Cookie" || name == "Authorization" {
+			result.Headers[name] = "***"
+		}
+	}
+	return result
+}
+
+// withRedirectPolicy attaches the redirect header policy of req's route to
+// its context, where checkRedirect picks it up.
+func (c *Client) withRedirectPolicy(req *http.Request) *http.Request {
+	route := c.redirectRoute(req.URL.String())
+	if route == nil || route.RedirectHeaders == nil {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), redirectHeadersKey{}, route.RedirectHeaders))
+}
+
+// checkRedirect is the CheckRedirect of all clients. net/http copies every
+// header of the original request to each redirect, so forged Referer/Origin
+// headers reach other CDN hosts too; routes with REDIRECT_HEADERS only keep
+// the listed headers once the host changes.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.New("stopped after 10 redirects")
+	}
+	if req.Context().Value(noFollowKey{}) != nil {
+		return http.ErrUseLastResponse
+	}
+
+	keep, ok := req.Context().Value(redirectHeadersKey{}).([]string)
+	if !ok || strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+		return nil
+	}
+
+	kept := make(http.Header)
+	for _, name := range keep {
+		if v := req.Header.Values(name); len(v) > 0 {
+			kept[http.CanonicalHeaderKey(name)] = v
+		}
+	}
+	req.Header = kept
+	return nil
+}
@@ -0,0 +1,107 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/throttle"
+	"media-proxy-go/pkg/types"
+)
+
+// routeLimiters returns the limiter of each route, nil for unpaced ones.
+func routeLimiters(routes []config.TransportRoute) []*throttle.Limiter {
+	limiters := make([]*throttle.Limiter, len(routes))
+	for i, route := range routes {
+		limiters[i] = throttle.New(route.Limit)
+	}
+	return limiters
+}
+
+// acquireRoute waits for the turn of a request to targetURL under the first
+// matching route that paces its requests. The returned release function, nil
+// when no route paces targetURL, must be called once the response is done
+// with.
+func (c *Client) acquireRoute(ctx context.Context, targetURL string) (func(), error) {
+	for i, l := range c.routeLimiters {
+		if l != nil && routeMatches(&c.routes[i], targetURL) {
+			return l.Acquire(ctx)
+		}
+	}
+	return nil, nil
+}
+
+// hostLimit returns how page and API requests are paced per host.
+// Throttled requests leave up to half an interval late, so bulk refreshes
+// don't hit a host on a fixed beat.
+func hostLimit(cfg *config.Config) throttle.Limit {
+	limit := throttle.Limit{Concurrency: cfg.OutboundHostMaxInFlight}
+	if cfg.OutboundHostInterval > 0 {
+		limit.Rate = float64(time.Second) / float64(cfg.OutboundHostInterval)
+		limit.Jitter = cfg.OutboundHostInterval / 2
+	}
+	return limit
+}
+
+// schedule waits for the turn of a page or API request: first within its
+// host's pacing, then within the global budget. The returned release
+// function, nil when nothing paces these requests, must be called once the
+// response is done with.
+func (c *Client) schedule(req *http.Request) (func(), error) {
+	if c.hosts == nil && c.outbound == nil {
+		return nil, nil
+	}
+	releaseHost, err := c.hosts.Acquire(req.Context(), strings.ToLower(req.URL.Hostname()))
+	if err != nil {
+		return nil, err
+	}
+	releaseBudget, err := c.outbound.Acquire(req.Context())
+	if err != nil {
+		releaseHost()
+		return nil, err
+	}
+	return func() {
+		releaseBudget()
+		releaseHost()
+	}, nil
+}
+
+// OutboundStats returns the counters of the outbound budget (nil when
+// unlimited) and of each paced host.
+func (c *Client) OutboundStats() (*types.ThrottleStats, map[string]types.ThrottleStats) {
+	var budget *types.ThrottleStats
+	if c.outbound != nil {
+		stats := c.outbound.Stats()
+		budget = &stats
+	}
+	return budget, c.hosts.Stats()
+}
+
+// RouteLimitStats returns the counters of the paced transport routes, by URL
+// pattern.
+func (c *Client) RouteLimitStats() map[string]types.ThrottleStats {
+	stats := make(map[string]types.ThrottleStats)
+	for i, l := range c.routeLimiters {
+		if l != nil {
+			stats[c.routes[i].URLPattern] = l.Stats()
+		}
+	}
+	return stats
+}
+
+// releaseBody releases a paced request's slot once its body is closed, so
+// streamed segments count against the route's concurrency for as long as
+// they transfer.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,108 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/throttle"
+)
+
+func TestClient_Do_RouteLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("segment"))
+	}))
+	defer srv.Close()
+
+	client := New(&config.Config{TransportRoutes: []config.TransportRoute{
+		{URLPattern: srv.URL + "/paced/", Match: config.RouteMatchContains, Limit: throttle.Limit{Concurrency: 1}},
+	}}, logging.New("error", false, nil))
+
+	get := func(ctx context.Context, path string) (*http.Response, error) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+path, nil)
+		return client.Do(req)
+	}
+
+	// The slot is held until the body is closed
+	resp, err := get(context.Background(), "/paced/seg1.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := get(ctx, "/paced/seg2.ts"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("second request err = %v, want it queued until the deadline", err)
+	}
+
+	// Other URLs aren't paced
+	other, err := get(context.Background(), "/free/seg1.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Body.Close()
+
+	resp.Body.Close()
+	resp, err = get(context.Background(), "/paced/seg2.ts")
+	if err != nil {
+		t.Fatalf("request after the body was closed: %v", err)
+	}
+	resp.Body.Close()
+
+	stats := client.RouteLimitStats()[srv.URL+"/paced/"]
+	if stats.Started != 2 || stats.Abandoned != 1 || stats.Active != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestClient_DoClass_HostPacing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	client := New(&config.Config{
+		OutboundMaxInFlight:  4,
+		OutboundHostInterval: 20 * time.Millisecond,
+	}, logging.New("error", false, nil))
+
+	start := time.Now()
+	for range 4 {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api", nil)
+		resp, err := client.DoClass(req, ClassPage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("4 page requests to a host took %v, want at least 3 intervals", elapsed)
+	}
+
+	// Segments and manifests aren't paced
+	start = time.Now()
+	for range 4 {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/seg.ts", nil)
+		resp, err := client.DoClass(req, ClassSegment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed >= 60*time.Millisecond {
+		t.Errorf("4 segment requests took %v, want them unpaced", elapsed)
+	}
+
+	budget, hosts := client.OutboundStats()
+	if budget == nil || budget.Started != 4 || budget.Active != 0 {
+		t.Errorf("budget = %+v", budget)
+	}
+	host := hosts["127.0.0.1"]
+	if host.Started != 4 || host.Throttled != 3 {
+		t.Errorf("host stats = %+v", hosts)
+	}
+}
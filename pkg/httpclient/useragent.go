@@ -0,0 +1,191 @@
+package httpclient
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"media-proxy-go/pkg/types"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// DefaultUAProfile is the User-Agent profile used when none is configured.
+const DefaultUAProfile = "desktop"
+
+// ErrUnknownUAProfile is returned when setting a profile that isn't a preset.
+var ErrUnknownUAProfile = errors.New("unknown User-Agent profile")
+
+// uaIdentity is a browser User-Agent and the TLS fingerprint of that browser,
+// so Cloudflare protected sites see a ClientHello matching the User-Agent.
+type uaIdentity struct {
+	userAgent string
+	hello     utls.ClientHelloID
+}
+
+// uaProfiles are the preset User-Agent profiles. The first identity of a
+// profile is used when rotation is off. Chromium based browsers give the
+// Chrome 120 fingerprint, so their User-Agents announce Chrome 120 too.
+var uaProfiles = map[string][]uaIdentity{
+	"desktop": {
+		{DefaultUserAgent, utls.HelloChrome_120},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", utls.HelloChrome_120},
+		{"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", utls.HelloChrome_120},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:120.0) Gecko/20100101 Firefox/120.0", utls.HelloFirefox_120},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.0 Safari/605.1.15", utls.HelloSafari_16_0},
+	},
+	"mobile": {
+		{"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36", utls.HelloChrome_120},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 14_8 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.2 Mobile/15E148 Safari/604.1", utls.HelloIOS_14},
+	},
+	"smarttv": {
+		{"Mozilla/5.0 (SMART-TV; LINUX; Tizen 7.0) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/5.0 Chrome/120.0.0.0 TV Safari/537.36", utls.HelloChrome_120},
+		{"Mozilla/5.0 (Web0S; Linux/SmartTV) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 WebAppManager", utls.HelloChrome_120},
+	},
+}
+
+// uaSettings is the profile in use and whether it rotates per host. The
+// seed is redrawn on each change, so hosts get new identities.
+type uaSettings struct {
+	name   string
+	rotate bool
+	seed   uint64
+}
+
+// userAgents picks the User-Agents of upstream requests from the profile in
+// use. With rotation, each host gets one of the profile's identities, the
+// same one for as long as the profile is unchanged, so a site sees a single
+// browser across its pages, cookies and tokens.
+type userAgents struct {
+	settings atomic.Pointer[uaSettings]
+}
+
+// newUserAgents returns the User-Agents of profile, the default one if
+// profile is empty.
+func newUserAgents(profile string, rotate bool) (*userAgents, error) {
+	u := &userAgents{}
+	if profile == "" {
+		profile = DefaultUAProfile
+	}
+	return u, u.set(profile, rotate)
+}
+
+// set switches to the named preset profile.
+func (u *userAgents) set(profile string, rotate bool) error {
+	profile = strings.ToLower(strings.TrimSpace(profile))
+	if _, ok := uaProfiles[profile]; !ok {
+		return fmt.Errorf("%w %q, want one of %s", ErrUnknownUAProfile, profile, strings.Join(uaProfileNames(), ", "))
+	}
+	var b [8]byte
+	rand.Read(b[:])
+	u.settings.Store(&uaSettings{name: profile, rotate: rotate, seed: binary.LittleEndian.Uint64(b[:])})
+	return nil
+}
+
+// identity returns the identity of requests to targetURL.
+func (u *userAgents) identity(targetURL string) uaIdentity {
+	settings := u.settings.Load()
+	identities := uaProfiles[settings.name]
+	if !settings.rotate || len(identities) == 1 {
+		return identities[0]
+	}
+	host := targetURL
+	if parsed, err := url.Parse(targetURL); err == nil && parsed.Host != "" {
+		host = parsed.Hostname()
+	}
+	h := fnv.New64a()
+	binary.Write(h, binary.LittleEndian, settings.seed)
+	h.Write([]byte(strings.ToLower(host)))
+	return identities[h.Sum64()%uint64(len(identities))]
+}
+
+// status returns the profile in use and the presets.
+func (u *userAgents) status() types.UAProfileStatus {
+	settings := u.settings.Load()
+	status := types.UAProfileStatus{
+		Profile:  settings.name,
+		Rotate:   settings.rotate,
+		Profiles: make(map[string][]string, len(uaProfiles)),
+	}
+	for name, identities := range uaProfiles {
+		for _, id := range identities {
+			status.Profiles[name] = append(status.Profiles[name], id.userAgent)
+		}
+	}
+	return status
+}
+
+// uaProfileNames returns the names of the preset profiles, sorted.
+func uaProfileNames() []string {
+	names := make([]string, 0, len(uaProfiles))
+	for name := range uaProfiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// presetIdentity returns the preset identity with the given User-Agent.
+func presetIdentity(userAgent string) (uaIdentity, bool) {
+	for _, identities := range uaProfiles {
+		for _, id := range identities {
+			if id.userAgent == userAgent {
+				return id, true
+			}
+		}
+	}
+	return uaIdentity{}, false
+}
+
+// helloFor returns the TLS fingerprint matching a User-Agent: the one of its
+// preset identity, Chrome 120 for any other.
+func helloFor(userAgent string) utls.ClientHelloID {
+	if id, ok := presetIdentity(userAgent); ok {
+		return id.hello
+	}
+	return utls.HelloChrome_120
+}
+
+// UserAgent returns the User-Agent of the profile in use for requests to
+// targetURL. Extractors send it to the pages they resolve and return it with
+// the stream, so playback goes on as the same browser. A nil client returns
+// DefaultUserAgent.
+func (c *Client) UserAgent(targetURL string) string {
+	if c == nil || c.userAgents == nil {
+		return DefaultUserAgent
+	}
+	return c.userAgents.identity(targetURL).userAgent
+}
+
+// UAProfile returns the User-Agent profile in use and the presets.
+func (c *Client) UAProfile() types.UAProfileStatus {
+	return c.userAgents.status()
+}
+
+// SetUAProfile switches to a preset User-Agent profile, rotating its
+// identities per host if rotate is set. Hosts get new identities.
+func (c *Client) SetUAProfile(profile string, rotate bool) error {
+	if err := c.userAgents.set(profile, rotate); err != nil {
+		return err
+	}
+	c.log.Info("🪪 User-Agent profile set", "profile", profile, "rotate", rotate)
+	return nil
+}
+
+// withUserAgent returns req with the User-Agent of the profile in use, if it
+// has none.
+func (c *Client) withUserAgent(req *http.Request) *http.Request {
+	if req.Header.Get("User-Agent") != "" {
+		return req
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", c.UserAgent(req.URL.String()))
+	return req
+}
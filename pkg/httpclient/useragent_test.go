@@ -0,0 +1,104 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/logging"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+func TestClient_UserAgent(t *testing.T) {
+	client := New(&config.Config{}, logging.New("error", false, nil))
+	if ua := client.UserAgent("https://a.example/page"); ua != DefaultUserAgent {
+		t.Errorf("UserAgent() = %q, want the default one", ua)
+	}
+
+	if err := client.SetUAProfile("SmartTV", false); err != nil {
+		t.Fatal(err)
+	}
+	if ua := client.UserAgent("https://a.example/page"); ua != uaProfiles["smarttv"][0].userAgent {
+		t.Errorf("UserAgent() = %q, want the first smarttv one", ua)
+	}
+	if err := client.SetUAProfile("fridge", true); !errors.Is(err, ErrUnknownUAProfile) {
+		t.Errorf("SetUAProfile() error = %v, want ErrUnknownUAProfile", err)
+	}
+	if status := client.UAProfile(); status.Profile != "smarttv" || status.Rotate || len(status.Profiles) != len(uaProfiles) {
+		t.Errorf("UAProfile() = %+v", status)
+	}
+
+	// Rotation gives each host its own User-Agent, the same across its pages
+	client.SetUAProfile("desktop", true)
+	seen := map[string]bool{}
+	for i := range 50 {
+		host := fmt.Sprintf("https://site%d.example", i)
+		ua := client.UserAgent(host + "/a")
+		if again := client.UserAgent(host + "/b?x=1"); again != ua {
+			t.Fatalf("UserAgent() of %s = %q then %q", host, ua, again)
+		}
+		seen[ua] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("%d User-Agents across 50 hosts, want rotation", len(seen))
+	}
+
+	if ua := (*Client)(nil).UserAgent("https://a.example/"); ua != DefaultUserAgent {
+		t.Errorf("UserAgent() of a nil client = %q", ua)
+	}
+}
+
+func TestClient_Do_UserAgent(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("User-Agent")
+	}))
+	defer srv.Close()
+
+	client := New(&config.Config{UAProfile: "mobile", TransportRoutes: []config.TransportRoute{
+		{URLPattern: srv.URL + "/routed/", Match: config.RouteMatchContains, Headers: map[string]string{"User-Agent": "VLC/3.0"}},
+	}}, logging.New("error", false, nil))
+
+	get := func(path, userAgent string) string {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		if userAgent != "" {
+			req.Header.Set("User-Agent", userAgent)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return got
+	}
+	if ua := get("/page", ""); ua != uaProfiles["mobile"][0].userAgent {
+		t.Errorf("User-Agent = %q, want the mobile profile's", ua)
+	}
+	if ua := get("/page", "Kodi/20"); ua != "Kodi/20" {
+		t.Errorf("User-Agent = %q, want the client's", ua)
+	}
+	// Transport routes override the profile, set explicitly or not
+	if ua := get("/routed/page", ""); ua != "VLC/3.0" {
+		t.Errorf("User-Agent = %q, want the route's", ua)
+	}
+	if ua := get("/routed/page", client.UserAgent(srv.URL)); ua != "VLC/3.0" {
+		t.Errorf("User-Agent = %q, want the route's over a preset", ua)
+	}
+}
+
+func TestHelloFor(t *testing.T) {
+	for name, identities := range uaProfiles {
+		for _, id := range identities {
+			if got := helloFor(id.userAgent); got != id.hello {
+				t.Errorf("%s: helloFor(%q) = %v, want %v", name, id.userAgent, got, id.hello)
+			}
+		}
+	}
+	if got := helloFor("VLC/3.0"); got != utls.HelloChrome_120 {
+		t.Errorf("helloFor() of an unknown User-Agent = %v", got)
+	}
+}
@@ -0,0 +1,113 @@
+// Package i18n translates dashboard strings and API error messages.
+//
+// English is the source language: messages are looked up by their English
+// text in the catalogs under locales/, and messages without a translation
+// are returned unchanged.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is the language of untranslated messages.
+const Default = "en"
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// catalogs maps a language to its translations by English message.
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]string {
+	catalogs := map[string]map[string]string{Default: {}}
+	files, _ := locales.ReadDir("locales")
+	for _, f := range files {
+		data, err := locales.ReadFile("locales/" + f.Name())
+		if err != nil {
+			panic(err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic("i18n: invalid catalog " + f.Name() + ": " + err.Error())
+		}
+		catalogs[strings.TrimSuffix(f.Name(), path.Ext(f.Name()))] = messages
+	}
+	return catalogs
+}
+
+// Supported returns the supported languages, sorted.
+func Supported() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Match returns the best supported language of a list of language ranges
+// such as an Accept-Language header ("it-IT,it;q=0.9,en;q=0.8"), or Default.
+// Only the primary subtag is matched, so "de-AT" selects "de".
+func Match(ranges string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(ranges, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		base, _, _ = strings.Cut(base, "_") // POSIX locales such as it_IT.UTF-8
+		if _, ok := catalogs[base]; ok && q > 0 {
+			candidates = append(candidates, candidate{base, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return Default
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
+
+// FromRequest returns the language of the responses to r: forced when set
+// (the UI_LANGUAGE option), else the client's Accept-Language.
+func FromRequest(r *http.Request, forced string) string {
+	if forced != "" {
+		return Match(forced)
+	}
+	return Match(r.Header.Get("Accept-Language"))
+}
+
+// Translate returns message in lang. A message without a translation of its
+// own that has the form "prefix: detail" is translated by its prefix, which
+// keeps details such as IDs and upstream errors.
+func Translate(lang, message string) string {
+	catalog := catalogs[lang]
+	if t, ok := catalog[message]; ok {
+		return t
+	}
+	if prefix, detail, ok := strings.Cut(message, ": "); ok {
+		if t, ok := catalog[prefix]; ok {
+			return t + ": " + detail
+		}
+	}
+	return message
+}
+
+// Messages returns the translations of lang, for scripts translating on the
+// client. The map must not be modified.
+func Messages(lang string) map[string]string {
+	return catalogs[lang]
+}
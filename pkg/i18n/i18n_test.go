@@ -0,0 +1,84 @@
+package i18n
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		ranges string
+		want   string
+	}{
+		{"", "en"},
+		{"it-IT,it;q=0.9,en;q=0.8", "it"},
+		{"fr-FR,fr;q=0.9,de;q=0.7", "de"},
+		{"en;q=0.5,es;q=0.8", "es"},
+		{"de-AT", "de"},
+		{"es_ES.UTF-8", "es"},
+		{"it;q=0,de", "de"},
+		{"fr, *;q=0.5", "en"},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.ranges); got != tt.want {
+			t.Errorf("Match(%q) = %q, want %q", tt.ranges, got, tt.want)
+		}
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Language", "es")
+	if got := FromRequest(r, ""); got != "es" {
+		t.Errorf("FromRequest() = %q, want es", got)
+	}
+	if got := FromRequest(r, "it"); got != "it" {
+		t.Errorf("FromRequest() with a forced language = %q, want it", got)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		lang, message, want string
+	}{
+		{"it", "url parameter required", "parametro url obbligatorio"},
+		{"de", "recording not found: rec_1", "Aufnahme nicht gefunden: rec_1"},
+		{"es", "something new", "something new"},
+		{"en", "url parameter required", "url parameter required"},
+		{"fr", "url parameter required", "url parameter required"},
+	}
+	for _, tt := range tests {
+		if got := Translate(tt.lang, tt.message); got != tt.want {
+			t.Errorf("Translate(%q, %q) = %q, want %q", tt.lang, tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogs(t *testing.T) {
+	if got, want := Supported(), []string{"de", "en", "es", "it"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Supported() = %v, want %v", got, want)
+	}
+
+	// Every catalog translates the same messages and keeps their placeholders
+	placeholders := regexp.MustCompile(`\{\d+\}`)
+	reference := catalogs["it"]
+	for _, lang := range Supported() {
+		if lang == Default {
+			continue
+		}
+		catalog := catalogs[lang]
+		if len(catalog) != len(reference) {
+			t.Errorf("%s has %d messages, it has %d", lang, len(catalog), len(reference))
+		}
+		for message, translation := range catalog {
+			if _, ok := reference[message]; !ok {
+				t.Errorf("%s: %q is not in the it catalog", lang, message)
+			}
+			if got, want := placeholders.FindAllString(translation, -1), placeholders.FindAllString(message, -1); len(got) != len(want) {
+				t.Errorf("%s: %q has placeholders %v, want %v", lang, translation, got, want)
+			}
+		}
+	}
+}
@@ -55,9 +55,20 @@ type Extractor interface {
 
 // ExtractOptions contains optional parameters for extraction.
 type ExtractOptions struct {
-	Headers    map[string]string
+	Headers      map[string]string
 	ForceRefresh bool
-	Proxy      string
+	Proxy        string
+	Host         string // Extractor to use, by name, instead of the one matching the URL
+}
+
+// RulesConsumer is an extractor whose patterns, URL templates and headers can
+// be updated by a rules bundle. DLHD is the only one so far.
+type RulesConsumer interface {
+	Name() string
+
+	// ApplyRules replaces the extractor's rules; empty rules restore the
+	// built-in behavior. Invalid rules are rejected as a whole.
+	ApplyRules(rules types.ExtractorRules) error
 }
 
 // HTTPClient abstracts HTTP operations for testability.
@@ -76,8 +87,23 @@ type ManifestRewriter interface {
 
 // Transcoder handles stream transcoding operations.
 type Transcoder interface {
-	// StartStream begins transcoding a stream, returning a stream ID.
-	StartStream(ctx context.Context, url string, headers map[string]string, clearKey string) (string, error)
+	// StartStream begins transcoding a stream with the named profile (empty
+	// for the default), burning in the subtitles at burnSubtitles if set,
+	// returning a stream ID.
+	StartStream(ctx context.Context, url string, headers map[string]string, clearKey string, profile string, burnSubtitles string) (string, error)
+
+	// Profiles returns the available transcoding profiles.
+	Profiles() []types.TranscodeProfile
+
+	// EncoderArgs returns the FFmpeg decoder (before -i) and encoder arguments
+	// of a profile, with hardware acceleration applied.
+	EncoderArgs(profile string) (input, output []string, err error)
+
+	// HWAccel reports the available and selected hardware acceleration.
+	HWAccel() types.HWAccelInfo
+
+	// WaitReady blocks until a stream's HLS playlist is available.
+	WaitReady(ctx context.Context, streamID string) error
 
 	// GetStreamPath returns the path to the transcoded stream files.
 	GetStreamPath(streamID string) string
@@ -94,8 +120,11 @@ type Transcoder interface {
 
 // RecordingManager handles DVR functionality.
 type RecordingManager interface {
-	// StartRecording begins recording a stream.
-	StartRecording(ctx context.Context, url, name, clearKey string) (*types.Recording, error)
+	// StartRecording begins recording a stream. URLs handled by an extractor
+	// are resolved before recording starts. quality pins the variant of an HLS
+	// master playlist (see types.Recording.Quality). backups are sources
+	// switched to in turn when the one in use stalls or fails.
+	StartRecording(ctx context.Context, url, name, clearKey string, headers map[string]string, quality string, backups []string) (*types.Recording, error)
 
 	// StopRecording stops an active recording.
 	StopRecording(id string) error
@@ -106,19 +135,73 @@ type RecordingManager interface {
 	// ListRecordings returns all recordings.
 	ListRecordings() ([]*types.Recording, error)
 
+	// QueryRecordings returns a page of the recordings matching q and the
+	// number of matching recordings.
+	QueryRecordings(q types.RecordingQuery) ([]*types.Recording, int, error)
+
+	// ListVolumes returns the usage of the recording volumes.
+	ListVolumes() []types.RecordingVolume
+
 	// ListActiveRecordings returns recordings in progress.
 	ListActiveRecordings() ([]*types.Recording, error)
 
+	// ImportRecordings registers the media files in the recording volumes and
+	// watch folder that aren't recordings yet, and returns the new entries.
+	ImportRecordings(ctx context.Context) ([]*types.Recording, error)
+
+	// UpdateRecording edits the metadata of a recording: name, description,
+	// tags, poster and favorite flag.
+	UpdateRecording(id string, update types.RecordingUpdate) (*types.Recording, error)
+
 	// DeleteRecording removes a recording.
 	DeleteRecording(id string) error
 
 	// GetRecordingStream returns a reader for the recording.
 	GetRecordingStream(id string) (io.ReadCloser, error)
 
+	// AddSubtitle attaches a subtitle track to a recording: an external URL,
+	// or the subtitles embedded in the stream.
+	AddSubtitle(id string, sub types.RecordingSubtitle) (*types.RecordingSubtitle, error)
+
+	// AnalyzeRecording analyzes the file of a recording into a health
+	// report, which is stored with the recording.
+	AnalyzeRecording(ctx context.Context, id string) (*types.RecordingHealth, error)
+
+	// DetectChapters starts detecting the black and silent filler of a
+	// finished recording, which splits it into chapters.
+	DetectChapters(id string) error
+
 	// Close shuts down the manager.
 	Close() error
 }
 
+// Notifier delivers application events (recording lifecycle, etc.) to external systems.
+type Notifier interface {
+	// Notify sends an event. Implementations should respect ctx cancellation.
+	Notify(ctx context.Context, event types.Event) error
+}
+
+// ChannelCatalog lists the live channels offered by a provider (e.g. Vavoo).
+type ChannelCatalog interface {
+	// Catalog returns the provider's channels in the given groups (countries), or all if groups is empty.
+	Catalog(ctx context.Context, groups []string) ([]types.Channel, error)
+}
+
+// KeyStore maps DRM key IDs to ClearKey content keys.
+type KeyStore interface {
+	// Lookup returns the key (32 hex digits) for a KID (32 hex digits), if known.
+	Lookup(kid string) (string, bool)
+}
+
+// Uploader pushes finished recordings to external storage (S3, WebDAV).
+type Uploader interface {
+	// Name returns the storage backend name.
+	Name() string
+
+	// Upload stores size bytes from r at remotePath and returns the resulting location.
+	Upload(ctx context.Context, remotePath string, r io.Reader, size int64) (string, error)
+}
+
 // Registry is a generic interface for component registries.
 type Registry[T any] interface {
 	// Register adds a component to the registry.
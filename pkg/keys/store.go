@@ -0,0 +1,204 @@
+// Package keys persists ClearKey content keys by KID, so protected streams
+// can be decrypted without passing clearkey on every request.
+package keys
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"media-proxy-go/pkg/crypto"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+)
+
+var (
+	// ErrNotFound is returned when a KID is not in the store.
+	ErrNotFound = errors.New("key not found")
+	// ErrInvalidKey is returned for a KID or KEY that is not 16 bytes in hex,
+	// UUID or base64 form.
+	ErrInvalidKey = errors.New("invalid key")
+)
+
+// Key is a stored KID -> KEY mapping.
+type Key struct {
+	KID     string    `json:"kid"`             // 32 lowercase hex digits
+	Key     string    `json:"key"`             // 32 lowercase hex digits
+	Label   string    `json:"label,omitempty"` // Free-form note, e.g. the channel name
+	AddedAt time.Time `json:"added_at"`
+}
+
+// Store holds the keys in memory and persists them to a JSON file.
+type Store struct {
+	filePath string // Empty disables persistence
+	log      *logging.Logger
+
+	saveMu sync.Mutex // Serializes writes to filePath
+	mu     sync.RWMutex
+	keys   map[string]Key
+}
+
+// NewStore creates a key store persisted at filePath.
+func NewStore(filePath string, log *logging.Logger) *Store {
+	return &Store{
+		filePath: filePath,
+		log:      log.WithComponent("keys"),
+		keys:     make(map[string]Key),
+	}
+}
+
+// Load loads the persisted keys. A missing file is not an error.
+func (s *Store) Load() error {
+	if s.filePath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read key store: %w", err)
+	}
+
+	var list []Key
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to parse key store: %w", err)
+	}
+
+	keys := make(map[string]Key, len(list))
+	for _, entry := range list {
+		k, err := normalize(entry)
+		if err != nil {
+			s.log.Warn("skipping invalid key", "kid", entry.KID, "error", err)
+			continue
+		}
+		keys[k.KID] = k
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+
+	s.log.Info("loaded keys", "path", s.filePath, "count", len(keys))
+	return nil
+}
+
+// Lookup returns the key for a KID, if known.
+func (s *Store) Lookup(kid string) (string, bool) {
+	k, ok := s.Get(kid)
+	return k.Key, ok
+}
+
+// Get returns the stored entry for a KID.
+func (s *Store) Get(kid string) (Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[crypto.NormalizeKID(kid)]
+	return k, ok
+}
+
+// List returns all keys sorted by KID.
+func (s *Store) List() []Key {
+	s.mu.RLock()
+	list := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		list = append(list, k)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].KID < list[j].KID })
+	return list
+}
+
+// Count returns the number of stored keys.
+func (s *Store) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys)
+}
+
+// Set adds or replaces a key and persists the store. The stored entry is returned.
+func (s *Store) Set(k Key) (Key, error) {
+	k, err := normalize(k)
+	if err != nil {
+		return Key{}, err
+	}
+
+	s.mu.Lock()
+	if existing, ok := s.keys[k.KID]; ok && !existing.AddedAt.IsZero() {
+		k.AddedAt = existing.AddedAt
+	} else {
+		k.AddedAt = time.Now().UTC()
+	}
+	s.keys[k.KID] = k
+	s.mu.Unlock()
+
+	return k, s.save()
+}
+
+// Delete removes a key and persists the store.
+func (s *Store) Delete(kid string) error {
+	kid = crypto.NormalizeKID(kid)
+
+	s.mu.Lock()
+	if _, ok := s.keys[kid]; !ok {
+		s.mu.Unlock()
+		return ErrNotFound
+	}
+	delete(s.keys, kid)
+	s.mu.Unlock()
+
+	return s.save()
+}
+
+// save writes the keys to disk, readable only by the owner.
+func (s *Store) save() error {
+	if s.filePath == "" {
+		return nil
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	data, err := json.MarshalIndent(s.List(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal keys: %w", err)
+	}
+
+	if dir := filepath.Dir(s.filePath); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create key store directory: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(s.filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to save keys: %w", err)
+	}
+	return nil
+}
+
+// normalize validates a key and converts its KID and KEY, in any form
+// crypto.NormalizeKID accepts, to lowercase hex.
+func normalize(k Key) (Key, error) {
+	k.KID = crypto.NormalizeKID(k.KID)
+	k.Key = crypto.NormalizeKID(k.Key)
+	k.Label = strings.TrimSpace(k.Label)
+
+	if b, err := hex.DecodeString(k.KID); err != nil || len(b) != 16 {
+		return Key{}, fmt.Errorf("%w: kid must be 16 bytes in hex, UUID or base64", ErrInvalidKey)
+	}
+	if b, err := hex.DecodeString(k.Key); err != nil || len(b) != 16 {
+		return Key{}, fmt.Errorf("%w: key must be 16 bytes in hex, UUID or base64", ErrInvalidKey)
+	}
+	return k, nil
+}
+
+var _ interfaces.KeyStore = (*Store)(nil)
@@ -0,0 +1,83 @@
+package keys
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"media-proxy-go/pkg/logging"
+)
+
+func TestStore_SetLookupPersist(t *testing.T) {
+	log := logging.New("error", false, nil)
+	file := filepath.Join(t.TempDir(), "keys.json")
+
+	store := NewStore(file, log)
+	stored, err := store.Set(Key{KID: "01234567-89AB-CDEF-0123-456789ABCDEF", Key: "00112233445566778899AABBCCDDEEFF", Label: " News "})
+	if err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if stored.KID != "0123456789abcdef0123456789abcdef" || stored.Key != "00112233445566778899aabbccddeeff" || stored.Label != "News" {
+		t.Errorf("Set() stored %+v", stored)
+	}
+	if stored.AddedAt.IsZero() {
+		t.Error("Set() did not set AddedAt")
+	}
+
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	restarted := NewStore(file, log)
+	if err := restarted.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	key, ok := restarted.Lookup("0123456789ABCDEF0123456789ABCDEF")
+	if !ok || key != "00112233445566778899aabbccddeeff" {
+		t.Errorf("Lookup() = %q, %v", key, ok)
+	}
+	if key, ok := restarted.Lookup("ASNFZ4mrze8BI0VniavN7w"); !ok || key != "00112233445566778899aabbccddeeff" {
+		t.Errorf("Lookup(base64url) = %q, %v", key, ok)
+	}
+
+	// Replacing a key keeps when it was first added
+	updated, err := restarted.Set(Key{KID: stored.KID, Key: "ffeeddccbbaa99887766554433221100"})
+	if err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if !updated.AddedAt.Equal(stored.AddedAt) {
+		t.Errorf("AddedAt = %v, want %v", updated.AddedAt, stored.AddedAt)
+	}
+
+	if err := restarted.Delete(stored.KID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := restarted.Delete(stored.KID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete() error = %v, want ErrNotFound", err)
+	}
+	if restarted.Count() != 0 {
+		t.Errorf("Count() = %d, want 0", restarted.Count())
+	}
+}
+
+func TestStore_SetInvalid(t *testing.T) {
+	store := NewStore("", logging.New("error", false, nil))
+
+	tests := []struct {
+		name string
+		key  Key
+	}{
+		{"short kid", Key{KID: "0123", Key: "00112233445566778899aabbccddeeff"}},
+		{"non-hex key", Key{KID: "0123456789abcdef0123456789abcdef", Key: "zz112233445566778899aabbccddeeff"}},
+		{"missing key", Key{KID: "0123456789abcdef0123456789abcdef"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := store.Set(tt.key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Set() error = %v, want ErrInvalidKey", err)
+			}
+		})
+	}
+}
@@ -2,14 +2,24 @@
 package middleware
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"strings"
 	"time"
 
 	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/i18n"
 	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/playtoken"
+	"media-proxy-go/pkg/urlcrypt"
+	"media-proxy-go/pkg/urlsign"
+	"media-proxy-go/pkg/urlutil"
 )
 
 // Chain combines multiple middleware into a single handler.
@@ -56,12 +66,22 @@ func Logging(log *logging.Logger) func(http.Handler) http.Handler {
 	}
 }
 
+// corsAllowHeaders lists the request headers of players by name next to the
+// wildcard: the Chromium of older Chromecast firmware takes "*" literally and
+// rejects the Range requests of its receiver otherwise.
+const corsAllowHeaders = "*, Range, Content-Type, Accept, Origin, X-Requested-With"
+
+// corsExposeHeaders are the response headers cross-origin players read, e.g.
+// the Chromecast receiver checking the length and range of segments.
+const corsExposeHeaders = "Content-Length, Content-Range, Accept-Ranges"
+
 // CORS adds CORS headers to responses.
 func CORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "*")
+		w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+		w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
 		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 
 		if r.Method == http.MethodOptions {
@@ -73,43 +93,266 @@ func CORS(next http.Handler) http.Handler {
 	})
 }
 
-// Auth checks API password authentication.
+// Route classes with their own authentication requirements.
+const (
+	RoutePublic    = "public"    // Never require a password
+	RouteStreaming = "streaming" // Proxy and playback: the API password, unless STREAMING_PUBLIC
+	RouteAdmin     = "admin"     // API, dashboard data and destructive actions: ADMIN_PASSWORD, else the API password
+)
+
+// Auth checks password authentication for the class of each route.
 func Auth(cfg *config.Config, log *logging.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Skip auth if no password configured
-			if cfg.APIPassword == "" {
+			class := RouteClass(r.URL.Path)
+			if Authorized(cfg, r, class) {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			// Skip auth for public endpoints
-			if isPublicEndpoint(r.URL.Path) {
+			log.Warn("unauthorized request",
+				"path", r.URL.Path,
+				"class", class,
+				"remote_addr", r.RemoteAddr,
+			)
+			http.Error(w, i18n.Translate(i18n.FromRequest(r, cfg.UILanguage), "Unauthorized"), http.StatusUnauthorized)
+		})
+	}
+}
+
+type playbackTokenKey struct{}
+
+// PlaybackToken verifies the token parameter of signed playback URLs on
+// streaming routes. A valid token authorizes the request in place of the
+// password; a token issued to another client, for another path or upstream
+// target, or expired is rejected.
+func PlaybackToken(signer *playtoken.Signer, cfg *config.Config, log *logging.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.URL.Query().Get("token")
+			if token == "" || RouteClass(r.URL.Path) != RouteStreaming {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			// Check query parameter
-			if r.URL.Query().Get("api_password") == cfg.APIPassword {
-				next.ServeHTTP(w, r)
+			err := signer.Verify(token, ClientIP(r, cfg.TrustedProxies), r.UserAgent(), r.URL.Path, playtoken.Target(r.URL.Query()))
+			if err != nil {
+				log.Warn("rejected playback token",
+					"path", r.URL.Path,
+					"remote_addr", r.RemoteAddr,
+					"error", err,
+				)
+				http.Error(w, err.Error(), http.StatusForbidden)
 				return
 			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), playbackTokenKey{}, true)))
+		})
+	}
+}
 
-			// Check header
-			if r.Header.Get("X-API-Password") == cfg.APIPassword {
+// EncryptedQuery expands the enc parameter of encrypted proxy URLs into the
+// query string it was sealed from, before authentication and the handlers
+// see the request. Parameters the player added next to it (a playback token,
+// LL-HLS directives) are kept, the sealed ones taking precedence.
+func EncryptedQuery(c *urlcrypt.Cipher, log *logging.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			sealed := query.Get(urlcrypt.Param)
+			if sealed == "" {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			log.Warn("unauthorized request",
-				"path", r.URL.Path,
-				"remote_addr", r.RemoteAddr,
-			)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			opened, err := c.Open(sealed)
+			var params url.Values
+			if err == nil {
+				params, err = url.ParseQuery(opened)
+			}
+			if err != nil {
+				log.Warn("rejected encrypted URL", "path", r.URL.Path, "remote_addr", r.RemoteAddr, "error", err)
+				http.Error(w, urlcrypt.ErrInvalid.Error(), http.StatusBadRequest)
+				return
+			}
+
+			query.Del(urlcrypt.Param)
+			for name, values := range query {
+				if !params.Has(name) {
+					params[name] = values
+				}
+			}
+			r = r.Clone(r.Context())
+			r.URL.RawQuery = params.Encode()
+			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// ClientIP returns the address of the client, taken from X-Forwarded-For or
+// X-Real-IP when the request came from a trusted proxy.
+func ClientIP(r *http.Request, trusted []netip.Prefix) string {
+	if trustedProxy(r.RemoteAddr, trusted) {
+		if ip := firstValue(r.Header.Get("X-Forwarded-For")); ip != "" {
+			return ip
+		}
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+			return ip
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// RouteClass returns the authentication class of a path.
+func RouteClass(path string) string {
+	switch {
+	case isPublicEndpoint(path):
+		return RoutePublic
+	case isStreamingEndpoint(path):
+		return RouteStreaming
+	default:
+		return RouteAdmin
+	}
+}
+
+// Authorized reports whether a request carries the password its route class
+// requires, as the api_password query parameter, an X-API-Password header or
+// a bearer token. The admin password, a verified playback token and a link
+// signed with the API password (see urlsign) are accepted on streaming
+// routes too.
+func Authorized(cfg *config.Config, r *http.Request, class string) bool {
+	var accepted []string
+	switch class {
+	case RoutePublic:
+		return true
+	case RouteStreaming:
+		if cfg.StreamingPassword() == "" || r.Context().Value(playbackTokenKey{}) != nil {
+			return true
+		}
+		if query := r.URL.Query(); urlsign.Signed(query) &&
+			urlsign.Verify(cfg.StreamingPassword(), query, r.URL.Path, ClientIP(r, cfg.TrustedProxies), time.Now()) == nil {
+			return true
+		}
+		accepted = []string{cfg.StreamingPassword(), cfg.AdminPassword}
+	default:
+		if cfg.RequiredAdminPassword() == "" {
+			return true
+		}
+		accepted = []string{cfg.RequiredAdminPassword()}
+	}
+
+	candidates := []string{r.URL.Query().Get("api_password"), r.Header.Get("X-API-Password")}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		candidates = append(candidates, token)
+	}
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		for _, password := range accepted {
+			if password != "" && subtle.ConstantTimeCompare([]byte(candidate), []byte(password)) == 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PublicURL resolves the public base URL and origin of each request into its
+// context (see urlutil.PublicBaseURL). Requests from a trusted proxy are
+// addressed by their X-Forwarded-Host/Proto or Forwarded headers, keeping the
+// BASE_URL path; all others get BASE_URL.
+func PublicURL(cfg *config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			baseURL, origin := ResolvePublicURL(r, cfg.BaseURL, cfg.TrustedProxies)
+			next.ServeHTTP(w, r.WithContext(urlutil.WithPublicURL(r.Context(), baseURL, origin)))
+		})
+	}
+}
+
+// ResolvePublicURL returns the base for absolute URLs handed to the client of
+// r and the origin it addressed.
+func ResolvePublicURL(r *http.Request, baseURL string, trusted []netip.Prefix) (string, string) {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	origin := scheme + "://" + r.Host
+
+	if !trustedProxy(r.RemoteAddr, trusted) {
+		return baseURL, origin
+	}
+	host, proto := forwardedHost(r)
+	if host == "" {
+		return baseURL, origin
+	}
+	if proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	origin = scheme + "://" + host
+
+	path := ""
+	if u, err := url.Parse(baseURL); err == nil {
+		path = strings.TrimSuffix(u.Path, "/")
+	}
+	return origin + path, origin
+}
+
+// forwardedHost returns the host and scheme a proxy received the request on,
+// preferring X-Forwarded-Host/Proto over the first Forwarded element.
+func forwardedHost(r *http.Request) (host, proto string) {
+	if host = firstValue(r.Header.Get("X-Forwarded-Host")); host != "" {
+		return host, strings.ToLower(firstValue(r.Header.Get("X-Forwarded-Proto")))
+	}
+	element, _, _ := strings.Cut(r.Header.Get("Forwarded"), ",")
+	for _, pair := range strings.Split(element, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+		value = strings.Trim(value, `"`)
+		switch strings.ToLower(key) {
+		case "host":
+			host = value
+		case "proto":
+			proto = strings.ToLower(value)
+		}
+	}
+	if strings.ContainsAny(host, "/?#@ ") {
+		return "", ""
+	}
+	return host, proto
+}
+
+// firstValue returns the first entry of a comma-separated header added to by
+// each proxy in a chain.
+func firstValue(s string) string {
+	first, _, _ := strings.Cut(s, ",")
+	first = strings.TrimSpace(first)
+	if strings.ContainsAny(first, "/?#@ ") {
+		return ""
+	}
+	return first
+}
+
+// trustedProxy reports whether remoteAddr is within a trusted proxy range.
+func trustedProxy(remoteAddr string, trusted []netip.Prefix) bool {
+	if len(trusted) == 0 {
+		return false
+	}
+	addrPort, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 // Recovery recovers from panics and logs them.
 func Recovery(log *logging.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -147,6 +390,19 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Flush implements http.Flusher for streaming responses, over HTTP/1.1 and
+// HTTP/2 alike.
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // generateRequestID creates a random request ID.
 func generateRequestID() string {
 	b := make([]byte, 8)
@@ -154,17 +410,96 @@ func generateRequestID() string {
 	return hex.EncodeToString(b)
 }
 
+// StreamingOnly restricts a listener to the streaming endpoints, so the API
+// and dashboard are only reachable on listeners serving all traffic.
+func StreamingOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isStreamingEndpoint(r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isStreamingEndpoint returns true for the endpoints players, Stremio and
+// media servers use: proxying, playback, keys and the recording streams.
+func isStreamingEndpoint(path string) bool {
+	streamingPrefixes := []string{
+		"/proxy/",
+		"/segment/",
+		"/decrypt/",
+		"/ffmpeg_stream/",
+		"/stremio",
+		"/auto/",
+	}
+	for _, prefix := range streamingPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	streamingPaths := []string{
+		"/favicon.ico",
+		"/extractor",
+		"/extractor/video",
+		"/play",
+		"/download",
+		"/transcode",
+		"/license",
+		"/key",
+		"/playlist.m3u",
+		"/generate_url",
+		"/generate_urls",
+		"/discover.json",
+		"/lineup_status.json",
+		"/lineup.json",
+		"/lineup.post",
+		"/device.xml",
+	}
+	for _, p := range streamingPaths {
+		if path == p {
+			return true
+		}
+	}
+
+	// Recording playback linked from Stremio: /api/recordings/{id}/stream,
+	// /download and /subtitles/{sub}
+	rest, ok := strings.CutPrefix(path, "/api/recordings/")
+	if !ok {
+		return false
+	}
+	parts := strings.Split(rest, "/")
+	switch {
+	case len(parts) == 2:
+		return parts[1] == "stream" || parts[1] == "download"
+	case len(parts) == 3:
+		return parts[1] == "subtitles"
+	}
+	return false
+}
+
 // isPublicEndpoint returns true for endpoints that don't require auth.
 func isPublicEndpoint(path string) bool {
 	publicPaths := []string{
 		"/",
 		"/info",
 		"/favicon.ico",
+		// HDHomeRun discovery: media servers cannot send the API password
+		"/discover.json",
+		"/lineup_status.json",
+		"/lineup.json",
+		"/lineup.post",
+		"/device.xml",
+		// MediaFlow link generation checks the api_password of its body
+		"/generate_url",
+		"/generate_urls",
 	}
 	for _, p := range publicPaths {
 		if path == p {
 			return true
 		}
 	}
-	return strings.HasPrefix(path, "/static/")
+	// Stremio installs authenticate through their addon configuration
+	return strings.HasPrefix(path, "/static/") || path == "/stremio" || strings.HasPrefix(path, "/stremio/")
 }
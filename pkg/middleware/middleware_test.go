@@ -0,0 +1,276 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/playtoken"
+	"media-proxy-go/pkg/urlcrypt"
+	"media-proxy-go/pkg/urlsign"
+	"media-proxy-go/pkg/urlutil"
+)
+
+func TestResolvePublicURL(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+	const baseURL = "https://proxy.example.com/media"
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		wantBase   string
+		wantOrigin string
+	}{
+		{
+			name:       "direct request",
+			remoteAddr: "192.0.2.1:1234",
+			wantBase:   baseURL,
+			wantOrigin: "http://internal:7860",
+		},
+		{
+			name:       "untrusted proxy headers ignored",
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string]string{"X-Forwarded-Host": "evil.example.com", "X-Forwarded-Proto": "https"},
+			wantBase:   baseURL,
+			wantOrigin: "http://internal:7860",
+		},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "10.1.2.3:1234",
+			headers:    map[string]string{"X-Forwarded-Host": "tv.example.org", "X-Forwarded-Proto": "https"},
+			wantBase:   "https://tv.example.org/media",
+			wantOrigin: "https://tv.example.org",
+		},
+		{
+			name:       "first entry of a proxy chain",
+			remoteAddr: "[::1]:1234",
+			headers:    map[string]string{"X-Forwarded-Host": "tv.example.org:8443, inner", "X-Forwarded-Proto": "https, http"},
+			wantBase:   "https://tv.example.org:8443/media",
+			wantOrigin: "https://tv.example.org:8443",
+		},
+		{
+			name:       "Forwarded header",
+			remoteAddr: "10.1.2.3:1234",
+			headers:    map[string]string{"Forwarded": `for=192.0.2.60;proto=https;host="tv.example.org", for=10.0.0.2`},
+			wantBase:   "https://tv.example.org/media",
+			wantOrigin: "https://tv.example.org",
+		},
+		{
+			name:       "trusted proxy without forwarded host",
+			remoteAddr: "10.1.2.3:1234",
+			headers:    map[string]string{"X-Forwarded-Proto": "https"},
+			wantBase:   baseURL,
+			wantOrigin: "http://internal:7860",
+		},
+		{
+			name:       "invalid forwarded host",
+			remoteAddr: "10.1.2.3:1234",
+			headers:    map[string]string{"X-Forwarded-Host": "evil.example.com/path"},
+			wantBase:   baseURL,
+			wantOrigin: "http://internal:7860",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://internal:7860/stremio/manifest.json", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+
+			base, origin := ResolvePublicURL(r, baseURL, trusted)
+			if base != tt.wantBase {
+				t.Errorf("base = %q, want %q", base, tt.wantBase)
+			}
+			if origin != tt.wantOrigin {
+				t.Errorf("origin = %q, want %q", origin, tt.wantOrigin)
+			}
+		})
+	}
+}
+
+func TestPublicURL(t *testing.T) {
+	cfg := &config.Config{
+		BaseURL:        "http://localhost:7860",
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0")},
+	}
+
+	var got string
+	handler := PublicURL(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = urlutil.PublicBaseURL(r.Context(), "fallback")
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/record?url=x", nil)
+	r.Header.Set("X-Forwarded-Host", "tv.example.org")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got != "https://tv.example.org" {
+		t.Errorf("PublicBaseURL() = %q, want https://tv.example.org", got)
+	}
+}
+
+func TestRouteClass(t *testing.T) {
+	tests := map[string]string{
+		"/":                                RoutePublic,
+		"/discover.json":                   RoutePublic,
+		"/stremio/manifest.json":           RoutePublic,
+		"/proxy/manifest.m3u8":             RouteStreaming,
+		"/decrypt/segment.ts":              RouteStreaming,
+		"/api/recordings/abc/stream":       RouteStreaming,
+		"/api/recordings/abc/subtitles/s1": RouteStreaming,
+		"/api/recordings/abc/subtitles":    RouteAdmin,
+		"/api/recordings/abc/delete":       RouteAdmin,
+		"/api/recordings":                  RouteAdmin,
+		"/api/keys":                        RouteAdmin,
+		"/record":                          RouteAdmin,
+		"/record/stop/abc":                 RouteAdmin,
+	}
+	for path, want := range tests {
+		if got := RouteClass(path); got != want {
+			t.Errorf("RouteClass(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		class    string
+		password string
+		want     bool
+	}{
+		{"no passwords", config.Config{}, RouteAdmin, "", true},
+		{"api password on streaming", config.Config{APIPassword: "stream"}, RouteStreaming, "stream", true},
+		{"missing password on streaming", config.Config{APIPassword: "stream"}, RouteStreaming, "", false},
+		{"api password on admin", config.Config{APIPassword: "stream"}, RouteAdmin, "stream", true},
+		{"public streaming", config.Config{APIPassword: "stream", StreamingPublic: true}, RouteStreaming, "", true},
+		{"public streaming keeps admin protected", config.Config{APIPassword: "stream", StreamingPublic: true}, RouteAdmin, "", false},
+		{"admin password on admin", config.Config{APIPassword: "stream", AdminPassword: "admin"}, RouteAdmin, "admin", true},
+		{"api password rejected on admin", config.Config{APIPassword: "stream", AdminPassword: "admin"}, RouteAdmin, "stream", false},
+		{"admin password on streaming", config.Config{APIPassword: "stream", AdminPassword: "admin"}, RouteStreaming, "admin", true},
+		{"admin password only", config.Config{AdminPassword: "admin"}, RouteStreaming, "", true},
+		{"public route", config.Config{APIPassword: "stream", AdminPassword: "admin"}, RoutePublic, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.password != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.password)
+			}
+			if got := Authorized(&tt.cfg, r, tt.class); got != tt.want {
+				t.Errorf("Authorized() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	cfg := &config.Config{APIPassword: "stream"}
+	signed := url.Values{"d": {"http://origin/live.m3u8"}}
+	urlsign.Sign("stream", signed, time.Now().Add(time.Hour))
+	if r := httptest.NewRequest(http.MethodGet, "/proxy/stream?"+signed.Encode(), nil); !Authorized(cfg, r, RouteStreaming) {
+		t.Error("signed link not authorized on streaming")
+	}
+	if r := httptest.NewRequest(http.MethodGet, "/api/keys?"+signed.Encode(), nil); Authorized(cfg, r, RouteAdmin) {
+		t.Error("signed link authorized on admin")
+	}
+
+	pinned := url.Values{urlsign.ParamPath: {"/api/recordings/abc/stream"}}
+	urlsign.Sign("stream", pinned, time.Time{})
+	if r := httptest.NewRequest(http.MethodGet, "/api/recordings/abc/stream?"+pinned.Encode(), nil); !Authorized(cfg, r, RouteStreaming) {
+		t.Error("link signed for its path not authorized")
+	}
+	if r := httptest.NewRequest(http.MethodGet, "/api/recordings/other/stream?"+pinned.Encode(), nil); Authorized(cfg, r, RouteStreaming) {
+		t.Error("link signed for another path authorized")
+	}
+}
+
+func TestPlaybackToken(t *testing.T) {
+	cfg := &config.Config{APIPassword: "secret"}
+	log := logging.New("error", false, nil)
+	signer := playtoken.NewSigner("key", time.Hour, []string{playtoken.BindIP})
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		PlaybackToken(signer, cfg, log), Auth(cfg, log))
+
+	token := signer.Issue("192.0.2.1", "", "/proxy/stream", "x")
+	tests := []struct {
+		name       string
+		target     string
+		remoteAddr string
+		want       int
+	}{
+		{"token of the client", "/proxy/stream?url=x&token=" + token, "192.0.2.1:1234", http.StatusOK},
+		{"token of another client", "/proxy/stream?url=x&token=" + token, "198.51.100.7:1234", http.StatusForbidden},
+		{"token for another target", "/proxy/stream?url=y&token=" + token, "192.0.2.1:1234", http.StatusForbidden},
+		{"token on another path", "/decrypt/segment.mp4?url=x&token=" + token, "192.0.2.1:1234", http.StatusForbidden},
+		{"no token or password", "/proxy/stream?url=x", "192.0.2.1:1234", http.StatusUnauthorized},
+		{"token on an admin route", "/api/keys?token=" + token, "192.0.2.1:1234", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			r.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptedQuery(t *testing.T) {
+	log := logging.New("error", false, nil)
+	cipher := mustCipher(t, "secret")
+	var got url.Values
+	handler := EncryptedQuery(cipher, log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query()
+	}))
+
+	sealed := cipher.Seal("url=" + url.QueryEscape("http://origin/live.m3u8") + "&h_referer=site")
+	tests := []struct {
+		name   string
+		target string
+		want   int
+		url    string
+		token  string
+	}{
+		{"encrypted", "/proxy/stream?enc=" + sealed, http.StatusOK, "http://origin/live.m3u8", ""},
+		{"with outer params", "/proxy/stream?enc=" + sealed + "&token=t1&url=other", http.StatusOK, "http://origin/live.m3u8", "t1"},
+		{"plain", "/proxy/stream?url=plain", http.StatusOK, "plain", ""},
+		{"tampered", "/proxy/stream?enc=" + sealed[:len(sealed)-4], http.StatusBadRequest, "", ""},
+		{"other secret", "/proxy/stream?enc=" + mustCipher(t, "other").Seal("url=x"), http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			if got.Get("url") != tt.url || got.Get("token") != tt.token || got.Has("enc") {
+				t.Errorf("query = %v, want url=%q token=%q", got, tt.url, tt.token)
+			}
+		})
+	}
+}
+
+func mustCipher(t *testing.T, secret string) *urlcrypt.Cipher {
+	t.Helper()
+	c, err := urlcrypt.New(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"context"
+	"sync"
+
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/types"
+)
+
+// subscriberBuffer is the number of events queued per subscriber before new
+// events are dropped for it.
+const subscriberBuffer = 32
+
+// Broker fans events out to in-process subscribers such as dashboard SSE clients.
+// Slow subscribers miss events instead of blocking publishers.
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[chan types.Event]struct{}
+}
+
+// NewBroker creates an event broker.
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[chan types.Event]struct{}),
+	}
+}
+
+// Subscribe registers a subscriber. Call the returned function to unsubscribe.
+func (b *Broker) Subscribe() (<-chan types.Event, func()) {
+	ch := make(chan types.Event, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Publish delivers an event to all current subscribers without blocking.
+func (b *Broker) Publish(event types.Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Notify publishes the event. It never fails.
+func (b *Broker) Notify(ctx context.Context, event types.Event) error {
+	b.Publish(event)
+	return nil
+}
+
+// Subscribers returns the number of active subscribers.
+func (b *Broker) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
+
+// Ensure Broker implements Notifier.
+var _ interfaces.Notifier = (*Broker)(nil)
@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"context"
+	"testing"
+
+	"media-proxy-go/pkg/types"
+)
+
+func TestBroker_PublishSubscribe(t *testing.T) {
+	b := NewBroker()
+
+	events, unsubscribe := b.Subscribe()
+	if b.Subscribers() != 1 {
+		t.Fatalf("Subscribers() = %d, want 1", b.Subscribers())
+	}
+
+	if err := b.Notify(context.Background(), types.Event{Type: types.EventRecordingStarted}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if got := <-events; got.Type != types.EventRecordingStarted {
+		t.Errorf("received %q, want %q", got.Type, types.EventRecordingStarted)
+	}
+
+	unsubscribe()
+	unsubscribe() // Must be safe to call twice
+	if b.Subscribers() != 0 {
+		t.Errorf("Subscribers() after unsubscribe = %d, want 0", b.Subscribers())
+	}
+}
+
+func TestBroker_SlowSubscriberDoesNotBlock(t *testing.T) {
+	b := NewBroker()
+	events, unsubscribe := b.Subscribe()
+	defer unsubscribe()
+
+	// Publishing past the buffer must drop events instead of blocking
+	for i := 0; i < subscriberBuffer*2; i++ {
+		b.Publish(types.Event{Type: types.EventServerStats})
+	}
+	if len(events) != subscriberBuffer {
+		t.Errorf("buffered events = %d, want %d", len(events), subscriberBuffer)
+	}
+}
@@ -0,0 +1,26 @@
+package notify
+
+import (
+	"context"
+	"errors"
+
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/types"
+)
+
+// Multi delivers events to several notifiers.
+type Multi []interfaces.Notifier
+
+// Notify sends the event to every notifier; the returned error joins all failures.
+func (m Multi) Notify(ctx context.Context, event types.Event) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Ensure Multi implements Notifier.
+var _ interfaces.Notifier = Multi(nil)
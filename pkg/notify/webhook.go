@@ -0,0 +1,83 @@
+// Package notify delivers application events to external systems
+// such as Discord, Home Assistant or any HTTP endpoint accepting JSON.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+// Webhook posts events as JSON to one or more URLs.
+type Webhook struct {
+	urls       []string
+	httpClient *http.Client
+	log        *logging.Logger
+}
+
+// NewWebhook creates a webhook notifier for the given URLs.
+func NewWebhook(urls []string, timeout time.Duration, log *logging.Logger) *Webhook {
+	return &Webhook{
+		urls: urls,
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+		log: log.WithComponent("webhook"),
+	}
+}
+
+// Notify posts the event to every configured URL.
+// All URLs are attempted even if some fail; the returned error joins all failures.
+func (w *Webhook) Notify(ctx context.Context, event types.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	var errs []error
+	for _, u := range w.urls {
+		if err := w.post(ctx, u, body); err != nil {
+			w.log.Warn("webhook delivery failed", "url", u, "event", event.Type, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		w.log.Debug("webhook delivered", "url", u, "event", event.Type)
+	}
+
+	return errors.Join(errs...)
+}
+
+// post sends a single webhook request.
+func (w *Webhook) post(ctx context.Context, u string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "MediaProxy-Webhook")
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Ensure Webhook implements Notifier.
+var _ interfaces.Notifier = (*Webhook)(nil)
@@ -0,0 +1,81 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+func TestWebhook_Notify_Success(t *testing.T) {
+	log := logging.New("error", false, nil)
+
+	var received types.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("expected Content-Type application/json, got %s", r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("failed to decode event: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	webhook := NewWebhook([]string{server.URL}, 5*time.Second, log)
+	event := types.Event{
+		Type:      types.EventRecordingCompleted,
+		Timestamp: 1700000000,
+		Message:   "Recording completed: test",
+		Recording: &types.RecordingSummary{ID: "rec_1", Name: "test", Status: "completed"},
+	}
+
+	if err := webhook.Notify(context.Background(), event); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if received.Type != types.EventRecordingCompleted {
+		t.Errorf("event = %q, want %q", received.Type, types.EventRecordingCompleted)
+	}
+	if received.Message != event.Message {
+		t.Errorf("content = %q, want %q", received.Message, event.Message)
+	}
+	if received.Recording == nil || received.Recording.ID != "rec_1" {
+		t.Errorf("recording = %+v, want ID rec_1", received.Recording)
+	}
+}
+
+func TestWebhook_Notify_PartialFailure(t *testing.T) {
+	log := logging.New("error", false, nil)
+
+	var okCalls int
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		okCalls++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer okServer.Close()
+
+	failServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failServer.Close()
+
+	// The failing URL comes first to check that delivery continues past it
+	webhook := NewWebhook([]string{failServer.URL, okServer.URL}, 5*time.Second, log)
+	err := webhook.Notify(context.Background(), types.Event{Type: types.EventRecordingStarted})
+
+	if err == nil {
+		t.Error("expected error from failing webhook, got nil")
+	}
+	if okCalls != 1 {
+		t.Errorf("ok webhook called %d times, want 1", okCalls)
+	}
+}
@@ -0,0 +1,139 @@
+// Package playtoken signs playback URLs, binding them to the client they were
+// issued to and to the request they were issued for, so shared or stolen
+// links cannot be replayed by other clients or for other streams.
+package playtoken
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client attributes a token can be bound to.
+const (
+	BindIP        = "ip"
+	BindUserAgent = "ua"
+)
+
+// Token verification errors.
+var (
+	ErrInvalid = errors.New("invalid playback token")
+	ErrExpired = errors.New("playback token expired")
+)
+
+// tokenSize is the size of a token without its scope: the expiry and the HMAC.
+const tokenSize = 8 + sha256.Size
+
+// Signer issues and verifies playback tokens. A token carries its expiry and
+// an HMAC over the expiry, the route path, the upstream target and the bound
+// client attributes, which are not stored in the token itself. A scoped token
+// also carries the prefix its targets must start with.
+type Signer struct {
+	key    []byte
+	ttl    time.Duration
+	bindIP bool
+	bindUA bool
+}
+
+// NewSigner creates a signer. An empty secret uses a random key, so tokens
+// do not survive a restart; bind lists the client attributes (BindIP,
+// BindUserAgent) tokens are bound to.
+func NewSigner(secret string, ttl time.Duration, bind []string) *Signer {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	s := &Signer{key: key, ttl: ttl}
+	for _, b := range bind {
+		switch b {
+		case BindIP:
+			s.bindIP = true
+		case BindUserAgent:
+			s.bindUA = true
+		}
+	}
+	return s
+}
+
+// Target returns the upstream target of a request to the proxy: its url
+// parameter, or d for MediaFlow-style routes. Tokens are only valid for the
+// target they were issued for.
+func Target(query url.Values) string {
+	if target := query.Get("url"); target != "" {
+		return target
+	}
+	return query.Get("d")
+}
+
+// Issue returns a token for the client with the given IP and user agent,
+// valid for requests to path with target (see Target).
+func (s *Signer) Issue(ip, userAgent, path, target string) string {
+	return s.issue(ip, userAgent, path, target, false)
+}
+
+// IssueScoped returns a token like Issue, valid for every target starting
+// with scope, e.g. the segments next to a DASH manifest the player requests
+// on its own. An empty scope issues a token for the empty target only.
+func (s *Signer) IssueScoped(ip, userAgent, path, scope string) string {
+	return s.issue(ip, userAgent, path, scope, scope != "")
+}
+
+func (s *Signer) issue(ip, userAgent, path, target string, scoped bool) string {
+	token := make([]byte, 8, tokenSize+len(target))
+	binary.BigEndian.PutUint64(token, uint64(time.Now().Add(s.ttl).Unix()))
+	token = append(token, s.mac(token[:8], ip, userAgent, path, target, scoped)...)
+	if scoped {
+		token = append(token, target...)
+	}
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// Verify checks that a token was issued by this signer to the client with the
+// given IP and user agent for a request to path with target, and has not
+// expired.
+func (s *Signer) Verify(token, ip, userAgent, path, target string) error {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) < tokenSize {
+		return ErrInvalid
+	}
+	signed, scoped := target, len(data) > tokenSize
+	if scoped {
+		if signed = string(data[tokenSize:]); !strings.HasPrefix(target, signed) {
+			return ErrInvalid
+		}
+	}
+	if !hmac.Equal(data[8:tokenSize], s.mac(data[:8], ip, userAgent, path, signed, scoped)) {
+		return ErrInvalid
+	}
+	if time.Now().Unix() > int64(binary.BigEndian.Uint64(data[:8])) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// mac signs the expiry, the request and the bound client attributes. target
+// is the scope of a scoped token.
+func (s *Signer) mac(expiry []byte, ip, userAgent, path, target string, scoped bool) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write(expiry)
+	h.Write([]byte("\x00path\x00" + path))
+	if scoped {
+		h.Write([]byte("\x00scope\x00" + target))
+	} else {
+		h.Write([]byte("\x00target\x00" + target))
+	}
+	if s.bindIP {
+		h.Write([]byte("\x00ip\x00" + ip))
+	}
+	if s.bindUA {
+		h.Write([]byte("\x00ua\x00" + userAgent))
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,62 @@
+package playtoken
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	s := NewSigner("secret", time.Hour, []string{BindIP, BindUserAgent})
+	token := s.Issue("192.0.2.1", "VLC/3.0", "/proxy/stream", "http://origin/seg1.ts")
+	scoped := s.IssueScoped("192.0.2.1", "VLC/3.0", "/proxy/stream", "http://origin/live/")
+
+	tests := []struct {
+		name      string
+		token     string
+		ip        string
+		userAgent string
+		path      string
+		target    string
+		want      error
+	}{
+		{"same client", token, "192.0.2.1", "VLC/3.0", "/proxy/stream", "http://origin/seg1.ts", nil},
+		{"other IP", token, "192.0.2.2", "VLC/3.0", "/proxy/stream", "http://origin/seg1.ts", ErrInvalid},
+		{"other user agent", token, "192.0.2.1", "curl/8.0", "/proxy/stream", "http://origin/seg1.ts", ErrInvalid},
+		{"other path", token, "192.0.2.1", "VLC/3.0", "/decrypt/segment.mp4", "http://origin/seg1.ts", ErrInvalid},
+		{"other target", token, "192.0.2.1", "VLC/3.0", "/proxy/stream", "http://evil/relay", ErrInvalid},
+		{"scoped target", scoped, "192.0.2.1", "VLC/3.0", "/proxy/stream", "http://origin/live/seg2.m4s", nil},
+		{"target out of scope", scoped, "192.0.2.1", "VLC/3.0", "/proxy/stream", "http://evil/relay", ErrInvalid},
+		{"scope on another path", scoped, "192.0.2.1", "VLC/3.0", "/proxy/hls/manifest.m3u8", "http://origin/live/index.m3u8", ErrInvalid},
+		{"tampered", token[:len(token)-2] + "AA", "192.0.2.1", "VLC/3.0", "/proxy/stream", "http://origin/seg1.ts", ErrInvalid},
+		{"widened scope", scoped[:len(scoped)-8], "192.0.2.1", "VLC/3.0", "/proxy/stream", "http://origin/live/seg2.m4s", ErrInvalid},
+		{"garbage", "not-a-token", "192.0.2.1", "VLC/3.0", "/proxy/stream", "http://origin/seg1.ts", ErrInvalid},
+		{"other key", NewSigner("other", time.Hour, nil).Issue("192.0.2.1", "VLC/3.0", "/proxy/stream", "http://origin/seg1.ts"), "192.0.2.1", "VLC/3.0", "/proxy/stream", "http://origin/seg1.ts", ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Verify(tt.token, tt.ip, tt.userAgent, tt.path, tt.target); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSigner_Binding(t *testing.T) {
+	s := NewSigner("secret", time.Hour, []string{BindIP})
+	if err := s.Verify(s.Issue("192.0.2.1", "VLC/3.0", "/proxy/stream", "x"), "192.0.2.1", "Kodi/20", "/proxy/stream", "x"); err != nil {
+		t.Errorf("IP-bound token rejected for another user agent: %v", err)
+	}
+
+	random := NewSigner("", time.Hour, nil)
+	if err := random.Verify(random.Issue("", "", "/proxy/stream", "x"), "192.0.2.9", "any", "/proxy/stream", "x"); err != nil {
+		t.Errorf("unbound token rejected: %v", err)
+	}
+}
+
+func TestSigner_Expired(t *testing.T) {
+	s := NewSigner("secret", -time.Second, nil)
+	if err := s.Verify(s.Issue("192.0.2.1", "", "/proxy/stream", "x"), "192.0.2.1", "", "/proxy/stream", "x"); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrExpired)
+	}
+}
@@ -2,12 +2,20 @@
 package registry
 
 import (
+	"context"
+	"slices"
+	"strings"
 	"sync"
 
 	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/throttle"
 	"media-proxy-go/pkg/types"
 )
 
+// AnyExtractor is the limits key applying to the extractors without limits
+// of their own, each paced separately.
+const AnyExtractor = "*"
+
 // StreamHandlerRegistry manages stream handlers.
 type StreamHandlerRegistry struct {
 	mu       sync.RWMutex
@@ -78,6 +86,10 @@ type ExtractorRegistry struct {
 	extractors []interfaces.Extractor
 	byName     map[string]interfaces.Extractor
 	fallback   interfaces.Extractor
+
+	limitsMu sync.Mutex
+	limits   map[string]throttle.Limit    // By lowercase extractor name or AnyExtractor
+	limiters map[string]*throttle.Limiter // By lowercase extractor name, nil when unlimited
 }
 
 // NewExtractorRegistry creates a new extractor registry.
@@ -127,6 +139,39 @@ func (r *ExtractorRegistry) GetByName(name string) interfaces.Extractor {
 	return r.fallback
 }
 
+// Lookup returns the extractor named name, ignoring case, or nil. Unlike
+// GetByName it doesn't fall back.
+func (r *ExtractorRegistry) Lookup(name string) interfaces.Extractor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, e := range r.extractors {
+		if strings.EqualFold(e.Name(), name) {
+			return e
+		}
+	}
+	if r.fallback != nil && strings.EqualFold(r.fallback.Name(), name) {
+		return r.fallback
+	}
+	return nil
+}
+
+// Names returns the sorted names of the extractors, the fallback included.
+func (r *ExtractorRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.extractors)+1)
+	for _, e := range r.extractors {
+		names = append(names, e.Name())
+	}
+	if r.fallback != nil {
+		names = append(names, r.fallback.Name())
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
 // All returns all registered extractors.
 func (r *ExtractorRegistry) All() []interfaces.Extractor {
 	r.mu.RLock()
@@ -137,6 +182,64 @@ func (r *ExtractorRegistry) All() []interfaces.Extractor {
 	return result
 }
 
+// SetLimits sets how the extractions of each extractor are paced, by name
+// (case-insensitive) or AnyExtractor.
+func (r *ExtractorRegistry) SetLimits(limits map[string]throttle.Limit) {
+	r.limitsMu.Lock()
+	defer r.limitsMu.Unlock()
+	r.limits = make(map[string]throttle.Limit, len(limits))
+	for name, limit := range limits {
+		r.limits[strings.ToLower(name)] = limit
+	}
+	r.limiters = make(map[string]*throttle.Limiter)
+}
+
+// limiter returns the limiter of the extractor named name, nil if its
+// extractions aren't limited.
+func (r *ExtractorRegistry) limiter(name string) *throttle.Limiter {
+	r.limitsMu.Lock()
+	defer r.limitsMu.Unlock()
+	if r.limits == nil {
+		return nil
+	}
+	name = strings.ToLower(name)
+	l, ok := r.limiters[name]
+	if !ok {
+		limit, ok := r.limits[name]
+		if !ok {
+			limit = r.limits[AnyExtractor]
+		}
+		l = throttle.New(limit)
+		r.limiters[name] = l
+	}
+	return l
+}
+
+// Extract runs an extraction of extractor within its limits, queueing it
+// while the extractor is at its concurrency cap or rate.
+func (r *ExtractorRegistry) Extract(ctx context.Context, extractor interfaces.Extractor, url string, opts interfaces.ExtractOptions) (*types.ExtractResult, error) {
+	release, err := r.limiter(extractor.Name()).Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return extractor.Extract(ctx, url, opts)
+}
+
+// LimitStats returns the counters of the limited extractors that ran, by
+// lowercase name.
+func (r *ExtractorRegistry) LimitStats() map[string]types.ThrottleStats {
+	r.limitsMu.Lock()
+	defer r.limitsMu.Unlock()
+	stats := make(map[string]types.ThrottleStats)
+	for name, l := range r.limiters {
+		if l != nil {
+			stats[name] = l.Stats()
+		}
+	}
+	return stats
+}
+
 // Close closes all registered extractors.
 func (r *ExtractorRegistry) Close() error {
 	r.mu.Lock()
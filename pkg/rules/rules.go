@@ -0,0 +1,243 @@
+// Package rules loads signed extraction rules bundles: regex patterns, URL
+// templates and header sets per extractor, fetched from a URL so that site
+// changes can be shipped without a new release.
+package rules
+
+import (
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+const (
+	// fetchTimeout bounds the download of a bundle.
+	fetchTimeout = 30 * time.Second
+
+	// maxBundleSize bounds the size of a bundle document.
+	maxBundleSize = 1 << 20
+)
+
+var (
+	// ErrSignature is returned for a bundle not signed with the configured key.
+	ErrSignature = errors.New("invalid rules bundle signature")
+	// ErrRollback is returned for a bundle older than the loaded one, such
+	// as a replayed earlier bundle.
+	ErrRollback = errors.New("rules bundle older than the loaded one")
+)
+
+// Bundle is the content of a rules bundle.
+type Bundle struct {
+	Version    string                          `json:"version"`
+	Extractors map[string]types.ExtractorRules `json:"extractors"` // By extractor name
+}
+
+// envelope is the document served at the rules URL: the bundle JSON, base64
+// encoded, and the Ed25519 signature of those bytes.
+type envelope struct {
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// Open verifies a bundle document with key and decodes its bundle.
+func Open(data []byte, key ed25519.PublicKey) (*Bundle, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("invalid rules bundle: %w", err)
+	}
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules bundle payload: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(env.Signature)
+	if err != nil || !ed25519.Verify(key, payload, sig) {
+		return nil, ErrSignature
+	}
+
+	var bundle Bundle
+	if err := json.Unmarshal(payload, &bundle); err != nil {
+		return nil, fmt.Errorf("invalid rules bundle payload: %w", err)
+	}
+	return &bundle, nil
+}
+
+// Seal signs a bundle with key into the document Open reads, for publishing
+// bundles.
+func Seal(bundle *Bundle, key ed25519.PrivateKey) ([]byte, error) {
+	payload, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope{
+		Payload:   base64.StdEncoding.EncodeToString(payload),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+	})
+}
+
+// ParsePublicKey decodes a base64 Ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("rules public key must be a base64 Ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Loader fetches the rules bundle and applies it to the extractors.
+type Loader struct {
+	url       string
+	key       ed25519.PublicKey
+	client    interfaces.HTTPClient
+	consumers []interfaces.RulesConsumer
+	log       *logging.Logger
+
+	mu     sync.Mutex // Serializes loads
+	status types.RulesStatus
+}
+
+// NewLoader creates a loader of the bundle at url, signed with the base64
+// Ed25519 publicKey, for consumers.
+func NewLoader(url, publicKey string, client interfaces.HTTPClient, consumers []interfaces.RulesConsumer, log *logging.Logger) (*Loader, error) {
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Loader{
+		url:       url,
+		key:       key,
+		client:    client,
+		consumers: consumers,
+		log:       log.WithComponent("rules"),
+		status:    types.RulesStatus{URL: url},
+	}, nil
+}
+
+// Load fetches and verifies the bundle and applies it. Extractors missing
+// from the bundle get their built-in rules back. When the bundle can't be
+// fetched or verified nothing changes; an extractor rejecting its rules keeps
+// its previous ones while the others are updated.
+func (l *Loader) Load(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.load(ctx)
+	l.status.CheckedAt = time.Now().Unix()
+	l.status.Error = ""
+	if err != nil {
+		l.status.Error = err.Error()
+	}
+	return err
+}
+
+// load does the work of Load; the caller holds mu.
+func (l *Loader) load(ctx context.Context) error {
+	data, err := l.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	bundle, err := Open(data, l.key)
+	if err != nil {
+		return err
+	}
+	if l.status.Version != "" && CompareVersions(bundle.Version, l.status.Version) < 0 {
+		return fmt.Errorf("%w: %q, loaded %q", ErrRollback, bundle.Version, l.status.Version)
+	}
+	for name := range bundle.Extractors {
+		if !slices.ContainsFunc(l.consumers, func(c interfaces.RulesConsumer) bool { return c.Name() == name }) {
+			l.log.Warn("rules bundle names an extractor without rules support", "extractor", name)
+		}
+	}
+
+	var errs []error
+	var applied []string
+	for _, c := range l.consumers {
+		rules, ok := bundle.Extractors[c.Name()]
+		if err := c.ApplyRules(rules); err != nil {
+			errs = append(errs, fmt.Errorf("rules of %s: %w", c.Name(), err))
+			continue
+		}
+		if ok {
+			applied = append(applied, c.Name())
+		}
+	}
+	sort.Strings(applied)
+
+	l.status.Version = bundle.Version
+	l.status.LoadedAt = time.Now().Unix()
+	l.status.Extractors = applied
+	l.log.Info("loaded extraction rules", "version", bundle.Version, "extractors", applied)
+	return errors.Join(errs...)
+}
+
+// CompareVersions compares two bundle versions by their dot-separated parts,
+// numerically where both parts are numbers ("2026.10.2" < "2026.10.10"),
+// returning -1, 0 or +1.
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range max(len(as), len(bs)) {
+		switch {
+		case i >= len(as):
+			return -1
+		case i >= len(bs):
+			return 1
+		}
+		c := cmp.Compare(as[i], bs[i])
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		if aErr == nil && bErr == nil {
+			c = cmp.Compare(an, bn)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// fetch downloads the bundle document.
+func (l *Loader) fetch(ctx context.Context) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules URL: %w", err)
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch rules: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch rules: status %d", resp.StatusCode)
+	}
+	data, err := httpclient.ReadLimited(resp.Body, maxBundleSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch rules: %w", err)
+	}
+	return data, nil
+}
+
+// Status returns the outcome of the last load.
+func (l *Loader) Status() types.RulesStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	status := l.status
+	status.Extractors = append([]string(nil), l.status.Extractors...)
+	return status
+}
@@ -0,0 +1,163 @@
+package rules
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
+)
+
+// fakeConsumer records the rules it is given and rejects them with err.
+type fakeConsumer struct {
+	name  string
+	err   error
+	rules *types.ExtractorRules
+}
+
+func (c *fakeConsumer) Name() string { return c.name }
+
+func (c *fakeConsumer) ApplyRules(rules types.ExtractorRules) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.rules = &rules
+	return nil
+}
+
+func TestOpen(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	bundle := &Bundle{
+		Version: "2026.10.1",
+		Extractors: map[string]types.ExtractorRules{
+			"dlhd": {Templates: map[string]string{"default": "https://cdn.example/{channel}.m3u8"}},
+		},
+	}
+	data, err := Seal(bundle, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := Open(data, pub)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if opened.Version != "2026.10.1" || opened.Extractors["dlhd"].Templates["default"] != "https://cdn.example/{channel}.m3u8" {
+		t.Errorf("Open() = %+v", opened)
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	if _, err := Open(data, otherPub); !errors.Is(err, ErrSignature) {
+		t.Errorf("Open() with another key error = %v, want ErrSignature", err)
+	}
+	if _, err := Open([]byte("not json"), pub); err == nil {
+		t.Error("Open() of an invalid document succeeded")
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(nil)
+	key, err := ParsePublicKey(base64.StdEncoding.EncodeToString(pub))
+	if err != nil || !key.Equal(pub) {
+		t.Errorf("ParsePublicKey() = %v, %v", key, err)
+	}
+	for _, s := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := ParsePublicKey(s); err == nil {
+			t.Errorf("ParsePublicKey(%q) succeeded", s)
+		}
+	}
+}
+
+func TestLoader_Load(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	data, _ := Seal(&Bundle{
+		Version: "7",
+		Extractors: map[string]types.ExtractorRules{
+			"dlhd":   {Headers: map[string]string{"Referer": "https://example.com/"}},
+			"broken": {Headers: map[string]string{"X": "1"}},
+		},
+	}, priv)
+	served := data
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(served)
+	}))
+	defer srv.Close()
+
+	dlhd := &fakeConsumer{name: "dlhd"}
+	vavoo := &fakeConsumer{name: "vavoo"}
+	broken := &fakeConsumer{name: "broken", err: errors.New("rejected")}
+	log := logging.New("error", false, io.Discard)
+	l, err := NewLoader(srv.URL, base64.StdEncoding.EncodeToString(pub), http.DefaultClient,
+		[]interfaces.RulesConsumer{dlhd, vavoo, broken}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Load(context.Background()); err == nil {
+		t.Error("Load() error = nil, want the rejected rules of broken")
+	}
+	if dlhd.rules == nil || dlhd.rules.Headers["Referer"] != "https://example.com/" {
+		t.Errorf("dlhd rules = %+v", dlhd.rules)
+	}
+	if vavoo.rules == nil || len(vavoo.rules.Headers) != 0 {
+		t.Errorf("vavoo rules = %+v, want empty rules", vavoo.rules)
+	}
+	status := l.Status()
+	if status.Version != "7" || len(status.Extractors) != 1 || status.Extractors[0] != "dlhd" || status.Error == "" {
+		t.Errorf("Status() = %+v", status)
+	}
+
+	// A bundle with a bad signature changes nothing
+	dlhd.rules = nil
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+	served, _ = Seal(&Bundle{Version: "8"}, otherPriv)
+	if err := l.Load(context.Background()); !errors.Is(err, ErrSignature) {
+		t.Errorf("Load() error = %v, want ErrSignature", err)
+	}
+	if dlhd.rules != nil {
+		t.Error("rules of an unverified bundle were applied")
+	}
+	if status := l.Status(); status.Version != "7" || status.Error == "" {
+		t.Errorf("Status() = %+v after a failed load", status)
+	}
+
+	// A replayed older bundle is rejected, even with a valid signature
+	served, _ = Seal(&Bundle{Version: "6", Extractors: map[string]types.ExtractorRules{"dlhd": {}}}, priv)
+	if err := l.Load(context.Background()); !errors.Is(err, ErrRollback) {
+		t.Errorf("Load() of an older bundle error = %v, want ErrRollback", err)
+	}
+	if dlhd.rules != nil {
+		t.Error("rules of an older bundle were applied")
+	}
+	served, _ = Seal(&Bundle{Version: "7"}, priv)
+	if err := l.Load(context.Background()); errors.Is(err, ErrRollback) || dlhd.rules == nil {
+		t.Errorf("Load() of the same version = %v, want it applied", err)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2026.10.1", "2026.10.1", 0},
+		{"2026.10.2", "2026.10.10", -1},
+		{"2026.11", "2026.10.5", 1},
+		{"2026.10", "2026.10.1", -1},
+		{"7", "10", -1},
+		{"2026.10.1b", "2026.10.1a", 1},
+		{"", "1", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
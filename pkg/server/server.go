@@ -3,7 +3,9 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,14 +15,21 @@ import (
 	"media-proxy-go/pkg/config"
 	"media-proxy-go/pkg/logging"
 	"media-proxy-go/pkg/middleware"
+	"media-proxy-go/pkg/playtoken"
+	"media-proxy-go/pkg/tracing"
+	"media-proxy-go/pkg/urlcrypt"
 )
 
 // Server is the main HTTP server.
 type Server struct {
-	httpServer *http.Server
-	cfg        *config.Config
-	log        *logging.Logger
-	router     *http.ServeMux
+	servers  []*http.Server // One per listener
+	redirect *http.Server   // Plain HTTP listener when serving HTTPS
+	cfg      *config.Config
+	log      *logging.Logger
+	router   *http.ServeMux
+	tracer   *tracing.Tracer
+	tokens   *playtoken.Signer
+	cipher   *urlcrypt.Cipher
 }
 
 // New creates a new server with the given configuration.
@@ -39,24 +48,92 @@ func (s *Server) Router() *http.ServeMux {
 	return s.router
 }
 
-// Start starts the HTTP server and blocks until shutdown.
+// SetTracer enables request tracing.
+func (s *Server) SetTracer(t *tracing.Tracer) {
+	s.tracer = t
+}
+
+// SetPlaybackTokens enables signed playback URLs.
+func (s *Server) SetPlaybackTokens(signer *playtoken.Signer) {
+	s.tokens = signer
+}
+
+// SetURLCipher enables encrypted proxy URLs.
+func (s *Server) SetURLCipher(c *urlcrypt.Cipher) {
+	s.cipher = c
+}
+
+// Start starts the HTTP server on its listeners and blocks until shutdown.
 func (s *Server) Start() error {
-	// Build middleware chain
-	handler := middleware.Chain(
-		s.router,
-		middleware.Recovery(s.log),
-		middleware.Logging(s.log),
-		middleware.CORS,
-		middleware.Auth(s.cfg, s.log),
-		middleware.RequestID,
-	)
+	listeners := s.cfg.Listeners
+	if len(listeners) == 0 {
+		listeners = []config.Listener{{Network: "tcp", Address: fmt.Sprintf(":%d", s.cfg.Port), Role: config.ListenerRoleAll}}
+	}
+
+	tlsEnabled := s.cfg.TLSEnabled()
+	var tlsConfig *tls.Config
+	if tlsEnabled {
+		var httpHandler http.Handler
+		var err error
+		tlsConfig, httpHandler, err = tlsSetup(s.cfg, s.log)
+		if err != nil {
+			return err
+		}
+		if s.cfg.HTTPPort > 0 {
+			s.redirect = &http.Server{
+				Addr:         fmt.Sprintf(":%d", s.cfg.HTTPPort),
+				Handler:      httpHandler,
+				ReadTimeout:  s.cfg.ReadTimeout,
+				WriteTimeout: s.cfg.ReadTimeout,
+				IdleTimeout:  s.cfg.IdleTimeout,
+			}
+			go func() {
+				s.log.Info("http listener starting", "port", s.cfg.HTTPPort)
+				if err := s.redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					s.log.Error("http listener error", "error", err)
+				}
+			}()
+		}
+	}
+
+	// Bind every listener before serving, so a bad address fails startup
+	var lns []net.Listener
+	for _, l := range listeners {
+		ln, err := listen(l)
+		if err != nil {
+			for _, ln := range lns {
+				ln.Close()
+			}
+			return err
+		}
+		lns = append(lns, ln)
+	}
 
-	s.httpServer = &http.Server{
-		Addr:         fmt.Sprintf(":%d", s.cfg.Port),
-		Handler:      handler,
-		ReadTimeout:  s.cfg.ReadTimeout,
-		WriteTimeout: s.cfg.WriteTimeout,
-		IdleTimeout:  s.cfg.IdleTimeout,
+	errs := make(chan error, len(listeners))
+	for i, l := range listeners {
+		// Unix sockets sit behind a local reverse proxy and stay plaintext
+		secure := tlsEnabled && l.Network == "tcp"
+		srv := &http.Server{
+			Handler:      s.handler(l.Role),
+			ReadTimeout:  s.cfg.ReadTimeout,
+			WriteTimeout: s.cfg.WriteTimeout,
+			IdleTimeout:  s.cfg.IdleTimeout,
+			Protocols:    protocols(s.cfg, secure),
+		}
+		if secure {
+			srv.TLSConfig = tlsConfig
+		}
+		s.servers = append(s.servers, srv)
+
+		s.log.Info("server starting", "network", l.Network, "address", l.Address, "role", l.Role,
+			"tls", secure, "http2", srv.Protocols.HTTP2(), "h2c", srv.Protocols.UnencryptedHTTP2())
+		go func(ln net.Listener) {
+			if secure {
+				errs <- srv.ServeTLS(ln, "", "")
+			} else {
+				errs <- srv.Serve(ln)
+			}
+		}(lns[i])
 	}
 
 	// Graceful shutdown
@@ -71,16 +148,19 @@ func (s *Server) Start() error {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		if err := s.httpServer.Shutdown(ctx); err != nil {
+		if err := s.Shutdown(ctx); err != nil {
 			s.log.Error("server shutdown error", "error", err)
 		}
 		close(done)
 	}()
 
-	s.log.Info("server starting", "port", s.cfg.Port)
-
-	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		return fmt.Errorf("server error: %w", err)
+	for range listeners {
+		if err := <-errs; err != nil && err != http.ErrServerClosed {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			s.Shutdown(ctx)
+			cancel()
+			return fmt.Errorf("server error: %w", err)
+		}
 	}
 
 	<-done
@@ -88,10 +168,72 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// handler returns the middleware-wrapped router for a listener role.
+func (s *Server) handler(role string) http.Handler {
+	var handler http.Handler = s.router
+	if role == config.ListenerRoleStreaming {
+		handler = middleware.StreamingOnly(handler)
+	}
+
+	middlewares := []func(http.Handler) http.Handler{
+		middleware.Recovery(s.log),
+		middleware.Logging(s.log),
+		middleware.CORS,
+	}
+	if s.cipher != nil {
+		middlewares = append(middlewares, middleware.EncryptedQuery(s.cipher, s.log))
+	}
+	if s.tokens != nil {
+		middlewares = append(middlewares, middleware.PlaybackToken(s.tokens, s.cfg, s.log))
+	}
+	middlewares = append(middlewares,
+		middleware.Auth(s.cfg, s.log),
+		middleware.RequestID,
+		middleware.PublicURL(s.cfg),
+	)
+	if s.tracer != nil {
+		// Outermost, so the server span covers the whole request
+		middlewares = append([]func(http.Handler) http.Handler{s.tracer.Middleware}, middlewares...)
+	}
+	return middleware.Chain(handler, middlewares...)
+}
+
+// listen opens a listener. A stale socket file left by an unclean exit is
+// removed first.
+func listen(l config.Listener) (net.Listener, error) {
+	if l.Network == "unix" {
+		if info, err := os.Stat(l.Address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(l.Address)
+		}
+	}
+	ln, err := net.Listen(l.Network, l.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", l.Address, err)
+	}
+	return ln, nil
+}
+
+// protocols returns the HTTP versions served on a listener: HTTP/1.1,
+// HTTP/2 when negotiated over TLS, and h2c on a plaintext listener when
+// enabled.
+func protocols(cfg *config.Config, secure bool) *http.Protocols {
+	p := new(http.Protocols)
+	p.SetHTTP1(true)
+	p.SetHTTP2(cfg.HTTP2 && secure)
+	p.SetUnencryptedHTTP2(cfg.H2C && !secure)
+	return p
+}
+
 // Shutdown gracefully shuts down the server.
 func (s *Server) Shutdown(ctx context.Context) error {
-	if s.httpServer == nil {
-		return nil
+	if s.redirect != nil {
+		s.redirect.Shutdown(ctx)
+	}
+	var firstErr error
+	for _, srv := range s.servers {
+		if err := srv.Shutdown(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
-	return s.httpServer.Shutdown(ctx)
+	return firstErr
 }
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/middleware"
+)
+
+func TestProtocols(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       config.Config
+		secure    bool
+		wantHTTP2 bool
+		wantH2C   bool
+	}{
+		{"plaintext", config.Config{HTTP2: true}, false, false, false},
+		{"h2c", config.Config{HTTP2: true, H2C: true}, false, false, true},
+		{"tls", config.Config{HTTP2: true, H2C: true}, true, true, false},
+		{"tls without http2", config.Config{}, true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := protocols(&tt.cfg, tt.secure)
+			if !p.HTTP1() {
+				t.Error("HTTP/1.1 should always be served")
+			}
+			if p.HTTP2() != tt.wantHTTP2 {
+				t.Errorf("HTTP2() = %v, want %v", p.HTTP2(), tt.wantHTTP2)
+			}
+			if p.UnencryptedHTTP2() != tt.wantH2C {
+				t.Errorf("UnencryptedHTTP2() = %v, want %v", p.UnencryptedHTTP2(), tt.wantH2C)
+			}
+		})
+	}
+}
+
+// TestH2CStreaming checks that flushed chunks reach an h2c client through the
+// middleware chain before the response ends.
+func TestH2CStreaming(t *testing.T) {
+	cfg := &config.Config{HTTP2: true, H2C: true}
+	log := logging.New("error", false, nil)
+
+	release := make(chan struct{})
+	handler := middleware.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first\n"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("second\n"))
+	}), middleware.Recovery(log), middleware.Logging(log), middleware.CORS)
+
+	srv := httptest.NewUnstartedServer(handler)
+	srv.Config.Protocols = protocols(cfg, false)
+	srv.Start()
+	defer srv.Close()
+
+	var clientProtocols http.Protocols
+	clientProtocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: &clientProtocols}}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		close(release)
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("protocol = %s, want HTTP/2", resp.Proto)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	close(release)
+	if err != nil || line != "first\n" {
+		t.Fatalf("first chunk = %q, %v; want flushed before the response ends", line, err)
+	}
+	if line, _ := reader.ReadString('\n'); line != "second\n" {
+		t.Errorf("second chunk = %q", line)
+	}
+}
+
+// TestStreamingListener checks that a streaming listener on a unix socket
+// serves the proxy endpoints but not the API.
+func TestStreamingListener(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://localhost:7860"}
+	s := New(cfg, logging.New("error", false, nil))
+	s.Router().HandleFunc("GET /api/info", func(w http.ResponseWriter, r *http.Request) {})
+	s.Router().HandleFunc("GET /proxy/stream", func(w http.ResponseWriter, r *http.Request) {})
+
+	socket := filepath.Join(t.TempDir(), "mp.sock")
+	// A stale socket file from an unclean exit is replaced
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := listen(config.Listener{Network: "unix", Address: socket, Role: config.ListenerRoleStreaming})
+	if err != nil {
+		t.Fatalf("listen() error = %v", err)
+	}
+	srv := &http.Server{Handler: s.handler(config.ListenerRoleStreaming)}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	for path, want := range map[string]int{
+		"/proxy/stream": http.StatusOK,
+		"/api/info":     http.StatusNotFound,
+	} {
+		resp, err := client.Get("http://mediaproxy" + path)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s status = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/logging"
+)
+
+// certReloadInterval is how often the certificate files are checked for
+// changes, so renewals (e.g. by certbot) are picked up without a restart.
+const certReloadInterval = time.Minute
+
+// tlsSetup returns the TLS configuration of the server and the handler of the
+// plain HTTP listener: ACME http-01 challenges when certificates are
+// provisioned, and redirects to HTTPS.
+func tlsSetup(cfg *config.Config, log *logging.Logger) (*tls.Config, http.Handler, error) {
+	redirect := httpsRedirect(cfg.Port)
+
+	if len(cfg.ACMEDomains) > 0 {
+		if err := os.MkdirAll(cfg.ACMECacheDir, 0700); err != nil {
+			return nil, nil, fmt.Errorf("failed to create ACME cache dir: %w", err)
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(cfg.ACMECacheDir),
+			HostPolicy: autocert.HostWhitelist(cfg.ACMEDomains...),
+			Email:      cfg.ACMEEmail,
+		}
+		if cfg.ACMEDirectoryURL != "" {
+			manager.Client = &acme.Client{DirectoryURL: cfg.ACMEDirectoryURL}
+		}
+		// TLSConfig answers tls-alpn-01 challenges on the HTTPS port
+		return manager.TLSConfig(), manager.HTTPHandler(redirect), nil
+	}
+
+	reloader, err := newCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile, log)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	return tlsConfig, redirect, nil
+}
+
+// httpsRedirect redirects plain HTTP requests to the HTTPS port.
+func httpsRedirect(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// certReloader serves a certificate/key pair from disk, reloading it when the
+// files change.
+type certReloader struct {
+	certFile, keyFile string
+	log               *logging.Logger
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newCertReloader(certFile, keyFile string, log *logging.Logger) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, log: log}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load reads the certificate/key pair.
+func (r *certReloader) load() error {
+	info, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to read TLS certificate: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	r.cert = &cert
+	r.modTime = info.ModTime()
+	return nil
+}
+
+// GetCertificate returns the current certificate, reloading it at most every
+// certReloadInterval when the certificate file changed. A pair that fails to
+// load keeps the previous certificate in use.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checkedAt) >= certReloadInterval {
+		r.checkedAt = time.Now()
+		if info, err := os.Stat(r.certFile); err == nil && !info.ModTime().Equal(r.modTime) {
+			if err := r.load(); err != nil {
+				r.log.Warn("keeping previous TLS certificate", "error", err)
+			} else {
+				r.log.Info("TLS certificate reloaded", "file", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
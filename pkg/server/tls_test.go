@@ -0,0 +1,120 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/logging"
+)
+
+// writeCert writes a self-signed certificate/key pair for host.
+func writeCert(t *testing.T, certFile, keyFile, host string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	log := logging.New("error", false, nil)
+
+	if _, err := newCertReloader(certFile, keyFile, log); err == nil {
+		t.Fatal("newCertReloader() with missing files should fail")
+	}
+
+	writeCert(t, certFile, keyFile, "old.example.com")
+	r, err := newCertReloader(certFile, keyFile, log)
+	if err != nil {
+		t.Fatalf("newCertReloader() error = %v", err)
+	}
+	r.checkedAt = time.Now()
+
+	commonName := func() string {
+		cert, err := r.GetCertificate(nil)
+		if err != nil {
+			t.Fatalf("GetCertificate() error = %v", err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+
+	writeCert(t, certFile, keyFile, "new.example.com")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	if got := commonName(); got != "old.example.com" {
+		t.Errorf("certificate before reload interval = %q, want old.example.com", got)
+	}
+
+	r.checkedAt = time.Time{}
+	if got := commonName(); got != "new.example.com" {
+		t.Errorf("certificate after reload = %q, want new.example.com", got)
+	}
+
+	// A broken pair keeps the previous certificate
+	os.WriteFile(keyFile, []byte("garbage"), 0600)
+	later := future.Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	r.checkedAt = time.Time{}
+	if got := commonName(); got != "new.example.com" {
+		t.Errorf("certificate after failed reload = %q, want new.example.com", got)
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	tests := []struct {
+		port   int
+		target string
+		want   string
+	}{
+		{443, "http://tv.example.org/stremio/manifest.json?a=1", "https://tv.example.org/stremio/manifest.json?a=1"},
+		{8443, "http://tv.example.org:8080/api/info", "https://tv.example.org:8443/api/info"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		httpsRedirect(tt.port).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != http.StatusMovedPermanently {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusMovedPermanently)
+		}
+		if got := rec.Header().Get("Location"); got != tt.want {
+			t.Errorf("Location = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package services
+
+// diskFree is not implemented on this platform; volumes are then chosen by
+// their quotas alone.
+func diskFree(path string) (int64, error) {
+	return 0, errDiskFreeUnsupported
+}
@@ -2,11 +2,15 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -14,15 +18,38 @@ import (
 	"media-proxy-go/pkg/config"
 	"media-proxy-go/pkg/interfaces"
 	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/types"
 )
 
+var (
+	// ErrUnknownProfile is returned when a transcode profile name isn't configured.
+	ErrUnknownProfile = errors.New("unknown transcode profile")
+	// ErrTooManySessions is returned when TRANSCODE_MAX_SESSIONS transcodes are running.
+	ErrTooManySessions = errors.New("too many transcode sessions")
+	// ErrBurnSubtitles is returned when the subtitles to burn in can't be used.
+	ErrBurnSubtitles = errors.New("cannot burn in subtitles")
+)
+
+// burnSubtitlesFile is the file in a stream's directory holding the
+// subtitles burned into it, converted to ASS so styled tracks keep their look.
+const burnSubtitlesFile = "burn.ass"
+
+// readyPollInterval is how often WaitReady checks for the HLS playlist.
+const readyPollInterval = 200 * time.Millisecond
+
+// burnSubtitlesTimeout bounds the download of subtitles to burn in.
+const burnSubtitlesTimeout = 20 * time.Second
+
 // FFmpegTranscoder manages FFmpeg transcoding processes.
 type FFmpegTranscoder struct {
 	cfg        *config.Config
 	log        *logging.Logger
 	outputDir  string
 	ffmpegPath string
+	profiles   []types.TranscodeProfile
+	hwaccel    types.HWAccelInfo
 
+	startMu     sync.Mutex // Serializes StartStream so identical requests share a session
 	mu          sync.RWMutex
 	processes   map[string]*ffmpegProcess
 	accessTimes map[string]time.Time
@@ -35,9 +62,11 @@ type FFmpegTranscoder struct {
 type ffmpegProcess struct {
 	cmd       *exec.Cmd
 	streamID  string
+	key       string // Source and profile, for reusing the session
 	outputDir string
 	cancel    context.CancelFunc
 	startTime time.Time
+	done      chan struct{} // Closed when FFmpeg exits
 }
 
 // NewFFmpegTranscoder creates a new FFmpeg transcoder.
@@ -47,6 +76,11 @@ func NewFFmpegTranscoder(cfg *config.Config, log *logging.Logger) (*FFmpegTransc
 		return nil, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	profiles := cfg.TranscodeProfiles
+	if len(profiles) == 0 {
+		profiles = config.DefaultTranscodeProfiles()
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	t := &FFmpegTranscoder{
@@ -54,12 +88,19 @@ func NewFFmpegTranscoder(cfg *config.Config, log *logging.Logger) (*FFmpegTransc
 		log:         log.WithComponent("ffmpeg"),
 		outputDir:   cfg.FFmpegOutputDir,
 		ffmpegPath:  cfg.FFmpegPath,
+		profiles:    profiles,
 		processes:   make(map[string]*ffmpegProcess),
 		accessTimes: make(map[string]time.Time),
 		ctx:         ctx,
 		cancel:      cancel,
 	}
 
+	t.detectHWAccel()
+
+	if _, err := t.profile(""); err != nil {
+		t.log.Warn("default transcode profile not configured", "profile", cfg.TranscodeDefaultProfile)
+	}
+
 	// Start cleanup goroutine
 	t.wg.Add(1)
 	go t.cleanupLoop()
@@ -67,8 +108,112 @@ func NewFFmpegTranscoder(cfg *config.Config, log *logging.Logger) (*FFmpegTransc
 	return t, nil
 }
 
-// StartStream begins transcoding a stream to HLS.
-func (t *FFmpegTranscoder) StartStream(ctx context.Context, url string, headers map[string]string, clearKey string) (string, error) {
+// detectHWAccel probes FFmpeg's hardware acceleration methods and selects one
+// according to FFMPEG_HWACCEL.
+func (t *FFmpegTranscoder) detectHWAccel() {
+	t.hwaccel.Preference = t.cfg.FFmpegHWAccel
+
+	available, err := detectHWAccels(t.ffmpegPath)
+	if err != nil {
+		t.log.Debug("hardware acceleration detection failed", "error", err)
+	}
+	t.hwaccel.Available = available
+	t.hwaccel.Selected = selectHWAccel(t.cfg.FFmpegHWAccel, available)
+
+	switch {
+	case t.hwaccel.Selected != "":
+		t.log.Info("using hardware acceleration", "hwaccel", t.hwaccel.Selected, "available", available)
+	case t.cfg.FFmpegHWAccel != "" && t.cfg.FFmpegHWAccel != "none":
+		t.log.Warn("no preferred hardware acceleration available, encoding in software",
+			"preference", t.cfg.FFmpegHWAccel, "available", available)
+	}
+
+	for _, p := range t.profiles {
+		if p.HWAccel != "" && !slices.Contains(available, p.HWAccel) {
+			t.log.Warn("transcode profile uses unavailable hardware acceleration", "profile", p.Name, "hwaccel", p.HWAccel)
+		}
+	}
+}
+
+// HWAccel reports the available and selected hardware acceleration.
+func (t *FFmpegTranscoder) HWAccel() types.HWAccelInfo {
+	return t.hwaccel
+}
+
+// EncoderArgs returns the decoder and encoder arguments of a profile, with the
+// selected hardware acceleration applied, for callers running their own FFmpeg.
+func (t *FFmpegTranscoder) EncoderArgs(profileName string) (input, output []string, err error) {
+	profile, err := t.profile(profileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return hwInputArgs(profile), encodeArgs(profile), nil
+}
+
+// Profiles returns the configured transcoding profiles.
+func (t *FFmpegTranscoder) Profiles() []types.TranscodeProfile {
+	return append([]types.TranscodeProfile(nil), t.profiles...)
+}
+
+// profile returns the named profile, or the default profile for an empty name,
+// moved onto the selected hardware encoder.
+func (t *FFmpegTranscoder) profile(name string) (types.TranscodeProfile, error) {
+	if name == "" {
+		name = t.cfg.TranscodeDefaultProfile
+		if name == "" {
+			return withHWAccel(t.profiles[0], t.hwaccel.Selected), nil
+		}
+	}
+	for _, p := range t.profiles {
+		if strings.EqualFold(p.Name, name) {
+			return withHWAccel(p, t.hwaccel.Selected), nil
+		}
+	}
+	return types.TranscodeProfile{}, fmt.Errorf("%w: %s", ErrUnknownProfile, name)
+}
+
+// StartStream begins transcoding a stream to HLS with the named profile
+// (empty for the default profile), burning in the subtitles at burnSubtitles
+// if set. A running session for the same source, profile and subtitles is
+// reused.
+func (t *FFmpegTranscoder) StartStream(ctx context.Context, url string, headers map[string]string, clearKey string, profileName string, burnSubtitles string) (string, error) {
+	profile, err := t.profile(profileName)
+	if err != nil {
+		return "", err
+	}
+	if burnSubtitles != "" {
+		if profile, err = burnProfile(profile); err != nil {
+			return "", err
+		}
+	}
+	key := sessionKey(url, headers, clearKey, profile.Name)
+	if burnSubtitles != "" {
+		key += "\nburn: " + burnSubtitles
+	}
+
+	t.startMu.Lock()
+	defer t.startMu.Unlock()
+
+	t.mu.Lock()
+	running := 0
+	for _, proc := range t.processes {
+		if proc.exited() {
+			continue
+		}
+		if proc.key == key {
+			t.accessTimes[proc.streamID] = time.Now()
+			t.mu.Unlock()
+			t.log.Debug("reusing FFmpeg transcode", "stream_id", proc.streamID, "profile", profile.Name)
+			return proc.streamID, nil
+		}
+		running++
+	}
+	t.mu.Unlock()
+
+	if t.cfg.TranscodeMaxSessions > 0 && running >= t.cfg.TranscodeMaxSessions {
+		return "", fmt.Errorf("%w: limit is %d", ErrTooManySessions, t.cfg.TranscodeMaxSessions)
+	}
+
 	streamID := fmt.Sprintf("stream_%d", time.Now().UnixNano())
 	streamDir := filepath.Join(t.outputDir, streamID)
 
@@ -78,17 +223,29 @@ func (t *FFmpegTranscoder) StartStream(ctx context.Context, url string, headers
 
 	outputPath := filepath.Join(streamDir, "index.m3u8")
 
+	var subtitles string
+	if burnSubtitles != "" {
+		subtitles = burnSubtitlesFile
+		if err := t.fetchSubtitles(burnSubtitles, headers, filepath.Join(streamDir, subtitles)); err != nil {
+			os.RemoveAll(streamDir)
+			return "", fmt.Errorf("%w: %v", ErrBurnSubtitles, err)
+		}
+	}
+
 	// Build FFmpeg command
-	args := t.buildFFmpegArgs(url, headers, clearKey, outputPath)
+	args := t.buildFFmpegArgs(url, headers, clearKey, outputPath, profile, subtitles)
 
 	t.log.Info("starting FFmpeg transcode",
 		"stream_id", streamID,
 		"url", url,
+		"profile", profile.Name,
+		"burn_subtitles", burnSubtitles,
 		"output", outputPath,
 	)
 
 	procCtx, procCancel := context.WithCancel(t.ctx)
 	cmd := exec.CommandContext(procCtx, t.ffmpegPath, args...)
+	cmd.Dir = streamDir // The subtitles filter reads its file from there, unescaped
 
 	// Redirect stderr for logging
 	cmd.Stderr = &ffmpegLogger{log: t.log, streamID: streamID}
@@ -101,9 +258,11 @@ func (t *FFmpegTranscoder) StartStream(ctx context.Context, url string, headers
 	proc := &ffmpegProcess{
 		cmd:       cmd,
 		streamID:  streamID,
+		key:       key,
 		outputDir: streamDir,
 		cancel:    procCancel,
 		startTime: time.Now(),
+		done:      make(chan struct{}),
 	}
 
 	t.mu.Lock()
@@ -117,8 +276,9 @@ func (t *FFmpegTranscoder) StartStream(ctx context.Context, url string, headers
 	return streamID, nil
 }
 
-// buildFFmpegArgs builds the FFmpeg command arguments.
-func (t *FFmpegTranscoder) buildFFmpegArgs(url string, headers map[string]string, clearKey string, outputPath string) []string {
+// buildFFmpegArgs builds the FFmpeg command arguments. subtitles names a
+// file in the working directory to burn into the video, if not empty.
+func (t *FFmpegTranscoder) buildFFmpegArgs(url string, headers map[string]string, clearKey string, outputPath string, profile types.TranscodeProfile, subtitles string) []string {
 	args := []string{
 		"-hide_banner",
 		"-loglevel", "warning",
@@ -146,19 +306,16 @@ func (t *FFmpegTranscoder) buildFFmpegArgs(url string, headers map[string]string
 		}
 	}
 
+	args = append(args, hwInputArgs(profile)...)
 	args = append(args, "-i", url)
 
 	// Encoding options
+	var filters []string
+	if subtitles != "" {
+		filters = append(filters, "subtitles="+subtitles)
+	}
+	args = append(args, encodeArgs(profile, filters...)...)
 	args = append(args,
-		"-threads", "0",
-		"-vf", "scale=-2:720",
-		"-c:v", "libx264",
-		"-preset", "ultrafast",
-		"-profile:v", "baseline",
-		"-level", "3.1",
-		"-c:a", "aac",
-		"-b:a", "128k",
-		"-ac", "2",
 		"-hls_time", "10",
 		"-hls_list_size", "0",
 		"-hls_flags", "delete_segments+append_list",
@@ -169,6 +326,96 @@ func (t *FFmpegTranscoder) buildFFmpegArgs(url string, headers map[string]string
 	return args
 }
 
+// fetchSubtitles downloads the subtitle track to burn into a transcode,
+// converting it to ASS at path. The subtitles filter needs the whole track
+// before the transcode starts, so live subtitle playlists, which never end,
+// fail with a timeout.
+func (t *FFmpegTranscoder) fetchSubtitles(url string, headers map[string]string, path string) error {
+	ctx, cancel := context.WithTimeout(t.ctx, burnSubtitlesTimeout)
+	defer cancel()
+
+	args := []string{"-hide_banner", "-loglevel", "error", "-y"}
+	if len(headers) > 0 {
+		var headerParts []string
+		for key, value := range headers {
+			headerParts = append(headerParts, fmt.Sprintf("%s: %s", key, value))
+		}
+		args = append(args, "-headers", strings.Join(headerParts, "\r\n"))
+	}
+	args = append(args, "-i", url, "-map", "0:s:0", "-f", "ass", path)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.ffmpegPath, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("subtitles not downloaded within %s (live subtitle playlists can't be burned in)", burnSubtitlesTimeout)
+		}
+		if output := strings.TrimSpace(stderr.String()); output != "" {
+			return fmt.Errorf("%w: %s", err, output)
+		}
+		return err
+	}
+	return nil
+}
+
+// sessionKey identifies a transcode by its source, headers, keys and profile.
+func sessionKey(url string, headers map[string]string, clearKey, profile string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(profile + "\n" + url + "\n" + clearKey)
+	for _, name := range names {
+		b.WriteString("\n" + strings.ToLower(name) + ": " + headers[name])
+	}
+	return b.String()
+}
+
+// exited reports whether the FFmpeg process has exited.
+func (p *ffmpegProcess) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// WaitReady blocks until a stream's HLS playlist has been written. It fails
+// if FFmpeg exits first or ctx is done.
+func (t *FFmpegTranscoder) WaitReady(ctx context.Context, streamID string) error {
+	t.mu.RLock()
+	proc, ok := t.processes[streamID]
+	t.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("stream not found: %s", streamID)
+	}
+
+	playlist := filepath.Join(proc.outputDir, "index.m3u8")
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := os.Stat(playlist); err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-proc.done:
+			if _, err := os.Stat(playlist); err == nil {
+				return nil
+			}
+			return fmt.Errorf("FFmpeg exited before writing the playlist")
+		case <-ticker.C:
+		}
+	}
+}
+
 // GetStreamPath returns the path to a stream's HLS files.
 func (t *FFmpegTranscoder) GetStreamPath(streamID string) string {
 	return filepath.Join(t.outputDir, streamID)
@@ -194,8 +441,8 @@ func (t *FFmpegTranscoder) StopStream(streamID string) error {
 	t.log.Info("stopping FFmpeg stream", "stream_id", streamID)
 	proc.cancel()
 
-	// Wait for process to exit
-	_ = proc.cmd.Wait()
+	// Wait for monitorProcess to reap the process
+	<-proc.done
 
 	return t.cleanupStream(streamID)
 }
@@ -220,6 +467,7 @@ func (t *FFmpegTranscoder) cleanupStream(streamID string) error {
 // monitorProcess monitors an FFmpeg process and cleans up when it exits.
 func (t *FFmpegTranscoder) monitorProcess(proc *ffmpegProcess) {
 	err := proc.cmd.Wait()
+	close(proc.done)
 
 	duration := time.Since(proc.startTime)
 	if err != nil {
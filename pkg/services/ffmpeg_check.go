@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/types"
+)
+
+// ffmpegCheckTimeout bounds each FFmpeg probe at startup.
+const ffmpegCheckTimeout = 10 * time.Second
+
+// minFFmpegMajor is the oldest FFmpeg release the remux and recording
+// commands are known to work with.
+const minFFmpegMajor = 4
+
+// ffmpegRequirements lists the bitstream filters ("bsf:") and muxers
+// ("muxer:") each feature's FFmpeg commands use.
+var ffmpegRequirements = map[string][]string{
+	types.FeatureDecryptRemux: {"bsf:h264_mp4toannexb", "bsf:aac_adtstoasc", "muxer:mpegts"},
+	types.FeatureRecording:    {"muxer:mpegts"},
+	types.FeatureTranscode:    {"muxer:hls"},
+	types.FeatureHDHomeRun:    {"muxer:mpegts"},
+}
+
+// versionPattern matches the release in "ffmpeg version 6.1.1-3ubuntu5 ...",
+// with or without the "n" prefix of git tag builds.
+var versionPattern = regexp.MustCompile(`^n?(\d+)\.(\d+)`)
+
+// CheckFFmpeg probes the FFmpeg binary at ffmpegPath: its version and the
+// components each dependent feature needs. A missing or outdated binary
+// disables all features; a build lacking a filter or muxer only disables
+// the features using it.
+func CheckFFmpeg(ffmpegPath string) types.FFmpegInfo {
+	info := types.FFmpegInfo{Path: ffmpegPath, Features: make(map[string]bool)}
+	for feature := range ffmpegRequirements {
+		info.Features[feature] = false
+	}
+
+	out, err := runFFmpeg(ffmpegPath, "-version")
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	info.Version = parseFFmpegVersion(out)
+	if !ffmpegVersionSupported(info.Version) {
+		info.Error = fmt.Sprintf("FFmpeg %s is older than the required %d.0", info.Version, minFFmpegMajor)
+		return info
+	}
+	info.Available = true
+
+	available := make(map[string]bool)
+	if out, err := runFFmpeg(ffmpegPath, "-bsfs"); err == nil {
+		for _, name := range parseFFmpegBSFs(out) {
+			available["bsf:"+name] = true
+		}
+	}
+	if out, err := runFFmpeg(ffmpegPath, "-muxers"); err == nil {
+		for _, name := range parseFFmpegMuxers(out) {
+			available["muxer:"+name] = true
+		}
+	}
+
+	for feature, required := range ffmpegRequirements {
+		info.Features[feature] = true
+		for _, component := range required {
+			if !available[component] {
+				info.Features[feature] = false
+				if !slices.Contains(info.Missing, component) {
+					info.Missing = append(info.Missing, component)
+				}
+			}
+		}
+	}
+	slices.Sort(info.Missing)
+	return info
+}
+
+// runFFmpeg runs ffmpeg with a listing flag and returns its output.
+func runFFmpeg(ffmpegPath, flag string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ffmpegCheckTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, ffmpegPath, "-hide_banner", flag).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s %s: %w", ffmpegPath, flag, err)
+	}
+	return string(out), nil
+}
+
+// parseFFmpegVersion returns the version from the first line of ffmpeg
+// -version, e.g. "6.1.1-3ubuntu5" or "N-113348-g0a5813fc68" for git builds.
+func parseFFmpegVersion(output string) string {
+	line, _, _ := strings.Cut(output, "\n")
+	fields := strings.Fields(line)
+	for i, field := range fields {
+		if field == "version" && i+1 < len(fields) {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
+
+// ffmpegVersionSupported reports whether version meets minFFmpegMajor.
+// Versions that aren't releases (git snapshots, custom builds) are assumed
+// to be recent.
+func ffmpegVersionSupported(version string) bool {
+	m := versionPattern.FindStringSubmatch(version)
+	if m == nil {
+		return true
+	}
+	major, _ := strconv.Atoi(m[1])
+	return major >= minFFmpegMajor
+}
+
+// parseFFmpegBSFs parses the output of ffmpeg -bsfs.
+func parseFFmpegBSFs(output string) []string {
+	var names []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue // Blank lines and the "Bitstream filters:" header
+		}
+		names = append(names, line)
+	}
+	return names
+}
+
+// parseFFmpegMuxers parses the output of ffmpeg -muxers: a legend, a "--"
+// separator, then lines of flags and comma-separated names, e.g.
+// " E  mpegts          MPEG-TS (MPEG-2 Transport Stream)".
+func parseFFmpegMuxers(output string) []string {
+	var names []string
+	listing := false
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if !listing {
+			listing = len(fields) == 1 && fields[0] == "--"
+			continue
+		}
+		if len(fields) < 2 || !strings.Contains(fields[0], "E") {
+			continue
+		}
+		names = append(names, strings.Split(fields[1], ",")...)
+	}
+	return names
+}
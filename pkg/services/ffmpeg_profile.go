@@ -0,0 +1,131 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"media-proxy-go/pkg/types"
+)
+
+// defaultVAAPIDevice is the render node used when a vaapi profile names none.
+const defaultVAAPIDevice = "/dev/dri/renderD128"
+
+// AudioProfile is the built-in profile that drops video, used for audio_only.
+const AudioProfile = "audio"
+
+// hwInputArgs returns the decoder flags that keep a hardware profile's frames
+// on the GPU. Software profiles and stream copies need none.
+func hwInputArgs(p types.TranscodeProfile) []string {
+	if p.VideoCodec == "copy" || p.VideoCodec == "none" {
+		return nil
+	}
+
+	switch p.HWAccel {
+	case "vaapi":
+		device := p.Device
+		if device == "" {
+			device = defaultVAAPIDevice
+		}
+		return []string{"-hwaccel", "vaapi", "-hwaccel_output_format", "vaapi", "-vaapi_device", device}
+	case "cuda":
+		args := []string{"-hwaccel", "cuda", "-hwaccel_output_format", "cuda"}
+		if p.Device != "" {
+			args = append(args, "-hwaccel_device", p.Device)
+		}
+		return args
+	case "qsv":
+		args := []string{"-hwaccel", "qsv", "-hwaccel_output_format", "qsv"}
+		if p.Device != "" {
+			args = append(args, "-qsv_device", p.Device)
+		}
+		return args
+	case "videotoolbox":
+		return []string{"-hwaccel", "videotoolbox"}
+	}
+	return nil
+}
+
+// videoEncoder returns the encoder and scale filter for a profile. A profile's
+// own VideoCodec wins, so hardware profiles can pick e.g. hevc_nvenc.
+func videoEncoder(p types.TranscodeProfile) (encoder, scale string) {
+	switch p.HWAccel {
+	case "vaapi":
+		encoder, scale = "h264_vaapi", fmt.Sprintf("scale_vaapi=w=-2:h=%d", p.Height)
+	case "cuda":
+		encoder, scale = "h264_nvenc", fmt.Sprintf("scale_cuda=-2:%d", p.Height)
+	case "qsv":
+		encoder, scale = "h264_qsv", fmt.Sprintf("scale_qsv=w=-1:h=%d", p.Height)
+	case "videotoolbox":
+		encoder, scale = "h264_videotoolbox", fmt.Sprintf("scale=-2:%d", p.Height)
+	default:
+		encoder, scale = "libx264", fmt.Sprintf("scale=-2:%d", p.Height)
+	}
+	if p.VideoCodec != "" {
+		encoder = p.VideoCodec
+	}
+	return encoder, scale
+}
+
+// encodeArgs returns the video and audio encoding arguments of a profile.
+// filters are applied to the video after scaling.
+func encodeArgs(p types.TranscodeProfile, filters ...string) []string {
+	var args []string
+
+	switch p.VideoCodec {
+	case "none":
+		args = append(args, "-vn")
+	case "copy":
+		args = append(args, "-c:v", "copy")
+	default:
+		encoder, scale := videoEncoder(p)
+		if p.HWAccel == "" {
+			args = append(args, "-threads", "0")
+		}
+		if p.Height > 0 {
+			filters = append([]string{scale}, filters...)
+		}
+		if len(filters) > 0 {
+			args = append(args, "-vf", strings.Join(filters, ","))
+		}
+		args = append(args, "-c:v", encoder)
+		if p.Preset != "" {
+			args = append(args, "-preset", p.Preset)
+		}
+		if p.VideoProfile != "" {
+			args = append(args, "-profile:v", p.VideoProfile)
+		}
+		if p.VideoBitrate != "" {
+			args = append(args, "-b:v", p.VideoBitrate)
+		}
+	}
+
+	audioCodec := p.AudioCodec
+	if audioCodec == "" {
+		audioCodec = "aac"
+	}
+	args = append(args, "-c:a", audioCodec)
+	if audioCodec != "copy" {
+		if p.AudioBitrate != "" {
+			args = append(args, "-b:a", p.AudioBitrate)
+		}
+		args = append(args, "-ac", "2")
+	}
+
+	return args
+}
+
+// burnProfile returns the profile used to burn subtitles into a transcode.
+// The subtitles filter draws on frames in system memory, so hardware
+// profiles fall back to encoding in software.
+func burnProfile(p types.TranscodeProfile) (types.TranscodeProfile, error) {
+	if p.VideoCodec == "copy" || p.VideoCodec == "none" {
+		return p, fmt.Errorf("%w: profile %s doesn't encode video", ErrBurnSubtitles, p.Name)
+	}
+	if p.HWAccel != "" {
+		p.HWAccel = ""
+		p.VideoCodec = ""
+		p.Device = ""
+		p.Preset = "veryfast"
+	}
+	return p, nil
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/types"
+)
+
+func TestFFmpegTranscoder_buildFFmpegArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		profile   types.TranscodeProfile
+		subtitles string
+		want      []string // Must appear in order
+		notWant   []string
+	}{
+		{
+			name:    "software 720p",
+			profile: types.TranscodeProfile{Name: "720p", Height: 720, VideoCodec: "libx264", Preset: "ultrafast", VideoProfile: "baseline", AudioCodec: "aac", AudioBitrate: "128k"},
+			want:    []string{"-i", "-threads 0", "-vf scale=-2:720", "-c:v libx264", "-preset ultrafast", "-profile:v baseline", "-c:a aac", "-b:a 128k", "-ac 2", "-f hls"},
+			notWant: []string{"-hwaccel"},
+		},
+		{
+			name:      "burned subtitles",
+			profile:   types.TranscodeProfile{Name: "480p", Height: 480, VideoCodec: "libx264", AudioCodec: "aac"},
+			subtitles: "burn.ass",
+			want:      []string{"-i", "-vf scale=-2:480,subtitles=burn.ass", "-c:v libx264"},
+		},
+		{
+			name:    "vaapi",
+			profile: types.TranscodeProfile{Name: "vaapi", Height: 1080, HWAccel: "vaapi", VideoBitrate: "4000k", AudioCodec: "aac"},
+			want:    []string{"-hwaccel vaapi", "-vaapi_device /dev/dri/renderD128", "-i", "-vf scale_vaapi=w=-2:h=1080", "-c:v h264_vaapi", "-b:v 4000k"},
+			notWant: []string{"-threads"},
+		},
+		{
+			name:    "nvenc with explicit codec",
+			profile: types.TranscodeProfile{Name: "hevc", Height: 720, HWAccel: "cuda", VideoCodec: "hevc_nvenc", Preset: "p4", AudioCodec: "aac"},
+			want:    []string{"-hwaccel cuda", "-hwaccel_output_format cuda", "-i", "-vf scale_cuda=-2:720", "-c:v hevc_nvenc", "-preset p4"},
+		},
+		{
+			name:    "qsv",
+			profile: types.TranscodeProfile{Name: "qsv", Height: 480, HWAccel: "qsv", AudioCodec: "aac"},
+			want:    []string{"-hwaccel qsv", "-i", "-vf scale_qsv=w=-1:h=480", "-c:v h264_qsv"},
+		},
+		{
+			name:    "audio only",
+			profile: types.TranscodeProfile{Name: "audio", VideoCodec: "none", AudioCodec: "aac", AudioBitrate: "128k", HWAccel: "vaapi"},
+			want:    []string{"-i", "-vn", "-c:a aac", "-b:a 128k"},
+			notWant: []string{"-hwaccel", "-c:v", "-vf"},
+		},
+		{
+			name:    "stream copy",
+			profile: types.TranscodeProfile{Name: "copy", VideoCodec: "copy", AudioCodec: "copy", HWAccel: "cuda"},
+			want:    []string{"-i", "-c:v copy", "-c:a copy"},
+			notWant: []string{"-hwaccel", "-vf", "-ac"},
+		},
+	}
+
+	tr := &FFmpegTranscoder{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := strings.Join(tr.buildFFmpegArgs("http://example.com/live.ts", nil, "", "/tmp/out/index.m3u8", tt.profile, tt.subtitles), " ")
+
+			rest := args
+			for _, w := range tt.want {
+				i := strings.Index(rest, w)
+				if i < 0 {
+					t.Fatalf("args missing %q (in order): %s", w, args)
+				}
+				rest = rest[i+len(w):]
+			}
+			for _, nw := range tt.notWant {
+				if strings.Contains(args, nw) {
+					t.Errorf("args contain %q: %s", nw, args)
+				}
+			}
+		})
+	}
+}
+
+func TestBurnProfile(t *testing.T) {
+	p, err := burnProfile(types.TranscodeProfile{Name: "hevc", Height: 720, HWAccel: "cuda", VideoCodec: "hevc_nvenc", Preset: "p4"})
+	if err != nil {
+		t.Fatalf("burnProfile() error = %v", err)
+	}
+	if p.HWAccel != "" || p.VideoCodec != "" || p.Preset != "veryfast" || p.Height != 720 {
+		t.Errorf("burnProfile() = %+v, want the software encoder at the same height", p)
+	}
+
+	if _, err := burnProfile(types.TranscodeProfile{Name: "copy", VideoCodec: "copy"}); !errors.Is(err, ErrBurnSubtitles) {
+		t.Errorf("burnProfile(copy) error = %v, want ErrBurnSubtitles", err)
+	}
+}
+
+func TestFFmpegTranscoder_profile(t *testing.T) {
+	tr := &FFmpegTranscoder{
+		cfg:      &config.Config{TranscodeDefaultProfile: "1080p"},
+		profiles: []types.TranscodeProfile{{Name: "720p"}, {Name: "1080p"}},
+	}
+
+	if p, err := tr.profile(""); err != nil || p.Name != "1080p" {
+		t.Errorf("profile(\"\") = %q, %v; want the default 1080p", p.Name, err)
+	}
+	if p, err := tr.profile("720P"); err != nil || p.Name != "720p" {
+		t.Errorf("profile(720P) = %q, %v; want 720p", p.Name, err)
+	}
+	if _, err := tr.profile("4k"); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("profile(4k) error = %v, want ErrUnknownProfile", err)
+	}
+}
+
+func TestSelectHWAccel(t *testing.T) {
+	available := parseHWAccels("Hardware acceleration methods:\nvdpau\ncuda\nvaapi\n\n")
+
+	tests := []struct {
+		preference string
+		want       string
+	}{
+		{"", ""},
+		{"none", ""},
+		{"auto", "cuda"},
+		{"vaapi,nvenc", "vaapi"},
+		{"qsv, nvenc", "cuda"},
+		{"videotoolbox", ""},
+	}
+
+	for _, tt := range tests {
+		if got := selectHWAccel(tt.preference, available); got != tt.want {
+			t.Errorf("selectHWAccel(%q) = %q, want %q", tt.preference, got, tt.want)
+		}
+	}
+}
+
+func TestWithHWAccel(t *testing.T) {
+	software := types.TranscodeProfile{Name: "720p", Height: 720, VideoCodec: "libx264", Preset: "ultrafast", VideoProfile: "baseline"}
+	got := withHWAccel(software, "vaapi")
+	if got.HWAccel != "vaapi" || got.VideoCodec != "" || got.Preset != "" || got.VideoProfile != "" {
+		t.Errorf("software profile = %+v, want it moved to vaapi", got)
+	}
+
+	for _, p := range []types.TranscodeProfile{
+		{Name: "copy", VideoCodec: "copy"},
+		{Name: "hevc", VideoCodec: "libx265"},
+		{Name: "qsv", HWAccel: "qsv"},
+	} {
+		if got := withHWAccel(p, "vaapi"); got != p {
+			t.Errorf("withHWAccel(%s) = %+v, want it unchanged", p.Name, got)
+		}
+	}
+}
+
+func TestCheckFFmpeg(t *testing.T) {
+	// Stand-in for an FFmpeg build without the hls muxer
+	dir := t.TempDir()
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	script := `#!/bin/sh
+case "$*" in
+*-version*) echo 'ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers' ;;
+*-bsfs*) printf 'Bitstream filters:\naac_adtstoasc\nh264_mp4toannexb\nnull\n' ;;
+*-muxers*) printf 'Formats:\n D. = Demuxing supported\n .E = Muxing supported\n --\n  E mp4             MP4 (MPEG-4 Part 14)\n  E mpegts          MPEG-TS (MPEG-2 Transport Stream)\n' ;;
+esac
+`
+	if err := os.WriteFile(ffmpeg, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	info := CheckFFmpeg(ffmpeg)
+	if !info.Available || info.Version != "6.1.1-3ubuntu5" {
+		t.Fatalf("CheckFFmpeg() = %+v, want version 6.1.1-3ubuntu5 available", info)
+	}
+	if !slices.Equal(info.Missing, []string{"muxer:hls"}) {
+		t.Errorf("missing = %v, want [muxer:hls]", info.Missing)
+	}
+	want := map[string]bool{
+		types.FeatureDecryptRemux: true,
+		types.FeatureRecording:    true,
+		types.FeatureTranscode:    false,
+		types.FeatureHDHomeRun:    true,
+	}
+	if !maps.Equal(info.Features, want) {
+		t.Errorf("features = %v, want %v", info.Features, want)
+	}
+
+	missing := CheckFFmpeg(filepath.Join(dir, "missing-ffmpeg"))
+	if missing.Available || missing.Error == "" || missing.Features[types.FeatureDecryptRemux] {
+		t.Errorf("CheckFFmpeg(missing) = %+v, want unavailable with an error", missing)
+	}
+}
+
+func TestFFmpegVersionSupported(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"6.1.1-3ubuntu5", true},
+		{"n7.0", true},
+		{"4.0", true},
+		{"3.4.11", false},
+		{"N-113348-g0a5813fc68", true}, // Git snapshot
+		{"", true},
+	}
+	for _, tt := range tests {
+		if got := ffmpegVersionSupported(tt.version); got != tt.want {
+			t.Errorf("ffmpegVersionSupported(%q) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
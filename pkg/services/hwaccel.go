@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/types"
+)
+
+// hwAccelDetectTimeout bounds the ffmpeg -hwaccels probe at startup.
+const hwAccelDetectTimeout = 10 * time.Second
+
+// hwAccelOrder is the preference order for FFMPEG_HWACCEL=auto.
+var hwAccelOrder = []string{"cuda", "qsv", "vaapi", "videotoolbox"}
+
+// detectHWAccels lists the hardware acceleration methods FFmpeg was built with.
+func detectHWAccels(ffmpegPath string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hwAccelDetectTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, ffmpegPath, "-hide_banner", "-hwaccels").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list FFmpeg hwaccels: %w", err)
+	}
+	return parseHWAccels(string(out)), nil
+}
+
+// parseHWAccels parses the output of ffmpeg -hwaccels.
+func parseHWAccels(output string) []string {
+	var methods []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue // Blank lines and the "Hardware acceleration methods:" header
+		}
+		methods = append(methods, line)
+	}
+	return methods
+}
+
+// selectHWAccel picks the first available method from a comma-separated
+// preference ("auto" for hwAccelOrder). "nvenc" is accepted for cuda.
+func selectHWAccel(preference string, available []string) string {
+	preference = strings.ToLower(strings.TrimSpace(preference))
+	if preference == "" || preference == "none" {
+		return ""
+	}
+
+	wanted := hwAccelOrder
+	if preference != "auto" {
+		wanted = nil
+		for _, name := range strings.Split(preference, ",") {
+			name = strings.TrimSpace(name)
+			if name == "nvenc" {
+				name = "cuda"
+			}
+			wanted = append(wanted, name)
+		}
+	}
+
+	for _, name := range wanted {
+		for _, method := range available {
+			if method == name {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// withHWAccel moves a software H.264 profile onto the selected hardware
+// encoder. Profiles naming their own hwaccel or encoder are left alone;
+// x264 presets and profiles don't carry over to hardware encoders.
+func withHWAccel(p types.TranscodeProfile, hwaccel string) types.TranscodeProfile {
+	if hwaccel == "" || p.HWAccel != "" || (p.VideoCodec != "" && p.VideoCodec != "libx264") {
+		return p
+	}
+	p.HWAccel = hwaccel
+	p.VideoCodec = ""
+	p.Preset = ""
+	p.VideoProfile = ""
+	return p
+}
@@ -6,19 +6,38 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
+	"media-proxy-go/pkg/diskcache"
 	"media-proxy-go/pkg/interfaces"
 	"media-proxy-go/pkg/logging"
 	"media-proxy-go/pkg/registry"
+	"media-proxy-go/pkg/tracing"
 	"media-proxy-go/pkg/types"
+	"media-proxy-go/pkg/urlutil"
 )
 
+// UnknownHostError is returned by HandleExtract for a host that names no
+// extractor.
+type UnknownHostError struct {
+	Host      string
+	Available []string // Names of the extractors
+}
+
+func (e *UnknownHostError) Error() string {
+	return fmt.Sprintf("unknown host %q, available: %s", e.Host, strings.Join(e.Available, ", "))
+}
+
 // ProxyService handles stream proxying and extraction.
 type ProxyService struct {
-	log                *logging.Logger
-	streamHandlers     *registry.StreamHandlerRegistry
-	extractorRegistry  *registry.ExtractorRegistry
-	baseURL            string
+	log               *logging.Logger
+	streamHandlers    *registry.StreamHandlerRegistry
+	extractorRegistry *registry.ExtractorRegistry
+	baseURL           string
+	notifier          interfaces.Notifier // Optional, receives extractor failures
+	sources           *sourceTracker      // Extracted stream URLs, for re-extraction when tokens expire
+	segmentCache      *diskcache.Cache    // Optional, caches VOD segments on disk
+	sniffed           *sniffedTypes       // Extension-less manifest URLs and their type
 }
 
 // NewProxyService creates a new proxy service.
@@ -33,64 +52,165 @@ func NewProxyService(
 		streamHandlers:    streamHandlers,
 		extractorRegistry: extractorRegistry,
 		baseURL:           baseURL,
+		sources:           newSourceTracker(),
+		sniffed:           newSniffedTypes(),
 	}
 }
 
+// SetNotifier sets the notifier that receives extractor failure events.
+func (s *ProxyService) SetNotifier(n interfaces.Notifier) {
+	s.notifier = n
+}
+
+// notifyExtractorFailed reports a failed extraction to the notifier, if any.
+func (s *ProxyService) notifyExtractorFailed(extractor, urlStr string, err error) {
+	if s.notifier == nil {
+		return
+	}
+
+	event := types.Event{
+		Type:      types.EventExtractorFailed,
+		Timestamp: time.Now().Unix(),
+		Message:   fmt.Sprintf("Extractor %s failed: %v", extractor, err),
+		Extractor: extractor,
+		URL:       urlStr,
+	}
+
+	go func() {
+		if err := s.notifier.Notify(context.Background(), event); err != nil {
+			s.log.Debug("failed to deliver extractor event", "extractor", extractor, "error", err)
+		}
+	}()
+}
+
+// ExtractorLimitStats returns the counters of the paced extractors.
+func (s *ProxyService) ExtractorLimitStats() map[string]types.ThrottleStats {
+	return s.extractorRegistry.LimitStats()
+}
+
+// extract runs an extractor in its own trace span.
+func (s *ProxyService) extract(ctx context.Context, extractor interfaces.Extractor, urlStr string, opts interfaces.ExtractOptions) (*types.ExtractResult, error) {
+	ctx, span := tracing.Start(ctx, "extract "+extractor.Name())
+	defer span.End()
+
+	result, err := s.extractorRegistry.Extract(ctx, extractor, urlStr, opts)
+	span.SetError(err)
+	return result, err
+}
+
 // HandleManifest processes a manifest request.
 func (s *ProxyService) HandleManifest(ctx context.Context, req *types.StreamRequest) (*types.StreamResponse, error) {
 	s.log.Debug("handling manifest request", "url", req.URL)
 
-	// Decode URL if needed
+	// Decode URL if needed, following earlier re-extractions of expired URLs
 	decodedURL := s.decodeURL(req.URL)
-	req.URL = decodedURL
+	req.URL = s.sources.resolve(decodedURL)
 
 	// Check if URL needs extraction first (e.g., popcdn.day -> planetary.lovecdn.ru)
 	extractor := s.extractorRegistry.Get(req.URL)
 	if extractor != nil && extractor.Name() != "generic" {
-		s.log.Debug("URL needs extraction", "url", req.URL, "extractor", extractor.Name())
+		// Reloads reuse the last extraction until its token expires
+		destination, streamHeaders, ok := s.sources.current(req.URL, req.Headers)
+		if ok {
+			s.log.Debug("reusing extracted URL", "original", req.URL, "destination", destination)
+		} else {
+			s.log.Debug("URL needs extraction", "url", req.URL, "extractor", extractor.Name())
+
+			opts := interfaces.ExtractOptions{
+				Headers: req.Headers,
+			}
 
-		opts := interfaces.ExtractOptions{
-			Headers: req.Headers,
-		}
+			result, err := s.extract(ctx, extractor, req.URL, opts)
+			if err != nil {
+				s.log.Error("extraction failed", "url", req.URL, "error", err)
+				s.notifyExtractorFailed(extractor.Name(), req.URL, err)
+				return nil, fmt.Errorf("extraction failed: %w", err)
+			}
 
-		result, err := extractor.Extract(ctx, req.URL, opts)
-		if err != nil {
-			s.log.Error("extraction failed", "url", req.URL, "error", err)
-			return nil, fmt.Errorf("extraction failed: %w", err)
+			s.log.Debug("extracted URL", "original", req.URL, "destination", result.DestinationURL)
+			s.sources.record(extractor, req.URL, req.Headers, result)
+			destination, streamHeaders = result.DestinationURL, result.RequestHeaders
 		}
 
-		s.log.Debug("extracted URL", "original", req.URL, "destination", result.DestinationURL)
-
 		// Update request with extracted URL and headers
-		req.URL = result.DestinationURL
-		if result.RequestHeaders != nil {
+		req.URL = destination
+		if streamHeaders != nil {
 			if req.Headers == nil {
 				req.Headers = make(map[string]string)
 			}
-			for k, v := range result.RequestHeaders {
+			for k, v := range streamHeaders {
 				req.Headers[k] = v
 			}
 		}
 	}
 
+	s.refreshExpiring(ctx, req)
+
 	// Get appropriate handler
-	handler := s.streamHandlers.Get(req.URL)
+	handler := s.manifestHandler(req.URL)
 	if handler == nil {
 		return nil, fmt.Errorf("no handler for URL: %s", req.URL)
 	}
 
 	s.log.Debug("using stream handler", "type", handler.Type(), "url", req.URL)
 
-	return handler.HandleManifest(ctx, req, s.baseURL)
+	// Rewritten URLs point at the address the client reached us on
+	baseURL := urlutil.PublicBaseURL(ctx, s.baseURL)
+	resp, err := handler.HandleManifest(ctx, req, baseURL)
+	if err == nil && resp != nil && isExpiredStatus(resp.StatusCode) && s.reextract(ctx, req) {
+		closeBody(resp)
+		resp, err = handler.HandleManifest(ctx, req, baseURL)
+	}
+
+	// An extension-less URL the generic handler found to be a manifest is
+	// handed, as fetched, to the handler that rewrites it
+	if err == nil && resp != nil && handler.Type() == types.StreamTypeGeneric &&
+		resp.Detected != "" && resp.Detected != types.StreamTypeGeneric {
+		if manifest := s.streamHandlers.GetByType(resp.Detected); manifest != nil && manifest.Type() == resp.Detected {
+			s.log.Debug("re-dispatching sniffed manifest", "type", resp.Detected, "url", req.URL)
+			s.sniffed.set(req.URL, resp.Detected)
+			sniffedReq := *req
+			sniffedReq.Prefetched = resp.Upstream
+			return manifest.HandleManifest(ctx, &sniffedReq, baseURL)
+		}
+	}
+	return resp, err
+}
+
+// manifestHandler returns the handler for a manifest URL, using the type
+// sniffed earlier for URLs no handler recognizes.
+func (s *ProxyService) manifestHandler(urlStr string) interfaces.StreamHandler {
+	handler := s.streamHandlers.Get(urlStr)
+	if handler != nil && handler.Type() != types.StreamTypeGeneric {
+		return handler
+	}
+	if t, ok := s.sniffed.get(urlStr); ok {
+		if sniffed := s.streamHandlers.GetByType(t); sniffed != nil {
+			return sniffed
+		}
+	}
+	return handler
 }
 
 // HandleSegment processes a segment request.
 func (s *ProxyService) HandleSegment(ctx context.Context, req *types.StreamRequest) (*types.StreamResponse, error) {
 	s.log.Debug("handling segment request", "url", req.URL)
 
-	// Decode URL if needed
+	// Decode URL if needed, following earlier re-extractions of expired URLs
 	decodedURL := s.decodeURL(req.URL)
-	req.URL = decodedURL
+
+	// Cached by the URL clients request, which stays the same when tokens are refreshed
+	var cacheKey string
+	if s.segmentCache != nil {
+		cacheKey = segmentCacheKey(decodedURL, req.Headers)
+		if resp, ok := s.cachedSegment(cacheKey); ok {
+			tracing.FromContext(ctx).SetAttr("segment_cache.hit", true)
+			return resp, nil
+		}
+	}
+
+	req.URL = s.sources.resolve(decodedURL)
+	s.refreshExpiring(ctx, req)
 
 	// Get appropriate handler
 	handler := s.streamHandlers.Get(req.URL)
@@ -103,7 +223,15 @@ func (s *ProxyService) HandleSegment(ctx context.Context, req *types.StreamReque
 		return nil, fmt.Errorf("no handler for URL: %s", req.URL)
 	}
 
-	return handler.HandleSegment(ctx, req)
+	resp, err := handler.HandleSegment(ctx, req)
+	if err == nil && resp != nil && isExpiredStatus(resp.StatusCode) && s.reextract(ctx, req) {
+		closeBody(resp)
+		resp, err = handler.HandleSegment(ctx, req)
+	}
+	if err == nil && resp != nil && s.segmentCache != nil {
+		resp = s.cacheSegment(cacheKey, req.VOD, resp)
+	}
+	return resp, err
 }
 
 // HandleExtract processes an extraction request.
@@ -114,8 +242,12 @@ func (s *ProxyService) HandleExtract(ctx context.Context, urlStr string, opts in
 	urlStr = s.decodeURL(urlStr)
 
 	// Get appropriate extractor
-	extractor := s.extractorRegistry.Get(urlStr)
-	if extractor == nil {
+	var extractor interfaces.Extractor
+	if opts.Host != "" {
+		if extractor = s.extractorRegistry.Lookup(opts.Host); extractor == nil {
+			return nil, &UnknownHostError{Host: opts.Host, Available: s.extractorRegistry.Names()}
+		}
+	} else if extractor = s.extractorRegistry.Get(urlStr); extractor == nil {
 		// Fall back to generic
 		extractor = s.extractorRegistry.GetByName("generic")
 	}
@@ -126,17 +258,31 @@ func (s *ProxyService) HandleExtract(ctx context.Context, urlStr string, opts in
 
 	s.log.Debug("using extractor", "name", extractor.Name(), "url", urlStr)
 
-	result, err := extractor.Extract(ctx, urlStr, opts)
+	result, err := s.extract(ctx, extractor, urlStr, opts)
 	if err != nil {
+		s.notifyExtractorFailed(extractor.Name(), urlStr, err)
 		return nil, fmt.Errorf("extraction failed: %w", err)
 	}
 
+	if extractor.Name() != "generic" {
+		s.sources.record(extractor, urlStr, opts.Headers, result)
+	}
+
 	// Add proxy URL to result
-	result.MediaflowProxyURL = s.buildProxyURL(result.DestinationURL, result.RequestHeaders, result.MediaflowEndpoint)
+	result.MediaflowProxyURL = s.buildProxyURL(ctx, result.DestinationURL, result.RequestHeaders, result.MediaflowEndpoint)
 
 	return result, nil
 }
 
+// StreamInfo decodes urlStr and returns it with the type of the stream handler that would serve it.
+func (s *ProxyService) StreamInfo(urlStr string) (string, types.StreamType) {
+	urlStr = s.decodeURL(urlStr)
+	if handler := s.manifestHandler(urlStr); handler != nil {
+		return urlStr, handler.Type()
+	}
+	return urlStr, types.StreamTypeGeneric
+}
+
 // decodeURL attempts to decode a potentially encoded URL.
 func (s *ProxyService) decodeURL(urlStr string) string {
 	if urlStr == "" {
@@ -179,8 +325,9 @@ func (s *ProxyService) decodeURL(urlStr string) string {
 	return urlStr
 }
 
-// buildProxyURL builds a proxy URL for the given destination.
-func (s *ProxyService) buildProxyURL(destURL string, headers map[string]string, endpoint string) string {
+// buildProxyURL builds a proxy URL for the given destination, based on the
+// public URL of the request in ctx.
+func (s *ProxyService) buildProxyURL(ctx context.Context, destURL string, headers map[string]string, endpoint string) string {
 	var path string
 	switch endpoint {
 	case "hls_manifest_proxy", "hls_proxy":
@@ -191,7 +338,7 @@ func (s *ProxyService) buildProxyURL(destURL string, headers map[string]string,
 		path = "/proxy/stream"
 	}
 
-	proxyURL, _ := url.Parse(s.baseURL + path)
+	proxyURL, _ := url.Parse(urlutil.PublicBaseURL(ctx, s.baseURL) + path)
 	query := proxyURL.Query()
 	query.Set("url", destURL)
 
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/registry"
+	"media-proxy-go/pkg/throttle"
+	"media-proxy-go/pkg/types"
+)
+
+func TestProxyService_ExtractHost(t *testing.T) {
+	log := logging.New("error", false, nil)
+	extractors := registry.NewExtractorRegistry()
+	extractors.Register(&rotatingExtractor{server: "http://cdn.example"})
+	s := NewProxyService(log, registry.NewStreamHandlerRegistry(), extractors, "http://localhost:7860")
+
+	// The host picks the extractor whatever the URL
+	result, err := s.HandleExtract(context.Background(), "http://unrelated.example/watch/1", interfaces.ExtractOptions{Host: "Rotating"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.DestinationURL != "http://cdn.example/live/1/index.m3u8" {
+		t.Errorf("DestinationURL = %q", result.DestinationURL)
+	}
+
+	_, err = s.HandleExtract(context.Background(), "http://rotating.example/1", interfaces.ExtractOptions{Host: "nope"})
+	var unknown *UnknownHostError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("err = %v, want an UnknownHostError", err)
+	}
+	if unknown.Host != "nope" || !reflect.DeepEqual(unknown.Available, []string{"rotating"}) {
+		t.Errorf("err = %+v", unknown)
+	}
+}
+
+// gatedExtractor blocks extractions until release is closed, counting how
+// many run at once.
+type gatedExtractor struct {
+	release chan struct{}
+	running atomic.Int32
+	peak    atomic.Int32
+}
+
+func (e *gatedExtractor) Name() string { return "gated" }
+
+func (e *gatedExtractor) CanExtract(u string) bool { return strings.Contains(u, "gated.example") }
+
+func (e *gatedExtractor) Extract(ctx context.Context, u string, opts interfaces.ExtractOptions) (*types.ExtractResult, error) {
+	n := e.running.Add(1)
+	defer e.running.Add(-1)
+	for {
+		peak := e.peak.Load()
+		if n <= peak || e.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	<-e.release
+	return &types.ExtractResult{DestinationURL: "http://cdn.example/index.m3u8"}, nil
+}
+
+func (e *gatedExtractor) Close() error { return nil }
+
+func TestProxyService_ExtractLimits(t *testing.T) {
+	log := logging.New("error", false, nil)
+	extractor := &gatedExtractor{release: make(chan struct{})}
+	extractors := registry.NewExtractorRegistry()
+	extractors.Register(extractor)
+	extractors.SetLimits(map[string]throttle.Limit{"Gated": {Concurrency: 2}})
+	s := NewProxyService(log, registry.NewStreamHandlerRegistry(), extractors, "http://localhost:7860")
+
+	var wg sync.WaitGroup
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.HandleExtract(context.Background(), fmt.Sprintf("http://gated.example/%d", i), interfaces.ExtractOptions{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	deadline := time.Now().Add(time.Second)
+	for s.ExtractorLimitStats()["gated"].Queued != 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(extractor.release)
+	wg.Wait()
+
+	if peak := extractor.peak.Load(); peak != 2 {
+		t.Errorf("%d extractions ran at once, want 2", peak)
+	}
+	stats := s.ExtractorLimitStats()["gated"]
+	if stats.Started != 5 || stats.Throttled != 3 || stats.Active != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// Queued extractions give up with their request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	extractor.release = make(chan struct{})
+	extractors.SetLimits(map[string]throttle.Limit{registry.AnyExtractor: {Concurrency: 1}})
+	done := make(chan struct{})
+	go func() {
+		s.HandleExtract(context.Background(), "http://gated.example/1", interfaces.ExtractOptions{})
+		close(done)
+	}()
+	for s.ExtractorLimitStats()["gated"].Active != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := s.HandleExtract(ctx, "http://gated.example/2", interfaces.ExtractOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	close(extractor.release)
+	<-done
+}
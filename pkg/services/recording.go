@@ -9,13 +9,17 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/export"
 	"media-proxy-go/pkg/interfaces"
 	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/registry"
 	"media-proxy-go/pkg/types"
 )
 
@@ -25,32 +29,64 @@ type RecordingManager struct {
 	log     *logging.Logger
 	baseURL string // Local proxy base URL for routing streams
 
+	extractorRegistry *registry.ExtractorRegistry // Optional, resolves extractor URLs (dlhd, vavoo...)
+	notifier          interfaces.Notifier         // Optional, receives recording lifecycle events
+	uploader          interfaces.Uploader         // Optional, exports completed recordings
+
 	mu         sync.RWMutex
 	recordings map[string]*recordingState
 	dbPath     string
 
+	volumes    []config.RecordingVolume // Directories new recordings are placed in
+	nextVolume atomic.Uint64            // Round-robin placement counter
+	importMu   sync.Mutex               // Serializes import scans
+
+	restartBackoff time.Duration // Base delay before restarting a stalled recording
+
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 }
 
 type recordingState struct {
-	mu         sync.Mutex
-	recording  *types.Recording
-	cmd        *exec.Cmd
-	procCancel context.CancelFunc
-	stdinPipe  io.WriteCloser
-	stderrPipe io.ReadCloser
-	done       chan struct{} // Closed when recording finishes
-	stopped    bool          // True if stop was requested
+	mu            sync.Mutex
+	recording     *types.Recording
+	cmd           *exec.Cmd
+	procCtx       context.Context
+	procCancel    context.CancelFunc
+	attemptCancel context.CancelFunc // Cancels only the current FFmpeg process
+	outFile       *os.File
+	// resolvedHeaders are the user headers merged with those returned by the extractor
+	resolvedHeaders map[string]string
+	stdinPipe       io.WriteCloser
+	stderrPipe      io.ReadCloser
+	copyDone        chan error    // Result of a passthrough copy, nil while FFmpeg records
+	done            chan struct{} // Closed when recording finishes
+	stopped         bool          // True if stop was requested
 }
 
 // NewRecordingManager creates a new recording manager.
-func NewRecordingManager(cfg *config.Config, log *logging.Logger, baseURL string) (*RecordingManager, error) {
-	// Ensure recordings directory exists
+// extractorRegistry may be nil, in which case URLs are recorded as given.
+func NewRecordingManager(
+	cfg *config.Config,
+	log *logging.Logger,
+	baseURL string,
+	extractorRegistry *registry.ExtractorRegistry,
+) (*RecordingManager, error) {
+	// Ensure recordings directory exists; it holds the recordings database
+	// even when recordings are placed on other volumes
 	if err := os.MkdirAll(cfg.RecordingsDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create recordings directory: %w", err)
 	}
+	volumes := recordingVolumes(cfg)
+	if err := createVolumes(volumes); err != nil {
+		return nil, err
+	}
+	if cfg.RecordingsWatchDir != "" {
+		if err := os.MkdirAll(cfg.RecordingsWatchDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create recordings watch directory: %w", err)
+		}
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -59,9 +95,14 @@ func NewRecordingManager(cfg *config.Config, log *logging.Logger, baseURL string
 		log:        log.WithComponent("recording"),
 		baseURL:    baseURL,
 		recordings: make(map[string]*recordingState),
-		dbPath:     filepath.Join(cfg.RecordingsDir, "recordings.json"),
-		ctx:        ctx,
-		cancel:     cancel,
+
+		extractorRegistry: extractorRegistry,
+		dbPath:            filepath.Join(cfg.RecordingsDir, "recordings.json"),
+		volumes:           volumes,
+		ctx:               ctx,
+		cancel:            cancel,
+
+		restartBackoff: 2 * time.Second,
 	}
 
 	// Load existing recordings
@@ -77,16 +118,89 @@ func NewRecordingManager(cfg *config.Config, log *logging.Logger, baseURL string
 	m.wg.Add(1)
 	go m.cleanupLoop()
 
+	if cfg.RecordingsWatchDir != "" && cfg.RecordingsImportScan > 0 {
+		m.wg.Add(1)
+		go m.importLoop()
+	}
+
 	return m, nil
 }
 
-// StartRecording begins recording a stream.
-func (m *RecordingManager) StartRecording(ctx context.Context, urlStr, name, clearKey string) (*types.Recording, error) {
+// SetNotifier sets the notifier that receives recording lifecycle events.
+func (m *RecordingManager) SetNotifier(n interfaces.Notifier) {
+	m.notifier = n
+}
+
+// SetUploader sets the uploader used to export completed recordings.
+func (m *RecordingManager) SetUploader(u interfaces.Uploader) {
+	m.uploader = u
+}
+
+// snapshotRecording copies a recording so it can be used outside the state lock.
+func snapshotRecording(rec *types.Recording) types.Recording {
+	snapshot := *rec
+	if rec.Upload != nil {
+		upload := *rec.Upload
+		snapshot.Upload = &upload
+	}
+	snapshot.Subtitles = slices.Clone(rec.Subtitles)
+	snapshot.Tags = slices.Clone(rec.Tags)
+	if rec.Health != nil {
+		health := *rec.Health
+		snapshot.Health = &health
+	}
+	if rec.Chapters != nil {
+		chapters := *rec.Chapters
+		chapters.Chapters = slices.Clone(rec.Chapters.Chapters)
+		snapshot.Chapters = &chapters
+	}
+	return snapshot
+}
+
+// notify sends a recording event in the background.
+// The payload is a summary of the recording at the time of the event.
+func (m *RecordingManager) notify(eventType types.EventType, rec types.Recording, message string) {
+	if m.notifier == nil {
+		return
+	}
+
+	event := types.Event{
+		Type:      eventType,
+		Timestamp: time.Now().Unix(),
+		Message:   message,
+		Recording: &types.RecordingSummary{
+			ID:        rec.ID,
+			Name:      rec.Name,
+			Status:    rec.Status,
+			StartedAt: rec.StartedAt,
+			Duration:  rec.Duration,
+			FileSize:  rec.FileSize,
+			Extractor: rec.Extractor,
+			Restarts:  rec.Restarts,
+			Upload:    rec.Upload,
+			Tags:      rec.Tags,
+		},
+	}
+
+	go func() {
+		if err := m.notifier.Notify(context.Background(), event); err != nil {
+			m.log.Debug("failed to deliver recording event", "event", eventType, "id", rec.ID, "error", err)
+		}
+	}()
+}
+
+// StartRecording begins recording a stream. backups are sources switched to
+// in turn when the one in use stalls or fails.
+func (m *RecordingManager) StartRecording(ctx context.Context, urlStr, name, clearKey string, headers map[string]string, quality string, backups []string) (*types.Recording, error) {
 	now := time.Now()
 	id := fmt.Sprintf("rec_%d", now.UnixNano())
 	dateStr := now.Format("20060102_150405")
 	filename := fmt.Sprintf("%s_%s.ts", dateStr, sanitizeFilename(name))
-	filePath := filepath.Join(m.cfg.RecordingsDir, filename)
+	volume, err := m.pickVolume()
+	if err != nil {
+		return nil, err
+	}
+	filePath := filepath.Join(volume, filename)
 
 	recording := &types.Recording{
 		ID:        id,
@@ -95,7 +209,11 @@ func (m *RecordingManager) StartRecording(ctx context.Context, urlStr, name, cle
 		StartedAt: now.Unix(),
 		Status:    string(types.RecordingStatusRecording),
 		FilePath:  filePath,
+		Volume:    volume,
 		ClearKey:  clearKey,
+		Headers:   headers,
+		Quality:   quality,
+		Backups:   normalizeBackups(urlStr, backups),
 	}
 
 	// Check for duplicate AND reserve the slot atomically
@@ -120,54 +238,166 @@ func (m *RecordingManager) StartRecording(ctx context.Context, urlStr, name, cle
 	m.recordings[id] = placeholderState
 	m.mu.Unlock()
 
-	m.log.Info("starting recording", "id", id, "name", name, "url", urlStr)
+	m.log.Info("starting recording", "id", id, "name", name, "url", urlStr, "backups", len(recording.Backups))
+
+	// Resolve extractor URLs up front so a bad link fails the request instead of the recording
+	if err := m.resolveSource(ctx, placeholderState, false); err != nil {
+		m.removeRecording(id)
+		return nil, err
+	}
 
 	// Create process context with timeout
 	procCtx, procCancel := context.WithTimeout(m.ctx, m.cfg.MaxRecordingDuration)
 
-	// Build FFmpeg command
-	args := m.buildRecordingArgs(urlStr, clearKey, filePath)
-	cmd := exec.CommandContext(procCtx, m.cfg.FFmpegPath, args...)
-
-	// Create pipes
-	stderrPipe, err := cmd.StderrPipe()
+	// FFmpeg writes to stdout and we append to the file, so restarts after a
+	// stall continue the same recording instead of overwriting it.
+	outFile, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0644)
 	if err != nil {
 		procCancel()
 		m.removeRecording(id)
-		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+		return nil, fmt.Errorf("failed to create recording file: %w", err)
 	}
 
-	stdinPipe, err := cmd.StdinPipe()
-	if err != nil {
-		procCancel()
-		m.removeRecording(id)
-		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
-	}
+	placeholderState.mu.Lock()
+	placeholderState.procCtx = procCtx
+	placeholderState.procCancel = procCancel
+	placeholderState.outFile = outFile
+	placeholderState.mu.Unlock()
 
-	// Start FFmpeg
-	if err := cmd.Start(); err != nil {
+	if err := m.startProcess(placeholderState); err != nil {
 		procCancel()
+		outFile.Close()
 		m.removeRecording(id)
-		return nil, fmt.Errorf("failed to start FFmpeg: %w", err)
+		return nil, err
 	}
 
-	// Update the placeholder state with FFmpeg process info
-	placeholderState.mu.Lock()
-	placeholderState.cmd = cmd
-	placeholderState.procCancel = procCancel
-	placeholderState.stdinPipe = stdinPipe
-	placeholderState.stderrPipe = stderrPipe
-	placeholderState.mu.Unlock()
-
 	// Save to disk
 	m.saveRecordings()
 
+	placeholderState.mu.Lock()
+	snapshot := snapshotRecording(recording)
+	placeholderState.mu.Unlock()
+	m.notify(types.EventRecordingStarted, snapshot, fmt.Sprintf("🔴 Recording started: %s", name))
+
 	// Monitor in background
 	go m.monitorRecording(placeholderState)
 
 	return recording, nil
 }
 
+// resolveSource resolves the source in use through the extractor registry.
+// Plain stream URLs are left untouched. forceRefresh bypasses extractor caches,
+// which is used on restarts since the previous stream token may have expired.
+// The resolved URL is recorded as is only by passthrough copies; FFmpeg goes
+// through the proxy with the extractor URL.
+func (m *RecordingManager) resolveSource(ctx context.Context, state *recordingState, forceRefresh bool) error {
+	if m.extractorRegistry == nil {
+		return nil
+	}
+
+	state.mu.Lock()
+	urlStr := sourceURL(state.recording)
+	headers := state.recording.Headers
+	state.mu.Unlock()
+
+	extractor := m.extractorRegistry.Get(urlStr)
+	if extractor == nil || extractor.Name() == "generic" {
+		return nil
+	}
+
+	result, err := m.extractorRegistry.Extract(ctx, extractor, urlStr, interfaces.ExtractOptions{
+		Headers:      headers,
+		ForceRefresh: forceRefresh,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to resolve recording URL: %w", err)
+	}
+
+	resolvedHeaders := make(map[string]string, len(headers)+len(result.RequestHeaders))
+	for k, v := range headers {
+		resolvedHeaders[k] = v
+	}
+	for k, v := range result.RequestHeaders {
+		resolvedHeaders[k] = v
+	}
+	if result.RequestCookies != "" {
+		resolvedHeaders["Cookie"] = result.RequestCookies
+	}
+
+	m.log.Debug("resolved recording URL", "extractor", extractor.Name(), "original", urlStr, "resolved", result.DestinationURL)
+
+	state.mu.Lock()
+	state.recording.Extractor = extractor.Name()
+	state.recording.ResolvedURL = result.DestinationURL
+	state.resolvedHeaders = resolvedHeaders
+	state.mu.Unlock()
+
+	return nil
+}
+
+// startProcess launches an FFmpeg process for the source in use, appending to
+// the recording's output file. Raw TS sources are copied directly instead.
+func (m *RecordingManager) startProcess(state *recordingState) error {
+	state.mu.Lock()
+	urlStr := sourceURL(state.recording)
+	headers := state.recording.Headers
+	streamURL, streamHeaders := urlStr, headers
+	if state.recording.ResolvedURL != "" {
+		streamURL = state.recording.ResolvedURL
+		streamHeaders = state.resolvedHeaders
+	}
+	clearKey := state.recording.ClearKey
+	quality := state.recording.Quality
+	continued := state.recording.Restarts > 0
+	procCtx := state.procCtx
+	outFile := state.outFile
+	state.mu.Unlock()
+
+	attemptCtx, attemptCancel := context.WithCancel(procCtx)
+
+	if m.usePassthrough(streamURL, clearKey) {
+		return m.startPassthrough(state, attemptCtx, attemptCancel, streamURL, streamHeaders)
+	}
+
+	// FFmpeg reads extractor URLs as given: the proxy extracts them and
+	// re-extracts the stream whenever its token expires, so long recordings
+	// don't fail or restart on an expired token
+	args := m.buildRecordingArgs(urlStr, clearKey, quality, headers, continued, "pipe:1")
+	cmd := exec.CommandContext(attemptCtx, m.cfg.FFmpegPath, args...)
+	cmd.Stdout = outFile
+
+	// Create pipes
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		attemptCancel()
+		return fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	stdinPipe, err := cmd.StdinPipe()
+	if err != nil {
+		attemptCancel()
+		return fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+
+	// Start FFmpeg
+	if err := cmd.Start(); err != nil {
+		attemptCancel()
+		return fmt.Errorf("failed to start FFmpeg: %w", err)
+	}
+
+	// Update the state with FFmpeg process info
+	state.mu.Lock()
+	state.cmd = cmd
+	state.attemptCancel = attemptCancel
+	state.stdinPipe = stdinPipe
+	state.stderrPipe = stderrPipe
+	state.copyDone = nil
+	state.recording.Passthrough = false
+	state.mu.Unlock()
+
+	return nil
+}
+
 // removeRecording removes a recording from the map (used for cleanup on error).
 func (m *RecordingManager) removeRecording(id string) {
 	m.mu.Lock()
@@ -175,29 +405,99 @@ func (m *RecordingManager) removeRecording(id string) {
 	m.mu.Unlock()
 }
 
-// monitorRecording monitors a recording process.
+// monitorRecording monitors a recording process, restarting FFmpeg when it
+// stalls or exits unexpectedly until the restart budget is exhausted. A
+// recording with backup sources switches to the next source instead; the
+// budget then applies to each source.
 func (m *RecordingManager) monitorRecording(state *recordingState) {
 	defer close(state.done)
 
-	// Capture stderr
-	var stderrOutput string
-	stderrDone := make(chan struct{})
-	go func() {
-		defer close(stderrDone)
-		if state.stderrPipe != nil {
-			data, _ := io.ReadAll(state.stderrPipe)
-			stderrOutput = string(data)
-			if len(stderrOutput) > 1000 {
-				stderrOutput = stderrOutput[len(stderrOutput)-1000:]
+	var err error
+	for {
+		var stalled bool
+		var stderrOutput string
+		err, stalled, stderrOutput = m.waitProcess(state)
+
+		state.mu.Lock()
+		recording := state.recording
+		stopped := state.stopped
+		procCtx := state.procCtx
+		state.mu.Unlock()
+
+		if stopped || procCtx.Err() != nil || (err == nil && !stalled) {
+			break
+		}
+
+		reason := "stall"
+		if !stalled {
+			reason = fmt.Sprintf("ffmpeg exited: %v", err)
+			if recording.Passthrough {
+				reason = fmt.Sprintf("stream copy failed: %v", err)
 			}
 		}
-	}()
 
-	// Wait for FFmpeg to exit
-	err := state.cmd.Wait()
+		state.mu.Lock()
+		canRestart := recording.Restarts < m.cfg.RecordingMaxRestarts*len(recordingSources(recording))
+		marker := types.RecordingInterruption{
+			At:        time.Now().Unix(),
+			Offset:    m.fileSize(recording.FilePath),
+			Reason:    reason,
+			Restarted: canRestart,
+		}
+		// Switch sources at once, backing off only once all of them failed
+		backoff := true
+		if canRestart {
+			recording.Restarts++
+			var wrapped bool
+			marker.Source, wrapped = failover(recording)
+			backoff = marker.Source == "" || wrapped
+			if marker.Source != "" {
+				// The previous source's resolution doesn't apply to this one
+				recording.Extractor = ""
+				recording.ResolvedURL = ""
+				state.resolvedHeaders = nil
+			}
+		}
+		recording.Interruptions = append(recording.Interruptions, marker)
+		attempt := recording.Restarts
+		state.mu.Unlock()
+
+		if !canRestart {
+			m.log.Warn("recording interrupted, restart limit reached", "id", recording.ID, "reason", reason, "ffmpeg_output", stderrOutput)
+			if err == nil {
+				err = fmt.Errorf("recording stalled")
+			}
+			break
+		}
 
-	// Wait for stderr to be fully read
-	<-stderrDone
+		if marker.Source != "" {
+			m.log.Warn("recording interrupted, switching source", "id", recording.ID, "reason", reason, "source", marker.Source, "attempt", attempt)
+		} else {
+			m.log.Warn("recording interrupted, restarting", "id", recording.ID, "reason", reason, "attempt", attempt)
+		}
+		m.saveRecordings()
+
+		if backoff {
+			select {
+			case <-procCtx.Done():
+			case <-time.After(m.restartBackoff * time.Duration(attempt)):
+			}
+		}
+		if procCtx.Err() != nil {
+			break
+		}
+
+		// Stream tokens from extractors expire, so re-resolve before restarting
+		if resolveErr := m.resolveSource(procCtx, state, true); resolveErr != nil {
+			m.log.Warn("failed to re-resolve recording URL, retrying with previous URL", "id", recording.ID, "error", resolveErr)
+		}
+
+		if startErr := m.startProcess(state); startErr != nil {
+			m.log.Warn("failed to restart recording", "id", recording.ID, "error", startErr)
+			err = startErr
+			break
+		}
+	}
 
 	// Update state
 	state.mu.Lock()
@@ -211,22 +511,208 @@ func (m *RecordingManager) monitorRecording(state *recordingState) {
 			m.log.Info("recording stopped", "id", recording.ID)
 		} else {
 			recording.Status = string(types.RecordingStatusFailed)
-			m.log.Warn("recording failed", "id", recording.ID, "error", err, "ffmpeg_output", stderrOutput)
+			m.log.Warn("recording failed", "id", recording.ID, "error", err)
 		}
 	} else {
 		recording.Status = string(types.RecordingStatusCompleted)
 		m.log.Info("recording completed", "id", recording.ID)
 	}
 
-	// Update file info
-	if info, statErr := os.Stat(recording.FilePath); statErr == nil {
-		recording.FileSize = info.Size()
+	if state.outFile != nil {
+		state.outFile.Close()
 	}
+
+	// Update file info
+	recording.FileSize = m.fileSize(recording.FilePath)
 	recording.Duration = int(time.Now().Unix() - recording.StartedAt)
 
+	upload := m.uploader != nil && recording.Status == string(types.RecordingStatusCompleted)
+	if upload {
+		recording.Upload = &types.RecordingUpload{
+			Status: types.UploadStatusPending,
+			Target: m.uploader.Name(),
+		}
+	}
+
+	snapshot := snapshotRecording(recording)
+	stopped := state.stopped
 	state.mu.Unlock()
 
 	m.saveRecordings()
+
+	if snapshot.FileSize > 0 {
+		m.extractPendingSubtitles(state)
+	}
+
+	analyze := m.cfg.RecordingAnalyze && m.cfg.FFprobePath != "" && snapshot.FileSize > 0
+	chapters := m.cfg.RecordingChapters && snapshot.FileSize > 0
+	if analyze || chapters || upload {
+		m.wg.Add(1)
+		go m.finishRecording(state, analyze, chapters, upload)
+	}
+
+	switch {
+	case snapshot.Status == string(types.RecordingStatusFailed):
+		m.notify(types.EventRecordingFailed, snapshot, fmt.Sprintf("❌ Recording failed: %s", snapshot.Name))
+	case stopped:
+		m.notify(types.EventRecordingStopped, snapshot, fmt.Sprintf("⏹️ Recording stopped: %s", snapshot.Name))
+	default:
+		m.notify(types.EventRecordingCompleted, snapshot, fmt.Sprintf("✅ Recording completed: %s", snapshot.Name))
+	}
+}
+
+// finishRecording analyzes an ended recording and detects its chapters, then
+// uploads it, so both are made from the local copy before it may be removed.
+func (m *RecordingManager) finishRecording(state *recordingState, analyze, chapters, upload bool) {
+	defer m.wg.Done()
+
+	if analyze {
+		m.analyzeFinished(state.recording.ID)
+	}
+	if chapters {
+		m.detectChapters(state)
+	}
+	if upload {
+		m.uploadRecording(state)
+	}
+}
+
+// uploadRecording exports a completed recording and optionally removes the local copy.
+func (m *RecordingManager) uploadRecording(state *recordingState) {
+	state.mu.Lock()
+	recording := state.recording
+	state.recording.Upload.Status = types.UploadStatusUploading
+	remotePath := export.RemotePath(m.cfg.ExportPathTemplate, recording)
+	filePath := recording.FilePath
+	state.mu.Unlock()
+	m.saveRecordings()
+
+	m.log.Info("uploading recording", "id", recording.ID, "target", m.uploader.Name(), "path", remotePath)
+
+	location, err := m.uploadFile(filePath, remotePath)
+
+	state.mu.Lock()
+	if err != nil {
+		recording.Upload.Status = types.UploadStatusFailed
+		recording.Upload.Error = err.Error()
+		m.log.Warn("recording upload failed", "id", recording.ID, "error", err)
+	} else {
+		recording.Upload.Status = types.UploadStatusUploaded
+		recording.Upload.Location = location
+		recording.Upload.UploadedAt = time.Now().Unix()
+		m.log.Info("recording uploaded", "id", recording.ID, "location", location)
+
+		if m.cfg.ExportDeleteLocal {
+			if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+				m.log.Warn("failed to remove local recording after upload", "path", filePath, "error", err)
+			} else {
+				recording.Upload.LocalDeleted = true
+			}
+		}
+	}
+	snapshot := snapshotRecording(recording)
+	state.mu.Unlock()
+
+	m.saveRecordings()
+
+	if err == nil {
+		m.notify(types.EventRecordingUploaded, snapshot, fmt.Sprintf("☁️ Recording uploaded: %s", snapshot.Name))
+	}
+}
+
+// uploadFile streams a local file to the configured uploader.
+func (m *RecordingManager) uploadFile(filePath, remotePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat recording: %w", err)
+	}
+
+	return m.uploader.Upload(m.ctx, remotePath, f, info.Size())
+}
+
+// waitProcess waits for the current FFmpeg process (or passthrough copy) to
+// exit while watching the output file for growth. A process whose output stops
+// growing for longer than the configured stall timeout is killed and reported
+// as stalled.
+func (m *RecordingManager) waitProcess(state *recordingState) (err error, stalled bool, stderrOutput string) {
+	state.mu.Lock()
+	cmd := state.cmd
+	stderrPipe := state.stderrPipe
+	copyDone := state.copyDone
+	attemptCancel := state.attemptCancel
+	filePath := state.recording.FilePath
+	state.mu.Unlock()
+
+	var exited <-chan error = copyDone
+	if copyDone == nil {
+		// Capture stderr
+		stderrDone := make(chan struct{})
+		go func() {
+			defer close(stderrDone)
+			if stderrPipe != nil {
+				data, _ := io.ReadAll(stderrPipe)
+				stderrOutput = string(data)
+				if len(stderrOutput) > 1000 {
+					stderrOutput = stderrOutput[len(stderrOutput)-1000:]
+				}
+			}
+		}()
+
+		cmdExited := make(chan error, 1)
+		go func() {
+			// Wait for stderr to be fully read before Wait closes the pipe
+			<-stderrDone
+			cmdExited <- cmd.Wait()
+		}()
+		exited = cmdExited
+	}
+
+	timeout := m.cfg.RecordingStallTimeout
+	if timeout <= 0 {
+		err = <-exited
+		attemptCancel()
+		return err, false, stderrOutput
+	}
+
+	ticker := time.NewTicker(timeout / 4)
+	defer ticker.Stop()
+
+	lastSize := m.fileSize(filePath)
+	lastGrowth := time.Now()
+
+	for {
+		select {
+		case err = <-exited:
+			attemptCancel()
+			return err, stalled, stderrOutput
+		case <-ticker.C:
+			if stalled {
+				continue
+			}
+			if size := m.fileSize(filePath); size != lastSize {
+				lastSize = size
+				lastGrowth = time.Now()
+			} else if time.Since(lastGrowth) > timeout {
+				m.log.Warn("recording stalled, killing FFmpeg", "path", filePath, "stalled_for", time.Since(lastGrowth).Round(time.Second))
+				stalled = true
+				attemptCancel()
+			}
+		}
+	}
+}
+
+// fileSize returns the size of a file, or 0 if it cannot be read.
+func (m *RecordingManager) fileSize(path string) int64 {
+	if info, err := os.Stat(path); err == nil {
+		return info.Size()
+	}
+	return 0
 }
 
 // StopRecording stops an active recording.
@@ -357,6 +843,11 @@ func (m *RecordingManager) DeleteRecording(id string) error {
 	filePath := state.recording.FilePath
 	procCancel := state.procCancel
 	done := state.done
+	snapshot := snapshotRecording(state.recording)
+	subtitlePaths := make([]string, 0, len(state.recording.Subtitles))
+	for _, sub := range state.recording.Subtitles {
+		subtitlePaths = append(subtitlePaths, sub.FilePath)
+	}
 	state.mu.Unlock()
 
 	delete(m.recordings, id)
@@ -379,10 +870,17 @@ func (m *RecordingManager) DeleteRecording(id string) error {
 			m.log.Warn("failed to remove recording file", "path", filePath, "error", err)
 		}
 	}
+	for _, path := range subtitlePaths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			m.log.Warn("failed to remove subtitle file", "path", path, "error", err)
+		}
+	}
 
 	m.log.Info("deleted recording", "id", id)
 	m.saveRecordings()
 
+	m.notify(types.EventRecordingDeleted, snapshot, fmt.Sprintf("🗑️ Recording deleted: %s", snapshot.Name))
+
 	return nil
 }
 
@@ -403,8 +901,10 @@ func (m *RecordingManager) GetRecordingStream(id string) (io.ReadCloser, error)
 	return os.Open(filePath)
 }
 
-// buildRecordingArgs builds FFmpeg arguments for recording.
-func (m *RecordingManager) buildRecordingArgs(urlStr, clearKey, outputPath string) []string {
+// buildRecordingArgs builds FFmpeg arguments for recording. continued marks
+// the start of the output as a discontinuity, for a restart or a switch of
+// source appending to the recording.
+func (m *RecordingManager) buildRecordingArgs(urlStr, clearKey, quality string, headers map[string]string, continued bool, outputPath string) []string {
 	args := []string{
 		"-hide_banner",
 		"-loglevel", "warning",
@@ -416,7 +916,7 @@ func (m *RecordingManager) buildRecordingArgs(urlStr, clearKey, outputPath strin
 	}
 
 	// Build proxy URL
-	proxyURL := m.buildProxyURL(urlStr, clearKey)
+	proxyURL := m.buildProxyURL(urlStr, clearKey, quality, headers)
 
 	// Network options
 	args = append(args,
@@ -438,14 +938,19 @@ func (m *RecordingManager) buildRecordingArgs(urlStr, clearKey, outputPath strin
 		"-map", "0:a:0?",
 		"-c", "copy",
 		"-f", "mpegts",
-		outputPath,
 	)
+	if continued {
+		// Timestamps and continuity counters start over: tell players
+		args = append(args, "-mpegts_flags", "+initial_discontinuity")
+	}
+	args = append(args, outputPath)
 
 	return args
 }
 
-// buildProxyURL builds a local proxy URL for recording.
-func (m *RecordingManager) buildProxyURL(originalURL, clearKey string) string {
+// buildProxyURL builds a local proxy URL for recording. quality makes the
+// proxy pin a single variant of an HLS master playlist.
+func (m *RecordingManager) buildProxyURL(originalURL, clearKey, quality string, headers map[string]string) string {
 	var endpoint string
 	lower := strings.ToLower(originalURL)
 	if strings.Contains(lower, ".mpd") || strings.Contains(lower, "/dash/") {
@@ -460,6 +965,12 @@ func (m *RecordingManager) buildProxyURL(originalURL, clearKey string) string {
 	if clearKey != "" {
 		query.Set("clearkey", clearKey)
 	}
+	if quality != "" {
+		query.Set("quality", quality)
+	}
+	for key, value := range headers {
+		query.Set("h_"+key, value)
+	}
 	query.Set("no_bypass", "1")
 	proxyURL.RawQuery = query.Encode()
 
@@ -491,9 +1002,27 @@ func (m *RecordingManager) loadRecordings() error {
 		if wasRecording {
 			rec.Status = string(types.RecordingStatusFailed)
 		}
+		// Uploads don't survive a restart
+		if rec.Upload != nil && (rec.Upload.Status == types.UploadStatusPending || rec.Upload.Status == types.UploadStatusUploading) {
+			rec.Upload.Status = types.UploadStatusFailed
+			rec.Upload.Error = "interrupted by restart"
+		}
+		// So don't subtitle captures
+		for i := range rec.Subtitles {
+			sub := &rec.Subtitles[i]
+			if sub.Status == types.SubtitleStatusPending || sub.Status == types.SubtitleStatusCapturing {
+				sub.Status = types.SubtitleStatusFailed
+				sub.Error = "interrupted by restart"
+			}
+		}
+		// Recordings from before volumes were tracked
+		if rec.Volume == "" {
+			rec.Volume = m.volumeFor(rec.FilePath)
+		}
 		// Refresh file size from disk if file exists
 		oldSize := rec.FileSize
-		if rec.FilePath != "" {
+		localDeleted := rec.Upload != nil && rec.Upload.LocalDeleted
+		if rec.FilePath != "" && !localDeleted {
 			if info, err := os.Stat(rec.FilePath); err == nil {
 				rec.FileSize = info.Size()
 				m.log.Info("refreshed file size",
@@ -527,10 +1056,10 @@ func (m *RecordingManager) loadRecordings() error {
 // saveRecordings saves recordings to disk.
 func (m *RecordingManager) saveRecordings() {
 	m.mu.RLock()
-	recordings := make([]*types.Recording, 0, len(m.recordings))
+	recordings := make([]types.Recording, 0, len(m.recordings))
 	for _, state := range m.recordings {
 		state.mu.Lock()
-		recordings = append(recordings, state.recording)
+		recordings = append(recordings, snapshotRecording(state.recording))
 		state.mu.Unlock()
 	}
 	m.mu.RUnlock()
@@ -564,6 +1093,7 @@ func (m *RecordingManager) cleanupLoop() {
 }
 
 // cleanupOldRecordings removes recordings older than retention period.
+// Imported files are kept.
 func (m *RecordingManager) cleanupOldRecordings() {
 	cutoff := time.Now().AddDate(0, 0, -m.cfg.RecordingsRetentionDays)
 
@@ -572,10 +1102,11 @@ func (m *RecordingManager) cleanupOldRecordings() {
 	for id, state := range m.recordings {
 		state.mu.Lock()
 		isActive := state.recording.Status == string(types.RecordingStatusRecording)
+		imported := state.recording.Imported
 		startedAt := time.Unix(state.recording.StartedAt, 0)
 		state.mu.Unlock()
 
-		if !isActive && startedAt.Before(cutoff) {
+		if !isActive && !imported && startedAt.Before(cutoff) {
 			toDelete = append(toDelete, id)
 		}
 	}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/types"
+)
+
+const (
+	// chapterDetectTimeout bounds detecting the chapters of a recording,
+	// which decodes the whole file.
+	chapterDetectTimeout = time.Hour
+	// blackPixelThreshold is the luminance under which a pixel is black.
+	blackPixelThreshold = "0.10"
+	// silenceNoise is the audio level under which sound is silence.
+	silenceNoise = "-50dB"
+	// minChapterLength drops the slivers of program between close filler.
+	minChapterLength = 0.5
+	// detectErrorTail is the number of FFmpeg output lines kept for errors.
+	detectErrorTail = 5
+)
+
+// ErrChaptersUnavailable is returned when chapters can't be detected yet.
+var ErrChaptersUnavailable = errors.New("chapter detection unavailable")
+
+// Detection lines of FFmpeg's blackdetect and silencedetect filters.
+var (
+	blackDetectRe  = regexp.MustCompile(`black_start:\s*([\d.]+)\s+black_end:\s*([\d.]+)`)
+	silenceStartRe = regexp.MustCompile(`silence_start:\s*(-?[\d.]+)`)
+	silenceEndRe   = regexp.MustCompile(`silence_end:\s*([\d.]+)`)
+)
+
+// chapterTitles are the titles of the filler chapters.
+var chapterTitles = map[string]string{
+	types.ChapterBlack:   "Black screen",
+	types.ChapterSilence: "Silence",
+	types.ChapterIdle:    "Break",
+}
+
+// chapterSpan is a black or silent stretch of a recording, in seconds.
+type chapterSpan struct {
+	start, end float64
+}
+
+// DetectChapters starts detecting the chapters of a finished recording in
+// the background, replacing the ones found before.
+func (m *RecordingManager) DetectChapters(id string) error {
+	m.mu.RLock()
+	state, ok := m.recordings[id]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("recording not found: %s", id)
+	}
+
+	state.mu.Lock()
+	var err error
+	switch rec := state.recording; {
+	case rec.Status == string(types.RecordingStatusRecording):
+		err = fmt.Errorf("%w: recording is still running", ErrChaptersUnavailable)
+	case rec.Chapters != nil && rec.Chapters.Status == types.ChaptersStatusDetecting:
+		err = fmt.Errorf("%w: detection already running", ErrChaptersUnavailable)
+	default:
+		rec.Chapters = &types.RecordingChapters{Status: types.ChaptersStatusDetecting}
+	}
+	state.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.detectChapters(state)
+	}()
+	return nil
+}
+
+// detectChapters runs black frame and silence detection over a recording and
+// stores the chapters they split it into.
+func (m *RecordingManager) detectChapters(state *recordingState) {
+	state.mu.Lock()
+	rec := state.recording
+	id, path := rec.ID, rec.FilePath
+	duration := float64(rec.Duration)
+	hasVideo, hasAudio := true, true
+	if rec.Health != nil && rec.Health.Error == "" {
+		// Filters for missing tracks would fail FFmpeg
+		hasVideo, hasAudio = rec.Health.VideoCodec != "", rec.Health.AudioCodec != ""
+		if rec.Health.Duration > 0 {
+			duration = rec.Health.Duration
+		}
+	}
+	rec.Chapters = &types.RecordingChapters{Status: types.ChaptersStatusDetecting}
+	state.mu.Unlock()
+	m.saveRecordings()
+
+	ctx, cancel := context.WithTimeout(m.ctx, chapterDetectTimeout)
+	defer cancel()
+
+	result := &types.RecordingChapters{DetectedAt: time.Now().Unix()}
+	black, silence, err := m.runChapterDetection(ctx, path, hasVideo, hasAudio)
+	if err != nil {
+		result.Status = types.ChaptersStatusFailed
+		result.Error = err.Error()
+		m.log.Warn("chapter detection failed", "id", id, "error", err)
+	} else {
+		result.Status = types.ChaptersStatusReady
+		result.Chapters = buildChapters(black, silence, duration)
+		m.log.Info("chapters detected", "id", id, "chapters", len(result.Chapters))
+	}
+
+	state.mu.Lock()
+	state.recording.Chapters = result
+	state.mu.Unlock()
+	m.saveRecordings()
+}
+
+// runChapterDetection runs FFmpeg's blackdetect and silencedetect filters
+// over a file, returning the black and the silent stretches they report.
+func (m *RecordingManager) runChapterDetection(ctx context.Context, path string, hasVideo, hasAudio bool) (black, silence []chapterSpan, err error) {
+	if !hasVideo && !hasAudio {
+		return nil, nil, fmt.Errorf("no audio or video track")
+	}
+
+	minLength := m.cfg.RecordingChaptersMin.Seconds()
+	args := []string{"-hide_banner", "-nostats", "-i", path}
+	if hasVideo {
+		// Detection doesn't need the full picture
+		args = append(args, "-vf", fmt.Sprintf("scale=320:-2,blackdetect=d=%g:pix_th=%s", minLength, blackPixelThreshold))
+	} else {
+		args = append(args, "-vn")
+	}
+	if hasAudio {
+		args = append(args, "-af", fmt.Sprintf("silencedetect=noise=%s:d=%g", silenceNoise, minLength))
+	} else {
+		args = append(args, "-an")
+	}
+	args = append(args, "-f", "null", "-")
+
+	cmd := exec.CommandContext(ctx, m.cfg.FFmpegPath, args...)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to run ffmpeg: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("failed to run ffmpeg: %w", err)
+	}
+
+	var tail []string
+	silenceStart := -1.0
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := blackDetectRe.FindStringSubmatch(line); match != nil {
+			start, _ := strconv.ParseFloat(match[1], 64)
+			end, _ := strconv.ParseFloat(match[2], 64)
+			black = append(black, chapterSpan{start, end})
+			continue
+		}
+		if match := silenceStartRe.FindStringSubmatch(line); match != nil {
+			silenceStart, _ = strconv.ParseFloat(match[1], 64)
+			silenceStart = max(silenceStart, 0)
+			continue
+		}
+		if match := silenceEndRe.FindStringSubmatch(line); match != nil {
+			end, _ := strconv.ParseFloat(match[1], 64)
+			if silenceStart >= 0 {
+				silence = append(silence, chapterSpan{silenceStart, end})
+			}
+			silenceStart = -1
+			continue
+		}
+		if tail = append(tail, line); len(tail) > detectErrorTail {
+			tail = tail[1:]
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if output := strings.TrimSpace(strings.Join(tail, "\n")); output != "" {
+			return nil, nil, fmt.Errorf("%w: %s", err, output)
+		}
+		return nil, nil, err
+	}
+	if silenceStart >= 0 {
+		// Silent up to the end of the file
+		silence = append(silence, chapterSpan{silenceStart, -1})
+	}
+	return black, silence, nil
+}
+
+// buildChapters splits a recording of duration seconds into program and
+// filler chapters. Overlapping black and silent stretches make one filler
+// chapter, idle when it is both black and silent.
+func buildChapters(black, silence []chapterSpan, duration float64) []types.RecordingChapter {
+	type filler struct {
+		chapterSpan
+		black, silent bool
+	}
+	var spans []filler
+	for _, s := range black {
+		spans = append(spans, filler{s, true, false})
+	}
+	for _, s := range silence {
+		spans = append(spans, filler{s, false, true})
+	}
+	for i := range spans {
+		if spans[i].end < 0 {
+			spans[i].end = max(duration, spans[i].start)
+		}
+		duration = max(duration, spans[i].end)
+	}
+	slices.SortFunc(spans, func(a, b filler) int { return cmp.Compare(a.start, b.start) })
+
+	var merged []filler
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.start <= merged[n-1].end+minChapterLength {
+			last := &merged[n-1]
+			last.end = max(last.end, s.end)
+			last.black = last.black || s.black
+			last.silent = last.silent || s.silent
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	var chapters []types.RecordingChapter
+	parts := 0
+	addProgram := func(start, end float64) {
+		if end-start < minChapterLength {
+			return
+		}
+		parts++
+		chapters = append(chapters, types.RecordingChapter{
+			Title: fmt.Sprintf("Part %d", parts),
+			Kind:  types.ChapterProgram,
+			Start: start,
+			End:   end,
+		})
+	}
+
+	cursor := 0.0
+	for _, f := range merged {
+		addProgram(cursor, f.start)
+		kind := types.ChapterBlack
+		switch {
+		case f.black && f.silent:
+			kind = types.ChapterIdle
+		case f.silent:
+			kind = types.ChapterSilence
+		}
+		chapters = append(chapters, types.RecordingChapter{
+			Title: chapterTitles[kind],
+			Kind:  kind,
+			Start: f.start,
+			End:   f.end,
+		})
+		cursor = f.end
+	}
+	addProgram(cursor, duration)
+	return chapters
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"slices"
+	"strings"
+
+	"media-proxy-go/pkg/types"
+)
+
+// normalizeBackups returns the backup sources of a recording of primary,
+// trimmed, without blanks, duplicates or the primary itself.
+func normalizeBackups(primary string, backups []string) []string {
+	var normalized []string
+	for _, backup := range backups {
+		backup = strings.TrimSpace(backup)
+		if backup == "" || backup == primary || slices.Contains(normalized, backup) {
+			continue
+		}
+		normalized = append(normalized, backup)
+	}
+	return normalized
+}
+
+// recordingSources returns the sources of a recording in failover order:
+// its URL, then the backups.
+func recordingSources(rec *types.Recording) []string {
+	return append([]string{rec.URL}, rec.Backups...)
+}
+
+// sourceURL returns the source a recording is reading.
+func sourceURL(rec *types.Recording) string {
+	sources := recordingSources(rec)
+	if rec.Source < 0 || rec.Source >= len(sources) {
+		return rec.URL
+	}
+	return sources[rec.Source]
+}
+
+// failover moves a recording to its next source, back to the first after the
+// last. It returns the source switched to, empty for a recording without
+// backups, and whether every source has been tried since the first. Caller
+// must hold the state's lock.
+func failover(rec *types.Recording) (source string, wrapped bool) {
+	sources := recordingSources(rec)
+	if len(sources) == 1 {
+		return "", false
+	}
+	rec.Source = (rec.Source + 1) % len(sources)
+	return sources[rec.Source], rec.Source == 0
+}
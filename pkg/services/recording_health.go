@@ -0,0 +1,276 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/types"
+)
+
+const (
+	// healthAnalyzeTimeout bounds analyzing a recording, which demuxes the
+	// whole file.
+	healthAnalyzeTimeout = 10 * time.Minute
+	// discontinuityGap is the smallest timestamp jump counted as a
+	// discontinuity, beyond the duration of the packet before it.
+	discontinuityGap = 1.0
+)
+
+// healthProbe is the part of ffprobe's JSON output the health report uses.
+type healthProbe struct {
+	Streams []struct {
+		CodecType    string `json:"codec_type"`
+		CodecName    string `json:"codec_name"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+		BitRate  string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// AnalyzeRecording analyzes the file of a recording with ffprobe and stores
+// the health report: average bitrate, resolution, dropped packets and
+// timestamp discontinuities. A running recording gets a partial report.
+func (m *RecordingManager) AnalyzeRecording(ctx context.Context, id string) (*types.RecordingHealth, error) {
+	if m.cfg.FFprobePath == "" {
+		return nil, fmt.Errorf("ffprobe is not configured")
+	}
+
+	m.mu.RLock()
+	state, ok := m.recordings[id]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("recording not found: %s", id)
+	}
+
+	state.mu.Lock()
+	path := state.recording.FilePath
+	active := state.recording.Status == string(types.RecordingStatusRecording)
+	interruptions := len(state.recording.Interruptions)
+	state.mu.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("recording file not available: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthAnalyzeTimeout)
+	defer cancel()
+
+	health := &types.RecordingHealth{
+		AnalyzedAt:    time.Now().Unix(),
+		Partial:       active,
+		Interruptions: interruptions,
+	}
+	if err := m.probeHealth(ctx, path, info.Size(), health); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, err
+		}
+		// ffprobe ran but couldn't read the file
+		health.Error = err.Error()
+	}
+	classifyHealth(health)
+
+	state.mu.Lock()
+	state.recording.Health = health
+	state.mu.Unlock()
+	m.saveRecordings()
+
+	m.log.Info("recording analyzed", "id", id, "status", health.Status, "issues", len(health.Issues))
+	result := *health
+	return &result, nil
+}
+
+// analyzeFinished analyzes a recording that just ended, logging failures.
+func (m *RecordingManager) analyzeFinished(id string) {
+	if _, err := m.AnalyzeRecording(m.ctx, id); err != nil {
+		m.log.Warn("failed to analyze recording", "id", id, "error", err)
+	}
+}
+
+// probeHealth fills health from two ffprobe runs: the streams and format of
+// the file, then a scan of the main track's packets.
+func (m *RecordingManager) probeHealth(ctx context.Context, path string, size int64, health *types.RecordingHealth) error {
+	out, err := exec.CommandContext(ctx, m.cfg.FFprobePath,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format", "-show_streams",
+		path,
+	).Output()
+	if err != nil {
+		return fmt.Errorf("failed to run ffprobe: %w", err)
+	}
+	var probe healthProbe
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	for _, s := range probe.Streams {
+		switch {
+		case s.CodecType == "video" && health.VideoCodec == "":
+			health.VideoCodec = s.CodecName
+			health.Width, health.Height = s.Width, s.Height
+			health.FrameRate = parseRatio(s.AvgFrameRate)
+		case s.CodecType == "audio" && health.AudioCodec == "":
+			health.AudioCodec = s.CodecName
+		}
+	}
+	health.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	health.Bitrate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+	if health.Bitrate == 0 && health.Duration > 0 {
+		health.Bitrate = int64(float64(size*8) / health.Duration)
+	}
+
+	track := "v:0"
+	if health.VideoCodec == "" {
+		if health.AudioCodec == "" {
+			return nil
+		}
+		track = "a:0"
+	}
+	return m.scanPackets(ctx, path, track, health)
+}
+
+// scanPackets counts the packets of a track, the corrupt ones, the MPEG-TS
+// continuity errors ffprobe reports and the jumps in decoding timestamps.
+func (m *RecordingManager) scanPackets(ctx context.Context, path, track string, health *types.RecordingHealth) error {
+	cmd := exec.CommandContext(ctx, m.cfg.FFprobePath,
+		"-v", "warning",
+		"-select_streams", track,
+		"-show_entries", "packet=dts_time,duration_time,flags",
+		"-of", "csv=p=0",
+		path,
+	)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to run ffprobe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to run ffprobe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run ffprobe: %w", err)
+	}
+
+	continuityErrors := make(chan int64, 1)
+	go func() {
+		var n int64
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			if strings.Contains(strings.ToLower(scanner.Text()), "continuity check failed") {
+				n++
+			}
+		}
+		io.Copy(io.Discard, stderr)
+		continuityErrors <- n
+	}()
+
+	prevDTS, prevDuration := -1.0, 0.0
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ",")
+		if len(fields) < 3 {
+			continue
+		}
+		dts, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		health.Packets++
+		if strings.Contains(fields[2], "C") {
+			health.Dropped++
+		}
+
+		if prevDTS >= 0 {
+			jump := dts - prevDTS - prevDuration
+			switch {
+			case jump > discontinuityGap:
+				health.Discontinuities++
+				health.Missing += jump
+			case dts-prevDTS < -discontinuityGap:
+				health.Discontinuities++
+			}
+		}
+		prevDTS = dts
+		prevDuration, _ = strconv.ParseFloat(fields[1], 64)
+	}
+	io.Copy(io.Discard, stdout)
+
+	health.Dropped += <-continuityErrors
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("failed to scan packets: %w", err)
+	}
+	return nil
+}
+
+// classifyHealth sets the status and issues of a health report.
+func classifyHealth(health *types.RecordingHealth) {
+	health.Issues = nil
+	unwatchable := false
+	addIssue := func(format string, args ...any) {
+		health.Issues = append(health.Issues, fmt.Sprintf(format, args...))
+	}
+
+	switch {
+	case health.Error != "":
+		unwatchable = true
+		addIssue("file could not be read")
+	case health.VideoCodec == "" && health.AudioCodec == "":
+		unwatchable = true
+		addIssue("no audio or video track")
+	case health.Duration > 0 && health.Missing >= health.Duration/2:
+		unwatchable = true
+		addIssue("%.0fs of %.0fs missing", health.Missing, health.Duration)
+	}
+
+	if health.VideoCodec != "" && health.AudioCodec == "" {
+		addIssue("no audio track")
+	}
+	if health.Discontinuities > 0 {
+		addIssue("timestamp discontinuities: %d (%.1fs missing)", health.Discontinuities, health.Missing)
+	}
+	if health.Dropped > 0 {
+		addIssue("dropped or corrupt packets: %d", health.Dropped)
+	}
+	if health.Interruptions > 0 {
+		addIssue("recorder interruptions: %d", health.Interruptions)
+	}
+
+	switch {
+	case unwatchable:
+		health.Status = types.HealthUnwatchable
+	case len(health.Issues) > 0:
+		health.Status = types.HealthDegraded
+	default:
+		health.Status = types.HealthGood
+	}
+}
+
+// parseRatio parses an ffprobe rate such as "30000/1001", 0 if unknown.
+func parseRatio(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	if !ok {
+		v, _ := strconv.ParseFloat(s, 64)
+		return v
+	}
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if err1 != nil || err2 != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
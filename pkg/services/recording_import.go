@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/types"
+)
+
+// importExtensions are the media files an import scan registers.
+var importExtensions = []string{".ts", ".mp4"}
+
+// recordingFilePattern matches the file names StartRecording gives, so a
+// re-imported recording gets back its name and start time.
+var recordingFilePattern = regexp.MustCompile(`^(\d{8}_\d{6})_(.+)$`)
+
+const (
+	// importMinAge skips files modified more recently, which may still be
+	// copied or downloaded into the watch folder.
+	importMinAge = 30 * time.Second
+	// importProbeTimeout bounds probing the duration of one file.
+	importProbeTimeout = 30 * time.Second
+)
+
+// ImportRecordings registers the media files in the recording volumes and the
+// watch folder that don't belong to a recording, with their size and probed
+// duration. Files stay where they are.
+func (m *RecordingManager) ImportRecordings(ctx context.Context) ([]*types.Recording, error) {
+	m.importMu.Lock()
+	defer m.importMu.Unlock()
+
+	known := make(map[string]bool)
+	m.mu.RLock()
+	for _, state := range m.recordings {
+		state.mu.Lock()
+		known[filepath.Clean(state.recording.FilePath)] = true
+		for _, sub := range state.recording.Subtitles {
+			known[filepath.Clean(sub.FilePath)] = true
+		}
+		state.mu.Unlock()
+	}
+	m.mu.RUnlock()
+
+	dirs := make([]string, 0, len(m.volumes)+1)
+	for _, vol := range m.volumes {
+		dirs = append(dirs, vol.Path)
+	}
+	if m.cfg.RecordingsWatchDir != "" {
+		dirs = append(dirs, m.cfg.RecordingsWatchDir)
+	}
+
+	var imported []*types.Recording
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			m.log.Warn("failed to scan directory for import", "dir", dir, "error", err)
+			continue
+		}
+		for _, entry := range entries {
+			if err := ctx.Err(); err != nil {
+				return imported, err
+			}
+			path := filepath.Clean(filepath.Join(dir, entry.Name()))
+			if entry.IsDir() || known[path] || !slices.Contains(importExtensions, strings.ToLower(filepath.Ext(path))) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || info.Size() == 0 || time.Since(info.ModTime()) < importMinAge {
+				continue
+			}
+			known[path] = true
+
+			rec := m.importFile(ctx, path, info)
+			m.mu.Lock()
+			for m.recordings[rec.ID] != nil {
+				rec.ID = fmt.Sprintf("rec_%d", time.Now().UnixNano())
+			}
+			m.recordings[rec.ID] = &recordingState{recording: rec, done: make(chan struct{})}
+			close(m.recordings[rec.ID].done)
+			m.mu.Unlock()
+
+			m.log.Info("imported media file", "id", rec.ID, "path", path, "duration", rec.Duration)
+			imported = append(imported, rec)
+		}
+	}
+
+	if len(imported) > 0 {
+		m.saveRecordings()
+	}
+	return imported, nil
+}
+
+// importFile builds the recording entry of an imported file.
+func (m *RecordingManager) importFile(ctx context.Context, path string, info os.FileInfo) *types.Recording {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	startedAt := info.ModTime()
+	if match := recordingFilePattern.FindStringSubmatch(name); match != nil {
+		if t, err := time.ParseInLocation("20060102_150405", match[1], time.Local); err == nil {
+			name, startedAt = match[2], t
+		}
+	}
+
+	duration, err := m.probeDuration(ctx, path)
+	if err != nil {
+		m.log.Warn("failed to probe imported file", "path", path, "error", err)
+	}
+
+	return &types.Recording{
+		ID:        fmt.Sprintf("rec_%d", time.Now().UnixNano()),
+		Name:      strings.ReplaceAll(name, "_", " "),
+		StartedAt: startedAt.Unix(),
+		Status:    string(types.RecordingStatusCompleted),
+		Duration:  duration,
+		FilePath:  path,
+		FileSize:  info.Size(),
+		Volume:    m.volumeFor(path),
+		Imported:  true,
+	}
+}
+
+// probeDuration returns the duration of a media file in seconds.
+func (m *RecordingManager) probeDuration(ctx context.Context, path string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, importProbeTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, m.cfg.FFprobePath,
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	).Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to run ffprobe: %w", err)
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse duration %q: %w", strings.TrimSpace(string(out)), err)
+	}
+	return int(seconds), nil
+}
+
+// importLoop periodically imports the files dropped into the watch folder.
+func (m *RecordingManager) importLoop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.cfg.RecordingsImportScan)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.ImportRecordings(m.ctx); err != nil && m.ctx.Err() == nil {
+			m.log.Warn("import scan failed", "error", err)
+		}
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"media-proxy-go/pkg/types"
+)
+
+// ErrInvalidMetadata is returned for a recording update with an invalid field.
+var ErrInvalidMetadata = errors.New("invalid recording metadata")
+
+// UpdateRecording edits the metadata of a recording. Only the fields set in
+// update change; tags are trimmed and deduplicated case-insensitively.
+func (m *RecordingManager) UpdateRecording(id string, update types.RecordingUpdate) (*types.Recording, error) {
+	if update.Name != nil && strings.TrimSpace(*update.Name) == "" {
+		return nil, fmt.Errorf("%w: name must not be empty", ErrInvalidMetadata)
+	}
+	if update.Poster != nil && *update.Poster != "" {
+		u, err := url.Parse(*update.Poster)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%w: poster must be an http(s) URL", ErrInvalidMetadata)
+		}
+	}
+
+	m.mu.RLock()
+	state, ok := m.recordings[id]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("recording not found: %s", id)
+	}
+
+	state.mu.Lock()
+	rec := state.recording
+	if update.Name != nil {
+		rec.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Description != nil {
+		rec.Description = strings.TrimSpace(*update.Description)
+	}
+	if update.Tags != nil {
+		rec.Tags = normalizeTags(*update.Tags)
+	}
+	if update.Poster != nil {
+		rec.Poster = *update.Poster
+	}
+	if update.Favorite != nil {
+		rec.Favorite = *update.Favorite
+	}
+	state.mu.Unlock()
+
+	m.saveRecordings()
+	m.log.Info("updated recording", "id", id)
+	return rec, nil
+}
+
+// normalizeTags trims tags and drops empty and duplicate ones, keeping the
+// first spelling of each.
+func normalizeTags(tags []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, tag)
+	}
+	return result
+}
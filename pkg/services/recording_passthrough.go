@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// errStreamEnded is reported when a live TS upstream closes the connection.
+var errStreamEnded = errors.New("stream ended")
+
+// rawTSExtensions are the extensions of continuous MPEG-TS streams (IPTV
+// /live/.../123.ts links, HDHomeRun tuners...).
+var rawTSExtensions = []string{".ts", ".mpegts", ".m2ts", ".mts"}
+
+// isRawTS reports whether urlStr is a continuous MPEG-TS stream that can be
+// recorded as-is. Local /proxy/stream URLs are judged by the URL they proxy.
+func isRawTS(urlStr string) bool {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return false
+	}
+	if strings.HasSuffix(u.Path, "/proxy/stream") {
+		if inner := u.Query().Get("url"); inner != "" {
+			return isRawTS(inner)
+		}
+	}
+
+	ext := strings.ToLower(path.Ext(u.Path))
+	for _, e := range rawTSExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// usePassthrough reports whether a recording is copied directly instead of
+// remuxed by FFmpeg: the source must already be an unencrypted TS stream.
+func (m *RecordingManager) usePassthrough(urlStr, clearKey string) bool {
+	return m.cfg.RecordingPassthrough && clearKey == "" && isRawTS(urlStr)
+}
+
+// startPassthrough copies a raw TS stream into the recording file through the
+// local proxy, without FFmpeg. The copy's result is sent on state.copyDone;
+// stall detection and restarts work as for FFmpeg recordings.
+func (m *RecordingManager) startPassthrough(state *recordingState, attemptCtx context.Context, attemptCancel context.CancelFunc, urlStr string, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(attemptCtx, http.MethodGet, m.buildStreamURL(urlStr, headers), nil)
+	if err != nil {
+		attemptCancel()
+		return fmt.Errorf("failed to create stream request: %w", err)
+	}
+	if password := m.cfg.StreamingPassword(); password != "" {
+		req.Header.Set("X-API-Password", password)
+	}
+
+	// No client timeout: the recording runs for hours, stalls are caught by waitProcess
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		attemptCancel()
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		attemptCancel()
+		return fmt.Errorf("stream returned status %d", resp.StatusCode)
+	}
+
+	state.mu.Lock()
+	outFile := state.outFile
+	state.mu.Unlock()
+
+	copyDone := make(chan error, 1)
+	go func() {
+		defer resp.Body.Close()
+		_, err := io.Copy(outFile, resp.Body)
+		if err == nil && resp.ContentLength < 0 {
+			// A live stream has no end; treat EOF as a drop so it is restarted
+			err = errStreamEnded
+		}
+		copyDone <- err
+	}()
+
+	state.mu.Lock()
+	state.cmd = nil
+	state.stdinPipe = nil
+	state.stderrPipe = nil
+	state.attemptCancel = attemptCancel
+	state.copyDone = copyDone
+	state.recording.Passthrough = true
+	state.mu.Unlock()
+
+	return nil
+}
+
+// buildStreamURL builds the local /proxy/stream URL a passthrough recording reads.
+func (m *RecordingManager) buildStreamURL(originalURL string, headers map[string]string) string {
+	if u, err := url.Parse(originalURL); err == nil && strings.HasSuffix(u.Path, "/proxy/stream") &&
+		strings.HasPrefix(originalURL, m.baseURL) {
+		return originalURL
+	}
+
+	proxyURL, _ := url.Parse(m.baseURL + "/proxy/stream")
+	query := proxyURL.Query()
+	query.Set("url", originalURL)
+	for key, value := range headers {
+		query.Set("h_"+key, value)
+	}
+	proxyURL.RawQuery = query.Encode()
+	return proxyURL.String()
+}
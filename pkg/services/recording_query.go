@@ -0,0 +1,116 @@
+package services
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"media-proxy-go/pkg/types"
+)
+
+// ErrInvalidQuery is returned for a recordings query with an unknown sort
+// key or order, or a negative limit or offset.
+var ErrInvalidQuery = errors.New("invalid recordings query")
+
+// queriedRecording holds the sort keys of a recording, read under its lock.
+type queriedRecording struct {
+	rec       *types.Recording
+	name      string
+	size      int64
+	startedAt int64
+}
+
+// QueryRecordings returns the recordings matching q, sorted and paged, and
+// the number of matching recordings before paging.
+func (m *RecordingManager) QueryRecordings(q types.RecordingQuery) ([]*types.Recording, int, error) {
+	sortKey := cmp.Or(q.Sort, types.RecordingSortDate)
+	if sortKey != types.RecordingSortDate && sortKey != types.RecordingSortSize && sortKey != types.RecordingSortName {
+		return nil, 0, fmt.Errorf("%w: unknown sort %q (date, size or name)", ErrInvalidQuery, q.Sort)
+	}
+	desc := sortKey != types.RecordingSortName
+	switch q.Order {
+	case "":
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	default:
+		return nil, 0, fmt.Errorf("%w: unknown order %q (asc or desc)", ErrInvalidQuery, q.Order)
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return nil, 0, fmt.Errorf("%w: limit and offset must not be negative", ErrInvalidQuery)
+	}
+	search := strings.ToLower(strings.TrimSpace(q.Query))
+
+	m.mu.RLock()
+	matches := make([]queriedRecording, 0, len(m.recordings))
+	for _, state := range m.recordings {
+		state.mu.Lock()
+		rec := state.recording
+		if (len(q.Status) == 0 || slices.Contains(q.Status, rec.Status)) && matchesRecordingQuery(rec, search) {
+			// Refresh file size if needed, as ListRecordings does
+			if rec.FileSize == 0 && rec.FilePath != "" {
+				if info, err := os.Stat(rec.FilePath); err == nil {
+					rec.FileSize = info.Size()
+				}
+			}
+			matches = append(matches, queriedRecording{
+				rec:       rec,
+				name:      strings.ToLower(rec.Name),
+				size:      rec.FileSize,
+				startedAt: rec.StartedAt,
+			})
+		}
+		state.mu.Unlock()
+	}
+	m.mu.RUnlock()
+
+	slices.SortFunc(matches, func(a, b queriedRecording) int {
+		var c int
+		switch sortKey {
+		case types.RecordingSortSize:
+			c = cmp.Compare(a.size, b.size)
+		case types.RecordingSortName:
+			c = cmp.Compare(a.name, b.name)
+		default:
+			c = cmp.Compare(a.startedAt, b.startedAt)
+		}
+		if desc {
+			c = -c
+		}
+		// Break ties by ID so pages are stable
+		return cmp.Or(c, cmp.Compare(a.rec.ID, b.rec.ID))
+	})
+
+	total := len(matches)
+	start := min(q.Offset, total)
+	end := total
+	if q.Limit > 0 {
+		end = min(start+q.Limit, total)
+	}
+
+	result := make([]*types.Recording, 0, end-start)
+	for _, match := range matches[start:end] {
+		result = append(result, match.rec)
+	}
+	return result, total, nil
+}
+
+// matchesRecordingQuery reports whether the name, description or a tag of
+// rec contains search, which is lowercase.
+func matchesRecordingQuery(rec *types.Recording, search string) bool {
+	if search == "" ||
+		strings.Contains(strings.ToLower(rec.Name), search) ||
+		strings.Contains(strings.ToLower(rec.Description), search) {
+		return true
+	}
+	for _, tag := range rec.Tags {
+		if strings.Contains(strings.ToLower(tag), search) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/types"
+)
+
+const (
+	// subtitleFetchTimeout bounds capturing an external track for a finished
+	// recording, where no recording end stops a live subtitle playlist.
+	subtitleFetchTimeout = 2 * time.Minute
+	// subtitleExtractTimeout bounds extracting embedded subtitles, which
+	// reads the whole recording.
+	subtitleExtractTimeout = 30 * time.Minute
+	// subtitleStopGrace lets FFmpeg finish the WebVTT file after an interrupt.
+	subtitleStopGrace = 5 * time.Second
+)
+
+// ErrInvalidSubtitle is returned for a subtitle track without a usable source.
+var ErrInvalidSubtitle = errors.New("invalid subtitle")
+
+// AddSubtitle attaches a subtitle track to a recording. External tracks are
+// captured right away, a live subtitle playlist until the recording ends;
+// embedded subtitles are extracted once the recording has finished.
+func (m *RecordingManager) AddSubtitle(id string, sub types.RecordingSubtitle) (*types.RecordingSubtitle, error) {
+	if err := ValidateSubtitle(sub); err != nil {
+		return nil, err
+	}
+	if sub.Embedded {
+		sub.URL = ""
+	}
+
+	m.mu.RLock()
+	state, ok := m.recordings[id]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("recording not found: %s", id)
+	}
+
+	state.mu.Lock()
+	rec := state.recording
+	sub.ID = fmt.Sprintf("sub%d", len(rec.Subtitles)+1)
+	sub.FilePath = strings.TrimSuffix(rec.FilePath, filepath.Ext(rec.FilePath)) + "." + sub.ID + ".vtt"
+	sub.Status = types.SubtitleStatusPending
+	sub.Error = ""
+	active := rec.Status == string(types.RecordingStatusRecording)
+	headers := rec.Headers
+	if state.resolvedHeaders != nil {
+		headers = state.resolvedHeaders
+	}
+	rec.Subtitles = append(rec.Subtitles, sub)
+	state.mu.Unlock()
+
+	m.saveRecordings()
+	m.log.Info("subtitle added", "id", id, "subtitle", sub.ID, "url", sub.URL, "embedded", sub.Embedded)
+
+	switch {
+	case !sub.Embedded:
+		m.wg.Add(1)
+		go m.captureSubtitle(state, sub, headers, active)
+	case !active:
+		m.wg.Add(1)
+		go m.extractSubtitle(state, sub)
+	}
+	// Embedded subtitles of an active recording are extracted when it finishes
+	return &sub, nil
+}
+
+// ValidateSubtitle checks that a subtitle track has an http(s) URL or is
+// embedded in the recording.
+func ValidateSubtitle(sub types.RecordingSubtitle) error {
+	if sub.Embedded {
+		return nil
+	}
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be http(s), or set embedded", ErrInvalidSubtitle)
+	}
+	return nil
+}
+
+// extractPendingSubtitles starts extracting the embedded subtitles queued
+// while the recording was running.
+func (m *RecordingManager) extractPendingSubtitles(state *recordingState) {
+	state.mu.Lock()
+	var pending []types.RecordingSubtitle
+	for _, sub := range state.recording.Subtitles {
+		if sub.Embedded && sub.Status == types.SubtitleStatusPending {
+			pending = append(pending, sub)
+		}
+	}
+	state.mu.Unlock()
+
+	for _, sub := range pending {
+		m.wg.Add(1)
+		go m.extractSubtitle(state, sub)
+	}
+}
+
+// captureSubtitle converts an external subtitle track to WebVTT. For an active
+// recording, FFmpeg is interrupted when the recording finishes, which ends the
+// capture of a live subtitle playlist.
+func (m *RecordingManager) captureSubtitle(state *recordingState, sub types.RecordingSubtitle, headers map[string]string, active bool) {
+	defer m.wg.Done()
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if active {
+		ctx, cancel = context.WithCancel(m.ctx)
+		go func() {
+			select {
+			case <-state.done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	} else {
+		ctx, cancel = context.WithTimeout(m.ctx, subtitleFetchTimeout)
+	}
+	defer cancel()
+
+	m.updateSubtitle(state, sub.ID, func(s *types.RecordingSubtitle) {
+		s.Status = types.SubtitleStatusCapturing
+	})
+
+	var args []string
+	if len(headers) > 0 {
+		var headerParts []string
+		for key, value := range headers {
+			headerParts = append(headerParts, fmt.Sprintf("%s: %s", key, value))
+		}
+		args = append(args, "-headers", strings.Join(headerParts, "\r\n"))
+	}
+	args = append(args, "-i", sub.URL, "-map", "0:s:0", "-c:s", "webvtt", "-f", "webvtt", sub.FilePath)
+
+	err := m.runSubtitleFFmpeg(ctx, "", args)
+	if err != nil && ctx.Err() != nil && m.fileSize(sub.FilePath) > 0 {
+		err = nil // Interrupted at the end of the recording, keep what was captured
+	}
+	m.finishSubtitle(state, sub, err)
+}
+
+// extractSubtitle converts the first subtitle stream of the finished recording
+// to WebVTT: teletext (where FFmpeg has libzvbi) or a text subtitle stream,
+// else the EIA-608 closed captions carried in the video.
+func (m *RecordingManager) extractSubtitle(state *recordingState, sub types.RecordingSubtitle) {
+	defer m.wg.Done()
+
+	state.mu.Lock()
+	recordingPath := state.recording.FilePath
+	state.mu.Unlock()
+
+	m.updateSubtitle(state, sub.ID, func(s *types.RecordingSubtitle) {
+		s.Status = types.SubtitleStatusCapturing
+	})
+
+	ctx, cancel := context.WithTimeout(m.ctx, subtitleExtractTimeout)
+	defer cancel()
+
+	// Run next to the files: the movie filter would need its path escaped
+	dir, input, output := filepath.Dir(recordingPath), filepath.Base(recordingPath), filepath.Base(sub.FilePath)
+	attempts := [][]string{
+		{"-txt_format", "text", "-i", input, "-map", "0:s:0", "-c:s", "webvtt", "-f", "webvtt", output},
+		{"-i", input, "-map", "0:s:0", "-c:s", "webvtt", "-f", "webvtt", output},
+		{"-f", "lavfi", "-i", "movie=" + input + "[out0+subcc]", "-map", "0:s:0", "-c:s", "webvtt", "-f", "webvtt", output},
+	}
+
+	var err error
+	for _, args := range attempts {
+		if err = m.runSubtitleFFmpeg(ctx, dir, args); err == nil && m.fileSize(sub.FilePath) > 0 {
+			break
+		}
+		if err == nil {
+			err = fmt.Errorf("no subtitles found")
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	m.finishSubtitle(state, sub, err)
+}
+
+// runSubtitleFFmpeg runs FFmpeg in dir, returning its error output on failure.
+func (m *RecordingManager) runSubtitleFFmpeg(ctx context.Context, dir string, args []string) error {
+	args = append([]string{"-hide_banner", "-loglevel", "error", "-y"}, args...)
+	cmd := exec.CommandContext(ctx, m.cfg.FFmpegPath, args...)
+	cmd.Dir = dir
+	// An interrupt lets FFmpeg finish writing the WebVTT file
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = subtitleStopGrace
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		output := strings.TrimSpace(stderr.String())
+		if len(output) > 300 {
+			output = output[len(output)-300:]
+		}
+		if output == "" {
+			return err
+		}
+		return fmt.Errorf("%w: %s", err, output)
+	}
+	return nil
+}
+
+// finishSubtitle records the outcome of a capture or extraction.
+func (m *RecordingManager) finishSubtitle(state *recordingState, sub types.RecordingSubtitle, err error) {
+	m.updateSubtitle(state, sub.ID, func(s *types.RecordingSubtitle) {
+		if err != nil {
+			s.Status = types.SubtitleStatusFailed
+			s.Error = err.Error()
+			return
+		}
+		s.Status = types.SubtitleStatusReady
+	})
+
+	if err != nil {
+		os.Remove(sub.FilePath)
+		m.log.Warn("subtitle failed", "subtitle", sub.ID, "url", sub.URL, "embedded", sub.Embedded, "error", err)
+		return
+	}
+	m.log.Info("subtitle ready", "subtitle", sub.ID, "path", sub.FilePath)
+}
+
+// updateSubtitle applies fn to a subtitle of the recording and persists it.
+func (m *RecordingManager) updateSubtitle(state *recordingState, subID string, fn func(*types.RecordingSubtitle)) {
+	state.mu.Lock()
+	for i := range state.recording.Subtitles {
+		if state.recording.Subtitles[i].ID == subID {
+			fn(&state.recording.Subtitles[i])
+		}
+	}
+	state.mu.Unlock()
+	m.saveRecordings()
+}
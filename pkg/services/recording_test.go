@@ -1,14 +1,26 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/interfaces"
 	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/registry"
 	"media-proxy-go/pkg/types"
 )
 
@@ -56,7 +68,7 @@ func TestRecordingManager_LoadRecordings_RefreshesFileSize(t *testing.T) {
 	}
 	log := logging.New("error", false, nil)
 
-	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080")
+	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080", nil)
 	if err != nil {
 		t.Fatalf("failed to create recording manager: %v", err)
 	}
@@ -117,7 +129,7 @@ func TestRecordingManager_LoadRecordings_MarksInterruptedAsFailed(t *testing.T)
 	}
 	log := logging.New("error", false, nil)
 
-	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080")
+	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080", nil)
 	if err != nil {
 		t.Fatalf("failed to create recording manager: %v", err)
 	}
@@ -156,7 +168,7 @@ func TestRecordingManager_ListRecordings_RefreshesFileSize(t *testing.T) {
 	}
 	log := logging.New("error", false, nil)
 
-	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080")
+	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080", nil)
 	if err != nil {
 		t.Fatalf("failed to create recording manager: %v", err)
 	}
@@ -228,3 +240,1170 @@ func TestSanitizeFilename(t *testing.T) {
 		})
 	}
 }
+
+func TestRecordingManager_RestartsStalledRecording(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "recording_test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Fake FFmpeg that never writes any output
+	fakeFFmpeg := filepath.Join(tempDir, "ffmpeg")
+	if err := os.WriteFile(fakeFFmpeg, []byte("#!/bin/sh\nexec sleep 30\n"), 0755); err != nil {
+		t.Fatalf("failed to create fake ffmpeg: %v", err)
+	}
+
+	cfg := &config.Config{
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    24 * time.Hour,
+		FFmpegPath:              fakeFFmpeg,
+		RecordingStallTimeout:   200 * time.Millisecond,
+		RecordingMaxRestarts:    1,
+	}
+	log := logging.New("error", false, nil)
+
+	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080", nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+	defer rm.Close()
+	rm.restartBackoff = 10 * time.Millisecond
+
+	rec, err := rm.StartRecording(context.Background(), "https://example.com/stream.m3u8", "stall", "", nil, "", nil)
+	if err != nil {
+		t.Fatalf("StartRecording() error = %v", err)
+	}
+
+	rm.mu.RLock()
+	state := rm.recordings[rec.ID]
+	rm.mu.RUnlock()
+
+	select {
+	case <-state.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording did not finish after exhausting restarts")
+	}
+
+	got, err := rm.GetRecording(rec.ID)
+	if err != nil {
+		t.Fatalf("GetRecording() error = %v", err)
+	}
+	if got.Status != string(types.RecordingStatusFailed) {
+		t.Errorf("Status = %v, want %v", got.Status, types.RecordingStatusFailed)
+	}
+	if got.Restarts != 1 {
+		t.Errorf("Restarts = %v, want 1", got.Restarts)
+	}
+	if len(got.Interruptions) != 2 {
+		t.Fatalf("len(Interruptions) = %v, want 2", len(got.Interruptions))
+	}
+	if !got.Interruptions[0].Restarted || got.Interruptions[1].Restarted {
+		t.Errorf("Interruptions restarted = [%v %v], want [true false]", got.Interruptions[0].Restarted, got.Interruptions[1].Restarted)
+	}
+	if got.Interruptions[0].Reason != "stall" {
+		t.Errorf("Interruptions[0].Reason = %q, want %q", got.Interruptions[0].Reason, "stall")
+	}
+}
+
+func TestRecordingManager_FailsOverToBackupSource(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Fake FFmpeg that fails on the primary source and records its arguments
+	// on the others
+	fakeFFmpeg := filepath.Join(tempDir, "ffmpeg")
+	script := "#!/bin/sh\ncase \"$*\" in *primary*) echo 'Server returned 404 Not Found' >&2; exit 1 ;; esac\nprintf '%s\\n' \"$@\"\n"
+	if err := os.WriteFile(fakeFFmpeg, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to create fake ffmpeg: %v", err)
+	}
+
+	cfg := &config.Config{
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    24 * time.Hour,
+		FFmpegPath:              fakeFFmpeg,
+		RecordingMaxRestarts:    1,
+	}
+	rm, err := NewRecordingManager(cfg, logging.New("error", false, nil), "http://localhost:8080", nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+	defer rm.Close()
+	rm.restartBackoff = time.Hour // Switching sources doesn't wait
+
+	backups := []string{" https://backup.example.com/live.m3u8", "", "https://primary.example.com/live.m3u8", "https://backup.example.com/live.m3u8"}
+	rec, err := rm.StartRecording(context.Background(), "https://primary.example.com/live.m3u8", "match", "", nil, "", backups)
+	if err != nil {
+		t.Fatalf("StartRecording() error = %v", err)
+	}
+
+	rm.mu.RLock()
+	state := rm.recordings[rec.ID]
+	rm.mu.RUnlock()
+	select {
+	case <-state.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording did not finish on the backup source")
+	}
+
+	got, err := rm.GetRecording(rec.ID)
+	if err != nil {
+		t.Fatalf("GetRecording() error = %v", err)
+	}
+	if !reflect.DeepEqual(got.Backups, []string{"https://backup.example.com/live.m3u8"}) {
+		t.Errorf("Backups = %q, want the backup once", got.Backups)
+	}
+	if got.Status != string(types.RecordingStatusCompleted) || got.Source != 1 || got.Restarts != 1 {
+		t.Errorf("recording = status %q, source %d, restarts %d, want completed on the backup", got.Status, got.Source, got.Restarts)
+	}
+	if len(got.Interruptions) != 1 || got.Interruptions[0].Source != "https://backup.example.com/live.m3u8" || !got.Interruptions[0].Restarted {
+		t.Errorf("Interruptions = %+v, want the switch to the backup", got.Interruptions)
+	}
+
+	// The backup's output is marked as a discontinuity
+	args, _ := os.ReadFile(rec.FilePath)
+	if !strings.Contains(string(args), "backup.example.com") || !strings.Contains(string(args), "+initial_discontinuity") {
+		t.Errorf("FFmpeg args = %q, want the backup with a discontinuity", args)
+	}
+}
+
+// stubExtractor resolves every URL containing its name to a fixed stream.
+type stubExtractor struct {
+	name  string
+	calls []interfaces.ExtractOptions
+}
+
+func (e *stubExtractor) Name() string { return e.name }
+
+func (e *stubExtractor) CanExtract(u string) bool { return strings.Contains(u, e.name) }
+
+func (e *stubExtractor) Extract(ctx context.Context, u string, opts interfaces.ExtractOptions) (*types.ExtractResult, error) {
+	e.calls = append(e.calls, opts)
+	return &types.ExtractResult{
+		DestinationURL: "https://cdn.example.com/live/index.m3u8?token=abc",
+		RequestHeaders: map[string]string{"Referer": "https://player.example.com/"},
+	}, nil
+}
+
+func (e *stubExtractor) Close() error { return nil }
+
+func TestRecordingManager_ResolveSource(t *testing.T) {
+	reg := registry.NewExtractorRegistry()
+	stub := &stubExtractor{name: "stubtv"}
+	reg.Register(stub)
+
+	m := &RecordingManager{
+		log:               logging.New("error", false, nil),
+		baseURL:           "http://localhost:8080",
+		extractorRegistry: reg,
+	}
+
+	state := &recordingState{recording: &types.Recording{
+		URL:     "https://stubtv.example.com/watch/42",
+		Headers: map[string]string{"User-Agent": "test-agent"},
+	}}
+
+	if err := m.resolveSource(context.Background(), state, true); err != nil {
+		t.Fatalf("resolveSource() error = %v", err)
+	}
+
+	if state.recording.Extractor != "stubtv" {
+		t.Errorf("Extractor = %q, want %q", state.recording.Extractor, "stubtv")
+	}
+	if state.recording.ResolvedURL != "https://cdn.example.com/live/index.m3u8?token=abc" {
+		t.Errorf("ResolvedURL = %q", state.recording.ResolvedURL)
+	}
+	if len(stub.calls) != 1 || !stub.calls[0].ForceRefresh || stub.calls[0].Headers["User-Agent"] != "test-agent" {
+		t.Errorf("Extract() called with %+v, want one forced call with user headers", stub.calls)
+	}
+
+	proxyURL, err := url.Parse(m.buildProxyURL(state.recording.ResolvedURL, "", "", state.resolvedHeaders))
+	if err != nil {
+		t.Fatalf("buildProxyURL() returned invalid URL: %v", err)
+	}
+	query := proxyURL.Query()
+	if got := query.Get("url"); got != state.recording.ResolvedURL {
+		t.Errorf("url = %q, want %q", got, state.recording.ResolvedURL)
+	}
+	if got := query.Get("h_Referer"); got != "https://player.example.com/" {
+		t.Errorf("h_Referer = %q, want %q", got, "https://player.example.com/")
+	}
+	if got := query.Get("h_User-Agent"); got != "test-agent" {
+		t.Errorf("h_User-Agent = %q, want %q", got, "test-agent")
+	}
+	if query.Has("quality") {
+		t.Errorf("quality = %q without a pinned quality", query.Get("quality"))
+	}
+
+	pinned, _ := url.Parse(m.buildProxyURL(state.recording.ResolvedURL, "", "720p", nil))
+	if got := pinned.Query().Get("quality"); got != "720p" {
+		t.Errorf("quality = %q, want 720p", got)
+	}
+}
+
+func TestRecordingManager_ResolveSource_PlainURL(t *testing.T) {
+	reg := registry.NewExtractorRegistry()
+	reg.Register(&stubExtractor{name: "stubtv"})
+
+	m := &RecordingManager{
+		log:               logging.New("error", false, nil),
+		extractorRegistry: reg,
+	}
+
+	state := &recordingState{recording: &types.Recording{URL: "https://cdn.example.com/live.m3u8"}}
+	if err := m.resolveSource(context.Background(), state, false); err != nil {
+		t.Fatalf("resolveSource() error = %v", err)
+	}
+	if state.recording.ResolvedURL != "" || state.recording.Extractor != "" {
+		t.Errorf("plain URL was resolved: extractor=%q resolved=%q", state.recording.Extractor, state.recording.ResolvedURL)
+	}
+}
+
+func TestRecordingManager_RecordsExtractorURL(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Fake FFmpeg that records its arguments
+	fakeFFmpeg := filepath.Join(tempDir, "ffmpeg")
+	if err := os.WriteFile(fakeFFmpeg, []byte("#!/bin/sh\nprintf '%s\\n' \"$@\"\n"), 0755); err != nil {
+		t.Fatalf("failed to create fake ffmpeg: %v", err)
+	}
+
+	reg := registry.NewExtractorRegistry()
+	reg.Register(&stubExtractor{name: "stubtv"})
+	rm, err := NewRecordingManager(&config.Config{
+		RecordingsDir:        tempDir,
+		MaxRecordingDuration: time.Hour,
+		FFmpegPath:           fakeFFmpeg,
+	}, logging.New("error", false, nil), "http://localhost:8080", reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm.Close()
+
+	rec, err := rm.StartRecording(context.Background(), "https://stubtv.example.com/watch/42", "live", "", nil, "", nil)
+	if err != nil {
+		t.Fatalf("StartRecording() error = %v", err)
+	}
+	rm.mu.RLock()
+	state := rm.recordings[rec.ID]
+	rm.mu.RUnlock()
+	<-state.done
+
+	args, _ := os.ReadFile(rec.FilePath)
+	var input string
+	for _, arg := range strings.Split(string(args), "\n") {
+		if strings.HasPrefix(arg, "http://localhost:8080/") {
+			input = arg
+		}
+	}
+	u, err := url.Parse(input)
+	if err != nil || u.Query().Get("url") != "https://stubtv.example.com/watch/42" {
+		t.Errorf("FFmpeg input = %q, want the proxy with the extractor URL", input)
+	}
+	if rec.ResolvedURL != "https://cdn.example.com/live/index.m3u8?token=abc" {
+		t.Errorf("ResolvedURL = %q", rec.ResolvedURL)
+	}
+}
+
+// stubUploader records uploads in memory.
+type stubUploader struct {
+	uploaded chan string
+	data     []byte
+}
+
+func (u *stubUploader) Name() string { return "stub" }
+
+func (u *stubUploader) Upload(ctx context.Context, remotePath string, r io.Reader, size int64) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	u.data = data
+	u.uploaded <- remotePath
+	return "stub://" + remotePath, nil
+}
+
+func TestRecordingManager_UploadsCompletedRecording(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "recording_test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Fake FFmpeg that writes a little output and exits cleanly
+	fakeFFmpeg := filepath.Join(tempDir, "ffmpeg")
+	if err := os.WriteFile(fakeFFmpeg, []byte("#!/bin/sh\nprintf 'mpegts'\n"), 0755); err != nil {
+		t.Fatalf("failed to create fake ffmpeg: %v", err)
+	}
+
+	cfg := &config.Config{
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    24 * time.Hour,
+		FFmpegPath:              fakeFFmpeg,
+		ExportPathTemplate:      "dvr/{id}.ts",
+		ExportDeleteLocal:       true,
+	}
+	log := logging.New("error", false, nil)
+
+	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080", nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+	uploader := &stubUploader{uploaded: make(chan string, 1)}
+	rm.SetUploader(uploader)
+
+	rec, err := rm.StartRecording(context.Background(), "https://example.com/stream.m3u8", "upload", "", nil, "", nil)
+	if err != nil {
+		t.Fatalf("StartRecording() error = %v", err)
+	}
+
+	select {
+	case remotePath := <-uploader.uploaded:
+		if remotePath != "dvr/"+rec.ID+".ts" {
+			t.Errorf("remote path = %q, want %q", remotePath, "dvr/"+rec.ID+".ts")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording was not uploaded")
+	}
+
+	// Close waits for the upload goroutine to finish updating the recording
+	rm.Close()
+
+	if string(uploader.data) != "mpegts" {
+		t.Errorf("uploaded data = %q, want %q", uploader.data, "mpegts")
+	}
+	if rec.Upload == nil {
+		t.Fatal("Upload = nil, want upload status")
+	}
+	if rec.Upload.Status != types.UploadStatusUploaded {
+		t.Errorf("Upload.Status = %q, want %q", rec.Upload.Status, types.UploadStatusUploaded)
+	}
+	if rec.Upload.Location != "stub://dvr/"+rec.ID+".ts" {
+		t.Errorf("Upload.Location = %q", rec.Upload.Location)
+	}
+	if !rec.Upload.LocalDeleted {
+		t.Error("Upload.LocalDeleted = false, want true")
+	}
+	if _, err := os.Stat(rec.FilePath); !os.IsNotExist(err) {
+		t.Errorf("local file still exists after upload: %v", err)
+	}
+}
+
+// stubNotifier queues the events it receives.
+type stubNotifier struct {
+	events chan types.Event
+}
+
+func (n *stubNotifier) Notify(ctx context.Context, event types.Event) error {
+	n.events <- event
+	return nil
+}
+
+func TestRecordingManager_NotifiesStop(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Fake FFmpeg that runs until it reads the quit command
+	fakeFFmpeg := filepath.Join(tempDir, "ffmpeg")
+	if err := os.WriteFile(fakeFFmpeg, []byte("#!/bin/sh\nprintf 'mpegts'\nhead -c 1 >/dev/null\nexit 255\n"), 0755); err != nil {
+		t.Fatalf("failed to create fake ffmpeg: %v", err)
+	}
+
+	cfg := &config.Config{
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    24 * time.Hour,
+		FFmpegPath:              fakeFFmpeg,
+	}
+	log := logging.New("error", false, nil)
+
+	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080", nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+	defer rm.Close()
+	notifier := &stubNotifier{events: make(chan types.Event, 4)}
+	rm.SetNotifier(notifier)
+
+	headers := map[string]string{"Authorization": "Bearer secret-token"}
+	rec, err := rm.StartRecording(context.Background(), "https://example.com/stream.m3u8?token=secret-token", "stop", "kid:secret-key", headers, "", nil)
+	if err != nil {
+		t.Fatalf("StartRecording() error = %v", err)
+	}
+	if err := rm.StopRecording(rec.ID); err != nil {
+		t.Fatalf("StopRecording() error = %v", err)
+	}
+
+	var got []types.EventType
+	for len(got) < 2 {
+		select {
+		case event := <-notifier.events:
+			got = append(got, event.Type)
+			payload, err := json.Marshal(event)
+			if err != nil {
+				t.Fatalf("failed to marshal event: %v", err)
+			}
+			if strings.Contains(string(payload), "secret") {
+				t.Errorf("%s payload leaks credentials: %s", event.Type, payload)
+			}
+			if event.Recording == nil || event.Recording.ID != rec.ID {
+				t.Errorf("%s recording = %+v, want ID %s", event.Type, event.Recording, rec.ID)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("events = %v, want started and stopped", got)
+		}
+	}
+	// Events are delivered in the background, so their order isn't fixed
+	if !slices.Contains(got, types.EventRecordingStarted) || !slices.Contains(got, types.EventRecordingStopped) {
+		t.Errorf("events = %v, want %s and %s", got, types.EventRecordingStarted, types.EventRecordingStopped)
+	}
+}
+
+func TestIsRawTS(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{"http://iptv.example.com:8080/live/user/pass/123.ts", true},
+		{"https://cdn.example.com/channel.mpegts?token=1", true},
+		{"http://localhost:7860/proxy/stream?url=" + url.QueryEscape("http://iptv.example.com/live/1.ts"), true},
+		{"http://localhost:7860/proxy/stream?url=" + url.QueryEscape("https://cdn.example.com/movie.mp4"), false},
+		{"https://cdn.example.com/live/index.m3u8", false},
+		{"https://cdn.example.com/manifest.mpd", false},
+	}
+
+	for _, tt := range tests {
+		if got := isRawTS(tt.url); got != tt.expected {
+			t.Errorf("isRawTS(%q) = %v, want %v", tt.url, got, tt.expected)
+		}
+	}
+}
+
+func TestRecordingManager_PassthroughRecording(t *testing.T) {
+	tempDir := t.TempDir()
+	packet := make([]byte, 188)
+	packet[0] = 0x47
+
+	// Stands in for the local proxy's /proxy/stream endpoint serving a live TS
+	var gotURL atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL.Store(r.URL.Query().Get("url"))
+		w.Header().Set("Content-Type", "video/mp2t")
+		for i := 0; i < 10; i++ {
+			w.Write(packet)
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer proxy.Close()
+
+	cfg := &config.Config{
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    24 * time.Hour,
+		FFmpegPath:              filepath.Join(tempDir, "missing-ffmpeg"), // Must not be needed
+		RecordingPassthrough:    true,
+	}
+	rm, err := NewRecordingManager(cfg, logging.New("error", false, nil), proxy.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+	defer rm.Close()
+
+	source := "http://iptv.example.com/live/user/pass/1.ts"
+	rec, err := rm.StartRecording(context.Background(), source, "passthrough", "", nil, "", nil)
+	if err != nil {
+		t.Fatalf("StartRecording() error = %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for rm.fileSize(rec.FilePath) < 10*188 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := rm.StopRecording(rec.ID); err != nil {
+		t.Fatalf("StopRecording() error = %v", err)
+	}
+
+	got, err := rm.GetRecording(rec.ID)
+	if err != nil {
+		t.Fatalf("GetRecording() error = %v", err)
+	}
+	if !got.Passthrough {
+		t.Error("Passthrough = false, want true")
+	}
+	if got.Status != string(types.RecordingStatusCompleted) {
+		t.Errorf("Status = %v, want %v", got.Status, types.RecordingStatusCompleted)
+	}
+	if got.FileSize != 10*188 {
+		t.Errorf("FileSize = %d, want %d", got.FileSize, 10*188)
+	}
+	if u, _ := gotURL.Load().(string); u != source {
+		t.Errorf("proxied url = %q, want %q", u, source)
+	}
+}
+
+func TestRecordingManager_Subtitles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Fake FFmpeg that writes a WebVTT file for subtitle commands, else a little output
+	fakeFFmpeg := filepath.Join(tempDir, "ffmpeg")
+	script := `#!/bin/sh
+for arg; do last="$arg"; done
+case "$*" in
+*webvtt*) printf 'WEBVTT\n' > "$last" ;;
+*) printf 'mpegts' ;;
+esac
+`
+	if err := os.WriteFile(fakeFFmpeg, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to create fake ffmpeg: %v", err)
+	}
+
+	cfg := &config.Config{
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    24 * time.Hour,
+		FFmpegPath:              fakeFFmpeg,
+	}
+	log := logging.New("error", false, nil)
+
+	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080", nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+
+	rec, err := rm.StartRecording(context.Background(), "https://example.com/stream.m3u8", "subs", "", nil, "", nil)
+	if err != nil {
+		t.Fatalf("StartRecording() error = %v", err)
+	}
+	rm.mu.RLock()
+	state := rm.recordings[rec.ID]
+	rm.mu.RUnlock()
+	select {
+	case <-state.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording did not complete")
+	}
+
+	if _, err := rm.AddSubtitle(rec.ID, types.RecordingSubtitle{URL: "ftp://example.com/subs.vtt"}); !errors.Is(err, ErrInvalidSubtitle) {
+		t.Errorf("AddSubtitle(ftp) error = %v, want ErrInvalidSubtitle", err)
+	}
+	if _, err := rm.AddSubtitle("missing", types.RecordingSubtitle{Embedded: true}); err == nil {
+		t.Error("AddSubtitle(missing recording) error = nil")
+	}
+
+	external, err := rm.AddSubtitle(rec.ID, types.RecordingSubtitle{URL: "https://example.com/subs.m3u8", Language: "en"})
+	if err != nil {
+		t.Fatalf("AddSubtitle(url) error = %v", err)
+	}
+	embedded, err := rm.AddSubtitle(rec.ID, types.RecordingSubtitle{Embedded: true})
+	if err != nil {
+		t.Fatalf("AddSubtitle(embedded) error = %v", err)
+	}
+	if external.ID != "sub1" || embedded.ID != "sub2" {
+		t.Errorf("subtitle IDs = %q, %q, want sub1, sub2", external.ID, embedded.ID)
+	}
+	wantPath := strings.TrimSuffix(rec.FilePath, ".ts") + ".sub1.vtt"
+	if external.FilePath != wantPath {
+		t.Errorf("FilePath = %q, want %q", external.FilePath, wantPath)
+	}
+
+	var got types.Recording
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		state.mu.Lock()
+		got = snapshotRecording(state.recording)
+		state.mu.Unlock()
+		if got.Subtitles[0].Status != types.SubtitleStatusPending && got.Subtitles[0].Status != types.SubtitleStatusCapturing &&
+			got.Subtitles[1].Status != types.SubtitleStatusPending && got.Subtitles[1].Status != types.SubtitleStatusCapturing {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subtitles were not captured")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	rm.Close()
+
+	if len(got.Subtitles) != 2 {
+		t.Fatalf("len(Subtitles) = %d, want 2", len(got.Subtitles))
+	}
+	for _, sub := range got.Subtitles {
+		if sub.Status != types.SubtitleStatusReady {
+			t.Errorf("%s status = %q (%s), want ready", sub.ID, sub.Status, sub.Error)
+			continue
+		}
+		data, err := os.ReadFile(sub.FilePath)
+		if err != nil || !strings.HasPrefix(string(data), "WEBVTT") {
+			t.Errorf("%s file = %q, %v", sub.ID, data, err)
+		}
+	}
+
+	if err := rm.DeleteRecording(rec.ID); err != nil {
+		t.Fatalf("DeleteRecording() error = %v", err)
+	}
+	for _, sub := range got.Subtitles {
+		if _, err := os.Stat(sub.FilePath); !os.IsNotExist(err) {
+			t.Errorf("%s file still exists after delete: %v", sub.ID, err)
+		}
+	}
+}
+
+func TestRecordingManager_UpdateRecording(t *testing.T) {
+	tempDir := t.TempDir()
+
+	recordings := []*types.Recording{
+		{
+			ID:        "rec_meta",
+			Name:      "Match",
+			URL:       "https://example.com/stream.m3u8",
+			StartedAt: time.Now().Add(-time.Hour).Unix(),
+			Status:    string(types.RecordingStatusCompleted),
+			FilePath:  filepath.Join(tempDir, "match.ts"),
+		},
+	}
+	dbPath := filepath.Join(tempDir, "recordings.json")
+	data, _ := json.MarshalIndent(recordings, "", "  ")
+	if err := os.WriteFile(dbPath, data, 0644); err != nil {
+		t.Fatalf("failed to create recordings.json: %v", err)
+	}
+
+	cfg := &config.Config{
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    24 * time.Hour,
+		FFmpegPath:              "ffmpeg",
+	}
+	log := logging.New("error", false, nil)
+
+	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080", nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+	defer rm.Close()
+
+	name := " Cup Final "
+	description := "Extra time and penalties"
+	tags := []string{"Football", " cup ", "football", ""}
+	poster := "https://example.com/poster.jpg"
+	favorite := true
+	rec, err := rm.UpdateRecording("rec_meta", types.RecordingUpdate{
+		Name:        &name,
+		Description: &description,
+		Tags:        &tags,
+		Poster:      &poster,
+		Favorite:    &favorite,
+	})
+	if err != nil {
+		t.Fatalf("UpdateRecording() error = %v", err)
+	}
+	if rec.Name != "Cup Final" || rec.Description != description || rec.Poster != poster || !rec.Favorite {
+		t.Errorf("recording = %+v", rec)
+	}
+	if strings.Join(rec.Tags, ",") != "Football,cup" {
+		t.Errorf("Tags = %q, want [Football cup]", rec.Tags)
+	}
+
+	// Fields left out are unchanged
+	favorite = false
+	rec, err = rm.UpdateRecording("rec_meta", types.RecordingUpdate{Favorite: &favorite})
+	if err != nil {
+		t.Fatalf("UpdateRecording() error = %v", err)
+	}
+	if rec.Favorite || rec.Name != "Cup Final" || len(rec.Tags) != 2 {
+		t.Errorf("recording after partial update = %+v", rec)
+	}
+
+	empty := " "
+	if _, err := rm.UpdateRecording("rec_meta", types.RecordingUpdate{Name: &empty}); !errors.Is(err, ErrInvalidMetadata) {
+		t.Errorf("UpdateRecording(empty name) error = %v, want ErrInvalidMetadata", err)
+	}
+	badPoster := "javascript:alert(1)"
+	if _, err := rm.UpdateRecording("rec_meta", types.RecordingUpdate{Poster: &badPoster}); !errors.Is(err, ErrInvalidMetadata) {
+		t.Errorf("UpdateRecording(bad poster) error = %v, want ErrInvalidMetadata", err)
+	}
+	if _, err := rm.UpdateRecording("missing", types.RecordingUpdate{Name: &name}); err == nil {
+		t.Error("UpdateRecording(missing) error = nil")
+	}
+
+	// Persisted in the recordings store
+	data, err = os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("failed to read recordings.json: %v", err)
+	}
+	var saved []*types.Recording
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("failed to parse recordings.json: %v", err)
+	}
+	if len(saved) != 1 || saved[0].Name != "Cup Final" || saved[0].Description != description || len(saved[0].Tags) != 2 {
+		t.Errorf("saved recordings = %+v", saved)
+	}
+}
+
+func TestRecordingManager_QueryRecordings(t *testing.T) {
+	tempDir := t.TempDir()
+
+	now := time.Now().Unix()
+	recordings := []*types.Recording{
+		{ID: "rec_a", Name: "Evening News", StartedAt: now - 300, Status: string(types.RecordingStatusCompleted), FileSize: 300},
+		{ID: "rec_b", Name: "cup final", StartedAt: now - 100, Status: string(types.RecordingStatusCompleted), FileSize: 100, Tags: []string{"Football"}},
+		{ID: "rec_c", Name: "Morning News", StartedAt: now - 200, Status: string(types.RecordingStatusFailed), FileSize: 200, Description: "football highlights"},
+		{ID: "rec_d", Name: "Documentary", StartedAt: now - 400, Status: string(types.RecordingStatusCompleted), FileSize: 400},
+	}
+	dbPath := filepath.Join(tempDir, "recordings.json")
+	data, _ := json.MarshalIndent(recordings, "", "  ")
+	if err := os.WriteFile(dbPath, data, 0644); err != nil {
+		t.Fatalf("failed to create recordings.json: %v", err)
+	}
+
+	cfg := &config.Config{
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    24 * time.Hour,
+		FFmpegPath:              "ffmpeg",
+	}
+	log := logging.New("error", false, nil)
+
+	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080", nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+	defer rm.Close()
+
+	tests := []struct {
+		name      string
+		query     types.RecordingQuery
+		wantIDs   string
+		wantTotal int
+	}{
+		{"default newest first", types.RecordingQuery{}, "rec_b,rec_c,rec_a,rec_d", 4},
+		{"oldest first", types.RecordingQuery{Order: "asc"}, "rec_d,rec_a,rec_c,rec_b", 4},
+		{"size largest first", types.RecordingQuery{Sort: "size"}, "rec_d,rec_a,rec_c,rec_b", 4},
+		{"name A-Z, case-insensitive", types.RecordingQuery{Sort: "name"}, "rec_b,rec_d,rec_a,rec_c", 4},
+		{"search name", types.RecordingQuery{Query: "NEWS"}, "rec_c,rec_a", 2},
+		{"search tag and description", types.RecordingQuery{Query: "football"}, "rec_b,rec_c", 2},
+		{"status", types.RecordingQuery{Status: []string{"failed"}}, "rec_c", 1},
+		{"page", types.RecordingQuery{Limit: 2, Offset: 1}, "rec_c,rec_a", 4},
+		{"offset past end", types.RecordingQuery{Offset: 10}, "", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := rm.QueryRecordings(tt.query)
+			if err != nil {
+				t.Fatalf("QueryRecordings() error = %v", err)
+			}
+			var ids []string
+			for _, rec := range got {
+				ids = append(ids, rec.ID)
+			}
+			if strings.Join(ids, ",") != tt.wantIDs {
+				t.Errorf("IDs = %v, want %s", ids, tt.wantIDs)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", total, tt.wantTotal)
+			}
+		})
+	}
+
+	for _, q := range []types.RecordingQuery{{Sort: "length"}, {Order: "up"}, {Limit: -1}} {
+		if _, _, err := rm.QueryRecordings(q); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("QueryRecordings(%+v) error = %v, want ErrInvalidQuery", q, err)
+		}
+	}
+}
+
+func TestRecordingManager_Volumes(t *testing.T) {
+	tempDir := t.TempDir()
+	vol1 := filepath.Join(tempDir, "disk1")
+	vol2 := filepath.Join(tempDir, "disk2")
+
+	// An old recording without a volume, on disk1 which is over its quota
+	if err := os.MkdirAll(vol1, 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldPath := filepath.Join(vol1, "old.ts")
+	if err := os.WriteFile(oldPath, make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	recordings := []*types.Recording{
+		{ID: "rec_old", Name: "old", Status: string(types.RecordingStatusCompleted), FilePath: oldPath, StartedAt: time.Now().Unix()},
+	}
+	data, _ := json.MarshalIndent(recordings, "", "  ")
+	if err := os.WriteFile(filepath.Join(tempDir, "recordings.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    24 * time.Hour,
+		FFmpegPath:              "ffmpeg",
+		RecordingsVolumes: []config.RecordingVolume{
+			{Path: vol1, Quota: 500},
+			{Path: vol2},
+		},
+	}
+	log := logging.New("error", false, nil)
+
+	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080", nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+	defer rm.Close()
+
+	rec, _ := rm.GetRecording("rec_old")
+	if rec.Volume != vol1 {
+		t.Errorf("Volume = %q, want %q", rec.Volume, vol1)
+	}
+
+	volumes := rm.ListVolumes()
+	if len(volumes) != 2 {
+		t.Fatalf("len(ListVolumes()) = %d, want 2", len(volumes))
+	}
+	if volumes[0].Used != 1000 || volumes[0].Recordings != 1 || !volumes[0].Full {
+		t.Errorf("disk1 = %+v, want 1000 bytes used, 1 recording, full", volumes[0])
+	}
+	if volumes[1].Used != 0 || volumes[1].Full {
+		t.Errorf("disk2 = %+v, want empty", volumes[1])
+	}
+
+	// Both policies skip the full volume
+	for _, policy := range []string{PlacementMostFree, PlacementRoundRobin} {
+		cfg.RecordingsPlacement = policy
+		for i := 0; i < 2; i++ {
+			if got, err := rm.pickVolume(); err != nil || got != vol2 {
+				t.Errorf("%s: pickVolume() = %q, %v, want %q", policy, got, err, vol2)
+			}
+		}
+	}
+
+	// Round robin alternates between volumes with room
+	cfg.RecordingsVolumes[0].Quota = 0
+	rm.volumes = recordingVolumes(cfg)
+	first, _ := rm.pickVolume()
+	second, _ := rm.pickVolume()
+	if first == second {
+		t.Errorf("round robin picked %q twice", first)
+	}
+
+	// Every volume full
+	rm.volumes = []config.RecordingVolume{{Path: vol1, Quota: 500}}
+	if _, err := rm.pickVolume(); !errors.Is(err, ErrVolumesFull) {
+		t.Errorf("pickVolume() error = %v, want ErrVolumesFull", err)
+	}
+}
+
+func TestRecordingManager_ImportRecordings(t *testing.T) {
+	tempDir := t.TempDir()
+	watchDir := filepath.Join(t.TempDir(), "watch")
+
+	// Fake ffprobe reporting a duration of an hour
+	fakeFFprobe := filepath.Join(tempDir, "ffprobe")
+	if err := os.WriteFile(fakeFFprobe, []byte("#!/bin/sh\necho 3600.5\n"), 0755); err != nil {
+		t.Fatalf("failed to create fake ffprobe: %v", err)
+	}
+
+	// A file of a known recording
+	knownPath := filepath.Join(tempDir, "known.ts")
+	recordings := []*types.Recording{
+		{ID: "rec_known", Name: "known", Status: string(types.RecordingStatusCompleted), FilePath: knownPath, StartedAt: time.Now().Unix()},
+	}
+	data, _ := json.MarshalIndent(recordings, "", "  ")
+	if err := os.WriteFile(filepath.Join(tempDir, "recordings.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    24 * time.Hour,
+		FFmpegPath:              "ffmpeg",
+		FFprobePath:             fakeFFprobe,
+		RecordingsWatchDir:      watchDir,
+	}
+	log := logging.New("error", false, nil)
+
+	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080", nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+	defer rm.Close()
+
+	old := time.Now().Add(-time.Hour)
+	for path, modTime := range map[string]time.Time{
+		knownPath: old,
+		filepath.Join(tempDir, "20240101_203000_Cup_Final.ts"): old,
+		filepath.Join(watchDir, "movie.mp4"):                   old,
+		filepath.Join(watchDir, "notes.txt"):                   old,
+		filepath.Join(watchDir, "downloading.mp4"):             time.Now(), // Still being written
+	} {
+		if err := os.WriteFile(path, []byte("media"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	imported, err := rm.ImportRecordings(context.Background())
+	if err != nil {
+		t.Fatalf("ImportRecordings() error = %v", err)
+	}
+	byName := make(map[string]*types.Recording)
+	for _, rec := range imported {
+		byName[rec.Name] = rec
+	}
+	if len(imported) != 2 || byName["Cup Final"] == nil || byName["movie"] == nil {
+		t.Fatalf("imported = %+v, want Cup Final and movie", imported)
+	}
+
+	final := byName["Cup Final"]
+	wantStart := time.Date(2024, 1, 1, 20, 30, 0, 0, time.Local).Unix()
+	if final.StartedAt != wantStart {
+		t.Errorf("StartedAt = %d, want %d (from the file name)", final.StartedAt, wantStart)
+	}
+	if final.Duration != 3600 || final.FileSize != 5 || !final.Imported || final.Volume != tempDir {
+		t.Errorf("recording = %+v", final)
+	}
+	if final.Status != string(types.RecordingStatusCompleted) {
+		t.Errorf("Status = %q, want completed", final.Status)
+	}
+	if movie := byName["movie"]; movie.StartedAt != old.Unix() || movie.Volume != "" {
+		t.Errorf("watch folder recording = %+v, want modification time and no volume", movie)
+	}
+
+	// Imported files are registered once, and kept by retention cleanup
+	again, err := rm.ImportRecordings(context.Background())
+	if err != nil || len(again) != 0 {
+		t.Errorf("second ImportRecordings() = %+v, %v, want nothing", again, err)
+	}
+	rm.cleanupOldRecordings()
+	if _, err := rm.GetRecording(final.ID); err != nil {
+		t.Errorf("imported recording removed by retention cleanup: %v", err)
+	}
+}
+
+func TestRecordingManager_AnalyzeRecording(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Fake ffprobe: the streams of a 720p recording, then video packets with
+	// a 5s jump, a corrupt packet and a continuity error. broken.ts is rejected.
+	fakeFFprobe := filepath.Join(tempDir, "ffprobe")
+	script := `#!/bin/sh
+case "$*" in
+*broken.ts*) echo "broken.ts: Invalid data found when processing input" >&2; exit 1 ;;
+*-show_format*) echo '{"streams":[{"codec_type":"video","codec_name":"h264","width":1280,"height":720,"avg_frame_rate":"25/1"},{"codec_type":"audio","codec_name":"aac"}],"format":{"duration":"60.000","bit_rate":"2000000"}}' ;;
+*) printf '0.000000,0.040000,K__\n0.040000,0.040000,___\n5.080000,0.040000,_C_\nN/A,N/A,___\n5.120000,0.040000,___\n'
+   echo "[mpegts @ 0x1] Continuity check failed for pid 256 expected 3 got 5" >&2 ;;
+esac
+`
+	if err := os.WriteFile(fakeFFprobe, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to create fake ffprobe: %v", err)
+	}
+
+	goodPath := filepath.Join(tempDir, "match.ts")
+	brokenPath := filepath.Join(tempDir, "broken.ts")
+	for _, path := range []string{goodPath, brokenPath} {
+		if err := os.WriteFile(path, []byte("media"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recordings := []*types.Recording{
+		{ID: "rec_match", Name: "match", Status: string(types.RecordingStatusCompleted), FilePath: goodPath, StartedAt: time.Now().Unix(),
+			Interruptions: []types.RecordingInterruption{{Reason: "stall"}}},
+		{ID: "rec_broken", Name: "broken", Status: string(types.RecordingStatusCompleted), FilePath: brokenPath, StartedAt: time.Now().Unix()},
+		{ID: "rec_gone", Name: "gone", Status: string(types.RecordingStatusCompleted), FilePath: filepath.Join(tempDir, "gone.ts"), StartedAt: time.Now().Unix()},
+	}
+	data, _ := json.MarshalIndent(recordings, "", "  ")
+	if err := os.WriteFile(filepath.Join(tempDir, "recordings.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    24 * time.Hour,
+		FFmpegPath:              "ffmpeg",
+		FFprobePath:             fakeFFprobe,
+	}
+	log := logging.New("error", false, nil)
+
+	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080", nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+	defer rm.Close()
+
+	health, err := rm.AnalyzeRecording(context.Background(), "rec_match")
+	if err != nil {
+		t.Fatalf("AnalyzeRecording() error = %v", err)
+	}
+	if health.Status != types.HealthDegraded {
+		t.Errorf("Status = %q, want degraded", health.Status)
+	}
+	if health.Width != 1280 || health.Height != 720 || health.FrameRate != 25 || health.Bitrate != 2000000 ||
+		health.VideoCodec != "h264" || health.AudioCodec != "aac" || health.Duration != 60 {
+		t.Errorf("health = %+v, want the streams and format of the file", health)
+	}
+	if health.Packets != 4 || health.Dropped != 2 || health.Discontinuities != 1 || health.Interruptions != 1 {
+		t.Errorf("packets = %d, dropped = %d, discontinuities = %d, interruptions = %d, want 4, 2, 1, 1",
+			health.Packets, health.Dropped, health.Discontinuities, health.Interruptions)
+	}
+	if health.Missing < 4.99 || health.Missing > 5.01 {
+		t.Errorf("Missing = %v, want 5s", health.Missing)
+	}
+	if len(health.Issues) != 3 {
+		t.Errorf("Issues = %q, want the discontinuity, drops and interruption", health.Issues)
+	}
+
+	// The report is stored with the recording
+	if rec, _ := rm.GetRecording("rec_match"); rec.Health == nil || rec.Health.Status != types.HealthDegraded {
+		t.Errorf("stored health = %+v", rec.Health)
+	}
+
+	health, err = rm.AnalyzeRecording(context.Background(), "rec_broken")
+	if err != nil {
+		t.Fatalf("AnalyzeRecording() of an unreadable file error = %v", err)
+	}
+	if health.Status != types.HealthUnwatchable || health.Error == "" {
+		t.Errorf("health = %+v, want unwatchable with the error", health)
+	}
+
+	if _, err := rm.AnalyzeRecording(context.Background(), "rec_gone"); err == nil {
+		t.Error("AnalyzeRecording() of a missing file succeeded")
+	}
+	if _, err := rm.AnalyzeRecording(context.Background(), "rec_unknown"); err == nil {
+		t.Error("AnalyzeRecording() of an unknown recording succeeded")
+	}
+}
+
+func TestClassifyHealth(t *testing.T) {
+	tests := []struct {
+		name   string
+		health types.RecordingHealth
+		want   string
+	}{
+		{"clean", types.RecordingHealth{VideoCodec: "h264", AudioCodec: "aac", Duration: 60}, types.HealthGood},
+		{"radio", types.RecordingHealth{AudioCodec: "aac", Duration: 60}, types.HealthGood},
+		{"no audio", types.RecordingHealth{VideoCodec: "h264", Duration: 60}, types.HealthDegraded},
+		{"no tracks", types.RecordingHealth{Duration: 60}, types.HealthUnwatchable},
+		{"mostly gaps", types.RecordingHealth{VideoCodec: "h264", AudioCodec: "aac", Duration: 60, Discontinuities: 2, Missing: 40}, types.HealthUnwatchable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classifyHealth(&tt.health)
+			if tt.health.Status != tt.want {
+				t.Errorf("Status = %q, want %q (issues %q)", tt.health.Status, tt.want, tt.health.Issues)
+			}
+		})
+	}
+}
+
+func TestBuildChapters(t *testing.T) {
+	black := []chapterSpan{{600, 720}, {1800, 1802}}
+	silence := []chapterSpan{{610, 725}, {3000, -1}}
+	chapters := buildChapters(black, silence, 3300)
+
+	want := []types.RecordingChapter{
+		{Title: "Part 1", Kind: types.ChapterProgram, Start: 0, End: 600},
+		{Title: "Break", Kind: types.ChapterIdle, Start: 600, End: 725},
+		{Title: "Part 2", Kind: types.ChapterProgram, Start: 725, End: 1800},
+		{Title: "Black screen", Kind: types.ChapterBlack, Start: 1800, End: 1802},
+		{Title: "Part 3", Kind: types.ChapterProgram, Start: 1802, End: 3000},
+		{Title: "Silence", Kind: types.ChapterSilence, Start: 3000, End: 3300},
+	}
+	if !reflect.DeepEqual(chapters, want) {
+		t.Errorf("buildChapters() = %+v, want %+v", chapters, want)
+	}
+
+	if chapters := buildChapters(nil, nil, 60); len(chapters) != 1 || chapters[0].Kind != types.ChapterProgram || chapters[0].End != 60 {
+		t.Errorf("buildChapters() without filler = %+v, want one program chapter", chapters)
+	}
+}
+
+func TestRecordingManager_DetectChapters(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Fake ffmpeg logging its arguments and printing the detection lines of
+	// a break at 10 minutes
+	argsFile := filepath.Join(tempDir, "args")
+	fakeFFmpeg := filepath.Join(tempDir, "ffmpeg")
+	script := `#!/bin/sh
+echo "$@" > ` + argsFile + `
+echo "[blackdetect @ 0x1] black_start:600 black_end:720 black_duration:120" >&2
+echo "[silencedetect @ 0x2] silence_start: 601.5" >&2
+echo "[silencedetect @ 0x2] silence_end: 719 | silence_duration: 117.5" >&2
+`
+	if err := os.WriteFile(fakeFFmpeg, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to create fake ffmpeg: %v", err)
+	}
+
+	filePath := filepath.Join(tempDir, "match.ts")
+	if err := os.WriteFile(filePath, []byte("media"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	recordings := []*types.Recording{
+		{ID: "rec_match", Name: "match", Status: string(types.RecordingStatusCompleted), FilePath: filePath, Duration: 1800, StartedAt: time.Now().Unix(),
+			Health: &types.RecordingHealth{VideoCodec: "h264", Duration: 1800}},
+	}
+	data, _ := json.MarshalIndent(recordings, "", "  ")
+	if err := os.WriteFile(filepath.Join(tempDir, "recordings.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    24 * time.Hour,
+		FFmpegPath:              fakeFFmpeg,
+		RecordingChaptersMin:    5 * time.Second,
+	}
+	log := logging.New("error", false, nil)
+
+	rm, err := NewRecordingManager(cfg, log, "http://localhost:8080", nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+	defer rm.Close()
+
+	if err := rm.DetectChapters("rec_match"); err != nil {
+		t.Fatalf("DetectChapters() error = %v", err)
+	}
+	state := rm.recordings["rec_match"]
+	var chapters *types.RecordingChapters
+	deadline := time.Now().Add(5 * time.Second)
+	for chapters == nil && time.Now().Before(deadline) {
+		state.mu.Lock()
+		snapshot := snapshotRecording(state.recording)
+		state.mu.Unlock()
+		if snapshot.Chapters != nil && snapshot.Chapters.Status != types.ChaptersStatusDetecting {
+			chapters = snapshot.Chapters
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if chapters == nil || chapters.Status != types.ChaptersStatusReady {
+		t.Fatalf("chapters = %+v, want ready", chapters)
+	}
+	if len(chapters.Chapters) != 3 || chapters.Chapters[1].Kind != types.ChapterIdle ||
+		chapters.Chapters[1].Start != 600 || chapters.Chapters[2].End != 1800 {
+		t.Errorf("chapters = %+v, want a break at 10 minutes", chapters.Chapters)
+	}
+
+	// The recording has no audio track: only the video is analyzed
+	args, _ := os.ReadFile(argsFile)
+	if !strings.Contains(string(args), "blackdetect=d=5:pix_th=0.10") || !strings.Contains(string(args), "-an") ||
+		strings.Contains(string(args), "silencedetect") {
+		t.Errorf("ffmpeg args = %q, want black frame detection only", args)
+	}
+
+	state.mu.Lock()
+	state.recording.Status = string(types.RecordingStatusRecording)
+	state.mu.Unlock()
+	if err := rm.DetectChapters("rec_match"); !errors.Is(err, ErrChaptersUnavailable) {
+		t.Errorf("DetectChapters() of an active recording error = %v, want ErrChaptersUnavailable", err)
+	}
+	if err := rm.DetectChapters("rec_unknown"); err == nil {
+		t.Error("DetectChapters() of an unknown recording succeeded")
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/types"
+)
+
+// Recording placement policies.
+const (
+	PlacementMostFree   = "most-free"
+	PlacementRoundRobin = "round-robin"
+)
+
+// ErrVolumesFull is returned when every recording volume has reached its
+// quota or is unavailable.
+var ErrVolumesFull = errors.New("no recording volume available")
+
+// errDiskFreeUnsupported is returned by diskFree where free space can't be read.
+var errDiskFreeUnsupported = errors.New("free space not supported on this platform")
+
+// recordingVolumes returns the configured volumes, or RecordingsDir alone.
+func recordingVolumes(cfg *config.Config) []config.RecordingVolume {
+	if len(cfg.RecordingsVolumes) > 0 {
+		return cfg.RecordingsVolumes
+	}
+	return []config.RecordingVolume{{Path: cfg.RecordingsDir}}
+}
+
+// ListVolumes returns the usage of each recording volume.
+func (m *RecordingManager) ListVolumes() []types.RecordingVolume {
+	used, counts := m.volumeUsage()
+
+	result := make([]types.RecordingVolume, 0, len(m.volumes))
+	for _, vol := range m.volumes {
+		info := types.RecordingVolume{
+			Path:       vol.Path,
+			Quota:      vol.Quota,
+			Used:       used[vol.Path],
+			Recordings: counts[vol.Path],
+			Free:       -1,
+			Full:       vol.Quota > 0 && used[vol.Path] >= vol.Quota,
+		}
+		if free, err := diskFree(vol.Path); err == nil {
+			info.Free = free
+		} else if !errors.Is(err, errDiskFreeUnsupported) {
+			info.Error = err.Error()
+		}
+		result = append(result, info)
+	}
+	return result
+}
+
+// pickVolume chooses the volume for a new recording by the placement policy,
+// skipping volumes that reached their quota or can't be read.
+func (m *RecordingManager) pickVolume() (string, error) {
+	volumes := m.ListVolumes()
+
+	var eligible []types.RecordingVolume
+	for _, vol := range volumes {
+		if !vol.Full && vol.Error == "" {
+			eligible = append(eligible, vol)
+		}
+	}
+	if len(eligible) == 0 {
+		return "", ErrVolumesFull
+	}
+
+	if m.cfg.RecordingsPlacement == PlacementRoundRobin {
+		next := m.nextVolume.Add(1) - 1
+		return eligible[next%uint64(len(eligible))].Path, nil
+	}
+
+	// Most free: the disk space left, capped by what the quota still allows
+	best, bestSpace := eligible[0].Path, int64(-1)
+	for _, vol := range eligible {
+		space := vol.Free
+		if space < 0 {
+			space = math.MaxInt64
+		}
+		if vol.Quota > 0 {
+			space = min(space, vol.Quota-vol.Used)
+		}
+		if space > bestSpace {
+			best, bestSpace = vol.Path, space
+		}
+	}
+	return best, nil
+}
+
+// volumeUsage sums the size and number of recordings on each volume. Sizes
+// of active recordings are read from disk.
+func (m *RecordingManager) volumeUsage() (map[string]int64, map[string]int) {
+	used := make(map[string]int64)
+	counts := make(map[string]int)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, state := range m.recordings {
+		state.mu.Lock()
+		rec := state.recording
+		volume, size := rec.Volume, rec.FileSize
+		localDeleted := rec.Upload != nil && rec.Upload.LocalDeleted
+		if rec.Status == string(types.RecordingStatusRecording) {
+			size = m.fileSize(rec.FilePath)
+		}
+		state.mu.Unlock()
+
+		if volume == "" || localDeleted {
+			continue
+		}
+		used[volume] += size
+		counts[volume]++
+	}
+	return used, counts
+}
+
+// volumeFor returns the volume holding path, or "" if none does.
+func (m *RecordingManager) volumeFor(path string) string {
+	for _, vol := range m.volumes {
+		rel, err := filepath.Rel(vol.Path, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return vol.Path
+		}
+	}
+	return ""
+}
+
+// createVolumes creates the volume directories.
+func createVolumes(volumes []config.RecordingVolume) error {
+	for _, vol := range volumes {
+		if err := os.MkdirAll(vol.Path, 0755); err != nil {
+			return fmt.Errorf("failed to create recordings volume %s: %w", vol.Path, err)
+		}
+	}
+	return nil
+}
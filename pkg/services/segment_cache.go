@@ -0,0 +1,194 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"media-proxy-go/pkg/diskcache"
+	"media-proxy-go/pkg/types"
+)
+
+// SetSegmentCache enables the disk cache for VOD segments.
+func (s *ProxyService) SetSegmentCache(c *diskcache.Cache) {
+	s.segmentCache = c
+}
+
+// SegmentCache returns the VOD segment cache, or nil if it is disabled.
+func (s *ProxyService) SegmentCache() *diskcache.Cache {
+	return s.segmentCache
+}
+
+// credentialHeaders are the upstream request headers that can make a segment
+// response specific to one user.
+var credentialHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// segmentCacheKey identifies a segment response: the URL, the requested range
+// and a hash of the credentials sent upstream, so a segment fetched with one
+// user's cookies or token is never served to requests without them.
+func segmentCacheKey(urlStr string, headers map[string]string) string {
+	var rangeHeader string
+	credentials := sha256.New()
+	hasCredentials := false
+	for name, value := range headers {
+		if strings.EqualFold(name, "Range") {
+			rangeHeader = value
+		}
+	}
+	for _, header := range credentialHeaders {
+		for name, value := range headers {
+			if strings.EqualFold(name, header) && value != "" {
+				fmt.Fprintf(credentials, "%s=%s\n", header, value)
+				hasCredentials = true
+			}
+		}
+	}
+	key := urlStr + "|" + rangeHeader
+	if hasCredentials {
+		key += "|" + hex.EncodeToString(credentials.Sum(nil))
+	}
+	return key
+}
+
+// cachedSegment returns the cached response for key, if any.
+func (s *ProxyService) cachedSegment(key string) (*types.StreamResponse, bool) {
+	entry, body, ok := s.segmentCache.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	headers := maps.Clone(entry.Headers)
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	headers["Content-Length"] = strconv.FormatInt(entry.Size, 10)
+	headers["X-Cache"] = "HIT"
+
+	s.log.Debug("segment cache hit", "key", key)
+	return &types.StreamResponse{
+		ContentType: entry.ContentType,
+		Headers:     headers,
+		Body:        body,
+		StatusCode:  entry.StatusCode,
+	}, true
+}
+
+// cacheSegment stores resp's body in the segment cache as it is read, if
+// the segment is cacheable: VOD segments and responses marked immutable,
+// unless upstream forbids caching. Upstream max-age and Expires bound how
+// long the entry is served.
+func (s *ProxyService) cacheSegment(key string, vod bool, resp *types.StreamResponse) *types.StreamResponse {
+	if resp.RedirectURL != "" || resp.Body == nil ||
+		(resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent) {
+		return resp
+	}
+
+	expires, ok := cacheExpiry(resp.Headers, time.Now())
+	if !ok {
+		return resp
+	}
+	if !vod && !strings.Contains(strings.ToLower(resp.Headers["Cache-Control"]), "immutable") {
+		return resp
+	}
+
+	size := int64(-1)
+	if v, err := strconv.ParseInt(resp.Headers["Content-Length"], 10, 64); err == nil {
+		size = v
+	}
+
+	entry := diskcache.Entry{
+		Key:         key,
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.ContentType,
+		Headers:     maps.Clone(resp.Headers),
+		Expires:     expires,
+	}
+	delete(entry.Headers, "Content-Length")
+	delete(entry.Headers, "Age") // Upstream's age at fetch time, stale once cached
+
+	resp.Body = &cachingBody{ReadCloser: resp.Body, w: s.segmentCache.Writer(entry, size), key: key, s: s}
+	if resp.Headers == nil {
+		resp.Headers = make(map[string]string)
+	}
+	resp.Headers["X-Cache"] = "MISS"
+	return resp
+}
+
+// cacheExpiry returns the Unix time a response may be cached until (0 for
+// no limit) from its Cache-Control and Expires headers, or false if it must
+// not be cached.
+func cacheExpiry(headers map[string]string, now time.Time) (int64, bool) {
+	maxAge := -1
+	for _, directive := range strings.Split(strings.ToLower(headers["Cache-Control"]), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch name {
+		case "no-store", "no-cache", "private":
+			return 0, false
+		case "max-age":
+			secs, err := strconv.Atoi(strings.Trim(value, `"`))
+			if err != nil {
+				return 0, false
+			}
+			maxAge = secs
+		}
+	}
+
+	switch {
+	case maxAge == 0:
+		return 0, false
+	case maxAge > 0:
+		return now.Add(time.Duration(maxAge) * time.Second).Unix(), true
+	}
+
+	if v := headers["Expires"]; v != "" {
+		t, err := http.ParseTime(v)
+		if err != nil || !t.After(now) {
+			return 0, false
+		}
+		return t.Unix(), true
+	}
+	return 0, true
+}
+
+// cachingBody copies a segment body into the cache as the client reads it.
+// The entry is committed only if the whole body was read.
+type cachingBody struct {
+	io.ReadCloser
+	w    *diskcache.Writer
+	key  string
+	s    *ProxyService
+	done bool
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.w.Write(p[:n])
+	}
+	if err != nil && !b.done {
+		b.done = true
+		if errors.Is(err, io.EOF) {
+			if cerr := b.w.Commit(); cerr != nil {
+				b.s.log.Debug("segment not cached", "key", b.key, "reason", cerr)
+			}
+		} else {
+			b.w.Abort()
+		}
+	}
+	return n, err
+}
+
+func (b *cachingBody) Close() error {
+	if !b.done {
+		b.done = true
+		b.w.Abort()
+	}
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/diskcache"
+	"media-proxy-go/pkg/handlers/streams"
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/registry"
+	"media-proxy-go/pkg/types"
+)
+
+func TestProxyService_SegmentCache(t *testing.T) {
+	var requests atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/no-store.ts":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/immutable.ts":
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+		if r.Header.Get("Range") != "" {
+			w.Header().Set("Content-Range", "bytes 0-3/12")
+			w.WriteHeader(http.StatusPartialContent)
+			io.WriteString(w, "segm")
+			return
+		}
+		io.WriteString(w, "segment-data")
+	}))
+	defer upstream.Close()
+
+	log := logging.New("error", false, nil)
+	client := httpclient.New(&config.Config{}, log)
+	streamHandlers := registry.NewStreamHandlerRegistry()
+	streamHandlers.SetFallback(streams.NewGenericHandler(client, log))
+	s := NewProxyService(log, streamHandlers, registry.NewExtractorRegistry(), "http://proxy")
+	cache := diskcache.New(t.TempDir(), 1<<20, 1<<20, log)
+	if err := cache.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	s.SetSegmentCache(cache)
+
+	fetch := func(path, rangeHeader string, vod bool) (string, *types.StreamResponse) {
+		t.Helper()
+		headers := map[string]string{}
+		if rangeHeader != "" {
+			headers["Range"] = rangeHeader
+		}
+		resp, err := s.HandleSegment(t.Context(), &types.StreamRequest{URL: upstream.URL + path, Headers: headers, VOD: vod})
+		if err != nil {
+			t.Fatalf("HandleSegment(%s) error = %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		rangeHeader  string
+		vod          bool
+		wantRequests int32 // Upstream requests for two fetches
+	}{
+		{"vod segment", "/seg1.ts", "", true, 1},
+		{"vod byte range", "/seg1.ts", "bytes=0-3", true, 1},
+		{"live segment", "/live.ts", "", false, 2},
+		{"immutable segment", "/immutable.ts", "", false, 1},
+		{"no-store", "/no-store.ts", "", true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := requests.Load()
+			first, _ := fetch(tt.path, tt.rangeHeader, tt.vod)
+			second, resp := fetch(tt.path, tt.rangeHeader, tt.vod)
+
+			if first != second {
+				t.Errorf("cached body = %q, want %q", second, first)
+			}
+			if got := requests.Load() - before; got != tt.wantRequests {
+				t.Errorf("upstream requests = %d, want %d", got, tt.wantRequests)
+			}
+			if tt.wantRequests == 1 {
+				if resp.Headers["X-Cache"] != "HIT" || resp.Headers["Content-Length"] != strconv.Itoa(len(first)) {
+					t.Errorf("headers = %v", resp.Headers)
+				}
+				if tt.rangeHeader != "" && (resp.StatusCode != http.StatusPartialContent || resp.Headers["Content-Range"] != "bytes 0-3/12") {
+					t.Errorf("range response = %d %v", resp.StatusCode, resp.Headers)
+				}
+			}
+		})
+	}
+}
+
+func TestSegmentCacheKey(t *testing.T) {
+	const u = "https://cdn.example.com/seg1.ts"
+	plain := segmentCacheKey(u, map[string]string{"Referer": "https://site/"})
+	if plain != u+"|" {
+		t.Errorf("key without credentials = %q", plain)
+	}
+	if got := segmentCacheKey(u, map[string]string{"range": "bytes=0-3"}); got != u+"|bytes=0-3" {
+		t.Errorf("key with a range = %q", got)
+	}
+
+	alice := segmentCacheKey(u, map[string]string{"Cookie": "session=alice"})
+	bob := segmentCacheKey(u, map[string]string{"cookie": "session=bob"})
+	token := segmentCacheKey(u, map[string]string{"Authorization": "Bearer alice"})
+	for name, key := range map[string]string{"alice": alice, "bob": bob, "token": token} {
+		if key == plain || strings.Contains(key, "alice") || strings.Contains(key, "bob") {
+			t.Errorf("%s key = %q, want a hash of the credentials", name, key)
+		}
+	}
+	if alice == bob || alice == token {
+		t.Error("requests with different credentials share a cache key")
+	}
+	if again := segmentCacheKey(u, map[string]string{"cookie": "session=alice"}); again != alice {
+		t.Errorf("key of the same credentials = %q, want %q", again, alice)
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int64
+		ok      bool
+	}{
+		{"no headers", nil, 0, true},
+		{"max-age", map[string]string{"Cache-Control": "public, max-age=60"}, now.Unix() + 60, true},
+		{"max-age=0", map[string]string{"Cache-Control": "max-age=0"}, 0, false},
+		{"no-cache", map[string]string{"Cache-Control": "no-cache"}, 0, false},
+		{"private", map[string]string{"Cache-Control": "max-age=60, private"}, 0, false},
+		{"expires", map[string]string{"Expires": now.Add(time.Hour).Format(http.TimeFormat)}, now.Unix() + 3600, true},
+		{"expired", map[string]string{"Expires": now.Add(-time.Hour).Format(http.TimeFormat)}, 0, false},
+		{"max-age wins", map[string]string{"Cache-Control": "max-age=10", "Expires": now.Add(-time.Hour).Format(http.TimeFormat)}, now.Unix() + 10, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := cacheExpiry(tt.headers, now)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("cacheExpiry() = %d, %v, want %d, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
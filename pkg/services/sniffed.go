@@ -0,0 +1,40 @@
+package services
+
+import (
+	"sync"
+
+	"media-proxy-go/pkg/types"
+)
+
+// maxSniffedURLs bounds the URL -> sniffed manifest type map.
+const maxSniffedURLs = 4096
+
+// sniffedTypes remembers extension-less URLs the generic handler found to be
+// manifests, so later requests (live playlists are reloaded every few
+// seconds) go straight to the right handler instead of fetching twice.
+type sniffedTypes struct {
+	mu    sync.RWMutex
+	types map[string]types.StreamType
+}
+
+func newSniffedTypes() *sniffedTypes {
+	return &sniffedTypes{types: make(map[string]types.StreamType)}
+}
+
+// get returns the manifest type sniffed for urlStr, if any.
+func (s *sniffedTypes) get(urlStr string) (types.StreamType, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.types[urlStr]
+	return t, ok
+}
+
+// set remembers the manifest type sniffed for urlStr.
+func (s *sniffedTypes) set(urlStr string, t types.StreamType) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.types) >= maxSniffedURLs {
+		s.types = make(map[string]types.StreamType)
+	}
+	s.types[urlStr] = t
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/handlers/streams"
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/registry"
+	"media-proxy-go/pkg/types"
+)
+
+func TestProxyService_SniffsExtensionlessManifest(t *testing.T) {
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		switch r.URL.Path {
+		case "/play":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("#EXTM3U\n#EXTINF:2,\nseg1.ts\n"))
+		case "/dash":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte(`<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static" mediaPresentationDuration="PT10S">
+  <Period>
+    <AdaptationSet mimeType="video/mp4">
+      <Representation id="v1" bandwidth="1000000" width="1280" height="720" codecs="avc1.64001f">
+        <SegmentTemplate media="v1_$Number$.m4s" initialization="v1_init.mp4" duration="2" timescale="1"/>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`))
+		default:
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte("raw bytes"))
+		}
+	}))
+	defer server.Close()
+
+	log := logging.New("error", false, nil)
+	client := httpclient.New(&config.Config{}, log)
+	handlers := registry.NewStreamHandlerRegistry()
+	handlers.Register(streams.NewHLSHandler(client, log, "http://proxy"))
+	handlers.Register(streams.NewMPDHandler(client, log, "http://proxy", nil))
+	handlers.SetFallback(streams.NewGenericHandler(client, log))
+	s := NewProxyService(log, handlers, registry.NewExtractorRegistry(), "http://proxy")
+
+	manifest := func(path string) string {
+		t.Helper()
+		resp, err := s.HandleManifest(context.Background(), &types.StreamRequest{URL: server.URL + path})
+		if err != nil {
+			t.Fatalf("HandleManifest(%s) error = %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	// The sniffed manifest is rewritten as fetched, without fetching it again
+	if body := manifest("/play"); !strings.Contains(body, "seg1.ts") || !strings.Contains(body, "http://proxy/") {
+		t.Errorf("extension-less playlist not rewritten:\n%s", body)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("sniffing fetched the playlist %d times, want 1", n)
+	}
+	fetches.Store(0)
+	if body := manifest("/dash"); !strings.Contains(body, "#EXT-X-STREAM-INF") {
+		t.Errorf("extension-less MPD not converted:\n%s", body)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("sniffing fetched the MPD %d times, want 1", n)
+	}
+	if _, streamType := s.StreamInfo(server.URL + "/play"); streamType != types.StreamTypeHLS {
+		t.Errorf("StreamInfo() type = %s, want %s", streamType, types.StreamTypeHLS)
+	}
+
+	// Reloads go straight to the HLS handler
+	fetches.Store(0)
+	manifest("/play")
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("reload fetched the playlist %d times, want 1", n)
+	}
+
+	if body := manifest("/file"); body != "raw bytes" {
+		t.Errorf("other content = %q, want it streamed raw", body)
+	}
+}
@@ -0,0 +1,377 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/types"
+)
+
+const (
+	// sourceIdleTTL is how long an extracted stream is remembered without requests.
+	sourceIdleTTL = 2 * time.Hour
+	// minReextractInterval stops a burst of expired requests from re-running
+	// the extractor more than once; later requests reuse the fresh result.
+	minReextractInterval = 10 * time.Second
+	// maxMovedURLs bounds the expired URL -> replacement map.
+	maxMovedURLs = 4096
+	// tokenRefreshMargin is how long before its token expires a stream is
+	// re-extracted, so players never see the expired token.
+	tokenRefreshMargin = 30 * time.Second
+	// maxSourceReuse is how long reloads of a source URL reuse its extraction
+	// when the token's expiry is unknown. Tokens rejected upstream are
+	// re-extracted sooner.
+	maxSourceReuse = 5 * time.Minute
+)
+
+// extractedSource remembers the page an extracted stream URL came from, so the
+// stream can be re-extracted when its token expires.
+type extractedSource struct {
+	mu            sync.Mutex // Serializes re-extraction
+	extractor     interfaces.Extractor
+	sourceURL     string
+	headers       map[string]string // Headers the extraction was requested with
+	destination   string
+	previous      string            // Destination before the last re-extraction
+	streamHeaders map[string]string // Headers returned by the last extraction
+	expiresAt     time.Time         // When the destination's token expires, zero if unknown
+	extractedAt   time.Time         // Last extraction, first or re-extraction
+	refreshedAt   time.Time
+	failedAt      time.Time // Last failed re-extraction
+	lastUsed      time.Time
+}
+
+// sourceTracker maps extracted stream URLs back to their sources.
+type sourceTracker struct {
+	mu     sync.Mutex
+	byDest map[string]*extractedSource // By destination URL
+	byDir  map[string]*extractedSource // By destination host and directory, for variants and segments
+	bySrc  map[string]*extractedSource // By source URL and request headers
+	moved  map[string]string           // Expired URL -> its re-extracted replacement
+}
+
+func newSourceTracker() *sourceTracker {
+	return &sourceTracker{
+		byDest: make(map[string]*extractedSource),
+		byDir:  make(map[string]*extractedSource),
+		bySrc:  make(map[string]*extractedSource),
+		moved:  make(map[string]string),
+	}
+}
+
+// record remembers that result was extracted from sourceURL.
+func (t *sourceTracker) record(extractor interfaces.Extractor, sourceURL string, headers map[string]string, result *types.ExtractResult) {
+	now := time.Now()
+	copied := make(map[string]string, len(headers))
+	for k, v := range headers {
+		copied[k] = v
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(now)
+	src := &extractedSource{
+		extractor:     extractor,
+		sourceURL:     sourceURL,
+		headers:       copied,
+		destination:   result.DestinationURL,
+		streamHeaders: result.RequestHeaders,
+		expiresAt:     expiryOf(result),
+		extractedAt:   now,
+		lastUsed:      now,
+	}
+	t.byDest[src.destination] = src
+	t.byDir[urlDir(src.destination)] = src
+	t.bySrc[sourceKey(sourceURL, headers)] = src
+}
+
+// current returns the destination and stream headers last extracted from
+// sourceURL with headers, false if there is none or its token is about to
+// expire. Live playlists reloaded from their source page (as recordings do)
+// are thus extracted once per token instead of on every reload.
+func (t *sourceTracker) current(sourceURL string, headers map[string]string) (string, map[string]string, bool) {
+	t.mu.Lock()
+	src := t.bySrc[sourceKey(sourceURL, headers)]
+	if src != nil {
+		src.lastUsed = time.Now()
+	}
+	t.mu.Unlock()
+	if src == nil {
+		return "", nil, false
+	}
+
+	// Waits for a re-extraction in progress
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	now := time.Now()
+	if src.expiresAt.IsZero() {
+		if now.Sub(src.extractedAt) >= maxSourceReuse {
+			return "", nil, false
+		}
+	} else if now.Add(tokenRefreshMargin).After(src.expiresAt) {
+		return "", nil, false
+	}
+	return src.destination, src.streamHeaders, true
+}
+
+// sourceKey identifies the extraction of sourceURL with headers.
+func sourceKey(sourceURL string, headers map[string]string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var b strings.Builder
+	b.WriteString(sourceURL)
+	for _, name := range names {
+		b.WriteString("\n" + name + ": " + headers[name])
+	}
+	return b.String()
+}
+
+// expiryOf returns when an extraction result's token expires, zero if unknown.
+func expiryOf(result *types.ExtractResult) time.Time {
+	if result.ExpiresAt <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(result.ExpiresAt, 0)
+}
+
+// expiresSoon reports whether the source's token expires within
+// tokenRefreshMargin and no refresh failed just now.
+func (src *extractedSource) expiresSoon(now time.Time) bool {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	if src.expiresAt.IsZero() || now.Sub(src.failedAt) < minReextractInterval {
+		return false
+	}
+	return now.Add(tokenRefreshMargin).After(src.expiresAt)
+}
+
+// resolve returns the current URL for urlStr, following earlier re-extractions.
+func (t *sourceTracker) resolve(urlStr string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if moved, ok := t.moved[urlStr]; ok {
+		return moved
+	}
+	return urlStr
+}
+
+// lookup returns the source of an extracted URL, or of a variant or segment next to it.
+func (t *sourceTracker) lookup(urlStr string) *extractedSource {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	src := t.byDest[urlStr]
+	if src == nil {
+		// Variants and segments live next to or below the extracted URL
+		u, err := url.Parse(urlStr)
+		if err != nil {
+			return nil
+		}
+		for dir := path.Dir(u.Path); src == nil; dir = path.Dir(dir) {
+			src = t.byDir[u.Host+dir]
+			if dir == "/" || dir == "." {
+				break
+			}
+		}
+	}
+	if src != nil {
+		src.lastUsed = time.Now()
+	}
+	return src
+}
+
+// update records a re-extraction of src that replaced oldURL with newURL.
+func (t *sourceTracker) update(src *extractedSource, oldURL, newURL string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.moved) >= maxMovedURLs {
+		t.moved = make(map[string]string)
+	}
+
+	t.byDest[src.destination] = src
+	t.byDir[urlDir(src.destination)] = src
+	t.moved[oldURL] = newURL
+	t.moved[src.previous] = src.destination
+	// Requests that were already redirected to oldURL follow it to newURL
+	for from, to := range t.moved {
+		if to == oldURL {
+			t.moved[from] = newURL
+		}
+	}
+}
+
+// prune forgets sources without recent requests. Caller must hold t.mu.
+func (t *sourceTracker) prune(now time.Time) {
+	cutoff := now.Add(-sourceIdleTTL)
+	alive := make(map[*extractedSource]bool)
+	for key, src := range t.byDest {
+		if src.lastUsed.Before(cutoff) {
+			delete(t.byDest, key)
+			continue
+		}
+		alive[src] = true
+	}
+	for key, src := range t.byDir {
+		if !alive[src] {
+			delete(t.byDir, key)
+		}
+	}
+	for key, src := range t.bySrc {
+		if !alive[src] {
+			delete(t.bySrc, key)
+		}
+	}
+	if len(alive) == 0 {
+		t.moved = make(map[string]string)
+	}
+}
+
+// isExpiredStatus reports whether an upstream status means the stream token expired.
+func isExpiredStatus(status int) bool {
+	return status == http.StatusForbidden || status == http.StatusGone
+}
+
+// refreshExpiring re-extracts req's stream shortly before its token expires
+// and points req at the fresh URL, so playlists and segments requested with the
+// old token are rewritten instead of failing. Streams without a known expiry
+// are only re-extracted once upstream rejects them.
+func (s *ProxyService) refreshExpiring(ctx context.Context, req *types.StreamRequest) {
+	src := s.sources.lookup(req.URL)
+	if src == nil || !src.expiresSoon(time.Now()) {
+		return
+	}
+	s.log.Debug("stream token expiring, refreshing", "source", src.sourceURL)
+	s.reextract(ctx, req)
+}
+
+// reextract re-runs the extractor for an expired stream URL and points req at
+// the fresh URL. It returns false if req.URL was not extracted or the
+// re-extraction failed.
+func (s *ProxyService) reextract(ctx context.Context, req *types.StreamRequest) bool {
+	src := s.sources.lookup(req.URL)
+	if src == nil {
+		return false
+	}
+
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	// Requests arriving right after a re-extraction reuse its result
+	if time.Since(src.refreshedAt) >= minReextractInterval {
+		s.log.Info("stream token expired, re-extracting", "source", src.sourceURL, "extractor", src.extractor.Name())
+
+		result, err := s.extract(ctx, src.extractor, src.sourceURL, interfaces.ExtractOptions{
+			Headers:      src.headers,
+			ForceRefresh: true,
+		})
+		if err != nil {
+			s.log.Warn("re-extraction failed", "source", src.sourceURL, "error", err)
+			src.failedAt = time.Now()
+			s.notifyExtractorFailed(src.extractor.Name(), src.sourceURL, err)
+			return false
+		}
+
+		src.previous = src.destination
+		src.destination = result.DestinationURL
+		src.streamHeaders = result.RequestHeaders
+		src.expiresAt = expiryOf(result)
+		src.extractedAt = time.Now()
+		src.refreshedAt = src.extractedAt
+	}
+
+	if len(src.streamHeaders) > 0 && req.Headers == nil {
+		req.Headers = make(map[string]string)
+	}
+	for k, v := range src.streamHeaders {
+		req.Headers[k] = v
+	}
+
+	newURL := remapURL(req.URL, src.previous, src.destination)
+	if newURL == "" || newURL == req.URL {
+		return false
+	}
+
+	s.log.Debug("retrying with re-extracted URL", "old", req.URL, "new", newURL)
+	s.sources.update(src, req.URL, newURL)
+	req.URL = newURL
+	return true
+}
+
+// closeBody releases the body of a response that is being retried.
+func closeBody(resp *types.StreamResponse) {
+	if resp != nil && resp.Body != nil {
+		resp.Body.Close()
+	}
+}
+
+// remapURL maps urlStr, extracted as (or relative to) oldDest, onto newDest.
+// Variants and segments keep their path relative to the destination; a query
+// identical to the old destination's (usually the token) is replaced with the
+// new one, as are single parameters carrying the old destination's values.
+func remapURL(urlStr, oldDest, newDest string) string {
+	if urlStr == oldDest {
+		return newDest
+	}
+
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return ""
+	}
+	oldU, err := url.Parse(oldDest)
+	if err != nil || oldU.Host != u.Host {
+		return ""
+	}
+	newU, err := url.Parse(newDest)
+	if err != nil {
+		return ""
+	}
+
+	oldDir := path.Dir(oldU.Path)
+	if oldDir != "/" && !strings.HasPrefix(u.Path, oldDir+"/") {
+		return ""
+	}
+
+	remapped := *newU
+	remapped.Path = path.Join(path.Dir(newU.Path), strings.TrimPrefix(u.Path, oldDir))
+	remapped.RawPath = ""
+	if u.RawQuery != oldU.RawQuery {
+		remapped.RawQuery = remapQuery(u.Query(), oldU.Query(), newU.Query()).Encode()
+		if remapped.RawQuery == u.Query().Encode() {
+			remapped.RawQuery = u.RawQuery // Keep the original encoding
+		}
+	}
+	return remapped.String()
+}
+
+// remapQuery replaces the parameters of query that carry oldQuery's values
+// (e.g. a token=... copied into segment URLs) with newQuery's.
+func remapQuery(query, oldQuery, newQuery url.Values) url.Values {
+	for name, values := range query {
+		if len(values) == 1 && oldQuery.Get(name) == values[0] && newQuery.Has(name) {
+			query.Set(name, newQuery.Get(name))
+		}
+	}
+	return query
+}
+
+// urlDir returns the host and directory of urlStr.
+func urlDir(urlStr string) string {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return urlStr
+	}
+	return u.Host + path.Dir(u.Path)
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/handlers/streams"
+	"media-proxy-go/pkg/httpclient"
+	"media-proxy-go/pkg/interfaces"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/registry"
+	"media-proxy-go/pkg/types"
+)
+
+func TestRemapURL(t *testing.T) {
+	oldDest := "https://cdn.example.com/live/abc/master.m3u8?token=1"
+	newDest := "https://cdn.example.com/live/def/master.m3u8?token=2"
+
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+	}{
+		{"destination", oldDest, newDest},
+		{"variant with token", "https://cdn.example.com/live/abc/720p/index.m3u8?token=1", "https://cdn.example.com/live/def/720p/index.m3u8?token=2"},
+		{"segment with own query", "https://cdn.example.com/live/abc/seg1.ts?n=1", "https://cdn.example.com/live/def/seg1.ts?n=1"},
+		{"segment with token and own query", "https://cdn.example.com/live/abc/seg1.ts?n=1&token=1", "https://cdn.example.com/live/def/seg1.ts?n=1&token=2"},
+		{"other host", "https://other.example.com/live/abc/seg1.ts", ""},
+		{"outside directory", "https://cdn.example.com/vod/x.ts", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := remapURL(tt.url, oldDest, newDest); got != tt.expected {
+				t.Errorf("remapURL() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// rotatingExtractor hands out a new stream token on every extraction.
+type rotatingExtractor struct {
+	server string
+	ttl    time.Duration // Reported token lifetime, 0 if unknown
+	token  atomic.Int32
+}
+
+func (e *rotatingExtractor) Name() string { return "rotating" }
+
+func (e *rotatingExtractor) CanExtract(u string) bool { return strings.Contains(u, "rotating.example") }
+
+func (e *rotatingExtractor) Extract(ctx context.Context, u string, opts interfaces.ExtractOptions) (*types.ExtractResult, error) {
+	token := e.token.Add(1)
+	result := &types.ExtractResult{DestinationURL: fmt.Sprintf("%s/live/%d/index.m3u8", e.server, token)}
+	if e.ttl > 0 {
+		result.ExpiresAt = time.Now().Add(e.ttl).Unix()
+	}
+	return result, nil
+}
+
+func (e *rotatingExtractor) Close() error { return nil }
+
+func TestProxyService_ReextractsExpiredStream(t *testing.T) {
+	log := logging.New("error", false, nil)
+	extractor := &rotatingExtractor{}
+
+	// Only the latest token is valid
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := fmt.Sprintf("/live/%d/", extractor.token.Load())
+		if !strings.HasPrefix(r.URL.Path, current) {
+			http.Error(w, "token expired", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("#EXTM3U\n#EXTINF:2,\nseg1.ts\n"))
+	}))
+	defer server.Close()
+	extractor.server = server.URL
+
+	handlers := registry.NewStreamHandlerRegistry()
+	handlers.Register(streams.NewHLSHandler(httpclient.New(&config.Config{}, log), log, "http://proxy"))
+	extractors := registry.NewExtractorRegistry()
+	extractors.Register(extractor)
+	s := NewProxyService(log, handlers, extractors, "http://proxy")
+
+	// The player starts from the source page and keeps reloading the extracted playlist
+	resp, err := s.HandleManifest(context.Background(), &types.StreamRequest{URL: "https://rotating.example/watch/1"})
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("HandleManifest(source) = %v, %v", resp, err)
+	}
+	expired := server.URL + "/live/1/index.m3u8"
+
+	extractor.token.Add(1) // Token 1 expires upstream
+
+	resp, err = s.HandleManifest(context.Background(), &types.StreamRequest{URL: expired})
+	if err != nil {
+		t.Fatalf("HandleManifest(expired) error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("HandleManifest(expired) status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "live%2F3%2Fseg1.ts") {
+		t.Errorf("manifest not rewritten against re-extracted URL:\n%s", body)
+	}
+
+	// Later reloads of the expired URL go straight to the replacement
+	calls := extractor.token.Load()
+	resp, err = s.HandleManifest(context.Background(), &types.StreamRequest{URL: expired})
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("HandleManifest(reload) = %v, %v", resp, err)
+	}
+	if extractor.token.Load() != calls {
+		t.Error("reload of a re-extracted URL ran the extractor again")
+	}
+
+	// Unknown URLs still surface the upstream error
+	resp, err = s.HandleManifest(context.Background(), &types.StreamRequest{URL: server.URL + "/other/index.m3u8"})
+	if err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("HandleManifest(unknown) = %v, %v, want status %d", resp, err, http.StatusForbidden)
+	}
+}
+
+func TestProxyService_ReusesExtractionOnReload(t *testing.T) {
+	log := logging.New("error", false, nil)
+	extractor := &rotatingExtractor{}
+
+	// Only the latest token is valid
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := fmt.Sprintf("/live/%d/", extractor.token.Load())
+		if !strings.HasPrefix(r.URL.Path, current) {
+			http.Error(w, "token expired", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("#EXTM3U\n#EXTINF:2,\nseg1.ts\n"))
+	}))
+	defer server.Close()
+	extractor.server = server.URL
+
+	handlers := registry.NewStreamHandlerRegistry()
+	handlers.Register(streams.NewHLSHandler(httpclient.New(&config.Config{}, log), log, "http://proxy"))
+	extractors := registry.NewExtractorRegistry()
+	extractors.Register(extractor)
+	s := NewProxyService(log, handlers, extractors, "http://proxy")
+
+	// A recorder keeps reloading the source page's playlist
+	reload := func() *types.StreamRequest {
+		t.Helper()
+		req := &types.StreamRequest{URL: "https://rotating.example/watch/1"}
+		resp, err := s.HandleManifest(context.Background(), req)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("HandleManifest(source) = %v, %v", resp, err)
+		}
+		return req
+	}
+	reload()
+	if req := reload(); req.URL != server.URL+"/live/1/index.m3u8" || extractor.token.Load() != 1 {
+		t.Errorf("reload went to %q after %d extractions, want the first one reused", req.URL, extractor.token.Load())
+	}
+
+	// Once upstream rejects the token, the source is extracted again
+	extractor.token.Add(1)
+	if req := reload(); req.URL != server.URL+"/live/3/index.m3u8" {
+		t.Errorf("reload of an expired token went to %q, want the re-extracted URL", req.URL)
+	}
+	reload()
+	if n := extractor.token.Load(); n != 3 {
+		t.Errorf("%d extractions, want 3", n)
+	}
+
+	// Tokens about to expire are never reused
+	extractor.ttl = tokenRefreshMargin / 2
+	s = NewProxyService(log, handlers, extractors, "http://proxy")
+	for range 2 {
+		reload()
+	}
+	if n := extractor.token.Load(); n < 5 {
+		t.Errorf("%d extractions after reloading an expiring token twice, want it extracted each time", n)
+	}
+}
+
+func TestProxyService_RefreshesExpiringToken(t *testing.T) {
+	log := logging.New("error", false, nil)
+	// The token expires within the refresh margin, so every stale request refreshes it first
+	extractor := &rotatingExtractor{ttl: tokenRefreshMargin / 2}
+
+	var rejected atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := fmt.Sprintf("/live/%d/", extractor.token.Load())
+		if !strings.HasPrefix(r.URL.Path, current) {
+			rejected.Add(1)
+			http.Error(w, "token expired", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("#EXTM3U\n#EXTINF:2,\nseg1.ts\n"))
+	}))
+	defer server.Close()
+	extractor.server = server.URL
+
+	handlers := registry.NewStreamHandlerRegistry()
+	handlers.Register(streams.NewHLSHandler(httpclient.New(&config.Config{}, log), log, "http://proxy"))
+	extractors := registry.NewExtractorRegistry()
+	extractors.Register(extractor)
+	s := NewProxyService(log, handlers, extractors, "http://proxy")
+
+	resp, err := s.HandleManifest(context.Background(), &types.StreamRequest{URL: "https://rotating.example/watch/1"})
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("HandleManifest(source) = %v, %v", resp, err)
+	}
+
+	// The player reloads the extracted playlist: the token is refreshed before fetching
+	req := &types.StreamRequest{URL: server.URL + "/live/1/index.m3u8"}
+	resp, err = s.HandleManifest(context.Background(), req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("HandleManifest(reload) = %v, %v", resp, err)
+	}
+	if req.URL != server.URL+"/live/2/index.m3u8" {
+		t.Errorf("request URL = %q, want the refreshed destination", req.URL)
+	}
+	if n := rejected.Load(); n != 0 {
+		t.Errorf("upstream rejected %d requests with the old token, want 0", n)
+	}
+}
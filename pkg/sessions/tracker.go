@@ -0,0 +1,273 @@
+// Package sessions tracks active playback sessions through the proxy so
+// operators of shared instances can see who is streaming what and stop it.
+//
+// A session is keyed by client IP and upstream host, so a master playlist and
+// the variant playlists it references (usually on the same CDN) form one session.
+// Follow-up requests (segments, keys) are attributed to the client's most
+// recently active session, whatever host they are fetched from.
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"media-proxy-go/pkg/middleware"
+	"media-proxy-go/pkg/types"
+)
+
+// bandwidthWindow is how often a session's bandwidth figure is recomputed.
+const bandwidthWindow = 5 * time.Second
+
+// Tracker records active playback sessions.
+type Tracker struct {
+	idleTimeout time.Duration
+	trusted     []netip.Prefix // Proxies whose forwarding headers name the client
+	now         func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*session  // By session ID
+	byKey    map[string]*session  // By client IP + upstream host
+	byClient map[string]*session  // Most recently active session per client IP
+	blocked  map[string]time.Time // Terminated session keys -> last rejected attempt
+	clients  map[string]time.Time // Client IPs with a terminated session -> last rejected attempt
+}
+
+// session is the mutable state behind a types.Session.
+type session struct {
+	info        types.Session
+	key         string
+	cancels     map[int]context.CancelFunc // In-flight requests
+	nextReq     int
+	windowStart time.Time
+	windowBytes int64
+}
+
+// NewTracker creates a tracker. Sessions without requests for idleTimeout
+// expire. Clients are identified as middleware.ClientIP does, trusting the
+// forwarding headers of the trusted proxies only.
+func NewTracker(idleTimeout time.Duration, trusted []netip.Prefix) *Tracker {
+	return &Tracker{
+		idleTimeout: idleTimeout,
+		trusted:     trusted,
+		now:         time.Now,
+		sessions:    make(map[string]*session),
+		byKey:       make(map[string]*session),
+		byClient:    make(map[string]*session),
+		blocked:     make(map[string]time.Time),
+		clients:     make(map[string]time.Time),
+	}
+}
+
+// Request is a single in-flight request attributed to a session.
+type Request struct {
+	tracker *Tracker
+	session *session
+	id      int
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// Begin attributes r to a session. entry marks manifest requests, which join or
+// start the session for their upstream host; other requests join the client's
+// current session, starting one only if there is none (e.g. a direct stream).
+// It returns false if the client's session was terminated and must be refused.
+func (t *Tracker) Begin(r *http.Request, entry bool, streamURL string, streamType types.StreamType) (*Request, bool) {
+	ip := middleware.ClientIP(r, t.trusted)
+	key := ip + "|" + host(streamURL)
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(now)
+
+	var s *session
+	if !entry {
+		s = t.byClient[ip]
+	}
+	if s == nil {
+		if t.isBlocked(t.blocked, key, now) || (!entry && t.isBlocked(t.clients, ip, now)) {
+			return nil, false
+		}
+		s = t.byKey[key]
+	}
+	if s == nil {
+		s = &session{
+			info: types.Session{
+				ID:        newID(),
+				ClientIP:  ip,
+				UserAgent: r.UserAgent(),
+				URL:       streamURL,
+				Type:      streamType,
+				StartedAt: now.Unix(),
+			},
+			key:         key,
+			cancels:     make(map[int]context.CancelFunc),
+			windowStart: now,
+		}
+		t.sessions[s.info.ID] = s
+		t.byKey[key] = s
+	}
+
+	s.info.LastSeen = now.Unix()
+	s.info.Requests++
+	t.byClient[ip] = s
+
+	ctx, cancel := context.WithCancel(r.Context())
+	s.nextReq++
+	s.cancels[s.nextReq] = cancel
+
+	return &Request{tracker: t, session: s, id: s.nextReq, ctx: ctx, cancel: cancel}, true
+}
+
+// Context returns the request context, cancelled when the session is terminated.
+func (req *Request) Context() context.Context {
+	return req.ctx
+}
+
+// StreamURL returns the URL the request's session started with.
+func (req *Request) StreamURL() string {
+	return req.session.info.URL
+}
+
+// StreamType returns the stream type of the request's session.
+func (req *Request) StreamType() types.StreamType {
+	return req.session.info.Type
+}
+
+// Add records n bytes sent to the client.
+func (req *Request) Add(n int) {
+	t := req.tracker
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := req.session
+	s.info.BytesSent += int64(n)
+	s.windowBytes += int64(n)
+	if elapsed := now.Sub(s.windowStart); elapsed >= bandwidthWindow {
+		s.info.Bandwidth = int64(float64(s.windowBytes*8) / elapsed.Seconds())
+		s.windowStart = now
+		s.windowBytes = 0
+	}
+}
+
+// End marks the request finished.
+func (req *Request) End() {
+	t := req.tracker
+
+	t.mu.Lock()
+	delete(req.session.cancels, req.id)
+	req.session.info.LastSeen = t.now().Unix()
+	t.mu.Unlock()
+
+	req.cancel()
+}
+
+// List returns active sessions, most recently started first.
+func (t *Tracker) List() []types.Session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(t.now())
+
+	result := make([]types.Session, 0, len(t.sessions))
+	for _, s := range t.sessions {
+		result = append(result, s.info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartedAt > result[j].StartedAt
+	})
+	return result
+}
+
+// Count returns the number of active sessions.
+func (t *Tracker) Count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(t.now())
+	return len(t.sessions)
+}
+
+// Terminate ends a session: in-flight requests are cancelled and the client is
+// refused for that stream until it stops retrying for the idle timeout.
+func (t *Tracker) Terminate(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.sessions[id]
+	if !ok {
+		return false
+	}
+
+	now := t.now()
+	t.blocked[s.key] = now
+	t.clients[s.info.ClientIP] = now
+	for _, cancel := range s.cancels {
+		cancel()
+	}
+	t.remove(s)
+	return true
+}
+
+// isBlocked reports whether key is blocked, extending the block on each attempt.
+// Caller must hold t.mu.
+func (t *Tracker) isBlocked(blocked map[string]time.Time, key string, now time.Time) bool {
+	if _, ok := blocked[key]; !ok {
+		return false
+	}
+	blocked[key] = now
+	return true
+}
+
+// prune removes idle sessions and expired blocks. Caller must hold t.mu.
+func (t *Tracker) prune(now time.Time) {
+	cutoff := now.Add(-t.idleTimeout).Unix()
+	for _, s := range t.sessions {
+		if len(s.cancels) == 0 && s.info.LastSeen < cutoff {
+			t.remove(s)
+		}
+	}
+
+	blockCutoff := now.Add(-t.idleTimeout)
+	for _, blocked := range []map[string]time.Time{t.blocked, t.clients} {
+		for key, last := range blocked {
+			if last.Before(blockCutoff) {
+				delete(blocked, key)
+			}
+		}
+	}
+}
+
+// remove drops a session from all indexes. Caller must hold t.mu.
+func (t *Tracker) remove(s *session) {
+	delete(t.sessions, s.info.ID)
+	delete(t.byKey, s.key)
+	if t.byClient[s.info.ClientIP] == s {
+		delete(t.byClient, s.info.ClientIP)
+	}
+}
+
+// host returns the host of rawURL, or rawURL itself if it cannot be parsed.
+func host(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return rawURL
+}
+
+// newID returns a random session ID.
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
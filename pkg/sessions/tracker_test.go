@@ -0,0 +1,122 @@
+package sessions
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/types"
+)
+
+func TestTracker_GroupsRequestsIntoSessions(t *testing.T) {
+	tracker := NewTracker(30*time.Second, []netip.Prefix{netip.MustParsePrefix("10.0.0.254/32")})
+
+	r := httptest.NewRequest("GET", "/proxy/manifest.m3u8", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+
+	// Master and variant playlist on the same host form one session
+	master, _ := tracker.Begin(r, true, "https://cdn.example.com/master.m3u8", types.StreamTypeHLS)
+	master.End()
+	variant, _ := tracker.Begin(r, true, "https://cdn.example.com/720p.m3u8", types.StreamTypeHLS)
+	variant.End()
+
+	// Segments from another host join the client's current session
+	segment, _ := tracker.Begin(r, false, "https://segments.example.net/1.ts", types.StreamTypeGeneric)
+	segment.Add(1000)
+	segment.End()
+
+	list := tracker.List()
+	if len(list) != 1 {
+		t.Fatalf("len(List()) = %d, want 1", len(list))
+	}
+	s := list[0]
+	if s.ClientIP != "10.0.0.1" || s.URL != "https://cdn.example.com/master.m3u8" || s.Type != types.StreamTypeHLS {
+		t.Errorf("session = %+v", s)
+	}
+	if s.Requests != 3 || s.BytesSent != 1000 {
+		t.Errorf("Requests = %d, BytesSent = %d, want 3, 1000", s.Requests, s.BytesSent)
+	}
+
+	// Another client, behind a trusted proxy, gets its own session
+	other := httptest.NewRequest("GET", "/proxy/manifest.m3u8", nil)
+	other.RemoteAddr = "10.0.0.254:5000"
+	other.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req, _ := tracker.Begin(other, true, "https://cdn.example.com/master.m3u8", types.StreamTypeHLS)
+	req.End()
+	if tracker.Count() != 2 {
+		t.Errorf("Count() = %d, want 2", tracker.Count())
+	}
+	if ip := req.session.info.ClientIP; ip != "203.0.113.7" {
+		t.Errorf("ClientIP behind a trusted proxy = %q, want 203.0.113.7", ip)
+	}
+}
+
+func TestTracker_Terminate(t *testing.T) {
+	tracker := NewTracker(30*time.Second, nil)
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+
+	r := httptest.NewRequest("GET", "/proxy/stream", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+
+	inFlight, ok := tracker.Begin(r, true, "https://cdn.example.com/live.m3u8", types.StreamTypeHLS)
+	if !ok {
+		t.Fatal("Begin() refused a new session")
+	}
+	id := tracker.List()[0].ID
+
+	if !tracker.Terminate(id) {
+		t.Fatal("Terminate() = false")
+	}
+	if inFlight.Context().Err() == nil {
+		t.Error("in-flight request context was not cancelled")
+	}
+	inFlight.End()
+
+	if _, ok := tracker.Begin(r, true, "https://cdn.example.com/live.m3u8", types.StreamTypeHLS); ok {
+		t.Error("playlist retry after termination was accepted")
+	}
+	if _, ok := tracker.Begin(r, false, "https://cdn.example.com/1.ts", types.StreamTypeGeneric); ok {
+		t.Error("segment retry after termination was accepted")
+	}
+	// Forwarding headers of an untrusted peer don't get around the block
+	forged := httptest.NewRequest("GET", "/proxy/stream", nil)
+	forged.RemoteAddr = r.RemoteAddr
+	forged.Header.Set("X-Forwarded-For", "203.0.113.9")
+	if _, ok := tracker.Begin(forged, true, "https://cdn.example.com/live.m3u8", types.StreamTypeHLS); ok {
+		t.Error("retry with a forged X-Forwarded-For was accepted")
+	}
+	if tracker.Terminate(id) {
+		t.Error("second Terminate() = true")
+	}
+
+	// The block lifts once the client stops retrying for the idle timeout
+	now = now.Add(31 * time.Second)
+	req, ok := tracker.Begin(r, true, "https://cdn.example.com/live.m3u8", types.StreamTypeHLS)
+	if !ok {
+		t.Fatal("Begin() still refused after idle timeout")
+	}
+	req.End()
+}
+
+func TestTracker_ExpiresIdleSessions(t *testing.T) {
+	tracker := NewTracker(30*time.Second, nil)
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+
+	r := httptest.NewRequest("GET", "/proxy/stream", nil)
+	req, _ := tracker.Begin(r, false, "https://cdn.example.com/movie.mp4", types.StreamTypeGeneric)
+
+	// In-flight requests keep the session alive
+	now = now.Add(time.Minute)
+	if tracker.Count() != 1 {
+		t.Fatalf("Count() with in-flight request = %d, want 1", tracker.Count())
+	}
+
+	req.End()
+	now = now.Add(31 * time.Second)
+	if tracker.Count() != 0 {
+		t.Errorf("Count() after idle timeout = %d, want 0", tracker.Count())
+	}
+}
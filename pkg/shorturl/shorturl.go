@@ -0,0 +1,118 @@
+// Package shorturl maps long proxy URLs to short opaque IDs kept server-side,
+// so playlists don't carry upstream URLs and headers in their query strings.
+package shorturl
+
+import (
+	"container/list"
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// idBytes is the entropy of an ID, 12 characters once encoded.
+const idBytes = 9
+
+// Store holds the targets of short IDs. An ID expires when it hasn't been
+// shortened again or resolved for the TTL; playlist refreshes keep the IDs of
+// a live stream alive. A full store drops its least recently used IDs.
+type Store struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	lru        *list.List // Of *entry, most recently used first
+	byID       map[string]*list.Element
+	byTarget   map[string]string
+}
+
+type entry struct {
+	id      string
+	target  string
+	expires time.Time
+}
+
+// New creates a store whose IDs expire after ttl without use, holding up to
+// maxEntries IDs.
+func New(ttl time.Duration, maxEntries int) *Store {
+	return &Store{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		byID:       make(map[string]*list.Element),
+		byTarget:   make(map[string]string),
+	}
+}
+
+// Shorten returns the ID of target, the same one as long as it is in use.
+func (s *Store) Shorten(target string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if id, ok := s.byTarget[target]; ok {
+		s.touch(s.byID[id], now)
+		return id
+	}
+
+	if len(s.byID) >= s.maxEntries {
+		s.prune(now)
+		for len(s.byID) >= max(s.maxEntries, 1) {
+			s.remove(s.lru.Back())
+		}
+	}
+
+	b := make([]byte, idBytes)
+	rand.Read(b)
+	id := base64.RawURLEncoding.EncodeToString(b)
+	s.byID[id] = s.lru.PushFront(&entry{id: id, target: target, expires: now.Add(s.ttl)})
+	s.byTarget[target] = id
+	return id
+}
+
+// Resolve returns the target of an ID, false if it is unknown or expired.
+func (s *Store) Resolve(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.byID[id]
+	if !ok {
+		return "", false
+	}
+	e := el.Value.(*entry)
+	now := time.Now()
+	if now.After(e.expires) {
+		s.remove(el)
+		return "", false
+	}
+	s.touch(el, now)
+	return e.target, true
+}
+
+// Len returns the number of IDs held.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.byID)
+}
+
+// touch extends the life of an ID and marks it most recently used. Caller
+// must hold s.mu.
+func (s *Store) touch(el *list.Element, now time.Time) {
+	el.Value.(*entry).expires = now.Add(s.ttl)
+	s.lru.MoveToFront(el)
+}
+
+// prune drops the expired IDs, which are the least recently used ones as
+// every use extends an ID by the same TTL. Caller must hold s.mu.
+func (s *Store) prune(now time.Time) {
+	for el := s.lru.Back(); el != nil && now.After(el.Value.(*entry).expires); el = s.lru.Back() {
+		s.remove(el)
+	}
+}
+
+// remove drops an ID. Caller must hold s.mu.
+func (s *Store) remove(el *list.Element) {
+	e := s.lru.Remove(el).(*entry)
+	delete(s.byID, e.id)
+	delete(s.byTarget, e.target)
+}
@@ -0,0 +1,63 @@
+package shorturl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	s := New(time.Hour, 10)
+
+	id := s.Shorten("/proxy/stream?url=http%3A%2F%2Forigin%2Fseg1.ts")
+	if len(id) != 12 {
+		t.Errorf("id = %q, want 12 characters", id)
+	}
+	if again := s.Shorten("/proxy/stream?url=http%3A%2F%2Forigin%2Fseg1.ts"); again != id {
+		t.Errorf("Shorten() of the same target = %q, want %q", again, id)
+	}
+	if other := s.Shorten("/proxy/stream?url=http%3A%2F%2Forigin%2Fseg2.ts"); other == id {
+		t.Error("different targets share an ID")
+	}
+
+	if target, ok := s.Resolve(id); !ok || target != "/proxy/stream?url=http%3A%2F%2Forigin%2Fseg1.ts" {
+		t.Errorf("Resolve() = %q, %v", target, ok)
+	}
+	if _, ok := s.Resolve("unknown"); ok {
+		t.Error("Resolve() of an unknown ID succeeded")
+	}
+}
+
+func TestStore_Expiry(t *testing.T) {
+	s := New(time.Millisecond, 2)
+	id := s.Shorten("a")
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := s.Resolve(id); ok {
+		t.Error("Resolve() of an expired ID succeeded")
+	}
+
+	// Full stores drop expired IDs first, then the least recently used
+	s.Shorten("b")
+	s.Shorten("c")
+	time.Sleep(5 * time.Millisecond)
+	s.Shorten("d")
+	if n := s.Len(); n != 1 {
+		t.Errorf("Len() = %d after pruning, want 1", n)
+	}
+
+	s = New(time.Hour, 2)
+	a := s.Shorten("a")
+	b := s.Shorten("b")
+	s.Resolve(a)
+	c := s.Shorten("c")
+	if n := s.Len(); n != 2 {
+		t.Errorf("Len() = %d after a full store, want 2", n)
+	}
+	if _, ok := s.Resolve(b); ok {
+		t.Error("Resolve() of the least recently used ID succeeded")
+	}
+	for id, want := range map[string]string{a: "a", c: "c"} {
+		if target, ok := s.Resolve(id); !ok || target != want {
+			t.Errorf("Resolve(%q) = %q, %v, want %q", id, target, ok, want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Package streamstats keeps per-stream counters (segments, bytes, upstream
+// errors, fetch times) so operators can tell which channel is buffering and why.
+//
+// Streams are keyed by their originating manifest URL: the URL a playback
+// session started with (see the sessions package), so variant playlists,
+// segments and keys requested by its players all count towards it.
+package streamstats
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"media-proxy-go/pkg/types"
+)
+
+// Sample is one finished proxied request.
+type Sample struct {
+	Manifest  bool          // A manifest (re)load rather than a segment, key or direct stream
+	Bytes     int64         // Bytes sent to the client
+	Status    int           // Response status
+	FetchTime time.Duration // Until the response started, i.e. the upstream fetch
+	Error     string        // Error message of a failed request
+}
+
+// Collector aggregates samples per stream.
+type Collector struct {
+	retention time.Duration
+	now       func() time.Time
+
+	mu      sync.Mutex
+	streams map[string]*stream // By manifest URL
+}
+
+// stream is the mutable state behind a types.StreamStats.
+type stream struct {
+	info      types.StreamStats
+	fetchTime time.Duration // Total over successful requests
+	fetches   int64
+}
+
+// NewCollector creates a collector. Streams without requests for retention are dropped.
+func NewCollector(retention time.Duration) *Collector {
+	return &Collector{
+		retention: retention,
+		now:       time.Now,
+		streams:   make(map[string]*stream),
+	}
+}
+
+// Record adds a finished request to the stream started by streamURL.
+func (c *Collector) Record(streamURL string, streamType types.StreamType, s Sample) {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st, ok := c.streams[streamURL]
+	if !ok {
+		st = &stream{info: types.StreamStats{URL: streamURL, Type: streamType, FirstSeen: now.Unix()}}
+		c.streams[streamURL] = st
+	}
+
+	if s.Manifest {
+		st.info.Manifests++
+	} else {
+		st.info.Segments++
+	}
+	st.info.Bytes += s.Bytes
+	st.info.LastSeen = now.Unix()
+
+	if s.Status >= 400 {
+		st.info.Errors++
+		st.info.LastError = s.Error
+		st.info.LastErrorAt = now.Unix()
+		return
+	}
+
+	st.fetches++
+	st.fetchTime += s.FetchTime
+	st.info.AvgFetchTime = (st.fetchTime / time.Duration(st.fetches)).Milliseconds()
+	st.info.MaxFetchTime = max(st.info.MaxFetchTime, s.FetchTime.Milliseconds())
+}
+
+// List returns the streams with recent requests, most recently active first.
+func (c *Collector) List() []types.StreamStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prune(c.now())
+
+	result := make([]types.StreamStats, 0, len(c.streams))
+	for _, st := range c.streams {
+		result = append(result, st.info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].LastSeen != result[j].LastSeen {
+			return result[i].LastSeen > result[j].LastSeen
+		}
+		return result[i].URL < result[j].URL
+	})
+	return result
+}
+
+// Reset drops all counters.
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.streams)
+}
+
+// prune drops streams idle for longer than the retention. Caller must hold c.mu.
+func (c *Collector) prune(now time.Time) {
+	cutoff := now.Add(-c.retention).Unix()
+	for key, st := range c.streams {
+		if st.info.LastSeen < cutoff {
+			delete(c.streams, key)
+		}
+	}
+}
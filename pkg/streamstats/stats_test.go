@@ -0,0 +1,59 @@
+package streamstats
+
+import (
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/types"
+)
+
+func TestCollector_Record(t *testing.T) {
+	c := NewCollector(time.Hour)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	const live = "https://cdn.example.com/live.m3u8"
+	c.Record(live, types.StreamTypeHLS, Sample{Manifest: true, Bytes: 100, Status: 200, FetchTime: 40 * time.Millisecond})
+	c.Record(live, types.StreamTypeHLS, Sample{Bytes: 1000, Status: 200, FetchTime: 80 * time.Millisecond})
+	c.Record(live, types.StreamTypeHLS, Sample{Status: 502, FetchTime: 5 * time.Second, Error: "502: upstream timeout"})
+
+	list := c.List()
+	if len(list) != 1 {
+		t.Fatalf("len(List()) = %d, want 1", len(list))
+	}
+	s := list[0]
+	if s.URL != live || s.Type != types.StreamTypeHLS || s.Manifests != 1 || s.Segments != 2 || s.Bytes != 1100 {
+		t.Errorf("counters = %+v", s)
+	}
+	// Failed requests don't skew the fetch times
+	if s.AvgFetchTime != 60 || s.MaxFetchTime != 80 {
+		t.Errorf("AvgFetchTime = %d, MaxFetchTime = %d, want 60, 80", s.AvgFetchTime, s.MaxFetchTime)
+	}
+	if s.Errors != 1 || s.LastError != "502: upstream timeout" || s.LastErrorAt != now.Unix() {
+		t.Errorf("errors = %d, %q at %d", s.Errors, s.LastError, s.LastErrorAt)
+	}
+}
+
+func TestCollector_Retention(t *testing.T) {
+	c := NewCollector(time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Record("https://cdn.example.com/old.m3u8", types.StreamTypeHLS, Sample{Status: 200})
+	now = now.Add(50 * time.Second)
+	c.Record("https://cdn.example.com/new.m3u8", types.StreamTypeHLS, Sample{Status: 200})
+
+	if list := c.List(); len(list) != 2 || list[0].URL != "https://cdn.example.com/new.m3u8" {
+		t.Fatalf("List() = %+v, want new stream first", list)
+	}
+
+	now = now.Add(30 * time.Second)
+	if list := c.List(); len(list) != 1 || list[0].URL != "https://cdn.example.com/new.m3u8" {
+		t.Errorf("List() after retention = %+v", list)
+	}
+
+	c.Reset()
+	if list := c.List(); len(list) != 0 {
+		t.Errorf("List() after Reset() = %+v", list)
+	}
+}
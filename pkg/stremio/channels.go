@@ -0,0 +1,99 @@
+package stremio
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"media-proxy-go/pkg/channels"
+	"media-proxy-go/pkg/types"
+)
+
+// handleChannelsCatalog returns the catalog of live channels, optionally
+// filtered by search query and group (genre).
+func (h *Handlers) handleChannelsCatalog(w http.ResponseWriter, searchQuery, genre string) {
+	h.log.Debug("fetching channels catalog", "search", searchQuery, "genre", genre)
+
+	metas := []Meta{}
+	for _, ch := range h.ctx.Channels.List() {
+		if genre != "" && ch.Group != genre {
+			continue
+		}
+		if searchQuery != "" && !strings.Contains(strings.ToLower(ch.Name), searchQuery) {
+			continue
+		}
+		metas = append(metas, channelToMeta(ch))
+	}
+
+	h.jsonResponse(w, map[string][]Meta{"metas": metas})
+}
+
+// handleChannelMeta returns metadata for a live channel.
+func (h *Handlers) handleChannelMeta(w http.ResponseWriter, channelID string) {
+	ch, ok := h.ctx.Channels.Get(channelID)
+	if !ok {
+		h.jsonResponse(w, map[string]any{"meta": nil})
+		return
+	}
+	h.jsonResponse(w, map[string]Meta{"meta": channelToMeta(ch)})
+}
+
+// handleChannelStream returns Play and (if DVR is enabled) Record streams for a live channel.
+func (h *Handlers) handleChannelStream(w http.ResponseWriter, r *http.Request, baseURL, channelID string) {
+	ch, ok := h.ctx.Channels.Get(channelID)
+	if !ok {
+		h.jsonResponse(w, map[string][]Stream{"streams": {}})
+		return
+	}
+
+	streams := []Stream{
+		{URL: h.signedURL(baseURL, "/proxy/manifest.m3u8", channels.ProxyQuery(ch, nil)), Title: "▶️ Play"},
+	}
+
+	if h.ctx.RecordingManager != nil && h.canManage(r) {
+		recordURL := fmt.Sprintf("%s%s/record/%s", baseURL, addonPath(r), url.PathEscape(ch.ID))
+		streams = append(streams, Stream{URL: recordURL, Title: "🔴 Record"})
+	}
+
+	h.jsonResponseNoCache(w, map[string][]Stream{"streams": streams})
+}
+
+// handleRecord serves the Record entry offered with a live channel: it starts
+// recording the channel in the background and redirects to its live stream.
+func (h *Handlers) handleRecord(w http.ResponseWriter, r *http.Request) {
+	if h.ctx.RecordingManager == nil || h.ctx.Channels == nil || !h.canManage(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	ch, ok := h.ctx.Channels.Get(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	rec, err := h.ctx.RecordingManager.StartRecording(r.Context(), ch.URL, ch.Name, ch.ClearKey, ch.Headers, "", nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.log.Info("recording started from stremio", "id", rec.ID, "channel", ch.ID)
+	http.Redirect(w, r, h.signedURL(h.playbackBaseURL(r), "/proxy/manifest.m3u8", channels.ProxyQuery(ch, nil)), http.StatusFound)
+}
+
+// channelToMeta converts a Channel to a Stremio Meta.
+func channelToMeta(ch types.Channel) Meta {
+	meta := Meta{
+		ID:          "live:" + ch.ID,
+		Type:        "tv",
+		Name:        ch.Name,
+		Poster:      ch.Logo,
+		PosterShape: "square",
+		Description: "Live channel",
+	}
+	if ch.Group != "" {
+		meta.Genres = []string{ch.Group}
+		meta.Description = "Live channel | " + ch.Group
+	}
+	return meta
+}
@@ -0,0 +1,107 @@
+package stremio
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/appctx"
+	"media-proxy-go/pkg/channels"
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/services"
+	"media-proxy-go/pkg/types"
+	"media-proxy-go/pkg/urlsign"
+)
+
+func TestHandlers_RecordChannel(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Fake FFmpeg that writes a little output and exits cleanly
+	fakeFFmpeg := filepath.Join(tempDir, "ffmpeg")
+	if err := os.WriteFile(fakeFFmpeg, []byte("#!/bin/sh\nprintf 'mpegts'\n"), 0755); err != nil {
+		t.Fatalf("failed to create fake ffmpeg: %v", err)
+	}
+
+	log := logging.New("error", false, io.Discard)
+	cfg := &config.Config{
+		APIPassword:             "secret",
+		AdminPassword:           "admin",
+		BaseURL:                 "https://proxy.example.com",
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    time.Hour,
+		FFmpegPath:              fakeFFmpeg,
+	}
+	rm, err := services.NewRecordingManager(cfg, log, cfg.BaseURL, nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+	defer rm.Close()
+
+	store := channels.NewStore("", nil, log)
+	ch, err := store.Add(types.Channel{Name: "Sports", URL: "https://origin.example.com/live.m3u8"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	mux := http.NewServeMux()
+	NewHandlers(appctx.New(cfg, log).WithRecordingManager(rm).WithChannels(store)).RegisterRoutes(mux)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+	streams := func(prefix string) []Stream {
+		t.Helper()
+		w := get(prefix + "/stream/tv/live:" + ch.ID + ".json")
+		var resp struct {
+			Streams []Stream `json:"streams"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode streams: %v", err)
+		}
+		return resp.Streams
+	}
+
+	// Recording is an admin action
+	playback := "/stremio/" + EncodeConfig(AddonConfig{Password: "secret"})
+	if got := streams(playback); len(got) != 1 {
+		t.Errorf("streams without the admin password = %+v, want only Play", got)
+	}
+	if w := get(playback + "/record/" + ch.ID); w.Code != http.StatusForbidden {
+		t.Errorf("record without the admin password status = %d, want 403", w.Code)
+	}
+
+	admin := "/stremio/" + EncodeConfig(AddonConfig{Password: "admin"})
+	got := streams(admin)
+	if len(got) != 2 || got[1].URL != cfg.BaseURL+admin+"/record/"+ch.ID {
+		t.Fatalf("streams with the admin password = %+v, want Play and Record", got)
+	}
+	if w := get(admin + "/record/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("record of an unknown channel status = %d, want 404", w.Code)
+	}
+
+	w := get(admin + "/record/" + ch.ID)
+	if w.Code != http.StatusFound {
+		t.Fatalf("record status = %d, want 302", w.Code)
+	}
+	u, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || u.Path != "/proxy/manifest.m3u8" || u.Query().Get("url") != ch.URL || u.Query().Has("api_password") {
+		t.Fatalf("record redirect = %q, want the signed live stream", w.Header().Get("Location"))
+	}
+	if err := urlsign.Verify("secret", u.Query(), u.Path, "", time.Now()); err != nil {
+		t.Errorf("record redirect signature: %v", err)
+	}
+	if recordings, _ := rm.ListRecordings(); len(recordings) != 1 || recordings[0].Name != "Sports" {
+		t.Errorf("recordings = %+v, want one of Sports", recordings)
+	}
+}
@@ -0,0 +1,191 @@
+package stremio
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"media-proxy-go/pkg/i18n"
+	"media-proxy-go/pkg/urlsign"
+	"media-proxy-go/pkg/urlutil"
+	"media-proxy-go/pkg/web"
+)
+
+// Catalogs an addon configuration can expose.
+const (
+	CatalogRecordings = "recordings"
+	CatalogChannels   = "channels"
+)
+
+// Playback URL modes of an addon configuration.
+const (
+	PlaybackExternal = "external" // BASE_URL, or the public address set by a trusted proxy
+	PlaybackInternal = "internal" // The address the addon was installed from, e.g. a LAN IP
+)
+
+// AddonConfig is the configuration of one addon install. It is carried in
+// the addon URL as base64url-encoded JSON: /stremio/{config}/manifest.json.
+type AddonConfig struct {
+	Password string   `json:"password,omitempty"`
+	Catalogs []string `json:"catalogs,omitempty"` // Empty for all
+	Playback string   `json:"playback,omitempty"` // PlaybackExternal (default) or PlaybackInternal
+}
+
+// EncodeConfig encodes an addon configuration for the addon URL.
+func EncodeConfig(c AddonConfig) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeConfig decodes an addon configuration from the addon URL.
+func DecodeConfig(s string) (AddonConfig, error) {
+	var c AddonConfig
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return c, fmt.Errorf("invalid addon config: %w", err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("invalid addon config: %w", err)
+	}
+	return c, nil
+}
+
+// catalogEnabled reports whether the configuration exposes a catalog.
+func (c AddonConfig) catalogEnabled(name string) bool {
+	return len(c.Catalogs) == 0 || slices.Contains(c.Catalogs, name)
+}
+
+type configKey struct{}
+
+// addonConfig returns the configuration of a request made through a
+// configured addon URL; unconfigured requests get the zero configuration.
+func addonConfig(r *http.Request) AddonConfig {
+	c, _ := r.Context().Value(configKey{}).(AddonConfig)
+	return c
+}
+
+// addonPath returns the path the addon was installed under: /stremio, or
+// /stremio/{config} for a configured install.
+func addonPath(r *http.Request) string {
+	c, ok := r.Context().Value(configKey{}).(AddonConfig)
+	if !ok {
+		return "/stremio"
+	}
+	return "/stremio/" + EncodeConfig(c)
+}
+
+// passwordRequired reports whether the addon only serves installs configured
+// with the API password.
+func (h *Handlers) passwordRequired() bool {
+	return h.ctx.Config.StremioRequirePassword && h.ctx.Config.APIPassword != ""
+}
+
+// authorized reports whether a request may use the catalogs and streams.
+func (h *Handlers) authorized(r *http.Request) bool {
+	if !h.passwordRequired() {
+		return true
+	}
+	password := []byte(addonConfig(r).Password)
+	return subtle.ConstantTimeCompare(password, []byte(h.ctx.Config.APIPassword)) == 1 ||
+		(h.ctx.Config.AdminPassword != "" && subtle.ConstantTimeCompare(password, []byte(h.ctx.Config.AdminPassword)) == 1)
+}
+
+// playbackBaseURL returns the base of the stream URLs handed to Stremio.
+func (h *Handlers) playbackBaseURL(r *http.Request) string {
+	if addonConfig(r).Playback == PlaybackInternal {
+		return requestBaseURL(r)
+	}
+	return urlutil.PublicBaseURL(r.Context(), h.ctx.BaseURL)
+}
+
+// signedURL returns the URL of path under baseURL with query, signed with
+// the streaming password (see urlsign.SignedURL), so Stremio plays it without
+// carrying the password.
+func (h *Handlers) signedURL(baseURL, path string, query url.Values) string {
+	return urlsign.SignedURL(h.ctx.Config.StreamingPassword(), baseURL, path, query)
+}
+
+// requestBaseURL returns the scheme and host a request was made to, as seen
+// by the client when it came through a trusted proxy.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return urlutil.RequestOrigin(r.Context(), scheme+"://"+r.Host)
+}
+
+// handleConfigured serves the addon under a configured URL,
+// /stremio/{config}/..., with the configuration in the request context.
+func (h *Handlers) handleConfigured(w http.ResponseWriter, r *http.Request) {
+	c, err := DecodeConfig(r.PathValue("config"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), configKey{}, c))
+
+	parts := strings.Split(r.PathValue("rest"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "manifest.json":
+		h.handleManifest(w, r)
+	case len(parts) == 1 && parts[0] == "configure":
+		h.handleConfigure(w, r)
+	case len(parts) == 2 && parts[0] == "delete":
+		r.SetPathValue("id", parts[1])
+		h.handleDelete(w, r)
+	case len(parts) == 2 && parts[0] == "stop":
+		r.SetPathValue("id", parts[1])
+		h.handleStop(w, r)
+	case len(parts) == 2 && parts[0] == "record":
+		r.SetPathValue("id", parts[1])
+		h.handleRecord(w, r)
+	case len(parts) >= 3 && len(parts) <= 4:
+		r.SetPathValue("type", parts[1])
+		r.SetPathValue("id", parts[2])
+		if len(parts) == 4 {
+			r.SetPathValue("extra", parts[3])
+		}
+		switch {
+		case parts[0] == "catalog":
+			h.handleCatalog(w, r)
+		case parts[0] == "meta" && len(parts) == 3:
+			h.handleMeta(w, r)
+		case parts[0] == "stream" && len(parts) == 3:
+			h.handleStream(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleConfigure serves the configuration page, which builds a configured
+// manifest URL to install.
+func (h *Handlers) handleConfigure(w http.ResponseWriter, r *http.Request) {
+	base := urlutil.PublicBaseURL(r.Context(), h.ctx.BaseURL)
+	page := web.StremioConfigure{
+		BaseURL:          base,
+		Password:         h.ctx.Config.APIPassword != "",
+		PasswordRequired: h.passwordRequired(),
+		External:         web.StremioPlayback{Value: PlaybackExternal, URL: base},
+		Internal:         web.StremioPlayback{Value: PlaybackInternal, URL: requestBaseURL(r)},
+	}
+	if h.ctx.RecordingManager != nil {
+		page.Recordings = CatalogRecordings
+	}
+	if h.ctx.Channels != nil && h.ctx.Channels.Count() > 0 {
+		page.Channels = CatalogChannels
+	}
+	if err := web.Render(w, "configure.html", i18n.FromRequest(r, h.ctx.Config.UILanguage), page); err != nil {
+		h.log.Error("failed to render configuration page", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
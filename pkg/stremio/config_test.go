@@ -0,0 +1,177 @@
+package stremio
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/appctx"
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/services"
+	"media-proxy-go/pkg/types"
+	"media-proxy-go/pkg/urlsign"
+)
+
+func TestDecodeConfig(t *testing.T) {
+	want := AddonConfig{Password: "secret", Catalogs: []string{CatalogRecordings}, Playback: PlaybackInternal}
+	got, err := DecodeConfig(EncodeConfig(want))
+	if err != nil {
+		t.Fatalf("DecodeConfig() error = %v", err)
+	}
+	if got.Password != want.Password || len(got.Catalogs) != 1 || got.Playback != want.Playback {
+		t.Errorf("DecodeConfig() = %+v, want %+v", got, want)
+	}
+
+	for _, invalid := range []string{"not base64!", "bm90IGpzb24"} {
+		if _, err := DecodeConfig(invalid); err == nil {
+			t.Errorf("DecodeConfig(%q) error = nil", invalid)
+		}
+	}
+}
+
+func TestHandlers_ConfiguredAddon(t *testing.T) {
+	tempDir := t.TempDir()
+	recordings := []*types.Recording{
+		{ID: "rec_1", Name: "Match", Status: string(types.RecordingStatusCompleted), FilePath: filepath.Join(tempDir, "match.ts"), FileSize: 100, StartedAt: time.Now().Unix()},
+	}
+	data, _ := json.Marshal(recordings)
+	if err := os.WriteFile(filepath.Join(tempDir, "recordings.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	log := logging.New("error", false, io.Discard)
+	cfg := &config.Config{
+		APIPassword:             "secret",
+		StremioRequirePassword:  true,
+		BaseURL:                 "https://proxy.example.com",
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    time.Hour,
+		FFmpegPath:              "ffmpeg",
+	}
+	rm, err := services.NewRecordingManager(cfg, log, cfg.BaseURL, nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+	defer rm.Close()
+
+	mux := http.NewServeMux()
+	NewHandlers(appctx.New(cfg, log).WithRecordingManager(rm)).RegisterRoutes(mux)
+
+	get := func(path string, v any) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = "192.168.1.5:7860"
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d, want 200", path, w.Code)
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("GET %s: invalid JSON: %v", path, err)
+		}
+	}
+
+	type manifest struct {
+		Catalogs      []map[string]any `json:"catalogs"`
+		BehaviorHints map[string]bool  `json:"behaviorHints"`
+	}
+	type streams struct {
+		Streams []Stream `json:"streams"`
+	}
+
+	// Unconfigured and wrongly configured installs must be configured first
+	wrong := EncodeConfig(AddonConfig{Password: "wrong"})
+	for _, path := range []string{"/stremio/manifest.json", "/stremio/" + wrong + "/manifest.json"} {
+		var m manifest
+		get(path, &m)
+		if len(m.Catalogs) != 0 || !m.BehaviorHints["configurationRequired"] || !m.BehaviorHints["configurable"] {
+			t.Errorf("GET %s = %+v, want no catalogs and configurationRequired", path, m)
+		}
+	}
+	var s streams
+	get("/stremio/"+wrong+"/stream/tv/dvr:rec_1.json", &s)
+	if len(s.Streams) != 0 {
+		t.Errorf("streams with wrong password = %+v, want none", s.Streams)
+	}
+
+	// The password unlocks the catalogs; internal playback uses the request address
+	internal := EncodeConfig(AddonConfig{Password: "secret", Playback: PlaybackInternal})
+	var m manifest
+	get("/stremio/"+internal+"/manifest.json", &m)
+	if len(m.Catalogs) != 1 || m.BehaviorHints["configurationRequired"] {
+		t.Errorf("configured manifest = %+v, want the recordings catalog", m)
+	}
+	var metas struct {
+		Metas []Meta `json:"metas"`
+	}
+	get("/stremio/"+internal+"/catalog/tv/"+RecordingsCatalogID+".json", &metas)
+	if len(metas.Metas) != 1 {
+		t.Errorf("catalog = %+v, want 1 recording", metas.Metas)
+	}
+	// Play links are signed for their path instead of carrying the password
+	signedFor := func(streams []Stream, base string) bool {
+		if len(streams) == 0 {
+			return false
+		}
+		u, err := url.Parse(streams[0].URL)
+		if err != nil || u.Scheme+"://"+u.Host != base || u.Query().Has("api_password") {
+			return false
+		}
+		return urlsign.Verify("secret", u.Query(), u.Path, "", time.Now()) == nil && u.Path == "/api/recordings/rec_1/stream"
+	}
+	get("/stremio/"+internal+"/stream/tv/dvr:rec_1.json", &s)
+	if !signedFor(s.Streams, "http://192.168.1.5:7860") {
+		t.Errorf("internal streams = %+v", s.Streams)
+	}
+
+	external := EncodeConfig(AddonConfig{Password: "secret"})
+	get("/stremio/"+external+"/stream/tv/dvr:rec_1.json", &s)
+	if !signedFor(s.Streams, "https://proxy.example.com") {
+		t.Errorf("external streams = %+v", s.Streams)
+	}
+
+	// Catalogs left out of the configuration are hidden
+	channelsOnly := EncodeConfig(AddonConfig{Password: "secret", Catalogs: []string{CatalogChannels}})
+	get("/stremio/"+channelsOnly+"/manifest.json", &m)
+	if len(m.Catalogs) != 0 {
+		t.Errorf("channels-only manifest catalogs = %+v, want none (no channels loaded)", m.Catalogs)
+	}
+	get("/stremio/"+channelsOnly+"/catalog/tv/"+RecordingsCatalogID+".json", &metas)
+	if len(metas.Metas) != 0 {
+		t.Errorf("hidden catalog = %+v, want empty", metas.Metas)
+	}
+}
+
+func TestHandlers_ConfigurePage(t *testing.T) {
+	cfg := &config.Config{APIPassword: "secret", BaseURL: "https://proxy.example.com/media"}
+	mux := http.NewServeMux()
+	NewHandlers(appctx.New(cfg, logging.New("error", false, io.Discard))).RegisterRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/stremio/configure", nil)
+	req.Host = "192.168.1.5:7860"
+	req.Header.Set("Accept-Language", "it")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	// The manifest URL keeps the path BASE_URL is served under
+	body := w.Body.String()
+	if !strings.Contains(body, `const url = "https://proxy.example.com/media" + '/stremio/'`) || strings.Contains(body, "location.host") {
+		t.Error("configuration page does not build the manifest URL from the public base URL")
+	}
+	for _, want := range []string{`<html lang="it">`, "Password API", "Installa addon"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("configuration page missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package stremio
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// deleteConfirmWindow is how long an armed deletion waits for its
+// confirmation. Stremio refetches the streams of an item when it is reopened,
+// showing the confirmation entry in their place.
+const deleteConfirmWindow = 2 * time.Minute
+
+// pendingDelete is a deletion armed by the first hit of a delete entry.
+type pendingDelete struct {
+	nonce   string
+	expires time.Time
+}
+
+// canManage reports whether the install may stop and delete recordings:
+// those are admin actions, so with a password set the addon configuration
+// must carry the admin password.
+func (h *Handlers) canManage(r *http.Request) bool {
+	password := h.ctx.Config.RequiredAdminPassword()
+	if password == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(addonConfig(r).Password), []byte(password)) == 1
+}
+
+// deleteStream returns the delete entry for a finished recording: the
+// confirmation while a deletion is armed, else the entry arming one.
+func (h *Handlers) deleteStream(r *http.Request, recordingID string) Stream {
+	deleteURL := fmt.Sprintf("%s%s/delete/%s", h.playbackBaseURL(r), addonPath(r), url.PathEscape(recordingID))
+	if pending, ok := h.pendingDelete(recordingID); ok {
+		remaining := time.Until(pending.expires).Round(time.Second)
+		return Stream{
+			URL:   deleteURL + "?confirm=" + pending.nonce,
+			Title: fmt.Sprintf("⚠️ Confirm Delete (%s left)", remaining),
+		}
+	}
+	return Stream{URL: deleteURL, Title: "🗑️ Delete Recording (asks to confirm)"}
+}
+
+// pendingDelete returns the armed deletion of a recording, if any.
+func (h *Handlers) pendingDelete(recordingID string) (pendingDelete, bool) {
+	h.deletesMu.Lock()
+	defer h.deletesMu.Unlock()
+	pending, ok := h.deletes[recordingID]
+	if !ok || time.Now().After(pending.expires) {
+		return pendingDelete{}, false
+	}
+	return pending, true
+}
+
+// handleDelete serves the delete entries offered with a finished recording.
+// The first hit arms the deletion; reopening the item then offers a confirm
+// entry, and only playing that within deleteConfirmWindow deletes it.
+func (h *Handlers) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if h.ctx.RecordingManager == nil || !h.canManage(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	recording, err := h.ctx.RecordingManager.GetRecording(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	nonce := r.URL.Query().Get("confirm")
+	if nonce == "" {
+		b := make([]byte, 8)
+		rand.Read(b)
+		now := time.Now()
+
+		h.deletesMu.Lock()
+		for key, pending := range h.deletes {
+			if now.After(pending.expires) {
+				delete(h.deletes, key)
+			}
+		}
+		h.deletes[id] = pendingDelete{nonce: hex.EncodeToString(b), expires: now.Add(deleteConfirmWindow)}
+		h.deletesMu.Unlock()
+
+		h.log.Info("recording deletion armed", "id", id, "name", recording.Name)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "Not deleted yet: reopen %q and choose \"Confirm Delete\" within %s.\n", recording.Name, deleteConfirmWindow)
+		return
+	}
+
+	pending, ok := h.pendingDelete(id)
+	if !ok || subtle.ConstantTimeCompare([]byte(nonce), []byte(pending.nonce)) != 1 {
+		http.Error(w, "Confirmation expired, choose Delete Recording again", http.StatusGone)
+		return
+	}
+	h.deletesMu.Lock()
+	delete(h.deletes, id)
+	h.deletesMu.Unlock()
+
+	if err := h.ctx.RecordingManager.DeleteRecording(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.log.Info("recording deleted from stremio", "id", id, "name", recording.Name)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "Deleted %q.\n", recording.Name)
+}
+
+// handleStop serves the Stop & Watch entry offered with an active recording:
+// it stops the recording and redirects to its stream.
+func (h *Handlers) handleStop(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if h.ctx.RecordingManager == nil || !h.canManage(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if _, err := h.ctx.RecordingManager.GetRecording(id); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := h.ctx.RecordingManager.StopRecording(id); err != nil {
+		// Recording might already be stopped, continue anyway
+		h.log.Debug("stop recording result", "id", id, "error", err)
+	}
+	h.log.Info("recording stopped from stremio", "id", id)
+	http.Redirect(w, r, h.signedURL(h.playbackBaseURL(r), "/api/recordings/"+id+"/stream", nil), http.StatusFound)
+}
@@ -0,0 +1,180 @@
+package stremio
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"media-proxy-go/pkg/appctx"
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/logging"
+	"media-proxy-go/pkg/services"
+	"media-proxy-go/pkg/types"
+	"media-proxy-go/pkg/urlsign"
+)
+
+func TestHandlers_ConfirmDelete(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "match.ts")
+	if err := os.WriteFile(filePath, []byte("ts"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	recordings := []*types.Recording{
+		{ID: "rec_1", Name: "Match", Status: string(types.RecordingStatusCompleted), FilePath: filePath, FileSize: 2, StartedAt: time.Now().Unix()},
+	}
+	data, _ := json.Marshal(recordings)
+	if err := os.WriteFile(filepath.Join(tempDir, "recordings.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	log := logging.New("error", false, io.Discard)
+	cfg := &config.Config{
+		APIPassword:             "secret",
+		AdminPassword:           "admin",
+		BaseURL:                 "https://proxy.example.com",
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    time.Hour,
+		FFmpegPath:              "ffmpeg",
+	}
+	rm, err := services.NewRecordingManager(cfg, log, cfg.BaseURL, nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+	defer rm.Close()
+
+	mux := http.NewServeMux()
+	NewHandlers(appctx.New(cfg, log).WithRecordingManager(rm)).RegisterRoutes(mux)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+	streams := func(addon string) []Stream {
+		t.Helper()
+		var s struct {
+			Streams []Stream `json:"streams"`
+		}
+		if err := json.Unmarshal(get(addon+"/stream/tv/dvr:rec_1.json").Body.Bytes(), &s); err != nil {
+			t.Fatal(err)
+		}
+		return s.Streams
+	}
+	path := func(s string) string {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u.RequestURI()
+	}
+
+	// Installs without the admin password get no delete entry and cannot delete
+	playback := "/stremio/" + EncodeConfig(AddonConfig{Password: "secret"})
+	if s := streams(playback); len(s) != 1 {
+		t.Errorf("streams without the admin password = %+v, want only Play", s)
+	}
+	if w := get(playback + "/delete/rec_1"); w.Code != http.StatusForbidden {
+		t.Errorf("delete without the admin password status = %d, want 403", w.Code)
+	}
+
+	admin := "/stremio/" + EncodeConfig(AddonConfig{Password: "admin"})
+	s := streams(admin)
+	if len(s) != 2 || !strings.HasPrefix(s[1].URL, "https://proxy.example.com"+admin+"/delete/rec_1") {
+		t.Fatalf("streams = %+v, want Play and Delete", s)
+	}
+
+	// The first hit only arms the deletion
+	if w := get(path(s[1].URL)); w.Code != http.StatusAccepted {
+		t.Fatalf("first delete hit status = %d, want 202", w.Code)
+	}
+	if _, err := rm.GetRecording("rec_1"); err != nil {
+		t.Fatal("recording deleted without confirmation")
+	}
+
+	s = streams(admin)
+	if len(s) != 2 || !strings.Contains(s[1].URL, "?confirm=") || !strings.Contains(s[1].Title, "Confirm") {
+		t.Fatalf("streams after arming = %+v, want a confirm entry", s)
+	}
+	confirm := path(s[1].URL)
+
+	if w := get(admin + "/delete/rec_1?confirm=wrong"); w.Code != http.StatusGone {
+		t.Errorf("wrong confirmation status = %d, want 410", w.Code)
+	}
+	if w := get(confirm); w.Code != http.StatusOK {
+		t.Fatalf("confirm status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if _, err := rm.GetRecording("rec_1"); err == nil {
+		t.Error("recording not deleted after confirmation")
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Errorf("recording file not removed: %v", err)
+	}
+}
+
+func TestHandlers_StopAndWatch(t *testing.T) {
+	tempDir := t.TempDir()
+	recordings := []*types.Recording{
+		{ID: "rec_1", Name: "Match", Status: string(types.RecordingStatusCompleted), FilePath: filepath.Join(tempDir, "match.ts"), StartedAt: time.Now().Unix()},
+	}
+	data, _ := json.Marshal(recordings)
+	if err := os.WriteFile(filepath.Join(tempDir, "recordings.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	log := logging.New("error", false, io.Discard)
+	cfg := &config.Config{
+		APIPassword:             "secret",
+		AdminPassword:           "admin",
+		BaseURL:                 "https://proxy.example.com",
+		RecordingsDir:           tempDir,
+		RecordingsRetentionDays: 7,
+		MaxRecordingDuration:    time.Hour,
+		FFmpegPath:              "ffmpeg",
+	}
+	rm, err := services.NewRecordingManager(cfg, log, cfg.BaseURL, nil)
+	if err != nil {
+		t.Fatalf("failed to create recording manager: %v", err)
+	}
+	defer rm.Close()
+
+	mux := http.NewServeMux()
+	NewHandlers(appctx.New(cfg, log).WithRecordingManager(rm)).RegisterRoutes(mux)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	// Stopping is an admin action
+	playback := "/stremio/" + EncodeConfig(AddonConfig{Password: "secret"})
+	if w := get(playback + "/stop/rec_1"); w.Code != http.StatusForbidden {
+		t.Errorf("stop without the admin password status = %d, want 403", w.Code)
+	}
+
+	admin := "/stremio/" + EncodeConfig(AddonConfig{Password: "admin"})
+	if w := get(admin + "/stop/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("stop of an unknown recording status = %d, want 404", w.Code)
+	}
+	w := get(admin + "/stop/rec_1")
+	if w.Code != http.StatusFound {
+		t.Fatalf("stop status = %d, want 302", w.Code)
+	}
+	u, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || u.Path != "/api/recordings/rec_1/stream" || u.Query().Has("api_password") {
+		t.Fatalf("stop redirect = %q, want the signed recording stream", w.Header().Get("Location"))
+	}
+	if err := urlsign.Verify("secret", u.Query(), u.Path, "", time.Now()); err != nil {
+		t.Errorf("stop redirect signature: %v", err)
+	}
+}
@@ -3,28 +3,37 @@ package stremio
 import (
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"net/http"
 	"net/url"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"media-proxy-go/pkg/appctx"
+	"media-proxy-go/pkg/i18n"
 	"media-proxy-go/pkg/logging"
 	"media-proxy-go/pkg/types"
+	"media-proxy-go/pkg/web"
 )
 
 // Handlers contains all Stremio addon handlers.
 type Handlers struct {
 	ctx *appctx.Context
 	log *logging.Logger
+
+	deletesMu sync.Mutex
+	deletes   map[string]pendingDelete // Armed deletions by recording ID
 }
 
 // NewHandlers creates a new Stremio Handlers instance.
 func NewHandlers(ctx *appctx.Context) *Handlers {
 	return &Handlers{
-		ctx: ctx,
-		log: ctx.Log.WithComponent("stremio"),
+		ctx:     ctx,
+		log:     ctx.Log.WithComponent("stremio"),
+		deletes: make(map[string]pendingDelete),
 	}
 }
 
@@ -34,229 +43,120 @@ func (h *Handlers) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /stremio/", h.handleHome)
 	mux.HandleFunc("GET /stremio/manifest.json", h.handleManifest)
 	mux.HandleFunc("GET /stremio/catalog/{type}/{id}", h.handleCatalog)
+	mux.HandleFunc("GET /stremio/catalog/{type}/{id}/{extra}", h.handleCatalog)
 	mux.HandleFunc("GET /stremio/meta/{type}/{id}", h.handleMeta)
 	mux.HandleFunc("GET /stremio/stream/{type}/{id}", h.handleStream)
+	mux.HandleFunc("GET /stremio/configure", h.handleConfigure)
+	mux.HandleFunc("GET /stremio/delete/{id}", h.handleDelete)
+	mux.HandleFunc("GET /stremio/stop/{id}", h.handleStop)
+	mux.HandleFunc("GET /stremio/record/{id}", h.handleRecord)
+	// Configured installs: /stremio/{config}/manifest.json and the resources below it
+	mux.HandleFunc("GET /stremio/{config}/{rest...}", h.handleConfigured)
 }
 
 // handleHome serves the Stremio addon installation page.
 func (h *Handlers) handleHome(w http.ResponseWriter, r *http.Request) {
-	scheme := "http"
-	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
-		scheme = "https"
-	}
-	host := r.Host
-	manifestURL := fmt.Sprintf("%s://%s/stremio/manifest.json", scheme, host)
-	stremioURL := fmt.Sprintf("stremio://%s/stremio/manifest.json", host)
-
-	html := fmt.Sprintf(`<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>DVR Recordings - Stremio Addon</title>
-    <style>
-        * { box-sizing: border-box; margin: 0; padding: 0; }
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, sans-serif;
-            background: linear-gradient(135deg, #1a1a2e 0%%, #16213e 100%%);
-            min-height: 100vh;
-            display: flex;
-            align-items: center;
-            justify-content: center;
-            color: #fff;
-        }
-        .container {
-            text-align: center;
-            padding: 2rem;
-            max-width: 500px;
-        }
-        .icon {
-            font-size: 4rem;
-            margin-bottom: 1rem;
-        }
-        h1 {
-            font-size: 2rem;
-            margin-bottom: 0.5rem;
-            font-weight: 600;
-        }
-        .subtitle {
-            color: #8892b0;
-            margin-bottom: 2rem;
-            font-size: 1.1rem;
-        }
-        .install-btn {
-            display: inline-block;
-            background: #7b2cbf;
-            color: #fff;
-            padding: 1rem 2.5rem;
-            border-radius: 50px;
-            text-decoration: none;
-            font-size: 1.1rem;
-            font-weight: 500;
-            transition: all 0.3s ease;
-            box-shadow: 0 4px 15px rgba(123, 44, 191, 0.4);
-        }
-        .install-btn:hover {
-            background: #9d4edd;
-            transform: translateY(-2px);
-            box-shadow: 0 6px 20px rgba(123, 44, 191, 0.5);
-        }
-        .manual {
-            margin-top: 2rem;
-            padding-top: 1.5rem;
-            border-top: 1px solid #2a2a4a;
-        }
-        .manual p {
-            color: #8892b0;
-            font-size: 0.9rem;
-            margin-bottom: 0.5rem;
-        }
-        .manifest-url {
-            background: #0d1117;
-            padding: 0.75rem 1rem;
-            border-radius: 8px;
-            font-family: monospace;
-            font-size: 0.85rem;
-            color: #58a6ff;
-            word-break: break-all;
-            cursor: pointer;
-            transition: all 0.2s;
-            position: relative;
-        }
-        .manifest-url:hover {
-            background: #161b22;
-        }
-        .manifest-url.copied {
-            background: #22c55e;
-            color: #fff;
-        }
-        .features {
-            display: flex;
-            justify-content: center;
-            gap: 2rem;
-            margin: 2rem 0;
-            flex-wrap: wrap;
-        }
-        .feature {
-            color: #8892b0;
-            font-size: 0.9rem;
-        }
-        .feature span {
-            display: block;
-            font-size: 1.5rem;
-            margin-bottom: 0.25rem;
-        }
-        .back-link {
-            display: inline-block;
-            margin-top: 2rem;
-            color: #8892b0;
-            text-decoration: none;
-            font-size: 0.9rem;
-        }
-        .back-link:hover {
-            color: #fff;
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="icon">📼</div>
-        <h1>DVR Recordings</h1>
-        <p class="subtitle">Access your MediaProxy DVR recordings in Stremio</p>
-
-        <div class="features">
-            <div class="feature"><span>📺</span>Browse</div>
-            <div class="feature"><span>🔍</span>Search</div>
-            <div class="feature"><span>▶️</span>Play</div>
-        </div>
-
-        <a href="%s" class="install-btn">Install Addon</a>
-
-        <div class="manual">
-            <p>Or copy the manifest URL:</p>
-            <div class="manifest-url" id="manifest-url" onclick="copyManifest()">%s</div>
-        </div>
-
-        <a href="/" class="back-link">← Back to MediaProxy</a>
-    </div>
-    <script>
-        function copyManifest() {
-            const url = '%s';
-            const el = document.getElementById('manifest-url');
-            navigator.clipboard.writeText(url).then(function() {
-                const original = el.textContent;
-                el.textContent = 'Copied!';
-                el.classList.add('copied');
-                setTimeout(function() {
-                    el.textContent = original;
-                    el.classList.remove('copied');
-                }, 1500);
-            });
-        }
-    </script>
-</body>
-</html>`, stremioURL, manifestURL, manifestURL)
-
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Write([]byte(html))
+	page := web.StremioHome{
+		InstallURL:  template.URL(fmt.Sprintf("stremio://%s/stremio/manifest.json", r.Host)),
+		ManifestURL: requestBaseURL(r) + "/stremio/manifest.json",
+	}
+	if err := web.Render(w, "stremio.html", i18n.FromRequest(r, h.ctx.Config.UILanguage), page); err != nil {
+		h.log.Error("failed to render install page", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
 
-// handleManifest returns the Stremio addon manifest.
+// handleManifest returns the Stremio addon manifest with the catalogs of the
+// install's configuration. When the addon requires the API password and the
+// configuration lacks it, no catalogs are offered and Stremio is asked to
+// configure the addon.
 func (h *Handlers) handleManifest(w http.ResponseWriter, r *http.Request) {
-	h.jsonResponse(w, Manifest)
+	if !h.authorized(r) {
+		h.jsonResponse(w, BuildManifest(false, false, true, nil, nil))
+		return
+	}
+
+	config := addonConfig(r)
+	dvrEnabled := h.ctx.RecordingManager != nil && config.catalogEnabled(CatalogRecordings)
+	channelsEnabled := h.ctx.Channels != nil && h.ctx.Channels.Count() > 0 && config.catalogEnabled(CatalogChannels)
+	var groups []string
+	if channelsEnabled {
+		groups = h.ctx.Channels.Groups()
+	}
+	var tags []string
+	if dvrEnabled {
+		tags = h.recordingTags()
+	}
+	h.jsonResponse(w, BuildManifest(dvrEnabled, channelsEnabled, false, tags, groups))
 }
 
-// handleCatalog returns the catalog of DVR recordings.
+// recordingTags returns the tags used by recordings, sorted, each once.
+func (h *Handlers) recordingTags() []string {
+	recordings, err := h.ctx.RecordingManager.ListRecordings()
+	if err != nil {
+		return nil
+	}
+	var tags []string
+	seen := make(map[string]bool)
+	for _, rec := range recordings {
+		for _, tag := range rec.Tags {
+			if key := strings.ToLower(tag); !seen[key] {
+				seen[key] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return strings.ToLower(tags[i]) < strings.ToLower(tags[j])
+	})
+	return tags
+}
+
+// handleCatalog dispatches catalog requests to the recordings or live channels catalog.
 func (h *Handlers) handleCatalog(w http.ResponseWriter, r *http.Request) {
 	catalogType := r.PathValue("type")
-	catalogID := r.PathValue("id")
+	catalogID := strings.TrimSuffix(r.PathValue("id"), ".json")
 
-	// Remove .json suffix if present
-	catalogID = strings.TrimSuffix(catalogID, ".json")
+	// Extra args are sent as an extra path segment (format: <catalog>/search=query&genre=x.json)
+	extra, _ := url.ParseQuery(strings.TrimSuffix(r.PathValue("extra"), ".json"))
 
-	if catalogType != "tv" || !strings.HasPrefix(catalogID, "mediaproxy-dvr-recordings") {
+	config := addonConfig(r)
+	switch {
+	case !h.authorized(r):
+		h.jsonResponse(w, map[string][]Meta{"metas": {}})
+	case catalogType == "tv" && catalogID == RecordingsCatalogID && h.ctx.RecordingManager != nil && config.catalogEnabled(CatalogRecordings):
+		skip, _ := strconv.Atoi(extra.Get("skip"))
+		h.handleRecordingsCatalog(w, extra.Get("search"), extra.Get("genre"), max(skip, 0))
+	case catalogType == "tv" && catalogID == ChannelsCatalogID && h.ctx.Channels != nil && config.catalogEnabled(CatalogChannels):
+		h.handleChannelsCatalog(w, strings.ToLower(extra.Get("search")), extra.Get("genre"))
+	default:
 		h.jsonResponse(w, map[string][]Meta{"metas": {}})
-		return
-	}
-
-	// Extract search query if present (format: mediaproxy-dvr-recordings/search=query.json)
-	searchQuery := ""
-	if idx := strings.Index(catalogID, "/search="); idx != -1 {
-		searchQuery = strings.ToLower(catalogID[idx+8:])
-		if decoded, err := url.QueryUnescape(searchQuery); err == nil {
-			searchQuery = strings.ToLower(decoded)
-		}
 	}
+}
 
-	h.log.Debug("fetching recordings catalog", "search", searchQuery)
+// handleRecordingsCatalog returns a page of the catalog of DVR recordings,
+// optionally filtered by search query and genre: favorites or a recording tag.
+func (h *Handlers) handleRecordingsCatalog(w http.ResponseWriter, searchQuery, genre string, skip int) {
+	h.log.Debug("fetching recordings catalog", "search", searchQuery, "genre", genre, "skip", skip)
 
-	recordings, err := h.ctx.RecordingManager.ListRecordings()
+	// Searched and sorted (newest first) by the recordings store
+	recordings, _, err := h.ctx.RecordingManager.QueryRecordings(types.RecordingQuery{
+		Query: searchQuery,
+		Sort:  types.RecordingSortDate,
+	})
 	if err != nil {
 		h.log.Error("failed to list recordings", "error", err)
 		h.jsonResponse(w, map[string][]Meta{"metas": {}})
 		return
 	}
 
-	h.log.Info("stremio catalog: got recordings from manager", "total_count", len(recordings))
-
 	// Separate active and completed recordings
 	var active []*types.Recording
 	var completed []*types.Recording
 
 	for _, rec := range recordings {
-		h.log.Debug("processing recording",
-			"id", rec.ID,
-			"name", rec.Name,
-			"status", rec.Status,
-			"file_size", rec.FileSize,
-			"file_path", rec.FilePath,
-		)
-
-		// Apply search filter if present
-		if searchQuery != "" {
-			if !strings.Contains(strings.ToLower(rec.Name), searchQuery) {
-				continue
-			}
+		if !matchesGenre(rec, genre) {
+			continue
 		}
 
 		if rec.Status == string(types.RecordingStatusRecording) {
@@ -266,41 +166,42 @@ func (h *Handlers) handleCatalog(w http.ResponseWriter, r *http.Request) {
 			isFinished := rec.Status == string(types.RecordingStatusCompleted) ||
 				rec.Status == "stopped" ||
 				rec.Status == string(types.RecordingStatusFailed)
-			h.log.Debug("recording filter check",
-				"id", rec.ID,
-				"has_valid_file", hasValidFile,
-				"is_finished", isFinished,
-				"will_include", isFinished && hasValidFile,
-			)
 			if isFinished && hasValidFile {
 				completed = append(completed, rec)
 			}
 		}
 	}
 
-	// Sort active by start time (newest first)
-	sort.Slice(active, func(i, j int) bool {
-		return active[i].StartedAt > active[j].StartedAt
-	})
-
-	// Sort completed by date (newest first)
-	sort.Slice(completed, func(i, j int) bool {
-		return completed[i].StartedAt > completed[j].StartedAt
-	})
-
 	// Combine: active first, then completed
 	valid := append(active, completed...)
+	page := valid[min(skip, len(valid)):min(skip+catalogPageSize, len(valid))]
 
-	metas := make([]Meta, len(valid))
-	for i, rec := range valid {
+	metas := make([]Meta, len(page))
+	for i, rec := range page {
 		metas[i] = h.recordingToMeta(rec)
 	}
 
-	h.log.Info("stremio catalog: returning recordings", "active", len(active), "completed", len(completed), "total", len(metas))
+	h.log.Debug("stremio catalog: returning recordings", "active", len(active), "completed", len(completed), "skip", skip, "page", len(metas))
 	h.jsonResponseNoCache(w, map[string][]Meta{"metas": metas})
 }
 
-// handleMeta returns metadata for a specific recording.
+// matchesGenre reports whether a recording belongs to a catalog genre.
+func matchesGenre(rec *types.Recording, genre string) bool {
+	switch genre {
+	case "", AllRecordingsGenre:
+		return true
+	case FavoritesGenre:
+		return rec.Favorite
+	}
+	for _, tag := range rec.Tags {
+		if strings.EqualFold(tag, genre) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleMeta returns metadata for a specific recording or channel.
 func (h *Handlers) handleMeta(w http.ResponseWriter, r *http.Request) {
 	metaType := r.PathValue("type")
 	metaID := r.PathValue("id")
@@ -308,7 +209,17 @@ func (h *Handlers) handleMeta(w http.ResponseWriter, r *http.Request) {
 	// Remove .json suffix if present
 	metaID = strings.TrimSuffix(metaID, ".json")
 
-	if metaType != "tv" || !strings.HasPrefix(metaID, "dvr:") {
+	if !h.authorized(r) {
+		h.jsonResponse(w, map[string]any{"meta": nil})
+		return
+	}
+
+	if metaType == "tv" && strings.HasPrefix(metaID, "live:") && h.ctx.Channels != nil {
+		h.handleChannelMeta(w, strings.TrimPrefix(metaID, "live:"))
+		return
+	}
+
+	if metaType != "tv" || !strings.HasPrefix(metaID, "dvr:") || h.ctx.RecordingManager == nil {
 		h.jsonResponse(w, map[string]any{"meta": nil})
 		return
 	}
@@ -324,7 +235,7 @@ func (h *Handlers) handleMeta(w http.ResponseWriter, r *http.Request) {
 	h.jsonResponse(w, map[string]Meta{"meta": h.recordingToMeta(recording)})
 }
 
-// handleStream returns stream URLs for a recording.
+// handleStream returns stream URLs for a recording or channel.
 func (h *Handlers) handleStream(w http.ResponseWriter, r *http.Request) {
 	streamType := r.PathValue("type")
 	streamID := r.PathValue("id")
@@ -332,7 +243,18 @@ func (h *Handlers) handleStream(w http.ResponseWriter, r *http.Request) {
 	// Remove .json suffix if present
 	streamID = strings.TrimSuffix(streamID, ".json")
 
-	if streamType != "tv" || !strings.HasPrefix(streamID, "dvr:") {
+	if !h.authorized(r) {
+		h.jsonResponse(w, map[string][]Stream{"streams": {}})
+		return
+	}
+
+	baseURL := h.playbackBaseURL(r)
+	if streamType == "tv" && strings.HasPrefix(streamID, "live:") && h.ctx.Channels != nil {
+		h.handleChannelStream(w, r, baseURL, strings.TrimPrefix(streamID, "live:"))
+		return
+	}
+
+	if streamType != "tv" || !strings.HasPrefix(streamID, "dvr:") || h.ctx.RecordingManager == nil {
 		h.jsonResponse(w, map[string][]Stream{"streams": {}})
 		return
 	}
@@ -350,20 +272,43 @@ func (h *Handlers) handleStream(w http.ResponseWriter, r *http.Request) {
 	var streams []Stream
 
 	if recording.Status == string(types.RecordingStatusRecording) {
-		// Active recording: offer Stop & Watch (uses GET endpoint that stops and redirects to stream)
-		stopAndWatchURL := fmt.Sprintf("%s/record/stop/%s", h.ctx.BaseURL, recordingID)
-		streams = append(streams, Stream{URL: stopAndWatchURL, Title: "Stop & Watch"})
+		// Active recording: offer Stop & Watch, which stops it and redirects to the stream
+		if h.canManage(r) {
+			stopAndWatchURL := fmt.Sprintf("%s%s/stop/%s", baseURL, addonPath(r), url.PathEscape(recordingID))
+			streams = append(streams, Stream{URL: stopAndWatchURL, Title: "Stop & Watch"})
+		}
 	} else {
-		// Completed recording: offer Play and Delete
-		streamURL := fmt.Sprintf("%s/api/recordings/%s/stream", h.ctx.BaseURL, recordingID)
-		deleteURL := fmt.Sprintf("%s/api/recordings/%s/delete", h.ctx.BaseURL, recordingID)
-		streams = append(streams, Stream{URL: streamURL, Title: "Play Recording"})
-		streams = append(streams, Stream{URL: deleteURL, Title: "Delete Recording"})
+		// Completed recording: offer Play, and Delete behind a confirmation
+		streamURL := h.signedURL(baseURL, "/api/recordings/"+recordingID+"/stream", nil)
+		streams = append(streams, Stream{URL: streamURL, Title: "Play Recording", Subtitles: h.recordingSubtitles(baseURL, recording)})
+		if h.canManage(r) {
+			streams = append(streams, h.deleteStream(r, recordingID))
+		}
 	}
 
 	h.jsonResponseNoCache(w, map[string][]Stream{"streams": streams})
 }
 
+// recordingSubtitles returns the ready subtitle tracks of a recording.
+func (h *Handlers) recordingSubtitles(baseURL string, rec *types.Recording) []Subtitle {
+	var subtitles []Subtitle
+	for _, sub := range rec.Subtitles {
+		if sub.Status != types.SubtitleStatusReady {
+			continue
+		}
+		lang := sub.Language
+		if lang == "" {
+			lang = "und"
+		}
+		subtitles = append(subtitles, Subtitle{
+			ID:   rec.ID + ":" + sub.ID,
+			URL:  h.signedURL(baseURL, "/api/recordings/"+rec.ID+"/subtitles/"+sub.ID, nil),
+			Lang: lang,
+		})
+	}
+	return subtitles
+}
+
 // recordingToMeta converts a Recording to a Stremio Meta.
 func (h *Handlers) recordingToMeta(rec *types.Recording) Meta {
 	size := formatFileSize(rec.FileSize)
@@ -412,19 +357,68 @@ func (h *Handlers) recordingToMeta(rec *types.Recording) Meta {
 		if len(details) > 0 {
 			description += "\n" + strings.Join(details, " | ")
 		}
+		if markers := chapterMarkers(rec); markers != "" {
+			description += "\n\n" + markers
+		}
 		runtime = duration
 	}
 
+	if rec.Favorite {
+		name = "⭐ " + name
+	}
+	if rec.Description != "" {
+		description = rec.Description + "\n\n" + description
+	}
+
 	return Meta{
 		ID:          "dvr:" + rec.ID,
 		Type:        "tv",
 		Name:        name,
+		Poster:      rec.Poster,
 		Description: description,
 		ReleaseInfo: date,
 		Runtime:     runtime,
+		Genres:      rec.Tags,
 	}
 }
 
+// maxChapterMarkers bounds the filler chapters listed in a description.
+const maxChapterMarkers = 20
+
+// chapterMarkers lists the filler chapters of a recording (breaks, black
+// screens, silence) with their positions, so they can be skipped with the
+// player's seek bar. Empty when none were detected.
+func chapterMarkers(rec *types.Recording) string {
+	if rec.Chapters == nil || rec.Chapters.Status != types.ChaptersStatusReady {
+		return ""
+	}
+	var lines []string
+	skipped := 0
+	for _, chapter := range rec.Chapters.Chapters {
+		if chapter.Kind == types.ChapterProgram {
+			continue
+		}
+		if len(lines) == maxChapterMarkers {
+			skipped++
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s–%s %s", formatPosition(chapter.Start), formatPosition(chapter.End), chapter.Title))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	if skipped > 0 {
+		lines = append(lines, fmt.Sprintf("…and %d more", skipped))
+	}
+	return "Skip markers:\n" + strings.Join(lines, "\n")
+}
+
+// formatPosition formats seconds as a position in a recording, h:mm:ss.
+func formatPosition(seconds float64) string {
+	s := int(seconds)
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
 // formatDuration formats seconds as human readable duration.
 func formatDuration(seconds float64) string {
 	if seconds <= 0 {
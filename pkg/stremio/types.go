@@ -1,48 +1,117 @@
-// Package stremio provides a Stremio addon for DVR recordings.
+// Package stremio provides a Stremio addon for DVR recordings and live channels.
 package stremio
 
-// Manifest is the Stremio addon manifest.
-var Manifest = map[string]interface{}{
-	"id":          "org.stremio.mediaproxy-dvr",
-	"version":     "1.0.0",
-	"name":        "MediaProxy DVR",
-	"description": "DVR recordings from MediaProxy",
-	"resources":   []string{"catalog", "stream", "meta"},
-	"types":       []string{"tv"},
-	"catalogs": []map[string]interface{}{
-		{
+// Catalog IDs served by the addon.
+const (
+	RecordingsCatalogID = "mediaproxy-dvr-recordings"
+	ChannelsCatalogID   = "mediaproxy-live-channels"
+)
+
+// catalogPageSize is the number of recordings per catalog page; Stremio
+// requests the next page with skip once it receives a full one.
+const catalogPageSize = 100
+
+// Genre filters of the recordings catalog besides the recording tags.
+const (
+	AllRecordingsGenre = "All Recordings"
+	FavoritesGenre     = "Favorites"
+)
+
+// BuildManifest returns the Stremio addon manifest. The recordings catalog is
+// included when DVR is enabled, with favorites and recording tags offered as
+// genre filters, and the live channels catalog when a channel list is loaded,
+// with its groups offered as genre filters. The addon is configurable through
+// /configure; configurationRequired asks Stremio to configure it before use.
+func BuildManifest(dvrEnabled, channelsEnabled, configurationRequired bool, recordingTags, channelGroups []string) map[string]interface{} {
+	catalogs := []map[string]interface{}{}
+	idPrefixes := []string{}
+
+	if dvrEnabled {
+		catalogs = append(catalogs, map[string]interface{}{
 			"type": "tv",
-			"id":   "mediaproxy-dvr-recordings",
+			"id":   RecordingsCatalogID,
 			"name": "MediaProxy Recordings",
 			"extra": []map[string]interface{}{
 				{
 					"name":       "genre",
 					"isRequired": false,
-					"options":    []string{"All Recordings"},
+					"options":    append([]string{AllRecordingsGenre, FavoritesGenre}, recordingTags...),
 				},
 				{
 					"name":       "search",
 					"isRequired": false,
 				},
+				{
+					"name":       "skip",
+					"isRequired": false,
+				},
+			},
+		})
+		idPrefixes = append(idPrefixes, "dvr:")
+	}
+
+	if channelsEnabled {
+		extra := []map[string]interface{}{
+			{
+				"name":       "search",
+				"isRequired": false,
 			},
+		}
+		if len(channelGroups) > 0 {
+			extra = append(extra, map[string]interface{}{
+				"name":       "genre",
+				"isRequired": false,
+				"options":    channelGroups,
+			})
+		}
+		catalogs = append(catalogs, map[string]interface{}{
+			"type":  "tv",
+			"id":    ChannelsCatalogID,
+			"name":  "MediaProxy Live",
+			"extra": extra,
+		})
+		idPrefixes = append(idPrefixes, "live:")
+	}
+
+	return map[string]interface{}{
+		"id":          "org.stremio.mediaproxy-dvr",
+		"version":     "1.1.0",
+		"name":        "MediaProxy DVR",
+		"description": "Live channels and DVR recordings from MediaProxy",
+		"resources":   []string{"catalog", "stream", "meta"},
+		"types":       []string{"tv"},
+		"catalogs":    catalogs,
+		"idPrefixes":  idPrefixes,
+		"behaviorHints": map[string]interface{}{
+			"configurable":          true,
+			"configurationRequired": configurationRequired,
 		},
-	},
-	"idPrefixes": []string{"dvr:"},
+	}
 }
 
 // Meta represents a Stremio catalog item.
 type Meta struct {
-	ID          string `json:"id"`
-	Type        string `json:"type"`
-	Name        string `json:"name"`
-	Poster      string `json:"poster,omitempty"`
-	Description string `json:"description,omitempty"`
-	ReleaseInfo string `json:"releaseInfo,omitempty"`
-	Runtime     string `json:"runtime,omitempty"`
+	ID          string   `json:"id"`
+	Type        string   `json:"type"`
+	Name        string   `json:"name"`
+	Poster      string   `json:"poster,omitempty"`
+	Description string   `json:"description,omitempty"`
+	ReleaseInfo string   `json:"releaseInfo,omitempty"`
+	Runtime     string   `json:"runtime,omitempty"`
+	Genres      []string `json:"genres,omitempty"`
+	PosterShape string   `json:"posterShape,omitempty"`
 }
 
 // Stream represents a Stremio stream item.
 type Stream struct {
-	URL   string `json:"url"`
-	Title string `json:"title"`
+	URL       string     `json:"url"`
+	Title     string     `json:"title"`
+	Subtitles []Subtitle `json:"subtitles,omitempty"`
+}
+
+// Subtitle is an external subtitle track of a stream.
+type Subtitle struct {
+	ID   string `json:"id"`
+	URL  string `json:"url"`
+	Lang string `json:"lang"`
 }
@@ -0,0 +1,72 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+
+	"media-proxy-go/pkg/types"
+)
+
+// maxIdleKeys bounds the limiters a Group keeps; beyond it the idle ones
+// are dropped.
+const maxIdleKeys = 1024
+
+// Group paces requests by key (e.g. host), each key with its own limiter
+// enforcing the same Limit. A nil Group lets everything through.
+type Group struct {
+	limit Limit
+
+	mu    sync.Mutex
+	byKey map[string]*Limiter
+}
+
+// NewGroup returns a group enforcing limit per key, or nil when it paces
+// nothing.
+func NewGroup(limit Limit) *Group {
+	if limit.IsZero() {
+		return nil
+	}
+	return &Group{limit: limit, byKey: make(map[string]*Limiter)}
+}
+
+// Acquire waits for the turn of a request of key, like Limiter.Acquire.
+func (g *Group) Acquire(ctx context.Context, key string) (release func(), err error) {
+	if g == nil {
+		return func() {}, nil
+	}
+	g.mu.Lock()
+	l, ok := g.byKey[key]
+	if !ok {
+		if len(g.byKey) >= maxIdleKeys {
+			g.prune()
+		}
+		l = New(g.limit)
+		g.byKey[key] = l
+	}
+	g.mu.Unlock()
+	return l.Acquire(ctx)
+}
+
+// prune drops the limiters with no request running, queued or still
+// spacing the next one. Caller must hold g.mu.
+func (g *Group) prune() {
+	for key, l := range g.byKey {
+		if l.idle() {
+			delete(g.byKey, key)
+		}
+	}
+}
+
+// Stats returns the counters of each key's limiter.
+func (g *Group) Stats() map[string]types.ThrottleStats {
+	stats := make(map[string]types.ThrottleStats)
+	if g == nil {
+		return stats
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for key, l := range g.byKey {
+		stats[key] = l.Stats()
+	}
+	return stats
+}
@@ -0,0 +1,176 @@
+// Package throttle paces requests to a site: a cap on how many run at once,
+// a rate at which they start, and random jitter so throttled requests don't
+// leave in lockstep. Sites ban IPs hammering them with parallel requests.
+package throttle
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"media-proxy-go/pkg/types"
+)
+
+// Limit describes how requests of one kind are paced. The zero Limit lets
+// everything through.
+type Limit struct {
+	Concurrency int           // Requests at a time, 0 for no cap
+	Rate        float64       // Requests started per second, 0 for no limit
+	Jitter      time.Duration // Up to this much random delay added to throttled requests
+}
+
+// IsZero reports whether l paces nothing.
+func (l Limit) IsZero() bool {
+	return l.Concurrency <= 0 && l.Rate <= 0
+}
+
+// Limiter enforces a Limit. Requests over the concurrency cap queue until a
+// slot frees up or their context ends. A nil Limiter lets everything through.
+type Limiter struct {
+	limit Limit
+	slots chan struct{} // Nil without a concurrency cap
+
+	mu        sync.Mutex
+	next      time.Time // Earliest start of the next request under the rate
+	active    int
+	queued    int
+	started   uint64
+	throttled uint64
+	abandoned uint64
+	waitTotal time.Duration
+}
+
+// New returns a limiter enforcing limit, or nil when it paces nothing.
+func New(limit Limit) *Limiter {
+	if limit.IsZero() {
+		return nil
+	}
+	l := &Limiter{limit: limit}
+	if limit.Concurrency > 0 {
+		l.slots = make(chan struct{}, limit.Concurrency)
+	}
+	return l
+}
+
+// Acquire waits for the request's turn, until ctx ends. The returned release
+// function must be called once the request is done.
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	start := time.Now()
+	l.mu.Lock()
+	l.queued++
+	l.mu.Unlock()
+
+	throttled := false
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			throttled = true
+			select {
+			case l.slots <- struct{}{}:
+			case <-ctx.Done():
+				l.abandon()
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	delay := l.reserve(start)
+	throttled = throttled || delay > 0
+	if throttled && l.limit.Jitter > 0 {
+		delay += rand.N(l.limit.Jitter)
+	}
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			l.freeSlot()
+			l.abandon()
+			return nil, ctx.Err()
+		}
+	}
+
+	l.mu.Lock()
+	l.queued--
+	l.active++
+	l.started++
+	if throttled {
+		l.throttled++
+		l.waitTotal += time.Since(start)
+	}
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.active--
+			l.mu.Unlock()
+			l.freeSlot()
+		})
+	}, nil
+}
+
+// reserve takes the next start time under the rate and returns how long
+// until it.
+func (l *Limiter) reserve(now time.Time) time.Duration {
+	if l.limit.Rate <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(time.Duration(float64(time.Second) / l.limit.Rate))
+	return at.Sub(now)
+}
+
+// freeSlot hands back a concurrency slot.
+func (l *Limiter) freeSlot() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// abandon counts a request that gave up waiting.
+func (l *Limiter) abandon() {
+	l.mu.Lock()
+	l.queued--
+	l.abandoned++
+	l.mu.Unlock()
+}
+
+// idle reports whether no request is running or queued and the rate no
+// longer delays the next one.
+func (l *Limiter) idle() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active == 0 && l.queued == 0 && !l.next.After(time.Now())
+}
+
+// Stats returns the limiter's occupancy and counters.
+func (l *Limiter) Stats() types.ThrottleStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := types.ThrottleStats{
+		Concurrency: l.limit.Concurrency,
+		Rate:        l.limit.Rate,
+		Active:      l.active,
+		Queued:      l.queued,
+		Started:     l.started,
+		Throttled:   l.throttled,
+		Abandoned:   l.abandoned,
+	}
+	if l.throttled > 0 {
+		stats.AvgWaitMs = float64(l.waitTotal.Microseconds()) / 1000 / float64(l.throttled)
+	}
+	return stats
+}
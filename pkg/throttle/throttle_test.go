@@ -0,0 +1,117 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Concurrency(t *testing.T) {
+	l := New(Limit{Concurrency: 2})
+	r1, _ := l.Acquire(context.Background())
+	r2, _ := l.Acquire(context.Background())
+
+	acquired := make(chan func())
+	go func() {
+		r, _ := l.Acquire(context.Background())
+		acquired <- r
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("third request started over the cap")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if s := l.Stats(); s.Active != 2 || s.Queued != 1 {
+		t.Errorf("Stats() = %+v, want 2 active and 1 queued", s)
+	}
+
+	r1()
+	r1() // Releasing twice frees one slot
+	r3 := <-acquired
+	if s := l.Stats(); s.Active != 2 || s.Queued != 0 || s.Started != 3 || s.Throttled != 1 {
+		t.Errorf("Stats() = %+v after a release", s)
+	}
+	r2()
+	r3()
+
+	// Requests whose context ends while queued give up
+	r1, _ = l.Acquire(context.Background())
+	r2, _ = l.Acquire(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire() error = %v, want the context's", err)
+	}
+	if s := l.Stats(); s.Abandoned != 1 || s.Queued != 0 {
+		t.Errorf("Stats() = %+v, want 1 abandoned", s)
+	}
+	r1()
+	r2()
+}
+
+func TestLimiter_Rate(t *testing.T) {
+	l := New(Limit{Rate: 50, Jitter: 5 * time.Millisecond}) // One start every 20ms
+	start := time.Now()
+	for range 4 {
+		release, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("4 requests at 50/s took %v, want at least 60ms", elapsed)
+	}
+	if s := l.Stats(); s.Throttled != 3 || s.AvgWaitMs <= 0 {
+		t.Errorf("Stats() = %+v, want the last 3 throttled", s)
+	}
+}
+
+func TestLimiter_Nil(t *testing.T) {
+	l := New(Limit{Jitter: time.Second})
+	if l != nil {
+		t.Fatal("New() of a limit without cap or rate returned a limiter")
+	}
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
+
+func TestGroup(t *testing.T) {
+	g := NewGroup(Limit{Concurrency: 1})
+	a, _ := g.Acquire(context.Background(), "a.example")
+
+	// Keys are paced separately
+	b, err := g.Acquire(context.Background(), "b.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := g.Acquire(ctx, "a.example"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire() error = %v, want a.example at its cap", err)
+	}
+	a()
+	b()
+
+	stats := g.Stats()
+	if len(stats) != 2 || stats["a.example"].Abandoned != 1 || stats["b.example"].Started != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	// Idle keys are dropped once the group is full
+	for i := range maxIdleKeys {
+		release, _ := g.Acquire(context.Background(), string(rune('A'+i)))
+		release()
+	}
+	if n := len(g.Stats()); n > maxIdleKeys {
+		t.Errorf("%d keys kept, want at most %d", n, maxIdleKeys)
+	}
+
+	if NewGroup(Limit{}) != nil {
+		t.Error("NewGroup() of a zero limit returned a group")
+	}
+}
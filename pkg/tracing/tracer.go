@@ -0,0 +1,282 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/logging"
+)
+
+const (
+	// exportInterval is how often finished spans are sent to the collector.
+	exportInterval = 5 * time.Second
+	// exportBatchSize sends spans early once this many are queued.
+	exportBatchSize = 512
+	// maxQueuedSpans bounds memory while the collector is unreachable; newer
+	// spans are dropped until the queue drains.
+	maxQueuedSpans = 8192
+)
+
+// Tracer starts traces for incoming requests and exports finished spans.
+type Tracer struct {
+	endpoint    string // OTLP traces URL
+	headers     map[string]string
+	service     string
+	samplePct   int
+	client      *http.Client
+	log         *logging.Logger
+	flushSignal chan struct{}
+
+	mu      sync.Mutex
+	spans   []*Span
+	dropped int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a tracer exporting to the collector at cfg.OTLPEndpoint.
+// Call Start to begin exporting.
+func New(cfg *config.Config, log *logging.Logger) *Tracer {
+	endpoint := strings.TrimSuffix(cfg.OTLPEndpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+
+	headers := make(map[string]string)
+	for _, h := range cfg.OTLPHeaders {
+		if name, value, ok := strings.Cut(h, "="); ok {
+			headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Tracer{
+		endpoint:    endpoint,
+		headers:     headers,
+		service:     cfg.TraceServiceName,
+		samplePct:   cfg.TraceSamplePercent,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		log:         log.WithComponent("tracing"),
+		flushSignal: make(chan struct{}, 1),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+// Start begins exporting spans in the background.
+func (t *Tracer) Start() {
+	t.wg.Add(1)
+	go t.loop()
+}
+
+// Close stops the exporter after sending the spans still queued.
+func (t *Tracer) Close() {
+	t.cancel()
+	t.wg.Wait()
+}
+
+func (t *Tracer) loop() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			t.flush()
+			return
+		case <-ticker.C:
+		case <-t.flushSignal:
+		}
+		t.flush()
+	}
+}
+
+// queue adds a finished span to the next export.
+func (t *Tracer) queue(s *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.spans) >= maxQueuedSpans {
+		t.dropped++
+		return
+	}
+	t.spans = append(t.spans, s)
+	if len(t.spans) == exportBatchSize {
+		select {
+		case t.flushSignal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// flush exports the queued spans in batches.
+func (t *Tracer) flush() {
+	t.mu.Lock()
+	spans, dropped := t.spans, t.dropped
+	t.spans, t.dropped = nil, 0
+	t.mu.Unlock()
+
+	if dropped > 0 {
+		t.log.Warn("trace export queue full, spans dropped", "dropped", dropped)
+	}
+	for len(spans) > 0 {
+		n := min(len(spans), exportBatchSize)
+		if err := t.export(spans[:n]); err != nil {
+			t.log.Warn("failed to export traces", "endpoint", t.endpoint, "spans", n, "error", err)
+		}
+		spans = spans[n:]
+	}
+}
+
+// export sends spans to the collector.
+func (t *Tracer) export(spans []*Span) error {
+	body, err := json.Marshal(t.encode(spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	// Export even while shutting down, so the last spans are not lost
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(t.ctx), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Middleware starts a server span for each sampled request and puts it in the
+// request context. An incoming W3C traceparent header continues the caller's
+// trace. The trace ID is returned in the X-Trace-ID response header.
+func (t *Tracer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := t.startRequest(r)
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer span.End()
+
+		w.Header().Set("X-Trace-ID", span.TraceID())
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		r = r.WithContext(context.WithValue(r.Context(), spanKey{}, span))
+		next.ServeHTTP(sw, r)
+
+		// The mux sets the matched pattern while routing
+		if r.Pattern != "" {
+			span.name = r.Pattern
+			if !strings.HasPrefix(r.Pattern, r.Method+" ") {
+				span.name = r.Method + " " + r.Pattern
+			}
+		}
+		span.SetAttr("http.response.status_code", sw.status)
+		if sw.status >= 500 {
+			span.setFailed(http.StatusText(sw.status))
+		}
+	})
+}
+
+// startRequest starts the server span of a request, or returns nil if the
+// request is not sampled.
+func (t *Tracer) startRequest(r *http.Request) *Span {
+	span := &Span{
+		tracer: t,
+		id:     newSpanID(),
+		name:   r.Method + " " + r.URL.Path,
+		kind:   KindServer,
+		start:  time.Now(),
+	}
+
+	if id, parent, sampled, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+		if !sampled {
+			return nil
+		}
+		span.traceID, span.parent = id, parent
+	} else {
+		if t.samplePct < 100 && rand.IntN(100) >= t.samplePct {
+			return nil
+		}
+		span.traceID = newTraceID()
+	}
+
+	span.attrs = []attribute{
+		{"http.request.method", r.Method},
+		{"url.path", r.URL.Path},
+		{"client.address", r.RemoteAddr},
+	}
+	return span
+}
+
+// parseTraceparent parses a W3C traceparent header: version-traceid-parentid-flags.
+func parseTraceparent(h string) (traceID, spanID, bool, bool) {
+	var id traceID
+	var parent spanID
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return id, parent, false, false
+	}
+	if n, err := hex.Decode(id[:], []byte(parts[1])); err != nil || n != len(id) || id == (traceID{}) {
+		return id, parent, false, false
+	}
+	if n, err := hex.Decode(parent[:], []byte(parts[2])); err != nil || n != len(parent) || parent == (spanID{}) {
+		return id, parent, false, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil || len(parts[3]) != 2 {
+		return id, parent, false, false
+	}
+	return id, parent, flags&1 == 1, true
+}
+
+// statusWriter records the response status for the server span.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher for streaming responses.
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
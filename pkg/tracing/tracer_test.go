@@ -0,0 +1,154 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"media-proxy-go/pkg/config"
+	"media-proxy-go/pkg/logging"
+)
+
+// collector is a fake OTLP/HTTP collector.
+type collector struct {
+	mu    sync.Mutex
+	spans map[string]otlpSpan // By name
+	auth  string
+}
+
+func newCollector(t *testing.T) (*collector, *httptest.Server) {
+	c := &collector{spans: make(map[string]otlpSpan)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("export to %s (%s)", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode export: %v", err)
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.auth = r.Header.Get("Authorization")
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					c.spans[s.Name] = s
+				}
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return c, server
+}
+
+func TestTracer_Middleware(t *testing.T) {
+	c, server := newCollector(t)
+	tracer := New(&config.Config{
+		OTLPEndpoint:       server.URL,
+		OTLPHeaders:        []string{"Authorization=Bearer secret"},
+		TraceServiceName:   "test",
+		TraceSamplePercent: 100,
+	}, logging.New("error", false, io.Discard))
+	tracer.Start()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /segment/{name}", func(w http.ResponseWriter, r *http.Request) {
+		_, fetch := StartClient(r.Context(), "GET cdn.example.com")
+		fetch.AddEvent("response headers")
+		fetch.SetAttr("http.response.status_code", 200)
+		fetch.End()
+
+		_, decrypt := Start(r.Context(), "decrypt")
+		decrypt.SetError(errors.New("bad key"))
+		decrypt.End()
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	handler := tracer.Middleware(mux)
+
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/segment/1.ts", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(rec, r)
+
+	if got := rec.Header().Get("X-Trace-ID"); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("X-Trace-ID = %q, want the incoming trace", got)
+	}
+
+	tracer.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", c.auth)
+	}
+	root, ok := c.spans["GET /segment/{name}"]
+	if !ok {
+		t.Fatalf("no server span named by route, got %v", c.spans)
+	}
+	if root.ParentSpanID != "00f067aa0ba902b7" || root.Kind != KindServer || root.Status.Code != 2 {
+		t.Errorf("server span = %+v", root)
+	}
+	fetch, decrypt := c.spans["GET cdn.example.com"], c.spans["decrypt"]
+	if fetch.ParentSpanID != root.SpanID || fetch.Kind != KindClient || len(fetch.Events) != 1 {
+		t.Errorf("client span = %+v", fetch)
+	}
+	if decrypt.ParentSpanID != root.SpanID || decrypt.TraceID != root.TraceID || decrypt.Status.Message != "bad key" {
+		t.Errorf("decrypt span = %+v", decrypt)
+	}
+}
+
+func TestTracer_Sampling(t *testing.T) {
+	tracer := New(&config.Config{OTLPEndpoint: "http://127.0.0.1:1"}, logging.New("error", false, io.Discard))
+
+	var traced bool
+	handler := tracer.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traced = FromContext(r.Context()) != nil
+	}))
+
+	// 0% sampling, and a caller that did not sample its trace
+	for _, traceparent := range []string{"", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"} {
+		r := httptest.NewRequest(http.MethodGet, "/proxy/stream", nil)
+		if traceparent != "" {
+			r.Header.Set("traceparent", traceparent)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		if traced {
+			t.Errorf("traceparent %q: request was traced", traceparent)
+		}
+	}
+
+	// Spans are never started without a trace in the context
+	if _, span := Start(t.Context(), "decrypt"); span != nil {
+		t.Error("Start() without a trace returned a span")
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		header      string
+		wantSampled bool
+		wantOK      bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false, true},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", false, false},
+		{"garbage", false, false},
+		{"", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			_, _, sampled, ok := parseTraceparent(tt.header)
+			if sampled != tt.wantSampled || ok != tt.wantOK {
+				t.Errorf("parseTraceparent() = %v, %v, want %v, %v", sampled, ok, tt.wantSampled, tt.wantOK)
+			}
+		})
+	}
+}
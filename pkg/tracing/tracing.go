@@ -0,0 +1,172 @@
+// Package tracing records request traces and exports them to an OpenTelemetry
+// collector over OTLP/HTTP, so a slow segment can be broken down by phase:
+// extraction, upstream fetches, decryption and FFmpeg remux.
+//
+// Spans travel in the request context. A trace starts when a request enters
+// the server (Tracer.Middleware); Start only creates a child span when the
+// context already carries one, so code doing traced work needs no tracer and
+// untraced work (recordings, background checks) records nothing.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Kind is the role of a span in a trace.
+type Kind int
+
+// Span kinds, numbered as in OTLP.
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+type (
+	traceID [16]byte
+	spanID  [8]byte
+)
+
+// Span is a timed operation in a trace. A nil *Span is valid and records
+// nothing, so callers never need to check whether tracing is enabled.
+type Span struct {
+	tracer  *Tracer
+	traceID traceID
+	id      spanID
+	parent  spanID
+	name    string
+	kind    Kind
+	start   time.Time
+
+	mu     sync.Mutex
+	end    time.Time
+	attrs  []attribute
+	events []event
+	err    string
+	failed bool
+}
+
+type attribute struct {
+	key   string
+	value any // string, int, int64, bool or float64
+}
+
+type event struct {
+	name string
+	at   time.Time
+}
+
+type spanKey struct{}
+
+// Start starts a child span of the span in ctx, if any.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return start(ctx, name, KindInternal)
+}
+
+// StartClient starts a child span for a request to an upstream server.
+func StartClient(ctx context.Context, name string) (context.Context, *Span) {
+	return start(ctx, name, KindClient)
+}
+
+func start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	span := &Span{
+		tracer:  parent.tracer,
+		traceID: parent.traceID,
+		id:      newSpanID(),
+		parent:  parent.id,
+		name:    name,
+		kind:    kind,
+		start:   time.Now(),
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// FromContext returns the span in ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// TraceID returns the span's trace ID in hex, or "" for a nil span.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// SetAttr records an attribute. value is a string, int, int64, bool or float64.
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attribute{key, value})
+}
+
+// AddEvent records a point in time within the span, e.g. the first response byte.
+func (s *Span) AddEvent(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event{name, time.Now()})
+}
+
+// SetError marks the span failed. A nil err is ignored; context cancellation
+// by the client is not a failure.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = true
+	s.err = err.Error()
+}
+
+// setFailed marks the span failed with a message.
+func (s *Span) setFailed(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = true
+	s.err = message
+}
+
+// End finishes the span and queues it for export. Later calls are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.queue(s)
+}
+
+func newTraceID() traceID {
+	var id traceID
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() spanID {
+	var id spanID
+	rand.Read(id[:])
+	return id
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"net/url"
 )
 
 // StreamType identifies the type of stream being handled.
@@ -27,7 +28,148 @@ type StreamRequest struct {
 	Force          bool
 	Extension      string
 	RepID          string
-	NoBypass       bool // Force all segments through proxy (for recordings)
+	AudioRepID     string            // Audio representation muxed into RepID's segments
+	IFrames        bool              // I-frame playlist of RepID instead of its media playlist
+	Muxed          bool              // Convert DASH to a single muxed audio+video variant
+	NoBypass       bool              // Force all segments through proxy (for recordings)
+	VOD            bool              // Segment of a VOD playlist, eligible for the segment cache
+	Directives     url.Values        // LL-HLS delivery directives (_HLS_msn, _HLS_part, _HLS_skip) for the upstream playlist
+	Filter         *VariantFilter    // Renditions to keep in an HLS master playlist, nil keeps all
+	InspectDRM     bool              // Return the manifest's DRM info as JSON instead of a playlist
+	Validators     map[string]string // Client If-None-Match/If-Modified-Since, sent with the manifest fetch only
+	Prefetched     *http.Response    // Upstream manifest already fetched (see StreamResponse.Upstream), rewritten instead of fetching again
+}
+
+// DRMInfo describes the content protection signalled in a manifest.
+type DRMInfo struct {
+	KIDs    []string `json:"kids"`           // Key IDs, 32 lowercase hex digits
+	Systems []string `json:"systems"`        // DRM systems (widevine, playready, clearkey)
+	PSSH    []string `json:"pssh,omitempty"` // Base64 pssh boxes
+	HasKeys bool     `json:"has_keys"`       // Keys are known for all KIDs
+}
+
+// ProbeResult describes a stream's tracks, as reported by /api/probe.
+type ProbeResult struct {
+	URL       string       `json:"url"`
+	Type      StreamType   `json:"type"`
+	Format    string       `json:"format,omitempty"`    // Container reported by ffprobe, e.g. "hls" or "mpegts"
+	Duration  float64      `json:"duration,omitempty"`  // Seconds, 0 for live streams
+	Bandwidth int64        `json:"bandwidth,omitempty"` // Estimated bits/s
+	Tracks    []ProbeTrack `json:"tracks"`
+	DRM       *DRMInfo     `json:"drm,omitempty"` // Set when the manifest signals encryption
+}
+
+// EgressInfo describes a path upstream requests leave through, as reported
+// by /proxy/ip.
+type EgressInfo struct {
+	Proxy   string   `json:"proxy"`             // Proxy URL with the password masked, "direct" without one
+	Default bool     `json:"default,omitempty"` // Used by requests no transport route matches
+	Routes  []string `json:"routes,omitempty"`  // URL patterns of the transport routes using it
+	IP      string   `json:"ip,omitempty"`
+	Country string   `json:"country,omitempty"` // ISO 3166-1 alpha-2 code
+	City    string   `json:"city,omitempty"`
+	Org     string   `json:"org,omitempty"` // ISP or AS name
+	Error   string   `json:"error,omitempty"`
+}
+
+// RouteTest is the evaluation of the transport routes for a URL, as reported
+// by /api/routes/test.
+type RouteTest struct {
+	URL       string            `json:"url"`
+	Transport string            `json:"transport"`                // "utls", "route", "global" or "direct"
+	Proxy     string            `json:"proxy,omitempty"`          // Proxy URL with the password masked
+	Insecure  bool              `json:"insecure,omitempty"`       // TLS verification disabled
+	Route     *RouteTestResult  `json:"route,omitempty"`          // Route deciding the transport
+	Redirect  *RouteTestResult  `json:"redirect_route,omitempty"` // Route deciding the redirect policy
+	Headers   map[string]string `json:"headers,omitempty"`        // Default headers added, credentials masked
+	Routes    []RouteTestResult `json:"routes"`                   // All routes, in evaluation order
+}
+
+// RouteTestResult is a transport route and whether it matched the URL.
+type RouteTestResult struct {
+	Index    int    `json:"index"` // Position in evaluation order
+	Pattern  string `json:"pattern"`
+	Match    string `json:"match"`
+	Priority int    `json:"priority"`
+	Matched  bool   `json:"matched"`
+}
+
+// ProbeTrack is a video, audio or subtitle track found by /api/probe.
+type ProbeTrack struct {
+	Type       string `json:"type"` // "video", "audio" or "subtitle"
+	Codec      string `json:"codec"`
+	Profile    string `json:"profile,omitempty"`
+	Width      int    `json:"width,omitempty"`
+	Height     int    `json:"height,omitempty"`
+	FrameRate  string `json:"frame_rate,omitempty"`
+	Bitrate    int64  `json:"bitrate,omitempty"`
+	Channels   int    `json:"channels,omitempty"`
+	SampleRate int    `json:"sample_rate,omitempty"`
+	Language   string `json:"language,omitempty"`
+}
+
+// VariantFilter selects the renditions kept in an HLS master playlist.
+type VariantFilter struct {
+	MaxWidth      int      // Drop variants wider than this (0 = no limit)
+	MaxHeight     int      // Drop variants taller than this (0 = no limit)
+	MinBandwidth  int      // Drop variants below this BANDWIDTH in bits/s
+	MaxBandwidth  int      // Drop variants above this BANDWIDTH in bits/s (0 = no limit)
+	AudioLangs    []string // Keep only audio renditions in these languages
+	AudioCodecs   []string // MPD: keep only audio in these codecs ("aac", "ec-3", "mp4a.40.2")
+	PreferEC3     bool     // MPD: make an E-AC-3 track the default audio
+	DropSubtitles bool     // Remove subtitle renditions
+	AudioOnly     bool     // Keep only audio (radio, background listening)
+	Pin           string   // PinBest or PinWorst: keep a single variant, empty keeps all
+	Device        string   // Player the output is tailored to, e.g. DeviceChromecast
+}
+
+// DeviceChromecast tailors playlists to the Chromecast default receiver:
+// H.264/AAC variants only, no I-frame playlists or orphaned renditions.
+const DeviceChromecast = "chromecast"
+
+// Variant pins of a VariantFilter.
+const (
+	PinBest  = "best"  // Highest BANDWIDTH of the variants the limits leave
+	PinWorst = "worst" // Lowest BANDWIDTH of the variants the limits leave
+)
+
+// TranscodeProfile is a named set of FFmpeg encoding settings for the transcoder.
+type TranscodeProfile struct {
+	Name         string `json:"name"`
+	Height       int    `json:"height,omitempty"`        // Output height, 0 keeps the source resolution
+	VideoCodec   string `json:"video_codec"`             // Software encoder (libx264), "copy", or "none" to drop video
+	VideoBitrate string `json:"video_bitrate,omitempty"` // e.g. "2500k", empty for the encoder default
+	Preset       string `json:"preset,omitempty"`        // Encoder preset, e.g. "ultrafast"
+	VideoProfile string `json:"video_profile,omitempty"` // H.264 profile, e.g. "baseline"
+	AudioCodec   string `json:"audio_codec"`             // e.g. "aac" or "copy"
+	AudioBitrate string `json:"audio_bitrate,omitempty"`
+	HWAccel      string `json:"hwaccel,omitempty"` // vaapi, cuda, qsv or videotoolbox; empty encodes in software
+	Device       string `json:"device,omitempty"`  // Hardware device, e.g. /dev/dri/renderD128 for vaapi
+}
+
+// HWAccelInfo reports the hardware acceleration available to FFmpeg.
+type HWAccelInfo struct {
+	Available  []string `json:"available"`            // Methods listed by ffmpeg -hwaccels
+	Preference string   `json:"preference,omitempty"` // FFMPEG_HWACCEL
+	Selected   string   `json:"selected,omitempty"`   // Used by software profiles; empty encodes in software
+}
+
+// Features that depend on FFmpeg, as keys of FFmpegInfo.Features.
+const (
+	FeatureDecryptRemux = "decrypt_remux" // DASH segments served as MPEG-TS
+	FeatureRecording    = "recording"     // DVR
+	FeatureTranscode    = "transcode"     // /transcode HLS output
+	FeatureHDHomeRun    = "hdhomerun"     // Tuner streams
+)
+
+// FFmpegInfo reports the FFmpeg build found at startup.
+type FFmpegInfo struct {
+	Path      string          `json:"path"`              // FFMPEG_PATH
+	Available bool            `json:"available"`         // Found, runnable and new enough
+	Version   string          `json:"version,omitempty"` // From ffmpeg -version, e.g. "6.1.1"
+	Missing   []string        `json:"missing,omitempty"` // Required components the build lacks, e.g. "muxer:hls"
+	Error     string          `json:"error,omitempty"`   // Why FFmpeg is unavailable
+	Features  map[string]bool `json:"features"`          // Feature* name -> usable with this build
 }
 
 // StreamResponse represents the result of stream processing.
@@ -36,7 +178,9 @@ type StreamResponse struct {
 	Headers     map[string]string
 	Body        io.ReadCloser
 	StatusCode  int
-	RedirectURL string // If non-empty, perform redirect instead
+	RedirectURL string         // If non-empty, perform redirect instead
+	Detected    StreamType     // Manifest type the generic handler sniffed from the body, if any
+	Upstream    *http.Response // Upstream response of a sniffed manifest, with Body as its body
 }
 
 // ExtractResult contains the result of URL extraction.
@@ -47,6 +191,7 @@ type ExtractResult struct {
 	MediaflowEndpoint string            `json:"mediaflow_endpoint"`
 	MediaflowProxyURL string            `json:"mediaflow_proxy_url,omitempty"`
 	QueryParams       map[string]string `json:"query_params,omitempty"`
+	ExpiresAt         int64             `json:"expires_at,omitempty"` // Unix time the stream token expires, 0 if unknown
 }
 
 // ManifestType identifies the type of manifest.
@@ -76,6 +221,419 @@ type Recording struct {
 	FilePath  string `json:"file_path"`
 	FileSize  int64  `json:"file_size"`
 	ClearKey  string `json:"clearkey,omitempty"`
+
+	// Headers are sent upstream (and to the extractor) for every request of the recording.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Extractor is set when URL is a page/link resolved through an extractor (e.g. dlhd, vavoo).
+	Extractor   string `json:"extractor,omitempty"`
+	ResolvedURL string `json:"resolved_url,omitempty"`
+	// Passthrough is set when a raw TS source is copied directly instead of remuxed by FFmpeg.
+	Passthrough bool `json:"passthrough,omitempty"`
+	// Quality pins the variant of an HLS master playlist that is recorded
+	// ("best", "worst", "1080p", "3M", "audio"), empty lets FFmpeg choose.
+	Quality string `json:"quality,omitempty"`
+	// Backups are source URLs recorded in turn when the one in use stalls
+	// or fails; Source is the index of the one in use, 0 for URL and i for
+	// Backups[i-1].
+	Backups []string `json:"backups,omitempty"`
+	Source  int      `json:"source,omitempty"`
+
+	// Restarts counts how many times the recorder was restarted after a stall or upstream drop.
+	Restarts      int                     `json:"restarts,omitempty"`
+	Interruptions []RecordingInterruption `json:"interruptions,omitempty"`
+
+	// Upload tracks export to external storage (nil when export is disabled).
+	Upload *RecordingUpload `json:"upload,omitempty"`
+
+	// Subtitles are WebVTT sidecar files stored next to the recording.
+	Subtitles []RecordingSubtitle `json:"subtitles,omitempty"`
+
+	// Health is the analysis of the recorded file (nil until analyzed).
+	Health *RecordingHealth `json:"health,omitempty"`
+	// Chapters split the recording at black or silent filler (nil until detected).
+	Chapters *RecordingChapters `json:"chapters,omitempty"`
+
+	// Volume is the recordings directory the file was placed in.
+	Volume string `json:"volume,omitempty"`
+	// Imported is set for media files registered by an import scan rather than
+	// recorded; retention cleanup leaves them alone.
+	Imported bool `json:"imported,omitempty"`
+
+	// User metadata, editable after recording.
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Poster      string   `json:"poster,omitempty"` // Poster image URL
+	Favorite    bool     `json:"favorite,omitempty"`
+}
+
+// RecordingUpdate changes the metadata of a recording; nil fields are left
+// unchanged.
+type RecordingUpdate struct {
+	Name        *string   `json:"name"`
+	Description *string   `json:"description"`
+	Tags        *[]string `json:"tags"`
+	Poster      *string   `json:"poster"`
+	Favorite    *bool     `json:"favorite"`
+}
+
+// Recording health statuses.
+const (
+	HealthGood        = "good"        // No defect found
+	HealthDegraded    = "degraded"    // Playable, with gaps, drops or interruptions
+	HealthUnwatchable = "unwatchable" // No playable media, or mostly gaps
+)
+
+// RecordingHealth is the integrity report of a recording, from analyzing its
+// file with ffprobe.
+type RecordingHealth struct {
+	Status     string   `json:"status"` // "good", "degraded", "unwatchable"
+	Issues     []string `json:"issues,omitempty"`
+	AnalyzedAt int64    `json:"analyzed_at"`
+	// Partial is set when the recording was still running when analyzed.
+	Partial bool `json:"partial,omitempty"`
+
+	Duration   float64 `json:"duration"` // Seconds of media in the file
+	Bitrate    int64   `json:"bitrate"`  // Average, in bits/s
+	VideoCodec string  `json:"video_codec,omitempty"`
+	Width      int     `json:"width,omitempty"`
+	Height     int     `json:"height,omitempty"`
+	FrameRate  float64 `json:"frame_rate,omitempty"`
+	AudioCodec string  `json:"audio_codec,omitempty"`
+
+	// Packets, Dropped and Discontinuities are counted on the main track
+	// (video, or audio for radio): corrupt packets and MPEG-TS continuity
+	// errors are dropped, timestamp jumps are discontinuities, Missing is
+	// the seconds of media they skip.
+	Packets         int64   `json:"packets"`
+	Dropped         int64   `json:"dropped"`
+	Discontinuities int     `json:"discontinuities"`
+	Missing         float64 `json:"missing"`
+	Interruptions   int     `json:"interruptions"` // Recorder restarts
+
+	Error string `json:"error,omitempty"`
+}
+
+// RecordingUpload describes the export of a recording to external storage.
+type RecordingUpload struct {
+	Status       string `json:"status"` // "pending", "uploading", "uploaded", "failed"
+	Target       string `json:"target"` // Storage backend name ("s3", "webdav")
+	Location     string `json:"location,omitempty"`
+	Error        string `json:"error,omitempty"`
+	UploadedAt   int64  `json:"uploaded_at,omitempty"`
+	LocalDeleted bool   `json:"local_deleted,omitempty"`
+}
+
+// Upload statuses.
+const (
+	UploadStatusPending   = "pending"
+	UploadStatusUploading = "uploading"
+	UploadStatusUploaded  = "uploaded"
+	UploadStatusFailed    = "failed"
+)
+
+// RecordingVolume reports the usage of a directory recordings are stored in.
+type RecordingVolume struct {
+	Path       string `json:"path"`
+	Quota      int64  `json:"quota,omitempty"` // Bytes, 0 for no quota
+	Used       int64  `json:"used"`            // Bytes of the recordings on the volume
+	Free       int64  `json:"free"`            // Free disk space in bytes, -1 if unknown
+	Recordings int    `json:"recordings"`
+	Full       bool   `json:"full"` // Quota reached: no new recordings are placed here
+	Error      string `json:"error,omitempty"`
+}
+
+// RecordingQuery filters, sorts and pages a recordings listing.
+type RecordingQuery struct {
+	Query  string   // Case-insensitive match on name, description and tags
+	Status []string // Any of these statuses; empty for all
+	Sort   string   // RecordingSortDate (default), RecordingSortSize or RecordingSortName
+	Order  string   // "asc" or "desc"; newest, largest and A-Z first by default
+	Limit  int      // 0 for all
+	Offset int
+}
+
+// Recording sort keys.
+const (
+	RecordingSortDate = "date"
+	RecordingSortSize = "size"
+	RecordingSortName = "name"
+)
+
+// RecordingSubtitle is a subtitle track of a recording, stored as WebVTT next to it.
+type RecordingSubtitle struct {
+	ID       string `json:"id"`
+	URL      string `json:"url,omitempty"`      // External track: WebVTT, SRT or an HLS subtitle playlist
+	Embedded bool   `json:"embedded,omitempty"` // Extracted from the recording: teletext, subtitle stream or closed captions
+	Language string `json:"lang,omitempty"`
+	Label    string `json:"label,omitempty"`
+	Status   string `json:"status"` // "pending", "capturing", "ready", "failed"
+	Error    string `json:"error,omitempty"`
+	FilePath string `json:"file_path,omitempty"`
+}
+
+// Subtitle statuses.
+const (
+	SubtitleStatusPending   = "pending" // Embedded subtitles wait for the recording to finish
+	SubtitleStatusCapturing = "capturing"
+	SubtitleStatusReady     = "ready"
+	SubtitleStatusFailed    = "failed"
+)
+
+// RecordingChapters are the chapters of a recording, split at the filler
+// found by black frame and silence detection.
+type RecordingChapters struct {
+	Status     string             `json:"status"` // "detecting", "ready", "failed"
+	Error      string             `json:"error,omitempty"`
+	DetectedAt int64              `json:"detected_at,omitempty"`
+	Chapters   []RecordingChapter `json:"chapters,omitempty"`
+}
+
+// RecordingChapter is a part of a recording, program or filler.
+type RecordingChapter struct {
+	Title string  `json:"title"`
+	Kind  string  `json:"kind"`  // "program", "black", "silence", "idle"
+	Start float64 `json:"start"` // Seconds from the start of the recording
+	End   float64 `json:"end"`
+}
+
+// Chapter detection statuses.
+const (
+	ChaptersStatusDetecting = "detecting"
+	ChaptersStatusReady     = "ready"
+	ChaptersStatusFailed    = "failed"
+)
+
+// Chapter kinds.
+const (
+	ChapterProgram = "program"
+	ChapterBlack   = "black"   // Black picture with sound
+	ChapterSilence = "silence" // Silence over a picture
+	ChapterIdle    = "idle"    // Black and silent, such as a break or an idle feed
+)
+
+// RecordingInterruption marks a point where a recording was interrupted.
+type RecordingInterruption struct {
+	At        int64  `json:"at"`     // Unix timestamp of the interruption
+	Offset    int64  `json:"offset"` // File size in bytes when the interruption was detected
+	Reason    string `json:"reason"`
+	Restarted bool   `json:"restarted"`
+	Source    string `json:"source,omitempty"` // Source switched to, when the recording failed over
+}
+
+// Channel is a live channel from the user's channel list (M3U playlist or JSON).
+type Channel struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	URL      string            `json:"url"`
+	Logo     string            `json:"logo,omitempty"`
+	Group    string            `json:"group,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	ClearKey string            `json:"clearkey,omitempty"`
+	Favorite bool              `json:"favorite,omitempty"`
+}
+
+// Channel health statuses.
+const (
+	HealthStatusUnknown = "unknown" // Not checked yet
+	HealthStatusUp      = "up"
+	HealthStatusDown    = "down"
+)
+
+// ChannelHealth is a channel's availability as seen by the health monitor.
+type ChannelHealth struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Status    string        `json:"status"` // "unknown", "up" or "down"
+	LastCheck int64         `json:"last_check,omitempty"`
+	Latency   int64         `json:"latency_ms,omitempty"` // Time to first byte of the last successful check
+	LastError string        `json:"last_error,omitempty"`
+	Failures  int           `json:"failures"` // Consecutive failed checks
+	Uptime    float64       `json:"uptime"`   // Percentage of successful checks in History
+	History   []HealthCheck `json:"history"`  // Oldest first
+}
+
+// HealthCheck is the result of a single channel check.
+type HealthCheck struct {
+	At      int64  `json:"at"`
+	Up      bool   `json:"up"`
+	Latency int64  `json:"latency_ms"`
+	Error   string `json:"error,omitempty"`
+}
+
+// EventType identifies an application event delivered to notifiers.
+type EventType string
+
+const (
+	EventRecordingStarted   EventType = "recording.started"
+	EventRecordingCompleted EventType = "recording.completed"
+	EventRecordingStopped   EventType = "recording.stopped"
+	EventRecordingFailed    EventType = "recording.failed"
+	EventRecordingDeleted   EventType = "recording.deleted"
+	EventRecordingUploaded  EventType = "recording.uploaded"
+	EventExtractorFailed    EventType = "extractor.failed"
+	EventServerStats        EventType = "server.stats"
+)
+
+// Event is the payload sent to notifiers.
+type Event struct {
+	Type      EventType         `json:"event"`
+	Timestamp int64             `json:"timestamp"`
+	Message   string            `json:"content"` // Human-readable summary ("content" is what Discord webhooks display)
+	Recording *RecordingSummary `json:"recording,omitempty"`
+	Extractor string            `json:"extractor,omitempty"`
+	URL       string            `json:"url,omitempty"`
+	Stats     *ServerStats      `json:"stats,omitempty"`
+}
+
+// RecordingSummary is the part of a recording sent with events. Source URLs,
+// headers and keys are left out as they may carry credentials.
+type RecordingSummary struct {
+	ID        string           `json:"id"`
+	Name      string           `json:"name"`
+	Status    string           `json:"status"`
+	StartedAt int64            `json:"started_at"`
+	Duration  int              `json:"duration"`
+	FileSize  int64            `json:"file_size"`
+	Extractor string           `json:"extractor,omitempty"`
+	Restarts  int              `json:"restarts,omitempty"`
+	Upload    *RecordingUpload `json:"upload,omitempty"`
+	Tags      []string         `json:"tags,omitempty"`
+}
+
+// ServerStats is a point-in-time snapshot of server activity.
+type ServerStats struct {
+	UptimeSeconds    int64        `json:"uptime_seconds"`
+	Goroutines       int          `json:"goroutines"`
+	MemoryBytes      uint64       `json:"memory_bytes"`
+	ActiveStreams    int64        `json:"active_streams"`
+	ActiveRecordings []*Recording `json:"active_recordings"`
+}
+
+// Session is an active playback session through the proxy.
+type Session struct {
+	ID        string     `json:"id"`
+	ClientIP  string     `json:"client_ip"`
+	UserAgent string     `json:"user_agent,omitempty"`
+	URL       string     `json:"url"`
+	Type      StreamType `json:"type"`
+	StartedAt int64      `json:"started_at"`
+	LastSeen  int64      `json:"last_seen"`
+	Requests  int64      `json:"requests"`
+	BytesSent int64      `json:"bytes_sent"`
+	Bandwidth int64      `json:"bandwidth"` // Bits per second over the last measurement window
+}
+
+// StreamStats are the counters of one stream through the proxy, keyed by the
+// manifest URL its playback sessions started with.
+type StreamStats struct {
+	URL          string     `json:"url"`
+	Type         StreamType `json:"type"`
+	Manifests    int64      `json:"manifests"` // Manifest (re)loads
+	Segments     int64      `json:"segments"`  // Segments, keys and direct stream requests
+	Bytes        int64      `json:"bytes"`
+	Errors       int64      `json:"errors"`       // Failed requests, mostly upstream errors
+	AvgFetchTime int64      `json:"avg_fetch_ms"` // Average time until a response started
+	MaxFetchTime int64      `json:"max_fetch_ms"` // Slowest response start
+	LastError    string     `json:"last_error,omitempty"`
+	LastErrorAt  int64      `json:"last_error_at,omitempty"`
+	FirstSeen    int64      `json:"first_seen"`
+	LastSeen     int64      `json:"last_seen"`
+}
+
+// RuntimeInfo is a snapshot of the process, for diagnosing memory growth and
+// goroutine leaks in long-running deployments.
+type RuntimeInfo struct {
+	GoVersion        string               `json:"go_version"`
+	Uptime           int64                `json:"uptime"` // Seconds
+	Goroutines       int                  `json:"goroutines"`
+	HeapAlloc        uint64               `json:"heap_alloc"` // Bytes of live heap objects
+	HeapInuse        uint64               `json:"heap_inuse"`
+	HeapObjects      uint64               `json:"heap_objects"`
+	Sys              uint64               `json:"sys"` // Bytes obtained from the OS
+	NumGC            uint32               `json:"num_gc"`
+	LastGCPause      int64                `json:"last_gc_pause_us"`
+	Processes        map[string]int       `json:"processes"` // Running child processes by command, e.g. ffmpeg
+	ActiveRecordings int                  `json:"active_recordings"`
+	Sessions         int                  `json:"sessions"`
+	Caches           map[string]CacheSize `json:"caches"`
+	DecryptPool      *PoolStats           `json:"decrypt_pool,omitempty"` // /decrypt segment jobs
+	RemuxPool        *RemuxPoolStats      `json:"remux_pool,omitempty"`   // Warm FFmpeg remux processes
+}
+
+// RemuxPoolStats describes the FFmpeg processes kept started ahead of
+// segment remuxes.
+type RemuxPoolStats struct {
+	Size   int    `json:"size"`
+	Idle   int    `json:"idle"`   // Started and waiting for a segment
+	Hits   uint64 `json:"hits"`   // Remuxes served by a warm process
+	Misses uint64 `json:"misses"` // Remuxes that started their own process
+}
+
+// PoolStats describes a worker pool's occupancy, as reported by
+// /api/debug/runtime.
+type PoolStats struct {
+	Workers   int     `json:"workers"`
+	Busy      int     `json:"busy"`
+	Queued    int     `json:"queued"`
+	MaxQueued int     `json:"max_queued"`
+	Clients   int     `json:"clients"`     // Clients with queued jobs
+	Completed uint64  `json:"completed"`   // Jobs run
+	Rejected  uint64  `json:"rejected"`    // Jobs refused with a full queue
+	Abandoned uint64  `json:"abandoned"`   // Jobs that gave up waiting
+	AvgWaitMs float64 `json:"avg_wait_ms"` // Average queueing time of jobs that waited
+}
+
+// ThrottleStats describes a request limiter, as reported by
+// /api/stats/throttle.
+type ThrottleStats struct {
+	Concurrency int     `json:"concurrency,omitempty"` // Cap, 0 for none
+	Rate        float64 `json:"rate,omitempty"`        // Starts per second, 0 for no limit
+	Active      int     `json:"active"`
+	Queued      int     `json:"queued"`
+	Started     uint64  `json:"started"`
+	Throttled   uint64  `json:"throttled"`   // Requests delayed by the cap or the rate
+	Abandoned   uint64  `json:"abandoned"`   // Requests that gave up waiting
+	AvgWaitMs   float64 `json:"avg_wait_ms"` // Average delay of throttled requests
+}
+
+// ThrottleReport lists the paced extractors, transport routes and hosts.
+type ThrottleReport struct {
+	Extractors map[string]ThrottleStats `json:"extractors"`         // By extractor name
+	Routes     map[string]ThrottleStats `json:"routes"`             // By route URL pattern
+	Outbound   *ThrottleStats           `json:"outbound,omitempty"` // Budget of page and API requests
+	Hosts      map[string]ThrottleStats `json:"hosts"`              // Page and API requests by host
+}
+
+// UAProfileStatus describes the User-Agent profile of upstream requests, as
+// reported and set by /api/useragent.
+type UAProfileStatus struct {
+	Profile  string              `json:"profile"`
+	Rotate   bool                `json:"rotate"`             // Each host gets its own User-Agent of the profile
+	Profiles map[string][]string `json:"profiles,omitempty"` // User-Agents of the presets, by profile name
+}
+
+// ExtractorRules are an extractor's part of a rules bundle. Each extractor
+// decides which pattern and template names it uses; others are ignored.
+type ExtractorRules struct {
+	Patterns  map[string][]string `json:"patterns,omitempty"`  // Regexes by name, tried before the built-in ones
+	Templates map[string]string   `json:"templates,omitempty"` // URL templates by name
+	Headers   map[string]string   `json:"headers,omitempty"`   // Headers set on the extracted stream's requests
+}
+
+// RulesStatus describes the last rules bundle load, as reported by /api/rules.
+type RulesStatus struct {
+	URL        string   `json:"url"`
+	Version    string   `json:"version,omitempty"`
+	LoadedAt   int64    `json:"loaded_at,omitempty"`  // Unix time of the last successful load
+	Extractors []string `json:"extractors,omitempty"` // Extractors the bundle has rules for
+	Error      string   `json:"error,omitempty"`      // Of the last load, if it failed
+	CheckedAt  int64    `json:"checked_at,omitempty"` // Unix time of the last load attempt
+}
+
+// CacheSize is the size of an in-memory or disk cache.
+type CacheSize struct {
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes,omitempty"`
 }
 
 // RecordingStatus represents the status of a recording.
@@ -0,0 +1,60 @@
+// Package urlcrypt encrypts the query strings of proxy URLs with AES-GCM, so
+// links handed to players don't reveal the upstream URLs and headers they
+// carry.
+package urlcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// Param is the query parameter carrying an encrypted query string.
+const Param = "enc"
+
+// ErrInvalid is returned for a value that wasn't sealed with the secret.
+var ErrInvalid = errors.New("invalid encrypted URL")
+
+// Cipher seals and opens query strings with a key derived from a secret.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// New creates a cipher keyed with the SHA-256 of secret.
+func New(secret string) (*Cipher, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts a query string, returning it base64url encoded with its
+// random nonce.
+func (c *Cipher) Seal(query string) string {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(query)+c.aead.Overhead())
+	rand.Read(nonce)
+	return base64.RawURLEncoding.EncodeToString(c.aead.Seal(nonce, nonce, []byte(query), nil))
+}
+
+// Open decrypts a value returned by Seal.
+func (c *Cipher) Open(sealed string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(data) < c.aead.NonceSize() {
+		return "", ErrInvalid
+	}
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	query, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	return string(query), nil
+}
@@ -0,0 +1,40 @@
+package urlcrypt
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCipher(t *testing.T) {
+	c, err := New("secret")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	query := "url=https%3A%2F%2Forigin.example%2Flive.m3u8%3Ftoken%3Dabc&h_referer=https%3A%2F%2Fsite.example%2F"
+
+	sealed := c.Seal(query)
+	if strings.Contains(sealed, "origin") || strings.ContainsAny(sealed, "+/=") {
+		t.Errorf("Seal() = %q, want opaque base64url", sealed)
+	}
+	if c.Seal(query) == sealed {
+		t.Error("Seal() reused its nonce")
+	}
+	if got, err := c.Open(sealed); err != nil || got != query {
+		t.Errorf("Open() = %q, %v, want %q", got, err, query)
+	}
+
+	other, _ := New("other secret")
+	if _, err := other.Open(sealed); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Open() with another secret error = %v, want ErrInvalid", err)
+	}
+	data, _ := base64.RawURLEncoding.DecodeString(sealed)
+	data[len(data)-1] ^= 1
+	tampered := base64.RawURLEncoding.EncodeToString(data)
+	for _, bad := range []string{tampered, "not base64!", ""} {
+		if _, err := c.Open(bad); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Open(%q) error = %v, want ErrInvalid", bad, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Package urlsign signs the query strings of generated proxy links, so they
+// can be handed out without the API password: the expiration and signature
+// parameters of MediaFlow-Proxy style links.
+package urlsign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query parameters of a signed link.
+const (
+	ParamExpiration = "expiration" // Unix time the link expires at, absent for no expiry
+	ParamSignature  = "signature"  // HMAC-SHA256 of the other parameters
+	ParamIP         = "ip"         // Client the link is restricted to, optional
+	ParamPath       = "path"       // Path the link is restricted to, or paths under it when ending in /; optional
+)
+
+var (
+	ErrInvalid = errors.New("invalid link signature")
+	ErrExpired = errors.New("link expired")
+	ErrIP      = errors.New("link issued to another client")
+	ErrPath    = errors.New("link issued for another path")
+)
+
+// Sign adds the expiry and signature of query, keyed with secret. A zero
+// expires makes a link that doesn't expire.
+func Sign(secret string, query url.Values, expires time.Time) {
+	query.Del(ParamSignature)
+	if expires.IsZero() {
+		query.Del(ParamExpiration)
+	} else {
+		query.Set(ParamExpiration, strconv.FormatInt(expires.Unix(), 10))
+	}
+	query.Set(ParamSignature, signature(secret, query))
+}
+
+// SignedURL returns the URL of path under baseURL with query, signed with
+// secret and restricted to path, for links handed to clients that can't send
+// the password. An empty secret leaves the link unsigned.
+func SignedURL(secret, baseURL, path string, query url.Values) string {
+	return ScopedURL(secret, baseURL, path, path, query)
+}
+
+// ScopedURL is SignedURL for a link restricted to scope, such as the
+// directory of a playlist and its segments.
+func ScopedURL(secret, baseURL, path, scope string, query url.Values) string {
+	if secret != "" {
+		if query == nil {
+			query = url.Values{}
+		}
+		query.Set(ParamPath, scope)
+		Sign(secret, query, time.Time{})
+	}
+	if len(query) == 0 {
+		return baseURL + path
+	}
+	return baseURL + path + "?" + query.Encode()
+}
+
+// Signed reports whether query carries a signature.
+func Signed(query url.Values) bool {
+	return query.Get(ParamSignature) != ""
+}
+
+// Verify checks the signature and expiry of query for a request to path from
+// the client at ip.
+func Verify(secret string, query url.Values, path, clientIP string, now time.Time) error {
+	if !hmac.Equal([]byte(query.Get(ParamSignature)), []byte(signature(secret, query))) {
+		return ErrInvalid
+	}
+	if exp := query.Get(ParamExpiration); exp != "" {
+		unix, err := strconv.ParseInt(exp, 10, 64)
+		if err != nil {
+			return ErrInvalid
+		}
+		if now.Unix() > unix {
+			return ErrExpired
+		}
+	}
+	if ip := query.Get(ParamIP); ip != "" && ip != clientIP {
+		return ErrIP
+	}
+	if p := query.Get(ParamPath); p != "" && p != path && !(strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+		return ErrPath
+	}
+	return nil
+}
+
+// signature returns the hex HMAC of query's parameters, in sorted order.
+// Those players and the proxy add to a link (LL-HLS directives, playback
+// tokens) aren't covered.
+func signature(secret string, query url.Values) string {
+	signed := url.Values{}
+	for name, values := range query {
+		if name == ParamSignature || name == "token" || strings.HasPrefix(name, "_HLS_") {
+			continue
+		}
+		signed[name] = values
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,67 @@
+package urlsign
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	link := func(expires time.Time, ip, path string) url.Values {
+		q := url.Values{"d": {"http://origin/live.m3u8"}, "h_referer": {"https://site/"}}
+		if ip != "" {
+			q.Set(ParamIP, ip)
+		}
+		if path != "" {
+			q.Set(ParamPath, path)
+		}
+		Sign("secret", q, expires)
+		return q
+	}
+
+	tests := []struct {
+		name   string
+		query  url.Values
+		secret string
+		path   string
+		ip     string
+		want   error
+	}{
+		{"valid", link(now.Add(time.Hour), "", ""), "secret", "/proxy/stream", "192.0.2.1", nil},
+		{"no expiry", link(time.Time{}, "", ""), "secret", "/proxy/stream", "192.0.2.1", nil},
+		{"expired", link(now.Add(-time.Second), "", ""), "secret", "/proxy/stream", "192.0.2.1", ErrExpired},
+		{"other secret", link(now.Add(time.Hour), "", ""), "other", "/proxy/stream", "192.0.2.1", ErrInvalid},
+		{"client", link(now.Add(time.Hour), "192.0.2.1", ""), "secret", "/proxy/stream", "192.0.2.1", nil},
+		{"other client", link(now.Add(time.Hour), "192.0.2.1", ""), "secret", "/proxy/stream", "198.51.100.7", ErrIP},
+		{"path", link(now.Add(time.Hour), "", "/proxy/stream"), "secret", "/proxy/stream", "192.0.2.1", nil},
+		{"other path", link(now.Add(time.Hour), "", "/proxy/stream"), "secret", "/proxy/hls/segment.ts", "192.0.2.1", ErrPath},
+		{"under directory", link(now.Add(time.Hour), "", "/ffmpeg_stream/abc/"), "secret", "/ffmpeg_stream/abc/seg1.ts", "192.0.2.1", nil},
+		{"other directory", link(now.Add(time.Hour), "", "/ffmpeg_stream/abc/"), "secret", "/ffmpeg_stream/abd/seg1.ts", "192.0.2.1", ErrPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.secret, tt.query, tt.path, tt.ip, now); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	q := link(now.Add(time.Hour), "", "")
+	q.Set("d", "http://elsewhere/")
+	if err := Verify("secret", q, "", "", now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Verify() of a changed link = %v, want ErrInvalid", err)
+	}
+	q = link(now.Add(time.Hour), "", "")
+	q.Set("_HLS_msn", "10")
+	q.Set("token", "t")
+	if err := Verify("secret", q, "", "", now); err != nil {
+		t.Errorf("Verify() with player parameters = %v", err)
+	}
+	q = link(now.Add(time.Hour), "", "")
+	q.Set(ParamExpiration, "9999999999")
+	if err := Verify("secret", q, "", "", now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Verify() with an extended expiry = %v, want ErrInvalid", err)
+	}
+}
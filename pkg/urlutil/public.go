@@ -0,0 +1,36 @@
+package urlutil
+
+import "context"
+
+type publicURLKey struct{}
+
+// publicURL is the public address of a request, resolved by the server
+// middleware from BASE_URL and the headers of trusted reverse proxies.
+type publicURL struct {
+	baseURL string // Base of absolute URLs handed to clients
+	origin  string // Scheme and host the client made the request to
+}
+
+// WithPublicURL returns a context carrying the public base URL and origin of
+// the request it belongs to.
+func WithPublicURL(ctx context.Context, baseURL, origin string) context.Context {
+	return context.WithValue(ctx, publicURLKey{}, publicURL{baseURL: baseURL, origin: origin})
+}
+
+// PublicBaseURL returns the base for absolute URLs handed to the client of
+// the request in ctx, or fallback outside a request (e.g. the DVR).
+func PublicBaseURL(ctx context.Context, fallback string) string {
+	if p, ok := ctx.Value(publicURLKey{}).(publicURL); ok && p.baseURL != "" {
+		return p.baseURL
+	}
+	return fallback
+}
+
+// RequestOrigin returns the scheme and host the client of the request in ctx
+// addressed, or fallback outside a request.
+func RequestOrigin(ctx context.Context, fallback string) string {
+	if p, ok := ctx.Value(publicURLKey{}).(publicURL); ok && p.origin != "" {
+		return p.origin
+	}
+	return fallback
+}
@@ -0,0 +1,146 @@
+// Rendering more rows than this makes large imported playlists sluggish
+const maxChannelRows = 200;
+let channelsData = [];
+let channelHealth = {};
+
+function channelPlayUrl(ch) {
+    const params = new URLSearchParams({ url: ch.url });
+    Object.entries(ch.headers || {}).forEach(([k, v]) => params.set('h_' + k, v));
+    if (ch.clearkey) params.set('clearkey', ch.clearkey);
+    return '/play?' + params.toString();
+}
+
+async function fetchChannels() {
+    try {
+        channelsData = (await fetch('/api/channels').then(r => r.json())) || [];
+        renderChannels();
+    } catch (e) { console.error('Failed to fetch channels:', e); }
+}
+
+async function fetchChannelHealth() {
+    try {
+        const list = (await fetch('/api/health/channels').then(r => r.json())) || [];
+        channelHealth = Object.fromEntries(list.map(h => [h.id, h]));
+        renderChannels();
+    } catch (e) { console.error('Failed to fetch channel health:', e); }
+}
+
+function channelHealthBadge(ch) {
+    const h = channelHealth[ch.id];
+    if (!h || h.status === 'unknown') return '';
+    if (h.status === 'up') {
+        return '<span title="' + t('Up, {0}% uptime', h.uptime.toFixed(0)) + '">🟢 ' + h.latency_ms + ' ms</span>';
+    }
+    return '<span title="' + escapeHtml(h.last_error) + '">🔴 ' + t('Down ({0}x)', h.failures) + '</span>';
+}
+
+function renderChannels() {
+    const filter = document.getElementById('channelFilter').value.toLowerCase();
+    const listEl = document.getElementById('channelList');
+    document.getElementById('channelCount').textContent = channelsData.length;
+
+    // Favorites first, otherwise keep playlist order
+    const indexes = channelsData.map((_, i) => i)
+        .filter(i => !filter || (channelsData[i].name + ' ' + (channelsData[i].group || '')).toLowerCase().includes(filter))
+        .sort((a, b) => (channelsData[b].favorite ? 1 : 0) - (channelsData[a].favorite ? 1 : 0) || a - b);
+
+    if (indexes.length === 0) {
+        listEl.innerHTML = '<div class="empty-state"><span>📺</span>' + t('No saved channels') + '</div>';
+        return;
+    }
+
+    listEl.innerHTML = indexes.slice(0, maxChannelRows).map(i => {
+        const ch = channelsData[i];
+        return '<div class="recording">' +
+            '<button class="btn btn-sm" style="background:none;font-size:1.25rem;" title="Favorite" onclick="toggleFavorite(' + i + ')">' + (ch.favorite ? '⭐' : '☆') + '</button>' +
+            '<div class="recording-info">' +
+                '<div class="recording-name">' + escapeHtml(ch.name) + '</div>' +
+                '<div class="recording-meta">' +
+                    channelHealthBadge(ch) +
+                    (ch.group ? '<span>📂 ' + escapeHtml(ch.group) + '</span>' : '') +
+                    (ch.clearkey ? '<span>🔑 ClearKey</span>' : '') +
+                '</div>' +
+            '</div>' +
+            '<div class="recording-actions">' +
+                '<a href="' + escapeHtml(channelPlayUrl(ch)) + '" target="_blank" class="btn btn-primary btn-sm">' + t('Play') + '</a>' +
+                (dashboard.features.dvr ? '<button class="btn btn-danger btn-sm" onclick="recordChannel(' + i + ')">' + t('Record') + '</button>' : '') +
+                '<button class="btn btn-sm" style="background:var(--bg-input);color:var(--text-primary);" onclick="deleteChannel(' + i + ')">' + t('Delete') + '</button>' +
+            '</div>' +
+        '</div>';
+    }).join('') + (indexes.length > maxChannelRows ? '<div class="empty-state">' + t('Showing {0} of {1} channels, use the filter to narrow down', maxChannelRows, indexes.length) + '</div>' : '');
+}
+
+async function saveChannel(e) {
+    e.preventDefault();
+    const referer = document.getElementById('channelReferer').value.trim();
+    const ch = {
+        name: document.getElementById('channelName').value.trim(),
+        url: document.getElementById('channelUrl').value.trim(),
+        group: document.getElementById('channelGroup').value.trim(),
+        clearkey: document.getElementById('channelClearKey').value.trim(),
+        favorite: true
+    };
+    if (referer) ch.headers = { 'Referer': referer };
+    try {
+        const res = await fetch('/api/channels', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(ch)
+        });
+        if (res.ok) {
+            showToast(t('Channel saved'), 'success');
+            document.getElementById('channelForm').reset();
+            fetchChannels();
+        } else {
+            const err = await res.json().catch(() => ({}));
+            showToast(t('Error') + ': ' + (err.error || res.status), 'error');
+        }
+    } catch (e) { showToast(t('Error') + ': ' + e.message, 'error'); }
+}
+
+async function toggleFavorite(i) {
+    const ch = Object.assign({}, channelsData[i], { favorite: !channelsData[i].favorite });
+    try {
+        const res = await fetch('/api/channels/' + encodeURIComponent(ch.id), {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(ch)
+        });
+        if (res.ok) { channelsData[i] = ch; renderChannels(); }
+        else { showToast(t('Failed to update channel'), 'error'); }
+    } catch (e) { showToast(t('Error') + ': ' + e.message, 'error'); }
+}
+
+async function deleteChannel(i) {
+    const ch = channelsData[i];
+    if (!confirm(t('Delete channel "{0}"?', ch.name))) return;
+    try {
+        const res = await fetch('/api/channels/' + encodeURIComponent(ch.id), { method: 'DELETE' });
+        if (res.ok) { showToast(t('Channel deleted'), 'success'); fetchChannels(); }
+        else { showToast(t('Failed to delete'), 'error'); }
+    } catch (e) { showToast(t('Error') + ': ' + e.message, 'error'); }
+}
+
+async function recordChannel(i) {
+    const ch = channelsData[i];
+    try {
+        const res = await fetch('/api/recordings/start', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ url: ch.url, name: ch.name, clearkey: ch.clearkey || '', headers: ch.headers || {} })
+        });
+        if (res.ok) {
+            showToast(t('Recording started!'), 'success');
+            if (typeof fetchRecordings === 'function') fetchRecordings();
+        } else {
+            const err = await res.json().catch(() => ({}));
+            showToast(t('Error') + ': ' + (err.error || t('Failed')), 'error');
+        }
+    } catch (e) { showToast(t('Error') + ': ' + e.message, 'error'); }
+}
+
+fetchChannels();
+if (dashboard.features.health) {
+    fetchChannelHealth();
+    setInterval(fetchChannelHealth, 30000);
+}
@@ -0,0 +1,22 @@
+// Dashboard configuration rendered by the server: which features are enabled
+// and the translations of the page language. Section scripts read it instead
+// of having values templated into them.
+const dashboard = JSON.parse(document.getElementById('dashboard-config').textContent);
+
+// t translates an English message, replacing {0}, {1}... with the arguments.
+function t(message, ...args) {
+    const translated = (dashboard.messages || {})[message] || message;
+    return translated.replace(/\{(\d+)\}/g, (m, i) => i < args.length ? args[i] : m);
+}
+
+function showToast(msg, type) {
+    const el = document.getElementById('toast');
+    el.textContent = msg;
+    el.className = 'toast ' + type;
+    el.style.display = 'block';
+    setTimeout(() => el.style.display = 'none', 3000);
+}
+
+function escapeHtml(s) {
+    return String(s || '').replace(/[&<>"']/g, c => ({'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;'}[c]));
+}
@@ -0,0 +1,27 @@
+function renderStats(stats) {
+    const el = document.getElementById('serverStats');
+    if (!el || !stats) return;
+    el.innerHTML = '<span title="' + t('Active streams') + '">📶 ' + t('{0} streams', stats.active_streams) + '</span>' +
+        '<span title="' + t('Active recordings') + '">🔴 ' + t('{0} recording', (stats.active_recordings || []).length) + '</span>' +
+        '<span title="' + t('Memory') + '">🧠 ' + (stats.memory_bytes / 1048576).toFixed(0) + ' MB</span>' +
+        '<span title="' + t('Uptime') + '">⏱ ' + Math.floor(stats.uptime_seconds / 3600) + 'h ' + Math.floor((stats.uptime_seconds % 3600) / 60) + 'm</span>';
+}
+
+function connectEvents() {
+    if (!window.EventSource) {
+        if (typeof fetchRecordings === 'function') setInterval(fetchRecordings, 5000);
+        return;
+    }
+    const es = new EventSource('/api/events' + location.search);
+    es.addEventListener('server.stats', e => {
+        const stats = JSON.parse(e.data).stats;
+        renderStats(stats);
+        if (typeof onRecordingProgress === 'function') onRecordingProgress(stats.active_recordings || []);
+    });
+    ['recording.started', 'recording.completed', 'recording.stopped', 'recording.failed', 'recording.deleted', 'recording.uploaded'].forEach(type => {
+        es.addEventListener(type, () => { if (typeof fetchRecordings === 'function') fetchRecordings(); });
+    });
+    es.addEventListener('extractor.failed', e => showToast(JSON.parse(e.data).content, 'error'));
+}
+
+connectEvents();
@@ -0,0 +1,176 @@
+// Store active recordings for real-time elapsed updates
+let activeRecordingsData = [];
+// Finished recordings shown on the dashboard
+const RECORDINGS_PAGE_SIZE = 100;
+
+function formatSize(bytes) {
+    if (!bytes) return '0 B';
+    const units = ['B', 'KB', 'MB', 'GB'];
+    let i = 0;
+    while (bytes >= 1024 && i < units.length - 1) { bytes /= 1024; i++; }
+    return bytes.toFixed(1) + ' ' + units[i];
+}
+
+function formatElapsed(seconds) {
+    if (!seconds || seconds < 0) seconds = 0;
+    const h = Math.floor(seconds / 3600);
+    const m = Math.floor((seconds % 3600) / 60);
+    const s = Math.floor(seconds % 60);
+    if (h > 0) return h + 'h ' + m.toString().padStart(2, '0') + 'm ' + s.toString().padStart(2, '0') + 's';
+    return m + 'm ' + s.toString().padStart(2, '0') + 's';
+}
+
+function formatDuration(seconds) {
+    if (!seconds) return '';
+    const h = Math.floor(seconds / 3600);
+    const m = Math.floor((seconds % 3600) / 60);
+    return h > 0 ? h + 'h ' + m + 'm' : m + 'm';
+}
+
+function formatDate(ts) {
+    if (!ts) return '';
+    return new Date(ts * 1000).toLocaleString();
+}
+
+async function fetchRecordings() {
+    try {
+        // The newest finished recordings, sorted and paged by the server
+        const [finishedRes, active] = await Promise.all([
+            fetch('/api/recordings?status=completed,failed&sort=date&limit=' + RECORDINGS_PAGE_SIZE),
+            fetch('/api/recordings/active').then(r => r.json())
+        ]);
+        const completed = await finishedRes.json();
+        const total = parseInt(finishedRes.headers.get('X-Total-Count')) || (completed || []).length;
+        activeRecordingsData = active || [];
+        renderRecordings(completed || [], total, activeRecordingsData);
+    } catch (e) { console.error('Failed to fetch recordings:', e); }
+}
+
+function renderRecordings(completed, completedTotal, active) {
+    document.getElementById('activeCount').textContent = active.length;
+    document.getElementById('completedCount').textContent = completedTotal;
+
+    const activeEl = document.getElementById('activeRecordings');
+    const completedEl = document.getElementById('completedRecordings');
+
+    if (active.length === 0) {
+        activeEl.innerHTML = '<div class="empty-state"><span>📭</span>' + t('No active recordings') + '</div>';
+    } else {
+        activeEl.innerHTML = active.map(r => `
+            <div class="recording" data-id="${r.id}" data-started="${r.started_at}">
+                <span class="recording-icon">🔴</span>
+                <div class="recording-info">
+                    <div class="recording-name">${r.name || t('Unnamed')}</div>
+                    <div class="recording-meta">
+                        <span class="elapsed" title="${t('Elapsed time')}">⏱ ${formatElapsed(Math.floor(Date.now()/1000) - r.started_at)}</span>
+                        <span class="filesize" title="${t('File size')}">💾 ${formatSize(r.file_size)}</span>
+                    </div>
+                </div>
+                <div class="recording-actions">
+                    <button class="btn btn-danger btn-sm" onclick="stopRecording('${r.id}')">${t('Stop')}</button>
+                </div>
+            </div>
+        `).join('');
+    }
+
+    if (completed.length === 0) {
+        completedEl.innerHTML = '<div class="empty-state"><span>📭</span>' + t('No completed recordings') + '</div>';
+    } else {
+        completedEl.innerHTML = completed.map(r => `
+            <div class="recording">
+                <span class="recording-icon">✅</span>
+                <div class="recording-info">
+                    <div class="recording-name">${r.name || t('Unnamed')}</div>
+                    <div class="recording-meta">
+                        <span title="${t('Recorded on')}">${formatDate(r.started_at)}</span>
+                        <span title="${t('Duration')}">⏱ ${formatDuration(r.duration)}</span>
+                        <span title="${t('File size')}">💾 ${formatSize(r.file_size)}</span>
+                    </div>
+                </div>
+                <div class="recording-actions">
+                    <a href="/api/recordings/${r.id}/stream" target="_blank" class="btn btn-primary btn-sm">${t('Play')}</a>
+                    <a href="/api/recordings/${r.id}/download" class="btn btn-sm" style="background:var(--bg-input);">${t('Download')}</a>
+                    <button class="btn btn-danger btn-sm" onclick="deleteRecording('${r.id}')">${t('Delete')}</button>
+                </div>
+            </div>
+        `).join('');
+    }
+}
+
+// Update elapsed time every second for active recordings
+function updateElapsedTimes() {
+    const now = Math.floor(Date.now() / 1000);
+    document.querySelectorAll('#activeRecordings .recording[data-started]').forEach(el => {
+        const started = parseInt(el.dataset.started);
+        const elapsed = now - started;
+        const elapsedEl = el.querySelector('.elapsed');
+        if (elapsedEl) {
+            elapsedEl.textContent = '⏱ ' + formatElapsed(elapsed);
+        }
+    });
+}
+
+async function startRecording(e) {
+    e.preventDefault();
+    const btn = e.target.querySelector('button[type="submit"]');
+    if (btn.disabled) return; // Prevent double submission
+    btn.disabled = true;
+    btn.textContent = t('Starting...');
+    const url = document.getElementById('recordUrl').value;
+    const name = document.getElementById('recordName').value || 'recording';
+    const quality = document.getElementById('recordQuality').value;
+    try {
+        const res = await fetch('/api/recordings/start', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ url, name, quality })
+        });
+        if (res.ok) {
+            showToast(t('Recording started!'), 'success');
+            document.getElementById('recordUrl').value = '';
+            document.getElementById('recordName').value = '';
+            fetchRecordings();
+        } else {
+            const err = await res.json();
+            showToast(t('Error') + ': ' + (err.error || t('Failed')), 'error');
+        }
+    } catch (e) { showToast(t('Error') + ': ' + e.message, 'error'); }
+    finally { btn.disabled = false; btn.textContent = t('Record'); }
+}
+
+async function stopRecording(id) {
+    try {
+        const res = await fetch('/api/recordings/' + id + '/stop', { method: 'POST' });
+        if (res.ok) { showToast(t('Recording stopped'), 'success'); fetchRecordings(); }
+        else {
+            const err = await res.json().catch(() => ({}));
+            showToast(t('Failed to stop') + ': ' + (err.error || res.status), 'error');
+        }
+    } catch (e) { showToast(t('Error') + ': ' + e.message, 'error'); }
+}
+
+async function deleteRecording(id) {
+    if (!confirm(t('Delete this recording?'))) return;
+    try {
+        const res = await fetch('/api/recordings/' + id, { method: 'DELETE' });
+        if (res.ok) { showToast(t('Recording deleted'), 'success'); fetchRecordings(); }
+        else { showToast(t('Failed to delete'), 'error'); }
+    } catch (e) { showToast(t('Error') + ': ' + e.message, 'error'); }
+}
+
+// Called with each server.stats event (see /api/events) to update file sizes live
+function onRecordingProgress(active) {
+    const known = new Set(activeRecordingsData.map(r => r.id));
+    if (active.length !== known.size || active.some(r => !known.has(r.id))) {
+        fetchRecordings();
+        return;
+    }
+    activeRecordingsData = active;
+    active.forEach(r => {
+        const el = document.querySelector('#activeRecordings .recording[data-id="' + r.id + '"] .filesize');
+        if (el) el.textContent = '💾 ' + formatSize(r.file_size);
+    });
+}
+
+fetchRecordings();
+setInterval(updateElapsedTimes, 1000);  // Update elapsed time every second
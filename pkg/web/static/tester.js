@@ -0,0 +1,100 @@
+// Parses "Name: value" lines; blank and malformed lines are skipped
+function testerHeaders() {
+    const headers = [];
+    document.getElementById('testerHeaders').value.split('\n').forEach(line => {
+        const i = line.indexOf(':');
+        if (i <= 0) return;
+        const name = line.slice(0, i).trim(), value = line.slice(i + 1).trim();
+        if (name && value) headers.push([name, value]);
+    });
+    return headers;
+}
+
+// testerParams returns the query string shared by all generated URLs.
+// URLSearchParams encodes the source URL and each h_ header value.
+function testerParams() {
+    const params = new URLSearchParams({ url: document.getElementById('testerUrl').value.trim() });
+    testerHeaders().forEach(([name, value]) => params.set('h_' + name, value));
+    const clearkey = document.getElementById('testerClearKey').value.trim();
+    if (clearkey) params.set('clearkey', clearkey);
+    const password = document.getElementById('testerPassword').value;
+    if (password) params.set('api_password', password);
+    return params.toString();
+}
+
+function testerUrlRow(label, url) {
+    return '<div class="recording">' +
+        '<div class="recording-info">' +
+            '<div class="recording-meta">' + label + '</div>' +
+            '<div class="recording-name" style="font-family:monospace;font-size:0.85rem;word-break:break-all;">' + escapeHtml(url) + '</div>' +
+        '</div>' +
+        '<div class="recording-actions">' +
+            '<button type="button" class="btn btn-primary btn-sm" data-url="' + escapeHtml(url) + '" onclick="copyTesterUrl(this)">' + t('Copy') + '</button>' +
+        '</div>' +
+    '</div>';
+}
+
+function updateTesterUrls() {
+    const el = document.getElementById('testerUrls');
+    if (!document.getElementById('testerUrl').value.trim()) {
+        el.classList.add('hidden');
+        return;
+    }
+    const params = testerParams();
+    el.innerHTML =
+        testerUrlRow(t('Proxied manifest'), location.origin + '/proxy/manifest.m3u8?' + params) +
+        testerUrlRow(t('Direct stream'), location.origin + '/proxy/stream?' + params) +
+        testerUrlRow(t('Web player'), location.origin + '/play?' + params);
+    el.classList.remove('hidden');
+}
+
+async function copyTesterUrl(btn) {
+    try {
+        await navigator.clipboard.writeText(btn.dataset.url);
+        showToast(t('URL copied'), 'success');
+    } catch (e) { showToast(t('Copy failed') + ': ' + e.message, 'error'); }
+}
+
+async function probeTestStream(e) {
+    e.preventDefault();
+    const btn = e.target.querySelector('button[type="submit"]');
+    const resultEl = document.getElementById('testerResult');
+    btn.disabled = true;
+    btn.textContent = t('Probing...');
+    resultEl.innerHTML = '';
+    try {
+        const res = await fetch('/api/probe?' + testerParams());
+        const body = await res.json().catch(() => ({}));
+        if (!res.ok) {
+            resultEl.innerHTML = '<div class="recording"><span class="recording-icon">❌</span><div class="recording-info">' +
+                '<div class="recording-name">' + t('Probe failed ({0})', res.status) + '</div>' +
+                '<div class="recording-meta">' + escapeHtml(body.error || res.statusText) + '</div></div></div>';
+            return;
+        }
+        resultEl.innerHTML = renderProbeResult(body);
+    } catch (e) { showToast(t('Error') + ': ' + e.message, 'error'); }
+    finally { btn.disabled = false; btn.textContent = t('Probe'); }
+}
+
+function renderProbeResult(p) {
+    const meta = [p.type, p.format, p.duration ? Math.round(p.duration) + 's' : t('live'),
+        p.bandwidth ? (p.bandwidth / 1000000).toFixed(2) + ' Mbit/s' : ''].filter(Boolean);
+    let html = '<div class="recording"><span class="recording-icon">✅</span><div class="recording-info">' +
+        '<div class="recording-name">' + escapeHtml(p.url) + '</div>' +
+        '<div class="recording-meta">' + meta.map(m => '<span>' + escapeHtml(m) + '</span>').join('') + '</div></div></div>';
+    if (p.drm) {
+        html += '<div class="recording"><span class="recording-icon">' + (p.drm.has_keys ? '🔓' : '🔒') + '</span><div class="recording-info">' +
+            '<div class="recording-name">DRM: ' + escapeHtml((p.drm.systems || []).join(', ')) + '</div>' +
+            '<div class="recording-meta"><span>KIDs: ' + escapeHtml((p.drm.kids || []).join(', ')) + '</span>' +
+            '<span>' + t(p.drm.has_keys ? 'Keys known' : 'Keys missing') + '</span></div></div></div>';
+    }
+    (p.tracks || []).forEach(t => {
+        const icon = t.type === 'video' ? '🎬' : t.type === 'audio' ? '🔊' : '💬';
+        const details = [t.codec, t.profile, t.width ? t.width + 'x' + t.height : '', t.frame_rate,
+            t.channels ? t.channels + ' ch' : '', t.sample_rate ? t.sample_rate + ' Hz' : '',
+            t.bitrate ? Math.round(t.bitrate / 1000) + ' kbit/s' : '', t.language].filter(Boolean);
+        html += '<div class="recording"><span class="recording-icon">' + icon + '</span><div class="recording-info">' +
+            '<div class="recording-meta">' + details.map(d => '<span>' + escapeHtml(d) + '</span>').join('') + '</div></div></div>';
+    });
+    return html;
+}
@@ -0,0 +1,148 @@
+// Package web holds the HTML templates and static assets of the dashboard and
+// the Stremio install page, embedded in the binary.
+//
+// Pages are html/template files under templates/, with dashboard sections as
+// partials under templates/partials/. Scripts and stylesheets live under
+// static/ and are served at /static/; templates link them with the asset
+// function, which versions each URL by a hash of the file so browsers can
+// cache assets until they change.
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"media-proxy-go/pkg/i18n"
+)
+
+// StaticPrefix is the path static assets are served under.
+const StaticPrefix = "/static/"
+
+//go:embed templates static
+var files embed.FS
+
+var (
+	static, _     = fs.Sub(files, "static")
+	assetVersions = hashAssets(static)
+	templates     = template.Must(template.New("").
+			Funcs(template.FuncMap{"asset": Asset}).
+			Funcs(translations(i18n.Default)).
+			ParseFS(files, "templates/*.html", "templates/partials/*.html"))
+)
+
+// Dashboard is the data of the dashboard page. It is also embedded in the
+// page as JSON for the section scripts, so adding a section only takes a
+// partial, a script and a feature flag here.
+type Dashboard struct {
+	Features DashboardFeatures `json:"features"`
+	Messages map[string]string `json:"messages"` // Translations of the page language, see i18n.Messages
+}
+
+// DashboardFeatures selects the dashboard sections and scripts.
+type DashboardFeatures struct {
+	DVR      bool `json:"dvr"`      // Recording form and lists
+	Channels bool `json:"channels"` // Saved channels
+	Health   bool `json:"health"`   // Channel health badges
+	Stremio  bool `json:"stremio"`  // Stremio addon link
+	Events   bool `json:"events"`   // Live updates from /api/events
+}
+
+// StremioHome is the data of the Stremio addon install page.
+type StremioHome struct {
+	InstallURL  template.URL // stremio:// URL, which html/template would otherwise reject
+	ManifestURL string
+}
+
+// StremioConfigure is the data of the Stremio addon configuration page.
+type StremioConfigure struct {
+	BaseURL          string // Public base URL the manifest URL is built on
+	Password         bool   // Ask for the API password
+	PasswordRequired bool   // The addon only serves installs with the password
+	Recordings       string // Catalog value of the DVR recordings, empty when unavailable
+	Channels         string // Catalog value of the live channels, empty when unavailable
+	External         StremioPlayback
+	Internal         StremioPlayback
+}
+
+// StremioPlayback is a playback URL mode offered on the configuration page.
+type StremioPlayback struct {
+	Value string // Mode stored in the configuration
+	URL   string // Base URL playback links use in this mode
+}
+
+// hashAssets returns a short content hash of every static asset by name.
+func hashAssets(fsys fs.FS) map[string]string {
+	versions := make(map[string]string)
+	fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		versions[name] = hex.EncodeToString(sum[:6])
+		return nil
+	})
+	return versions
+}
+
+// Asset returns the URL of a static asset, versioned by its content.
+func Asset(name string) string {
+	if v, ok := assetVersions[name]; ok {
+		return StaticPrefix + name + "?v=" + v
+	}
+	return StaticPrefix + name
+}
+
+// translations returns the template functions translating into lang: t
+// translates a message and lang returns the language.
+func translations(lang string) template.FuncMap {
+	return template.FuncMap{
+		"t":    func(message string) string { return i18n.Translate(lang, message) },
+		"lang": func() string { return lang },
+	}
+}
+
+// Render executes the named page template in lang and writes it as HTML. The
+// page is rendered to a buffer first, so a template error is reported instead
+// of a truncated page.
+func Render(w http.ResponseWriter, name, lang string, data any) error {
+	tmpl, err := templates.Clone()
+	if err != nil {
+		return fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Funcs(translations(lang)).ExecuteTemplate(&buf, name, data); err != nil {
+		return fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// StaticHandler serves the static assets under StaticPrefix. Requests for the
+// current version of an asset may be cached indefinitely; others revalidate.
+func StaticHandler() http.Handler {
+	files := http.StripPrefix(StaticPrefix, http.FileServerFS(static))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean(strings.TrimPrefix(r.URL.Path, StaticPrefix))
+		if v := r.URL.Query().Get("v"); v != "" && v == assetVersions[name] {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		files.ServeHTTP(w, r)
+	})
+}
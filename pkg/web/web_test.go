@@ -0,0 +1,154 @@
+package web
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"media-proxy-go/pkg/i18n"
+)
+
+func TestRender_Dashboard(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := Render(w, "dashboard.html", "en", Dashboard{Features: DashboardFeatures{DVR: true, Events: true}}); err != nil {
+		t.Fatal(err)
+	}
+	body := w.Body.String()
+	for _, want := range []string{`id="recordForm"`, Asset("recordings.js"), Asset("events.js"), `"dvr":true`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{`id="channelForm"`, Asset("channels.js"), `href="/stremio"`} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("dashboard has disabled feature %q", unwanted)
+		}
+	}
+}
+
+func TestRender_Translated(t *testing.T) {
+	w := httptest.NewRecorder()
+	page := Dashboard{Features: DashboardFeatures{DVR: true}, Messages: i18n.Messages("it")}
+	if err := Render(w, "dashboard.html", "it", page); err != nil {
+		t.Fatal(err)
+	}
+	body := w.Body.String()
+	for _, want := range []string{`<html lang="it">`, "Avvia registrazione", `"Delete":"Elimina"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard missing %q", want)
+		}
+	}
+	if got := w.Header().Get("Content-Language"); got != "it" {
+		t.Errorf("Content-Language = %q, want it", got)
+	}
+}
+
+// TestMessagesTranslated checks that every message the templates and scripts
+// translate has a translation in each catalog.
+func TestMessagesTranslated(t *testing.T) {
+	patterns := []*regexp.Regexp{
+		regexp.MustCompile(`\{\{t "([^"]+)"\}\}`),
+		regexp.MustCompile(`\bt\('([^']+)'`),
+	}
+	messages := map[string]bool{}
+	fs.WalkDir(files, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, _ := fs.ReadFile(files, name)
+		for _, p := range patterns {
+			for _, m := range p.FindAllStringSubmatch(string(data), -1) {
+				messages[m[1]] = true
+			}
+		}
+		return nil
+	})
+	if len(messages) == 0 {
+		t.Fatal("no translated messages found")
+	}
+	for _, lang := range i18n.Supported() {
+		if lang == i18n.Default {
+			continue
+		}
+		for message := range messages {
+			if _, ok := i18n.Messages(lang)[message]; !ok {
+				t.Errorf("%s: no translation for %q", lang, message)
+			}
+		}
+	}
+}
+
+func TestRender_StremioHome(t *testing.T) {
+	w := httptest.NewRecorder()
+	page := StremioHome{InstallURL: "stremio://proxy.example.com/stremio/manifest.json", ManifestURL: "https://proxy.example.com/stremio/manifest.json"}
+	if err := Render(w, "stremio.html", "en", page); err != nil {
+		t.Fatal(err)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `href="stremio://proxy.example.com/stremio/manifest.json"`) {
+		t.Error("install link missing or sanitized")
+	}
+	if !strings.Contains(body, `const url = "https://proxy.example.com/stremio/manifest.json"`) {
+		t.Error("manifest URL not passed to the copy script")
+	}
+}
+
+func TestRender_StremioConfigure(t *testing.T) {
+	w := httptest.NewRecorder()
+	page := StremioConfigure{
+		BaseURL:    "https://proxy.example.com/media",
+		Password:   true,
+		Recordings: "recordings",
+		External:   StremioPlayback{Value: "external", URL: "https://proxy.example.com/media"},
+		Internal:   StremioPlayback{Value: "internal", URL: "http://192.168.1.2:8080"},
+	}
+	if err := Render(w, "configure.html", "en", page); err != nil {
+		t.Fatal(err)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`<input type="password" id="password" autocomplete="current-password">`,
+		`value="recordings" checked`,
+		`value="external" checked> External (https://proxy.example.com/media)`,
+		`value="internal"> This address, e.g. on the local network (http://192.168.1.2:8080)`,
+		`const url = "https://proxy.example.com/media" + '/stremio/'`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("configuration page missing %q", want)
+		}
+	}
+	if strings.Contains(body, `name="catalog" value=""`) {
+		t.Error("configuration page offers an unavailable catalog")
+	}
+}
+
+func TestStaticHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       string
+		status       int
+		cacheControl string
+	}{
+		{"current version", Asset("dashboard.css"), http.StatusOK, "public, max-age=31536000, immutable"},
+		{"stale version", StaticPrefix + "dashboard.css?v=old", http.StatusOK, "no-cache"},
+		{"unknown asset", StaticPrefix + "missing.js", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			StaticHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Cache-Control"); tt.cacheControl != "" && got != tt.cacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.cacheControl)
+			}
+		})
+	}
+	if !strings.Contains(Asset("dashboard.css"), "?v=") {
+		t.Error("asset URL not versioned")
+	}
+}
@@ -0,0 +1,173 @@
+// Package workpool bounds how many jobs of a kind run at once, queueing the
+// others fairly between clients.
+package workpool
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"media-proxy-go/pkg/types"
+)
+
+// ErrSaturated is returned by Acquire when all workers are busy and the queue
+// is full.
+var ErrSaturated = errors.New("worker pool saturated")
+
+// Pool runs up to a fixed number of jobs at once. Jobs beyond that wait in a
+// bounded queue, served round-robin between clients so a client requesting
+// many jobs at once (a player prefetching segments, a recording catching up)
+// doesn't starve the others.
+type Pool struct {
+	mu        sync.Mutex
+	workers   int
+	maxQueued int
+	busy      int
+	queued    int
+	waiting   map[string][]*waiter // By client, oldest first
+	order     []string             // Clients with waiting jobs, next served first
+
+	completed uint64
+	rejected  uint64
+	abandoned uint64
+	waited    uint64
+	waitTotal time.Duration
+}
+
+// waiter is a job waiting for a worker.
+type waiter struct {
+	ready   chan struct{}
+	granted bool
+	queued  time.Time
+}
+
+// New returns a pool running up to workers jobs at once with up to
+// maxQueued jobs waiting.
+func New(workers, maxQueued int) *Pool {
+	return &Pool{
+		workers:   max(workers, 1),
+		maxQueued: max(maxQueued, 0),
+		waiting:   make(map[string][]*waiter),
+	}
+}
+
+// Acquire waits for a worker for a job of client, until ctx is done. It
+// returns ErrSaturated right away when the queue is full. The returned
+// release function must be called once the job is done.
+func (p *Pool) Acquire(ctx context.Context, client string) (release func(), err error) {
+	p.mu.Lock()
+	if p.busy < p.workers && p.queued == 0 {
+		p.busy++
+		p.mu.Unlock()
+		return p.releaser(), nil
+	}
+	if p.queued >= p.maxQueued {
+		p.rejected++
+		p.mu.Unlock()
+		return nil, ErrSaturated
+	}
+	w := &waiter{ready: make(chan struct{}), queued: time.Now()}
+	if len(p.waiting[client]) == 0 {
+		p.order = append(p.order, client)
+	}
+	p.waiting[client] = append(p.waiting[client], w)
+	p.queued++
+	p.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return p.releaser(), nil
+	case <-ctx.Done():
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if w.granted {
+		// A worker was handed over as ctx ended: pass it on
+		p.busy--
+		p.dispatch()
+	} else {
+		p.remove(client, w)
+	}
+	p.abandoned++
+	return nil, ctx.Err()
+}
+
+// releaser returns the function handing a worker back, once.
+func (p *Pool) releaser() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.busy--
+			p.completed++
+			p.dispatch()
+		})
+	}
+}
+
+// dispatch hands free workers to waiting jobs, one client after the other.
+// Caller must hold p.mu.
+func (p *Pool) dispatch() {
+	for p.busy < p.workers && len(p.order) > 0 {
+		client := p.order[0]
+		p.order = p.order[1:]
+		queue := p.waiting[client]
+		w := queue[0]
+		if len(queue) > 1 {
+			p.waiting[client] = queue[1:]
+			p.order = append(p.order, client)
+		} else {
+			delete(p.waiting, client)
+		}
+
+		p.queued--
+		p.busy++
+		p.waited++
+		p.waitTotal += time.Since(w.queued)
+		w.granted = true
+		close(w.ready)
+	}
+}
+
+// remove drops a waiting job of client from the queue. Caller must hold p.mu.
+func (p *Pool) remove(client string, w *waiter) {
+	queue := p.waiting[client]
+	i := slices.Index(queue, w)
+	if i < 0 {
+		return
+	}
+	queue = slices.Delete(queue, i, i+1)
+	p.queued--
+	if len(queue) > 0 {
+		p.waiting[client] = queue
+		return
+	}
+	delete(p.waiting, client)
+	if j := slices.Index(p.order, client); j >= 0 {
+		p.order = slices.Delete(p.order, j, j+1)
+	}
+}
+
+// Stats returns the pool's occupancy and counters.
+func (p *Pool) Stats() types.PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := types.PoolStats{
+		Workers:   p.workers,
+		Busy:      p.busy,
+		Queued:    p.queued,
+		MaxQueued: p.maxQueued,
+		Clients:   len(p.waiting),
+		Completed: p.completed,
+		Rejected:  p.rejected,
+		Abandoned: p.abandoned,
+	}
+	if p.waited > 0 {
+		stats.AvgWaitMs = float64(p.waitTotal.Microseconds()) / float64(p.waited) / 1000
+	}
+	return stats
+}
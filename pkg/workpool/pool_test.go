@@ -0,0 +1,129 @@
+package workpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// acquireAsync starts Acquire in a goroutine, returning the channel its
+// outcome is sent on.
+func acquireAsync(p *Pool, ctx context.Context, client string) chan func() {
+	done := make(chan func(), 1)
+	go func() {
+		release, err := p.Acquire(ctx, client)
+		if err != nil {
+			release = nil
+		}
+		done <- release
+	}()
+	return done
+}
+
+// waitQueued waits until n jobs are queued.
+func waitQueued(t *testing.T, p *Pool, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for p.Stats().Queued != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("queued = %d, want %d", p.Stats().Queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPool_Saturation(t *testing.T) {
+	p := New(1, 1)
+	release, err := p.Acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	queued := acquireAsync(p, context.Background(), "a")
+	waitQueued(t, p, 1)
+
+	if _, err := p.Acquire(context.Background(), "b"); !errors.Is(err, ErrSaturated) {
+		t.Fatalf("Acquire() on a full queue error = %v, want ErrSaturated", err)
+	}
+
+	release()
+	next := <-queued
+	if next == nil {
+		t.Fatal("queued job did not get the worker")
+	}
+	next()
+	release() // Releasing twice is a no-op
+
+	stats := p.Stats()
+	if stats.Busy != 0 || stats.Queued != 0 || stats.Completed != 2 || stats.Rejected != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestPool_Fairness(t *testing.T) {
+	p := New(1, 10)
+	release, _ := p.Acquire(context.Background(), "greedy")
+
+	// The greedy client queues three jobs before the other one queues its own
+	var mu sync.Mutex
+	var served []string
+	var wg sync.WaitGroup
+	enqueue := func(client string) {
+		wg.Add(1)
+		done := acquireAsync(p, context.Background(), client)
+		go func() {
+			defer wg.Done()
+			r := <-done
+			mu.Lock()
+			served = append(served, client)
+			mu.Unlock()
+			r()
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		enqueue("greedy")
+		waitQueued(t, p, i+1)
+	}
+	enqueue("polite")
+	waitQueued(t, p, 4)
+
+	release()
+	wg.Wait()
+
+	want := []string{"greedy", "polite", "greedy", "greedy"}
+	for i := range want {
+		if served[i] != want[i] {
+			t.Fatalf("served = %v, want %v", served, want)
+		}
+	}
+}
+
+func TestPool_Cancel(t *testing.T) {
+	p := New(1, 4)
+	release, _ := p.Acquire(context.Background(), "a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := acquireAsync(p, ctx, "b")
+	waiting := acquireAsync(p, context.Background(), "c")
+	waitQueued(t, p, 2)
+
+	cancel()
+	if r := <-canceled; r != nil {
+		t.Fatal("canceled job got a worker")
+	}
+	waitQueued(t, p, 1)
+
+	release()
+	r := <-waiting
+	if r == nil {
+		t.Fatal("waiting job did not get the worker")
+	}
+	r()
+
+	stats := p.Stats()
+	if stats.Abandoned != 1 || stats.Clients != 0 || stats.Busy != 0 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}